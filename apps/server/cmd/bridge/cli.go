@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	bridgev1 "github.com/daveisadork/solid-sdr/apps/server/proto/solidsdr/bridge/v1"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const cliUsage = `Usage:
+  %[1]s cli [flags] radios
+  %[1]s cli [flags] cmd RADIO COMMAND...
+  %[1]s cli [flags] connect RADIO
+  %[1]s cli [flags] monitor RADIO
+
+Talks to a radio through a running bridge's gRPC API (--grpc-listen).
+RADIO is the radio's host:port, as radios lists it.
+
+  radios   list the radios the bridge has found
+  cmd      send one command, print its reply, and exit non-zero if it failed
+  connect  send each line typed as a command, printing replies and status
+  monitor  print status for the --sub objects, or with --meters the meters
+           and GPS
+
+Flags:
+`
+
+var (
+	errCLIUsage   = errors.New("usage")
+	errCLIReply   = errors.New("command failed")
+	errCLIStream  = errors.New("bridge ended the session")
+	errNoConnect  = errors.New("bridge did not confirm the session")
+	errCLICommand = errors.New("unknown cli command")
+)
+
+// cliOptions are the flags every cli command takes.
+type cliOptions struct {
+	bridge   string
+	tls      bool
+	insecure bool
+	timeout  time.Duration
+	subs     []string
+	meters   bool
+	names    []string
+}
+
+// cliCommand implements "cli <command>" and returns the exit code.
+func cliCommand(args []string) int {
+	var opt cliOptions
+
+	fs := pflag.NewFlagSet("cli", pflag.ContinueOnError)
+	fs.StringVar(&opt.bridge, "bridge", "localhost:50051", "The bridge's gRPC address")
+	fs.BoolVar(&opt.tls, "tls", false, "Connect to the bridge over TLS")
+	fs.BoolVar(&opt.insecure, "insecure", false, "With --tls, skip verifying the bridge's certificate")
+	fs.DurationVar(&opt.timeout, "timeout", 10*time.Second, "How long cmd waits for its reply")
+	fs.StringSliceVar(&opt.subs, "sub", []string{"radio", "slice", "tx"}, "Objects monitor subscribes to")
+	fs.BoolVar(&opt.meters, "meters", false, "monitor prints meters instead of status")
+	fs.StringSliceVar(&opt.names, "meter", nil, "With --meters, only these meters, by name (e.g. SWR,FWDPWR)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, cliUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = runCLI(ctx, opt, fs.Args())
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errCLIUsage), errors.Is(err, errCLICommand):
+		fmt.Fprintf(os.Stderr, "error: %v\n\n", err)
+		fs.Usage()
+
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+		return 1
+	}
+}
+
+func runCLI(ctx context.Context, opt cliOptions, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no command", errCLIUsage)
+	}
+
+	creds := insecure.NewCredentials()
+	if opt.tls {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: opt.insecure}) //nolint:gosec // only when asked
+	}
+
+	conn, err := grpc.NewClient(opt.bridge, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("bridge %s: %w", opt.bridge, err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	client := bridgev1.NewBridgeClient(conn)
+
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "radios":
+		return cliRadios(ctx, client)
+	case "cmd":
+		if len(rest) < 2 {
+			return fmt.Errorf("%w: cmd RADIO COMMAND", errCLIUsage)
+		}
+
+		return cliCmd(ctx, client, opt, rest[0], strings.Join(rest[1:], " "))
+	case "connect":
+		if len(rest) != 1 {
+			return fmt.Errorf("%w: connect RADIO", errCLIUsage)
+		}
+
+		return cliConnect(ctx, client, rest[0])
+	case "monitor":
+		if len(rest) != 1 {
+			return fmt.Errorf("%w: monitor RADIO", errCLIUsage)
+		}
+
+		return cliMonitor(ctx, client, opt, rest[0])
+	default:
+		return fmt.Errorf("%w: %q", errCLICommand, cmd)
+	}
+}
+
+func cliRadios(ctx context.Context, client bridgev1.BridgeClient) error {
+	resp, err := client.ListRadios(ctx, &bridgev1.ListRadiosRequest{})
+	if err != nil {
+		return fmt.Errorf("list radios: %w", err)
+	}
+
+	for _, r := range resp.GetRadios() {
+		addr := r.GetAddress()
+		if addr == "" {
+			addr = "-"
+		}
+
+		fmt.Fprintf(os.Stdout, "%-21s %-10s %-8s %-12s %s\n", addr, r.GetModel(), r.GetCallsign(), r.GetStatus(), r.GetNickname())
+	}
+
+	return nil
+}
+
+// cliSession is an open Connect stream.
+type cliSession struct {
+	stream bridgev1.Bridge_ConnectClient
+	seq    uint32
+}
+
+// openCLISession connects to radio, returning once the bridge confirms it.
+func openCLISession(ctx context.Context, client bridgev1.BridgeClient, open *bridgev1.Open) (*cliSession, error) {
+	stream, err := client.Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	err = stream.Send(&bridgev1.ConnectRequest{Request: &bridgev1.ConnectRequest_Open{Open: open}})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", open.GetRadio(), err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", open.GetRadio(), err)
+	}
+
+	if resp.GetConnected() == nil {
+		return nil, errNoConnect
+	}
+
+	return &cliSession{stream: stream}, nil
+}
+
+// send sends command and returns its sequence number.
+func (s *cliSession) send(command string) (uint32, error) {
+	s.seq++
+
+	err := s.stream.Send(&bridgev1.ConnectRequest{Request: &bridgev1.ConnectRequest_Command{
+		Command: &bridgev1.Command{Sequence: s.seq, Command: command},
+	}})
+	if err != nil {
+		return 0, fmt.Errorf("send %q: %w", command, err)
+	}
+
+	return s.seq, nil
+}
+
+func (s *cliSession) recv() (*bridgev1.ConnectResponse, error) {
+	resp, err := s.stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil, errCLIStream
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("receive: %w", err)
+	}
+
+	return resp, nil
+}
+
+func cliCmd(ctx context.Context, client bridgev1.BridgeClient, opt cliOptions, radio, command string) error {
+	ctx, cancel := context.WithTimeout(ctx, opt.timeout)
+	defer cancel()
+
+	s, err := openCLISession(ctx, client, &bridgev1.Open{Radio: radio})
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = s.stream.CloseSend() }()
+
+	seq, err := s.send(command)
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := s.recv()
+		if err != nil {
+			return err
+		}
+
+		r := resp.GetReply()
+		if r == nil || r.GetSequence() != seq {
+			continue
+		}
+
+		if r.GetMessage() != "" {
+			fmt.Fprintln(os.Stdout, r.GetMessage())
+		}
+
+		if r.GetCode() != 0 {
+			return fmt.Errorf("%w: %08X", errCLIReply, r.GetCode())
+		}
+
+		return nil
+	}
+}
+
+func cliConnect(ctx context.Context, client bridgev1.BridgeClient, radio string) error {
+	s, err := openCLISession(ctx, client, &bridgev1.Open{Radio: radio})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "connected to %s; type commands, Ctrl-D to quit\n", radio)
+
+	go func() {
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+
+			_, err := s.send(line)
+			if err != nil {
+				break
+			}
+		}
+
+		_ = s.stream.CloseSend()
+	}()
+
+	for {
+		resp, err := s.recv()
+		if errors.Is(err, errCLIStream) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		printResponse(resp)
+	}
+}
+
+// printResponse prints a reply, status, message or other line as the radio
+// sent it.
+func printResponse(resp *bridgev1.ConnectResponse) {
+	switch {
+	case resp.GetReply() != nil:
+		r := resp.GetReply()
+		fmt.Fprintf(os.Stdout, "R%d|%08X|%s\n", r.GetSequence(), r.GetCode(), r.GetMessage())
+	case resp.GetStatus() != nil:
+		fmt.Fprintln(os.Stdout, resp.GetStatus().GetRaw())
+	case resp.GetMessage() != nil:
+		m := resp.GetMessage()
+		fmt.Fprintf(os.Stdout, "M%08X|%s\n", m.GetCode(), m.GetText())
+	case resp.GetLine() != nil:
+		fmt.Fprintln(os.Stdout, resp.GetLine().GetRaw())
+	}
+}
+
+func cliMonitor(ctx context.Context, client bridgev1.BridgeClient, opt cliOptions, radio string) error {
+	s, err := openCLISession(ctx, client, &bridgev1.Open{Radio: radio, Udp: opt.meters})
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = s.stream.CloseSend() }()
+
+	subs := opt.subs
+	if opt.meters {
+		subs = []string{"meter", "gps"}
+	}
+
+	for _, obj := range subs {
+		_, err = s.send("sub " + obj + " all")
+		if err != nil {
+			return err
+		}
+	}
+
+	meters := make(map[uint32]meterInfo)
+
+	for {
+		resp, err := s.recv()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !opt.meters:
+			if st := resp.GetStatus(); st != nil {
+				fmt.Fprintln(os.Stdout, st.GetRaw())
+			}
+		case resp.GetStatus().GetObject() == "gps":
+			printGPS(resp.GetStatus().GetAttributes())
+		case resp.GetStatus() != nil:
+			noteMeters(meters, resp.GetStatus().GetRaw())
+		case resp.GetMeters() != nil:
+			printMeters(meters, opt.names, resp.GetMeters())
+		}
+	}
+}
+
+// meterInfo is what a meter's status says about it.
+type meterInfo struct {
+	name string
+	unit string
+}
+
+// noteMeters records the meters a "meter" status line describes, e.g.
+// "S0|meter 7.src=SLC#7.num=0#7.nam=LEVEL#7.unit=dBm#".
+func noteMeters(meters map[uint32]meterInfo, raw string) {
+	_, body, ok := strings.Cut(raw, "|meter ")
+	if !ok {
+		return
+	}
+
+	for field := range strings.SplitSeq(body, "#") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+
+		idText, attr, ok := strings.Cut(key, ".")
+		if !ok {
+			continue
+		}
+
+		id, err := strconv.ParseUint(idText, 10, 32)
+		if err != nil {
+			continue
+		}
+
+		m := meters[uint32(id)]
+
+		switch attr {
+		case "nam":
+			m.name = value
+		case "unit":
+			m.unit = value
+		default:
+			continue
+		}
+
+		meters[uint32(id)] = m
+	}
+}
+
+func printMeters(meters map[uint32]meterInfo, names []string, pkt *bridgev1.Meters) {
+	now := time.Now().Format("15:04:05.000")
+
+	for _, m := range pkt.GetMeters() {
+		info, ok := meters[m.GetId()]
+		if !ok || (len(names) > 0 && !slices.Contains(names, info.name)) {
+			continue
+		}
+
+		fmt.Fprintf(os.Stdout, "%s %4d %-12s %10.2f %s\n", now, m.GetId(), info.name, meterValue(m.GetValue(), info.unit), info.unit)
+	}
+}
+
+// printGPS prints the lock, frequency error and time of a "gps" status, which
+// the radio sends as they change.
+func printGPS(attrs map[string]string) {
+	now := time.Now().Format("15:04:05.000")
+	status := strings.ReplaceAll(attrs["status"], "\x7f", " ")
+
+	fmt.Fprintf(os.Stdout, "%s gps  %-12s freq_error=%s time=%s grid=%s\n", now, status, attrs["freq_error"], attrs["time"], attrs["grid"])
+}
+
+// meterValue scales a raw meter value by its unit, as FlexLib does.
+func meterValue(raw int32, unit string) float64 {
+	switch unit {
+	case "dBm", "dBFS", "SWR":
+		return float64(raw) / 128
+	case "Volts", "Amps":
+		return float64(raw) / 256
+	case "degF", "degC":
+		return float64(raw) / 64
+	default:
+		return float64(raw)
+	}
+}
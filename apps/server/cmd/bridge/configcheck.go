@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/config"
+)
+
+const configUsage = `Usage:
+  %[1]s config check [flags]
+
+check reads the configuration from the flags given, FLEX_ environment
+variables and the config file, as the bridge would, validates it, and prints
+every setting with where it came from. It exits 1 if any problem is found.
+`
+
+// configCommand implements "config <command>" and returns the exit code.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintf(os.Stderr, configUsage, os.Args[0])
+
+		return 2
+	}
+
+	// config.Check reads the bridge's flags from os.Args.
+	os.Args = append(os.Args[:1], args[1:]...)
+
+	settings, problems := config.Check()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+
+	for _, s := range settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Value, s.Source)
+	}
+
+	_ = w.Flush()
+
+	for _, err := range problems {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+
+	if len(problems) > 0 {
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "configuration OK")
+
+	return 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/access"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/config"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/version"
+)
+
+// discoveryOnlyCommand runs just the discovery relay: the radio registry with
+// its HTTP, SSE and WebSocket feeds, and no WebRTC, so a small container on
+// the radios' network can make them visible to full bridges elsewhere.
+const discoveryOnlyCommand = "discovery-only"
+
+// runDiscoveryOnly serves the discovery relay until ctx is cancelled.
+func runDiscoveryOnly(ctx context.Context, v string, cfg config.Config) {
+	acl, err := access.NewACL(cfg.AllowCIDRs, cfg.DenyCIDRs)
+	if err != nil {
+		log.Fatalf("access config error: %v", err)
+	}
+
+	disco := startDiscovery(ctx, v, cfg, access.OriginChecker(wsOrigins(cfg)))
+
+	mux := http.NewServeMux()
+	mountDiscovery(mux, disco, cfg.AdminToken)
+	mux.HandleFunc("GET "+version.Path, version.Handler(version.Build(v)))
+
+	handler := http.Handler(mux)
+	if cfg.EnableCORS {
+		handler = withCORS(cfg, handler)
+	}
+
+	handler = acl.Handler(handler)
+
+	srv, err := newHTTPServers(cfg, handler, nil)
+	if err != nil {
+		log.Fatalf("http config error: %v", err)
+	}
+
+	log.Printf("solid-sdr-server %s (discovery only) listening on :%d (%s)", v, cfg.HTTPPort, srv.describe())
+	srv.listenAndServe()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	srv.shutdown(shutdownCtx)
+}
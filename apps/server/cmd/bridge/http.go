@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/config"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/dscp"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+var (
+	errHTTP3NeedsTLS          = errors.New("enable-http3 requires tls-cert and tls-key")
+	errWebTransportNeedsHTTP3 = errors.New("enable-webtransport requires enable-http3")
+)
+
+// httpServers bundles the TCP (HTTP/1.1 + HTTP/2) server with the optional
+// QUIC (HTTP/3) server so they start and stop together.
+type httpServers struct {
+	tcp  *http.Server
+	quic *http3.Server
+	// wt serves quic instead when WebTransport is enabled.
+	wt   *webtransport.Server
+	tls  bool
+	cert string
+	key  string
+}
+
+// newHTTPServers builds the servers; wt, when not nil, upgrades WebTransport
+// sessions and is wired into the HTTP/3 server.
+func newHTTPServers(cfg config.Config, handler http.Handler, wt *webtransport.Server) (*httpServers, error) {
+	useTLS := cfg.TLSCert != "" && cfg.TLSKey != ""
+	if cfg.EnableHTTP3 && !useTLS {
+		return nil, errHTTP3NeedsTLS
+	}
+
+	if wt != nil && !cfg.EnableHTTP3 {
+		return nil, errWebTransportNeedsHTTP3
+	}
+
+	addr := fmt.Sprintf(":%d", cfg.HTTPPort)
+
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.EnableHTTP2 && useTLS)
+	protocols.SetUnencryptedHTTP2(cfg.EnableH2C && !useTLS)
+
+	s := &httpServers{tls: useTLS, cert: cfg.TLSCert, key: cfg.TLSKey}
+
+	if cfg.EnableHTTP3 {
+		port := cfg.HTTP3Port
+		if port == 0 {
+			port = cfg.HTTPPort
+		}
+
+		s.quic = &http3.Server{
+			Addr:    fmt.Sprintf(":%d", port),
+			Handler: handler,
+		}
+
+		// Advertise HTTP/3 on every TCP response so browsers upgrade.
+		next := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = s.quic.SetQUICHeaders(w.Header())
+			next.ServeHTTP(w, r)
+		})
+
+		if wt != nil {
+			// The WebTransport server listens in place of the HTTP/3 one, so
+			// it needs the h3 ALPN the latter would otherwise add itself.
+			s.quic.TLSConfig = http3.ConfigureTLSConfig(&tls.Config{MinVersion: tls.VersionTLS13})
+			webtransport.ConfigureHTTP3Server(s.quic)
+
+			wt.H3 = s.quic
+			s.wt = wt
+		}
+	}
+
+	s.tcp = &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnState:         markConn(cfg.DSCPData),
+	}
+
+	return s, nil
+}
+
+// markConn marks each new connection with codePoint, so the WebSocket
+// tunnels' panadapter and the radio's lines queue behind audio at a router
+// with QoS.
+func markConn(codePoint int) func(net.Conn, http.ConnState) {
+	if codePoint == 0 {
+		return nil
+	}
+
+	return func(c net.Conn, st http.ConnState) {
+		if sc, ok := c.(syscall.Conn); ok && st == http.StateNew {
+			_ = dscp.Mark(sc, codePoint)
+		}
+	}
+}
+
+// describe summarizes the enabled protocols for the startup log.
+func (s *httpServers) describe() string {
+	p := s.tcp.Protocols
+
+	desc := "http/1.1"
+	if p.HTTP2() {
+		desc += "+h2"
+	}
+
+	if p.UnencryptedHTTP2() {
+		desc += "+h2c"
+	}
+
+	if s.tls {
+		desc += " (tls)"
+	}
+
+	if s.quic != nil {
+		desc += ", h3 on udp" + s.quic.Addr
+	}
+
+	if s.wt != nil {
+		desc += " with webtransport"
+	}
+
+	return desc
+}
+
+// listenAndServe starts every configured server and exits the process if any
+// of them fails for a reason other than shutdown.
+func (s *httpServers) listenAndServe() {
+	go func() {
+		var err error
+		if s.tls {
+			err = s.tcp.ListenAndServeTLS(s.cert, s.key)
+		} else {
+			err = s.tcp.ListenAndServe()
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	if s.quic == nil {
+		return
+	}
+
+	go func() {
+		var err error
+		if s.wt != nil {
+			err = s.wt.ListenAndServeTLS(s.cert, s.key)
+		} else {
+			err = s.quic.ListenAndServeTLS(s.cert, s.key)
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("http3 server error: %v", err)
+		}
+	}()
+}
+
+func (s *httpServers) shutdown(ctx context.Context) {
+	_ = s.tcp.Shutdown(ctx)
+
+	if s.wt != nil {
+		_ = s.wt.Close()
+	} else if s.quic != nil {
+		_ = s.quic.Shutdown(ctx)
+	}
+}
@@ -1,22 +1,64 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
 	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/daveisadork/solid-sdr/apps/server/internal/access"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/admin"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/alerts"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/antenna"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/audit"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/captions"
 	"github.com/daveisadork/solid-sdr/apps/server/internal/config"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/cors"
 	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/events"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/federation"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/grpcapi"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/history"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/kenwood"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/logbook"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/monitor"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/nat"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/proxy"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/recorder"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rigctl"
 	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/schedule"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/smartlink"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spectate"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spots"
 	"github.com/daveisadork/solid-sdr/apps/server/internal/static"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/status"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/storage"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/txaudio"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/tz"
 	"github.com/daveisadork/solid-sdr/apps/server/internal/version"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsdeflate"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsjtx"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wtapi"
+	"github.com/kardianos/service"
+	"github.com/quic-go/webtransport-go"
 )
 
+var errUnknownStorage = errors.New("unknown storage backend")
+
 func main() {
 	v := version.Resolve()
 
@@ -24,37 +66,466 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		os.Exit(serviceCommand(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "cli" {
+		os.Exit(cliCommand(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		os.Exit(selftestCommand(os.Args[2:]))
+	}
+
+	mode := run
+	if len(os.Args) > 1 && os.Args[1] == discoveryOnlyCommand {
+		mode = runDiscoveryOnly
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
 
-	// ---- Discovery ----
-	disco := discovery.New(discovery.Options{Port: cfg.DiscoveryPort})
+	tz.Set(cfg.Location)
+	log.SetFlags(0)
+	log.SetOutput(tz.LogWriter(os.Stderr))
+	log.Print(version.Build(v))
 
-	go func() {
-		err := disco.Run(context.Background())
+	if !service.Interactive() {
+		runService(v, cfg, mode)
+
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mode(ctx, v, cfg)
+}
+
+// run serves until ctx is cancelled, then drains client sessions and shuts
+// the HTTP servers down.
+func run(ctx context.Context, v string, cfg config.Config) {
+	// ---- Access control ----
+	acl, err := access.NewACL(cfg.AllowCIDRs, cfg.DenyCIDRs)
+	if err != nil {
+		log.Fatalf("access config error: %v", err)
+	}
+
+	checkOrigin := access.OriginChecker(wsOrigins(cfg))
+
+	// ---- Discovery and SmartLink ----
+	disco := startDiscovery(ctx, v, cfg, checkOrigin)
+
+	// ---- Command guard ----
+	cmdGuard, err := newGuard(cfg.Guard)
+	if err != nil {
+		log.Fatalf("guard config error: %v", err)
+	}
+
+	defer func() { _ = cmdGuard.Close() }()
+
+	// ---- Recording storage ----
+	store, err := newStorage(cfg.Storage)
+	if err != nil {
+		log.Fatalf("storage config error: %v", err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	// ---- Captions ----
+	var captioner *captions.Client
+
+	if cfg.CaptionsURL != "" {
+		captioner, err = captions.New(captions.Options{
+			URL:      cfg.CaptionsURL,
+			Model:    cfg.CaptionsModel,
+			APIKey:   cfg.CaptionsAPIKey,
+			Language: cfg.CaptionsLanguage,
+			Chunk:    cfg.CaptionsChunk,
+		})
 		if err != nil {
-			log.Printf("discovery terminated: %v", err)
+			log.Fatalf("captions config error: %v", err)
+		}
+	}
+
+	// ---- NAT ----
+	var (
+		natMapper *nat.Mapper
+		publicIPs []string
+	)
+
+	if cfg.EnableUPnP {
+		natMapper, publicIPs = mapPorts(cfg)
+		defer natMapper.Close()
+	}
+
+	// ---- Shared radio UDP ports ----
+	var udpPorts *rtc.UDPPorts
+
+	if cfg.UDPPortStart != 0 {
+		udpPorts, err = rtc.ListenUDPPorts(cfg.UDPPortStart, cfg.UDPPortEnd, cfg.UDPRcvBuf, cfg.DSCPMedia)
+		if err != nil {
+			log.Fatalf("udp ports: %v", err)
+		}
+
+		defer udpPorts.Close()
+	}
+
+	// ---- WSJT-X ----
+	var wsjtxBridge *wsjtx.Service
+
+	if cfg.WSJTXListen != "" {
+		wsjtxBridge, err = wsjtx.Listen(cfg.WSJTXListen)
+		if err != nil {
+			log.Fatalf("wsjtx config error: %v", err)
+		}
+
+		go func() {
+			err := wsjtxBridge.Run(ctx)
+			if err != nil {
+				log.Printf("wsjtx terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Antenna switches ----
+	antennas := newAntennas(cfg)
+	if antennas != nil {
+		go func() {
+			err := antennas.Run(ctx)
+			if err != nil {
+				log.Printf("antenna switches terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Logging software ----
+	var logBook *logbook.Service
+
+	if len(cfg.LogbookBroadcast) > 0 || cfg.LogbookListen != "" || cfg.LogbookFile != "" || len(cfg.LogbookForward) > 0 {
+		station := cfg.LogbookStation
+		if station == "" {
+			station, _ = os.Hostname()
+		}
+
+		logBook, err = logbook.New(logbook.Options{
+			Station:   station,
+			Broadcast: cfg.LogbookBroadcast,
+			Listen:    cfg.LogbookListen,
+			File:      cfg.LogbookFile,
+			Forward:   cfg.LogbookForward,
+		})
+		if err != nil {
+			log.Fatalf("logbook config error: %v", err)
+		}
+
+		go func() {
+			err := logBook.Run(ctx)
+			if err != nil {
+				log.Printf("logbook terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Spot feeds ----
+	var spotFeed *spots.Service
+
+	if len(cfg.SpotFeeds) > 0 {
+		spotFeed = spots.New(spots.Options{
+			Feeds:    cfg.SpotFeeds,
+			Callsign: cfg.SpotCallsign,
+			MaxAge:   cfg.SpotMaxAge,
+		})
+
+		go func() {
+			err := spotFeed.Run(ctx)
+			if err != nil {
+				log.Printf("spot feeds terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Activity history ----
+	var activity *history.Store
+
+	if cfg.HistoryDB != "" {
+		activity, err = history.Open(cfg.HistoryDB, cfg.HistoryRetention)
+		if err != nil {
+			log.Fatalf("history config error: %v", err)
+		}
+
+		go func() {
+			err := activity.Run(ctx)
+			if err != nil {
+				log.Printf("history terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Audit trail ----
+	var trail *audit.Log
+
+	if cfg.AuditLog != "" {
+		trail, err = audit.Open(cfg.AuditLog)
+		if err != nil {
+			log.Fatalf("audit config error: %v", err)
 		}
-	}()
+
+		go func() {
+			err := trail.Run(ctx)
+			if err != nil {
+				log.Printf("audit terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Events ----
+	bus := events.New()
+
+	go disco.WatchChanges(ctx, func(c discovery.RadioChange) {
+		bus.Publish("radio."+c.Kind, c.Radio)
+	})
+
+	// ---- Alerts ----
+	if len(cfg.Alerts.Rules) > 0 {
+		alerter := newAlerts(cfg.Alerts, v)
+
+		go func() {
+			err := alerter.Run(ctx, bus)
+			if err != nil {
+				log.Printf("alerts terminated: %v", err)
+			}
+		}()
+	}
 
 	// ---- RTC ----
+	fairness := rtc.FairnessOptions{
+		RateKbps: cfg.Fairness.RateKbps,
+		Priority: cfg.Fairness.Priority,
+		Weights:  cfg.Fairness.Weights,
+	}
+
+	spectators, err := spectate.New(cfg.SpectatorSecret)
+	if err != nil {
+		log.Fatalf("spectator config error: %v", err)
+	}
+
+	build := version.Build(v)
+	txAudio := newTXAudio(cfg.TXAudio)
+
 	rtcServer := rtc.New(disco, rtc.Options{
-		ICEPortStart: cfg.ICEPortStart,
-		ICEPortEnd:   cfg.ICEPortEnd,
-		STUN:         cfg.StunURLs,
-		NAT1To1IPs:   cfg.NAT1To1IPs,
-		Version:      v,
+		ICEPortStart:  cfg.ICEPortStart,
+		ICEPortEnd:    cfg.ICEPortEnd,
+		ICETCPPort:    cfg.ICETCPPort,
+		STUN:          cfg.StunURLs,
+		TURN:          rtc.TURNServer{URLs: cfg.TURNURLs, Username: cfg.TURNUsername, Credential: cfg.TURNCredential},
+		MediaDSCP:     cfg.DSCPMedia,
+		NAT1To1IPs:    cfg.NAT1To1IPs,
+		PublicIPs:     publicIPs,
+		Version:       v,
+		Build:         &build,
+		APILogFile:    cfg.APILogFile,
+		Guard:         cmdGuard,
+		Captions:      captioner,
+		Fairness:      fairness,
+		Recorder:      recorder.New(store, recorder.Options{FFmpeg: cfg.Storage.FFmpeg}),
+		Preflight:     cfg.Preflight,
+		MaxSessions:   cfg.MaxSessions,
+		AllowedRadios: cfg.AllowedRadios,
+		CheckOrigin:   checkOrigin,
+		Deflate:       wsdeflate.Policy{MinSize: cfg.WSDeflateMin},
+		RigctlSlices:  cfg.RigctlSlices,
+		WSJTX:         wsjtxBridge,
+		Antennas:      antennas,
+		Logbook:       logBook,
+		Spots:         spotFeed,
+		History:       activity,
+		MeterAlerts:   meterAlerts(cfg.MeterAlerts),
+		Events:        bus,
+		CWBuffer:      cfg.CWBuffer,
+		PTTKeepalive:  cfg.PTTKeepalive,
+		PTTMaxTX:      cfg.PTTMaxTX,
+		TXToken:       cfg.TXToken,
+		Spectators:    spectators,
+		Audit:         trail,
+		UserHeader:    cfg.AuditUserHeader,
+		RadioTimeout:  cfg.RadioTimeout,
+
+		AudioRedundancy: cfg.AudioRedundancy,
+		BandwidthShed:   cfg.BandwidthShed,
+		UDPPipeline: rtc.UDPPipelineOptions{
+			Workers:       cfg.UDPWorkers,
+			Queue:         cfg.UDPQueue,
+			ReceiveBuffer: cfg.UDPRcvBuf,
+			DSCP:          cfg.DSCPMedia,
+		},
+		LineBatch: rtc.LineBatchOptions{Delay: cfg.LineBatch, Bytes: cfg.LineBatchBytes},
+		StreamChannels: map[string]string{
+			rtc.StreamFFT:       cfg.ChannelFFT,
+			rtc.StreamWaterfall: cfg.ChannelWaterfall,
+			rtc.StreamMeters:    cfg.ChannelMeters,
+			rtc.StreamIQ:        cfg.ChannelIQ,
+		},
+		UDPPorts:     udpPorts,
+		SessionState: cfg.SessionState,
+		ResumeWindow: cfg.ResumeWindow,
+		StaleCleanup: cfg.StaleCleanup,
+		TXAudio:      txAudio,
+		Identity: rtc.ClientIdentity{
+			Program: cfg.ClientProgram,
+			Version: cfg.ClientVersion,
+			Station: cfg.ClientStation,
+		},
 	})
 
+	rtcServer.SetVerbose(cfg.Verbose)
+
+	err = rtcServer.RestoreSessions()
+	if err != nil {
+		log.Printf("restoring sessions: %v", err)
+	}
+
+	go rtcServer.PersistSessions(ctx)
+
+	// ---- Schedule ----
+	var scheduler *schedule.Service
+
+	if len(cfg.Schedule.Jobs) > 0 {
+		scheduler, err = newSchedule(cfg.Schedule, cfg.WakeBroadcast, cfg.Location, rtcServer, bus)
+		if err != nil {
+			log.Fatalf("schedule config error: %v", err)
+		}
+
+		go func() {
+			err := scheduler.Run(ctx)
+			if err != nil {
+				log.Printf("schedule terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Hamlib rigctld ----
+	if cfg.RigctlListen != "" {
+		rig := rigctl.New(rigctl.Options{Rig: rtcServer.Rig, Allowed: acl.Allowed})
+
+		go func() {
+			err := rig.ListenAndServe(ctx, cfg.RigctlListen)
+			if err != nil {
+				log.Printf("rigctl terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Kenwood CAT ----
+	if cfg.CATListen != "" {
+		cat := kenwood.New(kenwood.Options{Rig: rtcServer.ActiveRig, Allowed: acl.Allowed})
+
+		go func() {
+			err := cat.ListenAndServe(ctx, cfg.CATListen)
+			if err != nil {
+				log.Printf("cat terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- gRPC API ----
+	if cfg.GRPCListen != "" {
+		tlsConf, err := grpcTLS(cfg)
+		if err != nil {
+			log.Fatalf("grpc config error: %v", err)
+		}
+
+		api := grpcapi.New(grpcapi.Options{
+			RTC: rtcServer, Registry: disco.Registry(), Token: cfg.AdminToken,
+			Allowed: acl.Allowed, TLS: tlsConf,
+		})
+
+		go func() {
+			err := api.ListenAndServe(ctx, cfg.GRPCListen)
+			if err != nil {
+				log.Printf("grpc terminated: %v", err)
+			}
+		}()
+	}
+
+	// ---- Local audio monitor ----
+	var mon *monitor.Monitor
+
+	if cfg.MonitorRadio != "" {
+		// The microphone gets the TX audio chain's default profile.
+		micChain, _, _ := txAudio.For("")
+		mon = monitor.New(monitor.Options{
+			Radios: rtcServer, Radio: cfg.MonitorRadio, Device: cfg.MonitorDevice, FFmpeg: cfg.MonitorFFmpeg,
+			Station: cfg.MonitorStation, Frequency: cfg.MonitorFrequency, Mode: cfg.MonitorMode,
+			Mic: cfg.MonitorMic, MicChain: micChain, Events: bus,
+		})
+
+		go func() {
+			err := mon.Run(ctx)
+			if err != nil {
+				log.Printf("monitor terminated: %v", err)
+			}
+		}()
+	}
+
 	// ---- HTTP mux ----
 	mux := http.NewServeMux()
 	mux.Handle("/ws/signal", rtcServer)
 	mux.HandleFunc("/defaults.json", makeDefaultsHandler(cfg.DefaultsFile))
+	mountDiscovery(mux, disco, cfg.AdminToken)
+	mux.HandleFunc("GET "+version.Path, version.Handler(build))
+	mux.HandleFunc("GET "+rtc.ReadyPath, rtcServer.ReadyHandler)
+	mux.HandleFunc("GET "+rtc.ConnectCheckPath, rtcServer.ServeConnectCheck)
+	mux.HandleFunc("POST "+rtc.WHEPPath, rtcServer.ServeWHEP)
+	mux.HandleFunc("DELETE "+rtc.WHEPPath+"/{id}", rtcServer.EndWHEP)
+	mux.HandleFunc("GET "+rtc.AudioPath+"{file}", rtcServer.ServeAudio)
+	mux.HandleFunc("GET "+rtc.AudioPath+"{session}/{file}", rtcServer.ServeAudio)
+	mux.HandleFunc("GET "+rtc.UDPTunnelPath, rtcServer.ServeUDPTunnel)
+	mux.HandleFunc("GET "+rtc.RadioWSPath, rtcServer.ServeRadioWS)
+	mux.HandleFunc("GET "+rtc.SpectatePath+"{token}/{file...}", rtcServer.ServeSpectator)
+	mountStatus(mux, cfg.Status, rtcServer)
+	mux.Handle("GET /events", admin.RequireAuth(cfg.AdminToken, bus))
+	mux.Handle("GET "+rtc.MetricsPath, admin.RequireAuth(cfg.AdminToken, http.HandlerFunc(rtcServer.ServeMetrics)))
+	mountDebug(mux, cfg.AdminToken, rtcServer)
+
+	if cfg.FederationToken != "" {
+		mux.Handle("GET "+federation.Path, federation.NewHub(federation.HubOptions{
+			Token: cfg.FederationToken, Registry: disco.Registry(),
+		}))
+	}
+
+	var wt *webtransport.Server
+	if cfg.EnableWebTransport {
+		wt = &webtransport.Server{CheckOrigin: checkOrigin}
+		mux.Handle(wtapi.Path, wtapi.New(wtapi.Options{RTC: rtcServer, Server: wt}))
+	}
+
+	for _, pr := range cfg.Proxies {
+		h, err := proxy.New(proxy.Route{
+			Prefix: pr.Prefix, Upstream: pr.Upstream, Allow: pr.Allow, Methods: pr.Methods, Headers: pr.Headers,
+		})
+		if err != nil {
+			log.Fatalf("proxy config error: %v", err)
+		}
+
+		mux.Handle(pr.Prefix, h)
+	}
+
+	mux.Handle(admin.Prefix, admin.Handler(admin.Options{
+		Token: cfg.AdminToken, RTC: rtcServer, NAT: natMapper, Antennas: antennas, History: activity,
+		Schedule: scheduler, Audit: trail, Monitor: mon,
+	}))
 
 	if cfg.StaticDir != "" {
-		mux.Handle("/", http.FileServer(http.Dir(cfg.StaticDir)))
+		mux.Handle("/", static.Dir(cfg.StaticDir))
 	} else if h := static.Handler(); h != nil {
 		mux.Handle("/", h)
 	} else {
@@ -69,40 +540,207 @@ func main() {
 	}
 
 	if cfg.EnableCORS {
-		handler = withCORS(handler)
+		handler = withCORS(cfg, handler)
 	}
 
-	addr := fmt.Sprintf(":%d", cfg.HTTPPort)
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           handler,
-		ReadHeaderTimeout: 10 * time.Second,
+	// Spectator links reach their feeds from anywhere, like the link itself.
+	handler = acl.HandlerExcept(handler, rtcServer.AdmitsSpectator)
+
+	srv, err := newHTTPServers(cfg, handler, wt)
+	if err != nil {
+		log.Fatalf("http config error: %v", err)
 	}
 
-	go func() {
-		log.Printf("solid-sdr-server %s listening on %s", v, addr)
+	log.Printf("solid-sdr-server %s listening on :%d (%s)", v, cfg.HTTPPort, srv.describe())
+	srv.listenAndServe()
+
+	// ---- graceful shutdown ----
+	<-ctx.Done()
+
+	err = rtcServer.SaveSessions()
+	if err != nil {
+		log.Printf("saving sessions: %v", err)
+	}
+
+	log.Printf("shutting down; draining sessions for up to %s", cfg.DrainTimeout)
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	rtcServer.Drain(drainCtx, rtc.DrainOptions{Peer: readyPeer(drainCtx, cfg.DrainPeer)})
+	cancelDrain()
+
+	if activity != nil {
+		_ = activity.Close()
+	}
+
+	if trail != nil {
+		_ = trail.Close()
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	srv.shutdown(shutdownCtx)
+}
+
+// grpcTLS loads the HTTPS certificate for the gRPC API; nil without one.
+func grpcTLS(cfg config.Config) (*tls.Config, error) {
+	if cfg.TLSCert == "" || cfg.TLSKey == "" {
+		return nil, nil //nolint:nilnil // plaintext
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("load tls key pair: %w", err)
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// startDiscovery runs the LAN discovery relay, and the relay from a peer
+// bridge, the SmartLink poller and the federation edge when they are
+// configured, until ctx is cancelled.
+func startDiscovery(ctx context.Context, v string, cfg config.Config, checkOrigin func(*http.Request) bool) *discovery.Service {
+	disco := discovery.New(discovery.Options{
+		Port:          cfg.DiscoveryPort,
+		CheckOrigin:   checkOrigin,
+		Deflate:       wsdeflate.Policy{MinSize: cfg.WSDeflateMin},
+		SlowConsumer:  cfg.DiscoverySlowConsumer,
+		MaxBuffer:     cfg.DiscoveryMaxBuffer,
+		DedupWindow:   cfg.DiscoveryDedupWindow,
+		RelayToken:    cfg.DiscoveryRelayToken,
+		MACs:          cfg.RadioMACs,
+		WakeBroadcast: cfg.WakeBroadcast,
+	})
 
-		err := srv.ListenAndServe()
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("server error: %v", err)
+	go func() {
+		err := disco.Run(ctx)
+		if err != nil {
+			log.Printf("discovery terminated: %v", err)
 		}
 	}()
 
-	// ---- graceful shutdown ----
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
+	if cfg.DiscoveryRelayPeer != "" {
+		go func() {
+			err := disco.RunRelay(ctx, discovery.RelayOptions{
+				Peer:      cfg.DiscoveryRelayPeer,
+				Token:     cfg.DiscoveryRelayToken,
+				Broadcast: cfg.DiscoveryRelayBroadcast,
+			})
+			if err != nil {
+				log.Printf("discovery relay terminated: %v", err)
+			}
+		}()
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	if cfg.FederationHub != "" {
+		name := cfg.FederationName
+		if name == "" {
+			name, _ = os.Hostname()
+		}
+
+		go func() {
+			err := federation.RunEdge(ctx, federation.EdgeOptions{
+				Hub:      cfg.FederationHub,
+				Token:    cfg.FederationToken,
+				Name:     name,
+				Registry: disco.Registry(),
+			})
+			if err != nil {
+				log.Printf("federation edge terminated: %v", err)
+			}
+		}()
+	}
+
+	if cfg.SmartLinkToken != "" {
+		poller, err := smartlink.New(disco.Registry(), smartlink.Options{
+			Server:   cfg.SmartLinkServer,
+			Token:    cfg.SmartLinkToken,
+			AppName:  "solid-sdr-server " + v,
+			Interval: cfg.SmartLinkInterval,
+		})
+		if err != nil {
+			log.Fatalf("smartlink config error: %v", err)
+		}
 
-	_ = srv.Shutdown(ctx)
+		go poller.Run(ctx)
+	}
+
+	return disco
+}
+
+// mountDiscovery serves the radio registry and its live feeds, and to admins
+// the feed subscribers' stats and radio wake-up.
+func mountDiscovery(mux *http.ServeMux, disco *discovery.Service, adminToken string) {
+	mux.HandleFunc("GET /api/radios", disco.RadiosHandler)
+	mux.HandleFunc("GET /api/radios/events", disco.EventsHandler)
+	mux.Handle("POST /api/radios/{serial}/wake", admin.RequireAuth(adminToken, http.HandlerFunc(disco.WakeHandler)))
+	mux.HandleFunc("GET /ws/radios", disco.RadiosWSHandler)
+	mux.HandleFunc("GET /ws/discovery", disco.WSHandler)
+	mux.Handle("GET "+admin.Prefix+"discovery", admin.RequireAuth(adminToken, http.HandlerFunc(disco.FanoutHandler)))
+}
+
+// mountStatus serves the public status page when it is enabled.
+func mountStatus(mux *http.ServeMux, sc config.StatusConfig, rtcServer *rtc.Server) {
+	if !sc.Enabled {
+		return
+	}
+
+	page := status.New(status.Options{
+		Title:        sc.Title,
+		EmbedOrigins: sc.EmbedOrigins,
+		Station:      rtcServer.Station,
+		Show: status.Show{
+			Callsign:     sc.Callsign,
+			Model:        sc.Model,
+			Band:         sc.Band,
+			Mode:         sc.Mode,
+			Frequency:    sc.Frequency,
+			Listeners:    sc.Listeners,
+			Transmitting: sc.Transmitting,
+		},
+	})
+
+	mux.HandleFunc("GET "+status.PagePath, page.ServeHTML)
+	mux.HandleFunc("GET "+status.JSONPath, page.ServeJSON)
+}
+
+// mountDebug serves net/http/pprof, expvar and the runtime diagnostics under
+// /debug/, behind the admin API's auth. Both packages also register
+// themselves on http.DefaultServeMux, which the bridge never serves.
+func mountDebug(mux *http.ServeMux, adminToken string, rtcServer *rtc.Server) {
+	guard := func(h http.HandlerFunc) http.Handler { return admin.RequireAuth(adminToken, h) }
+
+	mux.Handle("GET /debug/pprof/", guard(pprof.Index))
+	mux.Handle("GET /debug/pprof/cmdline", guard(pprof.Cmdline))
+	mux.Handle("GET /debug/pprof/profile", guard(pprof.Profile))
+	mux.Handle("GET /debug/pprof/symbol", guard(pprof.Symbol))
+	mux.Handle("POST /debug/pprof/symbol", guard(pprof.Symbol))
+	mux.Handle("GET /debug/pprof/trace", guard(pprof.Trace))
+	mux.Handle("GET /debug/vars", admin.RequireAuth(adminToken, expvar.Handler()))
+	mux.Handle("GET "+rtc.DiagnosticsPath, guard(rtcServer.ServeDiagnostics))
+}
+
+// readyPeer returns peer if it is accepting sessions, so clients are only
+// handed to a bridge that will take them.
+func readyPeer(ctx context.Context, peer string) string {
+	if peer == "" {
+		return ""
+	}
+
+	err := rtc.CheckPeer(ctx, peer)
+	if err != nil {
+		log.Printf("not handing sessions to %s: %v", peer, err)
+
+		return ""
+	}
+
+	return peer
 }
 
 func isVersionFlag(v string) bool {
 	for _, arg := range os.Args[1:] {
 		if arg == "--version" || arg == "-version" || arg == "-V" {
-			_, _ = fmt.Fprintf(os.Stdout, "solid-sdr-server %s\n", v)
+			_, _ = fmt.Fprintln(os.Stdout, version.Build(v))
 
 			return true
 		}
@@ -111,6 +749,291 @@ func isVersionFlag(v string) bool {
 	return false
 }
 
+// wsOrigins returns the cross-origin patterns allowed to open WebSockets.
+// With CORS disabled only same-origin pages (and explicit ws-origins) may
+// connect; with CORS enabled the CORS origin list applies as well.
+func wsOrigins(cfg config.Config) []string {
+	origins := append([]string(nil), cfg.WSOrigins...)
+	if cfg.EnableCORS {
+		origins = append(origins, cfg.CORSOrigins...)
+	}
+
+	return origins
+}
+
+// newGuard builds the command guard from config. It returns nil (allow
+// everything) when neither rules nor an audit file are configured.
+func newGuard(gc config.GuardConfig) (*guard.Engine, error) {
+	if len(gc.Rules) == 0 && gc.AuditFile == "" {
+		return nil, nil //nolint:nilnil // nil engine means "no guard"
+	}
+
+	rules := make([]guard.Rule, 0, len(gc.Rules))
+	for _, r := range gc.Rules {
+		rules = append(rules, guard.Rule{
+			Category: guard.Category(r.Category),
+			Action:   guard.Action(r.Action),
+			Roles:    r.Roles,
+			Radios:   r.Radios,
+			MaxPower: r.MaxPower,
+		})
+	}
+
+	e, err := guard.New(guard.Policy{Rules: rules, Roles: gc.Roles, AuditFile: gc.AuditFile})
+	if err != nil {
+		return nil, fmt.Errorf("guard: %w", err)
+	}
+
+	log.Printf("[guard] %d rule(s) active", len(rules))
+
+	return e, nil
+}
+
+// newAntennas returns the antenna switch service, or nil when no switches are
+// configured or to be discovered.
+func newAntennas(cfg config.Config) *antenna.Service {
+	if len(cfg.AntennaSwitches) == 0 && !cfg.AntennaDiscover {
+		return nil
+	}
+
+	switches := make([]string, 0, len(cfg.AntennaSwitches))
+	for _, addr := range cfg.AntennaSwitches {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, strconv.Itoa(antenna.DefaultPort))
+		}
+
+		switches = append(switches, addr)
+	}
+
+	rules := make([]antenna.Rule, 0, len(cfg.AntennaRules))
+	for _, r := range cfg.AntennaRules {
+		rules = append(rules, antenna.Rule{
+			Switch:  r.Switch,
+			Radio:   r.Radio,
+			Port:    r.Port,
+			FromMHz: r.From,
+			ToMHz:   r.To,
+			Antenna: r.Antenna,
+		})
+	}
+
+	log.Printf("[antenna] %d switch(es), discovery %v, %d band-follow rule(s)", len(switches), cfg.AntennaDiscover, len(rules))
+
+	return antenna.New(antenna.Options{
+		Switches: switches,
+		Discover: cfg.AntennaDiscover,
+		Poll:     cfg.AntennaPoll,
+		Rules:    rules,
+	})
+}
+
+// newTXAudio builds the browser TX audio chain's profiles from config. It
+// returns nil when there are none.
+func newTXAudio(tc config.TXAudioConfig) *txaudio.Service {
+	profiles := make(map[string]txaudio.Chain, len(tc.Profiles))
+	for name, p := range tc.Profiles {
+		profiles[name] = txaudio.Chain{
+			Gain: p.Gain,
+			EQ:   p.EQ,
+			Compressor: txaudio.Compressor{
+				Threshold: p.Compressor.Threshold,
+				Ratio:     p.Compressor.Ratio,
+				Attack:    p.Compressor.Attack,
+				Release:   p.Compressor.Release,
+				Makeup:    p.Compressor.Makeup,
+			},
+			Limit: p.Limit,
+		}
+	}
+
+	return txaudio.New(txaudio.Options{FFmpeg: tc.FFmpeg, Profiles: profiles, Users: tc.Users, Default: tc.Default})
+}
+
+// newAlerts builds the alert service and its channels.
+func newAlerts(ac config.AlertsConfig, v string) *alerts.Service {
+	station := ac.Station
+	if station == "" {
+		station, _ = os.Hostname()
+	}
+
+	var notifiers []alerts.Notifier
+
+	for _, u := range ac.Webhooks {
+		notifiers = append(notifiers, alerts.Webhook{URL: u})
+	}
+
+	for _, u := range ac.Ntfy {
+		notifiers = append(notifiers, alerts.Ntfy{URL: u, Token: ac.NtfyToken})
+	}
+
+	if ac.Pushover.Token != "" {
+		notifiers = append(notifiers, alerts.Pushover{Token: ac.Pushover.Token, UserKey: ac.Pushover.UserKey})
+	}
+
+	if e := ac.Email; e.SMTP != "" {
+		notifiers = append(notifiers, alerts.Email{SMTP: e.SMTP, Username: e.Username, Password: e.Password, From: e.From, To: e.To})
+	}
+
+	rules := make([]alerts.Rule, 0, len(ac.Rules))
+	for _, r := range ac.Rules {
+		rules = append(rules, alerts.Rule{Kind: r.Kind, Radio: r.Radio, Meter: r.Meter, After: r.After})
+	}
+
+	if len(notifiers) == 0 {
+		log.Printf("[alerts] %d rule(s) but no channel to send alerts to", len(rules))
+	}
+
+	return alerts.New(alerts.Options{
+		Rules:     rules,
+		Cooldown:  ac.Cooldown,
+		Station:   station,
+		Version:   v,
+		Notifiers: notifiers,
+	})
+}
+
+// newSchedule builds the scheduler for the configured jobs, run on the RTC
+// server's radios.
+func newSchedule(sc config.ScheduleConfig, wakeBroadcast string, loc *time.Location, radios schedule.Radios, bus *events.Bus) (*schedule.Service, error) {
+	jobs := make([]schedule.Job, 0, len(sc.Jobs))
+
+	for _, j := range sc.Jobs {
+		spec, err := schedule.ParseSpec(j.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", j.Name, err)
+		}
+
+		jobs = append(jobs, schedule.Job{
+			Name: j.Name, Spec: spec, Action: j.Action, Radio: j.Radio, Profile: j.Profile,
+			Duration: j.Duration, Format: j.Format, MAC: j.MAC, URL: j.URL, Disabled: j.Disabled,
+		})
+	}
+
+	return schedule.New(schedule.Options{
+		Jobs:      jobs,
+		Radios:    radios,
+		Location:  loc,
+		Broadcast: cmp.Or(sc.Broadcast, wakeBroadcast),
+		Events:    bus,
+	}), nil
+}
+
+// meterAlerts turns the configured meter alerts into the RTC server's.
+func meterAlerts(alerts []config.MeterAlert) []rtc.MeterAlert {
+	out := make([]rtc.MeterAlert, 0, len(alerts))
+	for _, a := range alerts {
+		ma := rtc.MeterAlert{Meter: a.Meter, TX: a.TX}
+		if a.Above != nil {
+			ma.Limit = *a.Above
+		} else if a.Below != nil {
+			ma.Limit, ma.Below = *a.Below, true
+		}
+
+		out = append(out, ma)
+	}
+
+	return out
+}
+
+// maxMappedICEPorts bounds how many ports of a wide ICE range are mapped; home
+// gateways commonly cap the number of UPnP mappings.
+const maxMappedICEPorts = 64
+
+// mapPorts discovers the gateway, maps the ICE and HTTP ports and starts the
+// lease refresher. Failures are logged, not fatal: the bridge still works on
+// the LAN or with manual port forwarding.
+func mapPorts(cfg config.Config) (*nat.Mapper, []string) {
+	mapper, externalIP, err := nat.Discover()
+	if err != nil {
+		log.Printf("[nat] port mapping disabled: %v", err)
+
+		return nil, nil
+	}
+
+	log.Printf("[nat] gateway external address %s", externalIP)
+
+	end := int(cfg.ICEPortEnd)
+	if n := end - int(cfg.ICEPortStart) + 1; n > maxMappedICEPorts {
+		end = int(cfg.ICEPortStart) + maxMappedICEPorts - 1
+		log.Printf("[nat] ICE range has %d ports; mapping only %d..%d", n, cfg.ICEPortStart, end)
+	}
+
+	for port := int(cfg.ICEPortStart); port <= end; port++ {
+		err := mapper.MapUDP(port, "solid-sdr ICE", 0)
+		if err != nil {
+			log.Printf("[nat] %v", err)
+		}
+	}
+
+	err = mapper.MapTCP(cfg.HTTPPort, "solid-sdr HTTP", 0)
+	if err != nil {
+		log.Printf("[nat] %v", err)
+	}
+
+	if cfg.ICETCPPort != 0 {
+		err = mapper.MapTCP(int(cfg.ICETCPPort), "solid-sdr ICE-TCP", 0)
+		if err != nil {
+			log.Printf("[nat] %v", err)
+		}
+	}
+
+	if cfg.EnableHTTP3 {
+		port := cfg.HTTP3Port
+		if port == 0 {
+			port = cfg.HTTPPort
+		}
+
+		err = mapper.MapUDP(port, "solid-sdr HTTP/3", 0)
+		if err != nil {
+			log.Printf("[nat] %v", err)
+		}
+	}
+
+	mapper.StartRefresher(0)
+
+	return mapper, []string{externalIP}
+}
+
+// newStorage builds the recording store. Local storage is the default; s3
+// spills to Dir and uploads in the background.
+func newStorage(sc config.StorageConfig) (storage.Store, error) {
+	dir := sc.Dir
+	if dir == "" {
+		dir = "recordings"
+	}
+
+	var (
+		store storage.Store
+		err   error
+	)
+
+	switch sc.Backend {
+	case "", "local":
+		store = storage.NewLocal(dir)
+	case "s3":
+		store, err = storage.NewS3(storage.S3Options{
+			Endpoint:  sc.S3.Endpoint,
+			Region:    sc.S3.Region,
+			Bucket:    sc.S3.Bucket,
+			Prefix:    sc.S3.Prefix,
+			AccessKey: sc.S3.AccessKey,
+			SecretKey: sc.S3.SecretKey,
+			PathStyle: sc.S3.PathStyle,
+			SpillDir:  dir,
+		})
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownStorage, sc.Backend)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+
+	log.Printf("[storage] recordings -> %s", store)
+
+	return store, nil
+}
+
 func makeDefaultsHandler(path string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -150,18 +1073,47 @@ func withCOI(next http.Handler) http.Handler {
 	})
 }
 
-func withCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+func withCORS(cfg config.Config, next http.Handler) http.Handler {
+	def := cors.Policy{
+		AllowedOrigins:   cfg.CORSOrigins,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		AllowedHeaders:   cfg.CORSAllowHeaders,
+		ExposedHeaders:   cfg.CORSExposeHeaders,
+		MaxAge:           cfg.CORSMaxAge,
+	}
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+	// adminRoutes are the prefixes of the routes behind the admin token.
+	adminRoutes := []string{admin.Prefix, "/events", rtc.MetricsPath, "/debug/"}
 
-			return
+	routes := make([]cors.Route, 0, len(cfg.CORSRoutes)+len(adminRoutes))
+	for _, rc := range cfg.CORSRoutes {
+		p := def
+		if rc.Origins != nil {
+			p.AllowedOrigins = rc.Origins
 		}
 
-		next.ServeHTTP(w, r)
-	})
+		if rc.AllowCredentials != nil {
+			p.AllowCredentials = *rc.AllowCredentials
+		}
+
+		if rc.AllowHeaders != nil {
+			p.AllowedHeaders = rc.AllowHeaders
+		}
+
+		if rc.ExposeHeaders != nil {
+			p.ExposedHeaders = rc.ExposeHeaders
+		}
+
+		routes = append(routes, cors.Route{Prefix: rc.Prefix, Policy: p})
+	}
+
+	// The admin routes only take the origins configured for them: a page
+	// elsewhere must not read them, whatever the default allows.
+	for _, prefix := range adminRoutes {
+		if !slices.ContainsFunc(cfg.CORSRoutes, func(rc config.CORSRoute) bool { return rc.Prefix == prefix }) {
+			routes = append(routes, cors.Route{Prefix: prefix})
+		}
+	}
+
+	return cors.Handler(def, routes, next)
 }
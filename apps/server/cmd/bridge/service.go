@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/config"
+	"github.com/kardianos/service"
+)
+
+const serviceName = "solid-sdr-server"
+
+const serviceUsage = `Usage:
+  %[1]s service install [--service-user NAME] [discovery-only] [flags]
+  %[1]s service uninstall|start|stop|restart|status
+
+install registers the bridge with the system service manager (systemd,
+launchd or the Windows Service Control Manager). Any flags after install are
+baked into the service definition and the current directory becomes its
+working directory, so a config file found here keeps being found.
+`
+
+var errServiceUser = errors.New("--service-user needs a value")
+
+// runFunc serves until ctx is cancelled: run, or runDiscoveryOnly.
+type runFunc func(ctx context.Context, v string, cfg config.Config)
+
+// program adapts run to the service manager's start/stop callbacks.
+type program struct {
+	v      string
+	cfg    config.Config
+	run    runFunc
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (p *program) Start(service.Service) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		p.run(ctx, p.v, p.cfg)
+	}()
+
+	return nil
+}
+
+func (p *program) Stop(service.Service) error {
+	p.cancel()
+	<-p.done
+
+	return nil
+}
+
+// newServiceConfig describes the installed service. args are the bridge flags
+// to bake in.
+func newServiceConfig(args []string, user string) (*service.Config, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("working directory: %w", err)
+	}
+
+	sc := &service.Config{
+		Name:             serviceName,
+		DisplayName:      "Solid SDR Server",
+		Description:      "WebRTC bridge between browsers and FlexRadio transceivers",
+		Arguments:        args,
+		WorkingDirectory: wd,
+		UserName:         user,
+		Option:           service.KeyValue{},
+	}
+	platformServiceConfig(sc)
+
+	return sc, nil
+}
+
+// runService runs the bridge under the system service manager.
+func runService(v string, cfg config.Config, run runFunc) {
+	sc, err := newServiceConfig(nil, "")
+	if err != nil {
+		log.Fatalf("service: %v", err)
+	}
+
+	s, err := service.New(&program{v: v, cfg: cfg, run: run}, sc)
+	if err != nil {
+		log.Fatalf("service: %v", err)
+	}
+
+	err = s.Run()
+	if err != nil {
+		log.Fatalf("service: %v", err)
+	}
+}
+
+// serviceCommand implements "service <action>" and returns the exit code.
+func serviceCommand(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintf(os.Stderr, serviceUsage, os.Args[0])
+
+		return 2
+	}
+
+	action, rest := args[0], args[1:]
+
+	var (
+		bridgeArgs []string
+		user       string
+	)
+
+	if action == "install" {
+		var err error
+
+		bridgeArgs, user, err = splitServiceUser(rest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+			return 2
+		}
+	}
+
+	sc, err := newServiceConfig(bridgeArgs, user)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+		return 1
+	}
+
+	s, err := service.New(&program{}, sc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+
+		return 1
+	}
+
+	if action == "status" {
+		return printServiceStatus(s)
+	}
+
+	err = service.Control(s, action)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: service %s: %v\n\n", action, err)
+		fmt.Fprintf(os.Stderr, serviceUsage, os.Args[0])
+
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "service %s: ok\n", action)
+
+	if action == "install" && len(bridgeArgs) > 0 {
+		fmt.Fprintf(os.Stdout, "flags: %s\n", strings.Join(bridgeArgs, " "))
+	}
+
+	return 0
+}
+
+func printServiceStatus(s service.Service) int {
+	st, err := s.Status()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: service status: %v\n", err)
+
+		return 1
+	}
+
+	switch st {
+	case service.StatusRunning:
+		fmt.Fprintln(os.Stdout, "running")
+	case service.StatusStopped:
+		fmt.Fprintln(os.Stdout, "stopped")
+	case service.StatusUnknown:
+		fmt.Fprintln(os.Stdout, "unknown")
+	}
+
+	return 0
+}
+
+// splitServiceUser removes --service-user from the install arguments; every
+// other argument is passed through to the bridge.
+func splitServiceUser(args []string) (rest []string, user string, err error) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+
+		if v, ok := strings.CutPrefix(a, "--service-user="); ok {
+			user = v
+
+			continue
+		}
+
+		if a == "--service-user" {
+			if i+1 >= len(args) {
+				return nil, "", errServiceUser
+			}
+
+			i++
+			user = args[i]
+
+			continue
+		}
+
+		rest = append(rest, a)
+	}
+
+	return rest, user, nil
+}
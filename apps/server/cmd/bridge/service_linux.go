@@ -0,0 +1,33 @@
+package main
+
+import "github.com/kardianos/service"
+
+// systemdScript is kardianos/service's systemd unit with a few additions:
+// it waits for the network so discovery can bind, restarts promptly instead
+// of after two minutes, and grants CAP_NET_BIND_SERVICE so --http-port,
+// --discovery-port or ICE ports below 1024 still bind when a non-root
+// --service-user is set.
+const systemdScript = `[Unit]
+Description={{Description}}
+ConditionFileIsExecutable={{Path | cmdEscape}}
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+StartLimitInterval=5
+StartLimitBurst=10
+ExecStart={{Path | cmdEscape}}{{range Arguments}} {{. | cmd}}{{end}}
+{{if WorkingDirectory}}WorkingDirectory={{WorkingDirectory | cmdEscape}}
+{{end}}{{if UserName}}User={{UserName}}
+{{end}}AmbientCapabilities=CAP_NET_BIND_SERVICE
+Restart=on-failure
+RestartSec=5
+EnvironmentFile=-/etc/sysconfig/{{Name}}
+
+[Install]
+WantedBy=multi-user.target
+`
+
+func platformServiceConfig(sc *service.Config) {
+	sc.Option["SystemdScript"] = systemdScript
+}
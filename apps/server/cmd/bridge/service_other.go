@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "github.com/kardianos/service"
+
+func platformServiceConfig(sc *service.Config) {
+	// Windows: restart the service if it crashes and start it after boot
+	// settles, once the network is up.
+	sc.Option["OnFailure"] = "restart"
+	sc.Option["DelayedAutoStart"] = true
+	// macOS: launchd keeps the bridge running.
+	sc.Option["KeepAlive"] = true
+	sc.Option["RunAtLoad"] = true
+}
@@ -0,0 +1,129 @@
+package access
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestACL_DenyWins(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewACL([]string{"10.0.0.0/8"}, []string{"10.0.0.66"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"10.1.2.3":        true,
+		"10.0.0.66":       false,
+		"192.168.1.1":     false,
+		"::ffff:10.9.9.9": true,
+	}
+
+	for ip, want := range cases {
+		if got := a.Allowed(net.ParseIP(ip)); got != want {
+			t.Errorf("%s: got %v want %v", ip, got, want)
+		}
+	}
+}
+
+func TestACL_DenyOnly(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewACL(nil, []string{"2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Allowed(net.ParseIP("2001:db8::1")) {
+		t.Error("denied network should be rejected")
+	}
+
+	if !a.Allowed(net.ParseIP("192.0.2.1")) {
+		t.Error("empty allow list should admit everything else")
+	}
+}
+
+func TestNewACL_RejectsGarbage(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewACL([]string{"not-a-cidr"}, nil)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestACL_Handler(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewACL([]string{"127.0.0.1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.9:5555"
+	r.Header.Set("X-Forwarded-For", "127.0.0.1")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got %d want 403; forwarding headers must not be trusted", w.Code)
+	}
+}
+
+func TestACL_HandlerExcept(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewACL([]string{"127.0.0.1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := a.HandlerExcept(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }),
+		func(r *http.Request) bool { return r.URL.Path == "/signed" })
+
+	for path, want := range map[string]int{"/signed": http.StatusOK, "/": http.StatusForbidden} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "203.0.113.9:5555"
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != want {
+			t.Errorf("%s: got %d want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestOriginChecker(t *testing.T) {
+	t.Parallel()
+
+	check := OriginChecker([]string{"https://ui.example.com"})
+
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://bridge.local:8080", true},
+		{"https://ui.example.com", true},
+		{"https://evil.example.com", false},
+	}
+
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://bridge.local:8080/ws/signal", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+
+		if got := check(r); got != tc.want {
+			t.Errorf("origin %q: got %v want %v", tc.origin, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,846 @@
+// Package admin serves the /api/admin REST endpoints used to inspect and
+// control a running bridge.
+package admin
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/antenna"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/audit"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/history"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/monitor"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/nat"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/radiocheck"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/schedule"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spectate"
+)
+
+// Prefix is where the admin API is mounted.
+const Prefix = "/api/admin/"
+
+var errHistoryTime = errors.New("times must be RFC 3339, e.g. 2026-10-15T02:00:00Z")
+
+const (
+	udpCheckTimeout    = 3 * time.Second
+	udpCheckMaxTimeout = 30 * time.Second
+
+	drainTimeout = 5 * time.Minute
+)
+
+type Options struct {
+	// Token is the bearer token required on every request. When empty, the
+	// API only answers requests from loopback addresses that name the bridge
+	// by a loopback address and come from no other origin.
+	Token string
+	RTC   *rtc.Server
+	NAT   *nat.Mapper
+	// Antennas are the antenna switches; nil when none are configured.
+	Antennas *antenna.Service
+	// History is the activity history; nil when it is off.
+	History *history.Store
+	// Schedule runs the scheduled jobs; nil when none are configured.
+	Schedule *schedule.Service
+	// Audit is the trail of commands sent to radios; nil when it is off.
+	Audit *audit.Log
+	// Monitor is the local audio monitor; nil when it is off.
+	Monitor *monitor.Monitor
+}
+
+type loggingState struct {
+	Verbose bool `json:"verbose"`
+	Capture bool `json:"capture"`
+}
+
+type loggingPatch struct {
+	Verbose *bool `json:"verbose"`
+	Capture *bool `json:"capture"`
+}
+
+type drainState struct {
+	Draining bool   `json:"draining"`
+	Peer     string `json:"peer,omitempty"`
+	Sessions int    `json:"sessions"`
+}
+
+type drainRequest struct {
+	Peer    string `json:"peer"`
+	Reason  string `json:"reason"`
+	Timeout string `json:"timeout"` // Go duration, default 5m
+}
+
+type scheduleState struct {
+	Jobs []schedule.JobStatus `json:"jobs"`
+	// Muted are the radios whose TX is muted, "" for every radio, and why.
+	Muted map[string]string `json:"muted"`
+}
+
+type jobPatch struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type auditState struct {
+	Entries []audit.Entry `json:"entries"`
+	// Dropped counts entries lost since start because writing fell behind.
+	Dropped uint64 `json:"dropped"`
+}
+
+type spectateRequest struct {
+	TTL string `json:"ttl"` // Go duration, default 1h
+}
+
+type antennaSelect struct {
+	Antenna int `json:"antenna"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the admin API handler.
+func Handler(opt Options) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+Prefix+"sessions", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, opt.RTC.Sessions())
+	})
+	mux.HandleFunc("DELETE "+Prefix+"sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !opt.RTC.CloseSession(r.PathValue("id")) {
+			writeJSON(w, http.StatusNotFound, errorBody{Error: "no such session"})
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST "+Prefix+"sessions/{id}/ice-restart", func(w http.ResponseWriter, r *http.Request) {
+		err := opt.RTC.RestartICE(r.PathValue("id"))
+		if err != nil {
+			writeSessionError(w, err)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("POST "+Prefix+"sessions/{id}/spectate", func(w http.ResponseWriter, r *http.Request) {
+		handleSpectate(w, r, opt.RTC)
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats, ok := opt.RTC.Stats(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorBody{Error: "no such session"})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, stats)
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/meters", func(w http.ResponseWriter, r *http.Request) {
+		handleMeters(w, r, opt.RTC)
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/recording", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := opt.RTC.Recording(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, errorBody{Error: "no such session"})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("POST "+Prefix+"sessions/{id}/recording", func(w http.ResponseWriter, r *http.Request) {
+		handleStartRecording(w, r, opt.RTC)
+	})
+	mux.HandleFunc("DELETE "+Prefix+"sessions/{id}/recording", func(w http.ResponseWriter, r *http.Request) {
+		status, err := opt.RTC.StopRecording(r.PathValue("id"))
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, errorBody{Error: err.Error()})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/iq", func(w http.ResponseWriter, r *http.Request) {
+		handleIQRecording(w, r, opt.RTC.IQRecording)
+	})
+	mux.HandleFunc("POST "+Prefix+"sessions/{id}/iq", func(w http.ResponseWriter, r *http.Request) {
+		var req rtc.IQRecordingRequest
+
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+			return
+		}
+
+		handleIQRecording(w, r, func(id string) (rtc.IQRecordingStatus, error) {
+			return opt.RTC.StartIQRecording(id, req)
+		})
+	})
+	mux.HandleFunc("DELETE "+Prefix+"sessions/{id}/iq", func(w http.ResponseWriter, r *http.Request) {
+		handleIQRecording(w, r, opt.RTC.StopIQRecording)
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/profiles", func(w http.ResponseWriter, r *http.Request) {
+		handleRadioAction(w, r, opt.RTC.Profiles)
+	})
+	mux.HandleFunc("POST "+Prefix+"sessions/{id}/profiles", func(w http.ResponseWriter, r *http.Request) {
+		var req rtc.ProfileRequest
+
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+			return
+		}
+
+		handleRadioAction(w, r, func(id string) (rtc.RadioProfiles, error) {
+			return opt.RTC.ProfileAction(id, req)
+		})
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/mixer", func(w http.ResponseWriter, r *http.Request) {
+		handleRadioAction(w, r, opt.RTC.Mixer)
+	})
+	mux.HandleFunc("POST "+Prefix+"sessions/{id}/mixer", func(w http.ResponseWriter, r *http.Request) {
+		var req rtc.MixerRequest
+
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+			return
+		}
+
+		handleRadioAction(w, r, func(id string) (rtc.RadioMixer, error) {
+			return opt.RTC.MixerAction(id, req)
+		})
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/amplifiers", func(w http.ResponseWriter, r *http.Request) {
+		handleRadioAction(w, r, opt.RTC.Amplifiers)
+	})
+	mux.HandleFunc("POST "+Prefix+"sessions/{id}/atu", func(w http.ResponseWriter, r *http.Request) {
+		var req rtc.AmplifierRequest
+
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+			return
+		}
+
+		handleRadioAction(w, r, func(id string) (rtc.RadioAmplifiers, error) {
+			return opt.RTC.AmplifierAction(id, req)
+		})
+	})
+	mux.HandleFunc("GET "+Prefix+"sessions/{id}/gps", func(w http.ResponseWriter, r *http.Request) {
+		handleRadioAction(w, r, opt.RTC.GPS)
+	})
+	mux.HandleFunc("GET "+Prefix+"logging", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, loggingState{Verbose: opt.RTC.Verbose(), Capture: opt.RTC.Capture()})
+	})
+	mux.HandleFunc("PATCH "+Prefix+"logging", func(w http.ResponseWriter, r *http.Request) {
+		var p loggingPatch
+
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&p)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+			return
+		}
+
+		if p.Verbose != nil {
+			opt.RTC.SetVerbose(*p.Verbose)
+		}
+
+		if p.Capture != nil && !opt.RTC.SetCapture(*p.Capture) {
+			writeJSON(w, http.StatusConflict, errorBody{Error: "no api-log-file configured"})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, loggingState{Verbose: opt.RTC.Verbose(), Capture: opt.RTC.Capture()})
+	})
+	mux.HandleFunc("GET "+Prefix+"udp-check", handleUDPCheck)
+	mux.HandleFunc("GET "+Prefix+"drain", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, currentDrain(opt.RTC))
+	})
+	mux.HandleFunc("POST "+Prefix+"drain", func(w http.ResponseWriter, r *http.Request) {
+		handleDrain(w, r, opt.RTC)
+	})
+	mux.HandleFunc("DELETE "+Prefix+"drain", func(w http.ResponseWriter, _ *http.Request) {
+		opt.RTC.Resume()
+		writeJSON(w, http.StatusOK, currentDrain(opt.RTC))
+	})
+	mux.HandleFunc("GET "+Prefix+"nat", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, opt.NAT.Status())
+	})
+	mux.HandleFunc("GET "+Prefix+"antennas", func(w http.ResponseWriter, _ *http.Request) {
+		if opt.Antennas == nil {
+			writeJSON(w, http.StatusOK, []antenna.Switch{})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, opt.Antennas.Switches())
+	})
+	mux.HandleFunc("POST "+Prefix+"antennas/{switch}/ports/{port}", func(w http.ResponseWriter, r *http.Request) {
+		handleAntennaSelect(w, r, opt.Antennas)
+	})
+	mux.HandleFunc("GET "+Prefix+"monitor/mic", func(w http.ResponseWriter, r *http.Request) {
+		handleMic(w, r, opt.Monitor)
+	})
+	mux.HandleFunc("POST "+Prefix+"monitor/mic", func(w http.ResponseWriter, r *http.Request) {
+		handleMic(w, r, opt.Monitor)
+	})
+	mux.HandleFunc("GET "+Prefix+"audit", func(w http.ResponseWriter, r *http.Request) {
+		handleAudit(w, r, opt.Audit)
+	})
+	mux.HandleFunc("GET "+Prefix+"history", func(w http.ResponseWriter, r *http.Request) {
+		handleHistory(w, r, opt.History, func(q url.Values) (any, error) {
+			from, to, err := timeRange(q)
+			if err != nil {
+				return nil, err
+			}
+
+			limit, _ := strconv.Atoi(q.Get("limit"))
+
+			return opt.History.Events(r.Context(), q.Get("radio"), from, to, limit)
+		})
+	})
+	mux.HandleFunc("GET "+Prefix+"history/at", func(w http.ResponseWriter, r *http.Request) {
+		handleHistory(w, r, opt.History, func(q url.Values) (any, error) {
+			at, err := time.Parse(time.RFC3339, q.Get("time"))
+			if err != nil {
+				return nil, errHistoryTime
+			}
+
+			return opt.History.At(r.Context(), q.Get("radio"), at)
+		})
+	})
+	mux.HandleFunc("GET "+Prefix+"history/heatmap", func(w http.ResponseWriter, r *http.Request) {
+		handleHistory(w, r, opt.History, func(q url.Values) (any, error) {
+			from, to, err := timeRange(q)
+			if err != nil {
+				return nil, err
+			}
+
+			bucket, err := time.ParseDuration(cmp.Or(q.Get("bucket"), "1h"))
+			if err != nil {
+				return nil, fmt.Errorf("bucket: %w", err)
+			}
+
+			return opt.History.Heatmap(r.Context(), q.Get("radio"), from, to, bucket)
+		})
+	})
+	mux.HandleFunc("GET "+Prefix+"schedule", func(w http.ResponseWriter, _ *http.Request) {
+		state := scheduleState{Jobs: []schedule.JobStatus{}, Muted: opt.RTC.TXMuted()}
+		if opt.Schedule != nil {
+			state.Jobs = opt.Schedule.Jobs()
+		}
+
+		writeJSON(w, http.StatusOK, state)
+	})
+	mux.HandleFunc("POST "+Prefix+"schedule/{job}/run", func(w http.ResponseWriter, r *http.Request) {
+		handleJob(w, opt.Schedule, func(svc *schedule.Service) (schedule.JobStatus, error) {
+			return svc.RunNow(r.Context(), r.PathValue("job"))
+		})
+	})
+	mux.HandleFunc("PATCH "+Prefix+"schedule/{job}", func(w http.ResponseWriter, r *http.Request) {
+		var p jobPatch
+
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&p)
+		if err != nil || p.Enabled == nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "body must be {\"enabled\": true|false}"})
+
+			return
+		}
+
+		handleJob(w, opt.Schedule, func(svc *schedule.Service) (schedule.JobStatus, error) {
+			return svc.SetEnabled(r.PathValue("job"), *p.Enabled)
+		})
+	})
+
+	return RequireAuth(opt.Token, mux)
+}
+
+// handleUDPCheck tests the bridge↔radio UDP leg for ?radio=host:port, with
+// optional udpPort and timeout (Go duration, default 3s, max 30s).
+func handleUDPCheck(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	radio := q.Get("radio")
+	if radio == "" {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "radio=host:port is required"})
+
+		return
+	}
+
+	udpPort, _ := strconv.Atoi(q.Get("udpPort"))
+
+	timeout := udpCheckTimeout
+	if d, err := time.ParseDuration(q.Get("timeout")); err == nil && d > 0 {
+		timeout = min(d, udpCheckMaxTimeout)
+	}
+
+	writeJSON(w, http.StatusOK, radiocheck.UDP(r.Context(), radio, udpPort, timeout))
+}
+
+// handleSpectate mints a spectator link to a session, for sharing its audio
+// and panadapter read-only.
+func handleSpectate(w http.ResponseWriter, r *http.Request, srv *rtc.Server) {
+	var req spectateRequest
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+		return
+	}
+
+	ttl := spectate.DefaultTTL
+	if req.TTL != "" {
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 || ttl > spectate.MaxTTL {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "ttl must be a positive duration up to " + spectate.MaxTTL.String()})
+
+			return
+		}
+	}
+
+	link, ok := srv.SpectateLink(r.PathValue("id"), ttl)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: "no such session"})
+
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, link)
+}
+
+// handleDrain starts a maintenance drain: new sessions are refused and
+// connected clients are told to reconnect to the optional peer bridge, which
+// must report itself ready first. The drain runs in the background; sessions
+// left after the timeout are disconnected.
+func handleDrain(w http.ResponseWriter, r *http.Request, srv *rtc.Server) {
+	var req drainRequest
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+		return
+	}
+
+	timeout := drainTimeout
+	if req.Timeout != "" {
+		timeout, err = time.ParseDuration(req.Timeout)
+		if err != nil || timeout <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "timeout must be a positive duration"})
+
+			return
+		}
+	}
+
+	if req.Peer != "" {
+		err = rtc.CheckPeer(r.Context(), req.Peer)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, errorBody{Error: err.Error()})
+
+			return
+		}
+	}
+
+	if req.Reason == "" {
+		req.Reason = "bridge maintenance"
+	}
+
+	if !srv.BeginDrain(timeout, rtc.DrainOptions{Reason: req.Reason, Peer: req.Peer}) {
+		writeJSON(w, http.StatusConflict, errorBody{Error: "already draining"})
+
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, currentDrain(srv))
+}
+
+// handleStartRecording starts or schedules a session recording. The body is
+// an rtc.RecordingRequest; an empty body records the session's mix to Ogg.
+func handleStartRecording(w http.ResponseWriter, r *http.Request, srv *rtc.Server) {
+	var req rtc.RecordingRequest
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+		return
+	}
+
+	status, err := srv.StartRecording(r.PathValue("id"), req)
+	if err != nil {
+		writeSessionError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, status)
+}
+
+// handleIQRecording runs an IQ recording action for the session in the path:
+// 404 for an unknown session, 409 when the action fails (no radio or DAX IQ
+// stream, already recording), and 201 for a started recording.
+func handleIQRecording(w http.ResponseWriter, r *http.Request, action func(id string) (rtc.IQRecordingStatus, error)) {
+	status, err := action(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+
+		return
+	}
+
+	code := http.StatusOK
+	if r.Method == http.MethodPost {
+		code = http.StatusCreated
+	}
+
+	writeJSON(w, code, status)
+}
+
+// handleRadioAction runs a profile, amplifier or GPS action for the session
+// in the path: 404 for an unknown session, and 409 when the action fails (no
+// radio, sandboxed, refused by the guard or the radio).
+func handleRadioAction[T any](w http.ResponseWriter, r *http.Request, action func(id string) (T, error)) {
+	state, err := action(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, state)
+}
+
+// writeSessionError writes err from an action on a session: 404 for an
+// unknown session, 409 for any other failure.
+func writeSessionError(w http.ResponseWriter, err error) {
+	code := http.StatusConflict
+	if errors.Is(err, rtc.ErrNoSession) {
+		code = http.StatusNotFound
+	}
+
+	writeJSON(w, code, errorBody{Error: err.Error()})
+}
+
+// handleMic reports the local audio monitor's microphone or, for a POST,
+// applies the request in the body: 404 without a monitor or microphone, 409
+// when it cannot be applied.
+func handleMic(w http.ResponseWriter, r *http.Request, mon *monitor.Monitor) {
+	if mon == nil {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: "no audio monitor configured"})
+
+		return
+	}
+
+	var (
+		state monitor.MicState
+		err   error
+	)
+
+	if r.Method == http.MethodPost {
+		var req monitor.MicRequest
+
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+			return
+		}
+
+		state, err = mon.SetMic(req)
+	} else {
+		state, err = mon.Mic()
+	}
+
+	switch {
+	case errors.Is(err, monitor.ErrNoMic):
+		writeJSON(w, http.StatusNotFound, errorBody{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusConflict, errorBody{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, state)
+	}
+}
+
+// handleAntennaSelect switches a switch port to the antenna in the body: 404
+// for an unknown switch, 409 when the switch refuses or is unreachable.
+func handleAntennaSelect(w http.ResponseWriter, r *http.Request, svc *antenna.Service) {
+	var req antennaSelect
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+		return
+	}
+
+	port, err := strconv.Atoi(r.PathValue("port"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: "port must be a number"})
+
+		return
+	}
+
+	if svc == nil {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: "no antenna switches configured"})
+
+		return
+	}
+
+	err = svc.Select(r.Context(), r.PathValue("switch"), port, req.Antenna)
+
+	switch {
+	case errors.Is(err, antenna.ErrUnknownSwitch):
+		writeJSON(w, http.StatusNotFound, errorBody{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusConflict, errorBody{Error: err.Error()})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleJob runs or changes a scheduled job, answering with its status: 404
+// for an unknown job, 409 when a run fails.
+func handleJob(w http.ResponseWriter, svc *schedule.Service, act func(*schedule.Service) (schedule.JobStatus, error)) {
+	if svc == nil {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: "no scheduled jobs configured"})
+
+		return
+	}
+
+	status, err := act(svc)
+
+	switch {
+	case errors.Is(err, schedule.ErrUnknownJob):
+		writeJSON(w, http.StatusNotFound, errorBody{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusConflict, errorBody{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, status)
+	}
+}
+
+// handleMeters returns a session's downsampled meter history, taking
+// ?names=SWR,FWDPWR, ?since=5m and ?points=300.
+func handleMeters(w http.ResponseWriter, r *http.Request, srv *rtc.Server) {
+	q := rtc.MeterQuery{}
+
+	if v := r.URL.Query().Get("names"); v != "" {
+		q.Names = strings.Split(v, ",")
+	}
+
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "since: " + err.Error()})
+
+			return
+		}
+
+		q.Since = d
+	}
+
+	if v := r.URL.Query().Get("points"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{Error: "points: " + err.Error()})
+
+			return
+		}
+
+		q.Points = n
+	}
+
+	series, ok := srv.Meters(r.PathValue("id"), q)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: "no such session"})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, series)
+}
+
+// handleHistory answers a history query, or 404 when history is off and 400
+// when the query is refused.
+func handleHistory(w http.ResponseWriter, r *http.Request, store *history.Store, query func(url.Values) (any, error)) {
+	if store == nil {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: "activity history is not enabled"})
+
+		return
+	}
+
+	v, err := query(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, v)
+}
+
+// handleAudit answers a query of the audit trail: the newest commands in
+// ?from= to ?to=, optionally only those of ?user=, ?ip=, ?session= or
+// ?radio=, or containing ?contains=.
+func handleAudit(w http.ResponseWriter, r *http.Request, trail *audit.Log) {
+	if trail == nil {
+		writeJSON(w, http.StatusNotFound, errorBody{Error: "audit log is not enabled"})
+
+		return
+	}
+
+	q := r.URL.Query()
+
+	from, to, err := timeRange(q)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+		return
+	}
+
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	entries, err := trail.Query(audit.Query{
+		From: from, To: to, User: q.Get("user"), ClientIP: q.Get("ip"), Session: q.Get("session"),
+		Radio: q.Get("radio"), Contains: q.Get("contains"), Limit: limit,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody{Error: err.Error()})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, auditState{Entries: entries, Dropped: trail.Dropped()})
+}
+
+// timeRange reads ?from= and ?to= (RFC 3339); to defaults to now and from to
+// a day before to.
+func timeRange(q url.Values) (time.Time, time.Time, error) {
+	to := time.Now()
+
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errHistoryTime
+		}
+
+		to = t
+	}
+
+	from := to.Add(-24 * time.Hour)
+
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errHistoryTime
+		}
+
+		from = t
+	}
+
+	return from, to, nil
+}
+
+func currentDrain(srv *rtc.Server) drainState {
+	return drainState{Draining: srv.Draining(), Peer: srv.DrainPeer(), Sessions: len(srv.Sessions())}
+}
+
+// RequireAuth guards next as the admin API is guarded: by the bearer token,
+// or to same-origin loopback clients when token is empty.
+func RequireAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(token, r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSON(w, http.StatusUnauthorized, errorBody{Error: "unauthorized"})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func authorized(token string, r *http.Request) bool {
+	if token == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return false
+		}
+
+		ip := net.ParseIP(host)
+
+		return ip != nil && ip.IsLoopback() && sameOriginLoopback(r)
+	}
+
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// sameOriginLoopback reports whether r was made from the bridge host itself
+// rather than by a page a browser there has open: it must name the bridge
+// by a loopback address, which a rebound DNS name does not, and must not
+// come from another origin.
+func sameOriginLoopback(r *http.Request) bool {
+	if !loopbackHost(r.Host) {
+		return false
+	}
+
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// loopbackHost reports whether host, with or without a port, is localhost
+// or a loopback address.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
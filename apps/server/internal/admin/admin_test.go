@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+)
+
+func TestAuthorized_Token(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodGet, Prefix+"sessions", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+
+	if authorized("s3cret", r) {
+		t.Error("missing bearer token must be rejected even from loopback")
+	}
+
+	r.Header.Set("Authorization", "Bearer s3cret")
+
+	if !authorized("s3cret", r) {
+		t.Error("valid bearer token should be accepted")
+	}
+}
+
+func TestAuthorized_LoopbackOnlyWithoutToken(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+Prefix+"sessions", nil)
+
+	r.RemoteAddr = "[::1]:1234"
+	if !authorized("", r) {
+		t.Error("loopback should be allowed when no token is configured")
+	}
+
+	r.RemoteAddr = "192.0.2.7:1234"
+	if authorized("", r) {
+		t.Error("remote clients must be rejected when no token is configured")
+	}
+}
+
+func TestAuthorized_CrossSiteWithoutToken(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		name   string
+		host   string
+		header map[string]string
+		want   bool
+	}{
+		{"same origin", "localhost:8080", map[string]string{"Origin": "http://localhost:8080"}, true},
+		{"loopback address", "127.0.0.1:8080", map[string]string{"Sec-Fetch-Site": "same-origin"}, true},
+		{"typed in the address bar", "[::1]:8080", map[string]string{"Sec-Fetch-Site": "none"}, true},
+		{"cross-site page", "localhost:8080", map[string]string{"Origin": "https://evil.example"}, false},
+		{"another local port", "localhost:8080", map[string]string{"Origin": "http://localhost:3000"}, false},
+		{"cross-site without Origin", "localhost:8080", map[string]string{"Sec-Fetch-Site": "cross-site"}, false},
+		{"DNS rebinding", "rebind.evil.example:8080", nil, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, Prefix+"sessions", nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+		r.Host = c.host
+
+		for k, v := range c.header {
+			r.Header.Set(k, v)
+		}
+
+		if got := authorized("", r); got != c.want {
+			t.Errorf("%s: authorized = %t, want %t", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSessionActions_UnknownSession(t *testing.T) {
+	t.Parallel()
+
+	srv := rtc.New(nil, rtc.Options{ICEPortStart: 50000, ICEPortEnd: 50100})
+
+	for _, path := range []string{"ice-restart", "recording", "iq", "profiles"} {
+		r := httptest.NewRequest(http.MethodPost, Prefix+"sessions/nope/"+path, strings.NewReader("{}"))
+		r.SetPathValue("id", "nope")
+
+		w := httptest.NewRecorder()
+
+		switch path {
+		case "ice-restart":
+			writeSessionError(w, srv.RestartICE("nope"))
+		case "recording":
+			handleStartRecording(w, r, srv)
+		case "iq":
+			handleIQRecording(w, r, srv.IQRecording)
+		case "profiles":
+			handleRadioAction(w, r, srv.Profiles)
+		}
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, w.Code)
+		}
+	}
+}
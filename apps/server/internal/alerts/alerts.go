@@ -0,0 +1,339 @@
+// Package alerts notifies a station's owner of trouble: a radio dropping off
+// the network, a meter alert such as the PA running hot, a transmission that
+// runs on, or the bridge restarting. It follows the bridge's event stream and
+// sends each alert to webhooks, ntfy topics, Pushover or email.
+package alerts
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/events"
+)
+
+// Kinds of Rule.
+const (
+	// KindRadioLost is a radio dropping out of discovery.
+	KindRadioLost = "radio-lost"
+	// KindMeter is a meter alert being raised.
+	KindMeter = "meter"
+	// KindLongTX is a radio transmitting for longer than a rule's After.
+	KindLongTX = "long-tx"
+	// KindRestart is the bridge starting.
+	KindRestart = "restart"
+)
+
+const (
+	// DefaultCooldown is how often the same alert is sent at most.
+	DefaultCooldown = 15 * time.Minute
+
+	// queueSize bounds the alerts waiting to be sent.
+	queueSize = 64
+	// checkInterval is how often running transmissions are checked.
+	checkInterval = time.Second
+)
+
+// Rule picks what is alerted on.
+type Rule struct {
+	Kind string
+	// Radio limits the rule to one radio, by serial, nickname or host:port
+	// (or host); empty means any.
+	Radio string
+	// Meter limits a meter rule to one meter, e.g. PATEMP; empty means any.
+	Meter string
+	// After is how long a transmission may last before a long-tx rule
+	// alerts.
+	After time.Duration
+}
+
+// Options configures the service.
+type Options struct {
+	Rules []Rule
+	// Cooldown is how often the same alert, by rule and radio, is sent at
+	// most; zero means DefaultCooldown.
+	Cooldown time.Duration
+	// Station names the bridge in alerts.
+	Station string
+	// Version is the bridge's, told in the restart alert.
+	Version string
+	// Notifiers are where alerts are sent.
+	Notifiers []Notifier
+}
+
+// Alert is one notification.
+type Alert struct {
+	Kind    string    `json:"kind"`
+	Station string    `json:"station"`
+	Radio   string    `json:"radio,omitempty"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	// Urgent alerts are sent at a higher priority where the channel has one.
+	Urgent bool `json:"urgent"`
+}
+
+// Notifier delivers alerts to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+	// Name identifies the channel in logs, without its secrets.
+	Name() string
+}
+
+// Service turns events into alerts.
+type Service struct {
+	opt   Options
+	queue chan Alert
+
+	// sent is when each alert was last sent, by rule, radio and title.
+	sent map[string]time.Time
+	// transmitting is since when each radio has been transmitting, and
+	// alerted which long-tx rules have fired for the transmission.
+	transmitting map[string]time.Time
+	alerted      map[string]bool
+}
+
+// New returns a service sending alerts on opt's rules.
+func New(opt Options) *Service {
+	opt.Cooldown = cmp.Or(opt.Cooldown, DefaultCooldown)
+
+	return &Service{
+		opt:          opt,
+		queue:        make(chan Alert, queueSize),
+		sent:         make(map[string]time.Time),
+		transmitting: make(map[string]time.Time),
+		alerted:      make(map[string]bool),
+	}
+}
+
+// Run sends the restart alert, then alerts on bus's events until ctx is done.
+func (s *Service) Run(ctx context.Context, bus *events.Bus) error {
+	go s.deliver(ctx)
+
+	evs := bus.Follow(ctx)
+
+	s.started(time.Now())
+
+	t := time.NewTicker(checkInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-evs:
+			if !ok {
+				return nil
+			}
+
+			s.observe(ev)
+		case now := <-t.C:
+			s.checkTX(now)
+		}
+	}
+}
+
+func (s *Service) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-s.queue:
+			for _, n := range s.opt.Notifiers {
+				sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+				err := n.Notify(sendCtx, a)
+				cancel()
+
+				if err != nil {
+					log.Printf("[alerts] %s: %v", n.Name(), err)
+				}
+			}
+		}
+	}
+}
+
+func (s *Service) started(now time.Time) {
+	for i, r := range s.opt.Rules {
+		if r.Kind != KindRestart {
+			continue
+		}
+
+		s.send(i, Alert{
+			Kind:    KindRestart,
+			Title:   "Bridge started",
+			Message: fmt.Sprintf("The bridge on %s started (version %s).", s.opt.Station, s.opt.Version),
+			Time:    now,
+		})
+	}
+}
+
+// radioEvent is what an event's data says about its radio.
+type radioEvent struct {
+	// radio.removed
+	Serial   string `json:"serial"`
+	Model    string `json:"model"`
+	Nickname string `json:"nickname"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+
+	// tx.interlock and meter.alert, as host:port
+	Radio string `json:"radio"`
+	State string `json:"state"`
+
+	// meter.alert
+	Meter  string  `json:"meter"`
+	Unit   string  `json:"unit"`
+	Value  float64 `json:"value"`
+	Limit  float64 `json:"limit"`
+	Below  bool    `json:"below"`
+	Raised bool    `json:"raised"`
+}
+
+func (s *Service) observe(ev events.Event) {
+	var d radioEvent
+
+	err := json.Unmarshal(ev.Data, &d)
+	if err != nil {
+		return
+	}
+
+	switch ev.Type {
+	case "radio.removed":
+		s.radioLost(d, ev.Time)
+	case "meter.alert":
+		if d.Raised {
+			s.meterAlert(d, ev.Time)
+		}
+	case "tx.interlock":
+		s.noteTX(d.Radio, d.State == "TRANSMITTING", ev.Time)
+	}
+}
+
+func (s *Service) radioLost(d radioEvent, at time.Time) {
+	name := cmp.Or(d.Nickname, d.Serial)
+
+	for i, r := range s.opt.Rules {
+		if r.Kind != KindRadioLost || !matches(r.Radio, d.Serial, d.Nickname, d.Host) {
+			continue
+		}
+
+		s.send(i, Alert{
+			Kind:    KindRadioLost,
+			Radio:   name,
+			Title:   "Radio lost",
+			Message: fmt.Sprintf("%s %s (%s) is no longer seen on the network.", d.Model, name, d.Serial),
+			Time:    at,
+			Urgent:  true,
+		})
+	}
+}
+
+func (s *Service) meterAlert(d radioEvent, at time.Time) {
+	limit := "over"
+	if d.Below {
+		limit = "under"
+	}
+
+	host, _, _ := strings.Cut(d.Radio, ":")
+
+	for i, r := range s.opt.Rules {
+		if r.Kind != KindMeter || !matches(r.Radio, d.Radio, host) || (r.Meter != "" && r.Meter != d.Meter) {
+			continue
+		}
+
+		s.send(i, Alert{
+			Kind:    KindMeter,
+			Radio:   d.Radio,
+			Title:   d.Meter + " alert",
+			Message: fmt.Sprintf("%s on %s read %.1f %s, %s the limit of %g.", d.Meter, d.Radio, d.Value, d.Unit, limit, d.Limit),
+			Time:    at,
+			Urgent:  true,
+		})
+	}
+}
+
+// noteTX follows a radio's transmissions from its interlock.
+func (s *Service) noteTX(radio string, on bool, at time.Time) {
+	_, was := s.transmitting[radio]
+
+	switch {
+	case on && !was:
+		s.transmitting[radio] = at
+	case !on && was:
+		delete(s.transmitting, radio)
+
+		for i := range s.opt.Rules {
+			delete(s.alerted, txKey(i, radio))
+		}
+	}
+}
+
+// checkTX alerts on transmissions that have run past a long-tx rule's After.
+func (s *Service) checkTX(now time.Time) {
+	for radio, since := range s.transmitting {
+		host, _, _ := strings.Cut(radio, ":")
+
+		for i, r := range s.opt.Rules {
+			if r.Kind != KindLongTX || now.Sub(since) < r.After || !matches(r.Radio, radio, host) {
+				continue
+			}
+
+			key := txKey(i, radio)
+			if s.alerted[key] {
+				continue
+			}
+
+			s.alerted[key] = true
+
+			s.send(i, Alert{
+				Kind:    KindLongTX,
+				Radio:   radio,
+				Title:   "Long transmission",
+				Message: fmt.Sprintf("%s has been transmitting for %s.", radio, now.Sub(since).Round(time.Second)),
+				Time:    now,
+				Urgent:  true,
+			})
+		}
+	}
+}
+
+func txKey(rule int, radio string) string {
+	return fmt.Sprintf("%d/%s", rule, radio)
+}
+
+// send queues an alert for rule, unless the same one, for the same radio,
+// went out within the cooldown.
+func (s *Service) send(rule int, a Alert) {
+	key := fmt.Sprintf("%d/%s/%s", rule, a.Radio, a.Title)
+	if last, ok := s.sent[key]; ok && a.Time.Sub(last) < s.opt.Cooldown {
+		return
+	}
+
+	s.sent[key] = a.Time
+	a.Station = s.opt.Station
+
+	select {
+	case s.queue <- a:
+	default:
+		log.Printf("[alerts] queue full; dropped %q", a.Title)
+	}
+}
+
+// matches reports whether a rule's radio filter picks a radio known by names.
+func matches(filter string, names ...string) bool {
+	if filter == "" {
+		return true
+	}
+
+	for _, n := range names {
+		if n != "" && strings.EqualFold(n, filter) {
+			return true
+		}
+	}
+
+	return false
+}
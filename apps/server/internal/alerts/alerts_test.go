@@ -0,0 +1,174 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/events"
+)
+
+// recorder is a Notifier that keeps what it is sent.
+type recorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+	sent   chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{sent: make(chan struct{}, queueSize)}
+}
+
+func (r *recorder) Notify(_ context.Context, a Alert) error {
+	r.mu.Lock()
+	r.alerts = append(r.alerts, a)
+	r.mu.Unlock()
+
+	r.sent <- struct{}{}
+
+	return nil
+}
+
+func (*recorder) Name() string { return "recorder" }
+
+func (r *recorder) next(t *testing.T) Alert {
+	t.Helper()
+
+	select {
+	case <-r.sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert sent")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.alerts[len(r.alerts)-1]
+}
+
+func event(t *testing.T, typ string, data any, at time.Time) events.Event {
+	t.Helper()
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return events.Event{Type: typ, Time: at, Data: raw}
+}
+
+// queued drains the alerts s has queued.
+func queued(s *Service) []Alert {
+	var out []Alert
+
+	for {
+		select {
+		case a := <-s.queue:
+			out = append(out, a)
+		default:
+			return out
+		}
+	}
+}
+
+func TestRun_RestartAndRadioLost(t *testing.T) {
+	t.Parallel()
+
+	rec := newRecorder()
+	bus := events.New()
+	s := New(Options{
+		Rules:     []Rule{{Kind: KindRestart}, {Kind: KindRadioLost, Radio: "shack"}},
+		Station:   "bridge-1",
+		Version:   "1.2.3",
+		Notifiers: []Notifier{rec},
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	go func() { _ = s.Run(ctx, bus) }()
+
+	if a := rec.next(t); a.Kind != KindRestart || a.Station != "bridge-1" || a.Message != "The bridge on bridge-1 started (version 1.2.3)." {
+		t.Fatalf("restart alert = %+v", a)
+	}
+
+	bus.Publish("radio.removed", map[string]any{"serial": "0123-4567", "model": "FLEX-6600", "nickname": "garage"})
+	bus.Publish("radio.removed", map[string]any{"serial": "2345-6789", "model": "FLEX-8600", "nickname": "Shack"})
+
+	a := rec.next(t)
+	if a.Kind != KindRadioLost || a.Radio != "Shack" || !a.Urgent {
+		t.Fatalf("radio-lost alert = %+v", a)
+	}
+
+	if a.Message != "FLEX-8600 Shack (2345-6789) is no longer seen on the network." {
+		t.Errorf("message = %q", a.Message)
+	}
+}
+
+func TestObserve_MeterAlerts(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{Rules: []Rule{{Kind: KindMeter, Meter: "PATEMP"}}})
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	alert := map[string]any{"radio": "192.168.1.20:4992", "meter": "PATEMP", "unit": "degC", "value": 72.5, "limit": 70, "raised": true}
+
+	s.observe(event(t, "meter.alert", alert, now))
+	// A second session of the radio reports the same alert.
+	s.observe(event(t, "meter.alert", alert, now))
+	s.observe(event(t, "meter.alert", map[string]any{"radio": "192.168.1.20:4992", "meter": "SWR", "value": 4, "limit": 3, "raised": true}, now))
+	s.observe(event(t, "meter.alert", map[string]any{"radio": "192.168.1.20:4992", "meter": "PATEMP", "value": 65, "limit": 70}, now))
+
+	got := queued(s)
+	if len(got) != 1 || got[0].Message != "PATEMP on 192.168.1.20:4992 read 72.5 degC, over the limit of 70." {
+		t.Fatalf("alerts = %+v", got)
+	}
+
+	// Within the cooldown, it isn't sent again.
+	s.observe(event(t, "meter.alert", alert, now.Add(time.Minute)))
+
+	if got = queued(s); len(got) != 0 {
+		t.Fatalf("alerts in cooldown = %+v", got)
+	}
+
+	s.observe(event(t, "meter.alert", alert, now.Add(DefaultCooldown)))
+
+	if got = queued(s); len(got) != 1 {
+		t.Fatalf("alerts after cooldown = %+v", got)
+	}
+}
+
+func TestCheckTX_LongTransmission(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{Rules: []Rule{{Kind: KindLongTX, After: 5 * time.Minute, Radio: "192.168.1.20"}}, Cooldown: time.Second})
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	s.observe(event(t, "tx.interlock", map[string]any{"radio": "192.168.1.20:4992", "state": "TRANSMITTING"}, now))
+	s.observe(event(t, "tx.interlock", map[string]any{"radio": "10.0.0.9:4992", "state": "TRANSMITTING"}, now))
+
+	s.checkTX(now.Add(4 * time.Minute))
+
+	if got := queued(s); len(got) != 0 {
+		t.Fatalf("alerted early: %+v", got)
+	}
+
+	s.checkTX(now.Add(5 * time.Minute))
+	s.checkTX(now.Add(6 * time.Minute))
+
+	got := queued(s)
+	if len(got) != 1 || got[0].Radio != "192.168.1.20:4992" || got[0].Message != "192.168.1.20:4992 has been transmitting for 5m0s." {
+		t.Fatalf("alerts = %+v", got)
+	}
+
+	// The next long transmission alerts again.
+	s.observe(event(t, "tx.interlock", map[string]any{"radio": "192.168.1.20:4992", "state": "RECEIVE"}, now.Add(7*time.Minute)))
+	s.observe(event(t, "tx.interlock", map[string]any{"radio": "192.168.1.20:4992", "state": "TRANSMITTING"}, now.Add(8*time.Minute)))
+	s.checkTX(now.Add(14 * time.Minute))
+
+	if got = queued(s); len(got) != 1 {
+		t.Fatalf("alerts = %+v", got)
+	}
+}
@@ -0,0 +1,210 @@
+package alerts
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds sending one alert to one channel.
+const sendTimeout = 10 * time.Second
+
+// PushoverURL is where Pushover messages are posted.
+const PushoverURL = "https://api.pushover.net/1/messages.json"
+
+var errStatus = errors.New("unexpected response")
+
+// Webhook POSTs each alert as JSON to URL.
+type Webhook struct {
+	URL string
+}
+
+// Notify sends a.
+func (w Webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	return post(ctx, w.URL, "application/json", body, nil)
+}
+
+// Name is the webhook's host.
+func (w Webhook) Name() string {
+	return "webhook " + hostOf(w.URL)
+}
+
+// Ntfy publishes each alert to an ntfy topic, e.g. https://ntfy.sh/my-station,
+// with Token as its access token if set.
+type Ntfy struct {
+	URL   string
+	Token string
+}
+
+// Notify sends a.
+func (n Ntfy) Notify(ctx context.Context, a Alert) error {
+	header := http.Header{}
+	header.Set("Title", a.Station+": "+a.Title)
+	header.Set("Tags", "warning")
+
+	if a.Urgent {
+		header.Set("Priority", "high")
+	}
+
+	if n.Token != "" {
+		header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	return post(ctx, n.URL, "text/plain; charset=utf-8", []byte(a.Message), header)
+}
+
+// Name is the topic's host.
+func (n Ntfy) Name() string {
+	return "ntfy " + hostOf(n.URL)
+}
+
+// Pushover sends each alert through the Pushover application Token to the
+// user or group UserKey.
+type Pushover struct {
+	Token   string
+	UserKey string
+	// URL defaults to PushoverURL.
+	URL string
+}
+
+// Notify sends a.
+func (p Pushover) Notify(ctx context.Context, a Alert) error {
+	form := url.Values{
+		"token":     {p.Token},
+		"user":      {p.UserKey},
+		"title":     {a.Station + ": " + a.Title},
+		"message":   {a.Message},
+		"timestamp": {fmt.Sprint(a.Time.Unix())},
+	}
+
+	if a.Urgent {
+		form.Set("priority", "1")
+	}
+
+	return post(ctx, cmp.Or(p.URL, PushoverURL), "application/x-www-form-urlencoded", []byte(form.Encode()), nil)
+}
+
+// Name is "pushover".
+func (Pushover) Name() string {
+	return "pushover"
+}
+
+// Email mails each alert through the SMTP server at host:port, with STARTTLS
+// when the server offers it, logging in as Username if set.
+type Email struct {
+	SMTP     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Notify sends a.
+func (e Email) Notify(ctx context.Context, a Alert) error {
+	host, _, err := net.SplitHostPort(e.SMTP)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- smtp.SendMail(e.SMTP, auth, e.From, e.To, e.message(a))
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+
+	return nil
+}
+
+// message is a's plain-text mail.
+func (e Email) message(a Alert) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", a.Station+": "+a.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+
+	if a.Urgent {
+		b.WriteString("X-Priority: 1\r\n")
+	}
+
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(a.Message)
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
+
+// Name is the SMTP server's host.
+func (e Email) Name() string {
+	return "email " + e.SMTP
+}
+
+func post(ctx context.Context, target, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: %s", errStatus, resp.Status)
+	}
+
+	return nil
+}
+
+// hostOf names a URL by its host, leaving out paths that may hold secrets.
+func hostOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "?"
+	}
+
+	return u.Host
+}
@@ -0,0 +1,139 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAlert = Alert{
+	Kind:    KindMeter,
+	Station: "bridge-1",
+	Radio:   "192.168.1.20:4992",
+	Title:   "SWR alert",
+	Message: "SWR on 192.168.1.20:4992 read 3.4 SWR, over the limit of 3.",
+	Time:    time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+	Urgent:  true,
+}
+
+// capture serves one request, handing it and its body to the test.
+func capture(t *testing.T, status int) (string, <-chan *http.Request, <-chan string) {
+	t.Helper()
+
+	reqs := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		reqs <- r
+		bodies <- string(b)
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts.URL, reqs, bodies
+}
+
+func TestWebhook_PostsJSON(t *testing.T) {
+	t.Parallel()
+
+	u, reqs, bodies := capture(t, http.StatusNoContent)
+
+	err := Webhook{URL: u + "/hook/secret"}.Notify(t.Context(), testAlert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := <-reqs; r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("request = %s %s", r.Method, r.Header.Get("Content-Type"))
+	}
+
+	var got Alert
+
+	err = json.Unmarshal([]byte(<-bodies), &got)
+	if err != nil || got.Title != testAlert.Title || got.Radio != testAlert.Radio {
+		t.Errorf("body = %+v (%v)", got, err)
+	}
+
+	if name := (Webhook{URL: u + "/hook/secret"}).Name(); strings.Contains(name, "secret") {
+		t.Errorf("name %q gives the path away", name)
+	}
+}
+
+func TestNtfy_SetsHeaders(t *testing.T) {
+	t.Parallel()
+
+	u, reqs, bodies := capture(t, http.StatusOK)
+
+	err := Ntfy{URL: u + "/station", Token: "tk_abc"}.Notify(t.Context(), testAlert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-reqs
+	if r.URL.Path != "/station" || r.Header.Get("Title") != "bridge-1: SWR alert" ||
+		r.Header.Get("Priority") != "high" || r.Header.Get("Authorization") != "Bearer tk_abc" {
+		t.Errorf("request = %s %v", r.URL.Path, r.Header)
+	}
+
+	if body := <-bodies; body != testAlert.Message {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestPushover_PostsForm(t *testing.T) {
+	t.Parallel()
+
+	u, reqs, bodies := capture(t, http.StatusOK)
+
+	err := Pushover{Token: "app", UserKey: "user", URL: u}.Notify(t.Context(), testAlert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-reqs
+
+	body := <-bodies
+	for _, want := range []string{"token=app", "user=user", "priority=1", "title=bridge-1%3A+SWR+alert"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body %q lacks %q", body, want)
+		}
+	}
+}
+
+func TestPost_RejectsErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	u, _, _ := capture(t, http.StatusForbidden)
+
+	err := Webhook{URL: u}.Notify(t.Context(), testAlert)
+	if !errors.Is(err, errStatus) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestEmail_Message(t *testing.T) {
+	t.Parallel()
+
+	e := Email{SMTP: "smtp.example.org:587", From: "bridge@example.org", To: []string{"me@example.org", "club@example.org"}}
+	msg := string(e.message(testAlert))
+
+	for _, want := range []string{
+		"From: bridge@example.org\r\n",
+		"To: me@example.org, club@example.org\r\n",
+		"Subject: bridge-1: SWR alert\r\n",
+		"Date: Thu, 15 Oct 2026 12:00:00 +0000\r\n",
+		"X-Priority: 1\r\n",
+		"\r\n\r\n" + testAlert.Message + "\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+}
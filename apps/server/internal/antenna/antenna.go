@@ -0,0 +1,342 @@
+// Package antenna controls network antenna switches that speak the 4O3A
+// Antenna Genius protocol: it finds them by their discovery broadcasts,
+// tracks which antenna each of their radio ports is switched to, and switches
+// them to follow the band the radio is on.
+package antenna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPort is the Antenna Genius's API and discovery port.
+	DefaultPort = 9007
+	// DefaultPoll is how often each switch's ports are read again, in case
+	// a status was missed.
+	DefaultPoll = 10 * time.Second
+
+	// defaultPorts is how many radio ports a switch has when its discovery
+	// broadcast has not said.
+	defaultPorts = 2
+	// replyTimeout is how long a command waits for the switch.
+	replyTimeout = 3 * time.Second
+	// redialDelay is how long a lost switch is left before reconnecting.
+	redialDelay = 5 * time.Second
+)
+
+var (
+	// ErrUnknownSwitch is returned for a switch that is neither configured
+	// nor discovered.
+	ErrUnknownSwitch = errors.New("antenna: unknown switch")
+
+	errNotConnected = errors.New("antenna: switch not connected")
+	errTimeout      = errors.New("antenna: switch did not answer")
+	errRefused      = errors.New("antenna: switch refused")
+)
+
+// Antenna is one of a switch's antennas.
+type Antenna struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Band is one of the bands a switch is set up for.
+type Band struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	StartMHz float64 `json:"startMHz"`
+	StopMHz  float64 `json:"stopMHz"`
+}
+
+// Port is one of the switch's radio ports and the antennas it is switched
+// to.
+type Port struct {
+	ID           int  `json:"id"`
+	Band         int  `json:"band"`
+	RXAntenna    int  `json:"rxAntenna"`
+	TXAntenna    int  `json:"txAntenna"`
+	Transmitting bool `json:"transmitting"`
+	// Auto is set while the switch follows the band itself.
+	Auto bool `json:"auto"`
+}
+
+// Switch is one antenna switch's state.
+type Switch struct {
+	Address   string    `json:"address"`
+	Name      string    `json:"name,omitempty"`
+	Connected bool      `json:"connected"`
+	Antennas  []Antenna `json:"antennas"`
+	Bands     []Band    `json:"bands"`
+	Ports     []Port    `json:"ports"`
+}
+
+// Rule switches Port of a switch to Antenna while the radio is between
+// FromMHz and ToMHz.
+type Rule struct {
+	// Switch is the switch's address or name; empty means every switch.
+	Switch string
+	// Radio is the radio's host:port; empty means any radio.
+	Radio   string
+	Port    int
+	FromMHz float64
+	ToMHz   float64
+	Antenna int
+}
+
+// Options configures the service.
+type Options struct {
+	// Switches are host:port addresses to connect to.
+	Switches []string
+	// Discover listens for the switches' broadcasts and connects to each
+	// one heard.
+	Discover bool
+	// Poll is how often ports are read again; zero means DefaultPoll.
+	Poll  time.Duration
+	Rules []Rule
+}
+
+// Service keeps a connection to each switch.
+type Service struct {
+	opt Options
+
+	mu       sync.Mutex
+	switches map[string]*switchConn
+	subs     []func(Switch)
+	ctx      context.Context //nolint:containedctx // switches discovered later run under Run's context
+}
+
+// New returns a service for opt; Run connects it.
+func New(opt Options) *Service {
+	if opt.Poll <= 0 {
+		opt.Poll = DefaultPoll
+	}
+
+	return &Service{opt: opt, switches: make(map[string]*switchConn)}
+}
+
+// Subscribe calls fn with a switch's state whenever it changes. fn must not
+// block.
+func (s *Service) Subscribe(fn func(Switch)) {
+	s.mu.Lock()
+	s.subs = append(s.subs, fn)
+	s.mu.Unlock()
+}
+
+// Run connects to the configured switches, and to those discovered, until
+// ctx is done.
+func (s *Service) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	for _, addr := range s.opt.Switches {
+		s.add(addr, "", 0)
+	}
+
+	if !s.opt.Discover {
+		<-ctx.Done()
+
+		return nil
+	}
+
+	return s.discover(ctx)
+}
+
+// add starts a connection to the switch at addr unless there is one.
+func (s *Service) add(addr, name string, ports int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sc, ok := s.switches[addr]; ok {
+		if name != "" {
+			sc.setName(name)
+		}
+
+		return
+	}
+
+	if ports <= 0 {
+		ports = defaultPorts
+	}
+
+	sc := &switchConn{svc: s, state: Switch{Address: addr, Name: name}, ports: ports}
+	s.switches[addr] = sc
+
+	go sc.run(s.ctx)
+}
+
+// Switches returns every switch's state, by address.
+func (s *Service) Switches() []Switch {
+	s.mu.Lock()
+	conns := make([]*switchConn, 0, len(s.switches))
+
+	for _, sc := range s.switches {
+		conns = append(conns, sc)
+	}
+	s.mu.Unlock()
+
+	out := make([]Switch, 0, len(conns))
+	for _, sc := range conns {
+		out = append(out, sc.snapshot())
+	}
+
+	slices.SortFunc(out, func(a, b Switch) int { return strings.Compare(a.Address, b.Address) })
+
+	return out
+}
+
+// find returns the switch with address or name id.
+func (s *Service) find(id string) *switchConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sc, ok := s.switches[id]; ok {
+		return sc
+	}
+
+	for _, sc := range s.switches {
+		if sc.snapshot().Name == id {
+			return sc
+		}
+	}
+
+	return nil
+}
+
+// Select switches port of the switch id, by address or name, to antenna for
+// both receive and transmit.
+func (s *Service) Select(ctx context.Context, id string, port, antenna int) error {
+	sc := s.find(id)
+	if sc == nil {
+		return fmt.Errorf("%w %q", ErrUnknownSwitch, id)
+	}
+
+	return sc.selectAntenna(ctx, port, antenna)
+}
+
+// Follow applies the rules for a radio tuned to freqMHz: the first rule
+// matching each switch port picks its antenna. Ports already on it are left
+// alone.
+func (s *Service) Follow(radio string, freqMHz float64) {
+	done := make(map[string]bool)
+
+	for _, r := range s.opt.Rules {
+		if (r.Radio != "" && r.Radio != radio) || freqMHz < r.FromMHz || freqMHz > r.ToMHz {
+			continue
+		}
+
+		for _, sw := range s.Switches() {
+			if r.Switch != "" && r.Switch != sw.Address && r.Switch != sw.Name {
+				continue
+			}
+
+			key := sw.Address + "/" + strconv.Itoa(r.Port)
+			if done[key] || !sw.Connected {
+				continue
+			}
+
+			done[key] = true
+
+			i := slices.IndexFunc(sw.Ports, func(p Port) bool { return p.ID == r.Port })
+			if i >= 0 && sw.Ports[i].RXAntenna == r.Antenna && sw.Ports[i].TXAntenna == r.Antenna {
+				continue
+			}
+
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), replyTimeout)
+				defer cancel()
+
+				err := s.Select(ctx, sw.Address, r.Port, r.Antenna)
+				if err != nil {
+					log.Printf("[antenna] follow %.3f MHz: %v", freqMHz, err)
+				}
+			}()
+		}
+	}
+}
+
+func (s *Service) publish(sw Switch) {
+	s.mu.Lock()
+	subs := slices.Clone(s.subs)
+	s.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(sw)
+	}
+}
+
+// discover connects to every switch whose broadcast is heard, e.g.
+// "AG ip=192.168.1.39 port=9007 v=4.0.22 serial=... name=Shack ports=2
+// antennas=8 mode=master uptime=3034".
+func (s *Service) discover(ctx context.Context) error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: DefaultPort})
+	if err != nil {
+		return fmt.Errorf("antenna discovery: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	buf := make([]byte, 1500)
+
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("antenna discovery: %w", err)
+		}
+
+		addr, name, ports, ok := parseBeacon(string(buf[:n]), from)
+		if ok {
+			s.add(addr, name, ports)
+		}
+	}
+}
+
+// parseBeacon reads a discovery broadcast sent from from.
+func parseBeacon(b string, from *net.UDPAddr) (addr, name string, ports int, ok bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(b), "AG ")
+	if !ok {
+		return "", "", 0, false
+	}
+
+	f := attrs(rest)
+
+	ip := f["ip"]
+	if ip == "" && from != nil {
+		ip = from.IP.String()
+	}
+
+	port := f["port"]
+	if port == "" {
+		port = strconv.Itoa(DefaultPort)
+	}
+
+	ports, _ = strconv.Atoi(f["ports"])
+
+	return net.JoinHostPort(ip, port), strings.ReplaceAll(f["name"], "_", " "), ports, ip != ""
+}
+
+// attrs splits "key=value" words.
+func attrs(s string) map[string]string {
+	f := make(map[string]string)
+
+	for kv := range strings.FieldsSeq(s) {
+		k, v, _ := strings.Cut(kv, "=")
+		f[k] = v
+	}
+
+	return f
+}
@@ -0,0 +1,182 @@
+package antenna
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBeacon(t *testing.T) {
+	t.Parallel()
+
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 40), Port: 9007}
+
+	addr, name, ports, ok := parseBeacon("AG ip=192.168.1.39 port=9007 v=4.0.22 name=Shack_Switch ports=2 antennas=8\n", from)
+	if !ok || addr != "192.168.1.39:9007" || name != "Shack Switch" || ports != 2 {
+		t.Errorf("got %q %q %d %v", addr, name, ports, ok)
+	}
+
+	addr, _, _, ok = parseBeacon("AG name=Tower", from)
+	if !ok || addr != "192.168.1.40:9007" {
+		t.Errorf("without ip: got %q %v", addr, ok)
+	}
+
+	if _, _, _, ok = parseBeacon("discovery protocol_version=3.0.0.2", from); ok {
+		t.Error("not a switch's broadcast")
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	var published int
+
+	s := New(Options{})
+	s.Subscribe(func(Switch) { published++ })
+
+	sc := &switchConn{svc: s}
+
+	sc.apply("antenna 2 name=Beam tx=0003 rx=0003")
+	sc.apply("antenna 1 name=Vertical_40m")
+	sc.apply("band 3 name=20m freq_start=14.000000 freq_stop=14.350000")
+	sc.apply("port 1 auto=0 source=AUTO band=3 rxant=2 txant=2 tx=0")
+	sc.apply("port 1 tx=1")
+	sc.apply("port 1 tx=1")
+	sc.apply("garbage")
+
+	st := sc.snapshot()
+
+	if len(st.Antennas) != 2 || st.Antennas[0].Name != "Vertical 40m" || st.Antennas[1].ID != 2 {
+		t.Errorf("antennas = %+v", st.Antennas)
+	}
+
+	if len(st.Bands) != 1 || st.Bands[0].StartMHz != 14 || st.Bands[0].StopMHz != 14.35 {
+		t.Errorf("bands = %+v", st.Bands)
+	}
+
+	want := Port{ID: 1, Band: 3, RXAntenna: 2, TXAntenna: 2, Transmitting: true}
+	if len(st.Ports) != 1 || st.Ports[0] != want {
+		t.Errorf("ports = %+v, want %+v", st.Ports, want)
+	}
+
+	if published != 5 {
+		t.Errorf("published %d changes, want 5", published)
+	}
+}
+
+// fakeSwitch answers commands as an Antenna Genius would and reports what it
+// was sent.
+func fakeSwitch(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	sent := make(chan string, 64)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = conn.Close() }()
+
+		rd := bufio.NewScanner(conn)
+		for rd.Scan() {
+			seq, cmd, _ := strings.Cut(strings.TrimPrefix(rd.Text(), "C"), "|")
+			sent <- cmd
+
+			var reply string
+
+			switch {
+			case cmd == "antenna list":
+				reply = fmt.Sprintf("R%s|0|antenna 1 name=Dipole\nR%[1]s|0|antenna 2 name=Beam\n", seq)
+			case cmd == "port get 1":
+				reply = fmt.Sprintf("R%s|0|port 1 band=0 rxant=1 txant=1 tx=0\n", seq)
+			case strings.HasPrefix(cmd, "port set 1 "):
+				reply = fmt.Sprintf("R%s|0|\nS0|port 1 rxant=2 txant=2\n", seq)
+			case strings.HasPrefix(cmd, "port set "):
+				reply = fmt.Sprintf("R%s|FF|\n", seq)
+			default:
+				reply = fmt.Sprintf("R%s|0|\n", seq)
+			}
+
+			_, _ = conn.Write([]byte(reply))
+		}
+	}()
+
+	return ln.Addr().String(), sent
+}
+
+func TestService_SelectAndFollow(t *testing.T) {
+	t.Parallel()
+
+	addr, sent := fakeSwitch(t)
+
+	s := New(Options{
+		Switches: []string{addr},
+		Rules: []Rule{
+			{Radio: "10.0.0.2:4992", Port: 1, FromMHz: 14, ToMHz: 14.35, Antenna: 2},
+			{Port: 1, FromMHz: 0, ToMHz: 60, Antenna: 1},
+		},
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+
+	go func() { _ = s.Run(ctx) }()
+
+	waitFor(t, s, func(sw Switch) bool { return len(sw.Antennas) == 2 && len(sw.Ports) == 1 })
+
+	err := s.Select(ctx, addr, 2, 1)
+	if !errors.Is(err, errRefused) {
+		t.Errorf("refused select: %v", err)
+	}
+
+	err = s.Select(ctx, "nowhere", 1, 1)
+	if !errors.Is(err, ErrUnknownSwitch) {
+		t.Errorf("unknown switch: %v", err)
+	}
+
+	// Port 1 is already on antenna 1, which the catch-all rule picks.
+	s.Follow("10.0.0.3:4992", 14.074)
+	s.Follow("10.0.0.2:4992", 14.074)
+
+	waitFor(t, s, func(sw Switch) bool { return sw.Ports[0].RXAntenna == 2 })
+
+	for cmd := range sent {
+		if strings.HasPrefix(cmd, "port set 1 ") {
+			if cmd != "port set 1 rxant=2 txant=2" {
+				t.Errorf("sent %q", cmd)
+			}
+
+			break
+		}
+	}
+}
+
+func waitFor(t *testing.T, s *Service, ok func(Switch) bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for time.Now().Before(deadline) {
+		if sw := s.Switches(); len(sw) == 1 && ok(sw[0]) {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("switches = %+v", s.Switches())
+}
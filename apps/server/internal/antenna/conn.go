@@ -0,0 +1,306 @@
+package antenna
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// switchConn is the connection to one switch. Commands are
+// "C<seq>|<command>"; the switch answers each with "R<seq>|<hex code>|..."
+// lines, one per item of a list, and reports changes to what it was
+// subscribed to as "S0|..." lines.
+type switchConn struct {
+	svc   *Service
+	ports int
+
+	mu      sync.Mutex
+	state   Switch
+	conn    net.Conn
+	seq     uint32
+	pending map[uint32]chan error
+}
+
+// run keeps the switch connected until ctx is done.
+func (sc *switchConn) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := sc.session(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[antenna] %s: %v", sc.state.Address, err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(redialDelay):
+		}
+	}
+}
+
+// session connects, reads the switch's antennas, bands and ports, and
+// follows its status until the connection fails.
+func (sc *switchConn) session(ctx context.Context) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", sc.state.Address)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	sc.mu.Lock()
+	sc.conn = conn
+	sc.pending = make(map[uint32]chan error)
+	sc.state.Connected = true
+	sc.mu.Unlock()
+
+	defer sc.disconnected()
+
+	sc.svc.publish(sc.snapshot())
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go sc.setup(ctx, done)
+
+	rd := bufio.NewScanner(conn)
+	for rd.Scan() {
+		sc.handle(strings.TrimSpace(rd.Text()))
+	}
+
+	if err := rd.Err(); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	return nil
+}
+
+// setup asks for the switch's configuration and subscribes to its ports,
+// then reads the ports again every poll interval.
+func (sc *switchConn) setup(ctx context.Context, done <-chan struct{}) {
+	for _, cmd := range []string{"antenna list", "band list", "sub port all"} {
+		err := sc.command(ctx, cmd)
+		if err != nil {
+			log.Printf("[antenna] %s: %s: %v", sc.state.Address, cmd, err)
+		}
+	}
+
+	t := time.NewTicker(sc.svc.opt.Poll)
+	defer t.Stop()
+
+	for {
+		for port := 1; port <= sc.ports; port++ {
+			_ = sc.command(ctx, fmt.Sprintf("port get %d", port))
+		}
+
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (sc *switchConn) disconnected() {
+	sc.mu.Lock()
+	_ = sc.conn.Close()
+	sc.conn = nil
+
+	for _, ch := range sc.pending {
+		ch <- errNotConnected
+	}
+
+	sc.pending = nil
+	sc.state.Connected = false
+	sc.mu.Unlock()
+
+	sc.svc.publish(sc.snapshot())
+}
+
+// command sends cmd and waits for the switch to answer it.
+func (sc *switchConn) command(ctx context.Context, cmd string) error {
+	sc.mu.Lock()
+	conn := sc.conn
+	if conn == nil {
+		sc.mu.Unlock()
+
+		return errNotConnected
+	}
+
+	sc.seq++
+	seq := sc.seq
+	reply := make(chan error, 1)
+	sc.pending[seq] = reply
+	sc.mu.Unlock()
+
+	defer func() {
+		sc.mu.Lock()
+		delete(sc.pending, seq)
+		sc.mu.Unlock()
+	}()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(replyTimeout))
+
+	_, err := fmt.Fprintf(conn, "C%d|%s\r\n", seq, cmd)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd, err)
+	}
+
+	select {
+	case err := <-reply:
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd, err)
+		}
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", cmd, ctx.Err())
+	case <-time.After(replyTimeout):
+		return fmt.Errorf("%s: %w", cmd, errTimeout)
+	}
+}
+
+// handle applies one line from the switch: a reply, which may carry an item
+// of a list, or a status.
+func (sc *switchConn) handle(line string) {
+	switch {
+	case strings.HasPrefix(line, "R"):
+		seqText, rest, _ := strings.Cut(line[1:], "|")
+		code, body, _ := strings.Cut(rest, "|")
+
+		seq, err := strconv.ParseUint(seqText, 10, 32)
+		if err != nil {
+			return
+		}
+
+		sc.mu.Lock()
+		reply := sc.pending[uint32(seq)]
+		delete(sc.pending, uint32(seq))
+		sc.mu.Unlock()
+
+		if reply != nil {
+			if c, err := strconv.ParseUint(code, 16, 32); err != nil || c != 0 {
+				reply <- fmt.Errorf("%w: %s", errRefused, code)
+			} else {
+				reply <- nil
+			}
+		}
+
+		sc.apply(body)
+	case strings.HasPrefix(line, "S"):
+		_, body, _ := strings.Cut(line, "|")
+		sc.apply(body)
+	}
+}
+
+// apply updates the state from "antenna N ...", "band N ..." or "port N ..."
+// and publishes it if it changed.
+func (sc *switchConn) apply(body string) {
+	kind, rest, _ := strings.Cut(body, " ")
+	idText, attrText, _ := strings.Cut(rest, " ")
+
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		return
+	}
+
+	f := attrs(attrText)
+
+	sc.mu.Lock()
+
+	var changed bool
+
+	switch kind {
+	case "antenna":
+		a := Antenna{ID: id, Name: strings.ReplaceAll(f["name"], "_", " ")}
+		sc.state.Antennas, changed = upsert(sc.state.Antennas, a, func(a Antenna) int { return a.ID })
+	case "band":
+		b := Band{ID: id, Name: strings.ReplaceAll(f["name"], "_", " ")}
+		b.StartMHz, _ = strconv.ParseFloat(f["freq_start"], 64)
+		b.StopMHz, _ = strconv.ParseFloat(f["freq_stop"], 64)
+		sc.state.Bands, changed = upsert(sc.state.Bands, b, func(b Band) int { return b.ID })
+	case "port":
+		i := slices.IndexFunc(sc.state.Ports, func(p Port) bool { return p.ID == id })
+
+		p := Port{ID: id}
+		if i >= 0 {
+			p = sc.state.Ports[i]
+		}
+
+		applyPort(&p, f)
+		sc.state.Ports, changed = upsert(sc.state.Ports, p, func(p Port) int { return p.ID })
+	}
+
+	sc.mu.Unlock()
+
+	if changed {
+		sc.svc.publish(sc.snapshot())
+	}
+}
+
+func applyPort(p *Port, f map[string]string) {
+	for k, v := range f {
+		n, _ := strconv.Atoi(v)
+
+		switch k {
+		case "band":
+			p.Band = n
+		case "rxant":
+			p.RXAntenna = n
+		case "txant":
+			p.TXAntenna = n
+		case "tx":
+			p.Transmitting = n != 0
+		case "auto":
+			p.Auto = n != 0
+		}
+	}
+}
+
+// upsert puts v in list in ID order, reporting whether the list changed.
+func upsert[T comparable](list []T, v T, id func(T) int) ([]T, bool) {
+	i, found := slices.BinarySearchFunc(list, id(v), func(e T, target int) int { return id(e) - target })
+	if found {
+		if list[i] == v {
+			return list, false
+		}
+
+		list[i] = v
+
+		return list, true
+	}
+
+	return slices.Insert(list, i, v), true
+}
+
+// selectAntenna switches port to antenna for receive and transmit.
+func (sc *switchConn) selectAntenna(ctx context.Context, port, antenna int) error {
+	return sc.command(ctx, fmt.Sprintf("port set %d rxant=%d txant=%d", port, antenna, antenna))
+}
+
+func (sc *switchConn) setName(name string) {
+	sc.mu.Lock()
+	sc.state.Name = name
+	sc.mu.Unlock()
+}
+
+// snapshot copies the state.
+func (sc *switchConn) snapshot() Switch {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	s := sc.state
+	s.Antennas = slices.Clone(s.Antennas)
+	s.Bands = slices.Clone(s.Bands)
+	s.Ports = slices.Clone(s.Ports)
+
+	return s
+}
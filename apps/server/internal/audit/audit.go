@@ -0,0 +1,190 @@
+// Package audit keeps a trail of every command the bridge sends a radio, with
+// who it was sent for, in a JSONL file or SQLite, for shared and club
+// stations to answer "who did that, and when".
+package audit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultLimit is how many entries a query returns when it asks for no
+	// particular number; MaxLimit is the most it may ask for.
+	DefaultLimit = 1000
+	MaxLimit     = 10000
+
+	// queueSize is how many entries may wait to be written before further
+	// ones are dropped rather than holding up the radio link.
+	queueSize = 4096
+)
+
+var errRange = errors.New("audit: from must be before to")
+
+// Entry is one command sent to a radio.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// User is who the session's client authenticated as, when a proxy in
+	// front of the bridge says.
+	User     string `json:"user,omitempty"`
+	ClientIP string `json:"clientIp"`
+	Session  string `json:"session"`
+	// Handle is the radio's client handle for the session's connection.
+	Handle string `json:"handle"`
+	Radio  string `json:"radio"`
+	// Source is "client" for the session's own commands, or the bridge
+	// feature that sent one on its behalf, e.g. "ptt" or "rigctl".
+	Source  string `json:"source"`
+	Command string `json:"command"`
+}
+
+// Query selects entries. Zero fields match everything; Contains matches a
+// substring of the command.
+type Query struct {
+	From, To time.Time
+	User     string
+	ClientIP string
+	Session  string
+	Radio    string
+	Contains string
+	// Limit caps how many of the newest matches are returned; zero means
+	// DefaultLimit.
+	Limit int
+}
+
+// store keeps entries.
+type store interface {
+	add(entries []Entry) error
+	// query returns matches newest first.
+	query(q Query) ([]Entry, error)
+	close() error
+}
+
+// Log records entries in the background and answers queries about them.
+type Log struct {
+	store   store
+	queue   chan Entry
+	dropped atomic.Uint64
+
+	flushMu sync.Mutex // one flush at a time
+}
+
+// Open opens, creating if need be, the trail at path: an SQLite database
+// when it ends in .db, .sqlite or .sqlite3, otherwise a JSONL file.
+func Open(path string) (*Log, error) {
+	var (
+		s   store
+		err error
+	)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		s, err = openSQLite(path)
+	default:
+		s, err = openJSONL(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{store: s, queue: make(chan Entry, queueSize)}, nil
+}
+
+// Record queues e to be written. It never blocks: when the queue is full,
+// e is dropped and counted.
+func (l *Log) Record(e Entry) {
+	if l == nil {
+		return
+	}
+
+	select {
+	case l.queue <- e:
+	default:
+		if l.dropped.Add(1) == 1 {
+			log.Printf("[audit] writing is falling behind; dropping entries")
+		}
+	}
+}
+
+// Run writes queued entries until ctx is done, then writes what is left.
+func (l *Log) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			l.flush(nil)
+
+			return nil
+		case e := <-l.queue:
+			l.flush([]Entry{e})
+		}
+	}
+}
+
+// flush writes batch and whatever else is queued.
+func (l *Log) flush(batch []Entry) {
+	l.flushMu.Lock()
+	defer l.flushMu.Unlock()
+
+	for len(l.queue) > 0 {
+		batch = append(batch, <-l.queue)
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	err := l.store.add(batch)
+	if err != nil {
+		log.Printf("[audit] write: %v", err)
+	}
+}
+
+// Query returns the newest entries matching q, newest first.
+func (l *Log) Query(q Query) ([]Entry, error) {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return nil, errRange
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+
+	q.Limit = min(q.Limit, MaxLimit)
+
+	return l.store.query(q)
+}
+
+// Dropped reports how many entries were lost to a full queue.
+func (l *Log) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Close writes what is still queued, such as the commands of a shutdown's
+// drain, and closes the trail.
+func (l *Log) Close() error {
+	l.flush(nil)
+
+	return l.store.close()
+}
+
+// matches reports whether e is selected by q's filters, the time range
+// aside.
+func (q Query) matches(e Entry) bool {
+	return (q.User == "" || e.User == q.User) &&
+		(q.ClientIP == "" || e.ClientIP == q.ClientIP) &&
+		(q.Session == "" || e.Session == q.Session) &&
+		(q.Radio == "" || e.Radio == q.Radio) &&
+		(q.Contains == "" || strings.Contains(e.Command, q.Contains))
+}
+
+// inRange reports whether t falls in [q.From, q.To).
+func (q Query) inRange(t time.Time) bool {
+	return (q.From.IsZero() || !t.Before(q.From)) && (q.To.IsZero() || t.Before(q.To))
+}
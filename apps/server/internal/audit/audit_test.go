@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLog_Query(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"audit.jsonl", "audit.db"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l, err := Open(filepath.Join(t.TempDir(), name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(func() { _ = l.Close() })
+
+			at := time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)
+			for i, cmd := range []string{"slice tune 0 14.074", "xmit 1", "xmit 0", "slice tune 0 7.074"} {
+				l.Record(Entry{
+					Time: at.Add(time.Duration(i) * time.Minute), User: []string{"w1aw", "k1abc"}[i%2],
+					ClientIP: "203.0.113.9", Session: "s1", Handle: "0x1234", Radio: "10.0.0.5:4992",
+					Source: "client", Command: cmd,
+				})
+			}
+
+			ctx, cancel := context.WithCancel(t.Context())
+			cancel()
+
+			_ = l.Run(ctx) // writes what is queued
+
+			all, err := l.Query(Query{})
+			if err != nil || len(all) != 4 || all[0].Command != "slice tune 0 7.074" || !all[0].Time.Equal(at.Add(3*time.Minute)) {
+				t.Fatalf("all = %+v, %v", all, err)
+			}
+
+			got, err := l.Query(Query{User: "k1abc", Contains: "xmit"})
+			if err != nil || len(got) != 1 || got[0].Command != "xmit 1" || got[0].Handle != "0x1234" {
+				t.Errorf("k1abc keying = %+v, %v", got, err)
+			}
+
+			got, err = l.Query(Query{From: at.Add(time.Minute), To: at.Add(3 * time.Minute), Limit: 1})
+			if err != nil || len(got) != 1 || got[0].Command != "xmit 0" {
+				t.Errorf("range = %+v, %v", got, err)
+			}
+
+			if _, err := l.Query(Query{From: at, To: at}); !errors.Is(err, errRange) {
+				t.Errorf("empty range: %v", err)
+			}
+		})
+	}
+}
+
+func TestLog_RecordNeverBlocks(t *testing.T) {
+	t.Parallel()
+
+	l, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	for range queueSize + 3 {
+		l.Record(Entry{Command: "info"})
+	}
+
+	if l.Dropped() != 3 {
+		t.Errorf("dropped %d, want 3", l.Dropped())
+	}
+
+	var nilLog *Log
+	nilLog.Record(Entry{}) // a nil Log records nothing
+}
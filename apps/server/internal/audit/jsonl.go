@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// maxLine bounds one JSONL entry when reading the file back.
+const maxLine = 64 * 1024
+
+// jsonlStore appends one JSON object per entry to a file, which queries
+// read from the start.
+type jsonlStore struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func openJSONL(path string) (*jsonlStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // operator-configured path
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	return &jsonlStore{path: path, f: f}, nil
+}
+
+func (s *jsonlStore) add(entries []Entry) error {
+	var buf []byte
+
+	for _, e := range entries {
+		e.Time = e.Time.UTC()
+
+		b, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		buf = append(append(buf, b...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.f.Write(buf)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+// query scans the whole file, keeping the newest q.Limit matches.
+func (s *jsonlStore) query(q Query) ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	out := []Entry{}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 4096), maxLine)
+
+	for sc.Scan() {
+		var e Entry
+
+		// A line torn by a crash is skipped rather than failing the query.
+		if json.Unmarshal(sc.Bytes(), &e) != nil || !q.inRange(e.Time) || !q.matches(e) {
+			continue
+		}
+
+		if len(out) == q.Limit {
+			out = out[1:]
+		}
+
+		out = append(out, e)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	slices.Reverse(out)
+
+	return out, nil
+}
+
+func (s *jsonlStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.f.Close()
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
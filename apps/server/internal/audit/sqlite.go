@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS audit (
+	at        INTEGER NOT NULL,
+	user      TEXT NOT NULL,
+	client_ip TEXT NOT NULL,
+	session   TEXT NOT NULL,
+	handle    TEXT NOT NULL,
+	radio     TEXT NOT NULL,
+	source    TEXT NOT NULL,
+	command   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_at ON audit (at);
+CREATE INDEX IF NOT EXISTS audit_session_at ON audit (session, at);
+`
+
+// sqliteStore keeps entries in a table, with times in unix microseconds.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLite(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	// SQLite takes one writer at a time.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) add(entries []Entry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	for _, e := range entries {
+		_, err = tx.Exec(`INSERT INTO audit (at, user, client_ip, session, handle, radio, source, command)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.Time.UnixMicro(), e.User, e.ClientIP, e.Session, e.Handle, e.Radio, e.Source, e.Command)
+		if err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) query(q Query) ([]Entry, error) {
+	var (
+		where []string
+		args  []any
+	)
+
+	filter := func(cond string, v any) {
+		where = append(where, cond)
+		args = append(args, v)
+	}
+
+	if !q.From.IsZero() {
+		filter("at >= ?", q.From.UnixMicro())
+	}
+
+	if !q.To.IsZero() {
+		filter("at < ?", q.To.UnixMicro())
+	}
+
+	for col, v := range map[string]string{"user": q.User, "client_ip": q.ClientIP, "session": q.Session, "radio": q.Radio} {
+		if v != "" {
+			filter(col+" = ?", v)
+		}
+	}
+
+	if q.Contains != "" {
+		filter("instr(command, ?) > 0", q.Contains)
+	}
+
+	stmt := `SELECT at, user, client_ip, session, handle, radio, source, command FROM audit`
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := s.db.Query(stmt+" ORDER BY at DESC, rowid DESC LIMIT ?", append(args, q.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	out := []Entry{}
+
+	for rows.Next() {
+		var (
+			e  Entry
+			at int64
+		)
+
+		err := rows.Scan(&at, &e.User, &e.ClientIP, &e.Session, &e.Handle, &e.Radio, &e.Source, &e.Command)
+		if err != nil {
+			return nil, fmt.Errorf("audit: %w", err)
+		}
+
+		e.Time = time.UnixMicro(at).UTC()
+		out = append(out, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	return out, nil
+}
+
+func (s *sqliteStore) close() error {
+	err := s.db.Close()
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
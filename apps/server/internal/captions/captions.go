@@ -0,0 +1,276 @@
+// Package captions transcribes received audio into timed captions using an
+// OpenAI-compatible speech-to-text endpoint: the hosted API, or a local
+// whisper.cpp server started with --inference-path /v1/audio/transcriptions.
+package captions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+var (
+	errNoEndpoint = errors.New("captions: no endpoint configured")
+	errStatus     = errors.New("captions: transcription failed")
+)
+
+const (
+	// DefaultChunk is how much audio is sent per request. Longer chunks give
+	// the model more context but delay captions.
+	DefaultChunk = 10 * time.Second
+
+	requestTimeout = time.Minute
+	// maxBacklog chunks may wait for a slow endpoint before audio is dropped.
+	maxBacklog = 3
+)
+
+// Options configures the speech-to-text endpoint.
+type Options struct {
+	URL      string // e.g. https://api.openai.com/v1/audio/transcriptions
+	Model    string // e.g. whisper-1
+	APIKey   string
+	Language string // ISO-639-1 hint, optional
+	Chunk    time.Duration
+}
+
+// Caption is one transcribed segment. Start and End are wall-clock times in
+// unix milliseconds.
+type Caption struct {
+	Slice string `json:"slice,omitempty"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	Text  string `json:"text"`
+}
+
+// Client sends audio to the endpoint.
+type Client struct {
+	opt  Options
+	http *http.Client
+}
+
+func New(opt Options) (*Client, error) {
+	if opt.URL == "" {
+		return nil, errNoEndpoint
+	}
+
+	if opt.Chunk <= 0 {
+		opt.Chunk = DefaultChunk
+	}
+
+	return &Client{opt: opt, http: &http.Client{Timeout: requestTimeout}}, nil
+}
+
+type segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type verboseJSON struct {
+	Text     string    `json:"text"`
+	Segments []segment `json:"segments"`
+}
+
+// transcribe posts one Ogg/Opus chunk and returns its segments, with times
+// relative to the start of the chunk.
+func (c *Client) transcribe(ctx context.Context, ogg []byte) ([]segment, error) {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	fw, err := mw.CreateFormFile("file", "audio.ogg")
+	if err != nil {
+		return nil, fmt.Errorf("captions: %w", err)
+	}
+
+	_, _ = fw.Write(ogg)
+	_ = mw.WriteField("response_format", "verbose_json")
+
+	if c.opt.Model != "" {
+		_ = mw.WriteField("model", c.opt.Model)
+	}
+
+	if c.opt.Language != "" {
+		_ = mw.WriteField("language", c.opt.Language)
+	}
+
+	_ = mw.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.opt.URL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("captions: %w", err)
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	if c.opt.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opt.APIKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("captions: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return nil, fmt.Errorf("%w: %s: %s", errStatus, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var out verboseJSON
+
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return nil, fmt.Errorf("captions: decode response: %w", err)
+	}
+
+	// Servers that ignore verbose_json return only text; treat it as one
+	// segment spanning the chunk.
+	if len(out.Segments) == 0 && strings.TrimSpace(out.Text) != "" {
+		out.Segments = []segment{{End: c.opt.Chunk.Seconds(), Text: out.Text}}
+	}
+
+	return out.Segments, nil
+}
+
+// Stream turns a live Opus stream into captions. Audio is cut into chunks of
+// Options.Chunk, each wrapped in an Ogg container and transcribed in order on
+// a background goroutine; when the endpoint falls behind, whole chunks are
+// dropped rather than letting latency grow without bound.
+type Stream struct {
+	c     *Client
+	slice string
+	emit  func(Caption)
+
+	mu       sync.Mutex
+	buf      *bytes.Buffer
+	ogg      *oggwriter.OggWriter
+	started  time.Time
+	duration time.Duration
+
+	queue  chan chunk
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type chunk struct {
+	start time.Time
+	ogg   []byte
+}
+
+// NewStream starts a caption stream. emit is called from the stream's
+// goroutine for every non-empty segment; slice is copied into each caption.
+func (c *Client) NewStream(slice string, emit func(Caption)) *Stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Stream{c: c, slice: slice, emit: emit, queue: make(chan chunk, maxBacklog), cancel: cancel}
+
+	s.wg.Go(func() { s.run(ctx) })
+
+	return s
+}
+
+// WriteOpus adds one Opus packet of the given duration, received now.
+func (s *Stream) WriteOpus(payload []byte, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ogg == nil {
+		s.buf = &bytes.Buffer{}
+
+		w, err := oggwriter.NewWith(s.buf, 48000, 2)
+		if err != nil {
+			log.Printf("[captions] %v", err)
+
+			return
+		}
+
+		s.ogg = w
+		s.started = time.Now()
+		s.duration = 0
+	}
+
+	err := s.ogg.WriteRTP(&rtp.Packet{Payload: payload})
+	if err != nil {
+		return
+	}
+
+	s.duration += d
+	if s.duration >= s.c.opt.Chunk {
+		s.flushLocked()
+	}
+}
+
+func (s *Stream) flushLocked() {
+	if s.ogg == nil {
+		return
+	}
+
+	_ = s.ogg.Close()
+	c := chunk{start: s.started, ogg: s.buf.Bytes()}
+	s.ogg, s.buf = nil, nil
+
+	select {
+	case s.queue <- c:
+	default:
+		log.Printf("[captions] endpoint is falling behind; dropped %s of audio", s.duration)
+	}
+}
+
+// Close stops the stream. Audio not yet sent is discarded.
+func (s *Stream) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Stream) run(ctx context.Context) {
+	for {
+		var c chunk
+
+		select {
+		case <-ctx.Done():
+			return
+		case c = <-s.queue:
+		}
+
+		segs, err := s.c.transcribe(ctx, c.ogg)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[captions] %v", err)
+			}
+
+			continue
+		}
+
+		for _, seg := range segs {
+			text := strings.TrimSpace(seg.Text)
+			if text == "" {
+				continue
+			}
+
+			s.emit(Caption{
+				Slice: s.slice,
+				Start: c.start.Add(seconds(seg.Start)).UnixMilli(),
+				End:   c.start.Add(seconds(seg.End)).UnixMilli(),
+				Text:  text,
+			})
+		}
+	}
+}
+
+func seconds(f float64) time.Duration {
+	return time.Duration(f * float64(time.Second))
+}
@@ -0,0 +1,98 @@
+package captions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	got := make(chan map[string]string, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseMultipartForm(1 << 20)
+		if err != nil {
+			t.Error(err)
+		}
+
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+		} else {
+			magic := make([]byte, 4)
+			_, _ = f.Read(magic)
+
+			if string(magic) != "OggS" {
+				t.Errorf("upload starts with %q, want an Ogg page", magic)
+			}
+		}
+
+		got <- map[string]string{
+			"model":  r.FormValue("model"),
+			"lang":   r.FormValue("language"),
+			"auth":   r.Header.Get("Authorization"),
+			"format": r.FormValue("response_format"),
+		}
+
+		_ = json.NewEncoder(w).Encode(verboseJSON{Segments: []segment{
+			{Start: 0.5, End: 2, Text: " CQ CQ de N0CALL "},
+			{Start: 2, End: 3, Text: "  "},
+		}})
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := New(Options{URL: srv.URL, Model: "whisper-1", APIKey: "k", Language: "en", Chunk: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	captions := make(chan Caption, 4)
+	s := c.NewStream("A", func(c Caption) { captions <- c })
+	t.Cleanup(s.Close)
+
+	// 10 ms CELT-only frames (TOC config 16, one frame).
+	frame := []byte{0x80, 0xff, 0xfe}
+	for range 10 {
+		s.WriteOpus(frame, 10*time.Millisecond)
+	}
+
+	select {
+	case req := <-got:
+		want := map[string]string{"model": "whisper-1", "lang": "en", "auth": "Bearer k", "format": "verbose_json"}
+		for k, v := range want {
+			if req[k] != v {
+				t.Errorf("%s = %q, want %q", k, req[k], v)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transcription request")
+	}
+
+	select {
+	case caption := <-captions:
+		if caption.Slice != "A" || caption.Text != "CQ CQ de N0CALL" || caption.End-caption.Start != 1500 {
+			t.Errorf("caption = %+v", caption)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no caption")
+	}
+
+	select {
+	case caption := <-captions:
+		t.Errorf("blank segment should be skipped, got %+v", caption)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNewRequiresURL(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(Options{})
+	if err == nil {
+		t.Fatal("expected an error without a URL")
+	}
+}
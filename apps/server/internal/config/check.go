@@ -0,0 +1,673 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/schedule"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spectate"
+	"github.com/pion/stun/v3"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	errInvalidPort = errors.New("invalid port")
+	errInvalidSTUN = errors.New("invalid STUN/TURN URL")
+	errTLSPair     = errors.New("tls-cert and tls-key must be set together")
+	errPortOverlap = errors.New("port assigned twice")
+	errAntennaRule = errors.New("invalid antenna rule")
+	errAntennaPoll = errors.New("antenna-poll must be positive")
+	errLogbookAddr = errors.New("invalid logbook address")
+	errSpotFeed    = errors.New("invalid spot feed address")
+	errSpotLogin   = errors.New("spot-callsign is required with spot-feeds")
+	errProxyRoute  = errors.New("invalid proxy route")
+	errResume      = errors.New("resume-window must be positive")
+	errRetention   = errors.New("history-retention must be positive")
+	errMeterAlert  = errors.New("invalid meter alert")
+	errAlertRule   = errors.New("invalid alert rule")
+	errAlertSink   = errors.New("invalid alert channel")
+	errJob         = errors.New("invalid scheduled job")
+	errWake        = errors.New("invalid remote power-on setting")
+	errSpectator   = errors.New("spectator-secret is too short")
+	errLineBatch   = errors.New("invalid line batching")
+	errWSDeflate   = errors.New("ws-deflate-min must not be negative")
+	errTURN        = errors.New("invalid TURN relay")
+	errNAT1To1     = errors.New("invalid NAT 1:1 address")
+	errDSCP        = errors.New("DSCP code point must be 0 to 63")
+	errChannel     = errors.New("stream channel must be reliable, unordered, lossy or udp")
+	errIdentity    = errors.New("client identity must not contain | or line breaks")
+	errTXAudio     = errors.New("invalid tx-audio setting")
+	errMonitor     = errors.New("invalid audio monitor setting")
+	errCORS        = errors.New("cors-origins * can't allow credentials")
+)
+
+// Setting is one option's effective value and where it came from: "flag",
+// "env", "file" or "default".
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// Check reads the configuration as Load does and returns every setting with
+// its source, along with every problem found rather than only the first.
+func Check() ([]Setting, []error) {
+	cfg, v, err := load()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	problems := validate(&cfg)
+
+	return settings(v), problems
+}
+
+// validate checks cfg, filling in what is derived from it, and returns the
+// problems found.
+func validate(cfg *Config) []error {
+	var errs []error
+
+	var err error
+
+	cfg.Location, err = time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("timezone: %w", err))
+	}
+
+	switch cfg.Preflight {
+	case "off", "registry", "tcp":
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", errInvalidPreflight, cfg.Preflight))
+	}
+
+	switch cfg.DiscoverySlowConsumer {
+	case "drop-oldest", "disconnect":
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", errInvalidSlowConsumer, cfg.DiscoverySlowConsumer))
+	}
+
+	switch cfg.TXToken {
+	case "off", "grant", "steal":
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", errInvalidTXToken, cfg.TXToken))
+	}
+
+	switch cfg.AudioRedundancy {
+	case "auto", "on", "off":
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", errInvalidRedundancy, cfg.AudioRedundancy))
+	}
+
+	cfg.BandwidthShed, err = bandwidthShed(cfg.BandwidthShed)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.UDPWorkers < 1 || cfg.UDPQueue < cfg.UDPWorkers {
+		errs = append(errs, fmt.Errorf("%w: %d workers, queue of %d", errInvalidUDPPipeline, cfg.UDPWorkers, cfg.UDPQueue))
+	}
+
+	if cfg.UDPRcvBuf < 0 {
+		errs = append(errs, fmt.Errorf("%w: receive buffer of %d", errInvalidUDPPipeline, cfg.UDPRcvBuf))
+	}
+
+	if cfg.LineBatch < 0 || cfg.LineBatchBytes < 1 {
+		errs = append(errs, fmt.Errorf("%w: %s, %d bytes", errLineBatch, cfg.LineBatch, cfg.LineBatchBytes))
+	}
+
+	if cfg.WSDeflateMin < 0 {
+		errs = append(errs, fmt.Errorf("%w: %d", errWSDeflate, cfg.WSDeflateMin))
+	}
+
+	for _, c := range []struct{ name, mode string }{
+		{"channel-fft", cfg.ChannelFFT},
+		{"channel-waterfall", cfg.ChannelWaterfall},
+		{"channel-meters", cfg.ChannelMeters},
+		{"channel-iq", cfg.ChannelIQ},
+	} {
+		switch c.mode {
+		case "reliable", "unordered", "lossy", "udp":
+		default:
+			errs = append(errs, fmt.Errorf("%w: %s %q", errChannel, c.name, c.mode))
+		}
+	}
+
+	for _, d := range []struct {
+		name  string
+		value int
+	}{{"dscp-media", cfg.DSCPMedia}, {"dscp-data", cfg.DSCPData}} {
+		if d.value < 0 || d.value > 63 {
+			errs = append(errs, fmt.Errorf("%w: %s %d", errDSCP, d.name, d.value))
+		}
+	}
+
+	for _, id := range []struct{ name, value string }{
+		{"client-program", cfg.ClientProgram},
+		{"client-version", cfg.ClientVersion},
+		{"client-station", cfg.ClientStation},
+	} {
+		if strings.ContainsAny(id.value, "|\r\n") {
+			errs = append(errs, fmt.Errorf("%w: %s %q", errIdentity, id.name, id.value))
+		}
+	}
+
+	if cfg.UDPPortEnd == 0 {
+		cfg.UDPPortEnd = cfg.UDPPortStart
+	}
+
+	if cfg.UDPPortEnd < cfg.UDPPortStart || (cfg.UDPPortStart == 0) != (cfg.UDPPortEnd == 0) {
+		errs = append(errs, fmt.Errorf("%w: %d–%d", errInvalidUDPPortRange, cfg.UDPPortStart, cfg.UDPPortEnd))
+	}
+
+	if cfg.ICEPortEnd < cfg.ICEPortStart {
+		errs = append(errs, fmt.Errorf("%w: %d–%d", errInvalidICEPortRange, cfg.ICEPortStart, cfg.ICEPortEnd))
+	}
+
+	for _, p := range []struct {
+		name      string
+		port, min int
+	}{
+		{"http-port", cfg.HTTPPort, 1},
+		{"http3-port", cfg.HTTP3Port, 0},
+		{"discovery-port", cfg.DiscoveryPort, 1},
+	} {
+		if p.port < p.min || p.port > 65535 {
+			errs = append(errs, fmt.Errorf("%w: %s %d", errInvalidPort, p.name, p.port))
+		}
+	}
+
+	for _, u := range cfg.StunURLs {
+		_, err := stun.ParseURI(u)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w %q: %w", errInvalidSTUN, u, err))
+		}
+	}
+
+	errs = append(errs, checkTURN(cfg)...)
+
+	// Brackets are allowed, as an IPv6 address is often written with them.
+	for _, ip := range cfg.NAT1To1IPs {
+		if net.ParseIP(strings.Trim(ip, "[]")) == nil {
+			errs = append(errs, fmt.Errorf("%w %q: not an IP address", errNAT1To1, ip))
+		}
+	}
+
+	if cfg.AntennaPoll <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errAntennaPoll, cfg.AntennaPoll))
+	}
+
+	for i, r := range cfg.AntennaRules {
+		if r.Port < 1 || r.Antenna < 1 || r.To < r.From {
+			errs = append(errs, fmt.Errorf("%w %d: port %d, antenna %d, %g–%g MHz", errAntennaRule, i+1, r.Port, r.Antenna, r.From, r.To))
+		}
+	}
+
+	for _, addr := range cfg.LogbookBroadcast {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("%w: logbook-broadcast %q", errLogbookAddr, addr))
+		}
+	}
+
+	for _, addr := range cfg.LogbookForward {
+		if _, _, err := net.SplitHostPort(strings.TrimPrefix(addr, "tcp://")); err != nil {
+			errs = append(errs, fmt.Errorf("%w: logbook-forward %q", errLogbookAddr, addr))
+		}
+	}
+
+	for _, addr := range cfg.SpotFeeds {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %q", errSpotFeed, addr))
+		}
+	}
+
+	if len(cfg.SpotFeeds) > 0 && cfg.SpotCallsign == "" {
+		errs = append(errs, errSpotLogin)
+	}
+
+	if cfg.SessionState != "" && cfg.ResumeWindow <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errResume, cfg.ResumeWindow))
+	}
+
+	if cfg.HistoryDB != "" && cfg.HistoryRetention <= 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", errRetention, cfg.HistoryRetention))
+	}
+
+	for i, p := range cfg.Proxies {
+		u, err := url.Parse(p.Upstream)
+		if len(p.Prefix) < 2 || !strings.HasPrefix(p.Prefix, "/") || !strings.HasSuffix(p.Prefix, "/") ||
+			err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%w %d: prefix %q, upstream %q", errProxyRoute, i+1, p.Prefix, p.Upstream))
+		}
+	}
+
+	for i, a := range cfg.MeterAlerts {
+		if a.Meter == "" || (a.Above == nil) == (a.Below == nil) {
+			errs = append(errs, fmt.Errorf("%w %d: meter %q needs one of above and below", errMeterAlert, i+1, a.Meter))
+		}
+	}
+
+	errs = append(errs, checkAlerts(cfg.Alerts)...)
+	errs = append(errs, checkTXAudio(cfg.TXAudio)...)
+	errs = append(errs, checkMonitor(cfg)...)
+	errs = append(errs, checkCORS(cfg)...)
+	errs = append(errs, checkSchedule(cfg.Schedule)...)
+	errs = append(errs, checkWake(cfg)...)
+	errs = append(errs, checkSpectators(cfg)...)
+	errs = append(errs, checkTLS(cfg)...)
+	errs = append(errs, checkPorts(cfg)...)
+
+	return errs
+}
+
+// checkCORS checks no policy, the default or a route's, allows credentials
+// to any origin.
+func checkCORS(cfg *Config) []error {
+	if !cfg.EnableCORS {
+		return nil
+	}
+
+	var errs []error
+
+	if cfg.CORSAllowCredentials && slices.Contains(cfg.CORSOrigins, "*") {
+		errs = append(errs, fmt.Errorf("%w: list the origins that may send credentials", errCORS))
+	}
+
+	for _, rc := range cfg.CORSRoutes {
+		origins, creds := cfg.CORSOrigins, cfg.CORSAllowCredentials
+		if rc.Origins != nil {
+			origins = rc.Origins
+		}
+
+		if rc.AllowCredentials != nil {
+			creds = *rc.AllowCredentials
+		}
+
+		if creds && slices.Contains(origins, "*") && (rc.Origins != nil || rc.AllowCredentials != nil) {
+			errs = append(errs, fmt.Errorf("%w: cors-routes %q", errCORS, rc.Prefix))
+		}
+	}
+
+	return errs
+}
+
+// checkMonitor checks the audio monitor has a radio and a slice to play.
+func checkMonitor(cfg *Config) []error {
+	if cfg.MonitorRadio == "" {
+		if cfg.MonitorMic != "" {
+			return []error{fmt.Errorf("%w: monitor-mic needs monitor-radio", errMonitor)}
+		}
+
+		return nil
+	}
+
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(cfg.MonitorRadio); err != nil {
+		errs = append(errs, fmt.Errorf("%w: monitor-radio %q is not host:port", errMonitor, cfg.MonitorRadio))
+	}
+
+	if cfg.MonitorFrequency <= 0 || cfg.MonitorFrequency > 54 {
+		errs = append(errs, fmt.Errorf("%w: monitor-frequency %g MHz is not 0 to 54", errMonitor, cfg.MonitorFrequency))
+	}
+
+	if cfg.MonitorMode == "" || strings.ContainsAny(cfg.MonitorMode, " |\r\n") {
+		errs = append(errs, fmt.Errorf("%w: monitor-mode %q", errMonitor, cfg.MonitorMode))
+	}
+
+	if strings.ContainsAny(cfg.MonitorStation, "|\r\n") {
+		errs = append(errs, fmt.Errorf("%w: monitor-station %q", errMonitor, cfg.MonitorStation))
+	}
+
+	return errs
+}
+
+// checkTXAudio checks each profile's ranges and that every profile named
+// exists.
+func checkTXAudio(tc TXAudioConfig) []error {
+	var errs []error
+
+	for _, name := range slices.Sorted(maps.Keys(tc.Profiles)) {
+		p := tc.Profiles[name]
+
+		switch {
+		case len(p.EQ) > 8:
+			errs = append(errs, fmt.Errorf("%w: profile %q has %d EQ bands, at most 8", errTXAudio, name, len(p.EQ)))
+		case p.Gain < -24 || p.Gain > 24 || slices.ContainsFunc(p.EQ, func(g float64) bool { return g < -24 || g > 24 }):
+			errs = append(errs, fmt.Errorf("%w: profile %q gains must be -24 to 24 dB", errTXAudio, name))
+		case p.Limit < -24 || p.Limit > 0:
+			errs = append(errs, fmt.Errorf("%w: profile %q limit must be -24 to 0 dBFS", errTXAudio, name))
+		case p.Compressor.Threshold < -60 || p.Compressor.Threshold > 0 ||
+			(p.Compressor.Threshold < 0 && (p.Compressor.Ratio <= 1 || p.Compressor.Ratio > 20)):
+			errs = append(errs, fmt.Errorf("%w: profile %q compressor needs a threshold of -60 to 0 dBFS and a ratio above 1, up to 20", errTXAudio, name))
+		}
+	}
+
+	for _, user := range slices.Sorted(maps.Keys(tc.Users)) {
+		if _, ok := tc.Profiles[tc.Users[user]]; !ok {
+			errs = append(errs, fmt.Errorf("%w: user %q has unknown profile %q", errTXAudio, user, tc.Users[user]))
+		}
+	}
+
+	if _, ok := tc.Profiles[tc.Default]; tc.Default != "" && !ok {
+		errs = append(errs, fmt.Errorf("%w: unknown default profile %q", errTXAudio, tc.Default))
+	}
+
+	return errs
+}
+
+// checkAlerts checks the alert rules and that each channel is complete.
+func checkAlerts(ac AlertsConfig) []error {
+	var errs []error
+
+	for i, r := range ac.Rules {
+		switch r.Kind {
+		case "radio-lost", "meter", "restart":
+		case "long-tx":
+			if r.After <= 0 {
+				errs = append(errs, fmt.Errorf("%w %d: long-tx needs a positive after", errAlertRule, i+1))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%w %d: kind %q", errAlertRule, i+1, r.Kind))
+		}
+	}
+
+	for _, raw := range slices.Concat(ac.Webhooks, ac.Ntfy) {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%w: URL %q", errAlertSink, raw))
+		}
+	}
+
+	if (ac.Pushover.Token == "") != (ac.Pushover.UserKey == "") {
+		errs = append(errs, fmt.Errorf("%w: pushover needs both token and user-key", errAlertSink))
+	}
+
+	if e := ac.Email; e.SMTP != "" || e.From != "" || len(e.To) > 0 {
+		if _, _, err := net.SplitHostPort(e.SMTP); err != nil || e.From == "" || len(e.To) == 0 {
+			errs = append(errs, fmt.Errorf("%w: email needs smtp as host:port, from and to", errAlertSink))
+		}
+	}
+
+	return errs
+}
+
+// checkWake checks the wake-on-LAN target and the configured MACs.
+func checkWake(cfg *Config) []error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(cfg.WakeBroadcast); cfg.WakeBroadcast != "" && err != nil {
+		errs = append(errs, fmt.Errorf("%w: wake-broadcast %q must be host:port", errWake, cfg.WakeBroadcast))
+	}
+
+	for serial, mac := range cfg.RadioMACs {
+		if _, err := net.ParseMAC(mac); err != nil {
+			errs = append(errs, fmt.Errorf("%w: radio-macs %s: %q", errWake, serial, mac))
+		}
+	}
+
+	return errs
+}
+
+// checkTURN checks that the TURN relay's URLs are TURN URLs and that it has
+// the credentials every relay asks for.
+func checkTURN(cfg *Config) []error {
+	if len(cfg.TURNURLs) == 0 {
+		return nil
+	}
+
+	var errs []error
+
+	for _, u := range cfg.TURNURLs {
+		uri, err := stun.ParseURI(u)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w %q: %w", errTURN, u, err))
+		} else if uri.Scheme != stun.SchemeTypeTURN && uri.Scheme != stun.SchemeTypeTURNS {
+			errs = append(errs, fmt.Errorf("%w %q: not a turn: or turns: URL", errTURN, u))
+		}
+	}
+
+	if cfg.TURNUsername == "" || cfg.TURNCredential == "" {
+		errs = append(errs, fmt.Errorf("%w: turn-username and turn-credential are required", errTURN))
+	}
+
+	return errs
+}
+
+// checkSpectators checks the key that signs spectator links.
+func checkSpectators(cfg *Config) []error {
+	if n := len(cfg.SpectatorSecret); n > 0 && n < spectate.MinSecret {
+		return []error{fmt.Errorf("%w: need at least %d characters", errSpectator, spectate.MinSecret)}
+	}
+
+	return nil
+}
+
+// checkSchedule checks each job's name, cron expression and what its action
+// needs.
+func checkSchedule(sc ScheduleConfig) []error {
+	var errs []error
+
+	names := make(map[string]bool)
+
+	for i, j := range sc.Jobs {
+		bad := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("%w %d (%s): %s", errJob, i+1, j.Name, fmt.Sprintf(format, args...)))
+		}
+
+		if j.Name == "" || names[j.Name] {
+			bad("needs a unique name")
+		}
+
+		names[j.Name] = true
+
+		_, err := schedule.ParseSpec(j.Cron)
+		if err != nil {
+			bad("%v", err)
+		}
+
+		switch j.Action {
+		case schedule.ActionPowerOn:
+			if j.MAC == "" && j.URL == "" {
+				bad("power-on needs mac or url")
+			}
+
+			if _, err := net.ParseMAC(j.MAC); j.MAC != "" && err != nil {
+				bad("mac %q", j.MAC)
+			}
+		case schedule.ActionPowerOff:
+			if j.URL == "" {
+				bad("power-off needs url")
+			}
+		case schedule.ActionProfile:
+			if j.Radio == "" || j.Profile == "" {
+				bad("profile needs radio and profile")
+			}
+		case schedule.ActionRecord:
+			if j.Radio == "" {
+				bad("record needs radio")
+			}
+
+			if j.Format != "" && j.Format != "ogg" && j.Format != "wav" {
+				bad("format %q", j.Format)
+			}
+		case schedule.ActionMuteTX, schedule.ActionUnmuteTX:
+		default:
+			bad("action %q", j.Action)
+		}
+
+		if j.URL != "" {
+			u, err := url.Parse(j.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				bad("url %q", j.URL)
+			}
+		}
+	}
+
+	if sc.Broadcast != "" {
+		if _, _, err := net.SplitHostPort(sc.Broadcast); err != nil {
+			errs = append(errs, fmt.Errorf("%w: broadcast %q must be host:port", errJob, sc.Broadcast))
+		}
+	}
+
+	return errs
+}
+
+// checkTLS checks the certificate and key are set together and exist.
+func checkTLS(cfg *Config) []error {
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return []error{errTLSPair}
+	}
+
+	var errs []error
+
+	for _, f := range []struct{ name, path string }{{"tls-cert", cfg.TLSCert}, {"tls-key", cfg.TLSKey}} {
+		if f.path == "" {
+			continue
+		}
+
+		_, err := os.Stat(f.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+
+	return errs
+}
+
+// portUse is a range of ports one option listens on.
+type portUse struct {
+	name   string
+	proto  string
+	lo, hi int
+}
+
+// checkPorts reports any port two options would both listen on.
+func checkPorts(cfg *Config) []error {
+	uses := []portUse{
+		{"http-port", "tcp", cfg.HTTPPort, cfg.HTTPPort},
+		{"ice-port-start..ice-port-end", "udp", int(cfg.ICEPortStart), int(cfg.ICEPortEnd)},
+		{"discovery-port", "udp", cfg.DiscoveryPort, cfg.DiscoveryPort},
+	}
+
+	if cfg.EnableHTTP3 {
+		port := cfg.HTTP3Port
+		if port == 0 {
+			port = cfg.HTTPPort
+		}
+
+		uses = append(uses, portUse{"http3-port", "udp", port, port})
+	}
+
+	if cfg.ICETCPPort != 0 {
+		uses = append(uses, portUse{"ice-tcp-port", "tcp", int(cfg.ICETCPPort), int(cfg.ICETCPPort)})
+	}
+
+	if cfg.UDPPortStart != 0 {
+		uses = append(uses, portUse{"udp-port-start..udp-port-end", "udp", int(cfg.UDPPortStart), int(cfg.UDPPortEnd)})
+	}
+
+	for _, l := range []struct{ name, proto, addr string }{
+		{"grpc-listen", "tcp", cfg.GRPCListen},
+		{"rigctl-listen", "tcp", cfg.RigctlListen},
+		{"cat-listen", "tcp", cfg.CATListen},
+		{"wsjtx-listen", "udp", cfg.WSJTXListen},
+		{"logbook-listen", "udp", cfg.LogbookListen},
+		{"logbook-listen", "tcp", cfg.LogbookListen},
+	} {
+		if port := listenPort(l.addr); port != 0 {
+			uses = append(uses, portUse{l.name, l.proto, port, port})
+		}
+	}
+
+	var errs []error
+
+	for i, a := range uses {
+		for _, b := range uses[i+1:] {
+			if a.proto == b.proto && a.lo <= b.hi && b.lo <= a.hi {
+				errs = append(errs, fmt.Errorf("%w: %s and %s share %s port %d",
+					errPortOverlap, a.name, b.name, a.proto, max(a.lo, b.lo)))
+			}
+		}
+	}
+
+	return errs
+}
+
+// listenPort is the port of a listen address, or 0 if it has none.
+func listenPort(addr string) int {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0
+	}
+
+	n, _ := strconv.Atoi(port)
+
+	return n
+}
+
+// settings lists every setting v knows, sorted by name. Secrets are masked.
+func settings(v *viper.Viper) []Setting {
+	keys := v.AllKeys()
+	slices.Sort(keys)
+
+	out := make([]Setting, 0, len(keys))
+
+	for _, k := range keys {
+		out = append(out, Setting{Name: k, Value: settingValue(k, v.Get(k)), Source: settingSource(v, k)})
+	}
+
+	return out
+}
+
+func settingSource(v *viper.Viper, key string) string {
+	if f := pflag.CommandLine.Lookup(key); f != nil && f.Changed {
+		return "flag"
+	}
+
+	if _, ok := os.LookupEnv("FLEX_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))); ok {
+		return "env"
+	}
+
+	if v.InConfig(key) {
+		return "file"
+	}
+
+	return "default"
+}
+
+func settingValue(key string, value any) string {
+	var s string
+
+	switch x := value.(type) {
+	case []string:
+		s = strings.Join(x, ",")
+	default:
+		s = fmt.Sprint(x)
+	}
+
+	if s != "" && isSecret(key) {
+		return "********"
+	}
+
+	return s
+}
+
+// isSecret reports whether key holds a token, password or key.
+func isSecret(key string) bool {
+	for _, suffix := range []string{"token", "secret", "secret-key", "access-key", "api-key", "user-key", "password", "credential", "webhooks"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
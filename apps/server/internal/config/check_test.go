@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// validConfig is the defaults Load gives.
+func validConfig() Config {
+	return Config{
+		HTTPPort:              8080,
+		DiscoveryPort:         4992,
+		ICEPortStart:          50313,
+		ICEPortEnd:            50313,
+		StunURLs:              []string{"stun:stun.l.google.com:19302", "turn:turn.example.com:3478?transport=tcp"},
+		Timezone:              "UTC",
+		Preflight:             "off",
+		DiscoverySlowConsumer: "drop-oldest",
+		AudioRedundancy:       "auto",
+		UDPWorkers:            2,
+		UDPQueue:              1024,
+		LineBatchBytes:        16 * 1024,
+		ChannelFFT:            "lossy",
+		ChannelWaterfall:      "lossy",
+		ChannelMeters:         "lossy",
+		ChannelIQ:             "reliable",
+		DSCPMedia:             46,
+		DSCPData:              18,
+		AntennaPoll:           10 * time.Second,
+		WakeBroadcast:         "255.255.255.255:9",
+		TXToken:               "off",
+	}
+}
+
+func TestValidate_Defaults(t *testing.T) {
+	t.Parallel()
+
+	cfg := validConfig()
+	if errs := validate(&cfg); len(errs) != 0 {
+		t.Fatalf("problems with the defaults: %v", errs)
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	cfg := validConfig()
+	cfg.Preflight = "sometimes"
+	cfg.TXToken = "first-come"
+	cfg.StunURLs = []string{"stun.example.com"}
+	cfg.TLSCert = "cert.pem"
+	cfg.HTTPPort = 70000
+	cfg.AntennaRules = []AntennaRule{{Port: 1, From: 14.35, To: 14.0, Antenna: 2}}
+	cfg.LogbookForward = []string{"tcp://logger.lan:2333", "logger.lan"}
+	cfg.SpotFeeds = []string{"telnet.reversebeacon.net"}
+	cfg.SessionState = "sessions.json"
+	cfg.HistoryDB = "history.db"
+	cfg.Proxies = []ProxyRoute{{Prefix: "/proxy/tiles", Upstream: "https://tile.example.org/"}}
+	cfg.MeterAlerts = []MeterAlert{{Meter: "SWR"}}
+	cfg.Alerts = AlertsConfig{Rules: []AlertRule{{Kind: "long-tx"}}, Ntfy: []string{"ntfy.sh/station"}}
+	cfg.Schedule = ScheduleConfig{Jobs: []ScheduledJob{{Name: "quiet", Cron: "0 22 * *", Action: "mute-tx"}}}
+	cfg.RadioMACs = map[string]string{"1225-1213-8600-7918": "00:1c:2d"}
+	cfg.SpectatorSecret = "hunter2"
+	cfg.LineBatch = -time.Millisecond
+	cfg.WSDeflateMin = -1
+	cfg.TURNURLs = []string{"stun:turn.example.com:443"}
+	cfg.NAT1To1IPs = []string{"bridge.example.com"}
+	cfg.DSCPMedia = 64
+	cfg.ChannelIQ = "fast"
+	cfg.ClientStation = "Shack|PC"
+	cfg.TXAudio = TXAudioConfig{Default: "voice", Profiles: map[string]TXAudioProfile{"loud": {Limit: 3}}}
+	cfg.MonitorRadio = "192.168.1.20"
+	cfg.EnableCORS = true
+	cfg.CORSOrigins = []string{"*"}
+	cfg.CORSAllowCredentials = true
+
+	errs := validate(&cfg)
+
+	for _, want := range []error{errInvalidPreflight, errInvalidTXToken, errInvalidSTUN, errTLSPair, errInvalidPort, errAntennaRule, errLogbookAddr, errSpotFeed, errSpotLogin, errProxyRoute, errResume, errRetention, errMeterAlert, errAlertRule, errAlertSink, errJob, errWake, errSpectator, errLineBatch, errWSDeflate, errTURN, errNAT1To1, errDSCP, errChannel, errIdentity, errTXAudio, errMonitor, errCORS} {
+		if !errors.Is(errors.Join(errs...), want) {
+			t.Errorf("missing %v in %v", want, errs)
+		}
+	}
+}
+
+func TestValidate_MissingTLSFile(t *testing.T) {
+	t.Parallel()
+
+	cfg := validConfig()
+	cfg.TLSCert = t.TempDir() + "/cert.pem"
+	cfg.TLSKey = cfg.TLSCert
+
+	if errs := validate(&cfg); len(errs) != 2 {
+		t.Fatalf("want cert and key missing, got %v", errs)
+	}
+}
+
+func TestCheckPorts(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		edit    func(*Config)
+		overlap bool
+	}{
+		"ice on discovery":         {func(c *Config) { c.ICEPortStart, c.ICEPortEnd = 4990, 5000 }, true},
+		"ice on http, tcp and udp": {func(c *Config) { c.ICEPortStart, c.ICEPortEnd = 8080, 8080 }, false},
+		"http3 on ice": {func(c *Config) {
+			c.EnableHTTP3, c.HTTPPort = true, 50313
+		}, true},
+		"shared udp inside ice": {func(c *Config) {
+			c.ICEPortEnd, c.UDPPortStart, c.UDPPortEnd = 50400, 50350, 50360
+		}, true},
+		"grpc on http":    {func(c *Config) { c.GRPCListen = ":8080" }, true},
+		"ice-tcp on http": {func(c *Config) { c.ICETCPPort = 8080 }, true},
+		"ice-tcp on ice":  {func(c *Config) { c.ICETCPPort = 50313 }, false},
+		"wsjtx elsewhere": {func(c *Config) { c.WSJTXListen = "127.0.0.1:2237" }, false},
+		"logbook on wsjtx": {func(c *Config) {
+			c.WSJTXListen, c.LogbookListen = "127.0.0.1:2237", ":2237"
+		}, true},
+	}
+
+	for name, tc := range cases {
+		cfg := validConfig()
+		tc.edit(&cfg)
+
+		errs := checkPorts(&cfg)
+		if got := errors.Is(errors.Join(errs...), errPortOverlap); got != tc.overlap {
+			t.Errorf("%s: overlap %v, want %v (%v)", name, got, tc.overlap, errs)
+		}
+	}
+}
+
+func TestSettingValue_MasksSecrets(t *testing.T) {
+	t.Parallel()
+
+	if got := settingValue("admin-token", "hunter2"); got != "********" {
+		t.Errorf("admin-token shown as %q", got)
+	}
+
+	if got := settingValue("storage.s3.secret-key", "abc"); got != "********" {
+		t.Errorf("secret-key shown as %q", got)
+	}
+
+	if got := settingValue("spectator-secret", "abc"); got != "********" {
+		t.Errorf("spectator-secret shown as %q", got)
+	}
+
+	if got := settingValue("turn-credential", "abc"); got != "********" {
+		t.Errorf("turn-credential shown as %q", got)
+	}
+
+	if got := settingValue("admin-token", ""); got != "" {
+		t.Errorf("empty token shown as %q", got)
+	}
+
+	if got := settingValue("stun", []string{"a", "b"}); got != "a,b" {
+		t.Errorf("stun shown as %q", got)
+	}
+}
@@ -5,14 +5,23 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-var errInvalidICEPortRange = errors.New("invalid ICE port range")
+var (
+	errInvalidICEPortRange = errors.New("invalid ICE port range")
+	errInvalidPreflight    = errors.New("invalid preflight mode")
+	errInvalidSlowConsumer = errors.New("invalid discovery slow-consumer policy")
+	errInvalidRedundancy   = errors.New("invalid audio redundancy mode")
+	errInvalidBandwidth    = errors.New("invalid bandwidth stream")
+	errInvalidUDPPipeline  = errors.New("invalid UDP pipeline size")
+	errInvalidUDPPortRange = errors.New("invalid shared UDP port range")
+	errInvalidTXToken      = errors.New("invalid tx-token policy")
+)
 
 type Config struct {
 	// HTTP
@@ -22,32 +31,425 @@ type Config struct {
 	EnableCORS    bool   `mapstructure:"enable-cors"`
 	DiscoveryPort int    `mapstructure:"discovery-port"`
 
+	// Discovery fan-out
+	DiscoverySlowConsumer string `mapstructure:"discovery-slow-consumer"`
+	DiscoveryMaxBuffer    int    `mapstructure:"discovery-max-buffer"`
+
+	DiscoveryDedupWindow time.Duration `mapstructure:"discovery-dedup-window"`
+
+	// Discovery relay
+	DiscoveryRelayPeer      string `mapstructure:"discovery-relay-peer"`
+	DiscoveryRelayToken     string `mapstructure:"discovery-relay-token"`
+	DiscoveryRelayBroadcast string `mapstructure:"discovery-relay-broadcast"`
+
+	// Remote power-on
+	WakeBroadcast string            `mapstructure:"wake-broadcast"`
+	RadioMACs     map[string]string `mapstructure:"radio-macs"` // serial -> MAC (config file only)
+
+	// Bridge federation
+	FederationHub   string `mapstructure:"federation-hub"`
+	FederationToken string `mapstructure:"federation-token"`
+	FederationName  string `mapstructure:"federation-name"`
+
+	// TLS / protocols
+	TLSCert     string `mapstructure:"tls-cert"`
+	TLSKey      string `mapstructure:"tls-key"`
+	EnableHTTP2 bool   `mapstructure:"enable-http2"`
+	EnableH2C   bool   `mapstructure:"enable-h2c"`
+	EnableHTTP3 bool   `mapstructure:"enable-http3"`
+	HTTP3Port   int    `mapstructure:"http3-port"`
+
+	// WebTransport sessions
+	EnableWebTransport bool `mapstructure:"enable-webtransport"`
+
+	// CORS
+	CORSOrigins          []string      `mapstructure:"cors-origins"`
+	CORSAllowCredentials bool          `mapstructure:"cors-allow-credentials"`
+	CORSAllowHeaders     []string      `mapstructure:"cors-allow-headers"`
+	CORSExposeHeaders    []string      `mapstructure:"cors-expose-headers"`
+	CORSMaxAge           time.Duration `mapstructure:"cors-max-age"`
+	CORSRoutes           []CORSRoute   `mapstructure:"cors-routes"` // config file only
+
+	// WebSocket text messages compressed from this size; 0 = off
+	WSDeflateMin int `mapstructure:"ws-deflate-min"`
+
+	// Access control
+	WSOrigins     []string `mapstructure:"ws-origins"`
+	AllowCIDRs    []string `mapstructure:"allow-cidrs"`
+	DenyCIDRs     []string `mapstructure:"deny-cidrs"`
+	AllowedRadios []string `mapstructure:"allowed-radios"`
+	MaxSessions   int      `mapstructure:"max-sessions"`
+	Preflight     string   `mapstructure:"preflight"`
+
+	DrainTimeout time.Duration `mapstructure:"drain-timeout"`
+	DrainPeer    string        `mapstructure:"drain-peer"`
+	SessionState string        `mapstructure:"session-state"`
+	ResumeWindow time.Duration `mapstructure:"resume-window"`
+	StaleCleanup bool          `mapstructure:"stale-cleanup"`
+
+	// How sessions name themselves to the radio
+	ClientProgram string `mapstructure:"client-program"`
+	ClientVersion string `mapstructure:"client-version"`
+	ClientStation string `mapstructure:"client-station"`
+
+	// SmartLink
+	SmartLinkToken    string        `mapstructure:"smartlink-token"`
+	SmartLinkServer   string        `mapstructure:"smartlink-server"`
+	SmartLinkInterval time.Duration `mapstructure:"smartlink-interval"`
+
 	// WebRTC / ICE
 	ICEPortStart uint16 `mapstructure:"ice-port-start"`
 	ICEPortEnd   uint16 `mapstructure:"ice-port-end"`
 	StunURLs     []string `mapstructure:"stun"`
 	NAT1To1IPs   []string `mapstructure:"nat-1to1-ips"`
 
+	// Fallbacks for networks that block UDP: passive ICE-TCP on this port
+	// (0 = off), and a TURN relay, e.g. TURN over TLS on 443
+	ICETCPPort     uint16   `mapstructure:"ice-tcp-port"`
+	TURNURLs       []string `mapstructure:"turn"`
+	TURNUsername   string   `mapstructure:"turn-username"`
+	TURNCredential string   `mapstructure:"turn-credential"`
+	EnableUPnP   bool     `mapstructure:"enable-upnp"`
+
+	// DSCP code points for QoS: audio (the ICE and radio UDP sockets) and
+	// data (the HTTP and WebSocket connections); 0 leaves them unmarked
+	DSCPMedia int `mapstructure:"dscp-media"`
+	DSCPData  int `mapstructure:"dscp-data"`
+
 	// Diagnostics
 	APILogFile string `mapstructure:"api-log-file"`
+	Verbose    bool   `mapstructure:"verbose"`
+	AdminToken string `mapstructure:"admin-token"`
+	// Timezone is an IANA name ("Local" honours TZ) used when rendering
+	// timestamps in logs and API responses; Location is its resolved form.
+	Timezone string         `mapstructure:"timezone"`
+	Location *time.Location `mapstructure:"-"`
+
+	// Hamlib rigctld emulation
+	RigctlListen string   `mapstructure:"rigctl-listen"`
+	RigctlSlices []string `mapstructure:"rigctl-slices"`
+
+	// Kenwood TS-2000 CAT emulation
+	CATListen string `mapstructure:"cat-listen"`
+
+	// WSJT-X UDP bridge
+	WSJTXListen string `mapstructure:"wsjtx-listen"`
+
+	// gRPC API
+	GRPCListen string `mapstructure:"grpc-listen"`
+
+	// Local audio monitor: play a radio's RX audio on this machine
+	MonitorRadio     string  `mapstructure:"monitor-radio"`
+	MonitorDevice    string  `mapstructure:"monitor-device"`
+	MonitorFrequency float64 `mapstructure:"monitor-frequency"`
+	MonitorMode      string  `mapstructure:"monitor-mode"`
+	MonitorStation   string  `mapstructure:"monitor-station"`
+	MonitorFFmpeg    string  `mapstructure:"monitor-ffmpeg"`
+	MonitorMic       string  `mapstructure:"monitor-mic"`
+
+	// Network antenna switches (4O3A Antenna Genius protocol)
+	AntennaSwitches []string      `mapstructure:"antenna-switches"`
+	AntennaDiscover bool          `mapstructure:"antenna-discover"`
+	AntennaPoll     time.Duration `mapstructure:"antenna-poll"`
+	// Band-follow rules (config file only)
+	AntennaRules []AntennaRule `mapstructure:"antenna-rules"`
+
+	// Logging software (N1MM RadioInfo out, ADIF in)
+	LogbookBroadcast []string `mapstructure:"logbook-broadcast"`
+	LogbookListen    string   `mapstructure:"logbook-listen"`
+	LogbookFile      string   `mapstructure:"logbook-file"`
+	LogbookForward   []string `mapstructure:"logbook-forward"`
+	LogbookStation   string   `mapstructure:"logbook-station"`
+
+	// Spot feeds (RBN, CW Skimmer)
+	SpotFeeds    []string      `mapstructure:"spot-feeds"`
+	SpotCallsign string        `mapstructure:"spot-callsign"`
+	SpotMaxAge   time.Duration `mapstructure:"spot-max-age"`
+
+	// Activity history (SQLite)
+	HistoryDB        string        `mapstructure:"history-db"`
+	HistoryRetention time.Duration `mapstructure:"history-retention"`
+
+	// Audit trail of commands sent to radios (JSONL or SQLite)
+	AuditLog        string `mapstructure:"audit-log"`
+	AuditUserHeader string `mapstructure:"audit-user-header"`
+
+	// Helper backends served same-origin (config file only)
+	Proxies []ProxyRoute `mapstructure:"proxies"`
+
+	// Meter alert thresholds (config file only)
+	MeterAlerts []MeterAlert `mapstructure:"meter-alerts"`
+
+	// Owner notifications (config file only)
+	Alerts AlertsConfig `mapstructure:"alerts"`
+
+	// Scheduled actions (config file only)
+	Schedule ScheduleConfig `mapstructure:"schedule"`
+
+	// CW keying
+	CWBuffer time.Duration `mapstructure:"cw-buffer"`
+
+	// Remote PTT watchdog
+	PTTKeepalive time.Duration `mapstructure:"ptt-keepalive"`
+	PTTMaxTX     time.Duration `mapstructure:"ptt-max-tx"`
+
+	// TX token arbitration among a radio's sessions
+	TXToken string `mapstructure:"tx-token"`
+
+	// Spectator links: HMAC key; empty = a random key per run
+	SpectatorSecret string `mapstructure:"spectator-secret"`
+
+	// Radio link health
+	RadioTimeout time.Duration `mapstructure:"radio-timeout"`
+
+	// RX audio loss protection
+	AudioRedundancy string `mapstructure:"audio-redundancy"`
+
+	// Bandwidth budget: streams reduced, in order, on a congested link
+	BandwidthShed []string `mapstructure:"bandwidth-shed"`
+
+	// Radio UDP receive pipeline
+	UDPWorkers int `mapstructure:"udp-workers"`
+	UDPQueue   int `mapstructure:"udp-queue"`
+	UDPRcvBuf  int `mapstructure:"udp-rcvbuf"`
+
+	// Radio lines batched into fewer "tcp" data channel messages; 0 = off
+	LineBatch      time.Duration `mapstructure:"line-batch"`
+	LineBatchBytes int           `mapstructure:"line-batch-bytes"`
+
+	// How the data channels the bridge opens for each stream deliver:
+	// reliable, unordered, lossy, or udp to stay on the client's channel
+	ChannelFFT       string `mapstructure:"channel-fft"`
+	ChannelWaterfall string `mapstructure:"channel-waterfall"`
+	ChannelMeters    string `mapstructure:"channel-meters"`
+	ChannelIQ        string `mapstructure:"channel-iq"`
+
+	// Shared radio UDP ports; zero gives each session its own socket
+	UDPPortStart uint16 `mapstructure:"udp-port-start"`
+	UDPPortEnd   uint16 `mapstructure:"udp-port-end"`
+
+	// Captions (speech-to-text)
+	CaptionsURL      string        `mapstructure:"captions-url"`
+	CaptionsModel    string        `mapstructure:"captions-model"`
+	CaptionsAPIKey   string        `mapstructure:"captions-api-key"`
+	CaptionsLanguage string        `mapstructure:"captions-language"`
+	CaptionsChunk    time.Duration `mapstructure:"captions-chunk"`
 
 	// Server defaults
 	DefaultsFile string `mapstructure:"defaults-file"`
 
+	// Command guard (config file only)
+	Guard GuardConfig `mapstructure:"guard"`
+
+	// Recording storage (config file only)
+	Storage StorageConfig `mapstructure:"storage"`
+
+	// Browser TX audio processing (config file only)
+	TXAudio TXAudioConfig `mapstructure:"tx-audio"`
+
+	// Outbound data fairness between sessions on one radio (config file only)
+	Fairness FairnessConfig `mapstructure:"fairness"`
+
+	// Public status page (config file only)
+	Status StatusConfig `mapstructure:"status"`
+
 	// Config file path (optional)
 	ConfigFile string `mapstructure:"-"`
 }
 
-func defaultAPILogPath() string {
-	_, err := os.Stat(filepath.Join("apps", "server"))
-	if err == nil {
-		return filepath.Join("apps", "server", "messages.txt")
-	}
+// CORSRoute overrides the CORS policy for paths under Prefix. Unset fields
+// inherit the top-level cors-* values.
+type CORSRoute struct {
+	Prefix           string   `mapstructure:"prefix"`
+	Origins          []string `mapstructure:"origins"`
+	AllowCredentials *bool    `mapstructure:"allow-credentials"`
+	AllowHeaders     []string `mapstructure:"allow-headers"`
+	ExposeHeaders    []string `mapstructure:"expose-headers"`
+}
+
+// ProxyRoute serves the helper backend at Upstream under Prefix, so clients
+// reach it from the bridge's own origin.
+type ProxyRoute struct {
+	Prefix   string            `mapstructure:"prefix"`   // e.g. /proxy/tiles/
+	Upstream string            `mapstructure:"upstream"` // http(s) base URL
+	Allow    []string          `mapstructure:"allow"`    // path patterns under Prefix; empty = all
+	Methods  []string          `mapstructure:"methods"`  // empty = GET, HEAD
+	Headers  map[string]string `mapstructure:"headers"`  // set on upstream requests
+}
+
+// MeterAlert raises an event while a radio meter, by name (e.g. SWR, PATEMP,
+// +13.8A), reads over Above or under Below; exactly one must be set.
+type MeterAlert struct {
+	Meter string   `mapstructure:"meter"`
+	Above *float64 `mapstructure:"above"`
+	Below *float64 `mapstructure:"below"`
+	TX    bool     `mapstructure:"tx"` // only while transmitting
+}
 
-	return "messages.txt"
+// AlertsConfig notifies the station's owner of trouble. Every rule's alerts
+// go to every configured channel.
+type AlertsConfig struct {
+	Rules     []AlertRule    `mapstructure:"rules"`
+	Cooldown  time.Duration  `mapstructure:"cooldown"`   // same alert at most this often; 0 = 15m
+	Station   string         `mapstructure:"station"`    // names the bridge; empty = host name
+	Webhooks  []string       `mapstructure:"webhooks"`   // URLs alerts are POSTed to as JSON
+	Ntfy      []string       `mapstructure:"ntfy"`       // topic URLs, e.g. https://ntfy.sh/my-station
+	NtfyToken string         `mapstructure:"ntfy-token"` // access token for the topics
+	Pushover  PushoverConfig `mapstructure:"pushover"`
+	Email     EmailConfig    `mapstructure:"email"`
 }
 
+// AlertRule picks what is alerted on: radio-lost, meter, long-tx or restart.
+type AlertRule struct {
+	Kind  string        `mapstructure:"kind"`
+	Radio string        `mapstructure:"radio"` // serial, nickname or host[:port]; empty = any
+	Meter string        `mapstructure:"meter"` // meter: one meter alert, e.g. PATEMP; empty = any
+	After time.Duration `mapstructure:"after"` // long-tx: how long a transmission may last
+}
+
+type PushoverConfig struct {
+	Token   string `mapstructure:"token"`    // application token
+	UserKey string `mapstructure:"user-key"` // user or group key
+}
+
+type EmailConfig struct {
+	SMTP     string   `mapstructure:"smtp"` // host:port, e.g. smtp.example.org:587
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+// ScheduleConfig runs actions on radios at the times of cron expressions,
+// read in the display timezone.
+type ScheduleConfig struct {
+	Jobs      []ScheduledJob `mapstructure:"jobs"`
+	Broadcast string         `mapstructure:"broadcast"` // wake-on-LAN target; empty = wake-broadcast
+}
+
+// ScheduledJob is one action and when it runs.
+type ScheduledJob struct {
+	Name     string        `mapstructure:"name"`
+	Cron     string        `mapstructure:"cron"`     // e.g. "0 22 * * *" or @daily
+	Action   string        `mapstructure:"action"`   // power-on | power-off | profile | record | mute-tx | unmute-tx
+	Radio    string        `mapstructure:"radio"`    // host[:port]; empty = every radio for mute-tx and unmute-tx
+	Profile  string        `mapstructure:"profile"`  // profile: global profile to load
+	Duration time.Duration `mapstructure:"duration"` // record: how long; mute-tx: unmute after; 0 = until stopped
+	Format   string        `mapstructure:"format"`   // record: ogg (default) | wav
+	MAC      string        `mapstructure:"mac"`      // power-on: wake-on-LAN target
+	URL      string        `mapstructure:"url"`      // power-on, power-off: POSTed to, e.g. a remote-on relay
+	Disabled bool          `mapstructure:"disabled"`
+}
+
+// GuardConfig restricts dangerous radio commands on shared stations.
+type GuardConfig struct {
+	// AuditFile receives guarded commands and timestamped interlock
+	// transitions as JSON lines. It may be set without any rules.
+	AuditFile string              `mapstructure:"audit-file"`
+	Roles     map[string][]string `mapstructure:"roles"` // role -> client CIDRs
+	Rules     []GuardRule         `mapstructure:"rules"`
+}
+
+// GuardRule blocks or requires confirmation for one category of command.
+type GuardRule struct {
+	Category string   `mapstructure:"category"` // tx | power | atu-bypass | profile-write
+	Action   string   `mapstructure:"action"`   // allow | block | confirm
+	Roles    []string `mapstructure:"roles"`
+	Radios   []string `mapstructure:"radios"`
+	MaxPower int      `mapstructure:"max-power"`
+}
+
+// AntennaRule switches an antenna switch port to an antenna while a radio's
+// active slice is between From and To MHz.
+type AntennaRule struct {
+	Switch  string  `mapstructure:"switch"` // address or name; empty = every switch
+	Radio   string  `mapstructure:"radio"`  // host:port; empty = any radio
+	Port    int     `mapstructure:"port"`
+	From    float64 `mapstructure:"from"`
+	To      float64 `mapstructure:"to"`
+	Antenna int     `mapstructure:"antenna"`
+}
+
+// FairnessConfig shares each radio's panadapter, waterfall and other UDP data
+// among the sessions watching it. Roles come from guard.roles.
+type FairnessConfig struct {
+	RateKbps int            `mapstructure:"rate-kbps"` // per radio; 0 = off
+	Priority []string       `mapstructure:"priority"`  // roles never throttled
+	Weights  map[string]int `mapstructure:"weights"`   // role -> share weight, default 1
+}
+
+// StatusConfig publishes a read-only station status page. Only whether the
+// station is online is shown unless the fields below opt in to more.
+type StatusConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Title        string   `mapstructure:"title"`
+	EmbedOrigins []string `mapstructure:"embed-origins"` // sites allowed to frame the page; "*" for any
+	Callsign     bool     `mapstructure:"callsign"`
+	Model        bool     `mapstructure:"model"`
+	Band         bool     `mapstructure:"band"`
+	Mode         bool     `mapstructure:"mode"`
+	Frequency    bool     `mapstructure:"frequency"` // exact frequency; implies band
+	Listeners    bool     `mapstructure:"listeners"`
+	Transmitting bool     `mapstructure:"transmitting"`
+}
+
+// StorageConfig selects where recordings are written.
+type StorageConfig struct {
+	Backend string   `mapstructure:"backend"` // local (default) | s3
+	Dir     string   `mapstructure:"dir"`     // local directory, or the spill directory for s3
+	S3      S3Config `mapstructure:"s3"`
+	FFmpeg  string   `mapstructure:"ffmpeg"` // decodes WAV recordings; default ffmpeg on PATH
+}
+
+// TXAudioConfig is the processing chain browser TX audio runs through, by
+// profile.
+type TXAudioConfig struct {
+	FFmpeg   string                    `mapstructure:"ffmpeg"`  // default ffmpeg on PATH
+	Default  string                    `mapstructure:"default"` // profile for users not listed
+	Users    map[string]string         `mapstructure:"users"`   // user -> profile
+	Profiles map[string]TXAudioProfile `mapstructure:"profiles"`
+}
+
+// TXAudioProfile is one processing chain; zero values leave a stage out.
+type TXAudioProfile struct {
+	Gain       float64           `mapstructure:"gain"` // input gain, dB
+	EQ         []float64         `mapstructure:"eq"`   // dB at 63, 125, 250, 500, 1k, 2k, 4k and 8k Hz
+	Compressor TXAudioCompressor `mapstructure:"compressor"`
+	Limit      float64           `mapstructure:"limit"` // limiter ceiling, dBFS
+}
+
+type TXAudioCompressor struct {
+	Threshold float64       `mapstructure:"threshold"` // dBFS
+	Ratio     float64       `mapstructure:"ratio"`
+	Attack    time.Duration `mapstructure:"attack"`
+	Release   time.Duration `mapstructure:"release"`
+	Makeup    float64       `mapstructure:"makeup"` // dB
+}
+
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	Prefix    string `mapstructure:"prefix"`
+	AccessKey string `mapstructure:"access-key"`
+	SecretKey string `mapstructure:"secret-key"`
+	PathStyle bool   `mapstructure:"path-style"`
+}
+
+// Load reads the configuration from flags, FLEX_ environment variables and
+// the config file, in that order of precedence, and validates it.
 func Load() (Config, error) {
+	cfg, _, err := load()
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, errors.Join(validate(&cfg)...)
+}
+
+// load reads the configuration without validating it, returning the viper
+// instance that knows where each setting came from.
+func load() (Config, *viper.Viper, error) {
 	var cfg Config
 
 	fs := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
@@ -56,10 +458,53 @@ func Load() (Config, error) {
 
 	// Flags (with sensible defaults)
 	fs.IntP("http-port", "p", 8080, "HTTP port to listen on")
-	fs.String("static-dir", "", "Path to serve built UI (optional)")
+	fs.String("static-dir", "", "Serve web UI from this directory instead of the embedded copy")
 	fs.Bool("enable-coi", true, "Enable Cross-Origin-Isolation headers (COOP/COEP)")
-	fs.Bool("enable-cors", true, "Enable permissive CORS headers")
+	fs.String("tls-cert", "", "TLS certificate file (enables HTTPS together with --tls-key)")
+	fs.String("tls-key", "", "TLS private key file")
+	fs.Bool("enable-http2", true, "Serve HTTP/2 over TLS")
+	fs.Bool("enable-h2c", false, "Serve unencrypted HTTP/2 (prior knowledge) when TLS is off")
+	fs.Bool("enable-http3", false, "Serve HTTP/3 over QUIC (requires TLS)")
+	fs.Int("http3-port", 0, "UDP port for HTTP/3 (0 = same as --http-port)")
+	fs.Bool("enable-webtransport", false, "Serve radio sessions over WebTransport at /wt (requires --enable-http3)")
+	fs.Bool("enable-cors", true, "Enable CORS headers")
+	fs.StringSlice("cors-origins", []string{"*"}, "Allowed CORS origins (exact, https://*.example.com, or *)")
+	fs.Bool("cors-allow-credentials", false, "Allow credentialed CORS requests (cookies, auth headers); needs cors-origins other than *")
+	fs.StringSlice("cors-allow-headers", []string{"*"}, "Request headers allowed on CORS requests")
+	fs.StringSlice("cors-expose-headers", nil, "Response headers exposed to CORS requests")
+	fs.Duration("cors-max-age", 0, "How long browsers may cache preflight results (0 = browser default)")
+	fs.StringSlice("ws-origins", nil,
+		"Extra origins allowed to open WebSockets (default: same-origin, plus cors-origins when CORS is enabled)")
+	fs.Int("ws-deflate-min", 0,
+		"Compress WebSocket status and text messages of at least this many bytes with permessage-deflate (0 = off)")
+	fs.StringSlice("allow-cidrs", nil, "Only accept connections from these CIDRs/IPs (default: all)")
+	fs.StringSlice("deny-cidrs", nil, "Reject connections from these CIDRs/IPs (takes precedence over allow)")
+	fs.StringSlice("allowed-radios", nil, "Radios (host or host:port) clients may connect to (default: any)")
+	fs.Int("max-sessions", 0, "Maximum concurrent client sessions (0 = unlimited)")
+	fs.String("preflight", "off", "Check ?radio= before accepting signaling: off, registry, or tcp")
+	fs.Duration("drain-timeout", 10*time.Second, "How long to wait for clients to leave on shutdown before disconnecting them")
+	fs.String("drain-peer", "", "Base URL of a peer bridge clients are told to reconnect to on shutdown, if it is ready")
+	fs.String("session-state", "", "Save sessions to this file so clients can resume them after a restart (empty = off)")
+	fs.Duration("resume-window", 2*time.Minute, "How long a restarted bridge holds a saved session's radio for its client")
+	fs.Bool("stale-cleanup", false, "On startup, remove the streams and client handles the sessions in session-state left on their radios")
+	fs.String("client-program", "solid-sdr", "Program name sessions register with the radio as")
+	fs.String("client-version", "", "Version sent after the program name (default: the bridge's version)")
+	fs.String("client-station", "", "Station name sessions register with the radio as (default: the one each client sends)")
 	fs.Int("discovery-port", 4992, "UDP discovery port")
+	fs.String("discovery-slow-consumer", "drop-oldest", "What to do with a discovery subscriber whose buffer is full: drop-oldest or disconnect")
+	fs.Int("discovery-max-buffer", 4096, "Most discovery packets queued for one subscriber")
+	fs.Duration("discovery-dedup-window", 500*time.Millisecond, "Drop a discovery beacon identical to the radio's last one heard this recently (0 = keep every copy)")
+	fs.String("discovery-relay-peer", "", "Base URL of another bridge whose discovery beacons are broadcast on this network")
+	fs.String("discovery-relay-token", "", "Shared token for the discovery relay: sent to the peer, and required of bridges relaying from this one")
+	fs.String("discovery-relay-broadcast", "", "Address relayed beacons are broadcast to (default 255.255.255.255 on the discovery port)")
+	fs.String("wake-broadcast", "255.255.255.255:9", "Address wake-on-LAN packets that power on radios are sent to")
+	fs.String("federation-hub", "", "Base URL of a hub bridge to serve this bridge's LAN radios through")
+	fs.String("federation-token", "", "Federation token: sent to --federation-hub, and required of edge bridges connecting to this one")
+	fs.String("federation-name", "", "Name this bridge gives the hub (default the host name)")
+
+	fs.String("smartlink-token", "", "SmartLink account token; lists the account's radios alongside LAN discovery")
+	fs.String("smartlink-server", "smartlink.flexradio.com:443", "SmartLink server address")
+	fs.Duration("smartlink-interval", time.Minute, "How often to refresh the SmartLink radio list")
 
 	fs.Int("ice-port-start", 50313, "Lowest UDP port for ICE (inclusive)")
 	fs.Int("ice-port-end", 50313, "Highest UDP port for ICE (inclusive); set equal to start for single-port UDP mux")
@@ -68,7 +513,69 @@ func Load() (Config, error) {
 		"stun:stun.cloudflare.com:3478",
 	}, "Comma-separated STUN URLs")
 	fs.StringSlice("nat-1to1-ips", nil, "Optional public IPs for NAT 1:1 mapping (e.g. 203.0.113.2,2001:db8::2)")
-	fs.String("api-log-file", defaultAPILogPath(), "Path to write raw TCP API messages (set empty to disable)")
+	fs.Int("ice-tcp-port", 0, "TCP port for passive ICE-TCP candidates, for networks that block UDP (0 = off)")
+	fs.StringSlice("turn", nil, "TURN relay URLs, e.g. turns:turn.example.com:443?transport=tcp (empty = none)")
+	fs.String("turn-username", "", "Username for the TURN relay")
+	fs.String("turn-credential", "", "Credential (password) for the TURN relay")
+	fs.Bool("enable-upnp", false, "Map the ICE and HTTP ports on the gateway via UPnP/NAT-PMP and advertise its public IP")
+	fs.Int("dscp-media", 46, "DSCP code point for audio: the ICE sockets and the radio UDP sockets (46 = EF, 0 = unmarked)")
+	fs.Int("dscp-data", 18, "DSCP code point for HTTP and WebSocket connections (18 = AF21, 0 = unmarked)")
+	fs.String("api-log-file", "", "Path to write raw TCP API messages to (empty = off); the file is not rotated")
+	fs.Bool("verbose", false, "Log every client command (toggle at runtime via the admin API)")
+	fs.String("admin-token", "", "Bearer token for /api/admin (empty = same-origin loopback clients only)")
+	fs.String("timezone", "Local", "Display timezone for log lines, audit entries and API timestamps (IANA name, e.g. America/New_York)")
+	fs.String("captions-url", "", "OpenAI-compatible transcription endpoint for slice captions (empty = captions off)")
+	fs.String("captions-model", "whisper-1", "Speech-to-text model name sent to --captions-url")
+	fs.String("captions-api-key", "", "Bearer token for --captions-url")
+	fs.String("captions-language", "", "Language hint for captions (ISO-639-1, e.g. en)")
+	fs.Duration("captions-chunk", 10*time.Second, "Audio sent per transcription request; shorter is faster, longer is more accurate")
+	fs.Duration("cw-buffer", 50*time.Millisecond, "Delay applied to \"cw\" data channel key events to even out network jitter")
+	fs.Duration("ptt-keepalive", 2*time.Second, "Release a client's PTT when it has not been repeated for this long")
+	fs.Duration("ptt-max-tx", 3*time.Minute, "Release a client's PTT after this long in any case")
+	fs.String("tx-token", "off", "How sessions on one radio share the right to key it: off, grant or steal")
+	fs.String("spectator-secret", "", "Key that signs read-only spectator links (empty = random, so links end when the bridge restarts)")
+	fs.Duration("radio-timeout", 10*time.Second, "Report a radio's link unhealthy when it has not answered pings for this long")
+	fs.String("audio-redundancy", "auto", "Send RX audio with RFC 2198 redundancy to browsers that support it: auto (while they report loss), on, or off")
+	fs.StringSlice("bandwidth-shed", []string{"waterfall", "fft", "iq"},
+		"Streams (waterfall, fft, iq) thinned, in this order, while a session's link is congested; off to never thin them")
+	fs.Int("udp-workers", 2, "Goroutines parsing and dispatching each session's radio UDP")
+	fs.Int("udp-queue", 1024, "Radio UDP packets each session queues for its workers before dropping")
+	fs.Int("udp-rcvbuf", 4<<20, "Receive buffer in bytes for each session's radio UDP socket (0 = OS default)")
+	fs.Duration("line-batch", 0, "Longest a radio line waits to share a data channel message with others (0 = a message per line)")
+	fs.Int("line-batch-bytes", 16*1024, "Send a batch of radio lines once it holds this many bytes (at most 64 KiB)")
+	fs.String("channel-fft", "lossy", "Data channel for panadapter frames: reliable, unordered, lossy or udp (the client's channel)")
+	fs.String("channel-waterfall", "lossy", "Data channel for waterfall tiles: reliable, unordered, lossy or udp")
+	fs.String("channel-meters", "lossy", "Data channel for meters: reliable, unordered, lossy or udp")
+	fs.String("channel-iq", "reliable", "Data channel for DAX IQ: reliable, unordered, lossy or udp")
+	fs.Int("udp-port-start", 0, "Lowest of the UDP ports shared by every session's radio data (0 = a socket per session)")
+	fs.Int("udp-port-end", 0, "Highest shared radio UDP port (inclusive); 0 means --udp-port-start")
+	fs.String("rigctl-listen", "", "Serve the Hamlib rigctld protocol on this address, e.g. :4532 (empty = off)")
+	fs.StringSlice("rigctl-slices", []string{"A", "B"}, "Slice letters rigctl clients control as VFOA and VFOB")
+	fs.String("cat-listen", "", "Serve Kenwood TS-2000 CAT on this TCP address, e.g. :4533 (empty = off)")
+	fs.String("wsjtx-listen", "", "Receive WSJT-X UDP messages on this address and relay decodes to clients, e.g. 127.0.0.1:2237 (empty = off)")
+	fs.String("grpc-listen", "", "Serve the gRPC API on this address, e.g. :50051; uses --tls-cert/--tls-key when set (empty = off)")
+	fs.String("monitor-radio", "", "Play this radio's RX audio (host:port) on a local sound device, with no browser (empty = off)")
+	fs.String("monitor-device", "default", "Sound device for --monitor-radio: an ALSA PCM on Linux or a device index on macOS; Windows uses the default")
+	fs.Float64("monitor-frequency", 0, "Frequency in MHz of the slice --monitor-radio opens")
+	fs.String("monitor-mode", "USB", "Mode of the slice --monitor-radio opens, e.g. AM, LSB, USB, CW")
+	fs.String("monitor-station", "Monitor", "Station name the audio monitor registers with the radio as")
+	fs.String("monitor-ffmpeg", "ffmpeg", "ffmpeg binary that decodes and plays monitor audio (ffplay beside it on Windows)")
+	fs.String("monitor-mic", "", "Microphone the monitor transmits from: an ALSA PCM on Linux, an AVFoundation device on macOS, a DirectShow name on Windows (empty = none)")
+	fs.StringSlice("antenna-switches", nil, "Antenna Genius switches to connect to (host or host:port)")
+	fs.Bool("antenna-discover", false, "Find Antenna Genius switches by their broadcasts and connect to each")
+	fs.Duration("antenna-poll", 10*time.Second, "How often antenna switch ports are read again")
+	fs.StringSlice("logbook-broadcast", nil, "Send N1MM RadioInfo packets to these UDP addresses, e.g. 127.0.0.1:12060")
+	fs.String("logbook-listen", "", "Take ADIF records on this UDP and TCP address, e.g. :2333 (empty = off)")
+	fs.String("logbook-file", "", "Append logged QSOs to this ADIF file (empty = off)")
+	fs.StringSlice("logbook-forward", nil, "Pass logged QSOs on to these loggers, as UDP host:port or tcp://host:port")
+	fs.String("logbook-station", "", "Station name in RadioInfo packets (default: the host name)")
+	fs.StringSlice("spot-feeds", nil, "Follow these RBN or CW Skimmer telnet feeds, e.g. telnet.reversebeacon.net:7000")
+	fs.String("spot-callsign", "", "Callsign to log in to spot feeds with")
+	fs.Duration("spot-max-age", 10*time.Minute, "How long a spot is kept")
+	fs.String("history-db", "", "Record slice tuning and TX activity in this SQLite database (empty = off)")
+	fs.Duration("history-retention", 90*24*time.Hour, "How long activity history is kept")
+	fs.String("audit-log", "", "Record every command sent to a radio, with who sent it, in this file: SQLite if it ends in .db, else JSONL (empty = off)")
+	fs.String("audit-user-header", "", "Request header in which an authenticating proxy names the user, e.g. Remote-User (empty = trust none)")
 	fs.String("defaults-file", "", "Path to JSON file served as server defaults (optional)")
 	fs.String("config", "", "Path to optional config file")
 
@@ -77,7 +584,8 @@ func Load() (Config, error) {
 		fmt.Fprintf(os.Stderr, `solid-sdr-server
 
 Usage:
-  %s [flags]
+  %[1]s [flags]
+  %[1]s discovery-only [flags]   run only the discovery relay and radio feeds
 
 Flags:
   -V, --version         Print version and exit
@@ -93,6 +601,89 @@ Environment:
 Config file:
   Set FLEX_CONFIG=/path/to/file.(yaml|json|toml)
   Or place solid-sdr-server.yaml/json/toml in current directory
+
+  Per-route CORS overrides (file only), e.g.:
+    cors-routes:
+      - { prefix: /api/, origins: ["https://club.example.org"], allow-credentials: true }
+
+  Command guard (file only), e.g.:
+    guard:
+      audit-file: guard-audit.jsonl
+      roles:
+        guest: ["10.8.0.0/24"]
+      rules:
+        - { category: tx, action: confirm, roles: [guest] }
+        - { category: power, action: block, max-power: 50 }
+
+  Recording storage (file only), e.g.:
+    storage:
+      backend: s3            # or local (default)
+      dir: /var/spool/solid-sdr
+      s3: { endpoint: "http://minio.lan:9000", bucket: recordings, path-style: true,
+            access-key: ..., secret-key: ... }
+      ffmpeg: /usr/bin/ffmpeg  # for WAV recordings
+
+  Browser TX audio processing, run by ffmpeg (file only), e.g.:
+    tx-audio:
+      default: voice
+      users: { alice: hot-mic }
+      profiles:
+        voice: { gain: 6, eq: [-6, -3, 0, 0, 2, 3, 2, -6], limit: -1,
+                 compressor: { threshold: -20, ratio: 3, attack: 5ms, release: 100ms } }
+        hot-mic: { gain: -10, limit: -1 }
+
+  Antenna switch band-follow rules (file only), e.g.:
+    antenna-rules:
+      - { port: 1, from: 14.0, to: 14.35, antenna: 2 }
+      - { switch: 192.168.1.39:9007, port: 1, from: 7.0, to: 7.3, antenna: 1 }
+
+  Helper backends served same-origin (file only), e.g.:
+    proxies:
+      - prefix: /proxy/tiles/
+        upstream: https://tile.openstreetmap.org/
+        allow: ["/*/*/*.png"]
+      - { prefix: /proxy/qrz/, upstream: "https://xmldata.qrz.com/xml/current/" }
+
+  Meter alerts (file only), e.g.:
+    meter-alerts:
+      - { meter: SWR, above: 3, tx: true }
+      - { meter: PATEMP, above: 70 }
+      - { meter: +13.8A, below: 12.5 }
+
+  Owner notifications (file only), e.g.:
+    alerts:
+      rules:
+        - { kind: radio-lost }
+        - { kind: meter, meter: PATEMP }   # thresholds come from meter-alerts
+        - { kind: long-tx, after: 10m }
+        - { kind: restart }
+      ntfy: ["https://ntfy.sh/my-station"]
+      pushover: { token: ..., user-key: ... }
+      email: { smtp: "smtp.example.org:587", username: ..., password: ...,
+               from: bridge@example.org, to: [me@example.org] }
+
+  Radios' MAC addresses for remote power-on, when not learned on the LAN
+  (file only), e.g.:
+    radio-macs:
+      "1225-1213-8600-7918": "00:1c:2d:05:37:2a"
+
+  Scheduled actions (file only; times in the display timezone), e.g.:
+    schedule:
+      broadcast: 192.168.1.255:9
+      jobs:
+        - { name: quiet-hours, cron: "0 22 * * *", action: mute-tx }
+        - { name: morning, cron: "0 7 * * *", action: unmute-tx }
+        - { name: contest, cron: "0 0 * * sat", action: profile, radio: 192.168.1.20, profile: Contest }
+        - { name: net, cron: "0 19 * * tue", action: record, radio: 192.168.1.20, duration: 1h }
+        - { name: wake, cron: "30 6 * * *", action: power-on, mac: "00:1c:2d:05:37:2a" }
+
+  Public status page at /status and /api/status (file only), e.g.:
+    status:
+      enabled: true
+      title: W1AW club station
+      embed-origins: ["https://club.example.org"]
+      band: true
+      listeners: true
 `)
 	}
 	fs.Usage = usage
@@ -138,17 +729,37 @@ Config file:
 	// Unmarshal into your struct
 	err = v.Unmarshal(&cfg)
 	if err != nil {
-		return cfg, fmt.Errorf("unmarshal: %w", err)
+		return cfg, v, fmt.Errorf("unmarshal: %w", err)
 	}
 
 	cfg.ConfigFile = v.ConfigFileUsed()
 	log.Printf("[config] http=:%d static=%q ice=%d..%d api-log=%q defaults=%q file=%q\n",
 		cfg.HTTPPort, cfg.StaticDir, cfg.ICEPortStart, cfg.ICEPortEnd, cfg.APILogFile, cfg.DefaultsFile, cfg.ConfigFile)
 
-	// Sanity checks
-	if cfg.ICEPortEnd < cfg.ICEPortStart {
-		return cfg, fmt.Errorf("%w: %d–%d", errInvalidICEPortRange, cfg.ICEPortStart, cfg.ICEPortEnd)
+	return cfg, v, nil
+}
+
+// bandwidthShed checks a bandwidth-shed list, returning nil for "off".
+func bandwidthShed(shed []string) ([]string, error) {
+	if len(shed) == 1 && shed[0] == "off" {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+
+	for _, s := range shed {
+		switch s {
+		case "waterfall", "fft", "iq":
+		default:
+			return nil, fmt.Errorf("%w: %q", errInvalidBandwidth, s)
+		}
+
+		if seen[s] {
+			return nil, fmt.Errorf("%w: %q listed twice", errInvalidBandwidth, s)
+		}
+
+		seen[s] = true
 	}
 
-	return cfg, nil
+	return shed, nil
 }
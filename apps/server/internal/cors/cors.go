@@ -0,0 +1,169 @@
+// Package cors implements a configurable Cross-Origin Resource Sharing policy.
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultMethods = []string{ //nolint:gochecknoglobals
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// Policy describes which cross-origin requests are permitted.
+type Policy struct {
+	// AllowedOrigins lists exact origins ("https://example.com"), single-label
+	// wildcards ("https://*.example.com"), or "*" for any origin, which never
+	// gets AllowCredentials.
+	AllowedOrigins   []string
+	AllowCredentials bool
+	AllowedHeaders   []string // "*" reflects whatever the preflight asks for
+	ExposedHeaders   []string
+	AllowedMethods   []string // defaults to the common REST verbs
+	MaxAge           time.Duration
+}
+
+// Route overrides the default policy for requests under Prefix.
+type Route struct {
+	Prefix string
+	Policy Policy
+}
+
+// Handler wraps next with CORS handling. The route with the longest matching
+// prefix wins; requests matching no route use def.
+func Handler(def Policy, routes []Route, next http.Handler) http.Handler {
+	sorted := append([]Route(nil), routes...)
+	slices.SortFunc(sorted, func(a, b Route) int { return len(b.Prefix) - len(a.Prefix) })
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := def
+
+		for _, rt := range sorted {
+			if strings.HasPrefix(r.URL.Path, rt.Prefix) {
+				p = rt.Policy
+
+				break
+			}
+		}
+
+		if p.apply(w, r) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apply writes CORS headers for r and reports whether the request was a
+// preflight that has been fully answered.
+func (p Policy) apply(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	if origin == "" {
+		return false
+	}
+
+	h := w.Header()
+	h.Add("Vary", "Origin")
+
+	if !p.allowsOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+
+			return true
+		}
+
+		return false
+	}
+
+	// "*" can't be combined with credentials, and echoing the origin in its
+	// place would give every site credentialed access, so "*" never allows
+	// credentials.
+	if slices.Contains(p.AllowedOrigins, "*") {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+
+		if p.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if len(p.ExposedHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+		}
+
+		return false
+	}
+
+	methods := p.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultMethods
+	}
+
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
+
+	if headers := p.allowedHeaders(r); headers != "" {
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+
+	if p.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge/time.Second)))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+func (p Policy) allowedHeaders(r *http.Request) string {
+	if !slices.Contains(p.AllowedHeaders, "*") {
+		return strings.Join(p.AllowedHeaders, ", ")
+	}
+
+	// Browsers ignore the "*" wildcard on credentialed requests, so reflect
+	// the requested headers back in that case.
+	if p.AllowCredentials {
+		return r.Header.Get("Access-Control-Request-Headers")
+	}
+
+	return "*"
+}
+
+func (p Policy) allowsOrigin(origin string) bool {
+	return MatchOrigin(p.AllowedOrigins, origin)
+}
+
+// MatchOrigin reports whether origin matches any of patterns, using the same
+// rules as Policy.AllowedOrigins.
+func MatchOrigin(patterns []string, origin string) bool {
+	for _, allowed := range patterns {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+
+		prefix, suffix, ok := strings.Cut(allowed, "*")
+		if !ok || len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+
+		if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+
+		// The wildcard covers exactly one DNS label.
+		label := origin[len(prefix) : len(origin)-len(suffix)]
+		if !strings.ContainsAny(label, "./:") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serve(h http.Handler, method, path, origin string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+
+	if method == http.MethodOptions {
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestHandler_WildcardOrigin(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(Policy{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}}, nil, http.NotFoundHandler())
+
+	w := serve(h, http.MethodGet, "/", "https://example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin: got %q want *", got)
+	}
+}
+
+func TestHandler_WildcardOriginNeverAllowsCredentials(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, nil, http.NotFoundHandler())
+
+	w := serve(h, http.MethodGet, "/", "https://evil.example")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin: got %q want *", got)
+	}
+
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("credentials allowed to any origin")
+	}
+}
+
+func TestHandler_AllowlistAndCredentials(t *testing.T) {
+	t.Parallel()
+
+	p := Policy{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowCredentials: true,
+		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+	}
+	h := Handler(p, nil, http.NotFoundHandler())
+
+	w := serve(h, http.MethodGet, "/", "https://shack.example.com")
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://shack.example.com" {
+		t.Errorf("Allow-Origin: got %q", got)
+	}
+
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("expected Allow-Credentials")
+	}
+
+	if w.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Error("expected Expose-Headers")
+	}
+
+	w = serve(h, http.MethodGet, "/", "https://a.b.example.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("wildcard must not match nested subdomains")
+	}
+
+	w = serve(h, http.MethodOptions, "/", "https://shack.example.com")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("preflight: got %d want 204", w.Code)
+	}
+
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("credentialed preflight should reflect headers, got %q", got)
+	}
+
+	w = serve(h, http.MethodOptions, "/", "https://evil.test")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("disallowed preflight: got %d want 403", w.Code)
+	}
+}
+
+func TestHandler_RouteOverride(t *testing.T) {
+	t.Parallel()
+
+	def := Policy{AllowedOrigins: []string{"*"}}
+	routes := []Route{{Prefix: "/api/", Policy: Policy{AllowedOrigins: []string{"https://club.example.org"}}}}
+	h := Handler(def, routes, http.NotFoundHandler())
+
+	w := serve(h, http.MethodGet, "/api/radios", "https://other.example.org")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("route override should reject origin allowed by default policy")
+	}
+
+	w = serve(h, http.MethodGet, "/index.html", "https://other.example.org")
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("default policy should apply outside the route prefix")
+	}
+}
@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is how long a beacon identical to the last one from the
+// same radio is taken for a copy. Radios beacon about once a second, and a
+// bridge listening on several interfaces hears each beacon on all of them
+// within a few milliseconds.
+const DefaultDedupWindow = 500 * time.Millisecond
+
+// dedup recognises repeats of a radio's last beacon.
+type dedup struct {
+	window time.Duration
+	seed   maphash.Seed
+
+	mu   sync.Mutex
+	last map[string]beaconSeen
+}
+
+type beaconSeen struct {
+	sum uint64
+	at  time.Time
+}
+
+func newDedup(window time.Duration) *dedup {
+	return &dedup{window: window, seed: maphash.MakeSeed(), last: make(map[string]beaconSeen)}
+}
+
+// repeat reports whether pkt is the same as serial's last beacon, heard
+// within the window. A beacon without a serial is never a repeat.
+func (d *dedup) repeat(serial string, pkt []byte, now time.Time) bool {
+	if d.window <= 0 || serial == "" {
+		return false
+	}
+
+	sum := maphash.Bytes(d.seed, pkt)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prev, ok := d.last[serial]
+	if ok && prev.sum == sum && now.Sub(prev.at) < d.window {
+		return true
+	}
+
+	d.last[serial] = beaconSeen{sum: sum, at: now}
+
+	return false
+}
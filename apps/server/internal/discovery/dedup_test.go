@@ -0,0 +1,36 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	t.Parallel()
+
+	d := newDedup(500 * time.Millisecond)
+	now := time.Now()
+
+	for _, c := range []struct {
+		serial, pkt string
+		after       time.Duration
+		want        bool
+	}{
+		{"A", "beacon 1", 0, false},
+		{"A", "beacon 1", 5 * time.Millisecond, true},   // the same beacon on another interface
+		{"B", "beacon 1", 5 * time.Millisecond, false},  // another radio
+		{"A", "beacon 2", 10 * time.Millisecond, false}, // a change
+		{"A", "beacon 2", time.Second, false},           // the next interval
+		{"", "no serial", 0, false},
+		{"", "no serial", 0, false},
+	} {
+		if got := d.repeat(c.serial, []byte(c.pkt), now.Add(c.after)); got != c.want {
+			t.Errorf("%s %q at %s: repeat = %v", c.serial, c.pkt, c.after, got)
+		}
+	}
+
+	off := newDedup(0)
+	if off.repeat("A", nil, now) || off.repeat("A", nil, now) {
+		t.Error("a zero window dropped a beacon")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsdeflate"
 	"github.com/gorilla/websocket"
 )
 
@@ -23,6 +24,29 @@ type Options struct {
 	IdleRestart    time.Duration // default 30s
 	HealthInterval time.Duration // default 5s
 	MaxBackoff     time.Duration // default 5s
+	// CheckOrigin validates the Origin of WebSocket upgrades. nil falls back
+	// to gorilla's same-origin check.
+	CheckOrigin func(*http.Request) bool
+	// Deflate picks the WebSocket messages compressed with
+	// permessage-deflate; the zero Policy compresses none.
+	Deflate wsdeflate.Policy
+	// SlowConsumer is SlowDropOldest (the default) or SlowDisconnect.
+	SlowConsumer string
+	// MaxBuffer is the most packets a subscriber may have queued; default
+	// DefaultMaxBuffer.
+	MaxBuffer int
+	// DedupWindow drops a beacon identical to the radio's last one heard
+	// this recently, e.g. DefaultDedupWindow; zero keeps every copy.
+	DedupWindow time.Duration
+	// RelayToken lets another bridge relay this one's beacons, by opening
+	// /ws/discovery?relay=1 with it as a bearer token. Empty refuses relays.
+	RelayToken string
+	// MACs are radios' MAC addresses by serial, for waking radios whose MAC
+	// has not been learned from the LAN.
+	MACs map[string]string
+	// WakeBroadcast is where wake-on-LAN packets are sent; empty means
+	// wol.DefaultBroadcast.
+	WakeBroadcast string
 }
 
 type Service struct {
@@ -35,8 +59,14 @@ type Service struct {
 	// lastPktUnix holds the time of the most recent packet in Unix nanos (atomic)
 	lastPktUnix atomic.Int64
 
-	subMu sync.Mutex
-	subs  map[chan []byte]struct{}
+	subMu  sync.Mutex
+	subs   map[*Subscriber]struct{}
+	totals fanoutTotals
+	dedup  *dedup
+	// relayed holds the beacons RunRelay broadcast here.
+	relayed *relayedSet
+
+	registry *Registry
 }
 
 func New(opt Options) *Service {
@@ -52,7 +82,17 @@ func New(opt Options) *Service {
 		opt.MaxBackoff = 5 * time.Second
 	}
 
-	s := &Service{opt: opt, subs: make(map[chan []byte]struct{})}
+	if opt.SlowConsumer == "" {
+		opt.SlowConsumer = SlowDropOldest
+	}
+
+	if opt.MaxBuffer <= 0 {
+		opt.MaxBuffer = DefaultMaxBuffer
+	}
+
+	s := &Service{opt: opt, subs: make(map[*Subscriber]struct{}), registry: NewRegistry()}
+	s.dedup = newDedup(opt.DedupWindow)
+	s.relayed = newRelayedSet()
 	s.lastPktUnix.Store(time.Now().UnixNano())
 
 	return s
@@ -89,45 +129,13 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 }
 
-// Subscribe returns a channel of discovery payloads for the WS handler.
-func (s *Service) Subscribe() chan []byte {
-	ch := make(chan []byte, 256)
-
-	s.subMu.Lock()
-	s.subs[ch] = struct{}{}
-	s.subMu.Unlock()
-
-	return ch
-}
-
-func (s *Service) Unsubscribe(ch chan []byte) {
-	s.subMu.Lock()
-	delete(s.subs, ch)
-	close(ch)
-	s.subMu.Unlock()
-}
-
-// WSHandler streams discovery packets to a websocket client as binary frames.
-func (s *Service) WSHandler(w http.ResponseWriter, r *http.Request) {
-	up := websocket.Upgrader{
-		CheckOrigin:       func(*http.Request) bool { return true },
-		EnableCompression: false, // disabled due to interoperability/perf issues
-	}
-
-	ws, err := up.Upgrade(w, r, nil)
-	if err != nil {
-		return
-	}
-
-	defer func() { _ = ws.Close() }()
+// readUntilGone reads from a client that never sends, so its pongs are
+// handled, and calls cancel once it has gone or stopped answering.
+func readUntilGone(ws *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
 
-	ch := s.Subscribe()
-	defer s.Unsubscribe(ch)
-
-	for pkt := range ch {
-		_ = ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-
-		err := ws.WriteMessage(websocket.BinaryMessage, pkt)
+	for {
+		_, _, err := ws.NextReader()
 		if err != nil {
 			return
 		}
@@ -226,7 +234,7 @@ func (s *Service) readLoop(ctx context.Context, pc net.PacketConn, errCh chan<-
 	for {
 		_ = pc.SetReadDeadline(time.Now().Add(10 * time.Second))
 
-		n, _, err := pc.ReadFrom(buf)
+		n, from, err := pc.ReadFrom(buf)
 
 		var ne net.Error
 		if errors.As(err, &ne) && ne.Timeout() {
@@ -240,9 +248,25 @@ func (s *Service) readLoop(ctx context.Context, pc net.PacketConn, errCh chan<-
 		}
 
 		pkt := append([]byte(nil), buf[:n]...)
+		now := time.Now()
 
-		s.lastPktUnix.Store(time.Now().UnixNano())
-		s.broadcast(pkt)
+		s.lastPktUnix.Store(now.UnixNano())
+
+		fields, perr := parsePayload(pkt)
+		if perr == nil {
+			// A text beacon may leave the radio's address to its sender.
+			if ua, ok := from.(*net.UDPAddr); ok && fields["ip"] == "" {
+				fields["ip"] = ua.IP.String()
+			}
+
+			s.registry.ObserveLAN(fields, now)
+		}
+
+		if s.dedup.repeat(fields["serial"], pkt, now) {
+			s.totals.duplicates.Add(1)
+		} else {
+			s.broadcast(pkt, fields, s.relayed.has(pkt, now))
+		}
 
 		select {
 		case <-done:
@@ -258,17 +282,6 @@ func (s *Service) readLoop(ctx context.Context, pc net.PacketConn, errCh chan<-
 	}
 }
 
-func (s *Service) broadcast(b []byte) {
-	s.subMu.Lock()
-	for ch := range s.subs {
-		select {
-		case ch <- b:
-		default:
-		}
-	}
-	s.subMu.Unlock()
-}
-
 func (s *Service) closeAll() {
 	s.mu.Lock()
 	if s.c4 != nil {
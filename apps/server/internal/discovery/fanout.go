@@ -0,0 +1,351 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/heartbeat"
+	"github.com/gorilla/websocket"
+)
+
+// What a /ws/discovery client receives.
+const (
+	// FormatRaw sends each beacon as it arrived, in a binary frame.
+	FormatRaw = "raw"
+	// FormatJSON sends each beacon's fields as a JSON object.
+	FormatJSON = "json"
+	// FormatChanges sends a RadioChange when a radio appears, changes or
+	// goes, instead of every beacon.
+	FormatChanges = "changes"
+)
+
+var (
+	errFormat   = errors.New("discovery: format must be raw, json or changes")
+	errNetwork  = errors.New("discovery: bad network")
+	errInterval = errors.New("discovery: bad interval")
+)
+
+// Filter selects radios by serial, model or network. Each list that is set
+// must match; an empty Filter takes every radio.
+type Filter struct {
+	Serials  []string       `json:"serial,omitempty"`
+	Models   []string       `json:"model,omitempty"`
+	Networks []netip.Prefix `json:"network,omitempty"`
+}
+
+// Subscription is what a /ws/discovery client asks for, in the query string
+// on connecting or as a JSON text message at any time after.
+type Subscription struct {
+	Filter
+
+	// Format is FormatRaw, FormatJSON or FormatChanges.
+	Format string `json:"format,omitempty"`
+	// Interval, e.g. "5s", sends at most one beacon per radio that often,
+	// in the raw and json formats.
+	Interval string `json:"interval,omitempty"`
+}
+
+// change is a FormatChanges frame.
+type change struct {
+	Kind  string `json:"kind"`
+	Radio Radio  `json:"radio"`
+}
+
+// ParseSubscription reads a subscription from query parameters: serial,
+// model, network and format. Lists may repeat the parameter or separate
+// values with commas.
+func ParseSubscription(q map[string][]string) (Subscription, error) {
+	sub := Subscription{
+		Filter: Filter{Serials: queryList(q["serial"]), Models: queryList(q["model"])},
+		Format: FormatRaw,
+	}
+
+	for _, n := range queryList(q["network"]) {
+		p, err := netip.ParsePrefix(n)
+		if err != nil {
+			return sub, fmt.Errorf("%w %q", errNetwork, n)
+		}
+
+		sub.Networks = append(sub.Networks, p)
+	}
+
+	if f := strings.Join(q["format"], ""); f != "" {
+		sub.Format = f
+	}
+
+	sub.Interval = strings.Join(q["interval"], "")
+
+	return sub, sub.validate()
+}
+
+func (sub Subscription) validate() error {
+	switch sub.Format {
+	case FormatRaw, FormatJSON, FormatChanges:
+	default:
+		return fmt.Errorf("%w, not %q", errFormat, sub.Format)
+	}
+
+	_, err := sub.every()
+
+	return err
+}
+
+// every parses Interval; empty is zero.
+func (sub Subscription) every() (time.Duration, error) {
+	if sub.Interval == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(sub.Interval)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w %q", errInterval, sub.Interval)
+	}
+
+	return d, nil
+}
+
+func queryList(vals []string) []string {
+	var out []string
+
+	for _, v := range vals {
+		for item := range strings.SplitSeq(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+
+	return out
+}
+
+// empty reports whether the filter takes everything.
+func (f *Filter) empty() bool {
+	return f == nil || len(f.Serials)+len(f.Models)+len(f.Networks) == 0
+}
+
+func (f *Filter) match(serial, model, host string) bool {
+	if f.empty() {
+		return true
+	}
+
+	if len(f.Serials) > 0 && !slices.Contains(f.Serials, serial) {
+		return false
+	}
+
+	if len(f.Models) > 0 && !slices.ContainsFunc(f.Models, func(m string) bool { return strings.EqualFold(m, model) }) {
+		return false
+	}
+
+	if len(f.Networks) > 0 {
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return false
+		}
+
+		addr = addr.Unmap()
+		if !slices.ContainsFunc(f.Networks, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchFields matches a beacon's fields; one that did not parse only passes
+// an empty filter.
+func (f *Filter) matchFields(fields map[string]string) bool {
+	if f.empty() {
+		return true
+	}
+
+	if fields == nil {
+		return false
+	}
+
+	return f.match(fields["serial"], fields["model"], fields["ip"])
+}
+
+func (f *Filter) matchRadio(r Radio) bool {
+	return f.match(r.Serial, r.Model, r.Host)
+}
+
+// WSHandler streams discovery to a websocket client: every beacon as a binary
+// frame, unless the client subscribed to fewer radios or another format.
+func (s *Service) WSHandler(w http.ResponseWriter, r *http.Request) {
+	sub, err := ParseSubscription(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	relay := r.URL.Query().Get("relay") != ""
+	if relay && !s.relayAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="discovery relay"`)
+		http.Error(w, "discovery relay not authorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	up := websocket.Upgrader{CheckOrigin: s.opt.CheckOrigin}
+	s.opt.Deflate.Negotiate(&up)
+
+	ws, err := up.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	heartbeat.Keep(ctx, ws)
+
+	reqs := make(chan subscribeRequest, 1)
+	go readSubscriptions(ctx, ws, reqs, cancel)
+
+	name := "ws " + r.RemoteAddr
+	if relay {
+		name = "relay " + r.RemoteAddr
+	}
+
+	feed := s.subscribe(name, relay)
+	defer feed.Close()
+
+	var (
+		changes     chan RadioChange
+		stopChanges context.CancelFunc = func() {}
+	)
+
+	defer func() { stopChanges() }()
+
+	apply := func(next Subscription) {
+		stopChanges()
+
+		changes = nil
+
+		if next.Format == FormatChanges {
+			// Start over, so the radios already listed are sent as added.
+			var watchCtx context.Context
+
+			watchCtx, stopChanges = context.WithCancel(ctx)
+			out := make(chan RadioChange, 64)
+			changes = out
+
+			go s.WatchChanges(watchCtx, func(c RadioChange) {
+				select {
+				case out <- c:
+				case <-watchCtx.Done():
+				}
+			})
+		}
+
+		sub = next
+		feed.setFilter(sub.Filter)
+
+		every, _ := sub.every()
+		feed.setInterval(every)
+	}
+
+	write := func(msgType int, b []byte) bool {
+		_ = ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+		// Raw beacons are binary and never worth compressing.
+		return s.opt.Deflate.Write(ws, msgType, b, msgType == websocket.TextMessage) == nil
+	}
+
+	writeJSON := func(v any) bool {
+		b, _ := json.Marshal(v)
+
+		return write(websocket.TextMessage, b)
+	}
+
+	apply(sub)
+
+	for {
+		ok := true
+
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-reqs:
+			next := req.sub
+			if next.Format == "" {
+				next.Format = sub.Format
+			}
+
+			err := req.err
+			if err == nil {
+				err = next.validate()
+			}
+
+			if err != nil {
+				ok = writeJSON(map[string]string{"error": err.Error()})
+			} else {
+				apply(next)
+			}
+		case c := <-changes:
+			if sub.matchRadio(c.Radio) {
+				ok = writeJSON(change{Kind: c.Kind, Radio: c.Radio})
+			}
+		case pkt, open := <-feed.C:
+			if !open {
+				return
+			}
+
+			switch sub.Format {
+			case FormatRaw:
+				ok = write(websocket.BinaryMessage, pkt)
+			case FormatJSON:
+				fields, err := parsePayload(pkt)
+				if err == nil {
+					ok = writeJSON(fields)
+				}
+			}
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+// subscribeRequest is a subscription message, or why it could not be read.
+type subscribeRequest struct {
+	sub Subscription
+	err error
+}
+
+// readSubscriptions reads subscription messages until the client goes or
+// stops answering pings, then calls cancel.
+func readSubscriptions(ctx context.Context, ws *websocket.Conn, reqs chan<- subscribeRequest, cancel context.CancelFunc) {
+	defer cancel()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req subscribeRequest
+
+		err = json.Unmarshal(data, &req.sub)
+		if err != nil {
+			req.err = fmt.Errorf("discovery: bad subscription: %w", err)
+		}
+
+		select {
+		case reqs <- req:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package discovery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseSubscription(t *testing.T) {
+	t.Parallel()
+
+	q, _ := url.ParseQuery("serial=A,B&serial=C&model=flex-6600&network=10.1.0.0/16&format=json")
+
+	sub, err := ParseSubscription(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sub.Serials) != 3 || sub.Models[0] != "flex-6600" || sub.Networks[0].String() != "10.1.0.0/16" || sub.Format != FormatJSON {
+		t.Errorf("subscription = %+v", sub)
+	}
+
+	for _, bad := range []string{"network=10.1.0.0", "format=xml"} {
+		q, _ := url.ParseQuery(bad)
+
+		_, err := ParseSubscription(q)
+		if err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	t.Parallel()
+
+	q, _ := url.ParseQuery("model=FLEX-6600&network=10.1.0.0/16")
+
+	sub, err := ParseSubscription(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		fields map[string]string
+		want   bool
+	}{
+		{map[string]string{"serial": "A", "model": "flex-6600", "ip": "10.1.2.3"}, true},
+		{map[string]string{"serial": "A", "model": "FLEX-8600", "ip": "10.1.2.3"}, false},
+		{map[string]string{"serial": "A", "model": "FLEX-6600", "ip": "10.2.2.3"}, false},
+		{nil, false},
+	} {
+		if got := sub.matchFields(c.fields); got != c.want {
+			t.Errorf("%v: match = %v", c.fields, got)
+		}
+	}
+
+	var none *Filter
+	if !none.matchFields(nil) {
+		t.Error("no filter refused a packet")
+	}
+}
+
+func TestBroadcast_Filter(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{})
+
+	all := s.Subscribe("all")
+	t.Cleanup(all.Close)
+
+	some := s.Subscribe("some")
+	t.Cleanup(some.Close)
+	some.setFilter(Filter{Serials: []string{"B"}})
+
+	s.broadcast([]byte("a"), map[string]string{"serial": "A"}, false)
+	s.broadcast([]byte("b"), map[string]string{"serial": "B"}, false)
+
+	if string(<-all.C)+string(<-all.C) != "ab" {
+		t.Error("unfiltered subscriber missed packets")
+	}
+
+	if string(<-some.C) != "b" {
+		t.Error("filtered subscriber got the wrong packets")
+	}
+}
+
+func TestWSHandler_Changes(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{})
+	s.registry.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2"}, time.Now())
+	s.registry.ObserveLAN(map[string]string{"serial": "B", "ip": "10.0.0.3"}, time.Now())
+
+	ts := httptest.NewServer(http.HandlerFunc(s.WSHandler))
+	t.Cleanup(ts.Close)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "?format=changes&serial=B"
+
+	ws, _, err := websocket.DefaultDialer.DialContext(t.Context(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ws.Close() })
+
+	var c change
+
+	err = ws.ReadJSON(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Kind != RadioAdded || c.Radio.Serial != "B" {
+		t.Errorf("first change = %+v", c)
+	}
+
+	// Subscription messages change the format; a bad one is refused.
+	err = ws.WriteJSON(Subscription{Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reply map[string]string
+
+	err = ws.WriteMessage(websocket.TextMessage, []byte(`{"format":"xml"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ws.ReadJSON(&reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(reply["error"], "xml") {
+		t.Errorf("bad format answered with %v", reply)
+	}
+
+	if code := statusOf(t, ts.URL+"?format=xml"); code != http.StatusBadRequest {
+		t.Errorf("bad query answered %d", code)
+	}
+}
+
+func statusOf(t *testing.T, url string) int {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	return resp.StatusCode
+}
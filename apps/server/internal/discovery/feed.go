@@ -0,0 +1,186 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/heartbeat"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// feedInterval is how often the radio feeds look for changes.
+	feedInterval = time.Second
+	// feedKeepAlive is the longest a feed stays silent, so proxies do not
+	// close it while nothing changes.
+	feedKeepAlive = 15 * time.Second
+)
+
+// watchRadios calls send with the radio list now and whenever it changes,
+// until ctx is done or send fails. A beacon that only refreshes LastSeen is
+// not a change; a nil list asks for a keep-alive.
+func (s *Service) watchRadios(ctx context.Context, send func([]Radio) error) error {
+	tick := time.NewTicker(feedInterval)
+	defer tick.Stop()
+
+	var (
+		last []byte
+		sent time.Time
+	)
+
+	for {
+		now := time.Now()
+		radios := s.registry.List(now)
+
+		key := radiosKey(radios)
+		switch {
+		case !bytes.Equal(key, last):
+			err := send(radios)
+			if err != nil {
+				return err
+			}
+
+			last, sent = key, now
+		case now.Sub(sent) >= feedKeepAlive:
+			err := send(nil)
+			if err != nil {
+				return err
+			}
+
+			sent = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// Kinds of RadioChange.
+const (
+	RadioAdded   = "added"
+	RadioChanged = "changed"
+	RadioRemoved = "removed"
+)
+
+// RadioChange is a radio appearing in the list, changing, or leaving it.
+type RadioChange struct {
+	Kind  string
+	Radio Radio
+}
+
+// WatchChanges calls fn for every change to the radio list, one radio at a
+// time, until ctx is done. Radios already listed are reported as added.
+func (s *Service) WatchChanges(ctx context.Context, fn func(RadioChange)) {
+	prev := make(map[string]Radio)
+
+	_ = s.watchRadios(ctx, func(radios []Radio) error {
+		if radios == nil {
+			return nil
+		}
+
+		next := make(map[string]Radio, len(radios))
+
+		for _, r := range radios {
+			next[r.Serial] = r
+
+			old, ok := prev[r.Serial]
+			switch {
+			case !ok:
+				fn(RadioChange{Kind: RadioAdded, Radio: r})
+			case !bytes.Equal(radiosKey([]Radio{old}), radiosKey([]Radio{r})):
+				fn(RadioChange{Kind: RadioChanged, Radio: r})
+			}
+		}
+
+		for serial, r := range prev {
+			if _, ok := next[serial]; !ok {
+				fn(RadioChange{Kind: RadioRemoved, Radio: r})
+			}
+		}
+
+		prev = next
+
+		return nil
+	})
+}
+
+// radiosKey is what a feed compares to decide whether the list changed.
+func radiosKey(radios []Radio) []byte {
+	cmp := make([]Radio, len(radios))
+	for i, r := range radios {
+		r.LastSeen = time.Time{}
+		cmp[i] = r
+	}
+
+	b, _ := json.Marshal(cmp)
+
+	return b
+}
+
+// EventsHandler streams the radio list as server-sent events: a "radios"
+// event carrying the whole list, sent on connect and on every change.
+func (s *Service) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	_ = s.watchRadios(r.Context(), func(radios []Radio) error {
+		var err error
+		if radios == nil {
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		} else {
+			b, _ := json.Marshal(radios)
+			_, err = fmt.Fprintf(w, "event: radios\ndata: %s\n\n", b)
+		}
+
+		if err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+
+		return rc.Flush() //nolint:wrapcheck // only ends the stream
+	})
+}
+
+// RadiosWSHandler streams the radio list to a websocket client as JSON text
+// frames, on connect and on every change.
+func (s *Service) RadiosWSHandler(w http.ResponseWriter, r *http.Request) {
+	up := websocket.Upgrader{CheckOrigin: s.opt.CheckOrigin}
+	s.opt.Deflate.Negotiate(&up)
+
+	ws, err := up.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	heartbeat.Keep(ctx, ws)
+	go readUntilGone(ws, cancel)
+
+	_ = s.watchRadios(ctx, func(radios []Radio) error {
+		_ = ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+		if radios == nil {
+			return ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)) //nolint:wrapcheck // only ends the stream
+		}
+
+		b, err := json.Marshal(radios)
+		if err != nil {
+			return fmt.Errorf("discovery: %w", err)
+		}
+
+		return s.opt.Deflate.Write(ws, websocket.TextMessage, b, true) //nolint:wrapcheck // only ends the stream
+	})
+}
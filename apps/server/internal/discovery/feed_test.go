@@ -0,0 +1,123 @@
+package discovery
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsHandler(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{})
+	s.registry.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2", "port": "4992"}, time.Now())
+
+	ts := httptest.NewServer(http.HandlerFunc(s.EventsHandler))
+	t.Cleanup(ts.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	next := func() []Radio {
+		t.Helper()
+
+		for sc.Scan() {
+			data, ok := strings.CutPrefix(sc.Text(), "data: ")
+			if !ok {
+				continue
+			}
+
+			var radios []Radio
+
+			err := json.Unmarshal([]byte(data), &radios)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return radios
+		}
+
+		t.Fatalf("stream ended: %v", sc.Err())
+
+		return nil
+	}
+
+	if radios := next(); len(radios) != 1 || radios[0].Serial != "A" {
+		t.Fatalf("first event = %+v", radios)
+	}
+
+	// A repeat beacon is not a change; a new radio is.
+	s.registry.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2", "port": "4992"}, time.Now())
+	s.registry.ObserveLAN(map[string]string{"serial": "B", "ip": "10.0.0.3", "port": "4992"}, time.Now())
+
+	if radios := next(); len(radios) != 2 || radios[1].Serial != "B" {
+		t.Fatalf("second event = %+v", radios)
+	}
+}
+
+func TestRadiosKey_IgnoresLastSeen(t *testing.T) {
+	t.Parallel()
+
+	a := []Radio{{Serial: "A", Online: true, LastSeen: time.Now()}}
+	b := []Radio{{Serial: "A", Online: true, LastSeen: time.Now().Add(time.Second)}}
+	c := []Radio{{Serial: "A", Online: false, LastSeen: time.Now()}}
+
+	if string(radiosKey(a)) != string(radiosKey(b)) {
+		t.Error("LastSeen alone changed the key")
+	}
+
+	if string(radiosKey(a)) == string(radiosKey(c)) {
+		t.Error("going offline did not change the key")
+	}
+}
+
+func TestWatchChanges(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{})
+	s.registry.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2", "port": "4992"}, time.Now())
+
+	got := make(chan RadioChange, 8)
+
+	go s.WatchChanges(t.Context(), func(c RadioChange) { got <- c })
+
+	next := func() RadioChange {
+		t.Helper()
+
+		select {
+		case c := <-got:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change")
+
+			return RadioChange{}
+		}
+	}
+
+	if c := next(); c.Kind != RadioAdded || c.Radio.Serial != "A" {
+		t.Fatalf("first change = %+v", c)
+	}
+
+	s.registry.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2", "port": "4992", "nickname": "Shack"}, time.Now())
+
+	if c := next(); c.Kind != RadioChanged || c.Radio.Nickname != "Shack" {
+		t.Fatalf("second change = %+v", c)
+	}
+}
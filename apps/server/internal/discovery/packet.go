@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	errShortPacket  = errors.New("discovery: truncated packet")
+	errNotDiscovery = errors.New("discovery: not a discovery packet")
+)
+
+// discoveryClassCode is the VITA packet class FlexRadio uses for discovery.
+const discoveryClassCode = 0xFFFF
+
+// parsePayload extracts the key=value text from a discovery beacon: a VITA
+// packet, or the plain text some firmware may send instead. Fields of the
+// legacy protocol are given their current names as well.
+func parsePayload(b []byte) (map[string]string, error) {
+	fields, err := parseVITA(b)
+	if err != nil {
+		var terr error
+
+		fields, terr = parseText(b)
+		if terr != nil {
+			return nil, err
+		}
+	}
+
+	normalize(fields)
+
+	return fields, nil
+}
+
+// parseVITA extracts the key=value text from a VITA discovery packet.
+func parseVITA(b []byte) (map[string]string, error) {
+	if len(b) < 16 {
+		return nil, errShortPacket
+	}
+
+	hasClassID := b[0]&0x08 != 0
+	hasTrailer := b[0]&0x04 != 0
+	tsi := (b[1] >> 6) & 0x03
+	tsf := (b[1] >> 4) & 0x03
+
+	off := 8 // header word + stream ID
+
+	if !hasClassID {
+		return nil, errNotDiscovery
+	}
+
+	if binary.BigEndian.Uint16(b[off+6:off+8]) != discoveryClassCode {
+		return nil, errNotDiscovery
+	}
+
+	off += 8
+
+	if tsi != 0 {
+		off += 4
+	}
+
+	if tsf != 0 {
+		off += 8
+	}
+
+	end := len(b)
+	if hasTrailer {
+		end -= 4
+	}
+
+	if off > end {
+		return nil, errShortPacket
+	}
+
+	return parseFields(string(b[off:end])), nil
+}
+
+// parseText reads a beacon sent as text rather than in a VITA packet: the
+// same key=value pairs, or SSDP-style "Key: value" header lines after a
+// request line. Either must name the radio's serial.
+func parseText(b []byte) (map[string]string, error) {
+	text := string(b)
+	if !utf8.ValidString(text) {
+		return nil, errNotDiscovery
+	}
+
+	var fields map[string]string
+
+	if strings.Contains(text, "\n") {
+		fields = make(map[string]string)
+
+		for line := range strings.Lines(text) {
+			k, v, ok := strings.Cut(line, ":")
+			if !ok || strings.ContainsAny(k, " =") {
+				continue
+			}
+
+			k = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(k)), "-", "_")
+			fields[k] = strings.TrimSpace(v)
+		}
+	} else {
+		fields = parseFields(text)
+	}
+
+	if fields["serial"] == "" {
+		return nil, errNotDiscovery
+	}
+
+	return fields, nil
+}
+
+// parseFields splits space-separated key=value pairs. The radio encodes
+// spaces inside values as 0x7F.
+func parseFields(text string) map[string]string {
+	fields := make(map[string]string)
+
+	for pair := range strings.FieldsSeq(strings.TrimRight(text, "\x00")) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			continue
+		}
+
+		fields[strings.ToLower(k)] = strings.ReplaceAll(strings.TrimRight(v, "\x00"), "\x7f", " ")
+	}
+
+	return fields
+}
@@ -0,0 +1,344 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/tz"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wol"
+)
+
+const (
+	SourceLAN       = "lan"
+	SourceSmartLink = "smartlink"
+	// SourceFederation is a radio another bridge serves through this one.
+	SourceFederation = "federation"
+)
+
+// lanTimeout is how long a radio stays online after its last LAN beacon.
+// Radios beacon roughly once a second.
+const lanTimeout = 15 * time.Second
+
+// macRetry is how often a LAN radio's MAC is looked for while the ARP table
+// has none.
+const macRetry = 30 * time.Second
+
+// PublicEndpoint is how a radio is reached over SmartLink.
+type PublicEndpoint struct {
+	Host    string `json:"host"`
+	TLSPort int    `json:"tlsPort,omitempty"`
+	UDPPort int    `json:"udpPort,omitempty"`
+}
+
+// Radio is the merged view of a radio seen on the LAN and/or via SmartLink.
+type Radio struct {
+	Serial   string          `json:"serial"`
+	Model    string          `json:"model"`
+	Nickname string          `json:"nickname"`
+	Callsign string          `json:"callsign"`
+	Version  string          `json:"version"`
+	Host     string          `json:"host,omitempty"`
+	Port     int             `json:"port,omitempty"`
+	Status   string          `json:"status"`
+	Sources  []string        `json:"sources"`
+	Online   bool            `json:"online"`
+	LastSeen time.Time       `json:"lastSeen"`
+	Public   *PublicEndpoint `json:"public,omitempty"`
+	// MAC is the radio's hardware address, learned from the ARP table while
+	// it was on the LAN, for waking it.
+	MAC string `json:"mac,omitempty"`
+	// Protocol is the major version of the discovery protocol its beacons
+	// speak, and Firmware of its SmartSDR version; 0 when unknown.
+	Protocol int `json:"protocol,omitempty"`
+	Firmware int `json:"firmware,omitempty"`
+	// Unsupported says why the bridge cannot serve the radio, for warning
+	// before connecting to it.
+	Unsupported string `json:"unsupported,omitempty"`
+}
+
+// WANRadio is one entry of a SmartLink account's radio list.
+type WANRadio struct {
+	Serial   string
+	Model    string
+	Nickname string
+	Callsign string
+	Version  string
+	Status   string
+	Online   bool
+	Public   PublicEndpoint
+}
+
+type entry struct {
+	lan     *Radio
+	lanSeen time.Time
+	wan     *WANRadio
+	wanSeen time.Time
+	fed     *Radio
+	fedPeer string
+	fedSeen time.Time
+	// mac was learned for macIP, last looked for at macSeen.
+	mac     string
+	macIP   string
+	macSeen time.Time
+}
+
+// Registry tracks every known radio by serial number.
+type Registry struct {
+	mu     sync.Mutex
+	radios map[string]*entry
+	// neighbor looks up an IP address's MAC; wol.Neighbor.
+	neighbor func(ip string) string
+	// wanTimeout is how long a SmartLink entry counts after the fetch that
+	// listed it; zero for as long as it is listed.
+	wanTimeout time.Duration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{radios: make(map[string]*entry), neighbor: wol.Neighbor}
+}
+
+// ObserveLAN records a parsed discovery beacon.
+func (r *Registry) ObserveLAN(fields map[string]string, now time.Time) {
+	serial := fields["serial"]
+	if serial == "" {
+		return
+	}
+
+	port, _ := strconv.Atoi(fields["port"])
+	radio := &Radio{
+		Serial:   serial,
+		Model:    fields["model"],
+		Nickname: fields["nickname"],
+		Callsign: fields["callsign"],
+		Version:  fields["version"],
+		Host:     fields["ip"],
+		Port:     port,
+		Status:   fields["status"],
+		Protocol: protocolVersion(fields),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.entryLocked(serial)
+	e.lan = radio
+	e.lanSeen = now
+
+	if e.macIP != radio.Host || (e.mac == "" && now.Sub(e.macSeen) >= macRetry) {
+		// A radio that moved keeps its MAC until the new address resolves.
+		if mac := r.neighbor(radio.Host); mac != "" {
+			e.mac = mac
+		}
+
+		e.macIP = radio.Host
+		e.macSeen = now
+	}
+}
+
+// MAC returns the hardware address learned for the radio serial, "" if
+// none has been, and whether the radio is known at all.
+func (r *Registry) MAC(serial string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.radios[serial]
+	if !ok {
+		return "", false
+	}
+
+	return e.mac, true
+}
+
+// ExpireWAN has SmartLink entries not listed again within d count as
+// offline, as LAN radios do when their beacons stop, so a radio does not
+// stay online on the strength of a fetch that keeps failing.
+func (r *Registry) ExpireWAN(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wanTimeout = d
+}
+
+// ReplaceWAN replaces the SmartLink view with radios. Radios missing from the
+// list lose their SmartLink source.
+func (r *Registry) ReplaceWAN(radios []WANRadio, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for serial, e := range r.radios {
+		e.wan = nil
+		if e.lan == nil && e.fed == nil {
+			delete(r.radios, serial)
+		}
+	}
+
+	for i := range radios {
+		wr := radios[i]
+		if wr.Serial == "" {
+			continue
+		}
+
+		e := r.entryLocked(wr.Serial)
+		e.wan = &wr
+		e.wanSeen = now
+	}
+}
+
+// ReplaceFederated replaces the radios peer serves through this bridge, each
+// with the local address that reaches it. Radios missing from the list lose
+// their federation source; nil removes them all, as when the peer goes.
+func (r *Registry) ReplaceFederated(peer string, radios []Radio, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for serial, e := range r.radios {
+		if e.fed == nil || e.fedPeer != peer {
+			continue
+		}
+
+		e.fed = nil
+		if e.lan == nil && e.wan == nil {
+			delete(r.radios, serial)
+		}
+	}
+
+	for i := range radios {
+		fr := radios[i]
+		if fr.Serial == "" {
+			continue
+		}
+
+		e := r.entryLocked(fr.Serial)
+		e.fed = &fr
+		e.fedPeer = peer
+		e.fedSeen = now
+	}
+}
+
+func (r *Registry) entryLocked(serial string) *entry {
+	e, ok := r.radios[serial]
+	if !ok {
+		e = &entry{}
+		r.radios[serial] = e
+	}
+
+	return e
+}
+
+// HasHost reports whether an online LAN radio advertises host as its
+// address, or a federated radio is served there.
+func (r *Registry) HasHost(host string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.radios {
+		if e.lan != nil && e.lan.Host == host && now.Sub(e.lanSeen) < lanTimeout {
+			return true
+		}
+
+		if e.fed != nil && e.fed.Host == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+// List returns every known radio sorted by serial.
+func (r *Registry) List(now time.Time) []Radio {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Radio, 0, len(r.radios))
+	for _, e := range r.radios {
+		out = append(out, e.merge(now, r.wanTimeout))
+	}
+
+	slices.SortFunc(out, func(a, b Radio) int { return strings.Compare(a.Serial, b.Serial) })
+
+	for i := range out {
+		out[i].LastSeen = tz.In(out[i].LastSeen)
+	}
+
+	return out
+}
+
+func (e *entry) merge(now time.Time, wanTimeout time.Duration) Radio {
+	var r Radio
+
+	if e.lan != nil {
+		r = *e.lan
+		r.LastSeen = e.lanSeen
+		r.Online = now.Sub(e.lanSeen) < lanTimeout
+		r.Sources = append(r.Sources, SourceLAN)
+
+		if !r.Online {
+			r.Status = "Offline"
+		}
+	}
+
+	r.MAC = e.mac
+
+	// A federated radio is reached through this bridge's proxy, unless
+	// it is heard on the LAN too.
+	if e.fed != nil && (e.lan == nil || !r.Online) {
+		f := e.fed
+		r.Serial, r.Model, r.Nickname, r.Callsign, r.Version = f.Serial, f.Model, f.Nickname, f.Callsign, f.Version
+		r.Host, r.Port, r.Status, r.Protocol = f.Host, f.Port, f.Status, f.Protocol
+		r.LastSeen = e.fedSeen
+		r.Online = true
+	}
+
+	if e.fed != nil {
+		r.Sources = append(r.Sources, SourceFederation)
+	}
+
+	if e.wan != nil {
+		e.mergeWAN(&r, wanTimeout == 0 || now.Sub(e.wanSeen) < wanTimeout)
+	}
+
+	r.Firmware = majorVersion(r.Version)
+	r.Unsupported = unsupported(r.Firmware)
+
+	return r
+}
+
+// mergeWAN adds what SmartLink knows of the radio to r. A stale entry no
+// longer says whether it is online.
+func (e *entry) mergeWAN(r *Radio, fresh bool) {
+	w := e.wan
+	pub := w.Public
+	r.Public = &pub
+	r.Sources = append(r.Sources, SourceSmartLink)
+
+	if e.lan == nil && e.fed == nil {
+		r.Serial, r.Model, r.Nickname, r.Callsign, r.Version = w.Serial, w.Model, w.Nickname, w.Callsign, w.Version
+		r.Status = w.Status
+		r.LastSeen = e.wanSeen
+
+		if !fresh {
+			r.Status = "Offline"
+		}
+	}
+
+	if fresh && w.Online && !r.Online {
+		r.Online = true
+		r.Status = w.Status
+	}
+}
+
+// RadiosHandler serves the merged radio list as JSON.
+func (s *Service) RadiosHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.registry.List(time.Now()))
+}
+
+// Registry exposes the service's radio registry, e.g. for SmartLink merging.
+func (s *Service) Registry() *Registry {
+	return s.registry
+}
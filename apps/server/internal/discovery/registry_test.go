@@ -0,0 +1,233 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func buildDiscoveryPacket(payload string) []byte {
+	for len(payload)%4 != 0 {
+		payload += " "
+	}
+
+	b := make([]byte, 28+len(payload)+4)
+	b[0] = 0x38 // ext data with stream, class ID + trailer
+	b[1] = 0x50 // TSI=other, TSF=sample count
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)/4))
+	binary.BigEndian.PutUint32(b[4:8], 0x800)
+	binary.BigEndian.PutUint32(b[8:12], 0x001C2D)
+	binary.BigEndian.PutUint16(b[12:14], 0x534C)
+	binary.BigEndian.PutUint16(b[14:16], discoveryClassCode)
+	copy(b[28:], payload)
+
+	return b
+}
+
+func TestParsePayload(t *testing.T) {
+	t.Parallel()
+
+	pkt := buildDiscoveryPacket("model=FLEX-8600 serial=1225-1213-8600-7918 ip=10.0.0.2 port=4992 " +
+		"gui_client_stations=MacBook\x7fPro")
+
+	f, err := parsePayload(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f["serial"] != "1225-1213-8600-7918" || f["port"] != "4992" {
+		t.Errorf("unexpected fields: %v", f)
+	}
+
+	if f["gui_client_stations"] != "MacBook Pro" {
+		t.Errorf("0x7F should decode to space, got %q", f["gui_client_stations"])
+	}
+}
+
+func TestParsePayload_Legacy(t *testing.T) {
+	t.Parallel()
+
+	f, err := parsePayload(buildDiscoveryPacket("discovery_protocol_version=2.0.0.0 model=FLEX-6500 " +
+		"serial=0914-1039-6500-0042 version=2.4.9.147 inuse_ip=10.0.0.9 inuse_host=shack-pc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f["gui_client_ips"] != "10.0.0.9" || f["gui_client_hosts"] != "shack-pc" || f["inuse_ip"] != "10.0.0.9" {
+		t.Errorf("legacy fields not given their current names: %v", f)
+	}
+}
+
+func TestParsePayload_Text(t *testing.T) {
+	t.Parallel()
+
+	f, err := parsePayload([]byte("model=FLEX-8400 serial=1225-1213-8400-0001 version=4.0.1.100 ip=10.0.0.3"))
+	if err != nil || f["serial"] != "1225-1213-8400-0001" || f["ip"] != "10.0.0.3" {
+		t.Errorf("key=value text: %v, %v", f, err)
+	}
+
+	f, err = parsePayload([]byte("NOTIFY * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nSerial: 1225-1213-8400-0001\r\n" +
+		"Model: FLEX-8400\r\nDiscovery-Protocol-Version: 4.0.0.0\r\n\r\n"))
+	if err != nil || f["serial"] != "1225-1213-8400-0001" || f["model"] != "FLEX-8400" || f["discovery_protocol_version"] != "4.0.0.0" {
+		t.Errorf("SSDP-style text: %v, %v", f, err)
+	}
+
+	if _, err := parsePayload([]byte("hello, radio, are you there?")); !errors.Is(err, errNotDiscovery) {
+		t.Errorf("text without a serial: err = %v", err)
+	}
+}
+
+func TestRegistry_Versions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	r := NewRegistry()
+	r.ObserveLAN(map[string]string{"serial": "A", "version": "3.8.19.36458", "discovery_protocol_version": "3.1.0.2"}, now)
+	r.ObserveLAN(map[string]string{"serial": "B", "version": "2.4.9.147", "inuse_ip": ""}, now)
+	r.ReplaceWAN([]WANRadio{{Serial: "C", Version: "1.10.16.174"}}, now)
+
+	list := r.List(now)
+	if len(list) != 3 {
+		t.Fatalf("got %d radios want 3", len(list))
+	}
+
+	if a := list[0]; a.Protocol != 3 || a.Firmware != 3 || a.Unsupported != "" {
+		t.Errorf("v3 radio = %+v", a)
+	}
+
+	if b := list[1]; b.Protocol != 2 || b.Firmware != 2 || b.Unsupported == "" {
+		t.Errorf("v2 radio = %+v", b)
+	}
+
+	if c := list[2]; c.Protocol != 0 || c.Firmware != 1 || c.Unsupported == "" {
+		t.Errorf("SmartLink v1 radio = %+v", c)
+	}
+}
+
+func TestRegistry_MergesLANAndWAN(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	r := NewRegistry()
+	r.ObserveLAN(map[string]string{"serial": "A", "model": "FLEX-6600", "ip": "10.0.0.2", "port": "4992", "status": "Available"}, now)
+	r.ReplaceWAN([]WANRadio{
+		{Serial: "A", Status: "Available", Online: true, Public: PublicEndpoint{Host: "198.51.100.1", TLSPort: 4994}},
+		{Serial: "B", Model: "FLEX-8400", Status: "Offline"},
+	}, now)
+
+	list := r.List(now)
+	if len(list) != 2 {
+		t.Fatalf("got %d radios want 2", len(list))
+	}
+
+	a := list[0]
+	if a.Host != "10.0.0.2" || a.Public == nil || a.Public.TLSPort != 4994 || len(a.Sources) != 2 {
+		t.Errorf("radio A not merged: %+v", a)
+	}
+
+	b := list[1]
+	if b.Online || b.Model != "FLEX-8400" || b.Sources[0] != SourceSmartLink {
+		t.Errorf("radio B: %+v", b)
+	}
+
+	// LAN beacon goes stale but SmartLink still reports A online.
+	later := now.Add(time.Minute)
+	if a := r.List(later)[0]; !a.Online {
+		t.Error("SmartLink should keep A online after LAN beacons stop")
+	}
+
+	r.ReplaceWAN(nil, later)
+
+	list = r.List(later)
+	if len(list) != 1 || list[0].Online {
+		t.Errorf("expected only stale LAN entry for A, got %+v", list)
+	}
+}
+
+func TestRegistry_ExpiresWAN(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	r := NewRegistry()
+	r.ExpireWAN(75 * time.Second)
+	r.ReplaceWAN([]WANRadio{{Serial: "A", Status: "Available", Online: true}}, now)
+
+	if a := r.List(now.Add(time.Minute))[0]; !a.Online || a.Status != "Available" {
+		t.Errorf("within the timeout: %+v", a)
+	}
+
+	// The fetches since have failed, so nothing has listed A again.
+	a := r.List(now.Add(2 * time.Minute))[0]
+	if a.Online || a.Status != "Offline" || !a.LastSeen.Equal(now) || a.Sources[0] != SourceSmartLink {
+		t.Errorf("after the timeout: %+v", a)
+	}
+
+	r.ReplaceWAN([]WANRadio{{Serial: "A", Status: "Available", Online: true}}, now.Add(3*time.Minute))
+
+	if a := r.List(now.Add(3 * time.Minute))[0]; !a.Online {
+		t.Errorf("listed again: %+v", a)
+	}
+}
+
+func TestRegistry_Federated(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	r := NewRegistry()
+	r.ReplaceFederated("shack", []Radio{{Serial: "F", Model: "FLEX-8600", Host: "127.0.0.1", Port: 40000, Status: "Available"}}, now)
+
+	list := r.List(now)
+	if len(list) != 1 || list[0].Port != 40000 || !list[0].Online || list[0].Sources[0] != SourceFederation {
+		t.Fatalf("list = %+v", list)
+	}
+
+	if !r.HasHost("127.0.0.1", now) {
+		t.Error("federated radio's host not known")
+	}
+
+	// Another peer's list leaves it alone; its own empty list removes it.
+	r.ReplaceFederated("other", nil, now)
+	r.ReplaceFederated("shack", nil, now)
+
+	if got := r.List(now); len(got) != 0 {
+		t.Errorf("list after the peer went = %+v", got)
+	}
+}
+
+func TestRegistry_LearnsMAC(t *testing.T) {
+	t.Parallel()
+
+	arp := map[string]string{"10.0.0.2": "00:1c:2d:05:37:2a"}
+	lookups := 0
+
+	now := time.Unix(1000, 0)
+	r := NewRegistry()
+	r.neighbor = func(ip string) string {
+		lookups++
+
+		return arp[ip]
+	}
+
+	r.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2"}, now)
+	r.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2"}, now.Add(time.Second))
+
+	if mac, known := r.MAC("A"); mac != "00:1c:2d:05:37:2a" || !known || lookups != 1 {
+		t.Fatalf("MAC = %q, %v after %d lookups", mac, known, lookups)
+	}
+
+	if got := r.List(now)[0].MAC; got != "00:1c:2d:05:37:2a" {
+		t.Errorf("listed MAC = %q", got)
+	}
+
+	// A move to an address the table lacks keeps the MAC.
+	r.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.3"}, now.Add(2*time.Second))
+
+	if mac, _ := r.MAC("A"); mac != "00:1c:2d:05:37:2a" {
+		t.Errorf("MAC after a move = %q", mac)
+	}
+
+	if _, known := r.MAC("B"); known {
+		t.Error("unseen radio known")
+	}
+}
@@ -0,0 +1,213 @@
+package discovery
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/heartbeat"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/sockopt"
+	"github.com/gorilla/websocket"
+)
+
+// relayHold is how long a relayed beacon is remembered, so that hearing it
+// again from this network's broadcast is not relayed back to its peer.
+const relayHold = 5 * time.Second
+
+var errRelayPeer = errors.New("discovery: relay peer must be an http, https, ws or wss URL")
+
+// RelayOptions configures RunRelay.
+type RelayOptions struct {
+	// Peer is the other bridge's base URL, e.g. https://remote.example:8080.
+	Peer string
+	// Token is the peer's relay token.
+	Token string
+	// Broadcast is where relayed beacons are sent on this network; default
+	// the limited broadcast address on the discovery port.
+	Broadcast string
+}
+
+// relayedSet remembers the beacons a relay broadcast here.
+type relayedSet struct {
+	seed maphash.Seed
+
+	mu sync.Mutex
+	at map[uint64]time.Time
+}
+
+func newRelayedSet() *relayedSet {
+	return &relayedSet{seed: maphash.MakeSeed(), at: make(map[uint64]time.Time)}
+}
+
+func (r *relayedSet) add(pkt []byte, now time.Time) {
+	sum := maphash.Bytes(r.seed, pkt)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, at := range r.at {
+		if now.Sub(at) >= relayHold {
+			delete(r.at, k)
+		}
+	}
+
+	r.at[sum] = now
+}
+
+// has reports whether pkt was relayed here within relayHold.
+func (r *relayedSet) has(pkt []byte, now time.Time) bool {
+	sum := maphash.Bytes(r.seed, pkt)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	at, ok := r.at[sum]
+
+	return ok && now.Sub(at) < relayHold
+}
+
+// relayURL is the peer's /ws/discovery endpoint for a relay.
+func relayURL(peer string) (string, error) {
+	u, err := url.Parse(peer)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("%w: %q", errRelayPeer, peer)
+	}
+
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("%w: %q", errRelayPeer, peer)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/ws/discovery"
+	u.RawQuery = "relay=1"
+	u.Fragment = ""
+
+	return u.String(), nil
+}
+
+// RunRelay receives the discovery beacons of the bridge at opt.Peer and
+// broadcasts them on this network, so SmartSDR clients here see the radios
+// there. It reconnects until ctx is cancelled.
+func (s *Service) RunRelay(ctx context.Context, opt RelayOptions) error {
+	peer, err := relayURL(opt.Peer)
+	if err != nil {
+		return err
+	}
+
+	if opt.Broadcast == "" {
+		opt.Broadcast = net.JoinHostPort(net.IPv4bcast.String(), strconv.Itoa(s.opt.Port))
+	}
+
+	dst, err := net.ResolveUDPAddr("udp4", opt.Broadcast)
+	if err != nil {
+		return fmt.Errorf("discovery relay broadcast: %w", err)
+	}
+
+	lc := net.ListenConfig{Control: sockopt.Broadcast}
+
+	pc, err := lc.ListenPacket(ctx, "udp4", ":0")
+	if err != nil {
+		return fmt.Errorf("discovery relay socket: %w", err)
+	}
+
+	defer func() { _ = pc.Close() }()
+
+	header := http.Header{}
+	if opt.Token != "" {
+		header.Set("Authorization", "Bearer "+opt.Token)
+	}
+
+	backoff := 0 * time.Millisecond
+
+	for {
+		err := s.relayOnce(ctx, peer, header, pc, dst)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		backoff = next(backoff, s.opt.MaxBackoff)
+		log.Printf("[discovery] relay from %s ended: %v; reconnecting in %v", opt.Peer, err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *Service) relayOnce(ctx context.Context, peer string, header http.Header, pc net.PacketConn, dst net.Addr) error {
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, peer, header)
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("%w (%s)", err, resp.Status)
+		}
+
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		_ = ws.Close()
+	}()
+
+	heartbeat.Keep(ctx, ws)
+	log.Printf("[discovery] relaying beacons from %s", peer)
+
+	for {
+		msgType, pkt, err := ws.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+
+		s.relayed.add(pkt, time.Now())
+
+		_, err = pc.WriteTo(pkt, dst)
+		if err != nil {
+			log.Printf("[discovery] relay broadcast to %s: %v", dst, err)
+
+			continue
+		}
+
+		s.totals.relayed.Add(1)
+	}
+}
+
+// relayAuthorized checks a relay's bearer token against RelayToken. Without
+// a token relays are refused, since beacons name every radio on the network.
+func (s *Service) relayAuthorized(r *http.Request) bool {
+	if s.opt.RelayToken == "" {
+		return false
+	}
+
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(s.opt.RelayToken)) == 1
+}
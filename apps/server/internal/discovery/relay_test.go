@@ -0,0 +1,169 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelayURL(t *testing.T) {
+	t.Parallel()
+
+	for peer, want := range map[string]string{
+		"http://10.0.0.5:8080":           "ws://10.0.0.5:8080/ws/discovery?relay=1",
+		"https://remote.example/bridge/": "wss://remote.example/bridge/ws/discovery?relay=1",
+		"wss://remote.example":           "wss://remote.example/ws/discovery?relay=1",
+	} {
+		got, err := relayURL(peer)
+		if err != nil || got != want {
+			t.Errorf("relayURL(%q) = %q, %v", peer, got, err)
+		}
+	}
+
+	for _, bad := range []string{"remote.example:8080", "ftp://remote.example", "http://"} {
+		_, err := relayURL(bad)
+		if err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestRelayedSet(t *testing.T) {
+	t.Parallel()
+
+	r := newRelayedSet()
+	now := time.Now()
+
+	r.add([]byte("beacon"), now)
+
+	if !r.has([]byte("beacon"), now.Add(time.Second)) || r.has([]byte("other"), now) {
+		t.Error("relayed beacon not recognised")
+	}
+
+	if r.has([]byte("beacon"), now.Add(relayHold)) {
+		t.Error("relayed beacon remembered too long")
+	}
+}
+
+func TestBroadcast_SkipsRelaysForRelayed(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{})
+
+	local := s.Subscribe("local")
+	t.Cleanup(local.Close)
+
+	relay := s.subscribe("relay", true)
+	t.Cleanup(relay.Close)
+
+	s.broadcast([]byte("remote"), nil, true)
+	s.broadcast([]byte("lan"), nil, false)
+
+	if string(<-local.C)+string(<-local.C) != "remotelan" {
+		t.Error("local subscriber missed relayed beacons")
+	}
+
+	if got := string(<-relay.C); got != "lan" {
+		t.Errorf("relay was sent %q", got)
+	}
+}
+
+func TestWSHandler_RelayAuth(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		token, auth string
+		want        int
+	}{
+		{"", "Bearer anything", http.StatusUnauthorized},
+		{"s3cret", "", http.StatusUnauthorized},
+		{"s3cret", "Bearer wrong", http.StatusUnauthorized},
+	} {
+		s := New(Options{RelayToken: c.token})
+		ts := httptest.NewServer(http.HandlerFunc(s.WSHandler))
+
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL+"?relay=1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = resp.Body.Close()
+
+		ts.Close()
+
+		if resp.StatusCode != c.want {
+			t.Errorf("token %q, auth %q: status %d", c.token, c.auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestRunRelay(t *testing.T) {
+	t.Parallel()
+
+	remote := New(Options{RelayToken: "s3cret"})
+	ts := httptest.NewServer(http.HandlerFunc(remote.WSHandler))
+	t.Cleanup(ts.Close)
+
+	lan, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = lan.Close() })
+
+	local := New(Options{})
+	ctx, cancel := context.WithCancel(t.Context())
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- local.RunRelay(ctx, RelayOptions{Peer: ts.URL, Token: "s3cret", Broadcast: lan.LocalAddr().String()})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(remote.Fanout().Subscribers) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("relay did not connect")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if st := remote.Fanout().Subscribers[0]; !st.Relay || !strings.HasPrefix(st.Name, "relay ") {
+		t.Errorf("relay listed as %+v", st)
+	}
+
+	remote.broadcast([]byte("beacon"), nil, false)
+
+	_ = lan.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 64)
+
+	n, _, err := lan.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf[:n]) != "beacon" || !local.relayed.has(buf[:n], time.Now()) {
+		t.Errorf("broadcast %q", buf[:n])
+	}
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Errorf("RunRelay = %v", err)
+	}
+}
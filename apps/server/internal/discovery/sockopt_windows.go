@@ -2,7 +2,9 @@
 
 package discovery
 
-import "syscall"
+import (
+	"syscall"
+)
 
 // ipprotoIPv6 and ipv6V6Only are well-known constants (RFC 3493 / WinSock2).
 // The syscall package for Windows doesn't export them, so we define them here.
@@ -0,0 +1,332 @@
+package discovery
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/tz"
+)
+
+// What happens to a subscriber whose queue is full at its largest.
+const (
+	// SlowDropOldest drops its oldest queued packet for the new one.
+	SlowDropOldest = "drop-oldest"
+	// SlowDisconnect disconnects it.
+	SlowDisconnect = "disconnect"
+)
+
+const (
+	// DefaultMaxBuffer is the most packets a subscriber may have queued.
+	DefaultMaxBuffer = 4096
+	// minBuffer is where a subscriber's queue starts. It doubles, up to the
+	// maximum, each time the subscriber falls that far behind, and halves
+	// again each time it catches up.
+	minBuffer = 256
+)
+
+// Subscriber receives discovery packets on C, in order. Packets wait in a
+// queue of its own, so one slow subscriber does not hold the others up.
+type Subscriber struct {
+	// C is closed when the subscriber is closed, or disconnected for
+	// falling behind.
+	C <-chan []byte
+
+	s     *Service
+	name  string
+	relay bool // another bridge's relay, which is not sent beacons it relayed
+	since time.Time
+	out   chan []byte
+	wake  chan struct{}
+	done  chan struct{}
+	stop  sync.Once
+
+	mu      sync.Mutex
+	filter  *Filter // nil takes every packet
+	queue   [][]byte
+	limit   int
+	sent    uint64
+	dropped uint64
+	// every is the least time between two beacons of a radio; lastSent
+	// is when each radio's was queued, and skipped counts those left out.
+	every    time.Duration
+	lastSent map[string]time.Time
+	skipped  uint64
+}
+
+// SubscriberStats is one subscriber, as the admin API lists it.
+type SubscriberStats struct {
+	Name     string    `json:"name"`
+	Since    time.Time `json:"since"`
+	Filtered bool      `json:"filtered,omitempty"`
+	Relay    bool      `json:"relay,omitempty"`
+	Queued   int       `json:"queued"`
+	Buffer   int       `json:"buffer"`
+	Sent     uint64    `json:"sent"`
+	Dropped  uint64    `json:"dropped"`
+	// Skipped counts beacons left out to keep to the client's interval.
+	Skipped uint64 `json:"skipped,omitempty"`
+}
+
+// FanoutStats describes the discovery fan-out.
+type FanoutStats struct {
+	SlowConsumer string            `json:"slowConsumer"`
+	MaxBuffer    int               `json:"maxBuffer"`
+	Subscribers  []SubscriberStats `json:"subscribers"`
+	// Dropped and Disconnected count over the service's life, including
+	// subscribers that have gone.
+	Dropped      uint64 `json:"dropped"`
+	Disconnected uint64 `json:"disconnected"`
+	// Duplicates counts copies of a beacon that were not sent on.
+	Duplicates uint64 `json:"duplicates"`
+	// Relayed counts beacons a relay peer sent that were broadcast here.
+	Relayed uint64 `json:"relayed,omitempty"`
+}
+
+// fanoutTotals are the counters that outlive subscribers.
+type fanoutTotals struct {
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+	duplicates   atomic.Uint64
+	relayed      atomic.Uint64
+}
+
+// Subscribe returns a subscriber to every discovery packet. name tells it
+// apart in the stats, e.g. the client's address. Close it when done.
+func (s *Service) Subscribe(name string) *Subscriber {
+	return s.subscribe(name, false)
+}
+
+func (s *Service) subscribe(name string, relay bool) *Subscriber {
+	sub := &Subscriber{
+		s:     s,
+		name:  name,
+		relay: relay,
+		since: time.Now(),
+		out:   make(chan []byte),
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+		limit: min(minBuffer, s.opt.MaxBuffer),
+	}
+	sub.C = sub.out
+
+	s.subMu.Lock()
+	s.subs[sub] = struct{}{}
+	s.subMu.Unlock()
+
+	go sub.pump()
+
+	return sub
+}
+
+// Close stops the subscriber and closes C.
+func (sub *Subscriber) Close() {
+	sub.s.subMu.Lock()
+	delete(sub.s.subs, sub)
+	sub.s.subMu.Unlock()
+
+	sub.stop.Do(func() { close(sub.done) })
+}
+
+// setFilter changes what the subscriber receives.
+func (sub *Subscriber) setFilter(f Filter) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if f.empty() {
+		sub.filter = nil
+	} else {
+		sub.filter = &f
+	}
+}
+
+// setInterval sends at most one beacon per radio every d; zero sends all.
+func (sub *Subscriber) setInterval(d time.Duration) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.every = d
+	sub.lastSent = nil
+}
+
+// pump hands queued packets to C until the subscriber stops.
+func (sub *Subscriber) pump() {
+	defer close(sub.out)
+
+	for {
+		pkt, ok := sub.pop()
+		if !ok {
+			select {
+			case <-sub.wake:
+				continue
+			case <-sub.done:
+				return
+			}
+		}
+
+		select {
+		case sub.out <- pkt:
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+func (sub *Subscriber) pop() ([]byte, bool) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if len(sub.queue) == 0 {
+		sub.limit = max(min(minBuffer, sub.s.opt.MaxBuffer), sub.limit/2)
+
+		return nil, false
+	}
+
+	pkt := sub.queue[0]
+	sub.queue[0] = nil
+	sub.queue = sub.queue[1:]
+	sub.sent++
+
+	return pkt, true
+}
+
+// offer queues a packet the subscriber's filter takes. It reports false when
+// the subscriber is too far behind and must be disconnected.
+func (sub *Subscriber) offer(pkt []byte, fields map[string]string) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if !sub.filter.matchFields(fields) {
+		return true
+	}
+
+	if serial := fields["serial"]; sub.every > 0 && serial != "" {
+		now := time.Now()
+		if at, ok := sub.lastSent[serial]; ok && now.Sub(at) < sub.every {
+			sub.skipped++
+
+			return true
+		}
+
+		if sub.lastSent == nil {
+			sub.lastSent = make(map[string]time.Time)
+		}
+
+		sub.lastSent[serial] = now
+	}
+
+	if len(sub.queue) >= sub.limit {
+		switch {
+		case sub.limit < sub.s.opt.MaxBuffer:
+			sub.limit = min(2*sub.limit, sub.s.opt.MaxBuffer)
+		case sub.s.opt.SlowConsumer == SlowDisconnect:
+			return false
+		default:
+			if sub.dropped == 0 {
+				log.Printf("[discovery] %s is %d packets behind; dropping its oldest", sub.name, len(sub.queue))
+			}
+
+			sub.queue[0] = nil
+			sub.queue = sub.queue[1:]
+			sub.dropped++
+			sub.s.totals.dropped.Add(1)
+		}
+	}
+
+	sub.queue = append(sub.queue, pkt)
+
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+func (sub *Subscriber) stats() SubscriberStats {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	return SubscriberStats{
+		Name:     sub.name,
+		Since:    tz.In(sub.since),
+		Filtered: sub.filter != nil,
+		Relay:    sub.relay,
+		Queued:   len(sub.queue),
+		Buffer:   sub.limit,
+		Sent:     sub.sent,
+		Dropped:  sub.dropped,
+		Skipped:  sub.skipped,
+	}
+}
+
+// broadcast hands a packet, with its fields when it parsed, to every
+// subscriber whose filter takes it, disconnecting those too far behind. A
+// packet relayed from another bridge is not sent to relays, so two bridges
+// relaying to each other do not echo beacons back and forth.
+func (s *Service) broadcast(b []byte, fields map[string]string, relayed bool) {
+	var slow []*Subscriber
+
+	s.subMu.Lock()
+	for sub := range s.subs {
+		if relayed && sub.relay {
+			continue
+		}
+
+		if !sub.offer(b, fields) {
+			slow = append(slow, sub)
+		}
+	}
+	s.subMu.Unlock()
+
+	for _, sub := range slow {
+		log.Printf("[discovery] %s fell %d packets behind; disconnecting it", sub.name, s.opt.MaxBuffer)
+		s.totals.disconnected.Add(1)
+		sub.Close()
+	}
+}
+
+// Fanout describes the discovery subscribers and how far behind they are.
+func (s *Service) Fanout() FanoutStats {
+	s.subMu.Lock()
+	subs := make([]*Subscriber, 0, len(s.subs))
+	for sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	s.subMu.Unlock()
+
+	st := FanoutStats{
+		SlowConsumer: s.opt.SlowConsumer,
+		MaxBuffer:    s.opt.MaxBuffer,
+		Subscribers:  make([]SubscriberStats, 0, len(subs)),
+		Dropped:      s.totals.dropped.Load(),
+		Disconnected: s.totals.disconnected.Load(),
+		Duplicates:   s.totals.duplicates.Load(),
+		Relayed:      s.totals.relayed.Load(),
+	}
+
+	for _, sub := range subs {
+		st.Subscribers = append(st.Subscribers, sub.stats())
+	}
+
+	slices.SortFunc(st.Subscribers, func(a, b SubscriberStats) int {
+		if c := a.Since.Compare(b.Since); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return st
+}
+
+// FanoutHandler serves Fanout as JSON.
+func (s *Service) FanoutHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Fanout())
+}
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscriber_DropOldest(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{MaxBuffer: 4})
+	sub := &Subscriber{s: s, name: "slow", limit: 2, wake: make(chan struct{}, 1)}
+
+	for _, p := range []string{"1", "2", "3", "4", "5", "6"} {
+		if !sub.offer([]byte(p), nil) {
+			t.Fatal("disconnected under drop-oldest")
+		}
+	}
+
+	// The queue grew from 2 to the maximum of 4 before dropping.
+	var got string
+	for _, p := range sub.queue {
+		got += string(p)
+	}
+
+	if got != "3456" || sub.dropped != 2 || s.Fanout().Dropped != 2 {
+		t.Errorf("queue %q, %d dropped", got, sub.dropped)
+	}
+
+	// Catching up halves a grown queue, down to where queues start.
+	big := &Subscriber{s: New(Options{}), limit: 1024}
+	big.pop()
+
+	if big.limit != 512 {
+		t.Errorf("buffer %d after catching up", big.limit)
+	}
+
+	for range 3 {
+		big.pop()
+	}
+
+	if big.limit != minBuffer {
+		t.Errorf("buffer %d after catching up for good", big.limit)
+	}
+}
+
+func TestSubscriber_Disconnect(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{MaxBuffer: 4, SlowConsumer: SlowDisconnect})
+	sub := s.Subscribe("slow")
+
+	for range 10 {
+		s.broadcast([]byte("x"), nil, false)
+	}
+
+	timeout := time.After(5 * time.Second)
+
+	for open := true; open; {
+		select {
+		case _, open = <-sub.C:
+		case <-timeout:
+			t.Fatal("slow subscriber not disconnected")
+		}
+	}
+
+	st := s.Fanout()
+	if st.Disconnected != 1 || len(st.Subscribers) != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestSubscriber_Interval(t *testing.T) {
+	t.Parallel()
+
+	sub := &Subscriber{s: New(Options{}), limit: 8, wake: make(chan struct{}, 1)}
+	sub.setInterval(time.Hour)
+
+	for _, serial := range []string{"A", "A", "B", "A"} {
+		sub.offer([]byte(serial), map[string]string{"serial": serial})
+	}
+
+	if len(sub.queue) != 2 || sub.skipped != 2 {
+		t.Errorf("%d queued, %d skipped", len(sub.queue), sub.skipped)
+	}
+}
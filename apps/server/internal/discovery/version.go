@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinFirmware is the oldest SmartSDR major version the bridge works with:
+// it creates streams and registers GUI clients as the v3 API does.
+const MinFirmware = 3
+
+// legacyFields maps the fields of protocol v2 beacons to their current names,
+// so a radio on old firmware reads as a current one does.
+var legacyFields = map[string]string{
+	"inuse_ip":   "gui_client_ips",
+	"inuse_host": "gui_client_hosts",
+}
+
+// normalize adds the current names of a legacy beacon's fields, leaving any
+// the beacon already has.
+func normalize(fields map[string]string) {
+	for old, current := range legacyFields {
+		if v, ok := fields[old]; ok {
+			if _, has := fields[current]; !has {
+				fields[current] = v
+			}
+		}
+	}
+}
+
+// protocolVersion is the major version of the discovery protocol a beacon
+// speaks, from its discovery_protocol_version. Beacons without one are told
+// apart by their fields: v2 names the client in use, v3 lists GUI clients.
+// Zero when it is unknown.
+func protocolVersion(fields map[string]string) int {
+	if v := majorVersion(fields["discovery_protocol_version"]); v > 0 {
+		return v
+	}
+
+	_, legacy := fields["inuse_ip"]
+	_, current := fields["gui_client_handles"]
+
+	switch {
+	case legacy:
+		return 2
+	case current:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// majorVersion is the first number of a dotted version, e.g. 3 for
+// "3.8.19.36458", or 0 if it has none.
+func majorVersion(v string) int {
+	first, _, _ := strings.Cut(strings.TrimPrefix(strings.ToLower(v), "v"), ".")
+
+	n, err := strconv.Atoi(first)
+	if err != nil || n < 0 {
+		return 0
+	}
+
+	return n
+}
+
+// unsupported is why the bridge cannot serve a radio on firmware major
+// version, or "" when it can or the version is unknown.
+func unsupported(firmware int) string {
+	if firmware == 0 || firmware >= MinFirmware {
+		return ""
+	}
+
+	return fmt.Sprintf("SmartSDR v%d firmware; the bridge needs v%d or later", firmware, MinFirmware)
+}
@@ -0,0 +1,42 @@
+package discovery
+
+import "testing"
+
+func TestProtocolVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		name   string
+		fields map[string]string
+		want   int
+	}{
+		{"stated", map[string]string{"discovery_protocol_version": "3.1.0.2", "inuse_ip": ""}, 3},
+		{"legacy fields", map[string]string{"inuse_ip": "", "inuse_host": ""}, 2},
+		{"GUI client list", map[string]string{"gui_client_handles": ""}, 3},
+		{"neither", map[string]string{"serial": "A"}, 0},
+	} {
+		if got := protocolVersion(c.fields); got != c.want {
+			t.Errorf("%s: protocol %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	t.Parallel()
+
+	for v, want := range map[string]int{
+		"3.8.19.36458": 3,
+		"v2.4.9":       2,
+		"4":            4,
+		"":             0,
+		"beta":         0,
+	} {
+		if got := majorVersion(v); got != want {
+			t.Errorf("majorVersion(%q) = %d, want %d", v, got, want)
+		}
+	}
+
+	if unsupported(2) == "" || unsupported(MinFirmware) != "" || unsupported(0) != "" {
+		t.Error("unsupported should flag only known firmware before MinFirmware")
+	}
+}
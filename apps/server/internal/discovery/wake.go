@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wol"
+)
+
+// wakeResult is the body of a successful wake request.
+type wakeResult struct {
+	Serial string `json:"serial"`
+	MAC    string `json:"mac"`
+}
+
+// WakeHandler powers on the radio named by the serial path value with a
+// wake-on-LAN packet to its configured MAC, or else the one learned while it
+// was on the LAN. It answers 202 once the packet is sent, as the radio takes
+// a while to boot and start beaconing.
+func (s *Service) WakeHandler(w http.ResponseWriter, r *http.Request) {
+	if s.opt.CheckOrigin != nil && !s.opt.CheckOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+
+		return
+	}
+
+	serial := r.PathValue("serial")
+
+	learned, known := s.registry.MAC(serial)
+	mac := cmp.Or(s.opt.MACs[serial], learned)
+
+	switch {
+	case mac == "" && !known:
+		http.Error(w, "unknown radio", http.StatusNotFound)
+
+		return
+	case mac == "":
+		http.Error(w, "radio's MAC address is not known; set it in radio-macs", http.StatusConflict)
+
+		return
+	}
+
+	err := wol.Send(mac, cmp.Or(s.opt.WakeBroadcast, wol.DefaultBroadcast))
+	if err != nil {
+		log.Printf("[discovery] wake %s: %v", serial, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	log.Printf("[discovery] sent wake-on-LAN to %s (%s)", serial, mac) //nolint:gosec // serial names a known radio
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(wakeResult{Serial: serial, MAC: mac})
+}
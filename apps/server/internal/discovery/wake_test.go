@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWakeHandler(t *testing.T) {
+	t.Parallel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = pc.Close() }()
+
+	s := New(Options{
+		MACs:          map[string]string{"C": "00:1c:2d:05:37:2c"},
+		WakeBroadcast: pc.LocalAddr().String(),
+	})
+	s.registry.neighbor = func(string) string { return "" }
+	s.registry.ObserveLAN(map[string]string{"serial": "A", "ip": "10.0.0.2"}, time.Now())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/radios/{serial}/wake", s.WakeHandler)
+
+	for serial, want := range map[string]int{
+		"A": http.StatusConflict,
+		"B": http.StatusNotFound,
+		"C": http.StatusAccepted,
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/radios/"+serial+"/wake", nil))
+
+		if rec.Code != want {
+			t.Errorf("%s: status %d want %d: %s", serial, rec.Code, want, rec.Body)
+		}
+	}
+
+	buf := make([]byte, 200)
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 102 || buf[11] != 0x2c {
+		t.Errorf("packet = % x", buf[:n])
+	}
+}
@@ -0,0 +1,100 @@
+// Package dscp marks the bridge's sockets with DiffServ code points, so a
+// home router with QoS sends real-time audio ahead of bulk panadapter data.
+// Marking is per socket: a WebRTC connection bundles its audio and data
+// channels on one socket, so both go out with one mark.
+package dscp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/pion/transport/v4"
+	"github.com/pion/transport/v4/stdnet"
+)
+
+// Code points the bridge uses by default.
+const (
+	// EF, expedited forwarding, is for audio.
+	EF = 46
+	// AF21, low-latency data, is for the radio's lines and panadapter.
+	AF21 = 18
+)
+
+var errUnsupported = errors.New("dscp marking is not supported on this platform")
+
+// Mark sets c's code point for what it sends over IPv4 and IPv6. Zero leaves
+// c unmarked.
+func Mark(c syscall.Conn, codePoint int) error {
+	if codePoint == 0 {
+		return nil
+	}
+
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("dscp: %w", err)
+	}
+
+	var serr error
+
+	err = rc.Control(func(fd uintptr) {
+		serr = setTOS(fd, codePoint<<2) // the top six bits of the TOS byte
+	})
+	if err != nil {
+		return fmt.Errorf("dscp: %w", err)
+	}
+
+	if serr != nil {
+		return fmt.Errorf("dscp %d: %w", codePoint, serr)
+	}
+
+	return nil
+}
+
+// Net is pion's standard network with each UDP socket it opens marked with
+// codePoint, for the ICE agent and its UDP mux.
+func Net(codePoint int) (transport.Net, error) {
+	n, err := stdnet.NewNet()
+	if err != nil {
+		return nil, fmt.Errorf("dscp: %w", err)
+	}
+
+	return &markingNet{Net: n, codePoint: codePoint}, nil
+}
+
+type markingNet struct {
+	*stdnet.Net
+
+	codePoint int
+}
+
+func (n *markingNet) ListenUDP(network string, laddr *net.UDPAddr) (transport.UDPConn, error) {
+	c, err := n.Net.ListenUDP(network, laddr)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // as stdnet reports it
+	}
+
+	n.mark(c)
+
+	return c, nil
+}
+
+func (n *markingNet) ListenPacket(network, address string) (net.PacketConn, error) {
+	c, err := n.Net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // as stdnet reports it
+	}
+
+	n.mark(c)
+
+	return c, nil
+}
+
+// mark marks c if it is a real socket. A socket that cannot be marked still
+// works, so failing to is not an error.
+func (n *markingNet) mark(c any) {
+	if sc, ok := c.(syscall.Conn); ok {
+		_ = Mark(sc, n.codePoint)
+	}
+}
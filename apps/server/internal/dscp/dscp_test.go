@@ -0,0 +1,79 @@
+//go:build !windows
+
+package dscp
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func tos(t *testing.T, c *net.UDPConn) int {
+	t.Helper()
+
+	rc, err := c.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v int
+
+	_ = rc.Control(func(fd uintptr) {
+		v, err = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestMark(t *testing.T) {
+	t.Parallel()
+
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	err = Mark(c, EF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := tos(t, c); got != 0xb8 {
+		t.Errorf("TOS = %#x, want 0xb8", got)
+	}
+
+	// Zero leaves the socket as it is.
+	err = Mark(c, 0)
+	if err != nil || tos(t, c) != 0xb8 {
+		t.Errorf("Mark(0) changed the TOS: %v", err)
+	}
+}
+
+func TestNet_MarksUDPSockets(t *testing.T) {
+	t.Parallel()
+
+	n, err := Net(AF21)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := n.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	u, ok := c.(*net.UDPConn)
+	if !ok {
+		t.Fatalf("conn is %T", c)
+	}
+
+	if got := tos(t, u); got != AF21<<2 {
+		t.Errorf("TOS = %#x, want %#x", got, AF21<<2)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package dscp
+
+import "golang.org/x/sys/unix"
+
+// setTOS sets both the IPv4 TOS and the IPv6 traffic class, as a dual-stack
+// socket sends both; it fails only if neither applies.
+func setTOS(fd uintptr, tos int) error {
+	err4 := unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	err6 := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+
+	if err4 != nil && err6 != nil {
+		return err4 //nolint:wrapcheck // Mark wraps it
+	}
+
+	return nil
+}
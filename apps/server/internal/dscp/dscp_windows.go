@@ -0,0 +1,7 @@
+//go:build windows
+
+package dscp
+
+// setTOS fails, as Windows ignores IP_TOS and marks traffic only by its QoS
+// policies.
+func setTOS(uintptr, int) error { return errUnsupported }
@@ -0,0 +1,281 @@
+// Package events streams what happens on the bridge, such as radios coming and
+// going and sessions opening and closing, as server-sent events. A short
+// history is kept so a client that reconnects with Last-Event-ID misses
+// nothing.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// History is how many events are kept for clients that resume.
+	History = 1024
+	// keepAlive is the longest a stream stays silent, so proxies do not
+	// close it while nothing happens.
+	keepAlive = 15 * time.Second
+	// retry is how long EventSource clients wait before reconnecting.
+	retry = 3 * time.Second
+)
+
+// TypeReset tells a resuming client that events it asked for are gone, either
+// pushed out of the history or from before the bridge restarted. The retained
+// history follows it.
+const TypeReset = "reset"
+
+// Event is one entry of the stream.
+type Event struct {
+	ID   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Bus records events and hands them to every stream. A nil *Bus drops them.
+type Bus struct {
+	mu   sync.Mutex
+	next uint64
+	// ring holds the last History events, oldest first.
+	ring []Event
+	// wake is closed, and replaced, on every Publish.
+	wake chan struct{}
+}
+
+// New returns an empty bus. IDs start from the clock, so an ID a client kept
+// across a bridge restart is older than any the new bridge hands out.
+func New() *Bus {
+	return &Bus{
+		next: uint64(time.Now().UnixMicro()), //nolint:gosec // after 1970
+		wake: make(chan struct{}),
+	}
+}
+
+// Publish records an event of type typ carrying data encoded as JSON.
+func (b *Bus) Publish(typ string, data any) {
+	if b == nil {
+		return
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		raw = nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ev := Event{ID: b.next, Type: typ, Time: time.Now(), Data: raw}
+	b.next++
+
+	if len(b.ring) == History {
+		b.ring = append(b.ring[:0], b.ring[1:]...)
+	}
+
+	b.ring = append(b.ring, ev)
+
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+// since returns the events after id, whether some were lost in between, the
+// ID of the latest event, and a channel closed on the next Publish.
+func (b *Bus) since(id uint64) ([]Event, bool, uint64, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	last := b.next - 1
+
+	oldest := b.next - uint64(len(b.ring))
+	if id > last || id+1 < oldest {
+		return append([]Event(nil), b.ring...), true, last, b.wake
+	}
+
+	skip := len(b.ring) - int(last-id) //nolint:gosec // at most History
+
+	return append([]Event(nil), b.ring[skip:]...), false, last, b.wake
+}
+
+// last returns the ID of the latest event, for a stream that starts now.
+func (b *Bus) last() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.next - 1
+}
+
+// Follow returns the events published from now on, in order, until ctx is
+// done, when the channel is closed. A follower that falls more than History
+// events behind misses those it fell behind by. A nil *Bus has none.
+func (b *Bus) Follow(ctx context.Context) <-chan Event {
+	ch := make(chan Event)
+
+	if b == nil {
+		go func() {
+			<-ctx.Done()
+			close(ch)
+		}()
+
+		return ch
+	}
+
+	cursor := b.last()
+
+	go func() {
+		defer close(ch)
+
+		for {
+			evs, _, last, wake := b.since(cursor)
+			cursor = last
+
+			for _, ev := range evs {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			}
+		}
+	}()
+
+	return ch
+}
+
+// ServeHTTP streams events as they are published. A client resumes after the
+// event named by the Last-Event-ID header, or the lastEventId query parameter
+// for the first connection; without either it gets only new events. types,
+// e.g. "session,stream", limits the stream to those types and their
+// subtypes.
+func (b *Bus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	from := r.Header.Get("Last-Event-ID")
+	if from == "" {
+		from = r.URL.Query().Get("lastEventId")
+	}
+
+	cursor := b.last()
+
+	if from != "" {
+		id, err := strconv.ParseUint(from, 10, 64)
+		if err != nil {
+			http.Error(w, "bad Last-Event-ID", http.StatusBadRequest)
+
+			return
+		}
+
+		cursor = id
+	}
+
+	match := typeFilter(r.URL.Query().Get("types"))
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", retry.Milliseconds())
+	if err != nil {
+		return
+	}
+
+	_ = rc.Flush()
+
+	tick := time.NewTicker(keepAlive)
+	defer tick.Stop()
+
+	for {
+		evs, missed, last, wake := b.since(cursor)
+		cursor = last
+
+		if missed {
+			err = writeEvent(w, Event{Type: TypeReset, Time: time.Now()})
+			if err != nil {
+				return
+			}
+		}
+
+		for _, ev := range evs {
+			if !match(ev.Type) {
+				continue
+			}
+
+			err = writeEvent(w, ev)
+			if err != nil {
+				return
+			}
+		}
+
+		if missed || len(evs) > 0 {
+			err = rc.Flush()
+			if err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-tick.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+
+			err = rc.Flush()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev Event) error {
+	b, _ := json.Marshal(ev)
+
+	var err error
+	if ev.ID == 0 {
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+	} else {
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, b)
+	}
+
+	if err != nil {
+		return fmt.Errorf("write event: %w", err)
+	}
+
+	return nil
+}
+
+// typeFilter matches an event type against a comma-separated list, where
+// "session" also matches "session.created".
+func typeFilter(list string) func(string) bool {
+	if list == "" {
+		return func(string) bool { return true }
+	}
+
+	want := strings.Split(list, ",")
+
+	return func(typ string) bool {
+		for _, w := range want {
+			w = strings.TrimSpace(w)
+			if typ == w || strings.HasPrefix(typ, w+".") {
+				return true
+			}
+		}
+
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// stream opens the event stream and returns a function reading the next
+// event's id and type.
+func stream(t *testing.T, b *Bus, query string, lastID string) func() (string, string) {
+	t.Helper()
+
+	ts := httptest.NewServer(b)
+	t.Cleanup(ts.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+
+	return func() (string, string) {
+		t.Helper()
+
+		var id, typ string
+
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = line[4:]
+			case strings.HasPrefix(line, "event: "):
+				typ = line[7:]
+			case line == "" && typ != "":
+				return id, typ
+			}
+		}
+
+		t.Fatalf("stream ended: %v", sc.Err())
+
+		return "", ""
+	}
+}
+
+func TestBus_Live(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Publish("session.created", map[string]string{"id": "old"})
+
+	next := stream(t, b, "?types=session", "")
+
+	b.Publish("radio.added", nil)
+	b.Publish("session.closed", nil)
+
+	if _, typ := next(); typ != "session.closed" {
+		t.Errorf("first event = %q; want only new session events", typ)
+	}
+}
+
+func TestBus_Follow(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Publish("session.created", nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	evs := b.Follow(ctx)
+
+	b.Publish("radio.added", nil)
+	b.Publish("session.closed", nil)
+
+	for _, want := range []string{"radio.added", "session.closed"} {
+		if ev := <-evs; ev.Type != want {
+			t.Errorf("followed %q; want %q", ev.Type, want)
+		}
+	}
+
+	cancel()
+
+	for range evs {
+		// Drained until closed.
+	}
+}
+
+func TestBus_Resume(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Publish("session.created", nil)
+	first := b.last()
+	b.Publish("stream.started", nil)
+	b.Publish("session.closed", nil)
+
+	next := stream(t, b, "", strconv.FormatUint(first, 10))
+
+	if id, typ := next(); typ != "stream.started" || id != strconv.FormatUint(first+1, 10) {
+		t.Errorf("first resumed event = %s %q", id, typ)
+	}
+
+	if _, typ := next(); typ != "session.closed" {
+		t.Errorf("second resumed event = %q", typ)
+	}
+}
+
+func TestBus_ResumeLost(t *testing.T) {
+	t.Parallel()
+
+	b := New()
+	b.Publish("session.created", nil)
+	first := b.last()
+
+	for range History {
+		b.Publish("stream.started", nil)
+	}
+
+	// The event after first-1 has been pushed out of the history.
+	next := stream(t, b, "", strconv.FormatUint(first-1, 10))
+
+	if _, typ := next(); typ != TypeReset {
+		t.Errorf("first event after a gap = %q", typ)
+	}
+
+	if id, _ := next(); id != strconv.FormatUint(first+1, 10) {
+		t.Errorf("history starts at %s, want %d", id, first+1)
+	}
+
+	// An ID from a bridge that has since restarted.
+	next = stream(t, New(), "?lastEventId=1", "")
+
+	if _, typ := next(); typ != TypeReset {
+		t.Errorf("first event after a restart = %q", typ)
+	}
+}
+
+func TestTypeFilter(t *testing.T) {
+	t.Parallel()
+
+	match := typeFilter("session, stream.started")
+
+	for typ, want := range map[string]bool{
+		"session.created": true,
+		"session":         true,
+		"sessions":        false,
+		"stream.started":  true,
+		"stream.stopped":  false,
+		"radio.added":     false,
+	} {
+		if match(typ) != want {
+			t.Errorf("%q matched %t", typ, !want)
+		}
+	}
+}
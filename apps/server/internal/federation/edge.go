@@ -0,0 +1,449 @@
+package federation
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/heartbeat"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// advertiseEvery is how often the edge looks for changes to its radios,
+	// and advertiseKeep the longest it goes without resending them.
+	advertiseEvery = time.Second
+	advertiseKeep  = 30 * time.Second
+	// maxBackoff caps the wait between attempts to reach the hub.
+	maxBackoff = 30 * time.Second
+	// chanBuffer is how many frames of commands a channel may have waiting
+	// for the radio before it is closed for falling behind.
+	chanBuffer = 256
+)
+
+// EdgeOptions configures RunEdge.
+type EdgeOptions struct {
+	// Hub is the hub bridge's base URL, e.g. https://sdr.example.com.
+	Hub string
+	// Token is the hub's federation token.
+	Token string
+	// Name tells this edge apart at the hub.
+	Name string
+	// Registry supplies the radios to serve: those heard on the LAN.
+	Registry *discovery.Registry
+}
+
+// edge is the radio side of a tunnel.
+type edge struct {
+	opt EdgeOptions
+	t   *tunnel
+
+	mu    sync.Mutex
+	chans map[uint32]*edgeChan
+	// addrs are the radio addresses last advertised, the only ones the hub
+	// may open.
+	addrs []string
+}
+
+// edgeChan is one hub session's connection to a radio.
+type edgeChan struct {
+	id    uint32
+	addr  string
+	in    chan []byte
+	done  chan struct{}
+	once  sync.Once
+	udpMu sync.Mutex
+	udp   *net.UDPConn
+	raddr *net.UDPAddr
+}
+
+// hubURL is the hub's tunnel endpoint.
+func hubURL(hub, name string) (string, error) {
+	u, err := url.Parse(hub)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("%w: %q", errHub, hub)
+	}
+
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("%w: %q", errHub, hub)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + Path
+	u.RawQuery = url.Values{"name": {name}}.Encode()
+	u.Fragment = ""
+
+	return u.String(), nil
+}
+
+// RunEdge serves this bridge's LAN radios through the hub at opt.Hub,
+// reconnecting until ctx is cancelled.
+func RunEdge(ctx context.Context, opt EdgeOptions) error {
+	if opt.Token == "" {
+		return errToken
+	}
+
+	hub, err := hubURL(opt.Hub, opt.Name)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Authorization": {"Bearer " + opt.Token}}
+	backoff := time.Duration(0)
+
+	for {
+		connected, err := runEdgeOnce(ctx, hub, header, opt)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if connected {
+			backoff = 0
+		}
+
+		backoff = min(max(2*backoff, time.Second), maxBackoff)
+		log.Printf("[federation] hub %s: %v; reconnecting in %v", opt.Hub, err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func runEdgeOnce(ctx context.Context, hub string, header http.Header, opt EdgeOptions) (bool, error) {
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, hub, header)
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+
+	if err != nil {
+		if resp != nil {
+			return false, fmt.Errorf("%w (%s)", err, resp.Status)
+		}
+
+		return false, fmt.Errorf("dial: %w", err)
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		_ = ws.Close()
+	}()
+
+	heartbeat.Keep(ctx, ws)
+	log.Printf("[federation] serving radios through %s as %q", opt.Hub, opt.Name)
+
+	e := &edge{opt: opt, t: &tunnel{ws: ws}, chans: make(map[uint32]*edgeChan)}
+	defer e.closeAll()
+
+	go e.advertise(ctx)
+
+	return true, e.serve()
+}
+
+// advertise sends the LAN radios to the hub whenever they change.
+func (e *edge) advertise(ctx context.Context) {
+	tick := time.NewTicker(advertiseEvery)
+	defer tick.Stop()
+
+	var (
+		last []byte
+		sent time.Time
+	)
+
+	for {
+		radios := lanRadios(e.opt.Registry.List(time.Now()))
+
+		b, _ := json.Marshal(radios)
+		if !slices.Equal(b, last) || time.Since(sent) >= advertiseKeep {
+			addrs := make([]string, 0, len(radios))
+			for _, rd := range radios {
+				addrs = append(addrs, rd.addr())
+			}
+
+			e.mu.Lock()
+			e.addrs = addrs
+			e.mu.Unlock()
+
+			if e.t.send(frameRadios, 0, b) != nil {
+				return
+			}
+
+			last, sent = b, time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// lanRadios are the online radios heard on the LAN; radios this bridge
+// only knows through SmartLink or another federation are not passed on.
+func lanRadios(list []discovery.Radio) []radio {
+	radios := make([]radio, 0, len(list))
+
+	for _, r := range list {
+		if !r.Online || r.Host == "" || r.Port == 0 || !slices.Contains(r.Sources, discovery.SourceLAN) {
+			continue
+		}
+
+		radios = append(radios, radio{
+			Serial:   r.Serial,
+			Model:    r.Model,
+			Nickname: r.Nickname,
+			Callsign: r.Callsign,
+			Version:  r.Version,
+			Status:   r.Status,
+			Host:     r.Host,
+			Port:     r.Port,
+		})
+	}
+
+	return radios
+}
+
+// serve handles the hub's frames until the tunnel closes.
+func (e *edge) serve() error {
+	for {
+		_, b, err := e.t.ws.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+
+		f, err := decodeFrame(b)
+		if err != nil {
+			continue
+		}
+
+		if f.kind == frameOpen {
+			e.open(f.ch, string(f.data))
+
+			continue
+		}
+
+		e.mu.Lock()
+		c := e.chans[f.ch]
+		e.mu.Unlock()
+
+		if c == nil {
+			continue
+		}
+
+		switch f.kind {
+		case frameTCP:
+			select {
+			case c.in <- f.data:
+			default:
+				log.Printf("[federation] %s: hub session %d fell behind; closing it", c.addr, c.id)
+				e.closeChan(c, "fell behind")
+			}
+		case frameUDP:
+			c.udpMu.Lock()
+			u, raddr := c.udp, c.raddr
+			c.udpMu.Unlock()
+
+			if u != nil {
+				_, _ = u.WriteToUDP(f.data, raddr)
+			}
+		case frameClose:
+			e.closeChan(c, "")
+		}
+	}
+}
+
+// open connects a channel to the radio at addr, which must be one of those
+// advertised.
+func (e *edge) open(id uint32, addr string) {
+	e.mu.Lock()
+	allowed := slices.Contains(e.addrs, addr)
+
+	c := &edgeChan{id: id, addr: addr, in: make(chan []byte, chanBuffer), done: make(chan struct{})}
+	if allowed {
+		e.chans[id] = c
+	}
+	e.mu.Unlock()
+
+	if !allowed {
+		e.t.close(id, "not a radio this edge serves")
+
+		return
+	}
+
+	go e.run(c)
+}
+
+// run dials the radio and carries the channel's TCP and UDP until it closes.
+func (e *edge) run(c *edgeChan) {
+	host, port, err := net.SplitHostPort(c.addr)
+	if err != nil {
+		e.closeChan(c, err.Error())
+
+		return
+	}
+
+	tcpPort, err := strconv.Atoi(port)
+	if err != nil {
+		e.closeChan(c, err.Error())
+
+		return
+	}
+
+	// Radios take UDP on the port after their TCP port.
+	raddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(tcpPort+1)))
+	if err != nil {
+		e.closeChan(c, err.Error())
+
+		return
+	}
+
+	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		e.closeChan(c, err.Error())
+
+		return
+	}
+
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+
+	tcp, err := dialer.Dial("tcp", c.addr)
+	if err != nil {
+		_ = u.Close()
+
+		e.closeChan(c, err.Error())
+
+		return
+	}
+
+	c.udpMu.Lock()
+	c.udp, c.raddr = u, raddr
+	c.udpMu.Unlock()
+
+	go func() {
+		<-c.done
+		_ = tcp.Close()
+		_ = u.Close()
+	}()
+
+	var udpPort [2]byte
+
+	binary.BigEndian.PutUint16(udpPort[:], uint16(u.LocalAddr().(*net.UDPAddr).Port)) //nolint:forcetypeassert,gosec // a udp port
+
+	err = e.t.send(frameOpened, c.id, udpPort[:])
+	if err != nil {
+		e.closeChan(c, "")
+
+		return
+	}
+
+	log.Printf("[federation] hub session %d opened %s", c.id, c.addr)
+
+	go e.readUDP(c, u, raddr)
+	go e.readTCP(c, tcp)
+
+	for {
+		select {
+		case data := <-c.in:
+			_, err := tcp.Write(data)
+			if err != nil {
+				e.closeChan(c, err.Error())
+
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (e *edge) readTCP(c *edgeChan, tcp net.Conn) {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := tcp.Read(buf)
+		if n > 0 && e.t.send(frameTCP, c.id, buf[:n]) != nil {
+			return
+		}
+
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				e.closeChan(c, "radio closed the connection")
+			}
+
+			return
+		}
+	}
+}
+
+// readUDP passes on the radio's packets, from any of its ports.
+func (e *edge) readUDP(c *edgeChan, u *net.UDPConn, raddr *net.UDPAddr) {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, src, err := u.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		if !src.IP.Equal(raddr.IP) {
+			continue
+		}
+
+		if e.t.send(frameUDP, c.id, buf[:n]) != nil {
+			return
+		}
+	}
+}
+
+// closeChan closes a channel. A reason is sent to the hub, which is not
+// told when it closed the channel itself.
+func (e *edge) closeChan(c *edgeChan, reason string) {
+	c.once.Do(func() {
+		e.mu.Lock()
+		delete(e.chans, c.id)
+		e.mu.Unlock()
+
+		close(c.done)
+
+		if reason != "" {
+			e.t.close(c.id, reason)
+		}
+	})
+}
+
+func (e *edge) closeAll() {
+	e.mu.Lock()
+	chans := make([]*edgeChan, 0, len(e.chans))
+	for _, c := range e.chans {
+		chans = append(chans, c)
+	}
+	e.mu.Unlock()
+
+	for _, c := range chans {
+		e.closeChan(c, "")
+	}
+}
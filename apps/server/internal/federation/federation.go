@@ -0,0 +1,160 @@
+// Package federation lets one bridge serve radios that another bridge
+// reaches. The radio-side bridge, the edge, dials the browser-side bridge,
+// the hub, so it needs only outbound connectivity: a single WebSocket,
+// over TLS when the hub serves HTTPS, carries the TCP stream and UDP
+// packets of every session on the edge's radios.
+//
+// The hub gives each of the edge's radios a loopback address, TCP on one
+// port and UDP on the next, as a radio listens, and lists it in discovery
+// at that address, so sessions reach it as they reach any other radio.
+package federation
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Path is where the hub accepts edges.
+const Path = "/api/federation"
+
+// writeWait bounds each write to the tunnel.
+const writeWait = 10 * time.Second
+
+// Frame kinds. Every frame is a binary message: the kind, the channel it
+// belongs to as a big-endian uint32, and its data.
+const (
+	// frameRadios, edge to hub on channel 0, is the edge's radio list as
+	// JSON; it replaces the last one.
+	frameRadios byte = iota + 1
+	// frameOpen, hub to edge, opens a channel to the radio at the
+	// host:port in its data.
+	frameOpen
+	// frameOpened, edge to hub, says the radio answered; its data is the
+	// edge's UDP port for the channel as a big-endian uint16.
+	frameOpened
+	// frameTCP carries radio TCP data either way.
+	frameTCP
+	// frameUDP carries one radio UDP packet either way.
+	frameUDP
+	// frameClose closes a channel either way; its data may say why.
+	frameClose
+)
+
+const frameHeader = 5
+
+var (
+	errShortFrame = errors.New("federation: short frame")
+	errToken      = errors.New("federation: token required")
+	errHub        = errors.New("federation: hub must be an http, https, ws or wss URL")
+)
+
+// radio is a radio as the edge advertises it, at the address the edge
+// reaches it on.
+type radio struct {
+	Serial   string `json:"serial"`
+	Model    string `json:"model"`
+	Nickname string `json:"nickname,omitempty"`
+	Callsign string `json:"callsign,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+}
+
+func (r radio) addr() string {
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+}
+
+type frame struct {
+	kind byte
+	ch   uint32
+	data []byte
+}
+
+func encodeFrame(kind byte, ch uint32, data []byte) []byte {
+	b := make([]byte, frameHeader+len(data))
+	b[0] = kind
+	binary.BigEndian.PutUint32(b[1:frameHeader], ch)
+	copy(b[frameHeader:], data)
+
+	return b
+}
+
+func decodeFrame(b []byte) (frame, error) {
+	if len(b) < frameHeader {
+		return frame{}, errShortFrame
+	}
+
+	return frame{kind: b[0], ch: binary.BigEndian.Uint32(b[1:frameHeader]), data: b[frameHeader:]}, nil
+}
+
+// tunnel writes frames to the WebSocket from any goroutine.
+type tunnel struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+func (t *tunnel) send(kind byte, ch uint32, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_ = t.ws.SetWriteDeadline(time.Now().Add(writeWait))
+
+	err := t.ws.WriteMessage(websocket.BinaryMessage, encodeFrame(kind, ch, data))
+	if err != nil {
+		return fmt.Errorf("federation tunnel write: %w", err)
+	}
+
+	return nil
+}
+
+func (t *tunnel) close(ch uint32, reason string) {
+	_ = t.send(frameClose, ch, []byte(reason))
+}
+
+// authorized checks a bearer token against token, which must be set.
+func authorized(token string, r *http.Request) bool {
+	if token == "" {
+		return false
+	}
+
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// listenPair listens for TCP on a free loopback port and for UDP on the
+// port after it, the way a radio listens.
+func listenPair() (net.Listener, *net.UDPConn, error) {
+	var errs []error
+
+	for range 20 {
+		ln, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, fmt.Errorf("federation listen: %w", err)
+		}
+
+		port := ln.Addr().(*net.TCPAddr).Port //nolint:forcetypeassert // a tcp listener
+
+		u, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port + 1})
+		if err == nil {
+			return ln, u, nil
+		}
+
+		_ = ln.Close()
+
+		errs = append(errs, err)
+	}
+
+	return nil, nil, fmt.Errorf("federation listen: %w", errors.Join(errs...))
+}
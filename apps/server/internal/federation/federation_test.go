@@ -0,0 +1,224 @@
+package federation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+)
+
+func TestHubURL(t *testing.T) {
+	t.Parallel()
+
+	got, err := hubURL("https://sdr.example.com/bridge/", "home shack")
+	if err != nil || got != "wss://sdr.example.com/bridge/api/federation?name=home+shack" {
+		t.Errorf("hubURL = %q, %v", got, err)
+	}
+
+	for _, bad := range []string{"sdr.example.com", "ftp://sdr.example.com"} {
+		_, err := hubURL(bad, "x")
+		if err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestHub_Auth(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct{ token, auth string }{
+		{"", "Bearer anything"},
+		{"s3cret", ""},
+		{"s3cret", "Bearer wrong"},
+	} {
+		hub := NewHub(HubOptions{Token: c.token, Registry: discovery.NewRegistry()})
+
+		r := httptest.NewRequestWithContext(t.Context(), http.MethodGet, Path+"?name=x", nil)
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+
+		w := httptest.NewRecorder()
+		hub.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("token %q, auth %q: status %d", c.token, c.auth, w.Code)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	c := &hubChan{edgeUDP: 6000}
+
+	got := string(c.rewrite([]byte("C1|sub slice all\nC2|client udpport 5000\r\n")))
+	if got != "C1|sub slice all\nC2|client udpport 6000\n" || c.udpPort.Load() != 5000 {
+		t.Errorf("rewrite = %q, session port %d", got, c.udpPort.Load())
+	}
+}
+
+// fakeRadio greets each connection as a radio does and sends one UDP packet
+// wherever it is told to.
+func fakeRadio(t *testing.T) (addr string, udp *net.UDPConn, told <-chan int) {
+	t.Helper()
+
+	ln, udp, err := listenPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close(); _ = udp.Close() })
+
+	ports := make(chan int, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = conn.Close() }()
+
+		_, _ = fmt.Fprint(conn, "V1.4.0.0\nH12345678\n")
+
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			_, port, ok := strings.Cut(sc.Text(), "client udpport ")
+			if !ok {
+				continue
+			}
+
+			n, _ := strconv.Atoi(port)
+			ports <- n
+
+			_, _ = udp.WriteToUDP([]byte("vita"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: n})
+		}
+	}()
+
+	return ln.Addr().String(), udp, ports
+}
+
+func TestFederation(t *testing.T) {
+	t.Parallel()
+
+	radioAddr, radioUDP, told := fakeRadio(t)
+	host, port, _ := net.SplitHostPort(radioAddr)
+
+	edgeReg := discovery.NewRegistry()
+	edgeReg.ObserveLAN(map[string]string{"serial": "S1", "model": "FLEX-6600", "ip": host, "port": port}, time.Now())
+
+	hubReg := discovery.NewRegistry()
+	ts := httptest.NewServer(NewHub(HubOptions{Token: "s3cret", Registry: hubReg}))
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+
+	go func() {
+		_ = RunEdge(ctx, EdgeOptions{Hub: ts.URL, Token: "s3cret", Name: "shack", Registry: edgeReg})
+	}()
+
+	var served discovery.Radio
+
+	deadline := time.Now().Add(5 * time.Second)
+	for served.Serial == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("radio not federated")
+		}
+
+		for _, r := range hubReg.List(time.Now()) {
+			if slices.Contains(r.Sources, discovery.SourceFederation) {
+				served = r
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if served.Host != "127.0.0.1" || !served.Online || served.Model != "FLEX-6600" {
+		t.Fatalf("served as %+v", served)
+	}
+
+	// A session connects as to any radio.
+	conn, err := net.Dial("tcp", net.JoinHostPort(served.Host, strconv.Itoa(served.Port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	rd := bufio.NewReader(conn)
+
+	for _, want := range []string{"V1.4.0.0\n", "H12345678\n"} {
+		line, err := rd.ReadString('\n')
+		if err != nil || line != want {
+			t.Fatalf("handshake %q, %v", line, err)
+		}
+	}
+
+	session, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = session.Close() })
+
+	sessionPort := session.LocalAddr().(*net.UDPAddr).Port //nolint:forcetypeassert // udp listener
+	_, _ = fmt.Fprintf(conn, "C1|client udpport %d\n", sessionPort)
+
+	select {
+	case got := <-told:
+		if got == sessionPort {
+			t.Error("radio told the session's port, not the edge's")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("udpport command not relayed")
+	}
+
+	// The radio's packet reaches the session from the radio's UDP address.
+	_ = session.SetDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 64)
+
+	n, src, err := session.ReadFromUDP(buf)
+	if err != nil || string(buf[:n]) != "vita" || src.Port != served.Port+1 {
+		t.Fatalf("session got %q from %v, %v", buf[:n], src, err)
+	}
+
+	// And the session's packets reach the radio.
+	_, err = session.WriteToUDP([]byte("tx"), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = radioUDP.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	n, _, err = radioUDP.ReadFromUDP(buf)
+	if err != nil || string(buf[:n]) != "tx" {
+		t.Fatalf("radio got %q, %v", buf[:n], err)
+	}
+
+	// When the edge goes, so do its radios.
+	cancel()
+
+	deadline = time.Now().Add(5 * time.Second)
+	for len(hubReg.List(time.Now())) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("radio still listed after the edge went")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,434 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/heartbeat"
+	"github.com/gorilla/websocket"
+)
+
+// reUDPPort matches the command a session uses to tell the radio where to
+// send its UDP.
+var reUDPPort = regexp.MustCompile(`^(C\d+\|client udpport )(\d+)\s*$`)
+
+// HubOptions configures a Hub.
+type HubOptions struct {
+	// Token is the bearer token edges present. Without one, every edge is
+	// refused.
+	Token string
+	// Registry lists the edges' radios alongside the others.
+	Registry *discovery.Registry
+}
+
+// Hub accepts edges and serves their radios on loopback proxies.
+type Hub struct {
+	opt HubOptions
+	up  websocket.Upgrader
+
+	mu    sync.Mutex
+	edges map[string]*edgeLink
+}
+
+// NewHub returns a hub; serve it at Path.
+func NewHub(opt HubOptions) *Hub {
+	return &Hub{opt: opt, edges: make(map[string]*edgeLink)}
+}
+
+// edgeLink is the hub's end of one edge's tunnel.
+type edgeLink struct {
+	hub  *Hub
+	name string
+	t    *tunnel
+
+	mu      sync.Mutex
+	proxies map[string]*proxy // by serial
+	chans   map[uint32]*hubChan
+	nextCh  uint32
+}
+
+// proxy listens where sessions reach one of an edge's radios.
+type proxy struct {
+	link  *edgeLink
+	radio radio
+	ln    net.Listener
+	udp   *net.UDPConn
+}
+
+// hubChan is one session's connection to a radio through the tunnel.
+type hubChan struct {
+	id    uint32
+	proxy *proxy
+	conn  net.Conn
+	// ready is closed when the edge has reached the radio, and edgeUDP set.
+	ready   chan struct{}
+	opened  sync.Once
+	edgeUDP int
+	// udpPort is the session's UDP port, once it has told the radio.
+	udpPort atomic.Int32
+	once    sync.Once
+}
+
+// ServeHTTP accepts an edge's tunnel. The edge names itself in the name
+// query parameter; a second edge by the same name replaces the first.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorized(h.opt.Token, r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="federation"`)
+		http.Error(w, "federation not authorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "federation: name required", http.StatusBadRequest)
+
+		return
+	}
+
+	ws, err := h.up.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	heartbeat.Keep(ctx, ws)
+
+	link := &edgeLink{
+		hub:     h,
+		name:    name,
+		t:       &tunnel{ws: ws},
+		proxies: make(map[string]*proxy),
+		chans:   make(map[uint32]*hubChan),
+	}
+
+	h.mu.Lock()
+	prev := h.edges[name]
+	h.edges[name] = link
+	h.mu.Unlock()
+
+	if prev != nil {
+		_ = prev.t.ws.Close()
+	}
+
+	log.Printf("[federation] edge %q connected from %s", name, r.RemoteAddr)
+
+	link.serve()
+
+	h.mu.Lock()
+	if h.edges[name] == link {
+		delete(h.edges, name)
+		h.opt.Registry.ReplaceFederated(name, nil, time.Now())
+	}
+	h.mu.Unlock()
+
+	link.closeAll()
+	log.Printf("[federation] edge %q disconnected", name)
+}
+
+// serve handles the edge's frames until the tunnel closes.
+func (l *edgeLink) serve() {
+	for {
+		_, b, err := l.t.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		f, err := decodeFrame(b)
+		if err != nil {
+			continue
+		}
+
+		if f.kind == frameRadios {
+			l.setRadios(f.data)
+
+			continue
+		}
+
+		l.mu.Lock()
+		c := l.chans[f.ch]
+		l.mu.Unlock()
+
+		if c == nil {
+			continue
+		}
+
+		switch f.kind {
+		case frameOpened:
+			if len(f.data) == 2 {
+				c.opened.Do(func() {
+					c.edgeUDP = int(binary.BigEndian.Uint16(f.data))
+					close(c.ready)
+				})
+			}
+		case frameTCP:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+			_, err := c.conn.Write(f.data)
+			if err != nil {
+				l.closeChan(c, true)
+			}
+		case frameUDP:
+			if port := int(c.udpPort.Load()); port != 0 {
+				_, _ = c.proxy.udp.WriteToUDP(f.data, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+			}
+		case frameClose:
+			if len(f.data) > 0 {
+				log.Printf("[federation] %s via %q: %s", c.proxy.radio.addr(), l.name, f.data)
+			}
+
+			l.closeChan(c, false)
+		}
+	}
+}
+
+// setRadios replaces the edge's radios with the list it sent: new ones get
+// a proxy, gone ones lose theirs, and discovery lists the result.
+func (l *edgeLink) setRadios(data []byte) {
+	var radios []radio
+
+	err := json.Unmarshal(data, &radios)
+	if err != nil {
+		log.Printf("[federation] edge %q sent a bad radio list: %v", l.name, err)
+
+		return
+	}
+
+	seen := make(map[string]bool, len(radios))
+	listed := make([]discovery.Radio, 0, len(radios))
+
+	l.mu.Lock()
+	for _, rd := range radios {
+		if rd.Serial == "" {
+			continue
+		}
+
+		seen[rd.Serial] = true
+
+		p := l.proxies[rd.Serial]
+		if p == nil || p.radio.addr() != rd.addr() {
+			if p != nil {
+				p.close()
+			}
+
+			p, err = l.listen(rd)
+			if err != nil {
+				log.Printf("[federation] %s via %q: %v", rd.Serial, l.name, err)
+				delete(l.proxies, rd.Serial)
+
+				continue
+			}
+
+			l.proxies[rd.Serial] = p
+		}
+
+		listed = append(listed, discovery.Radio{
+			Serial:   rd.Serial,
+			Model:    rd.Model,
+			Nickname: rd.Nickname,
+			Callsign: rd.Callsign,
+			Version:  rd.Version,
+			Status:   rd.Status,
+			Host:     "127.0.0.1",
+			Port:     p.ln.Addr().(*net.TCPAddr).Port, //nolint:forcetypeassert // a tcp listener
+		})
+	}
+
+	for serial, p := range l.proxies {
+		if !seen[serial] {
+			p.close()
+			delete(l.proxies, serial)
+		}
+	}
+	l.mu.Unlock()
+
+	l.hub.opt.Registry.ReplaceFederated(l.name, listed, time.Now())
+}
+
+// listen starts a proxy for rd; l.mu is held.
+func (l *edgeLink) listen(rd radio) (*proxy, error) {
+	ln, udp, err := listenPair()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &proxy{link: l, radio: rd, ln: ln, udp: udp}
+
+	go p.accept()
+	go p.readUDP()
+
+	log.Printf("[federation] serving %s via %q on %s", rd.Serial, l.name, ln.Addr())
+
+	return p, nil
+}
+
+func (p *proxy) close() {
+	_ = p.ln.Close()
+	_ = p.udp.Close()
+}
+
+// accept opens a channel through the tunnel for each session that connects.
+func (p *proxy) accept() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		l := p.link
+
+		l.mu.Lock()
+		l.nextCh++
+		c := &hubChan{id: l.nextCh, proxy: p, conn: conn, ready: make(chan struct{})}
+		l.chans[c.id] = c
+		l.mu.Unlock()
+
+		err = l.t.send(frameOpen, c.id, []byte(p.radio.addr()))
+		if err != nil {
+			l.closeChan(c, false)
+
+			continue
+		}
+
+		go l.pumpTCP(c)
+	}
+}
+
+// pumpTCP sends the session's commands to the edge, a line at a time once
+// the radio has answered, pointing its UDP at the edge's socket.
+func (l *edgeLink) pumpTCP(c *hubChan) {
+	defer l.closeChan(c, true)
+
+	select {
+	case <-c.ready:
+	case <-time.After(30 * time.Second):
+		return
+	}
+
+	var pending []byte
+
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			return
+		}
+
+		pending = append(pending, buf[:n]...)
+
+		end := bytes.LastIndexByte(pending, '\n')
+		if end < 0 {
+			continue
+		}
+
+		out := c.rewrite(pending[:end+1])
+		pending = append([]byte(nil), pending[end+1:]...)
+
+		err = l.t.send(frameTCP, c.id, out)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// rewrite notes the session's UDP port and swaps in the edge's.
+func (c *hubChan) rewrite(lines []byte) []byte {
+	var out []byte
+
+	for line := range bytes.Lines(lines) {
+		m := reUDPPort.FindSubmatch(line)
+		if m == nil {
+			out = append(out, line...)
+
+			continue
+		}
+
+		port, _ := strconv.Atoi(string(m[2]))
+		c.udpPort.Store(int32(port)) //nolint:gosec // a port
+
+		out = append(out, m[1]...)
+		out = strconv.AppendInt(out, int64(c.edgeUDP), 10)
+		out = append(out, '\n')
+	}
+
+	return out
+}
+
+// readUDP sends sessions' UDP packets for the radio to the edge, on the
+// channel of the session they came from.
+func (p *proxy) readUDP() {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, src, err := p.udp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		if c := p.link.chanForUDP(p, src.Port); c != nil {
+			_ = p.link.t.send(frameUDP, c.id, buf[:n])
+		}
+	}
+}
+
+func (l *edgeLink) chanForUDP(p *proxy, port int) *hubChan {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, c := range l.chans {
+		if c.proxy == p && int(c.udpPort.Load()) == port {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// closeChan closes a session's channel, telling the edge when tell is set.
+func (l *edgeLink) closeChan(c *hubChan, tell bool) {
+	c.once.Do(func() {
+		l.mu.Lock()
+		delete(l.chans, c.id)
+		l.mu.Unlock()
+
+		_ = c.conn.Close()
+
+		if tell {
+			l.t.close(c.id, "")
+		}
+	})
+}
+
+func (l *edgeLink) closeAll() {
+	l.mu.Lock()
+	chans := make([]*hubChan, 0, len(l.chans))
+	for _, c := range l.chans {
+		chans = append(chans, c)
+	}
+
+	for serial, p := range l.proxies {
+		p.close()
+		delete(l.proxies, serial)
+	}
+	l.mu.Unlock()
+
+	for _, c := range chans {
+		l.closeChan(c, false)
+	}
+}
@@ -0,0 +1,319 @@
+// Package flac encodes audio as FLAC a frame at a time, so lossless audio
+// can be streamed as it arrives. It writes the subset a stream needs: the
+// "fLaC" marker and STREAMINFO, then variable-blocksize frames of
+// independent channels, each subframe constant, verbatim, or coded with the
+// best of FLAC's fixed predictors and a single Rice partition.
+package flac
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxBlock is the most samples per channel a frame holds.
+	MaxBlock = 65535
+	// maxRice is the largest Rice parameter; 15 is the escape code.
+	maxRice = 14
+	// maxOrder is the highest fixed predictor order.
+	maxOrder = 4
+)
+
+var (
+	errFormat = errors.New("flac: unsupported format")
+	errBlock  = errors.New("flac: bad block")
+)
+
+// Encoder frames one stream. Frames must be written in order.
+type Encoder struct {
+	rate     int
+	channels int
+	bits     int
+	// next is the number of the first sample in the next frame.
+	next uint64
+}
+
+// NewEncoder returns an encoder for rate Hz audio of channels channels,
+// with samples of bits bits.
+func NewEncoder(rate, channels, bits int) (*Encoder, error) {
+	if rate < 1 || rate > 655350 || channels < 1 || channels > 8 || bits < 4 || bits > 32 {
+		return nil, fmt.Errorf("%w: %d Hz, %d channels, %d bits", errFormat, rate, channels, bits)
+	}
+
+	return &Encoder{rate: rate, channels: channels, bits: bits}, nil
+}
+
+// Header is the stream's "fLaC" marker and its STREAMINFO block, which the
+// frames follow. The lengths and checksum of a live stream are unknown and
+// left zero.
+func (e *Encoder) Header() []byte {
+	var w bitWriter
+
+	w.writeBytes([]byte("fLaC"))
+	w.write(1, 1)   // last metadata block
+	w.write(0, 7)   // STREAMINFO
+	w.write(34, 24) // block length
+	w.write(16, 16) // minimum block size
+	w.write(MaxBlock, 16)
+	w.write(0, 24)                   // minimum frame size: unknown
+	w.write(0, 24)                   // maximum frame size: unknown
+	w.write(uint64(e.rate), 20)      //nolint:gosec // checked in NewEncoder
+	w.write(uint64(e.channels-1), 3) //nolint:gosec // checked in NewEncoder
+	w.write(uint64(e.bits-1), 5)     //nolint:gosec // checked in NewEncoder
+	w.write(0, 36)                   // total samples: unknown
+	w.writeBytes(make([]byte, 16))   // MD5: unknown
+
+	return w.buf
+}
+
+// Frame encodes a block: one slice of samples per channel, all the same
+// length.
+func (e *Encoder) Frame(samples [][]int32) ([]byte, error) {
+	if len(samples) != e.channels || len(samples[0]) == 0 || len(samples[0]) > MaxBlock {
+		return nil, fmt.Errorf("%w: %d channels", errBlock, len(samples))
+	}
+
+	n := len(samples[0])
+	for _, ch := range samples {
+		if len(ch) != n {
+			return nil, fmt.Errorf("%w: channels of %d and %d samples", errBlock, n, len(ch))
+		}
+	}
+
+	var w bitWriter
+
+	w.write(0xFFF9, 16)              // sync, variable block size
+	w.write(0x7, 4)                  // block size-1 follows in 16 bits
+	w.write(0, 4)                    // sample rate from STREAMINFO
+	w.write(uint64(e.channels-1), 4) //nolint:gosec // checked in NewEncoder
+	w.write(0, 3)                    // sample size from STREAMINFO
+	w.write(0, 1)                    // reserved
+	w.writeBytes(appendCoded(nil, e.next))
+	w.write(uint64(n-1), 16) //nolint:gosec // at most MaxBlock
+	w.writeBytes([]byte{crc8(w.buf)})
+
+	for _, ch := range samples {
+		e.subframe(&w, ch)
+	}
+
+	w.align()
+	crc := crc16(w.buf)
+	w.write(uint64(crc), 16)
+
+	e.next += uint64(n)
+
+	return w.buf, nil
+}
+
+// subframe writes one channel in whichever coding is smallest.
+func (e *Encoder) subframe(w *bitWriter, x []int32) {
+	bps := uint(e.bits) //nolint:gosec // checked in NewEncoder
+
+	constant := true
+	for _, s := range x[1:] {
+		if s != x[0] {
+			constant = false
+
+			break
+		}
+	}
+
+	if constant {
+		w.write(0, 8) // CONSTANT
+		w.write(uint64(uint32(x[0])), bps)
+
+		return
+	}
+
+	best, bestK, bestCost := -1, uint(0), uint64(bps)*uint64(len(x))
+	res := make([][]int64, maxOrder+1)
+
+	for order := 0; order <= maxOrder && order < len(x); order++ {
+		res[order] = residuals(x, order)
+		k, cost := riceParam(res[order])
+
+		cost += uint64(order)*uint64(bps) + 10 //nolint:gosec // small
+		if cost < bestCost {
+			best, bestK, bestCost = order, k, cost
+		}
+	}
+
+	if best < 0 {
+		w.write(0x02, 8) // VERBATIM
+		for _, s := range x {
+			w.write(uint64(uint32(s)), bps)
+		}
+
+		return
+	}
+
+	w.write(uint64(0x08|best)<<1, 8) //nolint:gosec // FIXED, order 0 to 4
+	for _, s := range x[:best] {
+		w.write(uint64(uint32(s)), bps)
+	}
+
+	w.write(0, 2) // Rice, 4-bit parameters
+	w.write(0, 4) // partition order 0
+	w.write(uint64(bestK), 4)
+
+	for _, r := range res[best] {
+		u := zigzag(r)
+		w.unary(u >> bestK)
+		w.write(u, bestK)
+	}
+}
+
+// residuals are x less the prediction of the fixed predictor of order.
+func residuals(x []int32, order int) []int64 {
+	r := make([]int64, 0, len(x)-order)
+
+	for i := order; i < len(x); i++ {
+		s := int64(x[i])
+
+		switch order {
+		case 1:
+			s -= int64(x[i-1])
+		case 2:
+			s -= 2*int64(x[i-1]) - int64(x[i-2])
+		case 3:
+			s -= 3*int64(x[i-1]) - 3*int64(x[i-2]) + int64(x[i-3])
+		case 4:
+			s -= 4*int64(x[i-1]) - 6*int64(x[i-2]) + 4*int64(x[i-3]) - int64(x[i-4])
+		}
+
+		r = append(r, s)
+	}
+
+	return r
+}
+
+// riceParam is the Rice parameter coding r in the fewest bits, and that
+// many bits.
+func riceParam(r []int64) (uint, uint64) {
+	bestK, best := uint(0), ^uint64(0)
+
+	for k := uint(0); k <= maxRice; k++ {
+		cost := uint64(len(r)) * uint64(1+k)
+		for _, v := range r {
+			cost += zigzag(v) >> k
+		}
+
+		if cost < best {
+			bestK, best = k, cost
+		}
+	}
+
+	return bestK, best
+}
+
+// zigzag maps signed residuals to unsigned: 0, -1, 1, -2 to 0, 1, 2, 3.
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63)) //nolint:gosec // the point
+}
+
+// appendCoded appends v in FLAC's UTF-8-like coding of frame and sample
+// numbers.
+func appendCoded(b []byte, v uint64) []byte {
+	if v < 0x80 {
+		return append(b, byte(v))
+	}
+
+	n := 2
+	for n < 7 && v >= 1<<(5*n+1) {
+		n++
+	}
+
+	b = append(b, ^byte(0xFF>>n)|byte(v>>(6*(n-1))))
+	for i := n - 2; i >= 0; i-- {
+		b = append(b, 0x80|byte(v>>(6*i))&0x3F)
+	}
+
+	return b
+}
+
+// crc8 is FLAC's frame header checksum: polynomial 0x07, initial 0.
+func crc8(b []byte) byte {
+	var c byte
+
+	for _, x := range b {
+		c ^= x
+		for range 8 {
+			if c&0x80 != 0 {
+				c = c<<1 ^ 0x07
+			} else {
+				c <<= 1
+			}
+		}
+	}
+
+	return c
+}
+
+// crc16 is FLAC's frame checksum: polynomial 0x8005, initial 0.
+func crc16(b []byte) uint16 {
+	var c uint16
+
+	for _, x := range b {
+		c ^= uint16(x) << 8
+		for range 8 {
+			if c&0x8000 != 0 {
+				c = c<<1 ^ 0x8005
+			} else {
+				c <<= 1
+			}
+		}
+	}
+
+	return c
+}
+
+// bitWriter packs values most significant bit first.
+type bitWriter struct {
+	buf []byte
+	acc uint64
+	n   uint
+}
+
+// write appends the low bits bits of v, at most 32 at a time.
+func (w *bitWriter) write(v uint64, n uint) {
+	for n > 32 {
+		n -= 32
+		w.write(v>>n, 32)
+	}
+
+	if n == 0 {
+		return
+	}
+
+	w.acc = w.acc<<n | v&(1<<n-1)
+	w.n += n
+
+	for w.n >= 8 {
+		w.n -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.n))
+	}
+
+	w.acc &= 1<<w.n - 1
+}
+
+// unary appends q zeros and a one.
+func (w *bitWriter) unary(q uint64) {
+	for ; q >= 32; q -= 32 {
+		w.write(0, 32)
+	}
+
+	w.write(1, uint(q)+1)
+}
+
+func (w *bitWriter) writeBytes(b []byte) {
+	for _, x := range b {
+		w.write(uint64(x), 8)
+	}
+}
+
+// align pads with zeros to a whole byte.
+func (w *bitWriter) align() {
+	if w.n > 0 {
+		w.write(0, 8-w.n)
+	}
+}
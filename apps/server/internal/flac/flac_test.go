@@ -0,0 +1,204 @@
+package flac
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// bitReader reads what bitWriter writes, for decoding in tests.
+type bitReader struct {
+	b   []byte
+	pos uint
+}
+
+func (r *bitReader) read(n uint) uint64 {
+	var v uint64
+
+	for range n {
+		bit := r.b[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+
+	return v
+}
+
+func (r *bitReader) signed(n uint) int64 {
+	v := r.read(n)
+
+	return int64(v<<(64-n)) >> (64 - n) //nolint:gosec // sign extension
+}
+
+// decodeFrame decodes the subset Frame writes, checking both checksums.
+func decodeFrame(t *testing.T, b []byte, channels int, bps uint) (uint64, [][]int32) {
+	t.Helper()
+
+	if got := crc16(b[:len(b)-2]); got != uint16(b[len(b)-2])<<8|uint16(b[len(b)-1]) {
+		t.Fatalf("frame CRC %04x", got)
+	}
+
+	r := &bitReader{b: b}
+	if r.read(16) != 0xFFF9 || r.read(4) != 7 || r.read(4) != 0 || int(r.read(4)) != channels-1 || r.read(4) != 0 {
+		t.Fatalf("frame header % x", b[:4])
+	}
+
+	// The coded sample number.
+	first := r.read(8)
+	n := bitsLen(first)
+	num := first & (0xFF >> (n + 1))
+
+	for range max(n, 1) - 1 {
+		num = num<<6 | r.read(8)&0x3F
+	}
+
+	size := int(r.read(16)) + 1
+	if crc := crc8(b[:r.pos/8]); crc != byte(r.read(8)) {
+		t.Fatal("header CRC")
+	}
+
+	out := make([][]int32, channels)
+
+	for c := range out {
+		r.read(1)
+		typ := r.read(6)
+		r.read(1)
+
+		x := make([]int32, 0, size)
+
+		switch {
+		case typ == 0:
+			s := int32(r.signed(bps)) //nolint:gosec // bps bits
+			for range size {
+				x = append(x, s)
+			}
+		case typ == 1:
+			for range size {
+				x = append(x, int32(r.signed(bps))) //nolint:gosec // bps bits
+			}
+		case typ&0x38 == 0x08:
+			order := int(typ & 7)
+			for range order {
+				x = append(x, int32(r.signed(bps))) //nolint:gosec // bps bits
+			}
+
+			if r.read(2) != 0 || r.read(4) != 0 {
+				t.Fatal("residual coding")
+			}
+
+			k := uint(r.read(4))
+
+			for i := order; i < size; i++ {
+				var q uint64
+				for r.read(1) == 0 {
+					q++
+				}
+
+				u := q<<k | r.read(k)
+				res := int64(u>>1) ^ -int64(u&1) //nolint:gosec // zigzag
+
+				var pred int64
+
+				switch order {
+				case 1:
+					pred = int64(x[i-1])
+				case 2:
+					pred = 2*int64(x[i-1]) - int64(x[i-2])
+				case 3:
+					pred = 3*int64(x[i-1]) - 3*int64(x[i-2]) + int64(x[i-3])
+				case 4:
+					pred = 4*int64(x[i-1]) - 6*int64(x[i-2]) + 4*int64(x[i-3]) - int64(x[i-4])
+				}
+
+				x = append(x, int32(pred+res)) //nolint:gosec // fits by construction
+			}
+		default:
+			t.Fatalf("subframe type %d", typ)
+		}
+
+		out[c] = x
+	}
+
+	return num, out
+}
+
+// bitsLen is the number of leading ones in a coded number's first byte.
+func bitsLen(b uint64) uint {
+	var n uint
+	for b&0x80 != 0 {
+		n++
+		b <<= 1
+	}
+
+	return n
+}
+
+func TestEncoder_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	e, err := NewEncoder(24000, 2, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hdr := e.Header()
+	if !bytes.HasPrefix(hdr, []byte("fLaC")) || len(hdr) != 4+4+34 {
+		t.Fatalf("header % x", hdr)
+	}
+
+	// A tone and noise, then silence; each wants a different coding.
+	tone := make([]int32, 256)
+	noise := make([]int32, 256)
+	seed := uint32(1)
+
+	for i := range tone {
+		tone[i] = int32(4e6 * math.Sin(float64(i)/7))
+		seed = seed*1664525 + 1013904223
+		noise[i] = int32(seed>>8) - 1<<23 //nolint:gosec // 24 bits
+	}
+
+	var at uint64
+
+	for _, block := range [][][]int32{{tone, noise}, {make([]int32, 128), tone[:128]}} {
+		b, err := e.Frame(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		num, got := decodeFrame(t, b, 2, 24)
+		if num != at {
+			t.Errorf("frame at sample %d, want %d", num, at)
+		}
+
+		for c := range block {
+			if !slices.Equal(got[c], block[c]) {
+				t.Errorf("channel %d differs after decoding", c)
+			}
+		}
+
+		at += uint64(len(block[0]))
+	}
+
+	_, err = e.Frame([][]int32{tone, noise[:10]})
+	if !errors.Is(err, errBlock) {
+		t.Errorf("ragged block: err = %v", err)
+	}
+}
+
+func TestAppendCoded(t *testing.T) {
+	t.Parallel()
+
+	for v, want := range map[uint64][]byte{
+		0x7F:    {0x7F},
+		0x80:    {0xC2, 0x80},
+		0x7FF:   {0xDF, 0xBF},
+		0x800:   {0xE0, 0xA0, 0x80},
+		0x10000: {0xF0, 0x90, 0x80, 0x80},
+	} {
+		if got := appendCoded(nil, v); !bytes.Equal(got, want) {
+			t.Errorf("%#x coded % x, want % x", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	bridgev1 "github.com/daveisadork/solid-sdr/apps/server/proto/solidsdr/bridge/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// meterClassCode marks the radio's meter packets.
+const meterClassCode = 0x8002
+
+// Connect runs one radio session. Responses are sent from this goroutine
+// only; requests are read on another and applied as they arrive.
+func (s *Server) Connect(stream bridgev1.Bridge_ConnectServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err //nolint:wrapcheck // already a gRPC status
+	}
+
+	open := first.GetOpen()
+	if open == nil {
+		return status.Error(codes.InvalidArgument, "the first request must be open")
+	}
+
+	h, err := s.opt.RTC.OpenHeadless(ctx, rtc.HeadlessOptions{
+		Radio:        open.GetRadio(),
+		ClientIP:     clientIP(ctx),
+		UDP:          open.GetUdp(),
+		Sandbox:      open.GetSandbox(),
+		Station:      open.GetStation(),
+		BindClientID: open.GetBindClientId(),
+	})
+	if err != nil {
+		return openError(err)
+	}
+
+	defer h.Close()
+
+	err = stream.Send(&bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Connected{
+		Connected: &bridgev1.Connected{SessionId: h.ID(), Handle: h.Handle(), ClientId: h.ClientID()},
+	}})
+	if err != nil {
+		return err //nolint:wrapcheck // already a gRPC status
+	}
+
+	recvErr := make(chan error, 1)
+
+	go func() { recvErr <- receive(stream, h) }()
+
+	audio := h.Audio()
+
+	for {
+		var resp *bridgev1.ConnectResponse
+
+		select {
+		case line := <-h.Lines():
+			resp = lineResponse(line)
+		case pkt := <-h.Packets():
+			resp = packetResponse(pkt)
+		case opus, ok := <-audio:
+			if !ok {
+				audio = nil
+
+				continue
+			}
+
+			resp = &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Audio{
+				Audio: &bridgev1.Audio{Opus: opus},
+			}}
+		case n := <-h.Notices():
+			resp = &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Signal{
+				Signal: &bridgev1.Signal{Type: n.Type, Payload: n.Payload},
+			}}
+		case err := <-recvErr:
+			return err
+		case <-h.Done():
+			return status.Error(codes.Unavailable, "radio session ended")
+		}
+
+		err = stream.Send(resp)
+		if err != nil {
+			return err //nolint:wrapcheck // already a gRPC status
+		}
+	}
+}
+
+// receive applies the client's requests until it closes its side, which
+// ends the session cleanly.
+func receive(stream bridgev1.Bridge_ConnectServer, h *rtc.Headless) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err //nolint:wrapcheck // already a gRPC status
+		}
+
+		switch r := req.GetRequest().(type) {
+		case *bridgev1.ConnectRequest_Command:
+			cmd := r.Command.GetCommand()
+			if strings.ContainsAny(cmd, "\r\n") {
+				return status.Error(codes.InvalidArgument, "a command is one line")
+			}
+
+			err = h.Command(fmt.Sprintf("C%d|%s", r.Command.GetSequence(), cmd))
+			if err != nil {
+				return status.Error(codes.Unavailable, err.Error())
+			}
+		case *bridgev1.ConnectRequest_Signal:
+			err = h.Signal(r.Signal.GetType(), r.Signal.GetPayload())
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+		case *bridgev1.ConnectRequest_Open:
+			return status.Error(codes.FailedPrecondition, "the session is already open")
+		default:
+			return status.Error(codes.InvalidArgument, "empty request")
+		}
+	}
+}
+
+// openError maps a session refusal onto the gRPC code nearest its HTTP
+// status on the signaling endpoint.
+func openError(err error) error {
+	var perr interface{ HTTPStatus() int }
+	if !errors.As(err, &perr) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+
+	code := codes.Unavailable
+
+	switch perr.HTTPStatus() {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	}
+
+	return status.Error(code, err.Error())
+}
+
+// lineResponse parses a line from the radio: a reply, a status or a message,
+// or else passes it on whole.
+func lineResponse(line string) *bridgev1.ConnectResponse {
+	line = strings.TrimRight(line, "\r\n")
+
+	if line == "" {
+		return &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Line{Line: &bridgev1.Line{}}}
+	}
+
+	head, rest, ok := strings.Cut(line[1:], "|")
+	if ok {
+		switch line[0] {
+		case 'R':
+			if r := reply(head, rest); r != nil {
+				return &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Reply{Reply: r}}
+			}
+		case 'S':
+			return &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Status{Status: statusLine(head, rest, line)}}
+		case 'M':
+			code, _ := strconv.ParseUint(head, 16, 32)
+
+			return &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Message{
+				Message: &bridgev1.RadioMessage{Code: uint32(code), Text: rest},
+			}}
+		}
+	}
+
+	return &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Line{Line: &bridgev1.Line{Raw: line}}}
+}
+
+// reply parses "R<seq>|<hex code>|<message>".
+func reply(seq, rest string) *bridgev1.Reply {
+	n, err := strconv.ParseUint(seq, 10, 32)
+	if err != nil {
+		return nil
+	}
+
+	hex, msg, _ := strings.Cut(rest, "|")
+
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil
+	}
+
+	return &bridgev1.Reply{Sequence: uint32(n), Code: uint32(code), Message: msg}
+}
+
+// statusLine splits a status body into the words naming its object and the
+// key=value attributes after them.
+func statusLine(handle, body, raw string) *bridgev1.Status {
+	st := &bridgev1.Status{Handle: handle, Raw: raw, Attributes: make(map[string]string)}
+
+	var object []string
+
+	for _, w := range strings.Fields(body) {
+		k, v, ok := strings.Cut(w, "=")
+		if !ok {
+			if len(st.Attributes) == 0 {
+				object = append(object, w)
+			}
+
+			continue
+		}
+
+		st.Attributes[k] = v
+	}
+
+	st.Object = strings.Join(object, " ")
+
+	return st
+}
+
+// packetResponse decodes meter packets, pairs of a 16-bit meter id and a
+// signed 16-bit value, and passes other packets on whole.
+func packetResponse(p rtc.Packet) *bridgev1.ConnectResponse {
+	if p.ClassCode != meterClassCode {
+		return &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Packet{Packet: &bridgev1.Packet{
+			ClassCode: uint32(p.ClassCode), StreamId: p.StreamID, Payload: p.Payload,
+		}}}
+	}
+
+	m := &bridgev1.Meters{}
+
+	for b := p.Payload; len(b) >= 4; b = b[4:] {
+		m.Meters = append(m.Meters, &bridgev1.Meter{
+			Id:    uint32(binary.BigEndian.Uint16(b)),
+			Value: int32(int16(binary.BigEndian.Uint16(b[2:]))), //nolint:gosec // signed on the wire
+		})
+	}
+
+	return &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Meters{Meters: m}}
+}
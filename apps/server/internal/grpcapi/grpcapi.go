@@ -0,0 +1,213 @@
+// Package grpcapi serves the bridge's gRPC API, defined in
+// proto/solidsdr/bridge/v1/bridge.proto, for native clients and automation:
+// the radio and session lists, and radio sessions that send commands and
+// receive parsed status, meters and audio.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=../../proto --go_opt=paths=source_relative --go-grpc_out=../../proto --go-grpc_opt=paths=source_relative solidsdr/bridge/v1/bridge.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	bridgev1 "github.com/daveisadork/solid-sdr/apps/server/proto/solidsdr/bridge/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Options configures the server.
+type Options struct {
+	RTC      *rtc.Server
+	Registry *discovery.Registry
+	// Token is the admin token ListSessions requires, as the admin API does;
+	// when empty, only loopback clients may list sessions.
+	Token string
+	// Allowed filters client addresses, e.g. by the bridge's ACL; nil allows
+	// everyone.
+	Allowed func(net.IP) bool
+	// TLS serves gRPC over TLS; nil serves plaintext (h2c).
+	TLS *tls.Config
+}
+
+// Server is the gRPC API.
+type Server struct {
+	bridgev1.UnimplementedBridgeServer
+
+	opt  Options
+	grpc *grpc.Server
+}
+
+func New(opt Options) *Server {
+	s := &Server{opt: opt}
+
+	sopts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryAccess),
+		grpc.StreamInterceptor(s.streamAccess),
+	}
+	if opt.TLS != nil {
+		sopts = append(sopts, grpc.Creds(credentials.NewTLS(opt.TLS)))
+	}
+
+	s.grpc = grpc.NewServer(sopts...)
+	bridgev1.RegisterBridgeServer(s.grpc, s)
+
+	return s
+}
+
+// ListenAndServe serves clients on addr until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("grpc listen: %w", err)
+	}
+
+	log.Printf("[grpc] listening on %s", ln.Addr())
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts clients on ln until ctx is done, then ends every stream.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, s.grpc.Stop)
+	defer stop()
+
+	err := s.grpc.Serve(ln)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("grpc serve: %w", err)
+	}
+
+	return nil
+}
+
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+// access applies the address filter to every call, and the admin token to
+// the admin methods.
+func (s *Server) access(ctx context.Context, method string) error {
+	ip := net.ParseIP(clientIP(ctx))
+	if s.opt.Allowed != nil && !s.opt.Allowed(ip) {
+		return status.Error(codes.PermissionDenied, "address not allowed")
+	}
+
+	if method != bridgev1.Bridge_ListSessions_FullMethodName {
+		return nil
+	}
+
+	if s.opt.Token == "" {
+		if ip == nil || !ip.IsLoopback() {
+			return status.Error(codes.Unauthenticated, "admin methods are loopback-only without an admin token")
+		}
+
+		return nil
+	}
+
+	var got string
+	if v := metadata.ValueFromIncomingContext(ctx, "authorization"); len(v) > 0 {
+		got, _ = strings.CutPrefix(v[0], "Bearer ")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(got), []byte(s.opt.Token)) != 1 {
+		return status.Error(codes.Unauthenticated, "bad admin token")
+	}
+
+	return nil
+}
+
+func (s *Server) unaryAccess(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	err := s.access(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAccess(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := s.access(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+func (s *Server) ListRadios(context.Context, *bridgev1.ListRadiosRequest) (*bridgev1.ListRadiosResponse, error) {
+	resp := &bridgev1.ListRadiosResponse{}
+	if s.opt.Registry == nil {
+		return resp, nil
+	}
+
+	for _, r := range s.opt.Registry.List(time.Now()) {
+		pr := &bridgev1.Radio{
+			Serial:   r.Serial,
+			Model:    r.Model,
+			Nickname: r.Nickname,
+			Callsign: r.Callsign,
+			Version:  r.Version,
+			Status:   r.Status,
+			Online:   r.Online,
+			Sources:  r.Sources,
+			LastSeen: timestamppb.New(r.LastSeen),
+		}
+		if r.Host != "" {
+			pr.Address = net.JoinHostPort(r.Host, fmt.Sprint(r.Port))
+		}
+
+		resp.Radios = append(resp.Radios, pr)
+	}
+
+	return resp, nil
+}
+
+func (s *Server) ListSessions(context.Context, *bridgev1.ListSessionsRequest) (*bridgev1.ListSessionsResponse, error) {
+	resp := &bridgev1.ListSessionsResponse{}
+
+	for _, si := range s.opt.RTC.Sessions() {
+		resp.Sessions = append(resp.Sessions, &bridgev1.Session{
+			Id:             si.ID,
+			ClientIp:       si.ClientIP,
+			Role:           si.Role,
+			CreatedAt:      timestamppb.New(si.CreatedAt),
+			PeerState:      si.PeerState,
+			Radio:          si.Radio,
+			Handle:         si.Handle,
+			Sandbox:        si.Sandbox,
+			GuiClientId:    si.GUIClientID,
+			BoundClient:    si.BoundClient,
+			RadioUnhealthy: si.RadioUnhealthy,
+			TcpFromRadio:   si.Bytes.TCPFromRadio,
+			TcpToRadio:     si.Bytes.TCPToRadio,
+			UdpFromRadio:   si.Bytes.UDPFromRadio,
+			UdpToRadio:     si.Bytes.UDPToRadio,
+		})
+	}
+
+	return resp, nil
+}
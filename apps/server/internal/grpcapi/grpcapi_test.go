@@ -0,0 +1,210 @@
+package grpcapi
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc/rtctest"
+	bridgev1 "github.com/daveisadork/solid-sdr/apps/server/proto/solidsdr/bridge/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLineResponse(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		line string
+		want *bridgev1.ConnectResponse
+	}{
+		{"R12|50000015|bad freq\n", &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Reply{
+			Reply: &bridgev1.Reply{Sequence: 12, Code: 0x50000015, Message: "bad freq"},
+		}}},
+		{"S1234ABCD|slice 0 RF_frequency=14.074000 mode=USB", &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Status{
+			Status: &bridgev1.Status{
+				Handle: "1234ABCD", Object: "slice 0",
+				Attributes: map[string]string{"RF_frequency": "14.074000", "mode": "USB"},
+				Raw:        "S1234ABCD|slice 0 RF_frequency=14.074000 mode=USB",
+			},
+		}}},
+		{"M10000001|Radio is hot", &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Message{
+			Message: &bridgev1.RadioMessage{Code: 0x10000001, Text: "Radio is hot"},
+		}}},
+		{"V1.4.0.0", &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Line{Line: &bridgev1.Line{Raw: "V1.4.0.0"}}}},
+		{"Rxyz|0|", &bridgev1.ConnectResponse{Response: &bridgev1.ConnectResponse_Line{Line: &bridgev1.Line{Raw: "Rxyz|0|"}}}},
+	}
+
+	for _, c := range cases {
+		if got := lineResponse(c.line); !proto.Equal(got, c.want) {
+			t.Errorf("%q:\ngot  %v\nwant %v", c.line, got, c.want)
+		}
+	}
+}
+
+func TestPacketResponse_Meters(t *testing.T) {
+	t.Parallel()
+
+	got := packetResponse(rtc.Packet{ClassCode: meterClassCode, Payload: []byte{0, 7, 0xff, 0x38, 0, 9, 0, 10}})
+
+	want := &bridgev1.Meters{Meters: []*bridgev1.Meter{{Id: 7, Value: -200}, {Id: 9, Value: 10}}}
+	if !proto.Equal(got.GetMeters(), want) {
+		t.Errorf("meters = %v", got)
+	}
+
+	if pan := packetResponse(rtc.Packet{ClassCode: 0x8003, StreamID: 0x40000000}); pan.GetPacket().GetStreamId() != 0x40000000 {
+		t.Errorf("panadapter packet = %v", pan)
+	}
+}
+
+func serve(t *testing.T, opt Options) bridgev1.BridgeClient {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = New(opt).Serve(t.Context(), ln) }()
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return bridgev1.NewBridgeClient(conn)
+}
+
+func TestConnect(t *testing.T) {
+	t.Parallel()
+
+	rtcServer := rtc.New(nil, rtc.Options{ICEPortStart: 50000, ICEPortEnd: 50100})
+	client := serve(t, Options{RTC: rtcServer})
+	radio, _ := rtctest.FakeRadio(t)
+	ctx := t.Context()
+
+	stream, err := client.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stream.Send(&bridgev1.ConnectRequest{Request: &bridgev1.ConnectRequest_Open{
+		Open: &bridgev1.Open{Radio: radio},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := resp.GetConnected(); c.GetHandle() != "0x1234ABCD" || c.GetSessionId() == "" {
+		t.Fatalf("first response = %v", resp)
+	}
+
+	sessions, err := client.ListSessions(ctx, &bridgev1.ListSessionsRequest{})
+	if err != nil || len(sessions.GetSessions()) != 1 || sessions.GetSessions()[0].GetHandle() != "0x1234ABCD" {
+		t.Errorf("ListSessions = %v, %v", sessions, err)
+	}
+
+	err = stream.Send(&bridgev1.ConnectRequest{Request: &bridgev1.ConnectRequest_Command{
+		Command: &bridgev1.Command{Sequence: 5, Command: "info"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		resp, err = stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r := resp.GetReply(); r != nil {
+			if r.GetSequence() != 5 || r.GetCode() != 0 {
+				t.Errorf("reply = %v", r)
+			}
+
+			break
+		}
+	}
+
+	err = stream.CloseSend()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("stream ended with %v", err)
+	}
+}
+
+func TestConnect_Refused(t *testing.T) {
+	t.Parallel()
+
+	rtcServer := rtc.New(nil, rtc.Options{ICEPortStart: 50000, ICEPortEnd: 50100, AllowedRadios: []string{"10.0.0.1"}})
+	client := serve(t, Options{RTC: rtcServer})
+
+	stream, err := client.Connect(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = stream.Send(&bridgev1.ConnectRequest{Request: &bridgev1.ConnectRequest_Open{
+		Open: &bridgev1.Open{Radio: "10.0.0.2:4992"},
+	}})
+
+	_, err = stream.Recv()
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("disallowed radio = %v", err)
+	}
+}
+
+func TestAccess(t *testing.T) {
+	t.Parallel()
+
+	rtcServer := rtc.New(nil, rtc.Options{ICEPortStart: 50000, ICEPortEnd: 50100})
+
+	denied := serve(t, Options{RTC: rtcServer, Allowed: func(net.IP) bool { return false }})
+
+	_, err := denied.ListRadios(t.Context(), &bridgev1.ListRadiosRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ListRadios from a denied address = %v", err)
+	}
+
+	tokened := serve(t, Options{RTC: rtcServer, Token: "s3cret"})
+
+	_, err = tokened.ListSessions(t.Context(), &bridgev1.ListSessionsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListSessions without the token = %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer s3cret")
+
+	_, err = tokened.ListSessions(ctx, &bridgev1.ListSessionsRequest{})
+	if err != nil {
+		t.Errorf("ListSessions with the token = %v", err)
+	}
+
+	_, err = tokened.ListRadios(t.Context(), &bridgev1.ListRadiosRequest{})
+	if err != nil {
+		t.Errorf("ListRadios needs no token: %v", err)
+	}
+}
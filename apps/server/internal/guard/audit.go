@@ -0,0 +1,93 @@
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/tz"
+)
+
+type auditEntry struct {
+	Time     time.Time `json:"time"`  // UTC
+	Local    string    `json:"local"` // Time in the display timezone
+	ClientIP string    `json:"clientIp"`
+	Role     string    `json:"role"`
+	Radio    string    `json:"radio"`
+	Command  string    `json:"command"`
+	Category Category  `json:"category"`
+	Reason   string    `json:"reason,omitempty"`
+	Outcome  string    `json:"outcome"`
+}
+
+type auditTransition struct {
+	Time  time.Time `json:"time"`
+	Local string    `json:"local"`
+	Event string    `json:"event"`
+	TXTransition
+}
+
+// auditLog appends one JSON object per guarded command to a file.
+type auditLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // operator-configured path
+	if err != nil {
+		return nil, fmt.Errorf("open guard audit log: %w", err)
+	}
+
+	return &auditLog{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (a *auditLog) write(req Request, d Decision, outcome string) {
+	_, body, _ := SplitCommand(req.Line)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	err := a.enc.Encode(auditEntry{
+		Time:     now,
+		Local:    tz.Format(now),
+		ClientIP: req.ClientIP,
+		Role:     req.Role,
+		Radio:    req.Radio,
+		Command:  body,
+		Category: d.Category,
+		Reason:   d.Reason,
+		Outcome:  outcome,
+	})
+	if err != nil {
+		log.Printf("[guard] audit write: %v", err)
+	}
+}
+
+func (a *auditLog) writeTransition(t TXTransition) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.enc.Encode(auditTransition{Time: t.At.UTC(), Local: tz.Format(t.At), Event: "interlock", TXTransition: t})
+	if err != nil {
+		log.Printf("[guard] audit write: %v", err)
+	}
+}
+
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.f.Close()
+	if err != nil {
+		return fmt.Errorf("close guard audit log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,321 @@
+// Package guard filters client commands bound for the radio's TCP API.
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errUnknownCategory = errors.New("unknown guard category")
+	errUnknownAction   = errors.New("unknown guard action")
+	errBadRoleCIDR     = errors.New("invalid role CIDR")
+)
+
+// DefaultRole is assigned to clients whose address matches no role mapping.
+const DefaultRole = "operator"
+
+// Action is what the engine does with a command matched by a rule.
+type Action string
+
+const (
+	ActionAllow   Action = "allow"
+	ActionBlock   Action = "block"
+	ActionConfirm Action = "confirm"
+)
+
+// Category groups radio commands considered dangerous on shared stations.
+type Category string
+
+const (
+	// CategoryTX matches keying the transmitter (xmit 1, transmit tune 1).
+	CategoryTX Category = "tx"
+	// CategoryPower matches rfpower/tunepower settings above a rule's MaxPower.
+	CategoryPower Category = "power"
+	// CategoryATUBypass matches "atu bypass".
+	CategoryATUBypass Category = "atu-bypass"
+	// CategoryProfileWrite matches profile save/create/reset/delete.
+	CategoryProfileWrite Category = "profile-write"
+)
+
+// Rule applies Action to commands in Category. Empty Roles or Radios match
+// every role or radio respectively.
+type Rule struct {
+	Category Category
+	Action   Action
+	Roles    []string
+	Radios   []string
+	MaxPower int
+}
+
+// Policy is the full guard configuration.
+type Policy struct {
+	Rules     []Rule
+	Roles     map[string][]string // role -> client CIDRs
+	AuditFile string
+}
+
+// Request describes a single command line a client wants to send.
+type Request struct {
+	ClientIP string
+	Role     string
+	Radio    string // radio host:port as dialed by the bridge
+	Line     string // raw line, e.g. "C12|xmit 1"
+}
+
+// Decision is the engine's verdict for a Request.
+type Decision struct {
+	Action   Action
+	Category Category
+	Reason   string
+}
+
+type roleNet struct {
+	role string
+	net  *net.IPNet
+}
+
+// Engine evaluates commands against a Policy. A nil *Engine allows everything.
+type Engine struct {
+	rules []Rule
+	roles []roleNet
+	audit *auditLog
+}
+
+// New validates p and returns an Engine, opening the audit file if configured.
+func New(p Policy) (*Engine, error) {
+	for _, r := range p.Rules {
+		switch r.Category {
+		case CategoryTX, CategoryPower, CategoryATUBypass, CategoryProfileWrite:
+		default:
+			return nil, fmt.Errorf("%w: %q", errUnknownCategory, r.Category)
+		}
+
+		switch r.Action {
+		case ActionAllow, ActionBlock, ActionConfirm:
+		default:
+			return nil, fmt.Errorf("%w: %q", errUnknownAction, r.Action)
+		}
+	}
+
+	e := &Engine{rules: append([]Rule(nil), p.Rules...)}
+
+	for role, cidrs := range p.Roles {
+		for _, c := range cidrs {
+			_, n, err := net.ParseCIDR(c)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q for role %q", errBadRoleCIDR, c, role)
+			}
+
+			e.roles = append(e.roles, roleNet{role: role, net: n})
+		}
+	}
+
+	// Most specific network wins when a client falls inside several.
+	slices.SortFunc(e.roles, func(a, b roleNet) int {
+		ao, _ := a.net.Mask.Size()
+		bo, _ := b.net.Mask.Size()
+
+		return bo - ao
+	})
+
+	if p.AuditFile != "" {
+		a, err := openAuditLog(p.AuditFile)
+		if err != nil {
+			return nil, err
+		}
+
+		e.audit = a
+	}
+
+	return e, nil
+}
+
+// RoleFor resolves the role of a client by address.
+func (e *Engine) RoleFor(clientIP string) string {
+	if e == nil {
+		return DefaultRole
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return DefaultRole
+	}
+
+	for _, rn := range e.roles {
+		if rn.net.Contains(ip) {
+			return rn.role
+		}
+	}
+
+	return DefaultRole
+}
+
+// Check returns the decision for req. The first matching rule wins.
+func (e *Engine) Check(req Request) Decision {
+	if e == nil {
+		return Decision{Action: ActionAllow}
+	}
+
+	_, body, ok := SplitCommand(req.Line)
+	if !ok {
+		return Decision{Action: ActionAllow}
+	}
+
+	for _, r := range e.rules {
+		if !matchesScope(r, req) {
+			continue
+		}
+
+		reason, hit := matchCategory(r, body)
+		if !hit {
+			continue
+		}
+
+		return Decision{Action: r.Action, Category: r.Category, Reason: reason}
+	}
+
+	return Decision{Action: ActionAllow}
+}
+
+// Record appends req and its outcome to the audit trail, if one is configured.
+// outcome is normally the decision action, or "confirmed"/"rejected" once a
+// held command is resolved.
+func (e *Engine) Record(req Request, d Decision, outcome string) {
+	if e == nil || e.audit == nil {
+		return
+	}
+
+	e.audit.write(req, d, outcome)
+}
+
+// TXTransition is an interlock state change observed on a radio. Durations
+// are in microseconds so amplifier sequencing can be checked from the log.
+type TXTransition struct {
+	At     time.Time `json:"-"`
+	Radio  string    `json:"radio"`
+	State  string    `json:"state"`
+	Prev   string    `json:"prev,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	Source string    `json:"source,omitempty"`
+	// SincePrevUs is the time spent in Prev.
+	SincePrevUs int64 `json:"sincePrevUs,omitempty"`
+	// SinceRequestUs is the time since the bridge forwarded the last xmit
+	// command, when one is pending.
+	SinceRequestUs int64 `json:"sinceRequestUs,omitempty"`
+}
+
+// RecordTransition appends an interlock transition to the audit trail, if
+// one is configured.
+func (e *Engine) RecordTransition(t TXTransition) {
+	if e == nil || e.audit == nil {
+		return
+	}
+
+	e.audit.writeTransition(t)
+}
+
+// Close flushes and closes the audit trail.
+func (e *Engine) Close() error {
+	if e == nil || e.audit == nil {
+		return nil
+	}
+
+	return e.audit.close()
+}
+
+// SplitCommand splits "C<seq>|<body>" (or the "CD" debug form) into its
+// sequence number and body.
+func SplitCommand(line string) (seq, body string, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+
+	rest, found := strings.CutPrefix(line, "C")
+	if !found {
+		return "", "", false
+	}
+
+	rest = strings.TrimPrefix(rest, "D")
+
+	seq, body, found = strings.Cut(rest, "|")
+	if !found || seq == "" {
+		return "", "", false
+	}
+
+	_, err := strconv.ParseUint(seq, 10, 32)
+	if err != nil {
+		return "", "", false
+	}
+
+	return seq, strings.TrimSpace(body), true
+}
+
+// IsTX reports whether line, e.g. "C12|xmit 1", keys the transmitter.
+func IsTX(line string) bool {
+	_, body, ok := SplitCommand(line)
+
+	return ok && reTX.MatchString(body)
+}
+
+func matchesScope(r Rule, req Request) bool {
+	if len(r.Roles) > 0 && !slices.Contains(r.Roles, req.Role) {
+		return false
+	}
+
+	if len(r.Radios) == 0 {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(req.Radio)
+	if err != nil {
+		host = req.Radio
+	}
+
+	return slices.Contains(r.Radios, req.Radio) || slices.Contains(r.Radios, host)
+}
+
+// The radio takes commands in any case, so these match in any case too.
+var (
+	reTX           = regexp.MustCompile(`(?i)^(?:xmit\s+1|transmit\s+tune\s+(?:1|on))\b`)
+	reTransmitSet  = regexp.MustCompile(`(?i)^transmit\s+set\b`)
+	rePower        = regexp.MustCompile(`(?i)\b(rfpower|tunepower)=(\d+)`)
+	reATUBypass    = regexp.MustCompile(`(?i)^atu\s+bypass\b`)
+	reProfileWrite = regexp.MustCompile(`(?i)^profile\s+(global|transmit|tx|mic|display)\s+(save|create|reset|delete)\b`)
+)
+
+func matchCategory(r Rule, body string) (reason string, ok bool) {
+	switch r.Category {
+	case CategoryTX:
+		if reTX.MatchString(body) {
+			return "transmit", true
+		}
+	case CategoryPower:
+		if !reTransmitSet.MatchString(body) {
+			return "", false
+		}
+
+		// One command may set both powers; every one must be within the limit.
+		for _, m := range rePower.FindAllStringSubmatch(body, -1) {
+			level, err := strconv.Atoi(m[2])
+			if err != nil || level > r.MaxPower {
+				return fmt.Sprintf("%s %s exceeds limit %d", strings.ToLower(m[1]), m[2], r.MaxPower), true
+			}
+		}
+	case CategoryATUBypass:
+		if reATUBypass.MatchString(body) {
+			return "ATU bypass", true
+		}
+	case CategoryProfileWrite:
+		m := reProfileWrite.FindStringSubmatch(body)
+		if m != nil {
+			return fmt.Sprintf("%s profile %s", strings.ToLower(m[1]), strings.ToLower(m[2])), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,129 @@
+package guard
+
+import "testing"
+
+func TestCheck_NilEngineAllows(t *testing.T) {
+	t.Parallel()
+
+	var e *Engine
+
+	d := e.Check(Request{Line: "C1|xmit 1"})
+	if d.Action != ActionAllow {
+		t.Errorf("got %q want allow", d.Action)
+	}
+}
+
+func TestCheck_Categories(t *testing.T) {
+	t.Parallel()
+
+	e, err := New(Policy{Rules: []Rule{
+		{Category: CategoryTX, Action: ActionConfirm},
+		{Category: CategoryPower, Action: ActionBlock, MaxPower: 50},
+		{Category: CategoryATUBypass, Action: ActionBlock},
+		{Category: CategoryProfileWrite, Action: ActionBlock},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		line string
+		want Action
+	}{
+		{"C1|xmit 1", ActionConfirm},
+		{"C2|xmit 0", ActionAllow},
+		{"CD3|transmit tune 1", ActionConfirm},
+		{"C4|transmit set rfpower=51", ActionBlock},
+		{"C5|transmit set rfpower=50", ActionAllow},
+		{"C6|transmit set tunepower=80", ActionBlock},
+		{"C7|atu bypass", ActionBlock},
+		{"C8|atu start", ActionAllow},
+		{"C9|profile global save \"Contest\"", ActionBlock},
+		{"C10|profile global load \"Contest\"", ActionAllow},
+		{"C11|transmit set rfpower=100 tunepower=5", ActionBlock},
+		{"C12|transmit set tunepower=5 rfpower=100", ActionBlock},
+		{"C13|transmit set rfpower=40 tunepower=5", ActionAllow},
+		{"C14|transmit set rfpower=99999999999999999999", ActionBlock},
+		{"C15|XMIT 1", ActionConfirm},
+		{"C16|Transmit Tune On", ActionConfirm},
+		{"C17|TRANSMIT SET RFPOWER=100", ActionBlock},
+		{"C18|ATU Bypass", ActionBlock},
+		{"C19|PROFILE GLOBAL SAVE \"Contest\"", ActionBlock},
+		{"not a command", ActionAllow},
+	}
+
+	for _, tc := range cases {
+		d := e.Check(Request{Line: tc.line})
+		if d.Action != tc.want {
+			t.Errorf("%q: got %q want %q", tc.line, d.Action, tc.want)
+		}
+	}
+}
+
+func TestCheck_Scope(t *testing.T) {
+	t.Parallel()
+
+	e, err := New(Policy{
+		Rules: []Rule{{
+			Category: CategoryTX,
+			Action:   ActionBlock,
+			Roles:    []string{"guest"},
+			Radios:   []string{"192.0.2.10"},
+		}},
+		Roles: map[string][]string{"guest": {"10.0.0.0/8"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guest := e.RoleFor("10.1.2.3")
+	if guest != "guest" {
+		t.Fatalf("RoleFor: got %q want guest", guest)
+	}
+
+	if e.RoleFor("192.168.1.5") != DefaultRole {
+		t.Fatal("unmapped client should get the default role")
+	}
+
+	d := e.Check(Request{Role: guest, Radio: "192.0.2.10:4992", Line: "C1|xmit 1"})
+	if d.Action != ActionBlock {
+		t.Errorf("guest on guarded radio: got %q want block", d.Action)
+	}
+
+	d = e.Check(Request{Role: guest, Radio: "192.0.2.11:4992", Line: "C1|xmit 1"})
+	if d.Action != ActionAllow {
+		t.Errorf("guest on other radio: got %q want allow", d.Action)
+	}
+
+	d = e.Check(Request{Role: DefaultRole, Radio: "192.0.2.10:4992", Line: "C1|xmit 1"})
+	if d.Action != ActionAllow {
+		t.Errorf("operator: got %q want allow", d.Action)
+	}
+}
+
+func TestNew_RejectsUnknownCategory(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(Policy{Rules: []Rule{{Category: "bogus", Action: ActionBlock}}})
+	if err == nil {
+		t.Error("expected error for unknown category")
+	}
+}
+
+func TestIsTX(t *testing.T) {
+	t.Parallel()
+
+	for line, want := range map[string]bool{
+		"C1|xmit 1\n":            true,
+		"CD2|transmit tune on":   true,
+		"C3|xmit 0":              false,
+		"C4|transmit set mox=1":  false,
+		"xmit 1":                 false,
+		"C5|slice tune 0 14.074": false,
+		"C6|XMIT 1":              true,
+	} {
+		if got := IsTX(line); got != want {
+			t.Errorf("IsTX(%q) = %t, want %t", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package heartbeat keeps WebSocket connections alive and notices peers that
+// have gone. A client behind a NAT that dropped its mapping never closes its
+// socket; without pings the server only finds out when a write buffer fills,
+// which an idle connection never does.
+package heartbeat
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Interval is how often the peer is pinged.
+	Interval = 15 * time.Second
+	// Timeout is how long the peer has to answer, counted from its last
+	// pong; past it, the connection's next read fails.
+	Timeout = 40 * time.Second
+
+	writeWait = 10 * time.Second
+)
+
+// Keep pings ws every Interval until ctx is done, and gives every read a
+// deadline of Timeout past the last pong.
+func Keep(ctx context.Context, ws *websocket.Conn) {
+	KeepEvery(ctx, ws, Interval, Timeout)
+}
+
+// KeepEvery is Keep with its own interval and timeout. Browsers answer pings
+// by themselves, so a peer that stops answering is gone: its read loop gets
+// a timeout error and should end the connection. Pings go out with
+// WriteControl, which is safe alongside the connection's one writer.
+func KeepEvery(ctx context.Context, ws *websocket.Conn, interval, timeout time.Duration) {
+	_ = ws.SetReadDeadline(time.Now().Add(timeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(timeout)) //nolint:wrapcheck // gorilla returns it from the read
+	})
+
+	go func() {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+
+			err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			if err != nil {
+				// Unblock the reader rather than wait out the deadline.
+				_ = ws.Close()
+
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,100 @@
+package heartbeat
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// server upgrades every request, keeps it alive and reports how its read
+// loop ended.
+func server(t *testing.T) (string, <-chan error) {
+	t.Helper()
+
+	ended := make(chan error, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var up websocket.Upgrader
+
+		ws, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = ws.Close() }()
+
+		KeepEvery(r.Context(), ws, 10*time.Millisecond, 60*time.Millisecond)
+
+		for {
+			_, _, err := ws.ReadMessage()
+			if err != nil {
+				ended <- err
+
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), ended
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	ws, _, err := websocket.DefaultDialer.DialContext(t.Context(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ws.Close() })
+
+	return ws
+}
+
+func TestKeep_AnsweringPeerStays(t *testing.T) {
+	t.Parallel()
+
+	url, ended := server(t)
+	ws := dial(t, url)
+
+	// Reading answers the pings.
+	go func() {
+		for {
+			_, _, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-ended:
+		t.Fatalf("connection ended: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestKeep_SilentPeerReaped(t *testing.T) {
+	t.Parallel()
+
+	url, ended := server(t)
+	_ = dial(t, url)
+
+	// Never reading, the client never answers a ping.
+	select {
+	case err := <-ended:
+		var ne net.Error
+		if !errors.As(err, &ne) || !ne.Timeout() {
+			t.Errorf("ended with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("silent peer not reaped")
+	}
+}
@@ -0,0 +1,166 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/status"
+)
+
+// maxBuckets bounds a heatmap's time buckets.
+const maxBuckets = 10000
+
+var errBuckets = errors.New("history: too many heatmap buckets")
+
+// Cell is the activity on a band during one time bucket.
+type Cell struct {
+	Start time.Time `json:"start"`
+	Band  string    `json:"band"`
+	// TunedSeconds is how long slices were tuned to the band, summed over
+	// slices.
+	TunedSeconds float64 `json:"tunedSeconds"`
+	// TXSeconds is how long the radio transmitted on it.
+	TXSeconds float64 `json:"txSeconds"`
+}
+
+// span is a stretch of time spent on a band.
+type span struct {
+	from, to time.Time
+	band     string
+	tx       bool
+}
+
+// Heatmap sums the activity from from up to to into bucket-wide time buckets
+// by band, for radio or, if empty, every radio. A tuning lasts until the
+// slice's next one, for at most an hour, and a transmission until the radio
+// stops. Frequencies outside the amateur bands are left out.
+func (s *Store) Heatmap(ctx context.Context, radio string, from, to time.Time, bucket time.Duration) ([]Cell, error) {
+	if !from.Before(to) {
+		return nil, errRange
+	}
+
+	if bucket <= 0 || to.Sub(from)/bucket > maxBuckets {
+		return nil, fmt.Errorf("%w: %s in %s", errBuckets, to.Sub(from), bucket)
+	}
+
+	// Rows from before from say where slices already were.
+	events, err := s.Events(ctx, radio, from.Add(-maxDwell), to, maxEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	end := earliest(to, time.Now())
+	cells := make(map[cellKey]*Cell)
+
+	for _, sp := range spans(events, end) {
+		if sp.band == "" {
+			continue
+		}
+
+		sp.from = latest(sp.from, from)
+		sp.to = earliest(sp.to, to)
+
+		for sp.from.Before(sp.to) {
+			start := from.Add(sp.from.Sub(from) / bucket * bucket)
+			stop := earliest(start.Add(bucket), sp.to)
+
+			key := cellKey{start: start.UnixMilli(), band: sp.band}
+
+			c := cells[key]
+			if c == nil {
+				c = &Cell{Start: start.UTC(), Band: sp.band}
+				cells[key] = c
+			}
+
+			if sp.tx {
+				c.TXSeconds += stop.Sub(sp.from).Seconds()
+			} else {
+				c.TunedSeconds += stop.Sub(sp.from).Seconds()
+			}
+
+			sp.from = stop
+		}
+	}
+
+	out := make([]Cell, 0, len(cells))
+	for _, c := range cells {
+		out = append(out, *c)
+	}
+
+	slices.SortFunc(out, func(a, b Cell) int {
+		if c := a.Start.Compare(b.Start); c != 0 {
+			return c
+		}
+
+		return bandOrder(a.Band) - bandOrder(b.Band)
+	})
+
+	return out, nil
+}
+
+type cellKey struct {
+	start int64 // unix ms
+	band  string
+}
+
+// spans turns events, oldest first, into the time spent on each band, the
+// last of them running to end.
+func spans(events []Event, end time.Time) []span {
+	var (
+		out  []span
+		open = make(map[string]span) // by radio and slice; "" for TX
+	)
+
+	closeSpan := func(key string, at time.Time) {
+		sp, ok := open[key]
+		if !ok {
+			return
+		}
+
+		delete(open, key)
+
+		sp.to = earliest(at, sp.from.Add(maxDwell))
+		if sp.from.Before(sp.to) {
+			out = append(out, sp)
+		}
+	}
+
+	for _, e := range events {
+		key := e.Radio + "/" + e.Slice
+		closeSpan(key, e.At)
+
+		if e.Kind != KindRX {
+			open[key] = span{from: e.At, band: status.Band(float64(e.FreqHz) / 1e6), tx: e.Kind == KindTX}
+		}
+	}
+
+	for key := range open {
+		closeSpan(key, end)
+	}
+
+	return out
+}
+
+// bandOrder ranks a band name by frequency.
+func bandOrder(name string) int {
+	return slices.Index(status.Bands(), name)
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+
+	return a
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+
+	return a
+}
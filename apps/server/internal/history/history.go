@@ -0,0 +1,278 @@
+// Package history keeps a time series of where each radio's slices were tuned
+// and when it transmitted, in SQLite, for "where was I yesterday at 02:00Z"
+// lookups and band activity heatmaps.
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+const (
+	// DefaultRetention is how long activity is kept.
+	DefaultRetention = 90 * 24 * time.Hour
+
+	// settle is how soon after a slice moves a further move replaces the
+	// row rather than adding one, so dragging across a band records where
+	// the slice settled instead of every step.
+	settle = 2 * time.Second
+	// maxDwell caps how long a tuning counts towards a heatmap without a
+	// further row, e.g. over a bridge restart.
+	maxDwell = time.Hour
+	// pruneInterval is how often rows past the retention are deleted.
+	pruneInterval = time.Hour
+	// maxEvents bounds an Events query.
+	maxEvents = 10000
+)
+
+const (
+	// KindTune is a slice's frequency or mode changing.
+	KindTune = "tune"
+	// KindTX is the radio starting to transmit, KindRX it stopping.
+	KindTX = "tx"
+	KindRX = "rx"
+)
+
+var errRange = errors.New("history: from must be before to")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS activity (
+	at      INTEGER NOT NULL,
+	radio   TEXT NOT NULL,
+	slice   TEXT NOT NULL,
+	kind    TEXT NOT NULL,
+	freq_hz INTEGER NOT NULL,
+	mode    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS activity_at ON activity (at);
+CREATE INDEX IF NOT EXISTS activity_radio_at ON activity (radio, at);
+`
+
+// Event is one row of activity. Slice is empty for TX and RX, which are the
+// radio's.
+type Event struct {
+	At     time.Time `json:"at"`
+	Radio  string    `json:"radio"`
+	Slice  string    `json:"slice,omitempty"`
+	Kind   string    `json:"kind"`
+	FreqHz int64     `json:"freqHz"`
+	Mode   string    `json:"mode"`
+}
+
+// tuned is a slice's last recorded tuning.
+type tuned struct {
+	freqHz int64
+	mode   string
+	at     time.Time
+	row    int64
+}
+
+// Store records activity and answers queries about it.
+type Store struct {
+	db        *sql.DB
+	retention time.Duration
+
+	mu      sync.Mutex
+	slices  map[string]tuned // by radio and slice
+	sending map[string]bool  // by radio
+}
+
+// Open opens, creating if need be, the database at path. A retention of zero
+// means DefaultRetention.
+func Open(path string, retention time.Duration) (*Store, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	// SQLite takes one writer at a time.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	return &Store{
+		db: db, retention: retention,
+		slices: make(map[string]tuned), sending: make(map[string]bool),
+	}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Run prunes activity past the retention until ctx is done.
+func (s *Store) Run(ctx context.Context) error {
+	t := time.NewTicker(pruneInterval)
+	defer t.Stop()
+
+	for {
+		s.prune(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Store) prune(now time.Time) {
+	_, err := s.db.Exec(`DELETE FROM activity WHERE at < ?`, now.Add(-s.retention).UnixMilli())
+	if err != nil {
+		log.Printf("[history] prune: %v", err)
+	}
+}
+
+// Tune records where a radio's slice is tuned, if it moved.
+func (s *Store) Tune(radio, slice string, freqHz int64, mode string, at time.Time) {
+	key := radio + "/" + slice
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.slices[key]
+	if ok && last.freqHz == freqHz && last.mode == mode {
+		return
+	}
+
+	var err error
+
+	if ok && at.Sub(last.at) < settle {
+		_, err = s.db.Exec(`UPDATE activity SET at = ?, freq_hz = ?, mode = ? WHERE rowid = ?`,
+			at.UnixMilli(), freqHz, mode, last.row)
+	} else {
+		last.row, err = s.insert(Event{At: at, Radio: radio, Slice: slice, Kind: KindTune, FreqHz: freqHz, Mode: mode})
+	}
+
+	if err != nil {
+		log.Printf("[history] %s: %v", key, err)
+
+		return
+	}
+
+	s.slices[key] = tuned{freqHz: freqHz, mode: mode, at: at, row: last.row}
+}
+
+// Transmit records a radio starting or stopping transmitting, on the TX
+// slice's frequency and mode.
+func (s *Store) Transmit(radio string, on bool, freqHz int64, mode string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sending[radio] == on {
+		return
+	}
+
+	kind := KindRX
+	if on {
+		kind = KindTX
+	}
+
+	_, err := s.insert(Event{At: at, Radio: radio, Kind: kind, FreqHz: freqHz, Mode: mode})
+	if err != nil {
+		log.Printf("[history] %s: %v", radio, err)
+
+		return
+	}
+
+	s.sending[radio] = on
+}
+
+func (s *Store) insert(e Event) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO activity (at, radio, slice, kind, freq_hz, mode) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.At.UnixMilli(), e.Radio, e.Slice, e.Kind, e.FreqHz, e.Mode)
+	if err != nil {
+		return 0, fmt.Errorf("insert: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("insert: %w", err)
+	}
+
+	return id, nil
+}
+
+// At reports where each slice was tuned at t: every slice's latest tuning at
+// or before it, for radio or, if empty, every radio.
+func (s *Store) At(ctx context.Context, radio string, t time.Time) ([]Event, error) {
+	// SQLite takes the bare columns from the row holding MAX(at).
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT MAX(at), radio, slice, kind, freq_hz, mode FROM activity
+		WHERE kind = ? AND at <= ? AND (? = '' OR radio = ?)
+		GROUP BY radio, slice ORDER BY radio, slice`,
+		KindTune, t.UnixMilli(), radio, radio)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	return scan(rows)
+}
+
+// Events lists the activity from from up to to, oldest first, for radio or,
+// if empty, every radio. At most limit rows are returned; zero or more than
+// 10000 means 10000.
+func (s *Store) Events(ctx context.Context, radio string, from, to time.Time, limit int) ([]Event, error) {
+	if !from.Before(to) {
+		return nil, errRange
+	}
+
+	if limit <= 0 || limit > maxEvents {
+		limit = maxEvents
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT at, radio, slice, kind, freq_hz, mode FROM activity
+		WHERE at >= ? AND at < ? AND (? = '' OR radio = ?)
+		ORDER BY at, rowid LIMIT ?`,
+		from.UnixMilli(), to.UnixMilli(), radio, radio, limit)
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	return scan(rows)
+}
+
+func scan(rows *sql.Rows) ([]Event, error) {
+	defer func() { _ = rows.Close() }()
+
+	out := []Event{}
+
+	for rows.Next() {
+		var (
+			e  Event
+			at int64
+		)
+
+		err := rows.Scan(&at, &e.Radio, &e.Slice, &e.Kind, &e.FreqHz, &e.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+
+		e.At = time.UnixMilli(at).UTC()
+		out = append(out, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,167 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s
+}
+
+var t0 = time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)
+
+func TestTune_RecordsMovesOnly(t *testing.T) {
+	t.Parallel()
+
+	s := openStore(t)
+
+	s.Tune("r1", "A", 14_074_000, "DIGU", t0)
+	s.Tune("r1", "A", 14_074_000, "DIGU", t0.Add(time.Minute))
+	s.Tune("r1", "A", 7_074_000, "DIGU", t0.Add(2*time.Minute))
+
+	events, err := s.Events(context.Background(), "r1", t0, t0.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[0].FreqHz != 14_074_000 || events[1].FreqHz != 7_074_000 {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestTune_SettlesQuickMoves(t *testing.T) {
+	t.Parallel()
+
+	s := openStore(t)
+
+	s.Tune("r1", "A", 14_000_000, "CW", t0)
+	s.Tune("r1", "A", 14_010_000, "CW", t0.Add(500*time.Millisecond))
+	s.Tune("r1", "A", 14_025_000, "CW", t0.Add(time.Second))
+
+	events, err := s.Events(context.Background(), "", t0, t0.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].FreqHz != 14_025_000 || !events[0].At.Equal(t0.Add(time.Second)) {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestTransmit_RecordsChanges(t *testing.T) {
+	t.Parallel()
+
+	s := openStore(t)
+
+	s.Transmit("r1", false, 14_074_000, "DIGU", t0)
+	s.Transmit("r1", true, 14_074_000, "DIGU", t0.Add(time.Second))
+	s.Transmit("r1", true, 14_074_000, "DIGU", t0.Add(2*time.Second))
+	s.Transmit("r1", false, 14_074_000, "DIGU", t0.Add(15*time.Second))
+
+	events, err := s.Events(context.Background(), "r1", t0, t0.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[0].Kind != KindTX || events[1].Kind != KindRX {
+		t.Fatalf("events = %+v", events)
+	}
+}
+
+func TestAt_LatestTuningPerSlice(t *testing.T) {
+	t.Parallel()
+
+	s := openStore(t)
+
+	s.Tune("r1", "A", 14_074_000, "DIGU", t0)
+	s.Tune("r1", "B", 7_030_000, "CW", t0.Add(time.Minute))
+	s.Tune("r1", "A", 21_074_000, "DIGU", t0.Add(time.Hour))
+	s.Tune("r2", "A", 3_573_000, "DIGU", t0)
+
+	got, err := s.At(context.Background(), "r1", t0.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0].Slice != "A" || got[0].FreqHz != 14_074_000 || got[1].FreqHz != 7_030_000 {
+		t.Fatalf("At = %+v", got)
+	}
+
+	got, err = s.At(context.Background(), "", t0.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 || got[0].FreqHz != 21_074_000 {
+		t.Fatalf("At = %+v", got)
+	}
+}
+
+func TestEvents_RejectsEmptyRange(t *testing.T) {
+	t.Parallel()
+
+	_, err := openStore(t).Events(context.Background(), "", t0, t0, 0)
+	if !errors.Is(err, errRange) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestHeatmap_SumsBandTime(t *testing.T) {
+	t.Parallel()
+
+	s := openStore(t)
+
+	// Already on 20m before the window opens.
+	s.Tune("r1", "A", 14_074_000, "DIGU", t0.Add(-10*time.Minute))
+	s.Transmit("r1", true, 14_074_000, "DIGU", t0.Add(20*time.Minute))
+	s.Transmit("r1", false, 14_074_000, "DIGU", t0.Add(21*time.Minute))
+	s.Tune("r1", "A", 7_074_000, "DIGU", t0.Add(40*time.Minute))
+	s.Tune("r1", "A", 7_200_000, "LSB", t0.Add(80*time.Minute))
+	// Outside the amateur bands.
+	s.Tune("r1", "B", 10_000_000, "AM", t0)
+
+	cells, err := s.Heatmap(context.Background(), "r1", t0, t0.Add(90*time.Minute), 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Cell{
+		{Start: t0, Band: "20m", TunedSeconds: 1800, TXSeconds: 60},
+		{Start: t0.Add(30 * time.Minute), Band: "40m", TunedSeconds: 1200},
+		{Start: t0.Add(30 * time.Minute), Band: "20m", TunedSeconds: 600},
+		{Start: t0.Add(time.Hour), Band: "40m", TunedSeconds: 1800},
+	}
+
+	if len(cells) != len(want) {
+		t.Fatalf("cells = %+v", cells)
+	}
+
+	for i, c := range cells {
+		if !c.Start.Equal(want[i].Start) || c.Band != want[i].Band ||
+			c.TunedSeconds != want[i].TunedSeconds || c.TXSeconds != want[i].TXSeconds {
+			t.Fatalf("cell %d = %+v, want %+v", i, c, want[i])
+		}
+	}
+}
+
+func TestHeatmap_BoundsBuckets(t *testing.T) {
+	t.Parallel()
+
+	_, err := openStore(t).Heatmap(context.Background(), "", t0, t0.Add(24*time.Hour), time.Second)
+	if !errors.Is(err, errBuckets) {
+		t.Fatalf("err = %v", err)
+	}
+}
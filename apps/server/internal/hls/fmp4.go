@@ -0,0 +1,150 @@
+package hls
+
+import (
+	"encoding/binary"
+)
+
+// Opus in ISO BMFF ("Encapsulation of Opus in ISO Base Media File Format").
+const (
+	timescale = 48000
+	channels  = 2
+	preSkip   = 312
+)
+
+// Sample is one Opus packet and its duration in 48 kHz ticks.
+type Sample struct {
+	Data     []byte
+	Duration uint32
+}
+
+// box is an ISO BMFF box under construction.
+type box []byte
+
+func newBox(typ string, payload ...[]byte) box {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+
+	b := make(box, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size)) //nolint:gosec // boxes are far below 4 GiB
+	copy(b[4:], typ)
+
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+
+	return b
+}
+
+// fullBox prefixes payload with the version and flags header.
+func fullBox(typ string, version byte, flags uint32, payload ...[]byte) box {
+	vf := u32(flags)
+	vf[0] = version
+
+	return newBox(typ, append([][]byte{vf}, payload...)...)
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+func zeros(n int) []byte { return make([]byte, n) }
+
+// unityMatrix is the identity transformation matrix of mvhd and tkhd.
+func unityMatrix() []byte {
+	var m []byte
+	for _, v := range []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000} {
+		m = append(m, u32(v)...)
+	}
+
+	return m
+}
+
+// InitSegment returns the fMP4 initialization segment (ftyp and moov) for a
+// single stereo Opus track.
+func InitSegment() []byte {
+	ftyp := newBox("ftyp", []byte("iso6"), u32(0), []byte("iso6"), []byte("mp41"))
+
+	mvhd := fullBox("mvhd", 0, 0,
+		u32(0), u32(0), u32(1000), u32(0), // creation, modification, timescale, duration
+		u32(0x00010000), u16(0x0100), zeros(10), // rate, volume, reserved
+		unityMatrix(), zeros(24), u32(2), // pre_defined, next_track_ID
+	)
+
+	tkhd := fullBox("tkhd", 0, 3, // enabled | in movie
+		u32(0), u32(0), u32(1), zeros(4), u32(0), // creation, modification, track_ID, reserved, duration
+		zeros(8), u16(0), u16(0), u16(0x0100), zeros(2), // reserved, layer, alternate_group, volume, reserved
+		unityMatrix(), u32(0), u32(0), // width, height
+	)
+
+	mdhd := fullBox("mdhd", 0, 0, u32(0), u32(0), u32(timescale), u32(0), u16(0x55c4), u16(0)) // language "und"
+	hdlr := fullBox("hdlr", 0, 0, u32(0), []byte("soun"), zeros(12), []byte("SoundHandler\x00"))
+
+	dOps := newBox("dOps", []byte{0, channels}, u16(preSkip), u32(timescale), u16(0), []byte{0})
+	opus := newBox("Opus",
+		zeros(6), u16(1), // reserved, data_reference_index
+		zeros(8), u16(channels), u16(16), zeros(4), u32(timescale<<16), // reserved, channelcount, samplesize, pre_defined+reserved, samplerate
+		dOps,
+	)
+
+	stbl := newBox("stbl",
+		fullBox("stsd", 0, 0, u32(1), opus),
+		fullBox("stts", 0, 0, u32(0)),
+		fullBox("stsc", 0, 0, u32(0)),
+		fullBox("stsz", 0, 0, u32(0), u32(0)),
+		fullBox("stco", 0, 0, u32(0)),
+	)
+	dinf := newBox("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, 1)))
+	minf := newBox("minf", fullBox("smhd", 0, 0, u16(0), u16(0)), dinf, stbl)
+	trak := newBox("trak", tkhd, newBox("mdia", mdhd, hdlr, minf))
+	mvex := newBox("mvex", fullBox("trex", 0, 0, u32(1), u32(1), u32(0), u32(0), u32(0)))
+
+	return append(ftyp, newBox("moov", mvhd, trak, mvex)...)
+}
+
+// MediaSegment returns one fMP4 media segment (moof and mdat) holding
+// samples, the first of which starts at base ticks.
+func MediaSegment(seq uint32, base uint64, samples []Sample) []byte {
+	const (
+		trunDataOffset     = 0x000001
+		trunSampleDuration = 0x000100
+		trunSampleSize     = 0x000200
+		tfhdDefaultBase    = 0x020000 // default-base-is-moof
+	)
+
+	entries := make([]byte, 0, 8*len(samples))
+	mdat := 0
+
+	for _, s := range samples {
+		entries = append(entries, u32(s.Duration)...)
+		entries = append(entries, u32(uint32(len(s.Data)))...) //nolint:gosec // Opus packets are small
+		mdat += len(s.Data)
+	}
+
+	build := func(dataOffset uint32) box {
+		trun := fullBox("trun", 0, trunDataOffset|trunSampleDuration|trunSampleSize,
+			u32(uint32(len(samples))), u32(dataOffset), entries) //nolint:gosec // bounded by segment length
+
+		return newBox("moof",
+			fullBox("mfhd", 0, 0, u32(seq)),
+			newBox("traf", fullBox("tfhd", 0, tfhdDefaultBase, u32(1)), fullBox("tfdt", 1, 0, u64(base)), trun),
+		)
+	}
+
+	// The data offset points past the moof and the mdat header; the moof's
+	// size does not depend on the offset's value.
+	moof := build(0)
+	moof = build(uint32(len(moof)) + 8) //nolint:gosec // small
+
+	out := make([]byte, 0, len(moof)+8+mdat)
+	out = append(out, moof...)
+	out = append(out, u32(uint32(8+mdat))...) //nolint:gosec // small
+	out = append(out, "mdat"...)
+
+	for _, s := range samples {
+		out = append(out, s.Data...)
+	}
+
+	return out
+}
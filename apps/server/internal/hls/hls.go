@@ -0,0 +1,116 @@
+// Package hls packages a live Opus stream as HLS: fMP4 segments (the only
+// container in which players accept Opus over HLS) and a sliding-window media
+// playlist. Segments are short so latency stays within a few seconds.
+package hls
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SegmentDuration is the length each segment is cut at.
+	SegmentDuration = time.Second
+	// window is how many complete segments the playlist lists.
+	window = 6
+	// keep is how many segments are retained, so a player that fetched the
+	// playlist just before it slid can still download what it listed.
+	keep = window + 3
+)
+
+// Names used in the playlist, relative to its own URL.
+const (
+	PlaylistName = "index.m3u8"
+	InitName     = "init.mp4"
+)
+
+type segment struct {
+	seq      uint32
+	duration time.Duration
+	data     []byte
+}
+
+// Segmenter cuts Opus packets into segments. It is safe for concurrent use:
+// one goroutine writes while HTTP handlers read.
+type Segmenter struct {
+	mu       sync.Mutex
+	segments []segment // oldest first, at most keep
+	pending  []Sample
+	pendingD time.Duration
+	next     uint32 // sequence number of the pending segment
+	ticks    uint64 // decode time of the first pending sample
+}
+
+// Write adds one Opus packet of duration d.
+func (s *Segmenter) Write(payload []byte, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, Sample{
+		Data:     append([]byte(nil), payload...),
+		Duration: uint32(d * timescale / time.Second), //nolint:gosec // packets are at most 120 ms
+	})
+	s.pendingD += d
+
+	if s.pendingD < SegmentDuration {
+		return
+	}
+
+	s.segments = append(s.segments, segment{
+		seq:      s.next,
+		duration: s.pendingD,
+		data:     MediaSegment(s.next+1, s.ticks, s.pending),
+	})
+	if len(s.segments) > keep {
+		s.segments = s.segments[len(s.segments)-keep:]
+	}
+
+	for _, p := range s.pending {
+		s.ticks += uint64(p.Duration)
+	}
+
+	s.next++
+	s.pending, s.pendingD = nil, 0
+}
+
+// Playlist returns the live media playlist, or false before the first
+// segment is complete.
+func (s *Segmenter) Playlist() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) == 0 {
+		return "", false
+	}
+
+	listed := s.segments[max(0, len(s.segments)-window):]
+
+	var b strings.Builder
+
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(SegmentDuration.Seconds()+0.5))
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", listed[0].seq)
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=%q\n", InitName)
+
+	for _, seg := range listed {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%d.m4s\n", seg.duration.Seconds(), seg.seq)
+	}
+
+	return b.String(), true
+}
+
+// Segment returns a retained segment by sequence number.
+func (s *Segmenter) Segment(seq uint32) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, seg := range s.segments {
+		if seg.seq == seq {
+			return seg.data, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,111 @@
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// boxes splits b into top-level box types, failing on a bad size.
+func boxes(t *testing.T, b []byte) []string {
+	t.Helper()
+
+	var types []string
+
+	for len(b) > 0 {
+		if len(b) < 8 {
+			t.Fatalf("truncated box header %x", b)
+		}
+
+		size := int(binary.BigEndian.Uint32(b))
+		if size < 8 || size > len(b) {
+			t.Fatalf("box %q has size %d with %d bytes left", b[4:8], size, len(b))
+		}
+
+		types = append(types, string(b[4:8]))
+		b = b[size:]
+	}
+
+	return types
+}
+
+func TestInitSegment(t *testing.T) {
+	t.Parallel()
+
+	b := InitSegment()
+	if got := strings.Join(boxes(t, b), ","); got != "ftyp,moov" {
+		t.Errorf("top-level boxes %s, want ftyp,moov", got)
+	}
+
+	for _, want := range []string{"mvhd", "trak", "mvex", "Opus", "dOps", "trex"} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("init segment has no %s box", want)
+		}
+	}
+}
+
+func TestMediaSegment(t *testing.T) {
+	t.Parallel()
+
+	samples := []Sample{{Data: []byte{1, 2, 3}, Duration: 480}, {Data: []byte{4, 5}, Duration: 960}}
+	seg := MediaSegment(7, 48000, samples)
+
+	if got := strings.Join(boxes(t, seg), ","); got != "moof,mdat" {
+		t.Fatalf("top-level boxes %s, want moof,mdat", got)
+	}
+
+	// trun's data offset, relative to the moof, must land on the first sample.
+	trun := bytes.Index(seg, []byte("trun"))
+	offset := binary.BigEndian.Uint32(seg[trun+12:])
+
+	if !bytes.Equal(seg[offset:], []byte{1, 2, 3, 4, 5}) {
+		t.Errorf("data offset %d points at %x", offset, seg[offset:])
+	}
+
+	tfdt := bytes.Index(seg, []byte("tfdt"))
+	if base := binary.BigEndian.Uint64(seg[tfdt+8:]); base != 48000 {
+		t.Errorf("base decode time %d, want 48000", base)
+	}
+}
+
+func TestSegmenter(t *testing.T) {
+	t.Parallel()
+
+	var s Segmenter
+
+	if _, ok := s.Playlist(); ok {
+		t.Fatal("playlist before the first segment")
+	}
+
+	for range 1000 { // 10 s of 10 ms packets
+		s.Write([]byte{0x80}, 10*time.Millisecond)
+	}
+
+	pl, ok := s.Playlist()
+	if !ok {
+		t.Fatal("no playlist after 10 s")
+	}
+
+	for _, want := range []string{"#EXT-X-MEDIA-SEQUENCE:4\n", `#EXT-X-MAP:URI="init.mp4"`, "#EXTINF:1.000,\n9.m4s\n"} {
+		if !strings.Contains(pl, want) {
+			t.Errorf("playlist lacks %q:\n%s", want, pl)
+		}
+	}
+
+	if _, ok := s.Segment(9); !ok {
+		t.Error("newest segment not retained")
+	}
+
+	if _, ok := s.Segment(0); ok {
+		t.Error("segment 0 should have aged out")
+	}
+
+	seg, _ := s.Segment(1)
+	tfdt := bytes.Index(seg, []byte("tfdt"))
+
+	if base := binary.BigEndian.Uint64(seg[tfdt+8:]); base != 48000 {
+		t.Errorf("segment 1 starts at %d ticks, want 48000", base)
+	}
+}
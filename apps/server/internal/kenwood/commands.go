@@ -0,0 +1,253 @@
+package kenwood
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rigctl"
+)
+
+// ts2000ID is what a TS-2000 answers to "ID;".
+const ts2000ID = "019"
+
+// client is one CAT connection. rx is the VFO set with FR, which MD and IF
+// act on.
+type client struct {
+	rig rigctl.Rig
+	rx  string
+	w   *bufio.Writer
+}
+
+// modes are Hamlib's names for the TS-2000 mode codes.
+var modes = [...]string{1: "LSB", 2: "USB", 3: "CW", 4: "FM", 5: "AM", 6: "RTTY", 7: "CWR", 9: "RTTYR"}
+
+// modeCodes maps the modes a slice can report onto the nearest TS-2000 code;
+// the radio's digital modes show as the sideband they are built on.
+var modeCodes = map[string]int{
+	"LSB": 1, "PKTLSB": 1,
+	"USB": 2, "PKTUSB": 2,
+	"CW": 3, "CWR": 7,
+	"FM": 4, "PKTFM": 4, "WFM": 4,
+	"AM": 5, "SAM": 5, "DSB": 5,
+	"RTTY": 6, "RTTYR": 9,
+}
+
+// command runs one command. Queries are answered with the command and its
+// value; settings are silent, as on the radio. Anything the emulation cannot
+// do is answered "?;".
+func (cl *client) command(cmd string) {
+	if len(cmd) < 2 {
+		cl.reply("?")
+
+		return
+	}
+
+	name, arg := strings.ToUpper(cmd[:2]), cmd[2:]
+
+	var err error
+
+	switch name {
+	case "FA":
+		err = cl.freq(name, rigctl.VFOA, arg)
+	case "FB":
+		err = cl.freq(name, rigctl.VFOB, arg)
+	case "MD":
+		err = cl.mode(arg)
+	case "TX":
+		err = cl.rig.SetPTT(true)
+	case "RX":
+		err = cl.rig.SetPTT(false)
+	case "IF":
+		err = cl.info()
+	case "FR":
+		err = cl.rxVFO(arg)
+	case "FT":
+		err = cl.txVFO(arg)
+	case "ID":
+		cl.reply("ID" + ts2000ID)
+	case "PS":
+		if arg == "" {
+			cl.reply("PS1")
+		}
+	case "AI":
+		// Auto-information is never sent; clients poll instead.
+		if arg == "" {
+			cl.reply("AI0")
+		}
+	default:
+		err = rigctl.ErrNotImplemented
+	}
+
+	if err != nil {
+		cl.reply("?")
+	}
+}
+
+func (cl *client) reply(s string) {
+	_, _ = cl.w.WriteString(s + ";")
+}
+
+func (cl *client) freq(name, vfo, arg string) error {
+	if arg != "" {
+		hz, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil || hz == 0 {
+			return rigctl.ErrInvalid
+		}
+
+		return cl.rig.SetFreq(vfo, float64(hz)) //nolint:wrapcheck // answered "?;"
+	}
+
+	hz, err := cl.rig.Freq(vfo)
+	if err != nil {
+		return err //nolint:wrapcheck // answered "?;"
+	}
+
+	cl.reply(fmt.Sprintf("%s%011.0f", name, hz))
+
+	return nil
+}
+
+func (cl *client) mode(arg string) error {
+	cur, _, err := cl.rig.Mode(cl.rx)
+	if err != nil {
+		return err //nolint:wrapcheck // answered "?;"
+	}
+
+	if arg == "" {
+		cl.reply(fmt.Sprintf("MD%d", modeCodes[cur]))
+
+		return nil
+	}
+
+	code, err := strconv.Atoi(arg)
+	if err != nil || code <= 0 || code >= len(modes) || modes[code] == "" {
+		return rigctl.ErrInvalid
+	}
+
+	// MD2 on a DIGU slice keeps DIGU: software that knows only USB must not
+	// knock a digital-mode slice out of its mode.
+	if modeCodes[cur] == code {
+		return nil
+	}
+
+	return cl.rig.SetMode(cl.rx, modes[code], 0) //nolint:wrapcheck // answered "?;"
+}
+
+func vfoCode(vfo string) int {
+	if vfo == rigctl.VFOB {
+		return 1
+	}
+
+	return 0
+}
+
+func parseVFO(arg string) (string, error) {
+	switch arg {
+	case "0":
+		return rigctl.VFOA, nil
+	case "1":
+		return rigctl.VFOB, nil
+	default:
+		return "", rigctl.ErrVFO
+	}
+}
+
+// rxVFO answers or sets FR. As on the radio, choosing the receive VFO also
+// makes it the transmit VFO.
+func (cl *client) rxVFO(arg string) error {
+	if arg == "" {
+		cl.reply(fmt.Sprintf("FR%d", vfoCode(cl.rx)))
+
+		return nil
+	}
+
+	vfo, err := parseVFO(arg)
+	if err != nil {
+		return err
+	}
+
+	cl.rx = vfo
+
+	split, _, err := cl.rig.Split()
+	if err != nil || !split {
+		return err //nolint:wrapcheck // answered "?;"
+	}
+
+	return cl.rig.SetSplit(false, vfo) //nolint:wrapcheck // answered "?;"
+}
+
+// txVFO answers or sets FT; a transmit VFO other than the receive VFO is
+// split.
+func (cl *client) txVFO(arg string) error {
+	if arg == "" {
+		split, tx, err := cl.rig.Split()
+		if err != nil {
+			return err //nolint:wrapcheck // answered "?;"
+		}
+
+		if !split {
+			tx = cl.rx
+		}
+
+		cl.reply(fmt.Sprintf("FT%d", vfoCode(tx)))
+
+		return nil
+	}
+
+	vfo, err := parseVFO(arg)
+	if err != nil {
+		return err
+	}
+
+	return cl.rig.SetSplit(vfo != cl.rx, vfo) //nolint:wrapcheck // answered "?;"
+}
+
+// info answers IF, the TS-2000's status summary: frequency, RIT/XIT, memory,
+// TX/RX, mode, VFO and split in fixed columns.
+func (cl *client) info() error {
+	hz, err := cl.rig.Freq(cl.rx)
+	if err != nil {
+		return err //nolint:wrapcheck // answered "?;"
+	}
+
+	mode, _, err := cl.rig.Mode(cl.rx)
+	if err != nil {
+		return err //nolint:wrapcheck // answered "?;"
+	}
+
+	tx, err := cl.rig.PTT()
+	if err != nil {
+		return err //nolint:wrapcheck // answered "?;"
+	}
+
+	split, _, err := cl.rig.Split()
+	if err != nil {
+		return err //nolint:wrapcheck // answered "?;"
+	}
+
+	cl.reply(fmt.Sprintf("IF%011.0f%05d%+05d%d%d%d%02d%d%d%d%d%d%d%02d%d",
+		hz,   // frequency
+		0,    // step
+		0,    // RIT/XIT offset
+		0, 0, // RIT, XIT
+		0, 0, // memory bank, channel
+		boolDigit(tx),    // TX/RX
+		modeCodes[mode],  // mode
+		vfoCode(cl.rx),   // VFO
+		0,                // scan
+		boolDigit(split), // split
+		0, 0, 0,          // tone, tone number, shift
+	))
+
+	return nil
+}
+
+func boolDigit(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
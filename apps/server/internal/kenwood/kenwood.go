@@ -0,0 +1,154 @@
+// Package kenwood emulates a Kenwood TS-2000's CAT protocol over TCP, for
+// software that can only drive a radio through a Kenwood "serial" port. Pair
+// it with a TCP-to-COM bridge such as com0com and hub4com, or point software
+// that accepts a network port straight at it.
+package kenwood
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rigctl"
+)
+
+// Options configures the server.
+type Options struct {
+	// Rig returns the radio a client at clientIP controls.
+	Rig func(clientIP string) rigctl.Rig
+	// Allowed filters client addresses, e.g. by the bridge's ACL; nil allows
+	// everyone.
+	Allowed func(net.IP) bool
+}
+
+// Server accepts CAT clients.
+type Server struct {
+	opt Options
+}
+
+func New(opt Options) *Server {
+	return &Server{opt: opt}
+}
+
+// ListenAndServe serves clients on addr until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cat listen: %w", err)
+	}
+
+	log.Printf("[cat] listening on %s", ln.Addr())
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts clients on ln until ctx is done.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			wg.Wait()
+
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("cat accept: %w", err)
+		}
+
+		ip := remoteIP(c)
+		if s.opt.Allowed != nil && !s.opt.Allowed(net.ParseIP(ip)) {
+			_ = c.Close()
+
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.serveConn(ctx, c, ip)
+		}()
+	}
+}
+
+func remoteIP(c net.Conn) string {
+	host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err != nil {
+		return c.RemoteAddr().String()
+	}
+
+	return host
+}
+
+// idleTimeout drops clients that have gone quiet; CAT software polls
+// several times a second.
+const idleTimeout = 5 * time.Minute
+
+func (s *Server) serveConn(ctx context.Context, c net.Conn, ip string) {
+	defer func() { _ = c.Close() }()
+
+	stop := context.AfterFunc(ctx, func() { _ = c.Close() })
+	defer stop()
+
+	log.Printf("[cat] client %s connected", ip)
+	defer log.Printf("[cat] client %s disconnected", ip)
+
+	cl := &client{rig: s.opt.Rig(ip), rx: rigctl.VFOA, w: bufio.NewWriter(c)}
+	rd := bufio.NewReader(c)
+
+	for {
+		_ = c.SetReadDeadline(time.Now().Add(idleTimeout))
+
+		cmd, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+
+		cl.command(cmd)
+
+		// Flush once the client has nothing more queued, so a burst of
+		// polls is answered in one write.
+		if rd.Buffered() > 0 {
+			continue
+		}
+
+		err = cl.w.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readCommand returns the next command without its ';'. Line breaks some
+// terminal programs add are ignored.
+func readCommand(rd *bufio.Reader) (string, error) {
+	var b []byte
+
+	for {
+		c, err := rd.ReadByte()
+		if err != nil {
+			return "", err //nolint:wrapcheck // only ends the connection
+		}
+
+		switch c {
+		case ';':
+			return string(b), nil
+		case '\r', '\n':
+		default:
+			b = append(b, c)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package kenwood
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rigctl"
+)
+
+type fakeRig struct {
+	freq  map[string]float64
+	mode  map[string]string
+	ptt   bool
+	split bool
+	tx    string
+	sets  []string
+}
+
+func newFakeRig() *fakeRig {
+	return &fakeRig{
+		freq: map[string]float64{rigctl.VFOA: 14074000, rigctl.VFOB: 7074000},
+		mode: map[string]string{rigctl.VFOA: "PKTUSB", rigctl.VFOB: "LSB"},
+		tx:   rigctl.VFOA,
+	}
+}
+
+func (f *fakeRig) Freq(vfo string) (float64, error) { return f.freq[vfo], nil }
+
+func (f *fakeRig) SetFreq(vfo string, hz float64) error {
+	f.freq[vfo] = hz
+
+	return nil
+}
+
+func (f *fakeRig) Mode(vfo string) (string, int, error) { return f.mode[vfo], 3000, nil }
+
+func (f *fakeRig) SetMode(vfo, mode string, _ int) error {
+	f.mode[vfo] = mode
+	f.sets = append(f.sets, vfo+" "+mode)
+
+	return nil
+}
+
+func (f *fakeRig) PTT() (bool, error) { return f.ptt, nil }
+
+func (f *fakeRig) SetPTT(on bool) error {
+	f.ptt = on
+
+	return nil
+}
+
+func (f *fakeRig) Split() (bool, string, error) { return f.split, f.tx, nil }
+
+func (f *fakeRig) SetSplit(on bool, tx string) error {
+	f.split, f.tx = on, tx
+	if !on {
+		f.tx = rigctl.VFOA
+	}
+
+	return nil
+}
+
+func run(rig rigctl.Rig, cmds string) string {
+	var out strings.Builder
+
+	cl := &client{rig: rig, rx: rigctl.VFOA, w: bufio.NewWriter(&out)}
+	rd := bufio.NewReader(strings.NewReader(cmds))
+
+	for {
+		cmd, err := readCommand(rd)
+		if err != nil {
+			break
+		}
+
+		cl.command(cmd)
+	}
+
+	_ = cl.w.Flush()
+
+	return out.String()
+}
+
+func TestClient_Commands(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name, input, want string
+	}{
+		{"get freq", "FA;FB;", "FA00014074000;FB00007074000;"},
+		{"set freq", "FA00014076000;FA;", "FA00014076000;"},
+		{"bad freq", "FAxyz;", "?;"},
+		{"mode", "MD;", "MD2;"},
+		{"set mode", "MD3;MD;", "MD3;"},
+		{"ptt", "TX;IF;RX;", "IF00014074000" + "00000" + "+0000" + "00000" + "120000000;"},
+		{"id", "ID;", "ID019;"},
+		{"auto info", "AI;AI2;", "AI0;"},
+		{"unknown", "ZZ;", "?;"},
+		{"line breaks", "FA;\r\nID;\n", "FA00014074000;ID019;"},
+		{"split", "FT1;FT;IF;", "FT1;IF00014074000" + "00000" + "+0000" + "00000" + "020010000;"},
+		{"rx vfo cancels split", "FT1;FR1;FR;FT;", "FR1;FT1;"},
+		{"short", "F;", "?;"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := run(newFakeRig(), tc.input); got != tc.want {
+				t.Errorf("%q:\ngot  %q\nwant %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClient_IFLength(t *testing.T) {
+	t.Parallel()
+
+	// Hamlib's TS-2000 backend reads IF by column.
+	got := run(newFakeRig(), "IF;")
+	if len(got) != 38 || got[28] != '0' || got[29] != '2' || got[30] != '0' || got[32] != '0' {
+		t.Errorf("IF = %q (%d bytes)", got, len(got))
+	}
+}
+
+func TestClient_KeepsDigitalMode(t *testing.T) {
+	t.Parallel()
+
+	rig := newFakeRig()
+	run(rig, "MD2;")
+
+	if len(rig.sets) != 0 || rig.mode[rigctl.VFOA] != "PKTUSB" {
+		t.Errorf("MD2 on a PKTUSB slice set %v", rig.sets)
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(Options{Rig: func(string) rigctl.Rig { return newFakeRig() }})
+
+	go func() { _ = srv.Serve(t.Context(), ln) }()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = c.Close() }()
+
+	_, err = c.Write([]byte("ID;FA;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+
+	var got string
+
+	for len(got) < len("ID019;FA00014074000;") {
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got += string(buf[:n])
+	}
+
+	if got != "ID019;FA00014074000;" {
+		t.Errorf("got %q", got)
+	}
+}
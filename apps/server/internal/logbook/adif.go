@@ -0,0 +1,110 @@
+package logbook
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record is one ADIF record: field values by upper-case field name, e.g.
+// CALL, QSO_DATE, TIME_ON, FREQ, MODE.
+type Record map[string]string
+
+// ADIF encodes the record, ending it with <EOR>. Fields are in name order so
+// the same record always reads the same.
+func (r Record) ADIF() string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	for _, name := range names {
+		v := r[name]
+		b.WriteString("<" + name + ":" + strconv.Itoa(len(v)) + ">" + v + " ")
+	}
+
+	b.WriteString("<EOR>\n")
+
+	return b.String()
+}
+
+// adifHeader starts a log file the bridge creates.
+const adifHeader = "solid-sdr log\n<ADIF_VER:5>3.1.4 <PROGRAMID:9>solid-sdr <EOH>\n"
+
+// parseADIF reads the complete records in b, skipping a header if there is
+// one, and returns them with what is left after the last <EOR>, the start of
+// a record still to come.
+func parseADIF(b []byte) ([]Record, []byte) {
+	var (
+		records []Record
+		cur     = Record{}
+		i, done int
+	)
+
+	for {
+		lt := indexByte(b, i, '<')
+		if lt < 0 {
+			break
+		}
+
+		gt := indexByte(b, lt, '>')
+		if gt < 0 {
+			break
+		}
+
+		spec := strings.Split(string(b[lt+1:gt]), ":")
+		name := strings.ToUpper(strings.TrimSpace(spec[0]))
+		i = gt + 1
+
+		switch name {
+		case "EOH":
+			// Everything before the header's end was the header.
+			cur = Record{}
+			done = i
+
+			continue
+		case "EOR":
+			if len(cur) > 0 {
+				records = append(records, cur)
+			}
+
+			cur = Record{}
+			done = i
+
+			continue
+		}
+
+		if len(spec) < 2 {
+			continue
+		}
+
+		n, err := strconv.Atoi(spec[1])
+		if err != nil || n < 0 {
+			continue
+		}
+
+		if i+n > len(b) {
+			break
+		}
+
+		cur[name] = string(b[i : i+n])
+		i += n
+	}
+
+	return records, b[done:]
+}
+
+// indexByte is the index of the first c in b at or after from, or -1.
+func indexByte(b []byte, from int, c byte) int {
+	i := bytes.IndexByte(b[from:], c)
+	if i < 0 {
+		return -1
+	}
+
+	return from + i
+}
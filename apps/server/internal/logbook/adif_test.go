@@ -0,0 +1,53 @@
+package logbook
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseADIF(t *testing.T) {
+	t.Parallel()
+
+	in := "Log4OM export\n<adif_ver:5>3.1.4 <eoh>\n" +
+		"<call:5>K1ABC <qso_date:8:D>20261015 <mode:3>FT8 <freq:9>14.074512 <eor>\n" +
+		"<CALL:4>W1AW<MODE:2>CW<EOR>" +
+		"<CALL:6>N0C"
+
+	records, rest := parseADIF([]byte(in))
+
+	if len(records) != 2 {
+		t.Fatalf("got %d records: %v", len(records), records)
+	}
+
+	want := Record{"CALL": "K1ABC", "QSO_DATE": "20261015", "MODE": "FT8", "FREQ": "14.074512"}
+	if !maps.Equal(records[0], want) {
+		t.Errorf("record = %v, want %v", records[0], want)
+	}
+
+	if records[1]["CALL"] != "W1AW" || records[1]["MODE"] != "CW" {
+		t.Errorf("second record = %v", records[1])
+	}
+
+	if string(rest) != "<CALL:6>N0C" {
+		t.Errorf("rest = %q", rest)
+	}
+
+	records, _ = parseADIF(append(rest, "ALL<EOR>"...))
+	if len(records) != 1 || records[0]["CALL"] != "N0CALL" {
+		t.Errorf("completed record = %v", records)
+	}
+}
+
+func TestRecord_ADIF(t *testing.T) {
+	t.Parallel()
+
+	r := Record{"MODE": "SSB", "CALL": "K1ABC"}
+	if got := r.ADIF(); got != "<CALL:5>K1ABC <MODE:3>SSB <EOR>\n" {
+		t.Errorf("ADIF() = %q", got)
+	}
+
+	back, _ := parseADIF([]byte(r.ADIF()))
+	if len(back) != 1 || !maps.Equal(back[0], r) {
+		t.Errorf("round trip = %v", back)
+	}
+}
@@ -0,0 +1,413 @@
+// Package logbook connects the bridge to logging software. It broadcasts what
+// each radio is tuned to as N1MM Logger+ RadioInfo packets, which loggers and
+// contest tools follow to fill in a QSO's frequency, mode and time, and it
+// takes ADIF records back over UDP or TCP, appending them to a log file and
+// passing them on to other loggers.
+package logbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBroadcast is where N1MM Logger+ sends its RadioInfo packets.
+	DefaultBroadcast = "127.0.0.1:12060"
+
+	// rebroadcast is how often an unchanged radio is broadcast again, so a
+	// logger started later picks it up.
+	rebroadcast = 10 * time.Second
+	// forwardTimeout bounds passing a record to a TCP logger.
+	forwardTimeout = 3 * time.Second
+	// maxRecord bounds the ADIF a TCP client may send without ending a
+	// record.
+	maxRecord = 64 * 1024
+)
+
+var errNoCall = errors.New("logbook: record has no CALL")
+
+// Radio is what a radio is tuned to, the context of a QSO started on it.
+type Radio struct {
+	// Radio is the radio's host:port, which names it in RadioInfo.
+	Radio    string
+	Callsign string
+	FreqHz   int64
+	// TXFreqHz differs from FreqHz when working split; zero means FreqHz.
+	TXFreqHz     int64
+	Mode         string
+	Transmitting bool
+}
+
+// Options configures the service.
+type Options struct {
+	// Station names the bridge in RadioInfo packets.
+	Station string
+	// Broadcast are UDP host:port addresses RadioInfo packets go to.
+	Broadcast []string
+	// Listen is the UDP and TCP address ADIF records are taken on; empty
+	// takes none over the network.
+	Listen string
+	// File is appended every record to; empty keeps none.
+	File string
+	// Forward are loggers every record is passed on to, as UDP host:port
+	// or tcp://host:port.
+	Forward []string
+}
+
+// radioState is a radio's last broadcast.
+type radioState struct {
+	nr    int
+	radio Radio
+	since time.Time
+}
+
+// Service broadcasts radio context and collects records.
+type Service struct {
+	opt       Options
+	broadcast []net.Conn
+	udp       *net.UDPConn
+	tcp       net.Listener
+
+	mu     sync.Mutex
+	radios map[string]*radioState
+	subs   []func(Record)
+	file   *os.File
+}
+
+// New opens the broadcast sockets, the listeners and the log file.
+func New(opt Options) (*Service, error) {
+	s := &Service{opt: opt, radios: make(map[string]*radioState)}
+
+	err := s.open()
+	if err != nil {
+		s.close()
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Service) open() error {
+	for _, addr := range s.opt.Broadcast {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			return fmt.Errorf("logbook broadcast: %w", err)
+		}
+
+		s.broadcast = append(s.broadcast, conn)
+	}
+
+	if s.opt.Listen != "" {
+		ua, err := net.ResolveUDPAddr("udp", s.opt.Listen)
+		if err != nil {
+			return fmt.Errorf("logbook listen: %w", err)
+		}
+
+		s.udp, err = net.ListenUDP("udp", ua)
+		if err != nil {
+			return fmt.Errorf("logbook listen: %w", err)
+		}
+
+		s.tcp, err = net.Listen("tcp", s.opt.Listen)
+		if err != nil {
+			return fmt.Errorf("logbook listen: %w", err)
+		}
+	}
+
+	if s.opt.File != "" {
+		f, err := os.OpenFile(s.opt.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644) //nolint:gosec // the operator's own log
+		if err != nil {
+			return fmt.Errorf("logbook file: %w", err)
+		}
+
+		s.file = f
+
+		if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+			_, err = f.WriteString(adifHeader)
+			if err != nil {
+				return fmt.Errorf("logbook file: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) close() {
+	for _, conn := range s.broadcast {
+		_ = conn.Close()
+	}
+
+	if s.udp != nil {
+		_ = s.udp.Close()
+	}
+
+	if s.tcp != nil {
+		_ = s.tcp.Close()
+	}
+
+	s.mu.Lock()
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+	s.mu.Unlock()
+}
+
+// Addr is the UDP address records are taken on, or nil.
+func (s *Service) Addr() net.Addr {
+	if s.udp == nil {
+		return nil
+	}
+
+	return s.udp.LocalAddr()
+}
+
+// Subscribe calls fn with every record logged from now on. fn must not
+// block.
+func (s *Service) Subscribe(fn func(Record)) {
+	s.mu.Lock()
+	s.subs = append(s.subs, fn)
+	s.mu.Unlock()
+}
+
+// Run takes records and broadcasts the radios again every so often until ctx
+// is done, then closes everything.
+func (s *Service) Run(ctx context.Context) error {
+	defer s.close()
+
+	if s.tcp != nil {
+		stop := context.AfterFunc(ctx, s.close)
+		defer stop()
+
+		log.Printf("[logbook] taking ADIF on %s (udp and tcp)", s.udp.LocalAddr())
+
+		go s.acceptTCP(ctx)
+		go s.readUDP(ctx)
+	}
+
+	t := time.NewTicker(rebroadcast)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			s.rebroadcast()
+		}
+	}
+}
+
+// Radio broadcasts what a radio is tuned to, if it changed. A new frequency
+// or mode starts a new QSO context, timestamped now.
+func (s *Service) Radio(r Radio) {
+	if len(s.broadcast) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+
+	st, ok := s.radios[r.Radio]
+	if !ok {
+		st = &radioState{nr: len(s.radios) + 1}
+		s.radios[r.Radio] = st
+	}
+
+	if ok && st.radio == r {
+		s.mu.Unlock()
+
+		return
+	}
+
+	if !ok || st.radio.FreqHz != r.FreqHz || st.radio.Mode != r.Mode {
+		st.since = time.Now()
+	}
+
+	st.radio = r
+	nr, since := st.nr, st.since
+	s.mu.Unlock()
+
+	s.send(nr, r, since)
+}
+
+func (s *Service) rebroadcast() {
+	s.mu.Lock()
+	states := make([]radioState, 0, len(s.radios))
+
+	for _, st := range s.radios {
+		states = append(states, *st)
+	}
+	s.mu.Unlock()
+
+	for _, st := range states {
+		s.send(st.nr, st.radio, st.since)
+	}
+}
+
+func (s *Service) send(nr int, r Radio, since time.Time) {
+	b, err := encodeRadioInfo(s.opt.Station, nr, r, since)
+	if err != nil {
+		log.Printf("[logbook] %v", err)
+
+		return
+	}
+
+	for _, conn := range s.broadcast {
+		_, err := conn.Write(b)
+		if err != nil {
+			log.Printf("[logbook] broadcast to %s: %v", conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// Log stores a record, passes it on and tells the subscribers. Field names
+// may be in any case.
+func (s *Service) Log(r Record) error {
+	rec := make(Record, len(r))
+	for k, v := range r {
+		rec[strings.ToUpper(k)] = v
+	}
+
+	if rec["CALL"] == "" {
+		return errNoCall
+	}
+
+	s.mu.Lock()
+
+	var err error
+	if s.file != nil {
+		_, err = s.file.WriteString(rec.ADIF())
+	}
+
+	subs := slices.Clone(s.subs)
+	s.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("logbook file: %w", err)
+	}
+
+	for _, fn := range subs {
+		fn(maps.Clone(rec))
+	}
+
+	if len(s.opt.Forward) > 0 {
+		go s.forward(rec)
+	}
+
+	return nil
+}
+
+// forward passes rec on to every logger in Forward.
+func (s *Service) forward(rec Record) {
+	b := []byte(rec.ADIF())
+
+	for _, dest := range s.opt.Forward {
+		network, addr := "udp", dest
+		if a, ok := strings.CutPrefix(dest, "tcp://"); ok {
+			network, addr = "tcp", a
+		}
+
+		conn, err := net.DialTimeout(network, addr, forwardTimeout)
+		if err != nil {
+			log.Printf("[logbook] forward to %s: %v", dest, err)
+
+			continue
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(forwardTimeout))
+
+		_, err = conn.Write(b)
+		if err != nil {
+			log.Printf("[logbook] forward to %s: %v", dest, err)
+		}
+
+		_ = conn.Close()
+	}
+}
+
+// logAll logs each record from a logger, reporting the ones refused.
+func (s *Service) logAll(records []Record, from net.Addr) {
+	for _, r := range records {
+		err := s.Log(r)
+		if err != nil {
+			log.Printf("[logbook] record from %s: %v", from, err)
+		}
+	}
+}
+
+// readUDP takes records a datagram at a time.
+func (s *Service) readUDP(ctx context.Context) {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, from, err := s.udp.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[logbook] udp: %v", err)
+			}
+
+			return
+		}
+
+		records, _ := parseADIF(buf[:n])
+		s.logAll(records, from)
+	}
+}
+
+func (s *Service) acceptTCP(ctx context.Context) {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[logbook] tcp: %v", err)
+			}
+
+			return
+		}
+
+		go s.readTCP(ctx, conn)
+	}
+}
+
+// readTCP takes records from a stream, each one once its <EOR> arrives.
+func (s *Service) readTCP(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	defer func() { _ = conn.Close() }()
+
+	buf := make([]byte, 4096)
+
+	var pending []byte
+
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			var records []Record
+
+			records, pending = parseADIF(append(pending, buf[:n]...))
+			s.logAll(records, conn.RemoteAddr())
+
+			if len(pending) > maxRecord {
+				log.Printf("[logbook] %s: record too long", conn.RemoteAddr())
+
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package logbook
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestService_Radio(t *testing.T) {
+	t.Parallel()
+
+	logger, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = logger.Close() })
+
+	s, err := New(Options{Station: "SHACK", Broadcast: []string{logger.LocalAddr().String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(s.close)
+
+	r := Radio{Radio: "192.168.1.20:4992", Callsign: "W1AW", FreqHz: 14074000, TXFreqHz: 14075500, Mode: "DIGU"}
+	s.Radio(r)
+	s.Radio(r) // unchanged, so not sent again
+
+	r.Transmitting = true
+	s.Radio(r)
+
+	buf := make([]byte, 4096)
+
+	_ = logger.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	n, err := logger.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(buf[:n])
+	for _, want := range []string{
+		"<StationName>SHACK</StationName>", "<RadioNr>1</RadioNr>", "<Freq>1407400</Freq>",
+		"<TXFreq>1407550</TXFreq>", "<Mode>USB</Mode>", "<OpCall>W1AW</OpCall>",
+		"<IsSplit>True</IsSplit>", "<IsTransmitting>False</IsTransmitting>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RadioInfo lacks %s:\n%s", want, got)
+		}
+	}
+
+	n, err = logger.Read(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), "<IsTransmitting>True</IsTransmitting>") {
+		t.Errorf("second packet: %q, %v", buf[:n], err)
+	}
+}
+
+func TestService_Records(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "log.adi")
+
+	s, err := New(Options{Listen: "127.0.0.1:0", File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logged := make(chan Record, 4)
+	s.Subscribe(func(r Record) { logged <- r })
+
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+
+	go func() { _ = s.Run(ctx) }()
+
+	udp, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = udp.Close() }()
+
+	_, _ = udp.Write([]byte("<call:5>K1ABC <mode:3>FT8 <eor>"))
+
+	tcp, err := net.Dial("tcp", s.tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = tcp.Close() }()
+
+	// A record split across writes is taken once it ends.
+	_, _ = tcp.Write([]byte("<CALL:4>W1"))
+	time.Sleep(20 * time.Millisecond)
+	_, _ = tcp.Write([]byte("AW<MODE:2>CW<EOR>"))
+
+	calls := map[string]bool{}
+
+	for range 2 {
+		select {
+		case r := <-logged:
+			calls[r["CALL"]] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("logged %v", calls)
+		}
+	}
+
+	if !calls["K1ABC"] || !calls["W1AW"] {
+		t.Errorf("logged %v", calls)
+	}
+
+	err = s.Log(Record{"mode": "SSB"})
+	if err == nil {
+		t.Error("a record without a call was logged")
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(b), adifHeader) || strings.Count(string(b), "<EOR>") != 2 {
+		t.Errorf("log file:\n%s", b)
+	}
+}
@@ -0,0 +1,93 @@
+package logbook
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// radioInfo is N1MM Logger+'s RadioInfo broadcast, which loggers, band
+// decoders and rotator software follow. Frequencies are in tens of hertz.
+// Timestamp is not N1MM's own; it carries the time the context applies from,
+// in the form N1MM's contact broadcasts use.
+type radioInfo struct {
+	XMLName        xml.Name `xml:"RadioInfo"`
+	App            string   `xml:"app"`
+	StationName    string   `xml:"StationName"`
+	RadioNr        int      `xml:"RadioNr"`
+	Freq           int64    `xml:"Freq"`
+	TXFreq         int64    `xml:"TXFreq"`
+	Mode           string   `xml:"Mode"`
+	OpCall         string   `xml:"OpCall"`
+	IsRunning      string   `xml:"IsRunning"`
+	FocusEntry     int      `xml:"FocusEntry"`
+	Antenna        int      `xml:"Antenna"`
+	Rotors         string   `xml:"Rotors"`
+	FocusRadioNr   int      `xml:"FocusRadioNr"`
+	IsStereo       string   `xml:"IsStereo"`
+	IsSplit        string   `xml:"IsSplit"`
+	ActiveRadioNr  int      `xml:"ActiveRadioNr"`
+	IsTransmitting string   `xml:"IsTransmitting"`
+	RadioName      string   `xml:"RadioName"`
+	IsConnected    string   `xml:"IsConnected"`
+	Timestamp      string   `xml:"Timestamp"`
+}
+
+// n1mmModes are the N1MM names of the radio's modes; N1MM names the radio's
+// sideband for digital modes, leaving the digital mode to the logger.
+var n1mmModes = map[string]string{
+	"DIGU": "USB",
+	"DIGL": "LSB",
+	"SAM":  "AM",
+	"NFM":  "FM",
+	"DFM":  "FM",
+}
+
+// encodeRadioInfo renders st as radio nr of station.
+func encodeRadioInfo(station string, nr int, st Radio, at time.Time) ([]byte, error) {
+	mode := strings.ToUpper(st.Mode)
+	if m, ok := n1mmModes[mode]; ok {
+		mode = m
+	}
+
+	txHz := st.TXFreqHz
+	if txHz == 0 {
+		txHz = st.FreqHz
+	}
+
+	info := radioInfo{
+		App:            "solid-sdr",
+		StationName:    station,
+		RadioNr:        nr,
+		Freq:           st.FreqHz / 10,
+		TXFreq:         txHz / 10,
+		Mode:           mode,
+		OpCall:         st.Callsign,
+		IsRunning:      "False",
+		FocusRadioNr:   nr,
+		IsStereo:       "False",
+		IsSplit:        n1mmBool(txHz != st.FreqHz),
+		ActiveRadioNr:  nr,
+		IsTransmitting: n1mmBool(st.Transmitting),
+		RadioName:      st.Radio,
+		IsConnected:    "True",
+		Timestamp:      at.UTC().Format(time.DateTime),
+	}
+
+	b, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode RadioInfo: %w", err)
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
+
+// n1mmBool is a boolean as N1MM writes it.
+func n1mmBool(b bool) string {
+	if b {
+		return "True"
+	}
+
+	return "False"
+}
@@ -0,0 +1,124 @@
+// Package metrics writes series in the Prometheus text exposition format,
+// for scraping the bridge without a client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the exposition format's media type.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Types of a family.
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Label is one name="value" pair of a series.
+type Label struct {
+	Name, Value string
+}
+
+type sample struct {
+	labels []Label
+	value  float64
+}
+
+type family struct {
+	name, typ, help string
+	samples         []sample
+}
+
+// Exposition gathers samples into families, written in the order each was
+// first added, as the format requires a family's samples to be together.
+type Exposition struct {
+	families []*family
+	byName   map[string]*family
+}
+
+// Add adds a sample of the family name, of type typ. Labels with empty
+// values are left out, as Prometheus treats them as absent anyway.
+func (e *Exposition) Add(name, typ, help string, value float64, labels ...Label) {
+	if e.byName == nil {
+		e.byName = make(map[string]*family)
+	}
+
+	f := e.byName[name]
+	if f == nil {
+		f = &family{name: name, typ: typ, help: help}
+		e.byName[name] = f
+		e.families = append(e.families, f)
+	}
+
+	var kept []Label
+
+	for _, l := range labels {
+		if l.Value != "" {
+			kept = append(kept, l)
+		}
+	}
+
+	f.samples = append(f.samples, sample{labels: kept, value: value})
+}
+
+// WriteTo writes every family.
+func (e *Exposition) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, f := range e.families {
+		_, _ = fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+
+		for _, s := range f.samples {
+			_, _ = bw.WriteString(f.name)
+
+			if len(s.labels) > 0 {
+				_ = bw.WriteByte('{')
+
+				for i, l := range s.labels {
+					if i > 0 {
+						_ = bw.WriteByte(',')
+					}
+
+					_, _ = fmt.Fprintf(bw, "%s=\"%s\"", l.Name, escapeValue(l.Value))
+				}
+
+				_ = bw.WriteByte('}')
+			}
+
+			_, _ = fmt.Fprintf(bw, " %s\n", strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+
+	err := bw.Flush()
+	if err != nil {
+		return cw.n, fmt.Errorf("metrics: %w", err)
+	}
+
+	return cw.n, nil
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	valueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeValue(s string) string { return valueEscaper.Replace(s) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err //nolint:wrapcheck // WriteTo wraps it
+}
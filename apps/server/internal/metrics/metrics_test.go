@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExposition_WriteTo(t *testing.T) {
+	t.Parallel()
+
+	var e Exposition
+
+	radio := func(serial, nick string) []Label {
+		return []Label{{"serial", serial}, {"nickname", nick}, {"session", ""}}
+	}
+
+	e.Add("solidsdr_bytes_total", Counter, "Bytes.", 10, radio("1225-1213", `Shack "A"`)...)
+	e.Add("solidsdr_sessions", Gauge, "Sessions\nopen.", 2)
+	e.Add("solidsdr_bytes_total", Counter, "Bytes.", 0.5, radio("0721-4400", "Remote")...)
+
+	var b strings.Builder
+
+	n, err := e.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP solidsdr_bytes_total Bytes.
+# TYPE solidsdr_bytes_total counter
+solidsdr_bytes_total{serial="1225-1213",nickname="Shack \"A\""} 10
+solidsdr_bytes_total{serial="0721-4400",nickname="Remote"} 0.5
+# HELP solidsdr_sessions Sessions\nopen.
+# TYPE solidsdr_sessions gauge
+solidsdr_sessions 2
+`
+	if b.String() != want || n != int64(len(want)) {
+		t.Errorf("wrote %d:\n%s\nwant:\n%s", n, b.String(), want)
+	}
+}
@@ -0,0 +1,208 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/txaudio"
+)
+
+// EventMic is published whenever the microphone's state changes.
+const EventMic = "monitor.mic"
+
+var (
+	// ErrNoMic is returned when the monitor has no microphone configured.
+	ErrNoMic        = errors.New("monitor: no microphone is configured")
+	errNotConnected = errors.New("monitor: not connected to the radio")
+)
+
+// MicState is the bridge host's microphone, as the mic endpoint reports it.
+type MicState struct {
+	Device string `json:"device"`
+	// Capture is whether the microphone is being recorded and sent on the
+	// radio's TX audio stream.
+	Capture bool `json:"capture"`
+	// Transmitting is whether the monitor's PTT is keyed.
+	Transmitting bool   `json:"transmitting"`
+	Error        string `json:"error,omitempty"`
+}
+
+// MicRequest changes the fields given. Transmit keys the monitor's session
+// as its "ptt" message does, so it must be repeated within --ptt-keepalive
+// for as long as it transmits.
+type MicRequest struct {
+	Capture  *bool `json:"capture,omitempty"`
+	Transmit *bool `json:"transmit,omitempty"`
+}
+
+// Mic reports the microphone's state.
+func (m *Monitor) Mic() (MicState, error) {
+	if m.opt.Mic == "" {
+		return MicState{}, ErrNoMic
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mic, nil
+}
+
+// SetMic applies req: starting or stopping the capture, then keying or
+// releasing the transmitter.
+func (m *Monitor) SetMic(req MicRequest) (MicState, error) {
+	if m.opt.Mic == "" {
+		return MicState{}, ErrNoMic
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.h == nil {
+		return m.mic, errNotConnected
+	}
+
+	if req.Capture != nil {
+		var err error
+		if *req.Capture {
+			err = m.startCapture()
+		} else {
+			m.stopCapture()
+		}
+
+		m.publish()
+
+		if err != nil {
+			return m.mic, err
+		}
+	}
+
+	if req.Transmit != nil {
+		raw, _ := json.Marshal(map[string]bool{"on": *req.Transmit}) //nolint:errchkjson // a map of bool
+
+		err := m.h.Signal("ptt", raw)
+		if err != nil {
+			return m.mic, fmt.Errorf("monitor: %w", err)
+		}
+	}
+
+	return m.mic, nil
+}
+
+// startCapture records the microphone onto the session's TX audio stream,
+// unless it already is. m.mu is held.
+func (m *Monitor) startCapture() error {
+	if m.capture != nil {
+		return nil
+	}
+
+	h := m.h
+
+	cp, err := txaudio.StartCapture(m.ctx, m.opt.FFmpeg, m.opt.Mic, m.opt.MicChain, func(opus []byte) {
+		_ = h.SendAudio(opus)
+	})
+	if err != nil {
+		m.mic.Capture, m.mic.Error = false, err.Error()
+
+		return fmt.Errorf("monitor: capturing %s: %w", m.opt.Mic, err)
+	}
+
+	m.capture = cp
+	m.mic.Capture, m.mic.Error = true, ""
+
+	go func() {
+		<-cp.Done()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if m.capture == cp {
+			m.capture = nil
+			m.mic.Capture, m.mic.Error = false, "capture stopped"
+			m.publish()
+		}
+	}()
+
+	return nil
+}
+
+// stopCapture stops recording the microphone. m.mu is held.
+func (m *Monitor) stopCapture() {
+	cp := m.capture
+	m.capture = nil
+	m.mic.Capture = false
+
+	if cp != nil {
+		cp.Close()
+	}
+}
+
+// attach makes h the session the microphone sends on, resuming a capture
+// that was on before a reconnect.
+func (m *Monitor) attach(ctx context.Context, h *rtc.Headless) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.h, m.ctx = h, ctx
+	if !m.resume {
+		return
+	}
+
+	err := m.startCapture()
+	if err != nil {
+		log.Printf("[monitor] %v", err)
+	}
+
+	m.publish()
+}
+
+// detach stops the capture when the session ends, to resume it on the
+// next.
+func (m *Monitor) detach() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resume = m.capture != nil
+	m.stopCapture()
+	m.h, m.ctx = nil, nil
+	m.mic.Transmitting = false
+	m.publish()
+}
+
+// notice follows the session's PTT from its "ptt" messages, and why keying
+// was refused from its errors.
+func (m *Monitor) notice(typ string, payload json.RawMessage) {
+	var p struct {
+		On      bool   `json:"on"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	if (typ != "ptt" && typ != "error") || json.Unmarshal(payload, &p) != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case typ == "ptt" && m.mic.Transmitting != p.On:
+		m.mic.Transmitting = p.On
+	case p.Code == "PTT_REFUSED":
+		m.mic.Error = p.Message
+	default:
+		return
+	}
+
+	m.publish()
+}
+
+// publish sends the microphone's state to the event stream. m.mu is held.
+func (m *Monitor) publish() {
+	if m.opt.Mic != "" {
+		m.opt.Events.Publish(EventMic, m.mic)
+	}
+}
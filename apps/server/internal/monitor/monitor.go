@@ -0,0 +1,329 @@
+// Package monitor plays a radio's receive audio on a local sound device, so
+// the bridge on its own, say a Raspberry Pi beside a powered speaker, is a
+// remote receiver with no browser at all. The bridge passes Opus through and
+// has no decoder of its own, so ffmpeg decodes the audio and writes it to
+// ALSA or CoreAudio; on Windows, where ffmpeg has no audio output, ffplay
+// plays it on the default WASAPI device. With a microphone too, ffmpeg
+// records and encodes it for the radio's TX audio stream, making the host a
+// complete remote head.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/events"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/txaudio"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+const (
+	// DefaultDevice is the system's default output on every platform.
+	DefaultDevice = "default"
+	// retry is how long the monitor waits before reconnecting after the
+	// radio or the player goes away.
+	retry = 5 * time.Second
+)
+
+var (
+	errNoPlayer     = errors.New("monitor: player was not found")
+	errSessionEnded = errors.New("monitor: radio session ended")
+	errPlayerExited = errors.New("monitor: player exited")
+)
+
+// Radios opens the monitor's session, as rtc.Server does.
+type Radios interface {
+	OpenHeadless(ctx context.Context, opt rtc.HeadlessOptions) (*rtc.Headless, error)
+}
+
+// Options select the radio, what to listen to and where to play it.
+type Options struct {
+	Radios Radios
+	// Radio is host:port of the radio's API.
+	Radio string
+	// Device is the output: an ALSA PCM name on Linux, an audio device
+	// index on macOS, or DefaultDevice.
+	Device string
+	// FFmpeg is the ffmpeg binary; default "ffmpeg" on PATH. On Windows the
+	// ffplay beside it is used.
+	FFmpeg string
+	// Station is the multiFLEX station the monitor registers as.
+	Station string
+	// Frequency (MHz) and Mode are the slice the monitor opens.
+	Frequency float64
+	Mode      string
+	// Mic is the sound device to record for TX, in ffmpeg's terms for the
+	// platform (see txaudio.StartCapture); empty for none.
+	Mic string
+	// MicChain processes the microphone before it is sent.
+	MicChain txaudio.Chain
+	// Events receives the microphone's state changes; may be nil.
+	Events *events.Bus
+}
+
+// Monitor keeps a session on the radio and its audio playing.
+type Monitor struct {
+	opt Options
+
+	mu      sync.Mutex
+	h       *rtc.Headless
+	ctx     context.Context //nolint:containedctx // the capture runs under the session's context
+	capture *txaudio.Capture
+	mic     MicState
+	// resume restarts the capture on reconnecting.
+	resume bool
+}
+
+// New returns a Monitor; Run starts it.
+func New(opt Options) *Monitor {
+	if opt.Device == "" {
+		opt.Device = DefaultDevice
+	}
+
+	if opt.FFmpeg == "" {
+		opt.FFmpeg = "ffmpeg"
+	}
+
+	if opt.Station == "" {
+		opt.Station = "Monitor"
+	}
+
+	if opt.Mode == "" {
+		opt.Mode = "USB"
+	}
+
+	return &Monitor{opt: opt, mic: MicState{Device: opt.Mic}}
+}
+
+// Run plays the radio until ctx is done, reconnecting whenever the session
+// or the player ends.
+func (m *Monitor) Run(ctx context.Context) error {
+	for {
+		err := m.listen(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if errors.Is(err, errNoPlayer) {
+			return err
+		}
+
+		log.Printf("[monitor] %v; reconnecting in %s", err, retry)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+	}
+}
+
+// listen runs one session: it opens a slice and the radio's Opus stream,
+// and plays the stream until either side ends.
+func (m *Monitor) listen(ctx context.Context) error {
+	bin, args := command(m.opt.FFmpeg, m.opt.Device)
+
+	_, err := exec.LookPath(bin)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errNoPlayer, bin)
+	}
+
+	h, err := m.opt.Radios.OpenHeadless(ctx, rtc.HeadlessOptions{
+		Radio:    m.opt.Radio,
+		ClientIP: "monitor",
+		UDP:      true,
+		Station:  m.opt.Station,
+	})
+	if err != nil {
+		return fmt.Errorf("monitor: %w", err)
+	}
+
+	defer h.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	played := make(chan error, 1)
+
+	go func() { played <- play(ctx, bin, args, h.Audio()) }()
+
+	cmds := setup(m.opt.Frequency, m.opt.Mode, m.opt.Mic != "")
+	for i, cmd := range cmds {
+		err := h.Command(fmt.Sprintf("C%d|%s", i+1, cmd))
+		if err != nil {
+			return fmt.Errorf("monitor: %s: %w", cmd, err)
+		}
+	}
+
+	log.Printf("[monitor] playing %s on %s (%s)", m.opt.Radio, m.opt.Device, h.ID())
+
+	m.attach(ctx, h)
+	defer m.detach()
+
+	for {
+		select {
+		case line := <-h.Lines():
+			if seq, msg, failed := failedReply(line); failed && seq >= 1 && seq <= len(cmds) {
+				log.Printf("[monitor] %q failed: %s", cmds[seq-1], msg)
+			}
+		case <-h.Packets():
+		case n := <-h.Notices():
+			m.notice(n.Type, n.Payload)
+		case err := <-played:
+			return err
+		case <-h.Done():
+			return errSessionEnded
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// setup is the commands that open the monitor's slice and its audio and,
+// with a microphone, send TX audio from it.
+func setup(freq float64, mode string, mic bool) []string {
+	cmds := []string{
+		fmt.Sprintf("slice create freq=%.6f mode=%s", freq, strings.ToUpper(mode)),
+		"stream create type=remote_audio_rx compression=OPUS",
+	}
+
+	if mic {
+		cmds = append(cmds, "stream create type=remote_audio_tx compression=OPUS", "mic input PC")
+	}
+
+	return cmds
+}
+
+// failedReply parses an "R<seq>|<code>|<message>" reply and reports whether
+// its code is an error.
+func failedReply(line string) (int, string, bool) {
+	rest, ok := strings.CutPrefix(line, "R")
+	if !ok {
+		return 0, "", false
+	}
+
+	parts := strings.SplitN(rest, "|", 3)
+	if len(parts) < 2 || strings.Trim(parts[1], "0") == "" {
+		return 0, "", false
+	}
+
+	var seq int
+
+	_, err := fmt.Sscan(parts[0], &seq)
+	if err != nil {
+		return 0, "", false
+	}
+
+	msg := "error " + parts[1]
+	if len(parts) == 3 && parts[2] != "" {
+		msg += ": " + parts[2]
+	}
+
+	return seq, msg, true
+}
+
+// command is the player and its arguments: Ogg/Opus on stdin, out to the
+// device, with as little buffering as it allows.
+func command(ffmpeg, device string) (string, []string) {
+	in := []string{
+		"-nostdin", "-loglevel", "error",
+		"-fflags", "nobuffer", "-flags", "low_delay", "-probesize", "32", "-analyzeduration", "0",
+		"-f", "ogg", "-i", "pipe:0",
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		ffplay := "ffplay"
+		if dir := filepath.Dir(ffmpeg); dir != "." {
+			ffplay = filepath.Join(dir, "ffplay.exe")
+		}
+
+		// ffplay takes no -nostdin, and plays on the default device.
+		return ffplay, append([]string{"-nodisp", "-autoexit"}, in[1:]...)
+	case "darwin":
+		args := append(in, "-f", "audiotoolbox")
+		if device != DefaultDevice {
+			args = append(args, "-audio_device_index", device)
+		}
+
+		return ffmpeg, append(args, "-")
+	default:
+		return ffmpeg, append(in, "-f", "alsa", device)
+	}
+}
+
+// play pipes audio to the player as Ogg/Opus until audio closes, ctx ends
+// or the player exits.
+func play(ctx context.Context, bin string, args []string, audio <-chan []byte) error {
+	cmd := exec.CommandContext(ctx, bin, args...) //nolint:gosec // binary is operator configuration
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("monitor: %w", err)
+	}
+
+	var stderr strings.Builder
+
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("monitor: %w", err)
+	}
+
+	exited := make(chan error, 1)
+
+	go func() { exited <- cmd.Wait() }()
+
+	err = feed(ctx, stdin, audio)
+	_ = stdin.Close()
+
+	select {
+	case werr := <-exited:
+		if werr != nil && ctx.Err() == nil {
+			return fmt.Errorf("%w: %v: %s", errPlayerExited, werr, strings.TrimSpace(stderr.String()))
+		}
+	case <-time.After(2 * time.Second):
+		_ = cmd.Process.Kill()
+
+		<-exited
+	}
+
+	return err
+}
+
+// feed writes each packet to w as Ogg/Opus until audio closes, ctx ends or
+// w fails.
+func feed(ctx context.Context, w io.Writer, audio <-chan []byte) error {
+	ogg, err := oggwriter.NewWith(w, 48000, 2)
+	if err != nil {
+		return fmt.Errorf("monitor: %w", err)
+	}
+
+	for {
+		select {
+		case pkt, ok := <-audio:
+			if !ok {
+				return errSessionEnded
+			}
+
+			err := ogg.WriteRTP(&rtp.Packet{Payload: pkt})
+			if err != nil {
+				return fmt.Errorf("%w: %w", errPlayerExited, err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
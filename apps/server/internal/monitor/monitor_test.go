@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestFailedReply(t *testing.T) {
+	t.Parallel()
+
+	seq, msg, failed := failedReply("R2|50000015|Unable to create slice")
+	if !failed || seq != 2 || msg != "error 50000015: Unable to create slice" {
+		t.Errorf("got %d, %q, %v", seq, msg, failed)
+	}
+
+	for _, line := range []string{"R1|0|", "R1|00000000|1", "S1234|slice 0 in_use=1", "R|x"} {
+		if _, _, failed := failedReply(line); failed {
+			t.Errorf("%q is a failure", line)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	t.Parallel()
+
+	want := []string{
+		"slice create freq=7.074000 mode=DIGU",
+		"stream create type=remote_audio_rx compression=OPUS",
+	}
+	if got := setup(7.074, "digu", false); !slices.Equal(got, want) {
+		t.Errorf("setup = %q", got)
+	}
+
+	want = append(want, "stream create type=remote_audio_tx compression=OPUS", "mic input PC")
+	if got := setup(7.074, "digu", true); !slices.Equal(got, want) {
+		t.Errorf("setup with a mic = %q", got)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("ALSA output")
+	}
+
+	bin, args := command("/usr/bin/ffmpeg", "plughw:1,0")
+	if bin != "/usr/bin/ffmpeg" || !slices.Equal(args[len(args)-3:], []string{"-f", "alsa", "plughw:1,0"}) {
+		t.Errorf("command = %s %q", bin, args)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	t.Parallel()
+
+	audio := make(chan []byte, 2)
+	audio <- []byte{0xF8, 1, 2, 3}
+	close(audio)
+
+	var out bytes.Buffer
+
+	err := feed(t.Context(), &out, audio)
+	if !errors.Is(err, errSessionEnded) {
+		t.Errorf("err = %v", err)
+	}
+
+	// OpusHead, OpusTags and the packet, a page each.
+	if n := bytes.Count(out.Bytes(), []byte("OggS")); n != 3 {
+		t.Errorf("%d Ogg pages", n)
+	}
+}
@@ -1,13 +1,18 @@
-// Package nat maps UDP ports on the local gateway via NAT-PMP/UPnP.
+// Package nat maps ports on the local gateway via PCP, NAT-PMP or UPnP.
 package nat
 
 import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
 	"time"
 
 	gonat "github.com/fd/go-nat"
+	"github.com/jackpal/gateway"
 )
 
 var (
@@ -16,24 +21,50 @@ var (
 )
 
 type Mapper struct {
-	nat gonat.NAT
+	nat      gonat.NAT
+	protocol string // the gonat.NAT type, e.g. "PCP" or "UPNP (IG2)"
+	gateway  net.IP
+	external string
 	// keep what we mapped so we can clean up
-	maps []mapping
+	mu   sync.Mutex
+	maps []Mapping
 	stop chan struct{}
 }
 
-type mapping struct {
-	Proto       string
-	Internal    int
-	External    int
-	Description string
-	TTL         time.Duration
+type Mapping struct {
+	Proto       string        `json:"proto"`
+	Internal    int           `json:"internal"`
+	External    int           `json:"external"`
+	Description string        `json:"description"`
+	TTL         time.Duration `json:"ttl"`
 }
 
+// Status describes the active gateway and its mappings for the admin API.
+type Status struct {
+	Protocol   string    `json:"protocol"`
+	Gateway    string    `json:"gateway"`
+	ExternalIP string    `json:"externalIP"`
+	Mappings   []Mapping `json:"mappings"`
+}
+
+// Discover finds a gateway that maps ports. Every default gateway (a host on
+// both Wi-Fi and Ethernet has two) is asked for PCP, then NAT-PMP; UPnP-IGD
+// discovery via SSDP runs only when none answers either, since many modern
+// routers speak PCP alone.
 func Discover() (*Mapper, string, error) {
-	n, err := gonat.DiscoverGateway()
+	gateways, err := gateway.DiscoverGateways()
 	if err != nil {
-		return nil, "", fmt.Errorf("nat discovery: %w", err)
+		log.Printf("[nat] gateway lookup: %v", err)
+	}
+
+	n, err := discover(ipv4Only(gateways), pcpProbe(pcpPort), natpmpProbe)
+	if err != nil {
+		log.Printf("[nat] no PCP or NAT-PMP gateway, trying UPnP")
+
+		n, err = gonat.DiscoverGateway()
+		if err != nil {
+			return nil, "", fmt.Errorf("nat discovery: %w", err)
+		}
 	}
 
 	if n == nil {
@@ -45,11 +76,72 @@ func Discover() (*Mapper, string, error) {
 		return nil, "", fmt.Errorf("external ip: %w", err)
 	}
 
-	return &Mapper{nat: n, stop: make(chan struct{})}, ip.String(), nil
+	gw, _ := n.GetDeviceAddress()
+	log.Printf("[nat] using %s via gateway %v", n.Type(), gw)
+
+	m := &Mapper{nat: n, protocol: n.Type(), gateway: gw, external: ip.String(), stop: make(chan struct{})}
+
+	return m, m.external, nil
+}
+
+// probe asks one gateway for one port mapping protocol.
+type probe func(gw net.IP) (gonat.NAT, error)
+
+func pcpProbe(port int) probe {
+	return func(gw net.IP) (gonat.NAT, error) { return discoverPCP(gw, port) }
+}
+
+func natpmpProbe(gw net.IP) (gonat.NAT, error) { return discoverNATPMP(gw) }
+
+// discover runs each probe against every gateway in turn and returns the
+// first that answers, so a protocol earlier in the list wins on any gateway.
+func discover(gateways []net.IP, probes ...probe) (gonat.NAT, error) {
+	for _, try := range probes {
+		for _, gw := range gateways {
+			n, err := try(gw)
+			if err == nil {
+				return n, nil
+			}
+
+			log.Printf("[nat] %s: %v", gw, err)
+		}
+	}
+
+	return nil, errNoNATDevice
+}
+
+// ipv4Only drops IPv6 and duplicate gateways; PCP and NAT-PMP mappings here
+// are for IPv4 NAT.
+func ipv4Only(ips []net.IP) []net.IP {
+	var out []net.IP
+
+	for _, ip := range ips {
+		v4 := ip.To4()
+		if v4 == nil || slices.ContainsFunc(out, v4.Equal) {
+			continue
+		}
+
+		out = append(out, v4)
+	}
+
+	return out
+}
+
+func mapKey(proto string, internal int) string {
+	return proto + "/" + strconv.Itoa(internal)
 }
 
 // MapUDP maps a UDP port. If external==0, most implementations will pick same as internal.
 func (m *Mapper) MapUDP(internal int, desc string, ttl time.Duration) error {
+	return m.add("udp", internal, desc, ttl)
+}
+
+// MapTCP maps a TCP port, e.g. the HTTP listener.
+func (m *Mapper) MapTCP(internal int, desc string, ttl time.Duration) error {
+	return m.add("tcp", internal, desc, ttl)
+}
+
+func (m *Mapper) add(proto string, internal int, desc string, ttl time.Duration) error {
 	if m == nil || m.nat == nil {
 		return errNATMapperNotReady
 	}
@@ -58,19 +150,49 @@ func (m *Mapper) MapUDP(internal int, desc string, ttl time.Duration) error {
 		ttl = 30 * time.Minute
 	}
 
-	external, err := m.nat.AddPortMapping("udp", internal, desc, ttl)
+	external, err := m.nat.AddPortMapping(proto, internal, desc, ttl)
 	if err != nil {
-		return fmt.Errorf("map udp port %d: %w", internal, err)
+		return fmt.Errorf("map %s port %d: %w", proto, internal, err)
 	}
 
-	log.Printf("[nat] mapped udp %d->%d (%s) ttl %s", internal, external, desc, ttl)
-	m.maps = append(m.maps, mapping{
-		Proto: "udp", Internal: internal, External: external, Description: desc, TTL: ttl,
+	log.Printf("[nat] mapped %s %d->%d (%s) ttl %s", proto, internal, external, desc, ttl)
+	m.mu.Lock()
+	m.maps = append(m.maps, Mapping{
+		Proto: proto, Internal: internal, External: external, Description: desc, TTL: ttl,
 	})
+	m.mu.Unlock()
 
 	return nil
 }
 
+// Status reports the protocol, gateway and mappings in use.
+func (m *Mapper) Status() Status {
+	st := Status{Mappings: m.Mappings()}
+	if st.Mappings == nil {
+		st.Mappings = []Mapping{}
+	}
+
+	if m != nil {
+		st.Protocol = m.protocol
+		st.Gateway = m.gateway.String()
+		st.ExternalIP = m.external
+	}
+
+	return st
+}
+
+// Mappings returns a copy of the currently held port mappings.
+func (m *Mapper) Mappings() []Mapping {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Mapping(nil), m.maps...)
+}
+
 // StartRefresher starts a refresher that renews all mappings before TTL expiry.
 func (m *Mapper) StartRefresher(interval time.Duration) {
 	if m == nil || m.nat == nil {
@@ -90,29 +212,34 @@ func (m *Mapper) StartRefresher(interval time.Duration) {
 			case <-m.stop:
 				return
 			case <-t.C:
-				for _, mp := range m.maps {
+				m.mu.Lock()
+				for i, mp := range m.maps {
 					// re-add to extend TTL
 					external, err := m.nat.AddPortMapping(mp.Proto, mp.Internal, mp.Description, mp.TTL)
 					if err != nil {
 						log.Printf("[nat] refresh %s %d->%d failed: %v", mp.Proto, mp.Internal, mp.External, err)
 					} else {
-						mp.External = external // in case it changed
+						m.maps[i].External = external // in case it changed
 					}
 				}
+				m.mu.Unlock()
 			}
 		}
 	}()
 }
 
 func (m *Mapper) Close() {
-	log.Printf("[nat] closing")
-
 	if m == nil || m.nat == nil {
 		return
 	}
 
+	log.Printf("[nat] closing")
+
 	close(m.stop)
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, mp := range m.maps {
 		log.Printf("[nat] removing %s %d->%d", mp.Proto, mp.Internal, mp.External)
 
@@ -0,0 +1,101 @@
+package nat
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	gonat "github.com/fd/go-nat"
+	natpmp "github.com/jackpal/go-nat-pmp"
+)
+
+const natpmpTimeout = 2 * time.Second
+
+var _ gonat.NAT = (*natpmpNAT)(nil)
+
+// natpmpNAT is a NAT-PMP (RFC 6886) client for a single gateway. Unlike the
+// go-nat one it requests the internal port as the external port and really
+// releases mappings on delete.
+type natpmpNAT struct {
+	c       *natpmp.Client
+	gateway net.IP
+	local   net.IP
+
+	mu    sync.Mutex
+	ports map[string]int // proto/internal -> external
+}
+
+func discoverNATPMP(gateway net.IP) (*natpmpNAT, error) {
+	c := natpmp.NewClientWithTimeout(gateway, natpmpTimeout)
+
+	_, err := c.GetExternalAddress()
+	if err != nil {
+		return nil, fmt.Errorf("nat-pmp: %w", err)
+	}
+
+	local, err := localAddrFor(net.JoinHostPort(gateway.String(), strconv.Itoa(pcpPort)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &natpmpNAT{c: c, gateway: gateway, local: local, ports: make(map[string]int)}, nil
+}
+
+func (n *natpmpNAT) Type() string { return "NAT-PMP" }
+
+func (n *natpmpNAT) GetDeviceAddress() (net.IP, error) { return n.gateway, nil }
+
+func (n *natpmpNAT) GetInternalAddress() (net.IP, error) { return n.local, nil }
+
+func (n *natpmpNAT) GetExternalAddress() (net.IP, error) {
+	res, err := n.c.GetExternalAddress()
+	if err != nil {
+		return nil, fmt.Errorf("nat-pmp: %w", err)
+	}
+
+	ip := res.ExternalIPAddress
+
+	return net.IPv4(ip[0], ip[1], ip[2], ip[3]), nil
+}
+
+func (n *natpmpNAT) AddPortMapping(protocol string, internalPort int, _ string, timeout time.Duration) (int, error) {
+	key := mapKey(protocol, internalPort)
+
+	n.mu.Lock()
+	want, ok := n.ports[key]
+	n.mu.Unlock()
+
+	if !ok {
+		want = internalPort
+	}
+
+	res, err := n.c.AddPortMapping(protocol, internalPort, want, int(timeout/time.Second))
+	if err != nil {
+		return 0, fmt.Errorf("nat-pmp: %w", err)
+	}
+
+	ext := int(res.MappedExternalPort)
+
+	n.mu.Lock()
+	n.ports[key] = ext
+	n.mu.Unlock()
+
+	return ext, nil
+}
+
+// DeletePortMapping releases a mapping; RFC 6886 deletes with a zero
+// lifetime and zero external port.
+func (n *natpmpNAT) DeletePortMapping(protocol string, internalPort int) error {
+	n.mu.Lock()
+	delete(n.ports, mapKey(protocol, internalPort))
+	n.mu.Unlock()
+
+	_, err := n.c.AddPortMapping(protocol, internalPort, 0, 0)
+	if err != nil {
+		return fmt.Errorf("nat-pmp: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package nat
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	gonat "github.com/fd/go-nat"
+)
+
+// PCP (RFC 6887) shares UDP port 5351 with NAT-PMP; version 2 requests are
+// PCP, version 0 NAT-PMP.
+const (
+	pcpPort    = 5351
+	pcpVersion = 2
+
+	pcpOpAnnounce = 0
+	pcpOpMap      = 1
+	pcpResponse   = 0x80
+
+	pcpResultSuccess = 0
+
+	pcpHeaderLen = 24
+	pcpMapLen    = 36
+)
+
+var (
+	errPCPShort   = errors.New("pcp: short response")
+	errPCPMatch   = errors.New("pcp: response does not match request")
+	errPCPResult  = errors.New("pcp: server returned error")
+	errPCPNoReply = errors.New("pcp: no reply")
+	errPCPVersion = errors.New("pcp: gateway only speaks NAT-PMP")
+)
+
+// pcpRetries are the per-attempt read timeouts. RFC 6887 starts at 3s; a
+// gateway on the local segment answers in milliseconds, so probing all of
+// them stays fast when none speaks PCP.
+var pcpRetries = []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second}
+
+var _ gonat.NAT = (*pcpNAT)(nil)
+
+// pcpNAT is a PCP client for a single gateway. It implements gonat.NAT so
+// the Mapper can treat every protocol alike.
+type pcpNAT struct {
+	gateway net.IP
+	addr    string // gateway host:port
+	local   net.IP // our address on the gateway's link
+
+	mu       sync.Mutex
+	nonces   map[string][12]byte // proto/internal -> nonce of the live mapping
+	external net.IP
+}
+
+// discoverPCP returns a client if gateway answers a PCP ANNOUNCE.
+func discoverPCP(gateway net.IP, port int) (*pcpNAT, error) {
+	addr := net.JoinHostPort(gateway.String(), strconv.Itoa(port))
+
+	local, err := localAddrFor(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pcpNAT{gateway: gateway, addr: addr, local: local, nonces: make(map[string][12]byte)}
+
+	_, err = p.call(p.header(pcpOpAnnounce, 0), pcpOpAnnounce)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// localAddrFor returns the source address the kernel picks for addr, which
+// is the client address PCP requests must carry.
+func localAddrFor(addr string) (net.IP, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("pcp: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	ua, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("pcp: unexpected local address %v", conn.LocalAddr()) //nolint:err113 // cannot happen for udp
+	}
+
+	return ua.IP, nil
+}
+
+func (p *pcpNAT) Type() string { return "PCP" }
+
+func (p *pcpNAT) GetDeviceAddress() (net.IP, error) { return p.gateway, nil }
+
+func (p *pcpNAT) GetInternalAddress() (net.IP, error) { return p.local, nil }
+
+// GetExternalAddress returns the address learned from the last MAP response.
+// PCP has no query opcode, so before any mapping exists it maps and then
+// immediately releases a throwaway UDP port to learn the address.
+func (p *pcpNAT) GetExternalAddress() (net.IP, error) {
+	p.mu.Lock()
+	ext := p.external
+	p.mu.Unlock()
+
+	if ext != nil {
+		return ext, nil
+	}
+
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{IP: p.local})
+	if err != nil {
+		return nil, fmt.Errorf("pcp: %w", err)
+	}
+	defer func() { _ = probe.Close() }()
+
+	port := probe.LocalAddr().(*net.UDPAddr).Port //nolint:forcetypeassert // ListenUDP always returns a UDPAddr
+
+	_, err = p.AddPortMapping("udp", port, "", 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = p.DeletePortMapping("udp", port)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.external == nil {
+		return nil, gonat.ErrNoExternalAddress
+	}
+
+	return p.external, nil
+}
+
+func (p *pcpNAT) AddPortMapping(protocol string, internalPort int, _ string, timeout time.Duration) (int, error) {
+	key := mapKey(protocol, internalPort)
+
+	p.mu.Lock()
+	nonce, ok := p.nonces[key]
+	p.mu.Unlock()
+
+	if !ok {
+		_, _ = rand.Read(nonce[:])
+	}
+
+	ext, ip, err := p.mapPort(nonce, protocol, internalPort, internalPort, uint32(timeout/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	p.mu.Lock()
+	p.nonces[key] = nonce
+	p.external = ip
+	p.mu.Unlock()
+
+	return ext, nil
+}
+
+// DeletePortMapping releases the mapping by repeating its MAP request with a
+// zero lifetime and the original nonce.
+func (p *pcpNAT) DeletePortMapping(protocol string, internalPort int) error {
+	key := mapKey(protocol, internalPort)
+
+	p.mu.Lock()
+	nonce, ok := p.nonces[key]
+	delete(p.nonces, key)
+	p.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	_, _, err := p.mapPort(nonce, protocol, internalPort, 0, 0)
+
+	return err
+}
+
+func (p *pcpNAT) mapPort(nonce [12]byte, protocol string, internal, suggested int, lifetime uint32) (int, net.IP, error) {
+	var proto byte
+
+	switch protocol {
+	case "udp":
+		proto = 17
+	case "tcp":
+		proto = 6
+	default:
+		return 0, nil, fmt.Errorf("pcp: unsupported protocol %q", protocol) //nolint:err113 // caller bug
+	}
+
+	req := p.header(pcpOpMap, lifetime)
+	body := make([]byte, pcpMapLen)
+	copy(body[0:12], nonce[:])
+	body[12] = proto
+	binary.BigEndian.PutUint16(body[16:18], uint16(internal))  //nolint:gosec // ports fit in 16 bits
+	binary.BigEndian.PutUint16(body[18:20], uint16(suggested)) //nolint:gosec // ports fit in 16 bits
+	copy(body[20:36], net.IPv4zero.To16())
+	req = append(req, body...)
+
+	resp, err := p.call(req, pcpOpMap)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(resp) < pcpHeaderLen+pcpMapLen {
+		return 0, nil, errPCPShort
+	}
+
+	rb := resp[pcpHeaderLen:]
+	if [12]byte(rb[0:12]) != nonce || rb[12] != proto {
+		return 0, nil, errPCPMatch
+	}
+
+	ext := int(binary.BigEndian.Uint16(rb[18:20]))
+
+	return ext, net.IP(append([]byte(nil), rb[20:36]...)), nil
+}
+
+// header builds a PCP request header for op.
+func (p *pcpNAT) header(op byte, lifetime uint32) []byte {
+	b := make([]byte, pcpHeaderLen)
+	b[0] = pcpVersion
+	b[1] = op
+	binary.BigEndian.PutUint32(b[4:8], lifetime)
+	copy(b[8:24], p.local.To16())
+
+	return b
+}
+
+// call sends req and waits for the matching response, retransmitting with
+// growing timeouts.
+func (p *pcpNAT) call(req []byte, op byte) ([]byte, error) {
+	conn, err := net.Dial("udp", p.addr)
+	if err != nil {
+		return nil, fmt.Errorf("pcp: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	buf := make([]byte, 1100) // RFC 6887 caps messages at 1100 bytes
+
+	for _, wait := range pcpRetries {
+		_, err = conn.Write(req)
+		if err != nil {
+			return nil, fmt.Errorf("pcp: %w", err)
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(wait))
+
+		n, err := conn.Read(buf)
+		if err != nil {
+			continue
+		}
+
+		// A NAT-PMP-only gateway answers with a version 0 UNSUPP_VERSION.
+		if n >= 4 && buf[0] == 0 {
+			return nil, errPCPVersion
+		}
+
+		if n < pcpHeaderLen || buf[0] != pcpVersion || buf[1] != pcpResponse|op {
+			continue
+		}
+
+		if code := buf[3]; code != pcpResultSuccess {
+			return nil, fmt.Errorf("%w: result code %d", errPCPResult, code)
+		}
+
+		return append([]byte(nil), buf[:n]...), nil
+	}
+
+	return nil, errPCPNoReply
+}
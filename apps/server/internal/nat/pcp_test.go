@@ -0,0 +1,143 @@
+package nat
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	gonat "github.com/fd/go-nat"
+)
+
+// fakePCP answers ANNOUNCE and MAP on 127.0.0.1, assigning external port
+// internal+1000 on 203.0.113.7. It records the lifetime of every MAP.
+func fakePCP(t *testing.T) (int, <-chan uint32) {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	lifetimes := make(chan uint32, 16)
+
+	go func() {
+		buf := make([]byte, 1100)
+
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			req := buf[:n]
+			resp := make([]byte, pcpHeaderLen, pcpHeaderLen+pcpMapLen)
+			resp[0] = pcpVersion
+			resp[1] = pcpResponse | req[1]
+			copy(resp[4:8], req[4:8])
+
+			if req[1] == pcpOpMap {
+				lifetimes <- binary.BigEndian.Uint32(req[4:8])
+
+				body := append([]byte(nil), req[pcpHeaderLen:pcpHeaderLen+pcpMapLen]...)
+				internal := binary.BigEndian.Uint16(body[16:18])
+				binary.BigEndian.PutUint16(body[18:20], internal+1000)
+				copy(body[20:36], net.IPv4(203, 0, 113, 7).To16())
+				resp = append(resp, body...)
+			}
+
+			_, _ = conn.WriteToUDP(resp, from)
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port, lifetimes //nolint:forcetypeassert // udp listener
+}
+
+func TestPCPMapAndDelete(t *testing.T) {
+	t.Parallel()
+
+	port, lifetimes := fakePCP(t)
+
+	p, err := discoverPCP(net.IPv4(127, 0, 0, 1), port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext, err := p.AddPortMapping("udp", 50000, "test", 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ext != 51000 {
+		t.Fatalf("external port = %d, want 51000", ext)
+	}
+
+	if got := <-lifetimes; got != 600 {
+		t.Fatalf("lifetime = %d, want 600", got)
+	}
+
+	ip, err := p.GetExternalAddress()
+	if err != nil || !ip.Equal(net.IPv4(203, 0, 113, 7)) {
+		t.Fatalf("external address = %v, %v", ip, err)
+	}
+
+	err = p.DeletePortMapping("udp", 50000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-lifetimes; got != 0 {
+		t.Fatalf("delete lifetime = %d, want 0", got)
+	}
+}
+
+func TestDiscoverPrefersEarlierProtocol(t *testing.T) {
+	t.Parallel()
+
+	port, _ := fakePCP(t)
+	dead := net.IPv4(127, 0, 0, 2)
+	live := net.IPv4(127, 0, 0, 1)
+
+	var tried []string
+
+	failing := func(gw net.IP) (gonat.NAT, error) {
+		tried = append(tried, "pmp "+gw.String())
+
+		return nil, errors.New("no answer") //nolint:err113 // test
+	}
+	pcp := func(gw net.IP) (gonat.NAT, error) {
+		tried = append(tried, "pcp "+gw.String())
+		if !gw.Equal(live) {
+			return nil, errPCPNoReply
+		}
+
+		return discoverPCP(gw, port)
+	}
+
+	n, err := discover([]net.IP{dead, live}, pcp, failing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Type() != "PCP" {
+		t.Fatalf("type = %q", n.Type())
+	}
+
+	if len(tried) != 2 || tried[0] != "pcp 127.0.0.2" || tried[1] != "pcp 127.0.0.1" {
+		t.Fatalf("tried = %v", tried)
+	}
+}
+
+func TestIPv4Only(t *testing.T) {
+	t.Parallel()
+
+	got := ipv4Only([]net.IP{
+		net.ParseIP("192.168.1.1"), net.ParseIP("fe80::1"), net.ParseIP("192.168.1.1"), net.ParseIP("10.0.0.1"),
+	})
+	if len(got) != 2 || !got[0].Equal(net.IPv4(192, 168, 1, 1)) || !got[1].Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Fatalf("ipv4Only = %v", got)
+	}
+}
@@ -0,0 +1,142 @@
+// Package proxy serves helper HTTP and WebSocket backends, such as callsign
+// lookups or map tiles, under a path of the bridge's own. A page served with
+// cross-origin isolation can't load most third-party resources directly, but
+// it can load them same-origin through the bridge.
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+var (
+	errPrefix   = errors.New("proxy prefix must start and end with /")
+	errUpstream = errors.New("proxy upstream must be an http or https URL")
+)
+
+// defaultMethods are the methods a route allows when it names none.
+var defaultMethods = []string{http.MethodGet, http.MethodHead} //nolint:gochecknoglobals
+
+// strippedResponse are upstream response headers that would clash with the
+// bridge's own cross-origin policy or set cookies on its origin.
+var strippedResponse = []string{ //nolint:gochecknoglobals
+	"Cross-Origin-Opener-Policy", "Cross-Origin-Embedder-Policy", "Cross-Origin-Resource-Policy",
+	"Access-Control-Allow-Origin", "Access-Control-Allow-Credentials", "Access-Control-Allow-Headers",
+	"Access-Control-Allow-Methods", "Access-Control-Expose-Headers", "Access-Control-Max-Age",
+	"Set-Cookie",
+}
+
+// Route serves Upstream under Prefix: a request for Prefix+"a/b" is sent to
+// Upstream's path joined with "/a/b".
+type Route struct {
+	// Prefix is the bridge path, e.g. "/proxy/tiles/".
+	Prefix string
+	// Upstream is the backend's base URL, e.g. "https://tile.example.org/".
+	Upstream string
+	// Allow are the paths under Prefix that may be requested, as path.Match
+	// patterns, e.g. "/*/*/*.png", or as prefixes ending in "/". Empty allows
+	// every path.
+	Allow []string
+	// Methods are the methods allowed; empty allows GET and HEAD, which
+	// includes WebSocket upgrades.
+	Methods []string
+	// Headers are set on every upstream request, e.g. an API key.
+	Headers map[string]string
+}
+
+// New returns a handler for r, to be mounted at r.Prefix.
+func New(r Route) (http.Handler, error) {
+	if len(r.Prefix) < 2 || !strings.HasPrefix(r.Prefix, "/") || !strings.HasSuffix(r.Prefix, "/") {
+		return nil, fmt.Errorf("%w: %q", errPrefix, r.Prefix)
+	}
+
+	upstream, err := url.Parse(r.Upstream)
+	if err != nil || (upstream.Scheme != "http" && upstream.Scheme != "https") || upstream.Host == "" {
+		return nil, fmt.Errorf("%w: %q", errUpstream, r.Upstream)
+	}
+
+	for _, pattern := range r.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("proxy allow %q: %w", pattern, err)
+		}
+	}
+
+	if len(r.Methods) == 0 {
+		r.Methods = defaultMethods
+	}
+
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+
+			// The bridge's own credentials are no business of the backend's.
+			pr.Out.Header.Del("Cookie")
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Origin")
+			pr.Out.Header.Del("Referer")
+
+			for k, v := range r.Headers {
+				pr.Out.Header.Set(k, v)
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			for _, h := range strippedResponse {
+				resp.Header.Del(h)
+			}
+
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("[proxy] %s %s: %v", req.Method, r.Upstream, err)
+			http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !slices.Contains(r.Methods, req.Method) {
+			w.Header().Set("Allow", strings.Join(r.Methods, ", "))
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		rest := "/" + strings.TrimPrefix(req.URL.Path, r.Prefix)
+		if !allowed(r.Allow, rest) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+
+			return
+		}
+
+		out := req.Clone(req.Context())
+		out.URL.Path = rest
+		out.URL.RawPath = ""
+
+		rp.ServeHTTP(w, out)
+	}), nil
+}
+
+// allowed reports whether p matches one of patterns, or there are none.
+func allowed(patterns []string, p string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(p, pattern) {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
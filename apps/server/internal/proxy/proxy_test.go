@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNew_Proxies(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "" || r.Header.Get("Authorization") != "" {
+			t.Errorf("bridge credentials reached the backend: %v", r.Header)
+		}
+
+		w.Header().Set("Cross-Origin-Resource-Policy", "cross-origin")
+		w.Header().Set("Set-Cookie", "track=1")
+		_, _ = io.WriteString(w, r.URL.Path+"?"+r.URL.RawQuery+" key="+r.Header.Get("X-Api-Key"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	h, err := New(Route{
+		Prefix:   "/proxy/tiles/",
+		Upstream: upstream.URL + "/v1/",
+		Allow:    []string{"/*/*/*.png", "/search/"},
+		Headers:  map[string]string{"X-Api-Key": "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/proxy/tiles/", h)
+
+	cases := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{http.MethodGet, "/proxy/tiles/3/4/5.png?s=a", http.StatusOK, "/v1/3/4/5.png?s=a key=secret"},
+		{http.MethodGet, "/proxy/tiles/search/q", http.StatusOK, "/v1/search/q? key=secret"},
+		{http.MethodGet, "/proxy/tiles/3/4/5.json", http.StatusForbidden, ""},
+		{http.MethodPost, "/proxy/tiles/3/4/5.png", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("Cookie", "session=1")
+		req.Header.Set("Authorization", "Bearer admin")
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Errorf("%s %s = %d, want %d", c.method, c.path, rec.Code, c.status)
+
+			continue
+		}
+
+		if c.body == "" {
+			continue
+		}
+
+		if got := rec.Body.String(); got != c.body {
+			t.Errorf("%s = %q, want %q", c.path, got, c.body)
+		}
+
+		if rec.Header().Get("Set-Cookie") != "" || rec.Header().Get("Cross-Origin-Resource-Policy") != "" {
+			t.Errorf("%s kept upstream headers: %v", c.path, rec.Header())
+		}
+	}
+}
+
+func TestNew_WebSocket(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = conn.Close() }()
+
+		mt, msg, err := conn.ReadMessage()
+		if err == nil {
+			_ = conn.WriteMessage(mt, append([]byte(r.URL.Path+" "), msg...))
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	h, err := New(Route{Prefix: "/proxy/feed/", Upstream: upstream.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bridge := httptest.NewServer(h)
+	t.Cleanup(bridge.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+bridge.URL[len("http"):]+"/proxy/feed/live", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	err = conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, msg, err := conn.ReadMessage()
+	if err != nil || string(msg) != "/live hello" {
+		t.Errorf("echo = %q, %v", msg, err)
+	}
+}
+
+func TestNew_BadRoute(t *testing.T) {
+	t.Parallel()
+
+	for _, r := range []Route{
+		{Prefix: "/proxy", Upstream: "https://example.org/"},
+		{Prefix: "/", Upstream: "https://example.org/"},
+		{Prefix: "/proxy/", Upstream: "ftp://example.org/"},
+		{Prefix: "/proxy/", Upstream: "https://example.org/", Allow: []string{"[a-"}},
+	} {
+		if _, err := New(r); err == nil {
+			t.Errorf("New(%+v) accepted a bad route", r)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package radiocheck
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// DefaultAPIPort is the radio's TCP API port.
+const DefaultAPIPort = 4992
+
+// NAT mapping behaviours, as the STUN servers saw the bridge.
+const (
+	// MappingNone: the bridge's own address is public.
+	MappingNone = "none"
+	// MappingIndependent: every server saw one address and port, so a client
+	// outside can reach the bridge through the mapping ICE discovers.
+	MappingIndependent = "endpoint-independent"
+	// MappingDependent: each server saw a different port, so a client
+	// outside will likely need TURN or ICE-TCP.
+	MappingDependent = "endpoint-dependent"
+	// MappingUnknown: fewer than two servers answered.
+	MappingUnknown = "unknown"
+)
+
+// ConnectOptions is what Connect checks.
+type ConnectOptions struct {
+	// Radio is the radio's host:port.
+	Radio string
+	// UDPPort is the radio's UDP port; zero means DefaultUDPPort.
+	UDPPort int
+	// STUN are the STUN URLs clients are given; others are skipped.
+	STUN    []string
+	Timeout time.Duration
+}
+
+// ConnectReport is every step between a client and a radio that Connect
+// checked, and a hint at the first that failed.
+type ConnectReport struct {
+	Radio string `json:"radio"`
+	// OK is set when the radio can be reached and heard over UDP.
+	OK            bool         `json:"ok"`
+	TCP           Direction    `json:"tcp"`
+	Handshake     Handshake    `json:"handshake"`
+	RadioToBridge Direction    `json:"radioToBridge"`
+	BridgeToRadio Direction    `json:"bridgeToRadio"`
+	LocalUDPPort  int          `json:"localUdpPort,omitempty"`
+	STUN          []STUNResult `json:"stun"`
+	NAT           NATResult    `json:"nat"`
+	Hint          string       `json:"hint,omitempty"`
+}
+
+// Handshake is the radio's greeting.
+type Handshake struct {
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
+	Version string `json:"version,omitempty"`
+	Handle  string `json:"handle,omitempty"`
+}
+
+// STUNResult is one STUN server's answer to a binding request.
+type STUNResult struct {
+	Server    string `json:"server"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Mapped    string `json:"mapped,omitempty"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+}
+
+// NATResult is how the bridge's NAT maps its UDP, judged from the STUN
+// servers' answers to one socket.
+type NATResult struct {
+	Mapping string `json:"mapping"`
+	Local   string `json:"local,omitempty"`
+	Public  string `json:"public,omitempty"`
+}
+
+// Connect checks, as a dry run, the whole path a session takes: it dials the
+// radio and reads its handshake, probes UDP both ways as UDP does, then asks
+// each STUN server for the bridge's public address from one socket and
+// judges its NAT by their answers.
+func Connect(ctx context.Context, opt ConnectOptions) ConnectReport {
+	res, hs := probe(ctx, opt.Radio, opt.UDPPort, opt.Timeout)
+
+	rep := ConnectReport{
+		Radio:         opt.Radio,
+		TCP:           res.TCP,
+		Handshake:     Handshake{Status: StatusNotChecked},
+		RadioToBridge: res.RadioToBridge,
+		BridgeToRadio: res.BridgeToRadio,
+		LocalUDPPort:  res.LocalUDPPort,
+	}
+
+	switch {
+	case hs.err != nil:
+		rep.TCP = Direction{Status: StatusOK}
+		rep.Handshake = Handshake{Status: StatusError, Detail: hs.err.Error()}
+	case hs.handle != "":
+		rep.Handshake = Handshake{Status: StatusOK, Version: hs.version, Handle: "0x" + hs.handle}
+	}
+
+	rep.STUN, rep.NAT = checkSTUN(opt.STUN, opt.Timeout)
+	rep.OK = rep.Handshake.Status == StatusOK && rep.RadioToBridge.Status == StatusOK &&
+		rep.BridgeToRadio.Status != StatusRefused && rep.BridgeToRadio.Status != StatusError
+	rep.Hint = connectHint(rep, res.Hint)
+
+	return rep
+}
+
+// checkSTUN sends a binding request to each STUN server from one socket.
+func checkSTUN(urls []string, timeout time.Duration) ([]STUNResult, NATResult) {
+	out := []STUNResult{}
+	nat := NATResult{Mapping: MappingUnknown}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return append(out, STUNResult{Status: StatusError, Detail: err.Error()}), nat
+	}
+	defer func() { _ = conn.Close() }()
+
+	var mapped []*net.UDPAddr
+
+	for _, u := range urls {
+		uri, err := stun.ParseURI(u)
+		if err != nil || uri.Scheme != stun.SchemeTypeSTUN {
+			continue
+		}
+
+		r, addr := stunBinding(conn, net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port)), timeout)
+		r.Server = u
+		out = append(out, r)
+
+		if addr != nil {
+			mapped = append(mapped, addr)
+		}
+	}
+
+	if len(mapped) == 0 {
+		return out, nat
+	}
+
+	nat.Public = mapped[0].String()
+
+	if la, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		nat.Local = la.String()
+	}
+
+	switch {
+	case isLocalIP(mapped[0].IP):
+		nat.Mapping = MappingNone
+	case len(mapped) < 2:
+	case slices.ContainsFunc(mapped[1:], func(a *net.UDPAddr) bool { return a.String() != nat.Public }):
+		nat.Mapping = MappingDependent
+	default:
+		nat.Mapping = MappingIndependent
+	}
+
+	return out, nat
+}
+
+// stunBinding asks server for the address conn's datagrams arrive from.
+func stunBinding(conn *net.UDPConn, server string, timeout time.Duration) (STUNResult, *net.UDPAddr) {
+	to, err := net.ResolveUDPAddr("udp4", server)
+	if err != nil {
+		return STUNResult{Status: StatusError, Detail: err.Error()}, nil
+	}
+
+	req, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+	if err != nil {
+		return STUNResult{Status: StatusError, Detail: err.Error()}, nil
+	}
+
+	start := time.Now()
+
+	_, err = conn.WriteToUDP(req.Raw, to)
+	if err != nil {
+		return STUNResult{Status: StatusError, Detail: err.Error()}, nil
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, 1500)
+
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return STUNResult{Status: StatusNoTraffic, Detail: "no answer before the deadline"}, nil
+		}
+
+		var res stun.Message
+
+		err = stun.Decode(buf[:n], &res)
+		if err != nil || !bytes.Equal(res.TransactionID[:], req.TransactionID[:]) {
+			continue // a late answer to an earlier server
+		}
+
+		var xor stun.XORMappedAddress
+
+		err = xor.GetFrom(&res)
+		if err != nil {
+			return STUNResult{Status: StatusError, Detail: err.Error()}, nil
+		}
+
+		addr := &net.UDPAddr{IP: xor.IP, Port: xor.Port}
+
+		return STUNResult{Status: StatusOK, Mapped: addr.String(), LatencyMs: time.Since(start).Milliseconds()}, addr
+	}
+}
+
+// isLocalIP reports whether ip is one of this host's addresses.
+func isLocalIP(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+
+	return slices.ContainsFunc(addrs, func(a net.Addr) bool {
+		n, ok := a.(*net.IPNet)
+
+		return ok && n.IP.Equal(ip)
+	})
+}
+
+// connectHint points at the first step that failed, bridge↔radio first.
+func connectHint(rep ConnectReport, udpHint string) string {
+	stunOK := slices.ContainsFunc(rep.STUN, func(r STUNResult) bool { return r.Status == StatusOK })
+
+	switch {
+	case rep.TCP.Status != StatusOK:
+		return udpHint
+	case rep.Handshake.Status != StatusOK:
+		return "something answered on the API port but did not greet as a radio; check the port is the radio's (4992)"
+	case !rep.OK:
+		return udpHint
+	case len(rep.STUN) == 0:
+		return "bridge-radio path is healthy; no STUN servers are configured, so only clients on the bridge's network can connect"
+	case !stunOK:
+		return "bridge-radio path is healthy, but no STUN server answered; outbound UDP from the bridge may be blocked, " +
+			"so clients outside its network will need TURN or ICE-TCP"
+	case rep.NAT.Mapping == MappingDependent:
+		return "bridge-radio path is healthy, but the bridge's NAT maps each destination to a different port; " +
+			"clients outside its network will likely need TURN or ICE-TCP, or a port forward with nat-1to1"
+	default:
+		return "the whole path looks healthy; if a client still fails, look at its own network"
+	}
+}
@@ -0,0 +1,102 @@
+package radiocheck
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
+)
+
+// fakeSTUN answers binding requests with the address they came from.
+func fakeSTUN(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			var req stun.Message
+			if stun.Decode(buf[:n], &req) != nil {
+				continue
+			}
+
+			res := stun.MustBuild(stun.NewTransactionIDSetter(req.TransactionID), stun.BindingSuccess,
+				&stun.XORMappedAddress{IP: from.IP, Port: from.Port}, stun.Fingerprint)
+			_, _ = conn.WriteToUDP(res.Raw, from)
+		}
+	}()
+
+	return "stun:" + conn.LocalAddr().String()
+}
+
+func TestConnect(t *testing.T) {
+	t.Parallel()
+
+	addr, udpPort, _ := fakeRadio(t, true)
+
+	rep := Connect(t.Context(), ConnectOptions{
+		Radio: addr, UDPPort: udpPort, Timeout: time.Second,
+		STUN: []string{fakeSTUN(t), fakeSTUN(t), "turn:relay.example.com"},
+	})
+
+	if !rep.OK || rep.TCP.Status != StatusOK {
+		t.Errorf("report = %+v", rep)
+	}
+
+	if rep.Handshake != (Handshake{Status: StatusOK, Version: "1.4.0.0", Handle: "0x1A2B3C4D"}) {
+		t.Errorf("handshake = %+v", rep.Handshake)
+	}
+
+	// The TURN server is not asked.
+	if len(rep.STUN) != 2 || rep.STUN[0].Status != StatusOK || rep.STUN[0].Mapped == "" {
+		t.Errorf("stun = %+v", rep.STUN)
+	}
+
+	// Loopback is the bridge's own address.
+	if rep.NAT.Mapping != MappingNone || rep.NAT.Public != rep.STUN[1].Mapped {
+		t.Errorf("nat = %+v", rep.NAT)
+	}
+}
+
+func TestConnect_NotARadio(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		_ = conn.Close()
+	}()
+
+	rep := Connect(t.Context(), ConnectOptions{Radio: ln.Addr().String(), Timeout: time.Second})
+
+	if rep.OK || rep.TCP.Status != StatusOK || rep.Handshake.Status != StatusError {
+		t.Errorf("report = %+v", rep)
+	}
+
+	if rep.RadioToBridge.Status != StatusNotChecked || rep.NAT.Mapping != MappingUnknown {
+		t.Errorf("later steps = %+v, %+v", rep.RadioToBridge, rep.NAT)
+	}
+}
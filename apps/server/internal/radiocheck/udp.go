@@ -0,0 +1,257 @@
+// Package radiocheck diagnoses the network path between the bridge and a
+// radio, independent of any browser client.
+package radiocheck
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultUDPPort is the radio's VITA-49 UDP port.
+const DefaultUDPPort = 4993
+
+// Leg names the network path a result describes, so problems on the
+// bridge↔radio leg are not confused with the client↔bridge (WebRTC) leg.
+const LegBridgeRadio = "bridge-radio"
+
+// Direction statuses.
+const (
+	StatusOK         = "ok"
+	StatusNoTraffic  = "no-traffic" // nothing arrived before the deadline
+	StatusSent       = "sent"       // datagrams left the bridge; the radio does not acknowledge them
+	StatusRefused    = "refused"    // ICMP port unreachable came back
+	StatusError      = "error"
+	StatusNotChecked = "not-checked"
+)
+
+var errBadHandshake = errors.New("radio handshake missing handle")
+
+// UDPResult reports reachability in each direction between bridge and radio.
+type UDPResult struct {
+	Leg           string    `json:"leg"`
+	Radio         string    `json:"radio"`
+	TCP           Direction `json:"tcp"`
+	RadioToBridge Direction `json:"radioToBridge"`
+	BridgeToRadio Direction `json:"bridgeToRadio"`
+	LocalUDPPort  int       `json:"localUdpPort,omitempty"`
+	Hint          string    `json:"hint,omitempty"`
+}
+
+type Direction struct {
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	Packets   int    `json:"packets,omitempty"`
+}
+
+// UDP opens a throwaway API session to addr (host:port), registers a fresh
+// UDP port with "client udpport" and subscribes to meters, which the radio
+// streams continuously, then waits up to timeout for datagrams. In the other
+// direction it sends "client udp_register" datagrams to the radio's UDP port
+// on a connected socket, so an ICMP unreachable surfaces as a refusal.
+func UDP(ctx context.Context, addr string, udpPort int, timeout time.Duration) UDPResult {
+	res, _ := probe(ctx, addr, udpPort, timeout)
+
+	return res
+}
+
+// handshake is the radio's greeting, or why it never came.
+type handshake struct {
+	version, handle string
+	err             error
+}
+
+// probe runs UDP's checks, also returning the radio's handshake.
+func probe(ctx context.Context, addr string, udpPort int, timeout time.Duration) (UDPResult, handshake) {
+	var hs handshake
+
+	res := UDPResult{
+		Leg:           LegBridgeRadio,
+		Radio:         addr,
+		TCP:           Direction{Status: StatusNotChecked},
+		RadioToBridge: Direction{Status: StatusNotChecked},
+		BridgeToRadio: Direction{Status: StatusNotChecked},
+	}
+
+	if udpPort == 0 {
+		udpPort = DefaultUDPPort
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		res.TCP = Direction{Status: StatusError, Detail: err.Error()}
+
+		return res, hs
+	}
+
+	start := time.Now()
+	d := net.Dialer{Timeout: timeout}
+
+	tcp, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		res.TCP = Direction{Status: StatusError, Detail: err.Error()}
+		res.Hint = "the radio's API port is unreachable from the bridge; check the address and any firewall between them"
+
+		return res, hs
+	}
+	defer func() { _ = tcp.Close() }()
+
+	_ = tcp.SetDeadline(time.Now().Add(timeout))
+
+	hs = readHandshake(bufio.NewReader(tcp))
+	if hs.err != nil {
+		res.TCP = Direction{Status: StatusError, Detail: hs.err.Error()}
+
+		return res, hs
+	}
+
+	res.TCP = Direction{Status: StatusOK, LatencyMs: time.Since(start).Milliseconds()}
+
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		res.RadioToBridge = Direction{Status: StatusError, Detail: err.Error()}
+
+		return res, hs
+	}
+	defer func() { _ = udp.Close() }()
+
+	if ua, ok := udp.LocalAddr().(*net.UDPAddr); ok {
+		res.LocalUDPPort = ua.Port
+	}
+
+	_, err = fmt.Fprintf(tcp, "C1|client udpport %d\nC2|sub meter all\n", res.LocalUDPPort)
+	if err != nil {
+		res.RadioToBridge = Direction{Status: StatusError, Detail: err.Error()}
+
+		return res, hs
+	}
+
+	radioUDP := net.JoinHostPort(host, strconv.Itoa(udpPort))
+	res.BridgeToRadio = sendRegister(ctx, radioUDP, hs.handle)
+	res.RadioToBridge = awaitDatagrams(udp, net.ParseIP(host), start, timeout)
+	res.Hint = hint(res)
+
+	return res, hs
+}
+
+// readHandshake reads the radio's two greeting lines: its version, then its
+// handle for this session.
+func readHandshake(rd *bufio.Reader) handshake {
+	var hs handshake
+
+	for range 2 {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			hs.err = fmt.Errorf("read handshake: %w", err)
+
+			return hs
+		}
+
+		line = strings.TrimSpace(line)
+
+		if v, ok := strings.CutPrefix(line, "V"); ok {
+			hs.version = v
+		}
+
+		if h, ok := strings.CutPrefix(line, "H"); ok {
+			if _, err := strconv.ParseUint(h, 16, 32); err != nil {
+				break
+			}
+
+			hs.handle = strings.ToUpper(h)
+
+			return hs
+		}
+	}
+
+	hs.err = errBadHandshake
+
+	return hs
+}
+
+func sendRegister(ctx context.Context, radioUDP, handle string) Direction {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "udp", radioUDP)
+	if err != nil {
+		return Direction{Status: StatusError, Detail: err.Error()}
+	}
+	defer func() { _ = conn.Close() }()
+
+	msg := []byte("client udp_register handle=0x" + handle)
+	sent := 0
+
+	for range 3 {
+		_, err = conn.Write(msg)
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return Direction{Status: StatusRefused, Detail: radioUDP + " answered port unreachable", Packets: sent}
+		}
+
+		if err != nil {
+			return Direction{Status: StatusError, Detail: err.Error(), Packets: sent}
+		}
+
+		sent++
+
+		// A refusal for the previous datagram is reported on the next call.
+		_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+
+		_, err = conn.Read(make([]byte, 1))
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return Direction{Status: StatusRefused, Detail: radioUDP + " answered port unreachable", Packets: sent}
+		}
+	}
+
+	return Direction{Status: StatusSent, Packets: sent}
+}
+
+func awaitDatagrams(udp *net.UDPConn, radioIP net.IP, start time.Time, timeout time.Duration) Direction {
+	_ = udp.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, 64*1024)
+	res := Direction{Status: StatusNoTraffic}
+
+	for {
+		_, src, err := udp.ReadFromUDP(buf)
+		if err != nil {
+			if res.Packets > 0 {
+				res.Status = StatusOK
+			}
+
+			return res
+		}
+
+		if radioIP != nil && !src.IP.Equal(radioIP) {
+			continue
+		}
+
+		if res.Packets == 0 {
+			res.LatencyMs = time.Since(start).Milliseconds()
+			// One datagram proves the path; collect a few more for a count.
+			_ = udp.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
+		}
+
+		res.Packets++
+	}
+}
+
+func hint(res UDPResult) string {
+	switch {
+	case res.BridgeToRadio.Status == StatusRefused:
+		return "the radio's UDP port refused traffic; check the radio address and UDP port"
+	case res.RadioToBridge.Status == StatusNoTraffic:
+		return "the radio accepted the API session but no UDP arrived; a firewall on the bridge host " +
+			"or a NAT between bridge and radio is dropping inbound UDP. This is not a browser/WebRTC problem"
+	case res.RadioToBridge.Status == StatusOK:
+		return "bridge-radio path is healthy; if audio still fails, look at the client-bridge (WebRTC/ICE) leg"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,109 @@
+package radiocheck
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRadio answers the API handshake and, when told a client UDP port,
+// sends a meter-like datagram to it. It records udp_register datagrams.
+func fakeRadio(t *testing.T, sendUDP bool) (tcpAddr string, udpPort int, registered chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = udp.Close() })
+
+	registered = make(chan string, 4)
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, _, err := udp.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			registered <- string(buf[:n])
+		}
+	}()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		_, _ = fmt.Fprint(conn, "V1.4.0.0\nH1A2B3C4D\n")
+
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			var port int
+			if _, err := fmt.Sscanf(sc.Text(), "C1|client udpport %d", &port); err == nil && sendUDP {
+				_, _ = udp.WriteToUDP([]byte("meter"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+			}
+		}
+	}()
+
+	return ln.Addr().String(), udp.LocalAddr().(*net.UDPAddr).Port, registered //nolint:forcetypeassert // always UDP
+}
+
+func TestUDP_BothDirections(t *testing.T) {
+	t.Parallel()
+
+	addr, udpPort, registered := fakeRadio(t, true)
+
+	res := UDP(t.Context(), addr, udpPort, 2*time.Second)
+
+	if res.TCP.Status != StatusOK {
+		t.Fatalf("tcp: %+v", res.TCP)
+	}
+
+	if res.RadioToBridge.Status != StatusOK || res.RadioToBridge.Packets < 1 {
+		t.Errorf("radioToBridge: %+v", res.RadioToBridge)
+	}
+
+	if res.BridgeToRadio.Status != StatusSent {
+		t.Errorf("bridgeToRadio: %+v", res.BridgeToRadio)
+	}
+
+	select {
+	case msg := <-registered:
+		if !strings.Contains(msg, "handle=0x1A2B3C4D") {
+			t.Errorf("register datagram: %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("radio never received udp_register")
+	}
+}
+
+func TestUDP_InboundBlocked(t *testing.T) {
+	t.Parallel()
+
+	addr, udpPort, _ := fakeRadio(t, false)
+
+	res := UDP(t.Context(), addr, udpPort, 300*time.Millisecond)
+
+	if res.RadioToBridge.Status != StatusNoTraffic {
+		t.Errorf("radioToBridge: %+v", res.RadioToBridge)
+	}
+
+	if res.Leg != LegBridgeRadio || !strings.Contains(res.Hint, "not a browser/WebRTC problem") {
+		t.Errorf("hint should point at the bridge-radio leg, got %q", res.Hint)
+	}
+}
@@ -0,0 +1,379 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IQFormat is the container an IQ recording is stored in.
+type IQFormat string
+
+const (
+	// IQFormatWAV is a stereo 32-bit float WAV (I left, Q right), promoted to
+	// RF64 past 4 GiB, with the "auxi" chunk SDR#, HDSDR and SDRuno read the
+	// center frequency from.
+	IQFormatWAV IQFormat = "wav"
+	// IQFormatSigMF is a cf32_le .sigmf-data file beside its .sigmf-meta.
+	IQFormatSigMF IQFormat = "sigmf"
+)
+
+var (
+	errIQFormat   = errors.New("recorder: IQ format must be wav or sigmf")
+	errIQRate     = errors.New("recorder: IQ sample rate is unknown")
+	errIQSeekable = errors.New("recorder: store cannot rewrite the WAV header")
+)
+
+// iqFullScale is the radio's 0 dBFS sample value; samples are written
+// normalised to [-1, 1].
+const iqFullScale = 1 << 15
+
+// iqBuffer batches writes; at 192 kHz the radio delivers 1.5 MB/s.
+const iqBuffer = 256 * 1024
+
+// IQMeta describes a DAX IQ capture; it names the files and fills in their
+// metadata.
+type IQMeta struct {
+	Callsign   string
+	Model      string // radio model, e.g. FLEX-6600
+	Channel    int    // DAX IQ channel
+	CenterMHz  float64
+	SampleRate int
+	Start      time.Time
+}
+
+// Key returns the object key of the recording's samples, e.g.
+// "2024-05-01/N0CALL_IQ1_14.100000_48000_20240501T120000Z.wav".
+func (m IQMeta) Key(f IQFormat) string {
+	ext := string(f)
+	if f == IQFormatSigMF {
+		ext = "sigmf-data"
+	}
+
+	return objectKey(m.Start, ext, m.Callsign, fmt.Sprintf("IQ%d", m.Channel), freqPart(m.CenterMHz),
+		fmt.Sprint(m.SampleRate))
+}
+
+// sigmfCapture is one segment of a SigMF recording at a fixed frequency.
+type sigmfCapture struct {
+	SampleStart uint64  `json:"core:sample_start"`
+	Frequency   float64 `json:"core:frequency"`
+	Datetime    string  `json:"core:datetime"`
+}
+
+// IQRecording is one IQ capture in progress.
+type IQRecording struct {
+	r      *Recorder
+	key    string
+	format IQFormat
+	meta   IQMeta
+
+	mu       sync.Mutex
+	dst      io.WriteCloser
+	buf      *bufio.Writer
+	scratch  []byte
+	samples  uint64
+	captures []sigmfCapture
+	closed   bool
+}
+
+// StartIQ begins an IQ capture. meta.SampleRate must be set; it goes into the
+// file header.
+func (r *Recorder) StartIQ(meta IQMeta, f IQFormat) (*IQRecording, error) {
+	if r == nil || r.store == nil {
+		return nil, errNoStore
+	}
+
+	if f != IQFormatWAV && f != IQFormatSigMF {
+		return nil, errIQFormat
+	}
+
+	if meta.SampleRate <= 0 {
+		return nil, errIQRate
+	}
+
+	rec := &IQRecording{r: r, key: meta.Key(f), format: f, meta: meta}
+	rec.captures = []sigmfCapture{{Frequency: meta.CenterMHz * 1e6, Datetime: meta.Start.UTC().Format(time.RFC3339Nano)}}
+
+	w, err := r.store.Create(rec.key)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: %w", err)
+	}
+
+	rec.dst = w
+	rec.buf = bufio.NewWriterSize(w, iqBuffer)
+
+	if f == IQFormatWAV {
+		if _, ok := w.(io.WriteSeeker); !ok {
+			_ = w.Close()
+
+			return nil, errIQSeekable
+		}
+
+		_, _ = rec.buf.Write(wavHeader(meta, meta.Start, 0))
+	}
+
+	return rec, nil
+}
+
+// Key is where the capture appears in the store: the WAV file, or the SigMF
+// metadata file that describes the dataset beside it.
+func (rec *IQRecording) Key() string {
+	if rec.format == IQFormatSigMF {
+		return sigmfMetaKey(rec.key)
+	}
+
+	return rec.key
+}
+
+// Recorded is how much signal has been written so far.
+func (rec *IQRecording) Recorded() time.Duration {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return time.Duration(rec.samples) * time.Second / time.Duration(rec.meta.SampleRate)
+}
+
+// WriteIQ adds a DAX IQ payload: interleaved I/Q float32 pairs, little-endian,
+// at the radio's full scale.
+func (rec *IQRecording) WriteIQ(payload []byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.closed {
+		return
+	}
+
+	n := len(payload) / 8 * 8
+	rec.scratch = append(rec.scratch[:0], payload[:n]...)
+
+	for i := 0; i < n; i += 4 {
+		v := math.Float32frombits(binary.LittleEndian.Uint32(rec.scratch[i:]))
+		binary.LittleEndian.PutUint32(rec.scratch[i:], math.Float32bits(v/iqFullScale))
+	}
+
+	_, err := rec.buf.Write(rec.scratch)
+	if err == nil {
+		rec.samples += uint64(n / 8) //nolint:gosec // n is never negative
+	}
+}
+
+// SetCenter notes that the panadapter was retuned. SigMF starts a new capture
+// segment; WAV keeps the frequency the recording started at.
+func (rec *IQRecording) SetCenter(mhz float64) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	last := &rec.captures[len(rec.captures)-1]
+	if mhz*1e6 == last.Frequency {
+		return
+	}
+
+	if last.SampleStart == rec.samples {
+		last.Frequency = mhz * 1e6
+
+		return
+	}
+
+	rec.captures = append(rec.captures, sigmfCapture{
+		SampleStart: rec.samples,
+		Frequency:   mhz * 1e6,
+		Datetime:    time.Now().UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// Close finishes the capture: the WAV header gets its final sizes, or the
+// SigMF metadata is written.
+func (rec *IQRecording) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.closed {
+		return nil
+	}
+
+	rec.closed = true
+
+	err := rec.buf.Flush()
+	if err == nil && rec.format == IQFormatWAV {
+		err = rec.finishWAV()
+	}
+
+	if err != nil {
+		_ = rec.dst.Close()
+
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	err = rec.dst.Close()
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	if rec.format == IQFormatSigMF {
+		return rec.writeSigMFMeta()
+	}
+
+	return nil
+}
+
+func (rec *IQRecording) finishWAV() error {
+	ws, _ := rec.dst.(io.WriteSeeker)
+
+	_, err := ws.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("rewrite header: %w", err)
+	}
+
+	_, err = ws.Write(wavHeader(rec.meta, time.Now(), rec.samples))
+	if err != nil {
+		return fmt.Errorf("rewrite header: %w", err)
+	}
+
+	return nil
+}
+
+func (rec *IQRecording) writeSigMFMeta() error {
+	desc := fmt.Sprintf("DAX IQ channel %d", rec.meta.Channel)
+	if rec.meta.Model != "" {
+		desc = rec.meta.Model + " " + desc
+	}
+
+	doc := map[string]any{
+		"global": map[string]any{
+			"core:datatype":    "cf32_le",
+			"core:sample_rate": rec.meta.SampleRate,
+			"core:version":     "1.0.0",
+			"core:recorder":    "solid-sdr-server",
+			"core:author":      rec.meta.Callsign,
+			"core:description": desc,
+			"core:dataset":     rec.key[strings.LastIndex(rec.key, "/")+1:],
+		},
+		"captures":    rec.captures,
+		"annotations": []any{},
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	w, err := rec.r.store.Create(sigmfMetaKey(rec.key))
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	_, err = w.Write(b)
+	if err != nil {
+		_ = w.Close()
+
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	return nil
+}
+
+func sigmfMetaKey(dataKey string) string {
+	return strings.TrimSuffix(dataKey, ".sigmf-data") + ".sigmf-meta"
+}
+
+// WAV layout. A JUNK chunk reserves room for the RF64 ds64 chunk, so a file
+// that outgrows 32-bit sizes can be promoted in place when it is closed
+// (EBU Tech 3306).
+const (
+	wavDS64Size  = 28
+	wavFmtSize   = 16
+	wavAuxiSize  = 68
+	wavHeaderLen = 12 + 8 + wavDS64Size + 8 + wavFmtSize + 8 + wavAuxiSize + 8
+	wavFrameSize = 8 // two float32 channels
+)
+
+// wavHeader returns the header for samples frames, with stop as the end time
+// recorded in the auxi chunk.
+func wavHeader(m IQMeta, stop time.Time, samples uint64) []byte {
+	le := binary.LittleEndian
+	dataSize := samples * wavFrameSize
+	riffSize := uint64(wavHeaderLen-8) + dataSize
+	rf64 := riffSize > math.MaxUint32
+
+	b := make([]byte, 0, wavHeaderLen)
+
+	if rf64 {
+		b = append(b, "RF64"...)
+		b = le.AppendUint32(b, math.MaxUint32)
+	} else {
+		b = append(b, "RIFF"...)
+		b = le.AppendUint32(b, uint32(riffSize))
+	}
+
+	b = append(b, "WAVE"...)
+
+	if rf64 {
+		b = append(b, "ds64"...)
+		b = le.AppendUint32(b, wavDS64Size)
+		b = le.AppendUint64(b, riffSize)
+		b = le.AppendUint64(b, dataSize)
+		b = le.AppendUint64(b, samples)
+		b = le.AppendUint32(b, 0) // no table
+	} else {
+		b = append(b, "JUNK"...)
+		b = le.AppendUint32(b, wavDS64Size)
+		b = append(b, make([]byte, wavDS64Size)...)
+	}
+
+	rate := uint32(m.SampleRate) //nolint:gosec // at most 192 kHz
+
+	b = append(b, "fmt "...)
+	b = le.AppendUint32(b, wavFmtSize)
+	b = le.AppendUint16(b, 3) // WAVE_FORMAT_IEEE_FLOAT
+	b = le.AppendUint16(b, 2)
+	b = le.AppendUint32(b, rate)
+	b = le.AppendUint32(b, rate*wavFrameSize)
+	b = le.AppendUint16(b, wavFrameSize)
+	b = le.AppendUint16(b, 32)
+
+	b = append(b, "auxi"...)
+	b = le.AppendUint32(b, wavAuxiSize)
+	b = appendSystemTime(b, m.Start)
+	b = appendSystemTime(b, stop)
+	b = le.AppendUint32(b, uint32(math.Round(m.CenterMHz*1e6))) // CenterFreq, Hz
+	b = le.AppendUint32(b, rate)                                // ADFrequency
+	b = append(b, make([]byte, 7*4)...)                         // IF, bandwidth, IQ offset, unused
+
+	b = append(b, "data"...)
+
+	if rf64 {
+		b = le.AppendUint32(b, math.MaxUint32)
+	} else {
+		b = le.AppendUint32(b, uint32(dataSize))
+	}
+
+	return b
+}
+
+// appendSystemTime appends t as a Windows SYSTEMTIME in UTC, as the auxi
+// chunk stores it.
+func appendSystemTime(b []byte, t time.Time) []byte {
+	t = t.UTC()
+
+	for _, v := range []int{
+		t.Year(), int(t.Month()), int(t.Weekday()), t.Day(),
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond() / int(time.Millisecond),
+	} {
+		b = binary.LittleEndian.AppendUint16(b, uint16(v)) //nolint:gosec // calendar fields fit
+	}
+
+	return b
+}
@@ -0,0 +1,178 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/storage"
+)
+
+// iqPayload builds n DAX IQ frames of I=+half scale, Q=-half scale.
+func iqPayload(n int) []byte {
+	b := make([]byte, 0, n*8)
+	for range n {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(16384))
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(-16384))
+	}
+
+	return b
+}
+
+func TestIQRecordWAV(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r := New(storage.NewLocal(dir), Options{})
+	meta := IQMeta{Callsign: "N0CALL", Channel: 1, CenterMHz: 14.1, SampleRate: 48000, Start: time.Now()}
+
+	rec, err := r.StartIQ(meta, IQFormatWAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 100 {
+		rec.WriteIQ(iqPayload(480))
+	}
+
+	err = rec.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Recorded() != time.Second {
+		t.Errorf("recorded %s, want 1s", rec.Recorded())
+	}
+
+	if !strings.Contains(rec.Key(), "N0CALL_IQ1_14.100000_48000_") || !strings.HasSuffix(rec.Key(), ".wav") {
+		t.Errorf("key = %q", rec.Key())
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rec.Key())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	le := binary.LittleEndian
+	dataSize := 48000 * 8
+
+	if len(b) != wavHeaderLen+dataSize {
+		t.Fatalf("file is %d bytes, want %d", len(b), wavHeaderLen+dataSize)
+	}
+
+	if string(b[0:4]) != "RIFF" || int(le.Uint32(b[4:])) != len(b)-8 || string(b[8:12]) != "WAVE" {
+		t.Errorf("bad RIFF header % x", b[:12])
+	}
+
+	if string(b[12:16]) != "JUNK" {
+		t.Errorf("chunk at 12 is %q, want JUNK", b[12:16])
+	}
+
+	fmtAt := 12 + 8 + wavDS64Size
+	if string(b[fmtAt:fmtAt+4]) != "fmt " || le.Uint16(b[fmtAt+8:]) != 3 || le.Uint32(b[fmtAt+12:]) != 48000 {
+		t.Errorf("bad fmt chunk % x", b[fmtAt:fmtAt+24])
+	}
+
+	auxiAt := fmtAt + 8 + wavFmtSize
+	if string(b[auxiAt:auxiAt+4]) != "auxi" || le.Uint32(b[auxiAt+8+32:]) != 14_100_000 {
+		t.Errorf("bad auxi chunk % x", b[auxiAt:auxiAt+48])
+	}
+
+	dataAt := wavHeaderLen - 8
+	if string(b[dataAt:dataAt+4]) != "data" || int(le.Uint32(b[dataAt+4:])) != dataSize {
+		t.Errorf("bad data chunk % x", b[dataAt:dataAt+8])
+	}
+
+	i := math.Float32frombits(le.Uint32(b[wavHeaderLen:]))
+	q := math.Float32frombits(le.Uint32(b[wavHeaderLen+4:]))
+
+	if i != 0.5 || q != -0.5 {
+		t.Errorf("first frame = (%v, %v), want (0.5, -0.5)", i, q)
+	}
+}
+
+func TestWAVHeaderRF64(t *testing.T) {
+	t.Parallel()
+
+	samples := uint64(1 << 30) // 8 GiB of frames
+	b := wavHeader(IQMeta{SampleRate: 192000}, time.Now(), samples)
+
+	if len(b) != wavHeaderLen {
+		t.Fatalf("header is %d bytes, want %d", len(b), wavHeaderLen)
+	}
+
+	le := binary.LittleEndian
+
+	if string(b[0:4]) != "RF64" || le.Uint32(b[4:]) != math.MaxUint32 || string(b[12:16]) != "ds64" {
+		t.Fatalf("not promoted to RF64: % x", b[:16])
+	}
+
+	if le.Uint64(b[28:]) != samples*8 || le.Uint64(b[36:]) != samples {
+		t.Errorf("ds64 data size %d, samples %d", le.Uint64(b[28:]), le.Uint64(b[36:]))
+	}
+}
+
+func TestIQRecordSigMF(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r := New(storage.NewLocal(dir), Options{})
+	meta := IQMeta{Callsign: "N0CALL", Model: "FLEX-6600", Channel: 2, CenterMHz: 7.1, SampleRate: 24000, Start: time.Now()}
+
+	rec, err := r.StartIQ(meta, IQFormatSigMF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec.WriteIQ(iqPayload(100))
+	rec.SetCenter(7.1)
+	rec.SetCenter(7.2)
+	rec.WriteIQ(iqPayload(50))
+
+	err = rec.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(rec.Key(), ".sigmf-meta") {
+		t.Fatalf("key = %q", rec.Key())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(strings.TrimSuffix(rec.Key(), "meta")+"data")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 150*8 {
+		t.Errorf("dataset is %d bytes, want %d", len(data), 150*8)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rec.Key())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Global   map[string]any `json:"global"`
+		Captures []sigmfCapture `json:"captures"`
+	}
+
+	err = json.Unmarshal(raw, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Global["core:datatype"] != "cf32_le" || doc.Global["core:sample_rate"] != float64(24000) {
+		t.Errorf("global = %v", doc.Global)
+	}
+
+	if len(doc.Captures) != 2 || doc.Captures[0].Frequency != 7.1e6 ||
+		doc.Captures[1].SampleStart != 100 || doc.Captures[1].Frequency != 7.2e6 {
+		t.Errorf("captures = %+v", doc.Captures)
+	}
+}
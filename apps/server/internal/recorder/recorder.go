@@ -0,0 +1,305 @@
+// Package recorder writes received Opus audio to the recording store, as
+// Ogg/Opus or, decoded by ffmpeg, as WAV.
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/storage"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// Format is the container a recording is stored in.
+type Format string
+
+const (
+	FormatOgg Format = "ogg"
+	FormatWAV Format = "wav"
+)
+
+var (
+	errFormat    = errors.New("recorder: format must be ogg or wav")
+	errNoDecoder = errors.New("recorder: wav needs ffmpeg, which was not found")
+	errNoStore   = errors.New("recorder: no recording store")
+)
+
+const (
+	// silentPacket is the largest Opus packet treated as silence. With the
+	// squelch closed the radio sends digital silence, which Opus codes in a
+	// handful of bytes; any real signal or noise takes far more.
+	silentPacket = 8
+	// squelchHang keeps recording this long after the audio goes silent, so
+	// pauses between overs are not clipped.
+	squelchHang = 1500 * time.Millisecond
+	// decodeTimeout bounds the WAV conversion when a recording stops.
+	decodeTimeout = 10 * time.Minute
+)
+
+// Options configures the recorder.
+type Options struct {
+	// FFmpeg is the ffmpeg binary used to decode WAV recordings; default
+	// "ffmpeg" on PATH.
+	FFmpeg string
+}
+
+// Recorder starts recordings into a store.
+type Recorder struct {
+	store  storage.Store
+	ffmpeg string
+}
+
+func New(store storage.Store, opt Options) *Recorder {
+	if opt.FFmpeg == "" {
+		opt.FFmpeg = "ffmpeg"
+	}
+
+	return &Recorder{store: store, ffmpeg: opt.FFmpeg}
+}
+
+// Meta describes what is being recorded; it names the file.
+type Meta struct {
+	Callsign string
+	Slice    string  // slice letter, e.g. "A"
+	FreqMHz  float64 // of the slice, 0 if unknown
+	Start    time.Time
+}
+
+var reUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Key returns the object key, e.g. "2024-05-01/N0CALL_14.074000_A_20240501T120000Z.ogg".
+// Times are UTC so recordings from bridges in different zones sort together.
+func (m Meta) Key(f Format) string {
+	return objectKey(m.Start, string(f), m.Callsign, freqPart(m.FreqMHz), m.Slice)
+}
+
+// objectKey joins the non-empty parts and the start time into a file name
+// under a directory for the day.
+func objectKey(start time.Time, ext string, parts ...string) string {
+	t := start.UTC()
+
+	name := make([]string, 0, len(parts)+1)
+	for _, p := range parts {
+		if p != "" {
+			name = append(name, p)
+		}
+	}
+
+	name = append(name, t.Format("20060102T150405Z"))
+
+	return t.Format("2006-01-02") + "/" + reUnsafe.ReplaceAllString(strings.Join(name, "_"), "-") + "." + ext
+}
+
+func freqPart(mhz float64) string {
+	if mhz <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%.6f", mhz)
+}
+
+// Recording is one recording in progress.
+type Recording struct {
+	r       *Recorder
+	key     string
+	format  Format
+	squelch bool
+
+	mu       sync.Mutex
+	dst      io.WriteCloser // the store object for ogg, a temporary file for wav
+	tmp      string
+	ogg      *oggwriter.OggWriter
+	open     time.Time // last time the squelch gate saw audio
+	recorded time.Duration
+	closed   bool
+}
+
+// Start begins a recording. With squelch set, silence is skipped, so the file
+// holds only the overs heard.
+func (r *Recorder) Start(meta Meta, f Format, squelch bool) (*Recording, error) {
+	if r == nil || r.store == nil {
+		return nil, errNoStore
+	}
+
+	rec := &Recording{r: r, key: meta.Key(f), format: f, squelch: squelch}
+
+	switch f {
+	case FormatOgg:
+		w, err := r.store.Create(rec.key)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: %w", err)
+		}
+
+		rec.dst = w
+	case FormatWAV:
+		_, err := exec.LookPath(r.ffmpeg)
+		if err != nil {
+			return nil, errNoDecoder
+		}
+
+		tmp, err := os.CreateTemp("", "recording-*.ogg")
+		if err != nil {
+			return nil, fmt.Errorf("recorder: %w", err)
+		}
+
+		rec.dst, rec.tmp = tmp, tmp.Name()
+	default:
+		return nil, errFormat
+	}
+
+	ogg, err := oggwriter.NewWith(rec.dst, 48000, 2)
+	if err != nil {
+		rec.discard()
+
+		return nil, fmt.Errorf("recorder: %w", err)
+	}
+
+	rec.ogg = ogg
+
+	return rec, nil
+}
+
+// Key is where the recording will appear in the store. A WAV recording whose
+// decode failed moves to the .ogg key once closed.
+func (rec *Recording) Key() string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.key
+}
+
+// Recorded is how much audio has been written so far.
+func (rec *Recording) Recorded() time.Duration {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.recorded
+}
+
+// WriteOpus adds one Opus packet of duration d.
+func (rec *Recording) WriteOpus(payload []byte, d time.Duration) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.closed {
+		return
+	}
+
+	if rec.squelch {
+		now := time.Now()
+		if len(payload) > silentPacket {
+			rec.open = now
+		}
+
+		if now.Sub(rec.open) > squelchHang {
+			return
+		}
+	}
+
+	err := rec.ogg.WriteRTP(&rtp.Packet{Payload: payload})
+	if err == nil {
+		rec.recorded += d
+	}
+}
+
+// Close finishes the recording and commits it to the store. WAV recordings
+// are decoded here, which can take a moment for long ones.
+func (rec *Recording) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.closed {
+		return nil
+	}
+
+	rec.closed = true
+
+	// The Ogg writer closes the file or store object beneath it.
+	err := rec.ogg.Close()
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	if rec.format != FormatWAV {
+		return nil
+	}
+
+	defer func() { _ = os.Remove(rec.tmp) }()
+
+	return rec.decodeWAV()
+}
+
+// decodeWAV converts the temporary Ogg file into a 16-bit WAV object. If
+// ffmpeg fails the Ogg is stored instead, under the same name with an .ogg
+// extension, so the audio is not lost.
+func (rec *Recording) decodeWAV() error {
+	ctx, cancel := context.WithTimeout(context.Background(), decodeTimeout)
+	defer cancel()
+
+	var stderr strings.Builder
+
+	wav := strings.TrimSuffix(rec.tmp, ".ogg") + ".wav"
+	defer func() { _ = os.Remove(wav) }()
+
+	cmd := exec.CommandContext(ctx, rec.r.ffmpeg, //nolint:gosec // binary is operator configuration
+		"-nostdin", "-loglevel", "error", "-y", "-i", rec.tmp, "-acodec", "pcm_s16le", wav)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		rec.key = strings.TrimSuffix(rec.key, "."+string(FormatWAV)) + "." + string(FormatOgg)
+
+		return errors.Join(
+			fmt.Errorf("recorder: ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String())),
+			rec.commit(rec.tmp),
+		)
+	}
+
+	return rec.commit(wav)
+}
+
+// commit copies a finished local file into the store under the recording's key.
+func (rec *Recording) commit(path string) error {
+	f, err := os.Open(path) //nolint:gosec // our own temporary file
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	w, err := rec.r.store.Create(rec.key)
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		_ = w.Close()
+
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	return nil
+}
+
+// discard abandons a recording that failed to start.
+func (rec *Recording) discard() {
+	_ = rec.dst.Close()
+
+	if rec.tmp != "" {
+		_ = os.Remove(rec.tmp)
+	}
+}
@@ -0,0 +1,123 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/storage"
+)
+
+var (
+	loud   = append([]byte{0x80}, make([]byte, 40)...)
+	silent = []byte{0x80, 0xff, 0xfe}
+)
+
+func TestMetaKey(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.FixedZone("EDT", -4*3600))
+
+	cases := map[string]Meta{
+		"2024-05-01/N0CALL_14.074000_A_20240501T120000Z.wav": {Callsign: "N0CALL", Slice: "A", FreqMHz: 14.074, Start: start},
+		"2024-05-01/20240501T120000Z.wav":                    {Start: start},
+		"2024-05-01/N0CALL-P_20240501T120000Z.wav":           {Callsign: "N0CALL/P", Start: start},
+	}
+	for want, m := range cases {
+		if got := m.Key(FormatWAV); got != want {
+			t.Errorf("Key(%+v) = %q, want %q", m, got, want)
+		}
+	}
+}
+
+func TestRecordOgg(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r := New(storage.NewLocal(dir), Options{})
+
+	rec, err := r.Start(Meta{Callsign: "N0CALL", Start: time.Now()}, FormatOgg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 100 {
+		rec.WriteOpus(loud, 10*time.Millisecond)
+	}
+
+	err = rec.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Recorded() != time.Second {
+		t.Errorf("recorded %s, want 1s", rec.Recorded())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rec.Key())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data[:4]) != "OggS" || len(data) < 100*len(loud) {
+		t.Errorf("recording is %d bytes starting %q", len(data), data[:4])
+	}
+}
+
+func TestRecordSquelchGate(t *testing.T) {
+	t.Parallel()
+
+	r := New(storage.NewLocal(t.TempDir()), Options{})
+
+	rec, err := r.Start(Meta{Start: time.Now()}, FormatOgg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rec.Close() }()
+
+	rec.WriteOpus(silent, 10*time.Millisecond) // squelch closed: skipped
+	rec.WriteOpus(loud, 10*time.Millisecond)
+	rec.WriteOpus(silent, 10*time.Millisecond) // within the hang time: kept
+
+	if rec.Recorded() != 20*time.Millisecond {
+		t.Errorf("recorded %s, want 20ms", rec.Recorded())
+	}
+}
+
+func TestRecordWAVFallsBackToOgg(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	r := New(storage.NewLocal(dir), Options{FFmpeg: "false"}) // exits 1
+
+	rec, err := r.Start(Meta{Start: time.Now()}, FormatWAV, false)
+	if err != nil {
+		t.Skipf("no false(1): %v", err)
+	}
+
+	rec.WriteOpus(loud, 10*time.Millisecond)
+
+	err = rec.Close()
+	if err == nil {
+		t.Fatal("expected the decode error")
+	}
+
+	if filepath.Ext(rec.Key()) != ".ogg" {
+		t.Fatalf("key %q should fall back to .ogg", rec.Key())
+	}
+
+	_, err = os.Stat(filepath.Join(dir, filepath.FromSlash(rec.Key())))
+	if err != nil {
+		t.Errorf("fallback recording missing: %v", err)
+	}
+}
+
+func TestStartRejectsUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(storage.NewLocal(t.TempDir()), Options{}).Start(Meta{Start: time.Now()}, "mp3", false)
+	if err == nil {
+		t.Error("expected an error for mp3")
+	}
+}
@@ -0,0 +1,424 @@
+package rigctl
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// client is one rigctl connection. Like rigctld, each connection has its own
+// current VFO.
+type client struct {
+	rig Rig
+	vfo string
+	w   *bufio.Writer
+}
+
+// command is one rigctl command. Getters print their values one per line,
+// labelled in extended responses; setters take args parameters and print only
+// a status.
+type command struct {
+	short  byte
+	name   string
+	args   int
+	labels []string
+	// raw commands print their own output and no status, like \dump_state.
+	raw bool
+	run func(cl *client, args []string) ([]string, error)
+}
+
+var commands = []command{
+	{short: 'f', name: "get_freq", labels: []string{"Frequency"}, run: (*client).getFreq},
+	{short: 'F', name: "set_freq", args: 1, run: (*client).setFreq},
+	{short: 'm', name: "get_mode", labels: []string{"Mode", "Passband"}, run: (*client).getMode},
+	{short: 'M', name: "set_mode", args: 2, run: (*client).setMode},
+	{short: 't', name: "get_ptt", labels: []string{"PTT"}, run: (*client).getPTT},
+	{short: 'T', name: "set_ptt", args: 1, run: (*client).setPTT},
+	{short: 'v', name: "get_vfo", labels: []string{"VFO"}, run: (*client).getVFO},
+	{short: 'V', name: "set_vfo", args: 1, run: (*client).setVFO},
+	{short: 's', name: "get_split_vfo", labels: []string{"Split", "TX VFO"}, run: (*client).getSplit},
+	{short: 'S', name: "set_split_vfo", args: 2, run: (*client).setSplit},
+	{short: 'i', name: "get_split_freq", labels: []string{"TX Frequency"}, run: (*client).getSplitFreq},
+	{short: 'I', name: "set_split_freq", args: 1, run: (*client).setSplitFreq},
+	{short: 'x', name: "get_split_mode", labels: []string{"TX Mode", "TX Passband"}, run: (*client).getSplitMode},
+	{short: 'X', name: "set_split_mode", args: 2, run: (*client).setSplitMode},
+	{short: '_', name: "get_info", labels: []string{"Info"}, run: (*client).getInfo},
+	{name: "chk_vfo", labels: []string{"ChkVFO"}, run: (*client).chkVFO},
+	{name: "get_powerstat", labels: []string{"Power Status"}, run: (*client).getPowerStat},
+	{name: "dump_state", raw: true, run: (*client).dumpState},
+}
+
+func lookup(tok string) (command, bool) {
+	name, long := strings.CutPrefix(tok, `\`)
+	for _, c := range commands {
+		if long && c.name == name || !long && len(tok) == 1 && c.short != 0 && c.short == tok[0] {
+			return c, true
+		}
+	}
+
+	return command{}, false
+}
+
+// line runs every command on a line of input and reports whether the client
+// asked to quit.
+func (cl *client) line(text string) bool {
+	toks := strings.Fields(text)
+
+	for len(toks) > 0 {
+		tok := toks[0]
+		toks = toks[1:]
+
+		// A leading '+' asks for an extended response, one item per line;
+		// ';', '|' or ',' for one on a single line, split by that character.
+		var sep string
+
+		if strings.ContainsAny(tok[:1], "+;|,") {
+			sep = tok[:1]
+			if sep == "+" {
+				sep = "\n"
+			}
+
+			tok = tok[1:]
+			if tok == "" {
+				if len(toks) == 0 {
+					return false
+				}
+
+				tok, toks = toks[0], toks[1:]
+			}
+		}
+
+		if tok == "q" || tok == "Q" || tok == `\quit` {
+			return true
+		}
+
+		c, ok := lookup(tok)
+		if !ok {
+			cl.reply(command{name: strings.TrimPrefix(tok, `\`)}, sep, nil, nil, ErrNotImplemented)
+
+			continue
+		}
+
+		if len(toks) < c.args {
+			cl.reply(c, sep, toks, nil, ErrInvalid)
+
+			return false
+		}
+
+		args := toks[:c.args]
+		toks = toks[c.args:]
+
+		vals, err := c.run(cl, args)
+		if c.raw && err == nil {
+			for _, v := range vals {
+				_, _ = cl.w.WriteString(v + "\n")
+			}
+
+			continue
+		}
+
+		cl.reply(c, sep, args, vals, err)
+	}
+
+	return false
+}
+
+// reply prints a command's result. Plain responses are the bare values for a
+// getter and "RPRT n" otherwise; extended responses echo the command and
+// label every value.
+func (cl *client) reply(c command, sep string, args, vals []string, err error) {
+	status := 0
+	if err != nil {
+		status = code(err)
+		vals = nil
+	}
+
+	if sep == "" {
+		for _, v := range vals {
+			_, _ = cl.w.WriteString(v + "\n")
+		}
+
+		if len(c.labels) == 0 || err != nil {
+			fmt.Fprintf(cl.w, "RPRT %d\n", status)
+		}
+
+		return
+	}
+
+	parts := []string{strings.TrimSpace(c.name + ": " + strings.Join(args, " "))}
+	if len(args) == 0 {
+		parts[0] = c.name + ":"
+	}
+
+	for i, v := range vals {
+		label := ""
+		if i < len(c.labels) {
+			label = c.labels[i]
+		}
+
+		parts = append(parts, label+": "+v)
+	}
+
+	parts = append(parts, fmt.Sprintf("RPRT %d", status))
+	_, _ = cl.w.WriteString(strings.Join(parts, sep) + "\n")
+}
+
+// vfoNames maps the names clients use onto VFOA and VFOB.
+var vfoNames = map[string]string{
+	"VFOA": VFOA, "A": VFOA, "Main": VFOA, "MainA": VFOA, "TX": VFOA, "RX": VFOA,
+	"VFOB": VFOB, "B": VFOB, "Sub": VFOB, "SubA": VFOB, "MainB": VFOB,
+}
+
+// other is the VFO that transmits in split.
+func other(vfo string) string {
+	if vfo == VFOA {
+		return VFOB
+	}
+
+	return VFOA
+}
+
+func parseBool(s string) (bool, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return false, ErrInvalid
+	}
+
+	return n != 0, nil
+}
+
+func boolString(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
+
+func (cl *client) getFreq([]string) ([]string, error) {
+	hz, err := cl.rig.Freq(cl.vfo)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // a Hamlib status
+	}
+
+	return []string{strconv.FormatFloat(hz, 'f', 0, 64)}, nil
+}
+
+func (cl *client) setFreq(args []string) ([]string, error) {
+	hz, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || hz <= 0 {
+		return nil, ErrInvalid
+	}
+
+	return nil, cl.rig.SetFreq(cl.vfo, hz) //nolint:wrapcheck // a Hamlib status
+}
+
+func (cl *client) getMode([]string) ([]string, error) {
+	return cl.mode(cl.vfo)
+}
+
+func (cl *client) setMode(args []string) ([]string, error) {
+	return nil, cl.applyMode(cl.vfo, args)
+}
+
+func (cl *client) mode(vfo string) ([]string, error) {
+	mode, pb, err := cl.rig.Mode(vfo)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // a Hamlib status
+	}
+
+	return []string{mode, strconv.Itoa(pb)}, nil
+}
+
+func (cl *client) applyMode(vfo string, args []string) error {
+	if args[0] == "?" {
+		return ErrInvalid
+	}
+
+	pb, err := strconv.Atoi(args[1])
+	if err != nil {
+		return ErrInvalid
+	}
+
+	return cl.rig.SetMode(vfo, strings.ToUpper(args[0]), pb) //nolint:wrapcheck // a Hamlib status
+}
+
+func (cl *client) getPTT([]string) ([]string, error) {
+	on, err := cl.rig.PTT()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // a Hamlib status
+	}
+
+	return []string{boolString(on)}, nil
+}
+
+func (cl *client) setPTT(args []string) ([]string, error) {
+	// 1 is plain PTT; 2 and 3 ask for mic or data audio, which the radio
+	// picks from its own transmit settings.
+	on, err := parseBool(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, cl.rig.SetPTT(on) //nolint:wrapcheck // a Hamlib status
+}
+
+func (cl *client) getVFO([]string) ([]string, error) {
+	return []string{cl.vfo}, nil
+}
+
+func (cl *client) setVFO(args []string) ([]string, error) {
+	if args[0] == "currVFO" {
+		return nil, nil
+	}
+
+	vfo, ok := vfoNames[args[0]]
+	if !ok {
+		return nil, ErrVFO
+	}
+
+	cl.vfo = vfo
+
+	return nil, nil
+}
+
+func (cl *client) getSplit([]string) ([]string, error) {
+	on, tx, err := cl.rig.Split()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // a Hamlib status
+	}
+
+	return []string{boolString(on), tx}, nil
+}
+
+func (cl *client) setSplit(args []string) ([]string, error) {
+	on, err := parseBool(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	tx := other(cl.vfo)
+	if args[1] != "currVFO" {
+		var ok bool
+
+		tx, ok = vfoNames[args[1]]
+		if !ok {
+			return nil, ErrVFO
+		}
+	}
+
+	return nil, cl.rig.SetSplit(on, tx) //nolint:wrapcheck // a Hamlib status
+}
+
+func (cl *client) getSplitFreq([]string) ([]string, error) {
+	hz, err := cl.rig.Freq(other(cl.vfo))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // a Hamlib status
+	}
+
+	return []string{strconv.FormatFloat(hz, 'f', 0, 64)}, nil
+}
+
+func (cl *client) setSplitFreq(args []string) ([]string, error) {
+	hz, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || hz <= 0 {
+		return nil, ErrInvalid
+	}
+
+	return nil, cl.rig.SetFreq(other(cl.vfo), hz) //nolint:wrapcheck // a Hamlib status
+}
+
+func (cl *client) getSplitMode([]string) ([]string, error) {
+	return cl.mode(other(cl.vfo))
+}
+
+func (cl *client) setSplitMode(args []string) ([]string, error) {
+	return nil, cl.applyMode(other(cl.vfo), args)
+}
+
+func (cl *client) getInfo([]string) ([]string, error) {
+	return []string{"solid-sdr bridge"}, nil
+}
+
+func (cl *client) chkVFO([]string) ([]string, error) {
+	// 0: clients do not prefix commands with a VFO.
+	return []string{"0"}, nil
+}
+
+func (cl *client) getPowerStat([]string) ([]string, error) {
+	return []string{"1"}, nil
+}
+
+// Hamlib mode bits, for \dump_state.
+const (
+	modeAM     = 0x1
+	modeCW     = 0x2
+	modeUSB    = 0x4
+	modeLSB    = 0x8
+	modeRTTY   = 0x10
+	modeFM     = 0x20
+	modePKTLSB = 0x400
+	modePKTUSB = 0x800
+	modePKTFM  = 0x1000
+	modeSAM    = 0x10000
+	modeDSB    = 0x80000
+
+	allModes = modeAM | modeCW | modeUSB | modeLSB | modeRTTY | modeFM |
+		modePKTLSB | modePKTUSB | modePKTFM | modeSAM | modeDSB
+)
+
+// dumpState answers \dump_state in protocol version 1, which is what Hamlib's
+// own NET rigctl backend reads when it connects: ranges, steps and filters,
+// then capabilities as key=value lines up to "done".
+func (cl *client) dumpState([]string) ([]string, error) {
+	m := fmt.Sprintf("0x%x", allModes)
+	ssb := fmt.Sprintf("0x%x", modeUSB|modeLSB|modePKTUSB|modePKTLSB)
+
+	return []string{
+		"1", // protocol version
+		"2", // rig model: NET rigctl
+		"0", // ITU region
+		// RX range: low high modes low_power high_power vfo ant
+		"30000.000000 54000000.000000 " + m + " -1 -1 0x3 0x1",
+		"0 0 0 0 0 0 0",
+		// TX range, powers in mW
+		"1800000.000000 54000000.000000 " + m + " 1000 100000 0x3 0x1",
+		"0 0 0 0 0 0 0",
+		// tuning steps
+		m + " 1",
+		"0 0",
+		// filters
+		ssb + " 2700",
+		fmt.Sprintf("0x%x 500", modeCW),
+		fmt.Sprintf("0x%x 6000", modeAM|modeSAM|modeDSB),
+		fmt.Sprintf("0x%x 12000", modeFM|modePKTFM),
+		"0 0",
+		"0",   // max RIT
+		"0",   // max XIT
+		"0",   // max IF shift
+		"0",   // announces
+		"0",   // preamps
+		"0",   // attenuators
+		"0x0", // has_get_func
+		"0x0", // has_set_func
+		"0x0", // has_get_level
+		"0x0", // has_set_level
+		"0x0", // has_get_parm
+		"0x0", // has_set_parm
+		"vfo_ops=0x0",
+		"ptt_type=0x1",
+		"targetable_vfo=0x0",
+		"has_set_vfo=1",
+		"has_get_vfo=1",
+		"has_set_freq=1",
+		"has_get_freq=1",
+		"has_set_conf=0",
+		"has_get_conf=0",
+		"has_power2mW=0",
+		"has_mW2power=0",
+		"timeout=2000",
+		"rig_model=2",
+		"done",
+	}, nil
+}
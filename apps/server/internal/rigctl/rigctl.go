@@ -0,0 +1,192 @@
+// Package rigctl serves the Hamlib NET rigctl protocol (what rigctld speaks)
+// so WSJT-X, fldigi and loggers configured for "Hamlib NET rigctl" can tune
+// and key a radio through the bridge.
+package rigctl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// VFOs a client can address. The bridge maps each onto a slice.
+const (
+	VFOA = "VFOA"
+	VFOB = "VFOB"
+)
+
+// Rig is the radio a client controls. Frequencies are in Hz, modes and
+// passbands use Hamlib's names and units.
+type Rig interface {
+	Freq(vfo string) (float64, error)
+	SetFreq(vfo string, hz float64) error
+	Mode(vfo string) (mode string, passband int, err error)
+	SetMode(vfo, mode string, passband int) error
+	PTT() (bool, error)
+	SetPTT(on bool) error
+	// Split reports whether transmit is on the other VFO, and which one
+	// transmits.
+	Split() (on bool, txVFO string, err error)
+	SetSplit(on bool, txVFO string) error
+}
+
+// Error is a Hamlib status code, answered as "RPRT -<code>".
+type Error int
+
+const (
+	ErrInvalid        Error = 1  // RIG_EINVAL
+	ErrNotImplemented Error = 4  // RIG_ENIMPL
+	ErrTimeout        Error = 5  // RIG_ETIMEOUT
+	ErrIO             Error = 6  // RIG_EIO
+	ErrProtocol       Error = 8  // RIG_EPROTO
+	ErrRejected       Error = 9  // RIG_ERJCTED
+	ErrUnavailable    Error = 11 // RIG_ENAVAIL
+	ErrVFO            Error = 16 // RIG_EVFO
+)
+
+func (e Error) Error() string {
+	switch e {
+	case ErrInvalid:
+		return "invalid parameter"
+	case ErrNotImplemented:
+		return "not implemented"
+	case ErrTimeout:
+		return "timed out"
+	case ErrIO:
+		return "no radio"
+	case ErrProtocol:
+		return "protocol error"
+	case ErrRejected:
+		return "command rejected by the radio"
+	case ErrUnavailable:
+		return "feature not available"
+	case ErrVFO:
+		return "invalid VFO"
+	default:
+		return fmt.Sprintf("hamlib error %d", int(e))
+	}
+}
+
+// code returns the status a failed command reports.
+func code(err error) int {
+	var e Error
+	if errors.As(err, &e) {
+		return -int(e)
+	}
+
+	return -int(ErrIO)
+}
+
+// Options configures the server.
+type Options struct {
+	// Rig returns the radio a client at clientIP controls.
+	Rig func(clientIP string) Rig
+	// Allowed filters client addresses, e.g. by the bridge's ACL; nil allows
+	// everyone.
+	Allowed func(net.IP) bool
+}
+
+// Server accepts rigctl clients.
+type Server struct {
+	opt Options
+}
+
+func New(opt Options) *Server {
+	return &Server{opt: opt}
+}
+
+// ListenAndServe serves clients on addr until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("rigctl listen: %w", err)
+	}
+
+	log.Printf("[rigctl] listening on %s", ln.Addr())
+
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts clients on ln until ctx is done.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			wg.Wait()
+
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("rigctl accept: %w", err)
+		}
+
+		ip := remoteIP(c)
+		if s.opt.Allowed != nil && !s.opt.Allowed(net.ParseIP(ip)) {
+			_ = c.Close()
+
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.serveConn(ctx, c, ip)
+		}()
+	}
+}
+
+func remoteIP(c net.Conn) string {
+	host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err != nil {
+		return c.RemoteAddr().String()
+	}
+
+	return host
+}
+
+// idleTimeout drops clients that have gone quiet; WSJT-X polls every second
+// or so.
+const idleTimeout = 5 * time.Minute
+
+func (s *Server) serveConn(ctx context.Context, c net.Conn, ip string) {
+	defer func() { _ = c.Close() }()
+
+	stop := context.AfterFunc(ctx, func() { _ = c.Close() })
+	defer stop()
+
+	log.Printf("[rigctl] client %s connected", ip)
+	defer log.Printf("[rigctl] client %s disconnected", ip)
+
+	cl := &client{rig: s.opt.Rig(ip), vfo: VFOA, w: bufio.NewWriter(c)}
+	sc := bufio.NewScanner(c)
+
+	for {
+		_ = c.SetReadDeadline(time.Now().Add(idleTimeout))
+
+		if !sc.Scan() {
+			return
+		}
+
+		quit := cl.line(sc.Text())
+
+		err := cl.w.Flush()
+		if err != nil || quit {
+			return
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package rigctl
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+type fakeRig struct {
+	freq  map[string]float64
+	mode  map[string]string
+	pb    map[string]int
+	ptt   bool
+	split bool
+	tx    string
+}
+
+func newFakeRig() *fakeRig {
+	return &fakeRig{
+		freq: map[string]float64{VFOA: 14074000, VFOB: 7074000},
+		mode: map[string]string{VFOA: "PKTUSB", VFOB: "LSB"},
+		pb:   map[string]int{VFOA: 3000, VFOB: 2700},
+		tx:   VFOA,
+	}
+}
+
+func (f *fakeRig) Freq(vfo string) (float64, error) { return f.freq[vfo], nil }
+
+func (f *fakeRig) SetFreq(vfo string, hz float64) error {
+	f.freq[vfo] = hz
+
+	return nil
+}
+
+func (f *fakeRig) Mode(vfo string) (string, int, error) { return f.mode[vfo], f.pb[vfo], nil }
+
+func (f *fakeRig) SetMode(vfo, mode string, passband int) error {
+	if mode == "WFM" {
+		return ErrRejected
+	}
+
+	f.mode[vfo], f.pb[vfo] = mode, passband
+
+	return nil
+}
+
+func (f *fakeRig) PTT() (bool, error) { return f.ptt, nil }
+
+func (f *fakeRig) SetPTT(on bool) error {
+	f.ptt = on
+
+	return nil
+}
+
+func (f *fakeRig) Split() (bool, string, error) { return f.split, f.tx, nil }
+
+func (f *fakeRig) SetSplit(on bool, tx string) error {
+	f.split, f.tx = on, tx
+
+	return nil
+}
+
+// run feeds input to a fresh client line by line and returns its output.
+func run(t *testing.T, rig Rig, input ...string) string {
+	t.Helper()
+
+	var out strings.Builder
+
+	cl := &client{rig: rig, vfo: VFOA, w: bufio.NewWriter(&out)}
+	for _, line := range input {
+		if cl.line(line) {
+			break
+		}
+	}
+
+	_ = cl.w.Flush()
+
+	return out.String()
+}
+
+func TestClient_Commands(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		input []string
+		want  string
+	}{
+		{"get freq", []string{"f"}, "14074000\n"},
+		{"set freq", []string{"F 14076000.000000", "f"}, "RPRT 0\n14076000\n"},
+		{"long names", []string{`\set_freq 21074000`, `\get_freq`}, "RPRT 0\n21074000\n"},
+		{"bad freq", []string{"F abc"}, "RPRT -1\n"},
+		{"missing argument", []string{"F"}, "RPRT -1\n"},
+		{"get mode", []string{"m"}, "PKTUSB\n3000\n"},
+		{"set mode", []string{"M usb 2400", "m"}, "RPRT 0\nUSB\n2400\n"},
+		{"rejected", []string{"M WFM 0"}, "RPRT -9\n"},
+		{"vfo", []string{"V VFOB", "v", "f"}, "RPRT 0\nVFOB\n7074000\n"},
+		{"bad vfo", []string{"V VFOC"}, "RPRT -16\n"},
+		{"ptt", []string{"T 1", "t"}, "RPRT 0\n1\n"},
+		{"split", []string{"S 1 VFOB", "s"}, "RPRT 0\n1\nVFOB\n"},
+		{"split freq", []string{"I 7076000", "i"}, "RPRT 0\n7076000\n"},
+		{"several per line", []string{"f m"}, "14074000\nPKTUSB\n3000\n"},
+		{"unknown", []string{"y"}, "RPRT -4\n"},
+		{"chk_vfo", []string{`\chk_vfo`}, "0\n"},
+		{"quit", []string{"q", "f"}, ""},
+		{"extended get", []string{"+f"}, "get_freq:\nFrequency: 14074000\nRPRT 0\n"},
+		{"extended set", []string{"+F 7000000"}, "set_freq: 7000000\nRPRT 0\n"},
+		{"extended single line", []string{";m"}, "get_mode:;Mode: PKTUSB;Passband: 3000;RPRT 0\n"},
+		{"extended long name", []string{`+\get_vfo`}, "get_vfo:\nVFO: VFOA\nRPRT 0\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := run(t, newFakeRig(), tc.input...)
+			if got != tc.want {
+				t.Errorf("%q:\ngot  %q\nwant %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClient_DumpState(t *testing.T) {
+	t.Parallel()
+
+	got := run(t, newFakeRig(), `\dump_state`)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	if lines[0] != "1" || lines[len(lines)-1] != "done" {
+		t.Errorf("dump_state = %q, want protocol 1 ending in done", got)
+	}
+
+	if strings.Contains(got, "RPRT") {
+		t.Errorf("dump_state carries a status: %q", got)
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(Options{Rig: func(string) Rig { return newFakeRig() }})
+
+	done := make(chan error, 1)
+
+	go func() { done <- srv.Serve(t.Context(), ln) }()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Write([]byte("F 3573000\nf\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rd := bufio.NewReader(c)
+	for _, want := range []string{"RPRT 0\n", "3573000\n"} {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if line != want {
+			t.Errorf("got %q, want %q", line, want)
+		}
+	}
+
+	_ = c.Close()
+	_ = ln.Close()
+
+	err = <-done
+	if err == nil {
+		t.Error("Serve returned nil after its listener closed while ctx was live")
+	}
+}
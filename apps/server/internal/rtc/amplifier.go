@@ -0,0 +1,457 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	errAmplifierAction  = errors.New("unknown amplifier action")
+	errAmplifierSandbox = errors.New("the ATU cannot be operated in a sandboxed session")
+	errAmplifierGuard   = errors.New("ATU command rejected by the command guard")
+)
+
+// interlockReasons explain the radio's interlock reasons.
+var interlockReasons = map[string]string{
+	"RCA_TXREQ":         "transmit requested on the RCA TX REQ input",
+	"ACC_TXREQ":         "transmit requested on the accessory connector",
+	"BAD_MODE":          "the transmit slice's mode cannot transmit",
+	"TUNED_TOO_FAR":     "the transmit slice is tuned too far from its panadapter",
+	"OUT_OF_BAND":       "the transmit frequency is outside the bands this radio may transmit on",
+	"PA_RANGE":          "the transmit frequency is outside the amplifier's range",
+	"CLIENT_TX_INHIBIT": "a client has inhibited transmit",
+	"XVTR_RX_ONLY":      "the transverter is receive-only",
+	"NO_TX_ASSIGNED":    "no slice is set to transmit",
+	"AMP":               "the amplifier is not ready",
+	"TGXL":              "the tuner is not ready",
+}
+
+// interlockStates explain the interlock states that stop transmit without a
+// reason.
+var interlockStates = map[string]string{
+	"TX_FAULT":    "the radio reported a transmit fault",
+	"TIMEOUT":     "transmit timed out",
+	"STUCK_INPUT": "a PTT input is stuck keyed",
+}
+
+// interlockText explains why an interlock in state for reason keeps the radio
+// from transmitting, or is empty when nothing does. Reasons the table does not
+// know are spelled out from the radio's name for them.
+func interlockText(state, reason string) string {
+	if reason != "" {
+		// The radio names an amplifier reason after it, e.g. "AMP:PGXL".
+		name, _, _ := strings.Cut(reason, ":")
+		if text, ok := interlockReasons[name]; ok {
+			return text
+		}
+
+		return strings.ToLower(strings.ReplaceAll(reason, "_", " "))
+	}
+
+	return interlockStates[state]
+}
+
+// Amplifier is an amplifier the radio knows about, as its "amplifier" status
+// reports it.
+type Amplifier struct {
+	Handle string `json:"handle"`
+	Model  string `json:"model,omitempty"`
+	IP     string `json:"ip,omitempty"`
+	Port   int    `json:"port,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	// State is e.g. "IDLE", "OPERATE" or "FAULT".
+	State string `json:"state,omitempty"`
+}
+
+// Tuner is an external antenna tuner the radio knows about, as its "tuner"
+// status reports it.
+type Tuner struct {
+	Handle  string `json:"handle"`
+	Model   string `json:"model,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Serial  string `json:"serial,omitempty"`
+	Operate bool   `json:"operate"`
+	Bypass  bool   `json:"bypass"`
+	Tuning  bool   `json:"tuning"`
+}
+
+// ATU is the radio's internal antenna tuner.
+type ATU struct {
+	// Status is e.g. "TUNE_SUCCESSFUL", "TUNE_IN_PROGRESS" or "TUNE_BYPASS".
+	Status          string `json:"status,omitempty"`
+	Enabled         bool   `json:"enabled"`
+	MemoriesEnabled bool   `json:"memoriesEnabled"`
+	UsingMemory     bool   `json:"usingMemory"`
+}
+
+// Interlock is the radio's transmit interlock. Reason and ReasonText say why
+// transmit is inhibited, when it is.
+type Interlock struct {
+	State      string `json:"state,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	ReasonText string `json:"reasonText,omitempty"`
+	Source     string `json:"source,omitempty"`
+	TXAllowed  bool   `json:"txAllowed"`
+}
+
+// RadioAmplifiers answers an "amplifier" message and the amplifiers endpoint,
+// and is sent again whenever it changes.
+type RadioAmplifiers struct {
+	Amplifiers []Amplifier `json:"amplifiers"`
+	Tuners     []Tuner     `json:"tuners"`
+	ATU        ATU         `json:"atu"`
+	Interlock  Interlock   `json:"interlock"`
+}
+
+// AmplifierRequest is a client's "amplifier" message. Action is "list",
+// which also subscribes to changes, "unsubscribe", or an ATU action: "start"
+// a tune, "bypass" the ATU, "clear" its memories, or "memories" to turn
+// recalling them on or off as Memories says.
+type AmplifierRequest struct {
+	Action   string `json:"action"`
+	Memories bool   `json:"memories,omitempty"`
+}
+
+// amplifierTable tracks the radio's amplifiers, tuners, ATU and interlock
+// from "amplifier", "tuner", "atu" and "interlock" status.
+type amplifierTable struct {
+	mu         sync.Mutex
+	amplifiers map[string]Amplifier
+	tuners     map[string]Tuner
+	atu        ATU
+	interlock  Interlock
+}
+
+// observe applies a status body. It reports whether anything changed.
+func (t *amplifierTable) observe(body string) bool {
+	kind, rest, _ := strings.Cut(body, " ")
+
+	switch kind {
+	case "amplifier":
+		return t.observeAmplifier(rest)
+	case "tuner":
+		return t.observeTuner(rest)
+	case "atu":
+		return t.observeATU(statusAttrs(rest))
+	case "interlock":
+		return t.observeInterlock(statusAttrs(rest))
+	}
+
+	return false
+}
+
+// observeAmplifier applies "<handle> removed" or "<handle> key=value ...".
+func (t *amplifierTable) observeAmplifier(rest string) bool {
+	handle, attrs, _ := strings.Cut(rest, " ")
+	f := statusAttrs(attrs)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, gone := f["removed"]; gone {
+		_, known := t.amplifiers[handle]
+		delete(t.amplifiers, handle)
+
+		return known
+	}
+
+	if t.amplifiers == nil {
+		t.amplifiers = make(map[string]Amplifier)
+	}
+
+	old, known := t.amplifiers[handle]
+	a := old
+	a.Handle = handle
+
+	for k, v := range f {
+		switch k {
+		case "model":
+			a.Model = v
+		case "ip":
+			a.IP = v
+		case "port":
+			a.Port, _ = strconv.Atoi(v)
+		case "serial_num":
+			a.Serial = v
+		case "state":
+			a.State = v
+		}
+	}
+
+	t.amplifiers[handle] = a
+
+	return !known || a != old
+}
+
+// observeTuner applies "<handle> removed" or "<handle> key=value ...".
+func (t *amplifierTable) observeTuner(rest string) bool {
+	handle, attrs, _ := strings.Cut(rest, " ")
+	f := statusAttrs(attrs)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, gone := f["removed"]; gone {
+		_, known := t.tuners[handle]
+		delete(t.tuners, handle)
+
+		return known
+	}
+
+	if t.tuners == nil {
+		t.tuners = make(map[string]Tuner)
+	}
+
+	old, known := t.tuners[handle]
+	tu := old
+	tu.Handle = handle
+
+	for k, v := range f {
+		switch k {
+		case "model":
+			tu.Model = v
+		case "ip":
+			tu.IP = v
+		case "serial_num":
+			tu.Serial = v
+		case "operate":
+			tu.Operate = v == "1"
+		case "bypass":
+			tu.Bypass = v == "1"
+		case "tuning":
+			tu.Tuning = v == "1"
+		}
+	}
+
+	t.tuners[handle] = tu
+
+	return !known || tu != old
+}
+
+func (t *amplifierTable) observeATU(f map[string]string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old := t.atu
+
+	for k, v := range f {
+		switch k {
+		case "status":
+			t.atu.Status = v
+		case "atu_enabled":
+			t.atu.Enabled = v == "1"
+		case "memories_enabled":
+			t.atu.MemoriesEnabled = v == "1"
+		case "using_mem":
+			t.atu.UsingMemory = v == "1"
+		}
+	}
+
+	return t.atu != old
+}
+
+func (t *amplifierTable) observeInterlock(f map[string]string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old := t.interlock
+
+	for k, v := range f {
+		switch k {
+		case "state":
+			t.interlock.State = v
+		case "reason":
+			t.interlock.Reason = v
+		case "source":
+			t.interlock.Source = v
+		case "tx_allowed":
+			t.interlock.TXAllowed = v == "1"
+		}
+	}
+
+	t.interlock.ReasonText = interlockText(t.interlock.State, t.interlock.Reason)
+
+	return t.interlock != old
+}
+
+// snapshot returns the state, with amplifiers and tuners in handle order.
+func (t *amplifierTable) snapshot() RadioAmplifiers {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a := RadioAmplifiers{
+		Amplifiers: make([]Amplifier, 0, len(t.amplifiers)),
+		Tuners:     make([]Tuner, 0, len(t.tuners)),
+		ATU:        t.atu,
+		Interlock:  t.interlock,
+	}
+
+	for _, amp := range t.amplifiers {
+		a.Amplifiers = append(a.Amplifiers, amp)
+	}
+
+	for _, tu := range t.tuners {
+		a.Tuners = append(a.Tuners, tu)
+	}
+
+	slices.SortFunc(a.Amplifiers, func(x, y Amplifier) int { return strings.Compare(x.Handle, y.Handle) })
+	slices.SortFunc(a.Tuners, func(x, y Tuner) int { return strings.Compare(x.Handle, y.Handle) })
+
+	return a
+}
+
+// observeAmplifiers tracks the radio's amplifiers, tuners, ATU and interlock
+// from a status body.
+func (rc *radioConn) observeAmplifiers(body string) {
+	if !rc.amplifiers.observe(body) {
+		return
+	}
+
+	rc.mu.RLock()
+	onAmplifiers := rc.onAmplifiers
+	rc.mu.RUnlock()
+
+	if onAmplifiers != nil {
+		onAmplifiers()
+	}
+}
+
+// refreshAmplifiers subscribes to amplifier, ATU and transmit status once
+// per connection; the radio answers each with the current state.
+func (rc *radioConn) refreshAmplifiers() error {
+	rc.mu.Lock()
+	done := rc.amplifiersSubscribed
+	rc.amplifiersSubscribed = true
+	rc.mu.Unlock()
+
+	if done {
+		return nil
+	}
+
+	for _, cmd := range []string{"sub amplifier all", "sub atu all", "sub tx all"} {
+		_, err := rc.clientCommand(cmd)
+		if err != nil {
+			rc.mu.Lock()
+			rc.amplifiersSubscribed = false
+			rc.mu.Unlock()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// atuCommand is the radio command for an ATU action.
+func atuCommand(req AmplifierRequest) (string, error) {
+	switch req.Action {
+	case "start", "bypass", "clear":
+		return "atu " + req.Action, nil
+	case "memories":
+		enabled := 0
+		if req.Memories {
+			enabled = 1
+		}
+
+		return fmt.Sprintf("atu set memories_enabled=%d", enabled), nil
+	}
+
+	return "", fmt.Errorf("%w %q", errAmplifierAction, req.Action)
+}
+
+// runAmplifier lists the session's radio's amplifiers or operates its ATU.
+// Tuning keys the radio, so a sandboxed session may not, and each command
+// must pass the guard as if the client had sent it.
+func (cs *clientSession) runAmplifier(req AmplifierRequest) (RadioAmplifiers, error) {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return RadioAmplifiers{}, errClientNoRadio
+	}
+
+	if req.Action == "list" {
+		err := rc.refreshAmplifiers()
+
+		return rc.amplifiers.snapshot(), err
+	}
+
+	cmd, err := atuCommand(req)
+	if err != nil {
+		return RadioAmplifiers{}, err
+	}
+
+	if cs.sandbox.isEnabled() {
+		return RadioAmplifiers{}, errAmplifierSandbox
+	}
+
+	if !cs.allowImmediate(rc, fmt.Sprintf("C%d|%s\n", internalClientSequence, cmd)) {
+		return RadioAmplifiers{}, errAmplifierGuard
+	}
+
+	_, err = rc.clientCommand(cmd)
+	if err != nil {
+		return RadioAmplifiers{}, err
+	}
+
+	return rc.amplifiers.snapshot(), nil
+}
+
+func (cs *clientSession) handleAmplifier(raw json.RawMessage) {
+	var req AmplifierRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	switch req.Action {
+	case "unsubscribe":
+		cs.amplifiers.Store(false)
+
+		return
+	case "list":
+		cs.amplifiers.Store(true)
+	}
+
+	a, err := cs.runAmplifier(req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "AMPLIFIER_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeAmplifiers, a))
+}
+
+// amplifiersChanged updates a session that listed the amplifiers.
+func (cs *clientSession) amplifiersChanged(rc *radioConn) {
+	if cs.amplifiers.Load() {
+		cs.trySend(mustEncode(typeAmplifiers, rc.amplifiers.snapshot()))
+	}
+}
+
+// Amplifiers reports session id's radio's amplifiers, tuners, ATU and
+// interlock.
+func (s *Server) Amplifiers(id string) (RadioAmplifiers, error) {
+	return s.AmplifierAction(id, AmplifierRequest{Action: "list"})
+}
+
+// AmplifierAction runs req for session id as its client's "amplifier"
+// message would.
+func (s *Server) AmplifierAction(id string, req AmplifierRequest) (RadioAmplifiers, error) {
+	cs := s.session(id)
+	if cs == nil {
+		return RadioAmplifiers{}, ErrNoSession
+	}
+
+	return cs.runAmplifier(req)
+}
@@ -0,0 +1,141 @@
+package rtc
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestAmplifierTable_Observe(t *testing.T) {
+	t.Parallel()
+
+	var tbl amplifierTable
+
+	if !tbl.observe("amplifier 0x1C5B0A2F ip=192.168.1.60 model=PowerGeniusXL port=9008 serial_num=1234-5678 state=IDLE") {
+		t.Error("a new amplifier is not a change")
+	}
+
+	if tbl.observe("amplifier 0x1C5B0A2F state=IDLE") {
+		t.Error("a repeated state is a change")
+	}
+
+	tbl.observe("amplifier 0x1C5B0A2F state=OPERATE")
+	tbl.observe("tuner 0x2A00B001 ip=192.168.1.61 model=TunerGeniusXL serial_num=42 operate=1 bypass=0 tuning=1")
+	tbl.observe("atu status=TUNE_IN_PROGRESS atu_enabled=1 memories_enabled=1 using_mem=0")
+	tbl.observe("interlock tx_client_handle=0x00000000 state=NOT_READY reason=OUT_OF_BAND source= tx_allowed=0")
+
+	a := tbl.snapshot()
+
+	wantAmp := Amplifier{Handle: "0x1C5B0A2F", Model: "PowerGeniusXL", IP: "192.168.1.60", Port: 9008, Serial: "1234-5678", State: "OPERATE"}
+	if len(a.Amplifiers) != 1 || a.Amplifiers[0] != wantAmp {
+		t.Errorf("amplifiers = %+v", a.Amplifiers)
+	}
+
+	wantTuner := Tuner{Handle: "0x2A00B001", Model: "TunerGeniusXL", IP: "192.168.1.61", Serial: "42", Operate: true, Tuning: true}
+	if len(a.Tuners) != 1 || a.Tuners[0] != wantTuner {
+		t.Errorf("tuners = %+v", a.Tuners)
+	}
+
+	if a.ATU != (ATU{Status: "TUNE_IN_PROGRESS", Enabled: true, MemoriesEnabled: true}) {
+		t.Errorf("atu = %+v", a.ATU)
+	}
+
+	if a.Interlock.State != "NOT_READY" || a.Interlock.Reason != "OUT_OF_BAND" || a.Interlock.TXAllowed ||
+		a.Interlock.ReasonText != interlockReasons["OUT_OF_BAND"] {
+		t.Errorf("interlock = %+v", a.Interlock)
+	}
+
+	tbl.observe("interlock state=READY reason= tx_allowed=1")
+
+	if il := tbl.snapshot().Interlock; il.ReasonText != "" || !il.TXAllowed {
+		t.Errorf("ready interlock = %+v", il)
+	}
+
+	if !tbl.observe("amplifier 0x1C5B0A2F removed") || len(tbl.snapshot().Amplifiers) != 0 {
+		t.Error("removal not applied")
+	}
+
+	if tbl.observe("slice 0 RF_frequency=14.074") {
+		t.Error("not an amplifier status")
+	}
+}
+
+func TestInterlockText(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct{ state, reason, want string }{
+		{"NOT_READY", "BAD_MODE", "the transmit slice's mode cannot transmit"},
+		{"NOT_READY", "AMP:PGXL", "the amplifier is not ready"},
+		{"NOT_READY", "NEW_REASON", "new reason"},
+		{"TIMEOUT", "", "transmit timed out"},
+		{"READY", "", ""},
+	}
+
+	for _, c := range cases {
+		if got := interlockText(c.state, c.reason); got != c.want {
+			t.Errorf("interlockText(%q, %q) = %q, want %q", c.state, c.reason, got, c.want)
+		}
+	}
+}
+
+func TestClientSession_RunAmplifier(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{handleHex: "1234ABCD"}
+	rc.out = newTCPWriter(client, func([]byte) {}, nil)
+	t.Cleanup(rc.out.close)
+
+	cs := &clientSession{srv: &Server{}, radio: rc}
+
+	lines := make(chan string, 8)
+
+	go func() {
+		rd := bufio.NewReader(radio)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			lines <- strings.TrimSpace(line)
+			rc.consumeClientReply("R2147483640|0|")
+		}
+	}()
+
+	_, err := cs.runAmplifier(AmplifierRequest{Action: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"sub amplifier all", "sub atu all", "sub tx all"} {
+		if l := nextLine(t, lines); l != "C2147483640|"+want {
+			t.Errorf("sent %q, want %q", l, want)
+		}
+	}
+
+	_, err = cs.runAmplifier(AmplifierRequest{Action: "memories", Memories: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, lines); l != "C2147483640|atu set memories_enabled=1" {
+		t.Errorf("sent %q", l)
+	}
+
+	_, err = cs.runAmplifier(AmplifierRequest{Action: "tune"})
+	if !errors.Is(err, errAmplifierAction) {
+		t.Errorf("unknown action: %v", err)
+	}
+
+	cs.sandbox.set(true)
+
+	_, err = cs.runAmplifier(AmplifierRequest{Action: "start"})
+	if !errors.Is(err, errAmplifierSandbox) {
+		t.Errorf("sandboxed start: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/antenna"
+)
+
+// antennaSelectTimeout is how long a client's antenna selection waits for
+// the switch.
+const antennaSelectTimeout = 3 * time.Second
+
+var (
+	errAntennaDisabled  = errors.New("no antenna switches configured")
+	errAntennaAction    = errors.New("unknown antenna action")
+	errAntennaSandboxed = errors.New("antennas cannot be switched in a sandboxed session")
+)
+
+// antennaRequest is a client's "antenna" message.
+type antennaRequest struct {
+	// Action is "subscribe", "unsubscribe" or "select".
+	Action string `json:"action"`
+	// Switch is the switch's address or name, for select.
+	Switch  string `json:"switch,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Antenna int    `json:"antenna,omitempty"`
+}
+
+// antennasPayload lists the antenna switches; it answers "subscribe" and is
+// sent again whenever a switch changes.
+type antennasPayload struct {
+	Switches []antenna.Switch `json:"switches"`
+}
+
+// publishAntennas sends the switches to the sessions that asked for them.
+func (s *Server) publishAntennas(antenna.Switch) {
+	msg := mustEncode(typeAntennas, antennasPayload{Switches: s.antennas.Switches()})
+
+	for _, cs := range s.sessionList() {
+		if cs.antennas.Load() {
+			cs.trySend(msg)
+		}
+	}
+}
+
+func (cs *clientSession) handleAntenna(raw json.RawMessage) {
+	var req antennaRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	svc := cs.srv.antennas
+	if svc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "ANTENNA_FAILED", Message: errAntennaDisabled.Error()}))
+
+		return
+	}
+
+	switch req.Action {
+	case "subscribe":
+		cs.antennas.Store(true)
+		cs.trySend(mustEncode(typeAntennas, antennasPayload{Switches: svc.Switches()}))
+	case "unsubscribe":
+		cs.antennas.Store(false)
+	case "select":
+		if cs.sandbox.isEnabled() {
+			err = errAntennaSandboxed
+
+			break
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), antennaSelectTimeout)
+		err = svc.Select(ctx, req.Switch, req.Port, req.Antenna)
+
+		cancel()
+	default:
+		err = fmt.Errorf("%w %q", errAntennaAction, req.Action)
+	}
+
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "ANTENNA_FAILED", Message: err.Error()}))
+	}
+}
+
+// followAntennas has the antenna switches follow the radio's active slice,
+// or its TX slice when none is marked active, after a slice status body.
+func (rc *radioConn) followAntennas(body string) {
+	rc.mu.RLock()
+	svc := rc.antennas
+	rc.mu.RUnlock()
+
+	if svc == nil || !strings.HasPrefix(body, "slice ") {
+		return
+	}
+
+	if mhz, ok := rc.info.activeFreq(); ok {
+		svc.Follow(rc.addr, mhz)
+	}
+}
+
+// activeFreq is the frequency of the active slice, or of the TX slice when
+// none is active.
+func (ri *radioInfo) activeFreq() (float64, bool) {
+	active, _, ok := ri.focus()
+
+	return active.freqMHz, ok
+}
+
+// focus returns the slice being operated, the active one or else the TX
+// slice, and the slice transmitting, the TX one or else the active slice. It
+// reports false while neither is tuned.
+func (ri *radioInfo) focus() (sliceInfo, sliceInfo, bool) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	var active, tx sliceInfo
+
+	for _, s := range ri.slices {
+		if s.active && active.freqMHz == 0 {
+			active = s
+		}
+
+		if s.tx && tx.freqMHz == 0 {
+			tx = s
+		}
+	}
+
+	if active.freqMHz == 0 {
+		active = tx
+	}
+
+	if tx.freqMHz == 0 {
+		tx = active
+	}
+
+	return active, tx, active.freqMHz > 0
+}
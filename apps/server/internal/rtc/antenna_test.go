@@ -0,0 +1,26 @@
+package rtc
+
+import "testing"
+
+func TestRadioInfo_ActiveFreq(t *testing.T) {
+	t.Parallel()
+
+	var ri radioInfo
+
+	if _, ok := ri.activeFreq(); ok {
+		t.Error("no slices yet")
+	}
+
+	ri.observe("slice 0 in_use=1 RF_frequency=7.074000 tx=1 active=0")
+	ri.observe("slice 1 in_use=1 RF_frequency=14.250000 tx=0 active=0")
+
+	if mhz, ok := ri.activeFreq(); !ok || mhz != 7.074 {
+		t.Errorf("tx slice: %v %v", mhz, ok)
+	}
+
+	ri.observe("slice 1 active=1")
+
+	if mhz, ok := ri.activeFreq(); !ok || mhz != 14.25 {
+		t.Errorf("active slice: %v %v", mhz, ok)
+	}
+}
@@ -0,0 +1,397 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// internalAudioGroupSequence tags slice mute commands issued by the bridge so
+// their replies can be swallowed instead of confusing the client.
+const internalAudioGroupSequence = 2147483646
+
+const (
+	audioGroupSolo   = "solo"
+	audioGroupUnsolo = "unsolo"
+	audioGroupMute   = "mute"
+	audioGroupUnmute = "unmute"
+	audioGroupLink   = "link"
+	audioGroupUnlink = "unlink"
+)
+
+var (
+	errUnknownSlice       = errors.New("unknown slice")
+	errLinkTooSmall       = errors.New("link needs at least two slices")
+	errUnknownAudioAction = errors.New("unknown audio group action")
+)
+
+type audioGroupPayload struct {
+	Action string `json:"action"`
+	Slice  int    `json:"slice"`
+	Slices []int  `json:"slices,omitempty"`
+}
+
+// audioGroupsPayload is the full group state, sent after every change.
+type audioGroupsPayload struct {
+	Solo   *int    `json:"solo"`
+	Muted  []int   `json:"muted"`
+	Groups [][]int `json:"groups"`
+}
+
+// audioGroups layers solo and link-group semantics over the radio's per-slice
+// audio_mute flag. The server keeps one per radio, shared by every session on
+// it no matter which one toggles it.
+//
+// Each slice carries the operator's own mute preference. A solo mutes every
+// slice outside the soloed slice's link group without touching those
+// preferences, so un-soloing restores the previous mix.
+type audioGroups struct {
+	mu     sync.Mutex
+	slices map[int]*sliceAudio // known in-use slices by index
+	link   map[int]int         // slice index -> link group id
+	nextID int
+	solo   int // soloed slice, or -1
+}
+
+type sliceAudio struct {
+	muted      bool // operator preference
+	radioMuted bool // last audio_mute reported by the radio
+}
+
+func newAudioGroups() *audioGroups {
+	return &audioGroups{
+		slices: make(map[int]*sliceAudio),
+		link:   make(map[int]int),
+		solo:   -1,
+	}
+}
+
+// observe updates the state from a "slice <n> ..." status body. It returns the
+// commands needed to re-assert group semantics when the radio's mute state
+// drifts from them, e.g. a new slice appearing while another is soloed.
+func (g *audioGroups) observe(body string) []string {
+	rest, ok := strings.CutPrefix(body, "slice ")
+	if !ok {
+		return nil
+	}
+
+	idxStr, attrs, _ := strings.Cut(rest, " ")
+
+	idx, err := strconv.Atoi(idxStr)
+	if err != nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if extractString(attrs, "in_use=") == "0" {
+		g.removeLocked(idx)
+
+		return nil
+	}
+
+	s, known := g.slices[idx]
+	if !known {
+		s = &sliceAudio{}
+		g.slices[idx] = s
+	}
+
+	mute := extractString(attrs, "audio_mute=")
+	if mute == "" {
+		if known {
+			return nil
+		}
+
+		return g.syncLocked()
+	}
+
+	s.radioMuted = mute == "1"
+
+	// Outside a solo, a mute the operator toggles directly on the radio (or
+	// from a client unaware of groups) becomes that slice's preference.
+	if g.solo < 0 {
+		s.muted = s.radioMuted
+
+		return nil
+	}
+
+	return g.syncLocked()
+}
+
+// apply performs a client action and returns the radio commands it implies.
+func (g *audioGroups) apply(p audioGroupPayload) ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch p.Action {
+	case audioGroupSolo:
+		if _, ok := g.slices[p.Slice]; !ok {
+			return nil, fmt.Errorf("%w: %d", errUnknownSlice, p.Slice)
+		}
+
+		g.solo = p.Slice
+	case audioGroupUnsolo:
+		g.solo = -1
+	case audioGroupMute, audioGroupUnmute:
+		if _, ok := g.slices[p.Slice]; !ok {
+			return nil, fmt.Errorf("%w: %d", errUnknownSlice, p.Slice)
+		}
+
+		for _, idx := range g.membersLocked(p.Slice) {
+			g.slices[idx].muted = p.Action == audioGroupMute
+		}
+	case audioGroupLink:
+		members := slices.Compact(slices.Sorted(slices.Values(p.Slices)))
+		if len(members) < 2 {
+			return nil, errLinkTooSmall
+		}
+
+		for _, idx := range members {
+			if _, ok := g.slices[idx]; !ok {
+				return nil, fmt.Errorf("%w: %d", errUnknownSlice, idx)
+			}
+		}
+
+		for _, idx := range members {
+			g.unlinkLocked(idx)
+		}
+
+		g.nextID++
+		for _, idx := range members {
+			g.link[idx] = g.nextID
+		}
+	case audioGroupUnlink:
+		g.unlinkLocked(p.Slice)
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownAudioAction, p.Action)
+	}
+
+	return g.syncLocked(), nil
+}
+
+func (g *audioGroups) removeLocked(idx int) {
+	delete(g.slices, idx)
+	g.unlinkLocked(idx)
+
+	if g.solo == idx {
+		g.solo = -1
+	}
+}
+
+// unlinkLocked takes idx out of its link group, dissolving the group if
+// only one slice is left in it.
+func (g *audioGroups) unlinkLocked(idx int) {
+	id, ok := g.link[idx]
+	if !ok {
+		return
+	}
+
+	delete(g.link, idx)
+
+	var left []int
+
+	for other, oid := range g.link {
+		if oid == id {
+			left = append(left, other)
+		}
+	}
+
+	if len(left) == 1 {
+		delete(g.link, left[0])
+	}
+}
+
+// membersLocked returns idx and every slice linked to it.
+func (g *audioGroups) membersLocked(idx int) []int {
+	id, linked := g.link[idx]
+	if !linked {
+		return []int{idx}
+	}
+
+	var out []int
+
+	for other, oid := range g.link {
+		if _, ok := g.slices[other]; ok && oid == id {
+			out = append(out, other)
+		}
+	}
+
+	if !slices.Contains(out, idx) {
+		out = append(out, idx)
+	}
+
+	return out
+}
+
+func (g *audioGroups) effectiveMuteLocked(idx int) bool {
+	if g.slices[idx].muted {
+		return true
+	}
+
+	if g.solo < 0 {
+		return false
+	}
+
+	return !slices.Contains(g.membersLocked(g.solo), idx)
+}
+
+// syncLocked returns commands for every slice whose radio state differs from
+// the group semantics, optimistically recording the new radio state.
+func (g *audioGroups) syncLocked() []string {
+	idxs := make([]int, 0, len(g.slices))
+	for idx := range g.slices {
+		idxs = append(idxs, idx)
+	}
+
+	slices.Sort(idxs)
+
+	var cmds []string
+
+	for _, idx := range idxs {
+		want := g.effectiveMuteLocked(idx)
+		if g.slices[idx].radioMuted == want {
+			continue
+		}
+
+		g.slices[idx].radioMuted = want
+		cmds = append(cmds, fmt.Sprintf("slice set %d audio_mute=%s", idx, boolFlag(want)))
+	}
+
+	return cmds
+}
+
+func (g *audioGroups) snapshot() audioGroupsPayload {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p := audioGroupsPayload{Muted: []int{}, Groups: [][]int{}}
+
+	if g.solo >= 0 {
+		solo := g.solo
+		p.Solo = &solo
+	}
+
+	byID := make(map[int][]int)
+
+	for idx := range g.slices {
+		if g.effectiveMuteLocked(idx) {
+			p.Muted = append(p.Muted, idx)
+		}
+
+		if id, ok := g.link[idx]; ok {
+			byID[id] = append(byID[id], idx)
+		}
+	}
+
+	for _, members := range byID {
+		slices.Sort(members)
+		p.Groups = append(p.Groups, members)
+	}
+
+	slices.Sort(p.Muted)
+	slices.SortFunc(p.Groups, func(a, b []int) int { return a[0] - b[0] })
+
+	return p
+}
+
+func boolFlag(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
+
+// sendAudioGroupCommands writes bridge-issued slice commands to the radio.
+func (rc *radioConn) sendAudioGroupCommands(cmds []string) {
+	for _, cmd := range cmds {
+		err := rc.writeTCPString(fmt.Sprintf("C%d|%s\n", internalAudioGroupSequence, cmd))
+		if err != nil {
+			log.Printf("[rtc] audio group: %v", err)
+
+			return
+		}
+	}
+}
+
+func isInternalAudioGroupReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalAudioGroupSequence))
+}
+
+func (cs *clientSession) handleAudioGroup(raw json.RawMessage) {
+	var p audioGroupPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "NO_RADIO", Message: "no radio connected"}))
+
+		return
+	}
+
+	groups := rc.audioGroups()
+
+	cmds, err := groups.apply(p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_AUDIO_GROUP", Message: err.Error()}))
+
+		return
+	}
+
+	rc.sendAudioGroupCommands(cmds)
+	cs.srv.audioGroupsChanged(rc.addr, groups.snapshot())
+}
+
+// radioAudio returns the audio groups of the radio at addr, shared by every
+// session on it.
+func (s *Server) radioAudio(addr string) *audioGroups {
+	s.audioMu.Lock()
+	defer s.audioMu.Unlock()
+
+	if s.audio == nil {
+		s.audio = make(map[string]*audioGroups)
+	}
+
+	g, ok := s.audio[addr]
+	if !ok {
+		g = newAudioGroups()
+		s.audio[addr] = g
+	}
+
+	return g
+}
+
+// audioGroupsChanged sends the groups of the radio at addr to every session
+// on it.
+func (s *Server) audioGroupsChanged(addr string, p audioGroupsPayload) {
+	msg := mustEncode(typeAudioGroups, p)
+
+	for _, cs := range s.sessionList() {
+		if rc := cs.currentRadio(); rc != nil && rc.addr == addr {
+			cs.trySend(msg)
+		}
+	}
+}
+
+// audioGroups returns the audio groups rc's commands follow.
+func (rc *radioConn) audioGroups() *audioGroups {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	return rc.audio
+}
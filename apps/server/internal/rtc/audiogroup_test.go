@@ -0,0 +1,191 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func newTestAudioGroups(t *testing.T, idxs ...string) *audioGroups {
+	t.Helper()
+
+	g := newAudioGroups()
+	for _, idx := range idxs {
+		if cmds := g.observe("slice " + idx + " in_use=1 audio_mute=0"); len(cmds) != 0 {
+			t.Fatalf("observe slice %s: unexpected commands %v", idx, cmds)
+		}
+	}
+
+	return g
+}
+
+func TestAudioGroups_SoloMutesOthersAndRestores(t *testing.T) {
+	t.Parallel()
+
+	g := newTestAudioGroups(t, "0", "1", "2")
+
+	cmds, err := g.apply(audioGroupPayload{Action: audioGroupMute, Slice: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"slice set 2 audio_mute=1"}; !slices.Equal(cmds, want) {
+		t.Errorf("mute: got %v, want %v", cmds, want)
+	}
+
+	cmds, _ = g.apply(audioGroupPayload{Action: audioGroupSolo, Slice: 0})
+	if want := []string{"slice set 1 audio_mute=1"}; !slices.Equal(cmds, want) {
+		t.Errorf("solo: got %v, want %v", cmds, want)
+	}
+
+	// Slice 2 stays muted after un-solo because the operator muted it.
+	cmds, _ = g.apply(audioGroupPayload{Action: audioGroupUnsolo})
+	if want := []string{"slice set 1 audio_mute=0"}; !slices.Equal(cmds, want) {
+		t.Errorf("unsolo: got %v, want %v", cmds, want)
+	}
+}
+
+func TestAudioGroups_LinkedSliceFollowsSoloAndMute(t *testing.T) {
+	t.Parallel()
+
+	g := newTestAudioGroups(t, "0", "1", "2")
+
+	_, err := g.apply(audioGroupPayload{Action: audioGroupLink, Slices: []int{0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmds, _ := g.apply(audioGroupPayload{Action: audioGroupSolo, Slice: 1})
+	if want := []string{"slice set 2 audio_mute=1"}; !slices.Equal(cmds, want) {
+		t.Errorf("solo linked: got %v, want %v", cmds, want)
+	}
+
+	cmds, _ = g.apply(audioGroupPayload{Action: audioGroupMute, Slice: 0})
+	if want := []string{"slice set 0 audio_mute=1", "slice set 1 audio_mute=1"}; !slices.Equal(cmds, want) {
+		t.Errorf("mute linked: got %v, want %v", cmds, want)
+	}
+
+	snap := g.snapshot()
+	if snap.Solo == nil || *snap.Solo != 1 {
+		t.Errorf("snapshot solo: got %v", snap.Solo)
+	}
+
+	if len(snap.Groups) != 1 || !slices.Equal(snap.Groups[0], []int{0, 1}) {
+		t.Errorf("snapshot groups: got %v", snap.Groups)
+	}
+}
+
+func TestAudioGroups_NewSliceDuringSoloIsMuted(t *testing.T) {
+	t.Parallel()
+
+	g := newTestAudioGroups(t, "0")
+
+	_, _ = g.apply(audioGroupPayload{Action: audioGroupSolo, Slice: 0})
+
+	cmds := g.observe("slice 3 in_use=1 audio_mute=0")
+	if want := []string{"slice set 3 audio_mute=1"}; !slices.Equal(cmds, want) {
+		t.Errorf("got %v, want %v", cmds, want)
+	}
+
+	// Removing the soloed slice clears the solo.
+	g.observe("slice 0 in_use=0")
+
+	if snap := g.snapshot(); snap.Solo != nil {
+		t.Errorf("solo should clear when its slice is removed, got %d", *snap.Solo)
+	}
+}
+
+func TestAudioGroups_Errors(t *testing.T) {
+	t.Parallel()
+
+	g := newTestAudioGroups(t, "0")
+
+	_, err := g.apply(audioGroupPayload{Action: audioGroupSolo, Slice: 7})
+	if !errors.Is(err, errUnknownSlice) {
+		t.Errorf("solo unknown slice: got %v", err)
+	}
+
+	_, err = g.apply(audioGroupPayload{Action: audioGroupLink, Slices: []int{0}})
+	if !errors.Is(err, errLinkTooSmall) {
+		t.Errorf("link one slice: got %v", err)
+	}
+
+	_, err = g.apply(audioGroupPayload{Action: audioGroupLink, Slices: []int{0, 0}})
+	if !errors.Is(err, errLinkTooSmall) {
+		t.Errorf("link a slice to itself: got %v", err)
+	}
+
+	_, err = g.apply(audioGroupPayload{Action: audioGroupLink, Slices: []int{0, 5}})
+	if !errors.Is(err, errUnknownSlice) {
+		t.Errorf("link unknown slice: got %v", err)
+	}
+
+	_, err = g.apply(audioGroupPayload{Action: "bogus"})
+	if !errors.Is(err, errUnknownAudioAction) {
+		t.Errorf("bogus action: got %v", err)
+	}
+}
+
+func TestAudioGroups_UnlinkDissolvesPair(t *testing.T) {
+	t.Parallel()
+
+	g := newTestAudioGroups(t, "0", "1", "2")
+
+	_, _ = g.apply(audioGroupPayload{Action: audioGroupLink, Slices: []int{0, 1, 2}})
+	_, _ = g.apply(audioGroupPayload{Action: audioGroupUnlink, Slice: 2})
+
+	if snap := g.snapshot(); len(snap.Groups) != 1 || !slices.Equal(snap.Groups[0], []int{0, 1}) {
+		t.Errorf("after unlinking one of three: got %v", snap.Groups)
+	}
+
+	_, _ = g.apply(audioGroupPayload{Action: audioGroupUnlink, Slice: 1})
+
+	if snap := g.snapshot(); len(snap.Groups) != 0 {
+		t.Errorf("a group of one should dissolve, got %v", snap.Groups)
+	}
+
+	// Slice 0 mutes alone now.
+	cmds, _ := g.apply(audioGroupPayload{Action: audioGroupMute, Slice: 0})
+	if want := []string{"slice set 0 audio_mute=1"}; !slices.Equal(cmds, want) {
+		t.Errorf("mute: got %v, want %v", cmds, want)
+	}
+}
+
+func TestHandleAudioGroup_SharedBySessionsOnRadio(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{sessions: make(map[string]*clientSession)}
+
+	session := func(id, addr string) *clientSession {
+		rc := &radioConn{addr: addr, audio: srv.radioAudio(addr)}
+		cs := &clientSession{id: id, srv: srv, send: make(chan message, 4), radio: rc}
+		srv.addSession(cs)
+
+		return cs
+	}
+
+	a := session("a", "192.0.2.10:4992")
+	b := session("b", "192.0.2.10:4992")
+	other := session("c", "192.0.2.11:4992")
+
+	a.radio.audio.observe("slice 0 in_use=1 audio_mute=0")
+	a.handleAudioGroup(json.RawMessage(`{"action":"solo","slice":0}`))
+
+	for _, cs := range []*clientSession{a, b} {
+		m := nextNotice(t, cs)
+
+		var p audioGroupsPayload
+		if err := json.Unmarshal(m.Payload, &p); m.Type != typeAudioGroups || err != nil || p.Solo == nil || *p.Solo != 0 {
+			t.Errorf("session %s got %s %s", cs.id, m.Type, m.Payload)
+		}
+	}
+
+	if len(other.send) != 0 {
+		t.Error("a session on another radio was sent the groups")
+	}
+
+	if b.radio.audio.snapshot().Solo == nil {
+		t.Error("the other session on the radio doesn't share the solo")
+	}
+}
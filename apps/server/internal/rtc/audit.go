@@ -0,0 +1,88 @@
+package rtc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/audit"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+)
+
+// auditSources names the bridge features behind commands sent on a
+// session's behalf, by their sequence numbers. Pings and the keepalive are
+// housekeeping, left out of the trail.
+var auditSources = map[uint64]string{
+	internalPTTSequence:        "ptt",
+	internalCWSequence:         "cw",
+	internalRigctlSequence:     "rigctl",
+	internalSandboxSequence:    "sandbox",
+	internalAudioGroupSequence: "audio-group",
+	internalClientSequence:     "gui-client",
+	internalStreamSequence:     "streams",
+	internalDisconnectSequence: "drain",
+	internalCleanupSequence:    "cleanup",
+	internalMuteSequence:       "tx-mute",
+	internalUDPPortSequence:    "udp-port",
+	internalPingSequence:       "",
+	internalKeepaliveSequence:  "",
+}
+
+// auditTap stamps a radio connection's commands with its session's identity
+// for the audit trail: its address is the peer's, which headers can't forge.
+type auditTap struct {
+	log      *audit.Log
+	user     string
+	clientIP string
+	session  string
+}
+
+// auditRadio starts recording the commands rc sends for cs, if there is an
+// audit trail.
+func (cs *clientSession) auditRadio(rc *radioConn) {
+	if cs.srv.audit == nil {
+		return
+	}
+
+	rc.audit.Store(&auditTap{log: cs.srv.audit, user: cs.user, clientIP: cs.peerIP, session: cs.id})
+}
+
+// record adds each command line in data, as written to rc, to the trail.
+func (a *auditTap) record(rc *radioConn, data []byte) {
+	now := time.Now()
+
+	for line := range strings.SplitSeq(strings.TrimRight(string(data), "\r\n"), "\n") {
+		seq, body, ok := guard.SplitCommand(line)
+		if !ok {
+			continue
+		}
+
+		source := "client"
+
+		if n, err := strconv.ParseUint(seq, 10, 32); err == nil {
+			if name, internal := auditSources[n]; internal {
+				if name == "" {
+					continue
+				}
+
+				source = name
+			}
+		}
+
+		a.log.Record(audit.Entry{
+			Time: now, User: a.user, ClientIP: a.clientIP, Session: a.session,
+			Handle: rc.handleHex, Radio: rc.addr, Source: source, Command: body,
+		})
+	}
+}
+
+// userFor is who a proxy in front of the bridge says r's client
+// authenticated as, if the bridge is told to trust one.
+func (s *Server) userFor(r *http.Request) string {
+	if s.userHeader == "" {
+		return ""
+	}
+
+	return strings.TrimSpace(r.Header.Get(s.userHeader))
+}
@@ -0,0 +1,62 @@
+package rtc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/audit"
+)
+
+func TestAuditTap_Record(t *testing.T) {
+	t.Parallel()
+
+	l, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = l.Close() })
+
+	s := &Server{audit: l, userHeader: "Remote-User"}
+
+	r := httptest.NewRequest(http.MethodGet, "/ws/signal", nil)
+	r.Header.Set("Remote-User", " w1aw ")
+
+	cs := &clientSession{id: "s1", srv: s, clientIP: "198.51.100.1", peerIP: "203.0.113.9", user: s.userFor(r)}
+	rc := &radioConn{addr: "10.0.0.5:4992", handleHex: "1234ABCD"}
+	cs.auditRadio(rc)
+
+	rc.tcpWritten([]byte(fmt.Sprintf("C7|slice tune 0 14.074\nC%d|ping\nC%d|xmit 0\nC%d|xmit 0\nC%d|client udpport 4993\n",
+		internalPingSequence, internalPTTSequence, internalMuteSequence, internalUDPPortSequence)))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_ = l.Run(ctx)
+
+	got, err := l.Query(audit.Query{})
+	if err != nil || len(got) != 4 {
+		t.Fatalf("entries = %+v, %v", got, err)
+	}
+
+	if e := got[3]; e.User != "w1aw" || e.ClientIP != "203.0.113.9" || e.Session != "s1" || e.Handle != "1234ABCD" ||
+		e.Source != "client" || e.Command != "slice tune 0 14.074" {
+		t.Errorf("client command = %+v", e)
+	}
+
+	if e := got[2]; e.Source != "ptt" || e.Command != "xmit 0" {
+		t.Errorf("PTT release = %+v", e)
+	}
+
+	if e := got[1]; e.Source != "tx-mute" || e.Command != "xmit 0" {
+		t.Errorf("TX mute unkey = %+v", e)
+	}
+
+	if e := got[0]; e.Source != "udp-port" || e.Command != "client udpport 4993" {
+		t.Errorf("UDP port = %+v", e)
+	}
+}
@@ -0,0 +1,206 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// Streams the bandwidth budget may reduce.
+const (
+	BandwidthFFT       = "fft"
+	BandwidthWaterfall = "waterfall"
+	BandwidthIQ        = "iq"
+)
+
+const (
+	// budgetWindow is how often a budget re-measures its link.
+	budgetWindow = 500 * time.Millisecond
+	// budgetHighWater is a "udp" channel backlog that, still growing, marks
+	// the link congested; below budgetLowWater it is calm.
+	budgetHighWater = 256 << 10
+	budgetLowWater  = 32 << 10
+	// budgetRecover is how many calm windows restore one step.
+	budgetRecover = 4
+	// budgetMaxLevel is the deepest a stream is reduced: one in 2^level
+	// frames sent.
+	budgetMaxLevel = 3
+	// VITA-49 class codes of the radio's panadapter and waterfall.
+	vitaFFTClass       = 0x8003
+	vitaWaterfallClass = 0x8004
+)
+
+// bandwidthBudget keeps a session's audio clear of the rest of its radio
+// data. It watches how fast the "udp" channel drains and whether the client
+// reports audio loss, and while the link is congested sends fewer panadapter
+// frames, waterfall lines and IQ packets, reducing the streams in shed order
+// one step at a time and restoring them in reverse once the link is calm.
+type bandwidthBudget struct {
+	shed []string
+	// loss is whether the client has reported RX audio loss since a time.
+	loss func(since time.Time) bool
+
+	mu          sync.Mutex
+	levels      map[string]int
+	windowStart time.Time
+	sent        uint64 // bytes allowed this window
+	buffered    uint64 // the channel's backlog at the window's start
+	throughput  float64
+	calm        int
+	iqCount     uint32
+}
+
+// BandwidthStats reports a session's budget.
+type BandwidthStats struct {
+	// Throughput is how fast the "udp" channel drained over the last
+	// window, in bits per second.
+	Throughput float64 `json:"throughput"`
+	// Decimation is, for each stream being reduced, N where one in N of
+	// its frames is sent.
+	Decimation map[string]int `json:"decimation,omitempty"`
+}
+
+// newBandwidthBudget returns nil, meaning unbudgeted, when shed is empty.
+func newBandwidthBudget(shed []string, loss func(since time.Time) bool) *bandwidthBudget {
+	if len(shed) == 0 {
+		return nil
+	}
+
+	return &bandwidthBudget{shed: shed, loss: loss, levels: make(map[string]int)}
+}
+
+// allow reports whether the packet v, n bytes, may be sent on a channel
+// with buffered bytes waiting.
+func (b *bandwidthBudget) allow(v vitaView, n int, buffered uint64) bool {
+	if b == nil {
+		return true
+	}
+
+	return b.allowAt(v, n, buffered, time.Now())
+}
+
+func (b *bandwidthBudget) allowAt(v vitaView, n int, buffered uint64, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.windowStart); elapsed >= budgetWindow {
+		if !b.windowStart.IsZero() {
+			b.measure(buffered, elapsed)
+		}
+
+		b.windowStart, b.sent, b.buffered = now, 0, buffered
+	}
+
+	keep := true
+
+	switch {
+	case v.ClassCode == vitaFFTClass && len(v.Payload) >= 12:
+		keep = decimated(binary.BigEndian.Uint32(v.Payload[8:]), b.levels[BandwidthFFT])
+	case v.ClassCode == vitaWaterfallClass && len(v.Payload) >= 28:
+		keep = decimated(binary.BigEndian.Uint32(v.Payload[24:]), b.levels[BandwidthWaterfall])
+	case iqSampleRate(v.ClassCode) != 0:
+		b.iqCount++
+		keep = decimated(b.iqCount, b.levels[BandwidthIQ])
+	}
+
+	if keep {
+		b.sent += uint64(n) //nolint:gosec // n is a packet length
+	}
+
+	return keep
+}
+
+// decimated is whether frame is one of those sent at level. Every packet of
+// a frame carries its index, so a frame is sent whole or not at all.
+func decimated(frame uint32, level int) bool {
+	return frame%(1<<level) == 0
+}
+
+// measure closes a window, judging the link from what drained and what the
+// client reported, and steps the streams; b.mu is held.
+func (b *bandwidthBudget) measure(buffered uint64, elapsed time.Duration) {
+	drained := float64(b.sent) - (float64(buffered) - float64(b.buffered))
+	b.throughput = max(drained, 0) * 8 / elapsed.Seconds()
+
+	congested := buffered > budgetHighWater && buffered > b.buffered
+	if b.loss != nil && b.loss(b.windowStart) {
+		congested = true
+	}
+
+	switch {
+	case congested:
+		b.calm = 0
+
+		for _, s := range b.shed {
+			if b.levels[s] < budgetMaxLevel {
+				b.levels[s]++
+
+				return
+			}
+		}
+	case buffered < budgetLowWater:
+		b.calm++
+		if b.calm < budgetRecover {
+			return
+		}
+
+		b.calm = 0
+
+		for i := len(b.shed) - 1; i >= 0; i-- {
+			if s := b.shed[i]; b.levels[s] > 0 {
+				b.levels[s]--
+
+				return
+			}
+		}
+	}
+}
+
+func (b *bandwidthBudget) stats() *BandwidthStats {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := &BandwidthStats{Throughput: b.throughput}
+
+	for s, level := range b.levels {
+		if level > 0 {
+			if st.Decimation == nil {
+				st.Decimation = make(map[string]int)
+			}
+
+			st.Decimation[s] = 1 << level
+		}
+	}
+
+	return st
+}
+
+// lossSince is whether the listener on ssrc has reported loss since t.
+func (t *opusTrack) lossSince(ssrc webrtc.SSRC, since time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range t.bindings {
+		if b.ssrc == ssrc && b.lossAt.After(since) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// audioLossSince is whether the session's client has reported RX audio
+// loss since t.
+func (cs *clientSession) audioLossSince(since time.Time) bool {
+	cs.mu.Lock()
+	track, ssrc := cs.audioTrack, cs.audioSSRC
+	cs.mu.Unlock()
+
+	return track != nil && track.lossSince(ssrc, since)
+}
@@ -0,0 +1,109 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func fftPacket(frame uint32) vitaView {
+	payload := make([]byte, 12)
+	binary.BigEndian.PutUint32(payload[8:], frame)
+
+	return vitaView{ClassCode: vitaFFTClass, Payload: payload}
+}
+
+func waterfallPacket(timecode uint32) vitaView {
+	payload := make([]byte, 28)
+	binary.BigEndian.PutUint32(payload[24:], timecode)
+
+	return vitaView{ClassCode: vitaWaterfallClass, Payload: payload}
+}
+
+func TestBandwidthBudget(t *testing.T) {
+	t.Parallel()
+
+	b := newBandwidthBudget([]string{BandwidthWaterfall, BandwidthFFT}, nil)
+	now := time.Now()
+
+	// window ends a window with the channel's backlog at buffered.
+	window := func(buffered uint64) {
+		now = now.Add(budgetWindow)
+		b.allowAt(vitaView{ClassCode: 0x8002}, 100, buffered, now)
+	}
+
+	window(0)
+
+	// A growing backlog thins the waterfall all the way before the FFT.
+	backlog := uint64(budgetHighWater)
+	for range budgetMaxLevel + 1 {
+		backlog += 100_000
+		window(backlog)
+	}
+
+	if b.levels[BandwidthWaterfall] != budgetMaxLevel || b.levels[BandwidthFFT] != 1 {
+		t.Fatalf("levels %v", b.levels)
+	}
+
+	// Every packet of a frame gets the same answer.
+	if !b.allowAt(fftPacket(4), 1000, backlog, now) || !b.allowAt(fftPacket(4), 1000, backlog, now) {
+		t.Error("even FFT frame dropped")
+	}
+
+	if b.allowAt(fftPacket(5), 1000, backlog, now) {
+		t.Error("odd FFT frame sent at half rate")
+	}
+
+	if b.allowAt(waterfallPacket(4), 1000, backlog, now) || !b.allowAt(waterfallPacket(16), 1000, backlog, now) {
+		t.Error("waterfall not at one line in eight")
+	}
+
+	if !b.allowAt(vitaView{ClassCode: 0x8002}, 100, backlog, now) {
+		t.Error("meters dropped")
+	}
+
+	st := b.stats()
+	if st.Decimation[BandwidthWaterfall] != 8 || st.Decimation[BandwidthFFT] != 2 {
+		t.Errorf("stats %+v", st)
+	}
+
+	// Calm restores the most important stream first.
+	for range budgetRecover + 1 {
+		window(0)
+	}
+
+	if b.levels[BandwidthFFT] != 0 || b.levels[BandwidthWaterfall] != budgetMaxLevel {
+		t.Errorf("after calm, levels %v", b.levels)
+	}
+}
+
+func TestBandwidthBudget_AudioLoss(t *testing.T) {
+	t.Parallel()
+
+	lossy := true
+	b := newBandwidthBudget([]string{BandwidthIQ}, func(time.Time) bool { return lossy })
+	now := time.Now()
+
+	b.allowAt(vitaView{}, 100, 0, now)
+	b.allowAt(vitaView{}, 100, 0, now.Add(budgetWindow))
+
+	if b.levels[BandwidthIQ] != 1 {
+		t.Fatalf("audio loss left IQ at level %d", b.levels[BandwidthIQ])
+	}
+
+	sent := 0
+
+	for range 8 {
+		if b.allowAt(vitaView{ClassCode: 0x02E4}, 100, 0, now.Add(budgetWindow)) {
+			sent++
+		}
+	}
+
+	if sent != 4 {
+		t.Errorf("%d of 8 IQ packets sent at half rate", sent)
+	}
+
+	if newBandwidthBudget(nil, nil) != nil || !(*bandwidthBudget)(nil).allow(fftPacket(1), 1, 0) {
+		t.Error("empty budget is not off")
+	}
+}
@@ -0,0 +1,66 @@
+package rtc
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/captions"
+	"github.com/pion/webrtc/v4"
+)
+
+// openCaptions starts transcribing RX audio for a "captions" data channel.
+// The label names the slice being captioned; it is copied into every caption
+// so the client can place it. The bridge only receives the radio's mixed RX
+// stream, so clients solo the slice (see audioGroup) to caption it alone.
+func (cs *clientSession) openCaptions(dc *webrtc.DataChannel) {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if cs.srv.captions == nil || rc == nil {
+		msg := "captions are not configured on this bridge"
+		if rc == nil {
+			msg = "no radio connection"
+		}
+
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "CAPTIONS_UNAVAILABLE", Message: msg}))
+		_ = dc.Close()
+
+		return
+	}
+
+	stream := cs.srv.captions.NewStream(dc.Label(), func(c captions.Caption) {
+		raw, err := json.Marshal(c)
+		if err != nil {
+			return
+		}
+
+		_ = dc.SendText(string(raw))
+	})
+
+	rc.setCaptions(stream)
+	dc.OnClose(func() { rc.clearCaptions(stream) })
+
+	log.Printf("[rtc] session %s: captioning slice %q", cs.id, dc.Label())
+}
+
+// setCaptions replaces the caption stream fed from RX audio, closing the old
+// one. A session has at most one.
+func (rc *radioConn) setCaptions(s *captions.Stream) {
+	if old := rc.captions.Swap(s); old != nil {
+		go old.Close()
+	}
+}
+
+// clearCaptions stops s if it is still the current stream.
+func (rc *radioConn) clearCaptions(s *captions.Stream) {
+	if rc.captions.CompareAndSwap(s, nil) {
+		go s.Close()
+	}
+}
+
+func (rc *radioConn) tapCaptions(payload []byte) {
+	if s := rc.captions.Load(); s != nil {
+		s.WriteOpus(payload, opusDuration(payload))
+	}
+}
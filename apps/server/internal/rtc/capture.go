@@ -0,0 +1,79 @@
+package rtc
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/tz"
+)
+
+// apiCapture appends raw TCP API traffic to a file for debugging. The file is
+// opened lazily the first time capture is active and a line is written.
+type apiCapture struct {
+	path string
+
+	mu sync.Mutex
+	on bool
+	f  *os.File
+}
+
+func newAPICapture(path string) *apiCapture {
+	return &apiCapture{path: path, on: path != ""}
+}
+
+func (c *apiCapture) enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.on
+}
+
+func (c *apiCapture) setEnabled(on bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" {
+		return false
+	}
+
+	c.on = on
+	if !on && c.f != nil {
+		_ = c.f.Close()
+		c.f = nil
+	}
+
+	return true
+}
+
+// record writes one line per API line. key is the connection's radio key,
+// dir is ">>" for client→radio and "<<" for radio→client.
+func (c *apiCapture) record(key, dir, data string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.on {
+		return
+	}
+
+	if c.f == nil {
+		f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // operator-configured path
+		if err != nil {
+			log.Printf("[rtc] api capture disabled: %v", err)
+
+			c.on = false
+
+			return
+		}
+
+		c.f = f
+	}
+
+	ts := tz.Format(time.Now())
+	for line := range strings.SplitSeq(strings.TrimRight(data, "\r\n"), "\n") {
+		_, _ = fmt.Fprintf(c.f, "%s %s %s %s\n", ts, key, dir, line)
+	}
+}
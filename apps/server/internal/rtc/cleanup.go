@@ -0,0 +1,116 @@
+package rtc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// internalCleanupSequence tags the commands that clear up after a session:
+// removing its streams when it ends uncleanly, and what a previous run's
+// handles left behind.
+const internalCleanupSequence = 2147483637
+
+// noteOwnedStream keeps track of the streams the radio reports for our
+// handle, of any type, so they can be removed should the session end
+// uncleanly.
+func (rc *radioConn) noteOwnedStream(stream audioStream) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	switch {
+	case stream.Removed:
+		delete(rc.owned, stream.StreamID)
+	case stream.ClientHandle == rc.handleU32:
+		if rc.owned == nil {
+			rc.owned = make(map[uint32]bool)
+		}
+
+		rc.owned[stream.StreamID] = true
+	}
+}
+
+// ownedStreams are the streams the radio reports for our handle, in order.
+func (rc *radioConn) ownedStreams() []uint32 {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(rc.owned))
+}
+
+// cleanup undoes on the radio what a session that ended uncleanly left: it
+// removes the session's streams and lets go of its GUI client binding and
+// handle, rather than leave them to the radio's TCP timeout.
+func (rc *radioConn) cleanup() {
+	for _, id := range rc.ownedStreams() {
+		err := rc.writeTCPString(fmt.Sprintf("C%d|stream remove 0x%08X\n", internalCleanupSequence, id))
+		if err != nil {
+			log.Printf("[rtc] cleanup %s: %v", rc.key, err)
+
+			break
+		}
+	}
+
+	// The radio has no unbind; a binding ends with the handle.
+	rc.mu.Lock()
+	bound := rc.boundClient
+	rc.boundClient = ""
+	rc.mu.Unlock()
+
+	if bound != "" {
+		log.Printf("[rtc] cleanup %s: releasing GUI client %s", rc.key, bound)
+	}
+
+	rc.disconnect()
+}
+
+// sweepStale removes what a saved session's handle left on its radio, should
+// the run that saved it have ended without letting go: its streams, then the
+// handle itself. rc is a connection of our own to the same radio.
+func (rc *radioConn) sweepStale(st savedSession) {
+	handle := strings.ToUpper(strings.TrimPrefix(st.Handle, "0x"))
+	if handle == "" || handle == rc.handleHex {
+		return
+	}
+
+	lines := make([]string, 0, len(st.Streams)+1)
+	for _, id := range st.Streams {
+		lines = append(lines, fmt.Sprintf("C%d|stream remove %s\n", internalCleanupSequence, id))
+	}
+
+	lines = append(lines, fmt.Sprintf("C%d|client disconnect 0x%s\n", internalCleanupSequence, handle))
+
+	for _, line := range lines {
+		err := rc.writeTCPString(line)
+		if err != nil {
+			log.Printf("[rtc] stale cleanup %s: %v", rc.key, err)
+
+			return
+		}
+	}
+
+	log.Printf("[rtc] removed %d stale stream(s) and handle 0x%s from %s", len(st.Streams), handle, st.Radio)
+}
+
+// sweepSaved dials a saved session's radio only to sweep what it left, for
+// a session too old to resume.
+func (s *Server) sweepSaved(st savedSession) {
+	w := &warmRadio{}
+
+	rc, err := newRadioConn(context.Background(), w, st.Radio, s.capture, nil, nil, w.writeFailed)
+	if err != nil {
+		log.Printf("[rtc] stale cleanup: %v", err)
+
+		return
+	}
+
+	rc.sweepStale(st)
+	rc.disconnect()
+}
+
+func isInternalCleanupReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalCleanupSequence))
+}
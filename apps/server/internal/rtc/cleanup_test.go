@@ -0,0 +1,74 @@
+package rtc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc/rtctest"
+)
+
+func TestRadioConn_Cleanup(t *testing.T) {
+	t.Parallel()
+
+	addr, got := rtctest.FakeRadio(t)
+
+	rc, err := newRadioConn(t.Context(), make(lineSink, 16), addr, nil, nil, nil, func(error) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc.noteOwnedStream(audioStream{StreamID: 0x04000002, ClientHandle: 0x1234ABCD})
+	rc.noteOwnedStream(audioStream{StreamID: 0x20000000, ClientHandle: 0x1234ABCD})
+	rc.noteOwnedStream(audioStream{StreamID: 0x04000009, ClientHandle: 0x0BADF00D})
+	rc.noteOwnedStream(audioStream{StreamID: 0x20000000, Removed: true})
+
+	rc.cleanup()
+
+	for _, want := range []string{
+		"C2147483637|stream remove 0x04000002",
+		"C2147483645|client disconnect 0x1234ABCD",
+	} {
+		if l := nextLine(t, got); l != want {
+			t.Errorf("radio got %q, want %q", l, want)
+		}
+	}
+}
+
+func TestRestoreSessions_StaleCleanup(t *testing.T) {
+	t.Parallel()
+
+	addr, got := rtctest.FakeRadio(t)
+	file := filepath.Join(t.TempDir(), "sessions.json")
+	saved := []savedSession{{
+		Token: "t", Radio: addr, Handle: "0x00C0FFEE", Streams: []string{"0x04000001"},
+		SavedAt: time.Now().Add(-time.Hour),
+	}}
+
+	b, _ := json.Marshal(saved)
+
+	err := os.WriteFile(file, b, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{restored: make(map[string]*restoredSession), stateFile: file, resumeWindow: time.Minute, staleCleanup: true}
+
+	err = srv.RestoreSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Too old to resume, its leftovers are still swept.
+	for _, want := range []string{
+		"C2147483637|stream remove 0x04000001",
+		"C2147483637|client disconnect 0x00C0FFEE",
+		"C2147483645|client disconnect 0x1234ABCD",
+	} {
+		if l := nextLine(t, got); l != want {
+			t.Errorf("radio got %q, want %q", l, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package rtc
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/radiocheck"
+)
+
+// ConnectCheckPath serves a dry run of everything a session needs, for a
+// client to show why it cannot connect:
+//
+//	/api/connect-check?host=<host[:port]>[&udpPort=<n>][&timeout=<duration>]
+//
+// It dials the radio and reads its handshake, probes radio UDP both ways,
+// and asks the bridge's STUN servers how its NAT maps UDP. The port
+// defaults to the radio's API port.
+const ConnectCheckPath = "/api/connect-check"
+
+const (
+	connectCheckTimeout    = 3 * time.Second
+	connectCheckMaxTimeout = 30 * time.Second
+)
+
+// ServeConnectCheck handles GET ConnectCheckPath. It is refused for a radio
+// outside the allowlist, as a session would be.
+func (s *Server) ServeConnectCheck(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	radio := q.Get("host")
+	if radio == "" {
+		writeHTTPError(w, http.StatusBadRequest, "BAD_RADIO_ADDR", "host is required")
+
+		return
+	}
+
+	if _, _, err := net.SplitHostPort(radio); err != nil {
+		radio = net.JoinHostPort(canonicalHost(radio), strconv.Itoa(radiocheck.DefaultAPIPort))
+	}
+
+	if !s.radioAllowed(radio) {
+		writeHTTPError(w, http.StatusForbidden, "RADIO_NOT_ALLOWED", "this bridge does not serve "+radio)
+
+		return
+	}
+
+	udpPort, _ := strconv.Atoi(q.Get("udpPort"))
+
+	timeout := connectCheckTimeout
+	if d, err := time.ParseDuration(q.Get("timeout")); err == nil && d > 0 {
+		timeout = min(d, connectCheckMaxTimeout)
+	}
+
+	var stunURLs []string
+	for _, srv := range s.iceServers {
+		stunURLs = append(stunURLs, srv.URLs...)
+	}
+
+	rep := radiocheck.Connect(r.Context(), radiocheck.ConnectOptions{
+		Radio: radio, UDPPort: udpPort, STUN: stunURLs, Timeout: timeout,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(rep)
+}
@@ -0,0 +1,55 @@
+package rtc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/radiocheck"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc/rtctest"
+)
+
+func TestServeConnectCheck(t *testing.T) {
+	t.Parallel()
+
+	addr, _ := rtctest.FakeRadio(t)
+	s := &Server{allowedRadios: allowedRadios([]string{addr})}
+
+	for _, tc := range []struct {
+		query   string
+		status  int
+		message string
+	}{
+		{"", http.StatusBadRequest, "host is required"},
+		{"?host=192.0.2.1", http.StatusForbidden, "this bridge does not serve 192.0.2.1:4992"},
+		{"?host=" + addr + "&timeout=200ms", http.StatusOK, ""},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeConnectCheck(w, httptest.NewRequest(http.MethodGet, ConnectCheckPath+tc.query, nil))
+
+		if w.Code != tc.status {
+			t.Errorf("%q: status %d, want %d", tc.query, w.Code, tc.status)
+
+			continue
+		}
+
+		if tc.status != http.StatusOK {
+			var e errorPayload
+
+			_ = json.Unmarshal(w.Body.Bytes(), &e)
+			if e.Message != tc.message {
+				t.Errorf("%q: %q", tc.query, e.Message)
+			}
+
+			continue
+		}
+
+		var rep radiocheck.ConnectReport
+
+		_ = json.Unmarshal(w.Body.Bytes(), &rep)
+		if rep.Radio != addr || rep.Handshake.Handle != rtctest.Handle {
+			t.Errorf("report = %+v", rep)
+		}
+	}
+}
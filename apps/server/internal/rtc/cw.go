@@ -0,0 +1,231 @@
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// internalCWSequence tags key commands sent for a "cw" data channel so their
+// replies are consumed instead of reaching the browser.
+const internalCWSequence = 2147483642
+
+const (
+	// DefaultCWBuffer is how long key events are held before they reach the
+	// radio, so that network jitter shorter than this does not change the
+	// length of elements and spaces.
+	DefaultCWBuffer = 50 * time.Millisecond
+	// cwResync is the pause after which the client's clock is matched to
+	// ours again, so drift between the two does not build up over a QSO.
+	cwResync = 2 * time.Second
+	// cwMaxKeyDown releases a key held this long, in case the client hung
+	// or lost its key-up.
+	cwMaxKeyDown = 10 * time.Second
+	// cwQueue is how many key events may wait for their time.
+	cwQueue = 256
+)
+
+var (
+	errCWSandbox  = errors.New("keying is disabled in the sandbox")
+	errCWRejected = errors.New("keying rejected by the command guard")
+)
+
+// cwEvent is one message on a "cw" data channel.
+type cwEvent struct {
+	// Down is the key's new state.
+	Down bool `json:"down"`
+	// T is when the key changed, in milliseconds on the client's clock,
+	// e.g. performance.now().
+	T float64 `json:"t"`
+}
+
+// cwKey is a key change due at a time on our clock.
+type cwKey struct {
+	down  bool
+	stamp uint16
+	at    time.Time
+}
+
+// cwKeyer replays a client's key changes to the radio with the spacing they
+// had on the client. Each change is due at the client's timestamp mapped onto
+// our clock, plus the buffer. The mapping is taken from the change that
+// arrived quickest since the last pause, so a change delayed in transit by
+// less than the buffer still reaches the radio on time.
+type cwKeyer struct {
+	buffer time.Duration
+	key    func(down bool, stamp uint16) error
+	keys   chan cwKey
+
+	mu sync.Mutex
+	// origin is the client's clock zero on ours.
+	origin  time.Time
+	arrived time.Time
+	last    time.Time
+}
+
+func newCWKeyer(buffer time.Duration, key func(down bool, stamp uint16) error) *cwKeyer {
+	return &cwKeyer{buffer: buffer, key: key, keys: make(chan cwKey, cwQueue)}
+}
+
+// event schedules a key change that arrived at now.
+func (k *cwKeyer) event(ev cwEvent, now time.Time) {
+	client := time.Duration(ev.T * float64(time.Millisecond))
+
+	k.mu.Lock()
+
+	origin := now.Add(-client)
+	if k.arrived.IsZero() || now.Sub(k.arrived) > cwResync || origin.Before(k.origin) {
+		k.origin = origin
+	}
+
+	k.arrived = now
+
+	// The origin is never later than this change's, so it is due within
+	// the buffer; a client clock stepping back must not reorder changes.
+	at := k.origin.Add(client + k.buffer)
+	if at.Before(k.last) {
+		at = k.last
+	}
+
+	k.last = at
+	k.mu.Unlock()
+
+	select {
+	case k.keys <- cwKey{down: ev.Down, stamp: uint16(int64(ev.T)), at: at}: //nolint:gosec // the radio takes 16 bits
+	default:
+		log.Printf("[rtc] cw: key events backed up; dropped one")
+	}
+}
+
+// run keys the radio as changes fall due, until ctx is done. The key is
+// released on the way out, and when held longer than cwMaxKeyDown.
+func (k *cwKeyer) run(ctx context.Context) {
+	var (
+		down    bool
+		release <-chan time.Time
+	)
+
+	up := func() {
+		if down {
+			_ = k.key(false, uint16(time.Now().UnixMilli())) //nolint:gosec // the radio takes 16 bits
+			down, release = false, nil
+		}
+	}
+	defer up()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-release:
+			log.Printf("[rtc] cw: key held for %s; released", cwMaxKeyDown)
+			up()
+		case ev := <-k.keys:
+			t := time.NewTimer(time.Until(ev.at))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+
+				return
+			case <-t.C:
+			}
+
+			if ev.down == down {
+				continue
+			}
+
+			err := k.key(ev.down, ev.stamp)
+			if err != nil {
+				continue
+			}
+
+			down = ev.down
+			if down {
+				release = time.After(cwMaxKeyDown)
+			} else {
+				release = nil
+			}
+		}
+	}
+}
+
+// openCW keys the radio from a "cw" data channel carrying cwEvents.
+func (cs *clientSession) openCW(ctx context.Context, dc *webrtc.DataChannel) {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "CW_UNAVAILABLE", Message: "no radio connection"}))
+		_ = dc.Close()
+
+		return
+	}
+
+	var index int
+
+	k := newCWKeyer(cs.srv.cwBuffer, func(down bool, stamp uint16) error {
+		index++
+
+		err := cs.cwKey(rc, down, stamp, index)
+		if err != nil {
+			cs.trySend(mustEncode(typeError, errorPayload{Code: "CW_REJECTED", Message: err.Error()}))
+		}
+
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		var ev cwEvent
+
+		err := json.Unmarshal(msg.Data, &ev)
+		if err != nil {
+			return
+		}
+
+		k.event(ev, time.Now())
+	})
+	dc.OnClose(cancel)
+
+	go k.run(ctx)
+
+	log.Printf("[rtc] session %s: cw keying on %s (buffer %s)", cs.id, rc.key, cs.srv.cwBuffer)
+}
+
+// cwKey sends one key change. Key-down goes through the sandbox and the
+// guard like a typed command, though a rule asking for confirmation refuses
+// it, since a held element is worthless. Key-up always goes out.
+func (cs *clientSession) cwKey(rc *radioConn, down bool, stamp uint16, index int) error {
+	state := 0
+	if down {
+		state = 1
+	}
+
+	line := fmt.Sprintf("C%d|cw key %d time=0x%04X index=%d client_handle=0x%s\n",
+		internalCWSequence, state, stamp, index, rc.handleHex)
+
+	if down {
+		if cs.sandbox.isEnabled() {
+			return errCWSandbox
+		}
+
+		if !cs.allowImmediate(rc, line) {
+			return errCWRejected
+		}
+	}
+
+	return rc.writeTCPString(line)
+}
+
+func isInternalCWReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalCWSequence))
+}
@@ -0,0 +1,165 @@
+package rtc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCWKeyer_EvensOutJitter(t *testing.T) {
+	t.Parallel()
+
+	k := newCWKeyer(40*time.Millisecond, nil)
+	now := time.Now()
+
+	// A dit and a space of 60ms each, the second change 30ms late in
+	// transit and the third 5ms late.
+	k.event(cwEvent{Down: true, T: 1000}, now)
+	k.event(cwEvent{Down: false, T: 1060}, now.Add(90*time.Millisecond))
+	k.event(cwEvent{Down: true, T: 1120}, now.Add(125*time.Millisecond))
+
+	var at []time.Duration
+
+	for range 3 {
+		key := <-k.keys
+		at = append(at, key.at.Sub(now))
+	}
+
+	want := []time.Duration{40 * time.Millisecond, 100 * time.Millisecond, 160 * time.Millisecond}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Errorf("change %d due at %s, want %s", i, at[i], want[i])
+		}
+	}
+}
+
+func TestCWKeyer_Resync(t *testing.T) {
+	t.Parallel()
+
+	k := newCWKeyer(40*time.Millisecond, nil)
+	now := time.Now()
+
+	// The first change was slow; the second, quicker, moves the mapping.
+	k.event(cwEvent{Down: true, T: 0}, now.Add(30*time.Millisecond))
+	k.event(cwEvent{Down: false, T: 60}, now.Add(70*time.Millisecond))
+
+	if d := (<-k.keys).at.Sub(now); d != 70*time.Millisecond {
+		t.Errorf("first change due at %s", d)
+	}
+
+	if d := (<-k.keys).at.Sub(now); d != 110*time.Millisecond {
+		t.Errorf("second change due at %s", d)
+	}
+
+	// A client clock stepping back never reorders changes.
+	k.event(cwEvent{Down: true, T: 20}, now.Add(75*time.Millisecond))
+
+	if d := (<-k.keys).at.Sub(now); d != 110*time.Millisecond {
+		t.Errorf("change from the past due at %s", d)
+	}
+
+	// After a pause the mapping starts over, even if slower.
+	k.event(cwEvent{Down: false, T: 5000}, now.Add(5100*time.Millisecond))
+
+	if d := (<-k.keys).at.Sub(now); d != 5140*time.Millisecond {
+		t.Errorf("change after a pause due at %s", d)
+	}
+}
+
+func TestCWKeyer_ReleasesOnClose(t *testing.T) {
+	t.Parallel()
+
+	got := make(chan bool, 4)
+
+	k := newCWKeyer(time.Millisecond, func(down bool, _ uint16) error {
+		got <- down
+
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+
+	go func() {
+		k.run(ctx)
+		close(done)
+	}()
+
+	k.event(cwEvent{Down: true, T: 0}, time.Now())
+
+	if !<-got {
+		t.Fatal("key not down")
+	}
+
+	cancel()
+	<-done
+
+	select {
+	case down := <-got:
+		if down {
+			t.Error("keyed down on close")
+		}
+	default:
+		t.Error("key left down on close")
+	}
+}
+
+func TestCWKey(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{handleHex: "1234ABCD"}
+	rc.out = newTCPWriter(client, func([]byte) {}, nil)
+	t.Cleanup(rc.out.close)
+
+	lines := make(chan string, 4)
+
+	go func() {
+		rd := bufio.NewReader(radio)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			lines <- strings.TrimSpace(line)
+		}
+	}()
+
+	cs := &clientSession{srv: &Server{}}
+
+	err := cs.cwKey(rc, true, 0x1234, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := <-lines; l != "C2147483642|cw key 1 time=0x1234 index=7 client_handle=0x1234ABCD" {
+		t.Errorf("sent %q", l)
+	}
+
+	cs.setSandbox(true)
+
+	if !errors.Is(cs.cwKey(rc, true, 0, 8), errCWSandbox) {
+		t.Error("keyed down in the sandbox")
+	}
+
+	// Releasing the key is always allowed.
+	err = cs.cwKey(rc, false, 0, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := <-lines; !strings.HasPrefix(l, "C2147483642|cw key 0 ") {
+		t.Errorf("sent %q", l)
+	}
+
+	if !isInternalCWReply("R2147483642|0|") || isInternalCWReply("R12|0|") {
+		t.Error("isInternalCWReply")
+	}
+}
@@ -0,0 +1,212 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"sync"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/flac"
+	"github.com/pion/webrtc/v4"
+)
+
+// Codecs an "audio" channel delivers DAX audio in, named by its label.
+const (
+	// AudioPCM is the samples as the radio sends them, little-endian:
+	// bit-exact.
+	AudioPCM = "pcm"
+	// AudioFLAC is FLAC frames, at 24 bits for the radio's float samples.
+	AudioFLAC = "flac"
+)
+
+const (
+	// daxAudioClass is DAX audio as 32-bit float stereo, and
+	// daxReducedClass as 16-bit mono, both big-endian at 24 kHz.
+	daxAudioClass   = 0x03E3
+	daxReducedClass = 0x0123
+	daxAudioRate    = 24000
+
+	// audioHeaderLen is the header on each "audio" channel message.
+	audioHeaderLen = 12
+	// maxAudioBacklog is how much the channel may buffer before packets are
+	// dropped rather than stall the radio's UDP.
+	maxAudioBacklog = 1 << 20
+)
+
+// Sample formats in an "audio" message header.
+const (
+	audioFloat32 = 1
+	audioInt16   = 2
+	audioFLAC    = 3
+)
+
+// daxAudio sends a session's DAX audio on its "audio" channel, in place of
+// the raw packets on "udp". Each message is a 12-byte header (the stream
+// ID, the sample rate, both big-endian uint32, then the channel count and
+// sample format as a byte each, and two reserved) and the samples: PCM
+// interleaved, or one FLAC frame, the first a stream sends preceded by its
+// "fLaC" header.
+type daxAudio struct {
+	dc    *webrtc.DataChannel
+	codec string
+
+	mu       sync.Mutex
+	encoders map[uint32]*flac.Encoder
+}
+
+// daxAudioFormat is the channel count and sample width in bytes of a DAX
+// audio packet class, or false for any other class.
+func daxAudioFormat(class uint16) (int, int, bool) {
+	switch class {
+	case daxAudioClass:
+		return 2, 4, true
+	case daxReducedClass:
+		return 1, 2, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// openAudio moves the session's DAX audio to dc, in the codec its label
+// names. The channel should be reliable and ordered, or samples are lost.
+func (cs *clientSession) openAudio(dc *webrtc.DataChannel) {
+	rc := cs.currentRadio()
+	if rc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "AUDIO_UNAVAILABLE", Message: "no radio connection"}))
+		_ = dc.Close()
+
+		return
+	}
+
+	if codec := dc.Label(); codec != AudioPCM && codec != AudioFLAC {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_AUDIO_CODEC", Message: "audio codec must be pcm or flac"}))
+		_ = dc.Close()
+
+		return
+	}
+
+	if !dc.Ordered() || dc.MaxRetransmits() != nil || dc.MaxPacketLifeTime() != nil {
+		log.Printf("[rtc] session %s: audio channel is not reliable; samples may be lost", cs.id)
+	}
+
+	a := &daxAudio{dc: dc, codec: dc.Label(), encoders: make(map[uint32]*flac.Encoder)}
+
+	rc.dax.Store(a)
+	dc.OnClose(func() { rc.dax.CompareAndSwap(a, nil) })
+
+	log.Printf("[rtc] session %s: DAX audio as %s", cs.id, a.codec)
+}
+
+// add sends a DAX audio packet. It reports false when the channel is too
+// far behind and the packet was dropped.
+func (a *daxAudio) add(v vitaView) bool {
+	if a.dc.BufferedAmount() > maxAudioBacklog {
+		return false
+	}
+
+	if msg := a.encode(v); msg != nil {
+		_ = a.dc.Send(msg)
+	}
+
+	return true
+}
+
+// encode is the "audio" channel message for a DAX audio packet, or nil if
+// it holds no samples.
+func (a *daxAudio) encode(v vitaView) []byte {
+	channels, width, _ := daxAudioFormat(v.ClassCode)
+
+	n := len(v.Payload) / (channels * width) * channels * width
+	if n == 0 {
+		return nil
+	}
+
+	msg := make([]byte, audioHeaderLen, audioHeaderLen+n)
+	binary.BigEndian.PutUint32(msg, v.StreamID)
+	binary.BigEndian.PutUint32(msg[4:], daxAudioRate)
+	msg[8] = byte(channels)
+
+	if a.codec == AudioFLAC {
+		msg[9] = audioFLAC
+
+		frame, err := a.flacFrame(v.StreamID, channels, width, v.Payload[:n])
+		if err != nil {
+			return nil
+		}
+
+		return append(msg, frame...)
+	}
+
+	msg[9] = audioInt16
+	if width == 4 {
+		msg[9] = audioFloat32
+	}
+
+	// Each sample, big-endian from the radio, little-endian to the client.
+	for i := 0; i < n; i += width {
+		for j := width - 1; j >= 0; j-- {
+			msg = append(msg, v.Payload[i+j])
+		}
+	}
+
+	return msg
+}
+
+// flacFrame encodes a packet's samples as a FLAC frame, after the stream's
+// header when it is the first. Float samples become 24-bit integers.
+func (a *daxAudio) flacFrame(stream uint32, channels, width int, p []byte) ([]byte, error) {
+	bps := 16
+	if width == 4 {
+		bps = 24
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var head []byte
+
+	enc := a.encoders[stream]
+	if enc == nil {
+		var err error
+
+		enc, err = flac.NewEncoder(daxAudioRate, channels, bps)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // a fixed, valid format
+		}
+
+		a.encoders[stream] = enc
+		head = enc.Header()
+	}
+
+	frames := len(p) / (channels * width)
+	block := make([][]int32, channels)
+
+	for c := range block {
+		block[c] = make([]int32, frames)
+	}
+
+	for i := range frames {
+		for c := range channels {
+			off := (i*channels + c) * width
+			if width == 2 {
+				block[c][i] = int32(int16(binary.BigEndian.Uint16(p[off:]))) //nolint:gosec // two's complement
+			} else {
+				block[c][i] = toInt24(math.Float32frombits(binary.BigEndian.Uint32(p[off:])))
+			}
+		}
+	}
+
+	frame, err := enc.Frame(block)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // from the package's own checks
+	}
+
+	return append(head, frame...), nil
+}
+
+// toInt24 scales a float sample in [-1, 1] to a 24-bit integer, clipping.
+func toInt24(f float32) int32 {
+	const full = 1<<23 - 1
+
+	return int32(math.Round(math.Max(-full-1, math.Min(full, float64(f)*full))))
+}
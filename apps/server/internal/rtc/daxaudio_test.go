@@ -0,0 +1,61 @@
+package rtc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/flac"
+)
+
+func TestDAXAudio_EncodePCM(t *testing.T) {
+	t.Parallel()
+
+	a := &daxAudio{codec: AudioPCM}
+
+	p := binary.BigEndian.AppendUint32(nil, math.Float32bits(0.5))
+	p = binary.BigEndian.AppendUint32(p, math.Float32bits(-0.25))
+	p = append(p, 1, 2, 3) // a partial sample is left off
+
+	msg := a.encode(vitaView{ClassCode: daxAudioClass, StreamID: 0x04000008, Payload: p})
+
+	want := []byte{0x04, 0, 0, 0x08, 0, 0, 0x5D, 0xC0, 2, audioFloat32, 0, 0}
+	want = binary.LittleEndian.AppendUint32(want, math.Float32bits(0.5))
+	want = binary.LittleEndian.AppendUint32(want, math.Float32bits(-0.25))
+
+	if !bytes.Equal(msg, want) {
+		t.Errorf("message % x\nwant    % x", msg, want)
+	}
+
+	if a.encode(vitaView{ClassCode: daxReducedClass, Payload: []byte{1}}) != nil {
+		t.Error("a message without samples")
+	}
+}
+
+func TestDAXAudio_EncodeFLAC(t *testing.T) {
+	t.Parallel()
+
+	a := &daxAudio{codec: AudioFLAC, encoders: make(map[uint32]*flac.Encoder)}
+	v := vitaView{ClassCode: daxReducedClass, StreamID: 7, Payload: make([]byte, 256)}
+
+	first := a.encode(v)
+	if first[9] != audioFLAC || !bytes.HasPrefix(first[audioHeaderLen:], []byte("fLaC")) {
+		t.Fatalf("first message % x", first[:16])
+	}
+
+	// Later frames follow on without the header.
+	if next := a.encode(v); !bytes.HasPrefix(next[audioHeaderLen:], []byte{0xFF, 0xF9}) {
+		t.Errorf("second message % x", next[:16])
+	}
+}
+
+func TestToInt24(t *testing.T) {
+	t.Parallel()
+
+	for f, want := range map[float32]int32{0: 0, 1: 1<<23 - 1, -1: -(1<<23 - 1), 2: 1<<23 - 1, -2: -1 << 23} {
+		if got := toInt24(f); got != want {
+			t.Errorf("toInt24(%v) = %d, want %d", f, got, want)
+		}
+	}
+}
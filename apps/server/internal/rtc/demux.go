@@ -1,16 +1,24 @@
 package rtc
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/pion/webrtc/v4"
-	"github.com/pion/webrtc/v4/pkg/media"
 )
 
-func startUDPDemux(rc *radioConn, audioTrack *webrtc.TrackLocalStaticSample) {
+var errNoUDP = errors.New("no UDP connection to the radio")
+
+func startUDPDemux(rc *radioConn, audioTrack *opusTrack) {
 	rc.mu.RLock()
 	u := rc.udpConn
+	shared := rc.udpPort != nil
+	opt := rc.udpOptions
 	rc.mu.RUnlock()
 
 	if u == nil {
@@ -19,19 +27,34 @@ func startUDPDemux(rc *radioConn, audioTrack *webrtc.TrackLocalStaticSample) {
 		return
 	}
 
+	// A shared port's reader feeds the pipeline; it only needs workers.
+	if shared {
+		pl := newUDPPipeline(rc, audioTrack, opt)
+		if rc.pipeline.CompareAndSwap(nil, pl) {
+			pl.start()
+		}
+
+		return
+	}
+
 	go rc.demuxLoop(audioTrack)
 }
 
 // demuxLoop reads VITA packets from the radio's UDP socket until the socket is
-// closed, routing Opus audio (class 0x8005) to the WebRTC track and everything
-// else to the client's UDP data channel.
-func (rc *radioConn) demuxLoop(audioTrack *webrtc.TrackLocalStaticSample) {
-	defer rc.closeUDP()
-
+// closed, queueing them for the pipeline's workers to dispatch.
+func (rc *radioConn) demuxLoop(audioTrack *opusTrack) {
 	rc.mu.RLock()
 	raddr := rc.udpRaddr
+	opt := rc.udpOptions
 	rc.mu.RUnlock()
 
+	pl := newUDPPipeline(rc, audioTrack, opt)
+	rc.pipeline.Store(pl)
+	pl.start()
+
+	defer rc.closeUDP()
+	defer pl.stop()
+
 	buf := make([]byte, 64*1024)
 
 	for {
@@ -54,69 +77,239 @@ func (rc *radioConn) demuxLoop(audioTrack *webrtc.TrackLocalStaticSample) {
 			return
 		}
 
+		rc.udpIn.Add(uint64(n)) //nolint:gosec // n is never negative
+
 		// Accept packets from any source port the radio uses but only
 		// from the radio's IP.
 		if raddr != nil && !src.IP.Equal(raddr.IP) {
 			continue
 		}
 
-		p := buf[:n]
+		pl.enqueue(buf[:n])
+	}
+}
 
-		v, perr := parseVITA(p)
-		if perr != nil {
-			continue
-		}
+// dispatch routes a packet: Opus audio (class 0x8005) to the WebRTC track,
+// DAX audio to the "audio" channel while one is open, everything else to
+// the client's UDP data channel, and a copy of each to any WebSocket
+// tunnels. It reports false when p is not a VITA-49 packet.
+func (rc *radioConn) dispatch(p []byte, audioTrack *opusTrack) bool {
+	v, err := parseVITA(p)
+	if err != nil {
+		return false
+	}
 
-		if v.ClassCode == 0x8005 {
-			writeAudioSample(v, audioTrack)
+	rc.tunnels.publish(v)
 
-			continue
+	if v.ClassCode == vitaMeterClass {
+		rc.noteMeters(v, rc.clock.at(v, time.Now()))
+	}
+
+	if v.ClassCode == 0x8005 {
+		rc.lastAudio.Store(rc.clock.at(v, time.Now()).UnixNano())
+		audioTrack.write(v)
+		rc.tapAudio(v.Payload)
+
+		return true
+	}
+
+	if w := rc.waterfall.Load(); w != nil && v.ClassCode == vitaWaterfallClass {
+		w.add(v)
+
+		return true
+	}
+
+	if a := rc.dax.Load(); a != nil {
+		if _, _, ok := daxAudioFormat(v.ClassCode); ok {
+			if !a.add(v) {
+				rc.dropped.Add(1)
+			}
+
+			return true
+		}
+	}
+
+	if v.ClassCode == vitaFFTClass {
+		if p = rc.fft.add(p, v); p == nil {
+			return true
 		}
+	}
 
-		rc.forwardToDataChannel(p)
+	if rate := iqSampleRate(v.ClassCode); rate != 0 {
+		rc.tapIQ(v, rate)
 	}
+
+	rc.forwardToDataChannel(p, v)
+
+	return true
 }
 
-// closeUDP closes and clears the radio's UDP socket. Safe to call more than once.
-func (rc *radioConn) closeUDP() {
+// maxHeldStreams bounds how many streams' packets wait for the "udp"
+// channel.
+const maxHeldStreams = 64
+
+// holdUDP keeps p, the latest packet of its stream, for the "udp" channel
+// when it is attached: meters and status arrive from the moment the radio
+// has a port, which may well be before the client opens the channel.
+// Everything older is dropped, as it would be stale by then.
+func (rc *radioConn) holdUDP(p []byte, v vitaView) {
 	rc.mu.Lock()
-	if rc.udpConn != nil {
-		_ = rc.udpConn.Close()
-		rc.udpConn = nil
+	defer rc.mu.Unlock()
+
+	if rc.udpHeld == nil {
+		rc.udpHeld = make(map[uint32][]byte)
 	}
+
+	held, ok := rc.udpHeld[v.StreamID]
+	if !ok && len(rc.udpHeld) >= maxHeldStreams {
+		return
+	}
+
+	// The demux reuses p.
+	rc.udpHeld[v.StreamID] = append(held[:0], p...)
+}
+
+// attachUDP makes dc the session's "udp" channel, sending it what was held.
+func (rc *radioConn) attachUDP(dc *webrtc.DataChannel) {
+	rc.mu.Lock()
+	rc.udpDC = dc
+	held := rc.udpHeld
+	rc.udpHeld = nil
 	rc.mu.Unlock()
+
+	for _, p := range held {
+		_ = dc.Send(p)
+	}
 }
 
-// writeAudioSample decodes the Opus frame count from a VITA audio payload and
-// writes it to the WebRTC track. No-op when there is no track or payload.
-func writeAudioSample(v vitaView, audioTrack *webrtc.TrackLocalStaticSample) {
-	if audioTrack == nil || len(v.Payload) == 0 {
-		return
+// detachUDP goes back to holding packets if dc is still the "udp" channel.
+func (rc *radioConn) detachUDP(dc *webrtc.DataChannel) {
+	rc.mu.Lock()
+	if rc.udpDC == dc {
+		rc.udpDC = nil
+	}
+	rc.mu.Unlock()
+}
+
+// radioUDPAddr is the radio's UDP address given its TCP one: the next port,
+// as clients label their "udp" channel.
+func radioUDPAddr(tcpAddr string) (string, error) {
+	host, port, err := net.SplitHostPort(tcpAddr)
+	if err != nil {
+		return "", fmt.Errorf("radio address %q: %w", tcpAddr, err)
 	}
 
-	frames := opusFrameCount(v.Payload)
-	if frames <= 0 {
-		frames = 1
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("radio address %q: %w", tcpAddr, err)
 	}
 
-	_ = audioTrack.WriteSample(media.Sample{
-		Data:     append([]byte(nil), v.Payload...),
-		Duration: time.Duration(frames) * 10 * time.Millisecond,
-	})
+	return net.JoinHostPort(host, strconv.Itoa(n+1)), nil
+}
+
+// writeUDP sends a packet to the radio's UDP port, for whichever transport
+// the session's packets arrived on.
+func (rc *radioConn) writeUDP(p []byte) error {
+	rc.mu.RLock()
+	u := rc.udpConn
+	raddr := rc.udpRaddr
+	rc.mu.RUnlock()
+
+	if u == nil || raddr == nil {
+		return errNoUDP
+	}
+
+	n, err := u.WriteToUDP(p, raddr)
+	rc.udpOut.Add(uint64(n)) //nolint:gosec // n is never negative
+
+	if err != nil {
+		return fmt.Errorf("udp write: %w", err)
+	}
+
+	return nil
+}
+
+// sendTXAudio sends an Opus packet on the session's remote_audio_tx stream.
+// Without one the packet is dropped.
+func (rc *radioConn) sendTXAudio(opus []byte) error {
+	streamID, count, ok := rc.nextTXPacket()
+	if !ok {
+		return nil
+	}
+
+	return rc.writeUDP(buildTXOpusPacket(streamID, count, opus))
+}
+
+// closeUDP closes and clears the radio's UDP socket, or gives back its
+// shared port. Safe to call more than once.
+func (rc *radioConn) closeUDP() {
+	rc.mu.Lock()
+	u, up := rc.udpConn, rc.udpPort
+	rc.udpConn, rc.udpPort = nil, nil
+	rc.mu.Unlock()
+
+	rc.releaseUDP(u, up)
+}
+
+// opusDuration is the playout time of an Opus packet, or the radio's 10 ms
+// frame when it cannot be parsed.
+func opusDuration(b []byte) time.Duration {
+	samples := opusFrameSamples(b)
+	if samples <= 0 {
+		return 10 * time.Millisecond
+	}
+
+	return time.Duration(samples) * time.Second / opusClockRate
 }
 
 // forwardToDataChannel relays a raw packet to the client's UDP data channel in
-// chunks, applying backpressure when the channel's send buffer is full.
-func (rc *radioConn) forwardToDataChannel(p []byte) {
+// chunks, applying backpressure when the channel's send buffer is full, or
+// under a low latency profile dropping the packet instead. A
+// headless session's packets go to its udpSink instead.
+func (rc *radioConn) forwardToDataChannel(p []byte, v vitaView) {
 	rc.mu.RLock()
 	dc := rc.udpDC
+	if sdc := rc.streamDCs[streamOf(v)]; sdc != nil {
+		dc = sdc
+	}
+
+	sink := rc.udpSink
+	flow := rc.flow
+	backlog, drop := cmp.Or(rc.udpBacklog, 1<<20), rc.udpDrop
+	budget := rc.budget
 	rc.mu.RUnlock()
 
-	if dc == nil || dc.ReadyState() != webrtc.DataChannelStateOpen {
+	if sink == nil && (dc == nil || dc.ReadyState() != webrtc.DataChannelStateOpen) {
+		rc.holdUDP(p, v)
+
 		return
 	}
 
-	for dc.BufferedAmount() > (1 << 20) {
+	if !flow.allow(len(p)) {
+		rc.dropped.Add(1)
+
+		return
+	}
+
+	if sink != nil {
+		sink(p)
+
+		return
+	}
+
+	if !budget.allow(v, len(p), dc.BufferedAmount()) {
+		rc.dropped.Add(1)
+
+		return
+	}
+
+	for dc.BufferedAmount() > backlog {
+		if drop {
+			rc.dropped.Add(1)
+
+			return
+		}
+
 		time.Sleep(2 * time.Millisecond)
 	}
 
@@ -127,65 +320,29 @@ func (rc *radioConn) forwardToDataChannel(p []byte) {
 	}
 }
 
+// opusFrameCount is the number of frames in an Opus packet, from the code in
+// its TOC byte and, for code 3, its frame count byte (RFC 6716 section
+// 3.2). Zero when the packet is too short to say.
 func opusFrameCount(b []byte) int {
 	if len(b) < 1 {
 		return 0
 	}
 
-	toc := b[0]
-	switch toc & 0x03 {
+	switch b[0] & 0x03 {
 	case 0:
 		return 1
-	case 1:
+	case 1, 2:
 		return 2
-	case 2:
+	default:
 		if len(b) < 2 {
 			return 0
 		}
 
-		n := int(b[1])
+		n := int(b[1] & 0x3F)
 		if n < 1 || n > 48 {
 			return 0
 		}
 
 		return n
-	case 3:
-		i := 1
-		frames := 0
-
-		for i < len(b) {
-			size, n := opusReadSize(b, i)
-			if n == 0 || i+n+size > len(b) {
-				return 0
-			}
-
-			i += n + size
-			frames++
-		}
-
-		if frames < 1 || frames > 48 {
-			return 0
-		}
-
-		return frames
-	default:
-		return 0
-	}
-}
-
-func opusReadSize(b []byte, i int) (size int, n int) {
-	if i >= len(b) {
-		return 0, 0
-	}
-
-	sz := int(b[i])
-	if sz < 252 {
-		return sz, 1
 	}
-
-	if i+1 >= len(b) {
-		return 0, 0
-	}
-
-	return 252 + int(b[i+1]), 2
 }
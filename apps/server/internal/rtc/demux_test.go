@@ -0,0 +1,47 @@
+package rtc
+
+import (
+	"testing"
+)
+
+func TestHoldUDP(t *testing.T) {
+	t.Parallel()
+
+	rc := &radioConn{}
+	buf := []byte("first")
+
+	rc.holdUDP(buf, vitaView{StreamID: 0x700})
+	copy(buf, "reuse")
+	rc.holdUDP([]byte("pan"), vitaView{StreamID: 0x40000000})
+
+	if got := string(rc.udpHeld[0x700]); got != "first" {
+		t.Errorf("meters held as %q", got)
+	}
+
+	rc.holdUDP([]byte("newer"), vitaView{StreamID: 0x700})
+
+	if got := string(rc.udpHeld[0x700]); got != "newer" || len(rc.udpHeld) != 2 {
+		t.Errorf("held %d streams, meters %q", len(rc.udpHeld), got)
+	}
+
+	for id := range uint32(maxHeldStreams) {
+		rc.holdUDP([]byte("x"), vitaView{StreamID: 0x1000 + id})
+	}
+
+	if len(rc.udpHeld) != maxHeldStreams {
+		t.Errorf("held %d streams", len(rc.udpHeld))
+	}
+}
+
+func TestRadioUDPAddr(t *testing.T) {
+	t.Parallel()
+
+	got, err := radioUDPAddr("192.0.2.10:4992")
+	if err != nil || got != "192.0.2.10:4993" {
+		t.Errorf("radioUDPAddr = %q, %v", got, err)
+	}
+
+	if _, err := radioUDPAddr("192.0.2.10"); err == nil {
+		t.Error("no port accepted")
+	}
+}
@@ -0,0 +1,199 @@
+package rtc
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/tz"
+)
+
+// DiagnosticsPath serves a snapshot of the bridge's runtime and of every
+// session's buffers, for diagnosing a bridge that falls behind.
+const DiagnosticsPath = "/debug/runtime"
+
+// Occupancy is how full one buffer is, in items: packets, commands or
+// messages.
+type Occupancy struct {
+	Len int `json:"len"`
+	Cap int `json:"cap"`
+}
+
+// SessionBuffers is what is waiting in one session's buffers. Fields are nil
+// or empty while the buffer does not exist.
+type SessionBuffers struct {
+	ID string `json:"id"`
+	// Signaling is messages waiting for the session's WebSocket.
+	Signaling Occupancy `json:"signaling"`
+	// RadioCommands is commands waiting to be written to the radio.
+	RadioCommands *Occupancy `json:"radioCommands,omitempty"`
+	// UDPQueue is radio packets waiting for the pipeline's workers.
+	UDPQueue *Occupancy `json:"udpQueue,omitempty"`
+	// UDPChannelBytes is what the "udp" data channel has yet to send.
+	UDPChannelBytes uint64 `json:"udpChannelBytes"`
+	// Listeners and Tunnels are the HTTP audio listeners' and WebSocket
+	// tunnels' packets not yet written.
+	Listeners []Occupancy `json:"listeners,omitempty"`
+	Tunnels   []Occupancy `json:"tunnels,omitempty"`
+}
+
+// GCStats summarises the garbage collector and heap.
+type GCStats struct {
+	Cycles       uint32        `json:"cycles"`
+	Last         time.Time     `json:"last,omitzero"`
+	LastPause    time.Duration `json:"lastPauseNs"`
+	TotalPause   time.Duration `json:"totalPauseNs"`
+	CPUFraction  float64       `json:"cpuFraction"`
+	HeapAlloc    uint64        `json:"heapAlloc"`
+	HeapInuse    uint64        `json:"heapInuse"`
+	HeapObjects  uint64        `json:"heapObjects"`
+	NextGC       uint64        `json:"nextGc"`
+	Sys          uint64        `json:"sys"`
+	TotalAlloc   uint64        `json:"totalAlloc"`
+	Mallocs      uint64        `json:"mallocs"`
+	Frees        uint64        `json:"frees"`
+	StackInuse   uint64        `json:"stackInuse"`
+	ForcedCycles uint32        `json:"forcedCycles"`
+}
+
+// Diagnostics is a point-in-time snapshot of the bridge's runtime.
+type Diagnostics struct {
+	At         time.Time        `json:"at"`
+	GoVersion  string           `json:"goVersion"`
+	CPUs       int              `json:"cpus"`
+	GOMAXPROCS int              `json:"gomaxprocs"`
+	Goroutines int              `json:"goroutines"`
+	GC         GCStats          `json:"gc"`
+	Sessions   []SessionBuffers `json:"sessions"`
+}
+
+// Diagnostics reads the runtime's counters and every session's buffers.
+func (s *Server) Diagnostics() Diagnostics {
+	var ms runtime.MemStats
+
+	runtime.ReadMemStats(&ms)
+
+	d := Diagnostics{
+		At:         tz.In(time.Now()),
+		GoVersion:  runtime.Version(),
+		CPUs:       runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		GC: GCStats{
+			Cycles:       ms.NumGC,
+			TotalPause:   time.Duration(ms.PauseTotalNs), //nolint:gosec // nanoseconds since start fit
+			CPUFraction:  ms.GCCPUFraction,
+			HeapAlloc:    ms.HeapAlloc,
+			HeapInuse:    ms.HeapInuse,
+			HeapObjects:  ms.HeapObjects,
+			NextGC:       ms.NextGC,
+			Sys:          ms.Sys,
+			TotalAlloc:   ms.TotalAlloc,
+			Mallocs:      ms.Mallocs,
+			Frees:        ms.Frees,
+			StackInuse:   ms.StackInuse,
+			ForcedCycles: ms.NumForcedGC,
+		},
+		Sessions: []SessionBuffers{},
+	}
+
+	if ms.NumGC > 0 {
+		d.GC.Last = tz.In(time.Unix(0, int64(ms.LastGC)))              //nolint:gosec // a unix time in nanoseconds
+		d.GC.LastPause = time.Duration(ms.PauseNs[(ms.NumGC+255)%256]) //nolint:gosec // one pause fits
+	}
+
+	for _, si := range s.Sessions() {
+		if cs := s.session(si.ID); cs != nil {
+			d.Sessions = append(d.Sessions, cs.buffers())
+		}
+	}
+
+	return d
+}
+
+// ServeDiagnostics handles GET DiagnosticsPath.
+func (s *Server) ServeDiagnostics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(s.Diagnostics())
+}
+
+func (cs *clientSession) buffers() SessionBuffers {
+	b := SessionBuffers{ID: cs.id, Signaling: Occupancy{Len: len(cs.send), Cap: cap(cs.send)}}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return b
+	}
+
+	b.RadioCommands = rc.out.occupancy()
+	b.UDPQueue = rc.pipeline.Load().occupancy()
+	b.Listeners = rc.listeners.occupancy()
+	b.Tunnels = rc.tunnels.occupancy()
+
+	rc.mu.RLock()
+	dc := rc.udpDC
+	rc.mu.RUnlock()
+
+	if dc != nil {
+		b.UDPChannelBytes = dc.BufferedAmount()
+	}
+
+	return b
+}
+
+func (w *tcpWriter) occupancy() *Occupancy {
+	if w == nil {
+		return nil
+	}
+
+	return &Occupancy{Len: len(w.queue), Cap: cap(w.queue)}
+}
+
+func (pl *udpPipeline) occupancy() *Occupancy {
+	if pl == nil {
+		return nil
+	}
+
+	var o Occupancy
+
+	for _, q := range pl.queues {
+		o.Len += len(q)
+		o.Cap += cap(q)
+	}
+
+	return &o
+}
+
+func (f *audioFanout) occupancy() []Occupancy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out []Occupancy
+
+	for ch := range f.subs {
+		out = append(out, Occupancy{Len: len(ch), Cap: cap(ch)})
+	}
+
+	return out
+}
+
+func (t *udpTunnels) occupancy() []Occupancy {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []Occupancy
+
+	for sub := range t.subs {
+		out = append(out, Occupancy{Len: len(sub.ch), Cap: cap(sub.ch)})
+	}
+
+	return out
+}
@@ -0,0 +1,66 @@
+package rtc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeDiagnostics_ReportsSessionBuffers(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+
+	rc := &radioConn{addr: "10.0.0.5:4992", out: &tcpWriter{queue: make(chan []byte, 8)}}
+	rc.out.queue <- []byte("c1|ping\n")
+
+	sub := rc.tunnels.subscribe(nil)
+	sub.ch <- []byte{1}
+	sub.ch <- []byte{2}
+
+	cs := &clientSession{id: "a", srv: s, send: make(chan message, 4), radio: rc}
+	cs.send <- message{Type: "hello"}
+	s.addSession(cs)
+	s.addSession(&clientSession{id: "b", srv: s, send: make(chan message, 4)})
+
+	w := httptest.NewRecorder()
+	s.ServeDiagnostics(w, httptest.NewRequest(http.MethodGet, DiagnosticsPath, nil))
+
+	var d Diagnostics
+
+	err := json.Unmarshal(w.Body.Bytes(), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Goroutines == 0 || d.GOMAXPROCS == 0 || d.GC.Sys == 0 {
+		t.Errorf("runtime = %+v", d)
+	}
+
+	byID := map[string]SessionBuffers{}
+	for _, b := range d.Sessions {
+		byID[b.ID] = b
+	}
+
+	a := byID["a"]
+	if a.Signaling != (Occupancy{Len: 1, Cap: 4}) {
+		t.Errorf("signaling = %+v", a.Signaling)
+	}
+
+	if a.RadioCommands == nil || *a.RadioCommands != (Occupancy{Len: 1, Cap: 8}) {
+		t.Errorf("radio commands = %+v", a.RadioCommands)
+	}
+
+	if a.UDPQueue != nil {
+		t.Errorf("udp queue without a pipeline = %+v", a.UDPQueue)
+	}
+
+	if len(a.Tunnels) != 1 || a.Tunnels[0] != (Occupancy{Len: 2, Cap: tunnelBuffer}) {
+		t.Errorf("tunnels = %+v", a.Tunnels)
+	}
+
+	if b, ok := byID["b"]; !ok || b.RadioCommands != nil {
+		t.Errorf("session without a radio = %+v", b)
+	}
+}
@@ -0,0 +1,207 @@
+package rtc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// internalDisconnectSequence tags the bridge's own "client disconnect" sent
+// while draining.
+const internalDisconnectSequence = 2147483645
+
+const drainPollInterval = 100 * time.Millisecond
+
+type shutdownPayload struct {
+	Reason   string `json:"reason"`
+	Deadline int64  `json:"deadline"` // unix ms after which the bridge disconnects
+	// ReconnectTo is the base URL of a peer bridge that accepts the session
+	// instead, set during a handoff.
+	ReconnectTo string `json:"reconnectTo,omitempty"`
+}
+
+// DrainOptions describes why sessions are being drained and where they go.
+type DrainOptions struct {
+	Reason string
+	// Peer is the base URL (e.g. https://bridge-b:8080) of another bridge
+	// clients are told to reconnect to. Callers should CheckPeer it first.
+	Peer string
+}
+
+// Draining reports whether the server has stopped accepting sessions.
+func (s *Server) Draining() bool { return s.draining.Load() }
+
+// DrainPeer returns the peer of the drain in progress, if any.
+func (s *Server) DrainPeer() string {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	return s.drainPeer
+}
+
+// Drain refuses new sessions, tells every connected client the bridge is
+// going away (and where to reconnect, for a handoff), and waits for them to
+// leave until ctx is done. Sessions still open at that point are closed
+// cleanly: the radio is sent a client disconnect for our handle, then the
+// PeerConnection and WebSocket are closed with a going-away status.
+//
+// A Resume while Drain waits ends it early and leaves sessions alone.
+func (s *Server) Drain(ctx context.Context, opt DrainOptions) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.drainMu.Lock()
+	s.draining.Store(true)
+	s.drainPeer = opt.Peer
+	s.drainCancel = cancel
+	s.drainMu.Unlock()
+
+	if opt.Reason == "" {
+		opt.Reason = "server shutting down"
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now()
+	}
+
+	msg := mustEncode(typeShutdown, shutdownPayload{
+		Reason: opt.Reason, Deadline: deadline.UnixMilli(), ReconnectTo: opt.Peer,
+	})
+
+	all := s.sessionList()
+	if len(all) == 0 {
+		return
+	}
+
+	if opt.Peer != "" {
+		log.Printf("[rtc] draining %d session(s) to %s", len(all), opt.Peer)
+	} else {
+		log.Printf("[rtc] draining %d session(s)", len(all))
+	}
+
+	for _, cs := range all {
+		cs.trySend(msg)
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for s.sessionCount() > 0 {
+		select {
+		case <-ctx.Done():
+			if !s.draining.Load() {
+				log.Printf("[rtc] drain cancelled")
+
+				return
+			}
+
+			for _, cs := range s.sessionList() {
+				cs.closeGracefully()
+			}
+
+			return
+		case <-ticker.C:
+		}
+	}
+
+	log.Printf("[rtc] all sessions drained")
+}
+
+// BeginDrain runs Drain in the background for up to timeout. It reports
+// false, doing nothing, when a drain is already in progress.
+func (s *Server) BeginDrain(timeout time.Duration, opt DrainOptions) bool {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	if !s.draining.CompareAndSwap(false, true) {
+		return false
+	}
+
+	s.drainPeer = opt.Peer
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	go func() {
+		defer cancel()
+
+		s.Drain(ctx, opt)
+	}()
+
+	return true
+}
+
+// Resume accepts new sessions again after a maintenance drain, ending any
+// drain still waiting for clients to leave.
+func (s *Server) Resume() {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	s.draining.Store(false)
+	s.drainPeer = ""
+
+	if s.drainCancel != nil {
+		s.drainCancel()
+		s.drainCancel = nil
+	}
+
+	log.Printf("[rtc] accepting sessions again")
+}
+
+func (s *Server) sessionList() []*clientSession {
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+
+	out := make([]*clientSession, 0, len(s.sessions))
+	for _, cs := range s.sessions {
+		out = append(out, cs)
+	}
+
+	return out
+}
+
+// closeGracefully tears a session down in an order that lets the radio and
+// client clean up: radio first, then the PeerConnection, then the WebSocket.
+func (cs *clientSession) closeGracefully() {
+	cs.mu.Lock()
+	pc := cs.pc
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc != nil {
+		rc.disconnect()
+	}
+
+	if pc != nil {
+		_ = pc.Close()
+	}
+
+	// A headless session ends with its context.
+	if cs.ws != nil {
+		_ = cs.ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(time.Second))
+	}
+
+	cs.cancel()
+
+	if cs.ws != nil {
+		_ = cs.ws.Close()
+	}
+
+	log.Printf("[rtc] session %s (%s) closed for shutdown", cs.id, cs.clientIP)
+}
+
+// disconnect asks the radio to drop our client handle, so it releases the
+// slices and streams immediately instead of waiting out the TCP timeout.
+func (rc *radioConn) disconnect() {
+	err := rc.writeTCPString(fmt.Sprintf("C%d|client disconnect 0x%s\n", internalDisconnectSequence, rc.handleHex))
+	if err != nil {
+		log.Printf("[rtc] clean disconnect %s: %v", rc.key, err)
+	}
+
+	rc.close()
+}
@@ -0,0 +1,64 @@
+package rtc
+
+import "fmt"
+
+// Event types published on Options.Events.
+const (
+	EventSessionCreated = "session.created"
+	EventSessionClosed  = "session.closed"
+	EventStreamStarted  = "stream.started"
+	EventStreamStopped  = "stream.stopped"
+	EventTXInterlock    = "tx.interlock"
+	EventQSOLogged      = "qso.logged"
+	EventMeterAlert     = "meter.alert"
+	EventTXToken        = "tx.token"
+)
+
+// Kinds of StreamEvent.
+const (
+	StreamRXAudio   = "rx_audio"
+	StreamTXAudio   = "tx_audio"
+	StreamWHEP      = "whep"
+	StreamHTTPAudio = "http_audio"
+	StreamHLS       = "hls"
+	StreamRecording = "recording"
+	StreamUDPTunnel = "udp_tunnel"
+)
+
+// StreamEvent is the data of a stream.started or stream.stopped event: audio
+// to or from the radio, a listener of a session's audio, or a recording.
+type StreamEvent struct {
+	Session string `json:"session"`
+	Kind    string `json:"kind"`
+	// ID is the radio's stream ID, the WHEP resource or the recording key.
+	ID       string `json:"id,omitempty"`
+	ClientIP string `json:"clientIp,omitempty"`
+}
+
+// InterlockEvent is the data of a tx.interlock event: a radio's transmit
+// interlock changing state, as seen by a session. ReasonText explains a state
+// that keeps the radio from transmitting.
+type InterlockEvent struct {
+	Session    string `json:"session"`
+	Radio      string `json:"radio"`
+	State      string `json:"state"`
+	Prev       string `json:"prev,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	ReasonText string `json:"reasonText,omitempty"`
+	Source     string `json:"source,omitempty"`
+}
+
+// radioStream publishes the session's audio streams to and from the radio
+// starting and stopping.
+func (cs *clientSession) radioStream(started bool, kind string, streamID uint32) {
+	cs.srv.publishStream(started, StreamEvent{Session: cs.id, Kind: kind, ID: fmt.Sprintf("0x%08X", streamID)})
+}
+
+func (s *Server) publishStream(started bool, ev StreamEvent) {
+	typ := EventStreamStopped
+	if started {
+		typ = EventStreamStarted
+	}
+
+	s.events.Publish(typ, ev)
+}
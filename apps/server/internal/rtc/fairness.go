@@ -0,0 +1,175 @@
+package rtc
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// fairWindow is how often a radio re-measures its priority traffic.
+	fairWindow = 500 * time.Millisecond
+	// fairBurst is how much unused share a flow may save up.
+	fairBurst = 500 * time.Millisecond
+	// fairMinBucket lets a flow with a tiny share still send one large
+	// packet (a full waterfall line) now and then instead of none at all.
+	fairMinBucket = 64 * 1024
+)
+
+// FairnessOptions shares each radio's outbound data among the sessions
+// connected to it. Sessions in a Priority role are never throttled; the rest
+// split what they leave of RateKbps in proportion to their role's weight.
+type FairnessOptions struct {
+	RateKbps int            // per radio; 0 disables the scheduler
+	Priority []string       // roles served first, e.g. operator
+	Weights  map[string]int // role -> weight, default 1
+}
+
+// fairness holds one scheduler per radio address.
+type fairness struct {
+	opt FairnessOptions
+
+	mu     sync.Mutex
+	radios map[string]*fairRadio
+}
+
+func newFairness(opt FairnessOptions) *fairness {
+	if opt.RateKbps <= 0 {
+		return nil
+	}
+
+	return &fairness{opt: opt, radios: make(map[string]*fairRadio)}
+}
+
+// fairRadio schedules the flows of one radio.
+type fairRadio struct {
+	rate float64 // bytes per second
+
+	mu           sync.Mutex
+	flows        map[*fairFlow]struct{}
+	totalWeight  int // of throttled flows
+	windowStart  time.Time
+	priorityUsed float64 // bytes sent by priority flows this window
+	priorityRate float64 // bytes per second over the last window
+}
+
+// fairFlow is one session's outbound data from a radio.
+type fairFlow struct {
+	key      string
+	radio    *fairRadio
+	weight   int
+	priority bool
+	tokens   float64
+	last     time.Time
+}
+
+// join registers a session of role on radio addr. It returns nil, meaning
+// unthrottled, when the scheduler is off.
+func (f *fairness) join(addr, role string) *fairFlow {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := f.radios[addr]
+	if r == nil {
+		r = &fairRadio{rate: float64(f.opt.RateKbps) * 1000 / 8, flows: make(map[*fairFlow]struct{})}
+		f.radios[addr] = r
+	}
+
+	w := f.opt.Weights[role]
+	if w <= 0 {
+		w = 1
+	}
+
+	flow := &fairFlow{key: addr, radio: r, weight: w, priority: slices.Contains(f.opt.Priority, role)}
+
+	r.mu.Lock()
+	r.flows[flow] = struct{}{}
+	if !flow.priority {
+		r.totalWeight += w
+	}
+	r.mu.Unlock()
+
+	return flow
+}
+
+// leave unregisters flow, dropping the radio's scheduler with its last flow.
+func (f *fairness) leave(flow *fairFlow) {
+	if f == nil || flow == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := flow.radio
+
+	r.mu.Lock()
+	if _, ok := r.flows[flow]; ok {
+		delete(r.flows, flow)
+
+		if !flow.priority {
+			r.totalWeight -= flow.weight
+		}
+	}
+	empty := len(r.flows) == 0
+	r.mu.Unlock()
+
+	if empty && f.radios[flow.key] == r {
+		delete(f.radios, flow.key)
+	}
+}
+
+// allow reports whether n bytes may be sent now. Throttled packets are
+// dropped by the caller rather than queued, so a spectator's display skips
+// frames instead of falling behind.
+func (flow *fairFlow) allow(n int) bool {
+	if flow == nil {
+		return true
+	}
+
+	return flow.allowAt(n, time.Now())
+}
+
+func (flow *fairFlow) allowAt(n int, now time.Time) bool {
+	r := flow.radio
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elapsed := now.Sub(r.windowStart); elapsed >= fairWindow {
+		if !r.windowStart.IsZero() {
+			r.priorityRate = r.priorityUsed / elapsed.Seconds()
+		}
+
+		r.windowStart, r.priorityUsed = now, 0
+	}
+
+	if flow.priority {
+		r.priorityUsed += float64(n)
+
+		return true
+	}
+
+	share := max(r.rate-r.priorityRate, 0) * float64(flow.weight) / float64(max(r.totalWeight, 1))
+	limit := max(share*fairBurst.Seconds(), fairMinBucket)
+
+	if flow.last.IsZero() {
+		flow.tokens = limit
+	} else {
+		flow.tokens = min(flow.tokens+share*now.Sub(flow.last).Seconds(), limit)
+	}
+
+	flow.last = now
+
+	if flow.tokens < float64(n) {
+		return false
+	}
+
+	flow.tokens -= float64(n)
+
+	return true
+}
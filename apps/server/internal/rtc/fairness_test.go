@@ -0,0 +1,69 @@
+package rtc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFairness_PriorityAndWeights(t *testing.T) {
+	t.Parallel()
+
+	// 800 kbit/s = 100 kB/s for the radio.
+	f := newFairness(FairnessOptions{
+		RateKbps: 800,
+		Priority: []string{"operator"},
+		Weights:  map[string]int{"member": 3},
+	})
+
+	op := f.join("radio", "operator")
+	guest := f.join("radio", "spectator")
+	member := f.join("radio", "member")
+
+	// The operator uses 40 kB/s; each spectator asks for 100 kB/s.
+	start := time.Unix(0, 0)
+	sent := map[*fairFlow]int{}
+
+	for i := range 2000 { // 20 s in 10 ms steps
+		now := start.Add(time.Duration(i) * 10 * time.Millisecond)
+
+		if !op.allowAt(400, now) {
+			t.Fatal("priority flow was throttled")
+		}
+
+		for _, flow := range []*fairFlow{guest, member} {
+			if flow.allowAt(1000, now) && i >= 500 {
+				sent[flow] += 1000
+			}
+		}
+	}
+
+	// The 60 kB/s left over splits 1:3 once the initial bursts are spent.
+	for flow, want := range map[*fairFlow]float64{guest: 15000, member: 45000} {
+		got := float64(sent[flow]) / 15
+		if math.Abs(got-want)/want > 0.1 {
+			t.Errorf("weight %d flow got %.0f B/s, want about %.0f", flow.weight, got, want)
+		}
+	}
+
+	f.leave(guest)
+	f.leave(member)
+	f.leave(op)
+
+	if len(f.radios) != 0 {
+		t.Error("radio scheduler should go away with its last flow")
+	}
+}
+
+func TestFairness_Off(t *testing.T) {
+	t.Parallel()
+
+	f := newFairness(FairnessOptions{})
+
+	flow := f.join("radio", "spectator")
+	if flow != nil || !flow.allow(1<<20) {
+		t.Error("a disabled scheduler should allow everything")
+	}
+
+	f.leave(flow)
+}
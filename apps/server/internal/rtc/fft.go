@@ -0,0 +1,232 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+const (
+	// fftHeader is the size of the header before a panadapter packet's
+	// bins: first bin, bins in this packet, bytes per bin, the frame's total
+	// bins, and the frame index.
+	fftHeader = 12
+	// maxFFTWidth bounds a declared width; no display is wider.
+	maxFFTWidth = 8192
+)
+
+var errFFTWidth = errors.New("fft width must be between 0 and 8192")
+
+// fftWidthPayload declares the width in pixels of the client's panadapter
+// display, for one stream or with no stream for all of them. Zero sends the
+// radio's full resolution again.
+type fftWidthPayload struct {
+	Stream string `json:"stream,omitempty"`
+	Width  int    `json:"width"`
+}
+
+// fftBinner narrows a session's panadapter frames to the width its client
+// draws them at. The radio splits a frame of a few thousand bins across
+// several packets; the binner collects them and sends one packet of the
+// frame at the display's width, which on a phone is a fraction of the size.
+type fftBinner struct {
+	mu     sync.Mutex
+	all    int
+	widths map[uint32]int
+	// frames is each stream's frame being collected.
+	frames map[uint32]*fftFrame
+}
+
+// fftFrame is one panadapter frame being collected.
+type fftFrame struct {
+	index    uint32
+	bins     []uint16
+	filled   int
+	header   []byte // the first packet's VITA header
+	trailer  []byte
+	complete bool
+}
+
+func newFFTBinner() *fftBinner {
+	return &fftBinner{widths: make(map[uint32]int), frames: make(map[uint32]*fftFrame)}
+}
+
+// setWidth narrows stream, or with stream zero every stream without a width
+// of its own, to width; zero restores full resolution.
+func (f *fftBinner) setWidth(stream uint32, width int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case stream == 0:
+		f.all = width
+	case width == 0:
+		delete(f.widths, stream)
+	default:
+		f.widths[stream] = width
+	}
+}
+
+// width is stream's width; f.mu is held.
+func (f *fftBinner) width(stream uint32) int {
+	if w, ok := f.widths[stream]; ok {
+		return w
+	}
+
+	return f.all
+}
+
+// add takes the panadapter packet p, parsed as v, and returns what to send
+// in its place: p itself when the stream is not narrowed or already fits,
+// nil while a frame is still being collected, and the narrowed frame once
+// its last packet arrives. A frame missing a packet is dropped when the
+// next one starts.
+func (f *fftBinner) add(p []byte, v vitaView) []byte {
+	if f == nil {
+		return p
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	width := f.width(v.StreamID)
+	if width <= 0 || len(v.Payload) < fftHeader {
+		return p
+	}
+
+	first := int(binary.BigEndian.Uint16(v.Payload[0:]))
+	count := int(binary.BigEndian.Uint16(v.Payload[2:]))
+	total := int(binary.BigEndian.Uint16(v.Payload[6:]))
+	index := binary.BigEndian.Uint32(v.Payload[8:])
+
+	if width >= total {
+		return p
+	}
+
+	bins := v.Payload[fftHeader:]
+	if len(bins) < 2*count || first+count > total {
+		return nil
+	}
+
+	fr := f.frames[v.StreamID]
+	if fr == nil || fr.index != index || len(fr.bins) != total {
+		fr = &fftFrame{index: index, bins: make([]uint16, total)}
+		f.frames[v.StreamID] = fr
+	}
+
+	if fr.complete {
+		return nil
+	}
+
+	if fr.header == nil {
+		// The buffer p is in is reused for the next packet.
+		fr.header = append([]byte(nil), p[:len(p)-len(v.Payload)-trailerLen(v)]...)
+		fr.trailer = append([]byte(nil), p[len(p)-trailerLen(v):]...)
+	}
+
+	for i := range count {
+		fr.bins[first+i] = binary.BigEndian.Uint16(bins[2*i:])
+	}
+
+	fr.filled += count
+	if fr.filled < total {
+		return nil
+	}
+
+	fr.complete = true
+
+	return fr.packet(width)
+}
+
+// packet builds one VITA packet holding the frame narrowed to width bins.
+func (fr *fftFrame) packet(width int) []byte {
+	out := make([]byte, 0, len(fr.header)+fftHeader+2*width+len(fr.trailer))
+	out = append(out, fr.header...)
+	out = binary.BigEndian.AppendUint16(out, 0)
+	out = binary.BigEndian.AppendUint16(out, uint16(width)) //nolint:gosec // width < total, a uint16
+	out = binary.BigEndian.AppendUint16(out, 2)
+	out = binary.BigEndian.AppendUint16(out, uint16(width)) //nolint:gosec // likewise
+	out = binary.BigEndian.AppendUint32(out, fr.index)
+
+	for _, v := range binFFT(fr.bins, width) {
+		out = binary.BigEndian.AppendUint16(out, v)
+	}
+
+	if width%2 != 0 {
+		out = append(out, 0, 0) // pad to a whole word
+	}
+
+	out = append(out, fr.trailer...)
+
+	// The header's packet size is in 32-bit words.
+	binary.BigEndian.PutUint16(out[2:], uint16(len(out)/4)) //nolint:gosec // under 64 KiB
+
+	return out
+}
+
+// binFFT reduces bins to width, each the strongest of the bins it covers so
+// a narrow signal is not averaged away. The radio sends each bin as the
+// display row it lands on, so the strongest is the smallest.
+func binFFT(bins []uint16, width int) []uint16 {
+	out := make([]uint16, width)
+
+	for x := range out {
+		lo := x * len(bins) / width
+		hi := max((x+1)*len(bins)/width, lo+1)
+
+		out[x] = bins[lo]
+		for _, v := range bins[lo+1 : hi] {
+			out[x] = min(out[x], v)
+		}
+	}
+
+	return out
+}
+
+func trailerLen(v vitaView) int {
+	if v.HasTrailer {
+		return 4
+	}
+
+	return 0
+}
+
+// handleFFTWidth sets the width the session's panadapter frames are
+// narrowed to and echoes it back.
+func (cs *clientSession) handleFFTWidth(raw json.RawMessage) {
+	var p fftWidthPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	if p.Width < 0 || p.Width > maxFFTWidth {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: errFFTWidth.Error()}))
+
+		return
+	}
+
+	stream := parseHex32(p.Stream)
+	if p.Stream != "" && stream == 0 {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: "bad stream " + p.Stream}))
+
+		return
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "FFT_UNAVAILABLE", Message: "no radio connection"}))
+
+		return
+	}
+
+	rc.fft.setWidth(stream, p.Width)
+	cs.trySend(mustEncode(typeFFTWidth, p))
+}
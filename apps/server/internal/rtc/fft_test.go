@@ -0,0 +1,121 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+// panPacket builds a panadapter packet of stream carrying bins from first
+// of a frame of total bins.
+func panPacket(t *testing.T, stream, frame uint32, first, total int, bins []uint16) ([]byte, vitaView) {
+	t.Helper()
+
+	p := []byte{0x1C, 0, 0, 0} // class ID and trailer present
+	p = binary.BigEndian.AppendUint32(p, stream)
+	p = binary.BigEndian.AppendUint32(p, 0x001C2D)
+	p = binary.BigEndian.AppendUint32(p, vitaFFTClass)
+	p = binary.BigEndian.AppendUint16(p, uint16(first))     //nolint:gosec // test sizes
+	p = binary.BigEndian.AppendUint16(p, uint16(len(bins))) //nolint:gosec // test sizes
+	p = binary.BigEndian.AppendUint16(p, 2)
+	p = binary.BigEndian.AppendUint16(p, uint16(total)) //nolint:gosec // test sizes
+	p = binary.BigEndian.AppendUint32(p, frame)
+
+	for _, b := range bins {
+		p = binary.BigEndian.AppendUint16(p, b)
+	}
+
+	p = binary.BigEndian.AppendUint32(p, 0xDEADBEEF)
+	binary.BigEndian.PutUint16(p[2:], uint16(len(p)/4)) //nolint:gosec // test sizes
+
+	v, err := parseVITA(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p, v
+}
+
+func TestFFTBinner(t *testing.T) {
+	t.Parallel()
+
+	f := newFFTBinner()
+
+	// Not narrowed: packets pass as they are.
+	p, v := panPacket(t, 0x40000000, 1, 0, 8, []uint16{1, 2, 3, 4, 5, 6, 7, 8})
+	if out := f.add(p, v); &out[0] != &p[0] {
+		t.Fatal("unnarrowed packet rebuilt")
+	}
+
+	f.setWidth(0, 3)
+
+	// A frame split across two packets comes out as one, each column the
+	// smallest (strongest) of its bins.
+	p, v = panPacket(t, 0x40000000, 2, 0, 8, []uint16{9, 4, 7, 8})
+	if out := f.add(p, v); out != nil {
+		t.Fatal("half a frame sent")
+	}
+
+	p, v = panPacket(t, 0x40000000, 2, 4, 8, []uint16{6, 5, 3, 9})
+
+	out := f.add(p, v)
+	if out == nil {
+		t.Fatal("whole frame not sent")
+	}
+
+	got, err := parseVITA(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.StreamID != 0x40000000 || got.ClassCode != vitaFFTClass || !got.HasTrailer {
+		t.Errorf("header %+v", got)
+	}
+
+	if words := int(binary.BigEndian.Uint16(out[2:])); words*4 != len(out) {
+		t.Errorf("size %d words for %d bytes", words, len(out))
+	}
+
+	if binary.BigEndian.Uint32(out[len(out)-4:]) != 0xDEADBEEF {
+		t.Error("trailer lost")
+	}
+
+	pl := got.Payload
+	if n, total, frame := binary.BigEndian.Uint16(pl[2:]), binary.BigEndian.Uint16(pl[6:]), binary.BigEndian.Uint32(pl[8:]); n != 3 || total != 3 || frame != 2 {
+		t.Errorf("bins %d of %d, frame %d", n, total, frame)
+	}
+
+	var bins []uint16
+	for i := range 3 {
+		bins = append(bins, binary.BigEndian.Uint16(pl[fftHeader+2*i:]))
+	}
+
+	if !slices.Equal(bins, []uint16{4, 6, 3}) {
+		t.Errorf("bins %v", bins)
+	}
+
+	// A frame missing a packet is dropped for the next.
+	p, v = panPacket(t, 0x40000000, 3, 0, 8, []uint16{1, 1, 1, 1})
+	f.add(p, v)
+
+	p, v = panPacket(t, 0x40000000, 4, 4, 8, []uint16{1, 1, 1, 1})
+	if out := f.add(p, v); out != nil {
+		t.Error("incomplete frame sent")
+	}
+
+	// A stream's own width overrides the default, and zero restores it.
+	f.setWidth(0x40000000, 16)
+
+	p, v = panPacket(t, 0x40000000, 5, 0, 8, []uint16{1, 2, 3, 4, 5, 6, 7, 8})
+	if out := f.add(p, v); len(out) != len(p) {
+		t.Error("stream narrowed past its own width")
+	}
+}
+
+func TestBinFFT(t *testing.T) {
+	t.Parallel()
+
+	if got := binFFT([]uint16{5, 1, 4, 2, 3}, 2); !slices.Equal(got, []uint16{1, 2}) {
+		t.Errorf("binFFT = %v", got)
+	}
+}
@@ -0,0 +1,254 @@
+package rtc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GPS is the radio's GPS and GPS-disciplined oscillator, as its "gps" status
+// reports it.
+type GPS struct {
+	Installed bool `json:"installed"`
+	// Status is e.g. "Not Present", "Present" or "Fine Lock".
+	Status string `json:"status,omitempty"`
+	// Locked is set while the oscillator is locked to GPS.
+	Locked bool `json:"locked"`
+	// FreqError is the oscillator's error as the radio reports it, in parts
+	// per billion.
+	FreqError float64 `json:"freqError"`
+	// UTC is the time of the last fix, e.g. "14:32:10Z".
+	UTC string `json:"utc,omitempty"`
+	// Fix is set while the radio reports a position; Lat and Lon are in
+	// degrees.
+	Fix       bool    `json:"fix"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	AltitudeM float64 `json:"altitudeM"`
+	// Grid is the six-character Maidenhead locator of Lat and Lon.
+	Grid        string  `json:"grid,omitempty"`
+	Tracked     int     `json:"tracked"`
+	Visible     int     `json:"visible"`
+	SpeedKnots  float64 `json:"speedKnots"`
+	TrackDegree float64 `json:"trackDegree"`
+}
+
+// gpsState tracks the radio's "gps" status.
+type gpsState struct {
+	mu  sync.Mutex
+	gps GPS
+}
+
+// observe applies a "gps ..." status body. It reports whether anything
+// changed.
+func (g *gpsState) observe(body string) bool {
+	attrs, ok := strings.CutPrefix(body, "gps ")
+	if !ok {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	old := g.gps
+	gps := old
+
+	var (
+		position     bool // the status carries the position
+		latOK, lonOK bool
+	)
+
+	// The radio sends spaces in values as 0x7f.
+	for k, v := range statusAttrs(attrs) {
+		v = strings.ReplaceAll(v, "\x7f", " ")
+
+		switch k {
+		case "installed":
+			gps.Installed = v == "1"
+		case "status":
+			gps.Status = v
+			gps.Locked = strings.HasSuffix(v, "Lock")
+		case "freq_error":
+			gps.FreqError, _ = strconv.ParseFloat(v, 64)
+		case "time":
+			gps.UTC = v
+		case "lat":
+			position = true
+			gps.Lat, latOK = parseCoordinate(v, 90)
+		case "lon":
+			position = true
+			gps.Lon, lonOK = parseCoordinate(v, 180)
+		case "altitude":
+			gps.AltitudeM, _ = strconv.ParseFloat(v, 64)
+		case "tracked":
+			gps.Tracked, _ = strconv.Atoi(v)
+		case "visible":
+			gps.Visible, _ = strconv.Atoi(v)
+		case "speed":
+			gps.SpeedKnots, _ = strconv.ParseFloat(v, 64)
+		case "track":
+			gps.TrackDegree, _ = strconv.ParseFloat(v, 64)
+		}
+	}
+
+	// A status without the position leaves it as it was.
+	if position {
+		gps.Fix = latOK && lonOK
+		gps.Grid = ""
+
+		if gps.Fix {
+			gps.Grid = maidenhead(gps.Lat, gps.Lon)
+		}
+	}
+
+	g.gps = gps
+
+	return gps != old
+}
+
+func (g *gpsState) snapshot() GPS {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.gps
+}
+
+// parseCoordinate parses a latitude or longitude of at most limit degrees
+// either way; it reports false when the radio has none.
+func parseCoordinate(v string, limit float64) (float64, bool) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.Abs(f) > limit {
+		return 0, false
+	}
+
+	return f, true
+}
+
+// maidenhead is the six-character Maidenhead locator of a position, e.g.
+// "FN31pr".
+func maidenhead(lat, lon float64) string {
+	// The north pole and the antimeridian belong to the last square.
+	lat = math.Min(lat+90, 180-1e-9)
+	lon = math.Min(lon+180, 360-1e-9)
+
+	return string([]byte{
+		'A' + byte(lon/20),
+		'A' + byte(lat/10),
+		'0' + byte(math.Mod(lon, 20)/2),
+		'0' + byte(math.Mod(lat, 10)),
+		'a' + byte(math.Mod(lon, 2)*12),
+		'a' + byte(math.Mod(lat, 1)*24),
+	})
+}
+
+// observeGPS tracks the radio's GPS from a status body.
+func (rc *radioConn) observeGPS(body string) {
+	if !rc.gps.observe(body) {
+		return
+	}
+
+	rc.mu.RLock()
+	onGPS := rc.onGPS
+	rc.mu.RUnlock()
+
+	if onGPS != nil {
+		onGPS()
+	}
+}
+
+// subscribeGPS subscribes to the radio's GPS status once per connection; the
+// radio answers with the current state.
+func (rc *radioConn) subscribeGPS() error {
+	rc.mu.Lock()
+	done := rc.gpsSubscribed
+	rc.gpsSubscribed = true
+	rc.mu.Unlock()
+
+	if done {
+		return nil
+	}
+
+	_, err := rc.clientCommand("sub gps all")
+	if err != nil {
+		rc.mu.Lock()
+		rc.gpsSubscribed = false
+		rc.mu.Unlock()
+	}
+
+	return err
+}
+
+// gpsRequest is a client's "gps" message: Action is "subscribe", which is
+// answered with the GPS and sends it again as it changes, or "unsubscribe".
+type gpsRequest struct {
+	Action string `json:"action"`
+}
+
+func (cs *clientSession) handleGPS(raw json.RawMessage) {
+	var req gpsRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	switch req.Action {
+	case "subscribe":
+		cs.gps.Store(true)
+	case "unsubscribe":
+		cs.gps.Store(false)
+
+		return
+	default:
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "GPS_FAILED", Message: fmt.Sprintf("unknown gps action %q", req.Action)}))
+
+		return
+	}
+
+	gps, err := cs.readGPS()
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "GPS_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeGPS, gps))
+}
+
+// gpsChanged updates a session that subscribed to the GPS.
+func (cs *clientSession) gpsChanged(rc *radioConn) {
+	if cs.gps.Load() {
+		cs.trySend(mustEncode(typeGPS, rc.gps.snapshot()))
+	}
+}
+
+// readGPS reports the session's radio's GPS, subscribing to it first if need
+// be.
+func (cs *clientSession) readGPS() (GPS, error) {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return GPS{}, errClientNoRadio
+	}
+
+	err := rc.subscribeGPS()
+
+	return rc.gps.snapshot(), err
+}
+
+// GPS reports session id's radio's GPS.
+func (s *Server) GPS(id string) (GPS, error) {
+	cs := s.session(id)
+	if cs == nil {
+		return GPS{}, ErrNoSession
+	}
+
+	return cs.readGPS()
+}
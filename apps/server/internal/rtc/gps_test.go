@@ -0,0 +1,64 @@
+package rtc
+
+import "testing"
+
+func TestMaidenhead(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		lat, lon float64
+		want     string
+	}{
+		{41.714775, -72.727260, "FN31pr"},
+		{48.14666, 11.60833, "JN58td"},
+		{-34.91, -56.21166, "GF15vc"},
+		{90, 180, "RR99xx"},
+		{-90, -180, "AA00aa"},
+	}
+
+	for _, c := range cases {
+		if got := maidenhead(c.lat, c.lon); got != c.want {
+			t.Errorf("maidenhead(%v, %v) = %q, want %q", c.lat, c.lon, got, c.want)
+		}
+	}
+}
+
+func TestGPSState_Observe(t *testing.T) {
+	t.Parallel()
+
+	var g gpsState
+
+	if !g.observe("gps lat=41.714775 lon=-72.727260 grid=FN31pr altitude=25 tracked=9 visible=12 speed=0 freq_error=-0.4 status=Fine\x7fLock time=14:32:10Z track=0 installed=1") {
+		t.Error("a first status is not a change")
+	}
+
+	gps := g.snapshot()
+
+	want := GPS{
+		Installed: true, Status: "Fine Lock", Locked: true, FreqError: -0.4, UTC: "14:32:10Z",
+		Fix: true, Lat: 41.714775, Lon: -72.72726, AltitudeM: 25, Grid: "FN31pr", Tracked: 9, Visible: 12,
+	}
+	if gps != want {
+		t.Errorf("gps = %+v, want %+v", gps, want)
+	}
+
+	if g.observe("gps status=Fine\x7fLock") {
+		t.Error("a repeated status is a change")
+	}
+
+	g.observe("gps time=14:32:11Z")
+
+	if gps := g.snapshot(); gps.UTC != "14:32:11Z" || !gps.Fix {
+		t.Errorf("time update: %+v", gps)
+	}
+
+	g.observe("gps lat= lon= status=Present")
+
+	if gps := g.snapshot(); gps.Fix || gps.Grid != "" || gps.Locked {
+		t.Errorf("lost fix: %+v", gps)
+	}
+
+	if g.observe("slice 0 RF_frequency=14.074") {
+		t.Error("not a gps status")
+	}
+}
@@ -0,0 +1,254 @@
+package rtc
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+	"github.com/google/uuid"
+)
+
+const (
+	// guardBlockedCode is the radio's "Security fault" response code, reused so
+	// clients surface a blocked command like any other rejected one.
+	guardBlockedCode = "50000021"
+
+	guardConfirmTimeout = 30 * time.Second
+)
+
+type commandGuardPayload struct {
+	ID       string `json:"id"`
+	Command  string `json:"command"`
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+	Expires  int64  `json:"expires"`
+}
+
+type commandConfirmPayload struct {
+	ID      string `json:"id"`
+	Approve bool   `json:"approve"`
+}
+
+// heldCommand is a command awaiting operator confirmation.
+type heldCommand struct {
+	req      guard.Request
+	decision guard.Decision
+	timer    *time.Timer
+}
+
+// forwardCommand runs each line in data through the guard engine and writes
+// the permitted lines to the radio. Blocked lines, including keying a radio
+// whose TX is muted or whose TX token another session holds, are answered
+// locally with an error reply; lines needing confirmation are held until the
+// client answers.
+//
+// A client's "client program" and "client station" are first renamed as the
+// bridge's ClientIdentity says.
+//
+// In a sandboxed session, TX and configuration commands are first answered
+// by the sandbox and never reach the guard or the radio.
+func (cs *clientSession) forwardCommand(rc *radioConn, data []byte) error {
+	data = cs.srv.identity.rewrite(data)
+
+	data, simulated := cs.sandbox.filter(data, rc.handleHex)
+	for _, line := range simulated {
+		rc.sendTCPLine(line)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	engine := cs.srv.guard
+	if !cs.srv.screening() {
+		rc.noteOutgoingCommand(data)
+
+		return rc.writeTCP(data)
+	}
+
+	var pass []byte
+
+	for line := range strings.SplitAfterSeq(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		req := guard.Request{ClientIP: cs.peerIP, Role: cs.role, Radio: rc.addr, Line: line}
+
+		d := cs.srv.checkCommand(cs.id, req)
+		switch d.Action {
+		case guard.ActionBlock:
+			engine.Record(req, d, "blocked")
+			rc.rejectCommand(line, d.Reason)
+		case guard.ActionConfirm:
+			cs.holdCommand(req, d)
+		case guard.ActionAllow:
+			pass = append(pass, line...)
+		}
+	}
+
+	if len(pass) == 0 {
+		return nil
+	}
+
+	rc.noteOutgoingCommand(pass)
+
+	return rc.writeTCP(pass)
+}
+
+// allowImmediate applies the guard to a command the bridge sends on the
+// client's behalf that cannot wait: a rule asking for confirmation refuses it.
+func (cs *clientSession) allowImmediate(rc *radioConn, line string) bool {
+	engine := cs.srv.guard
+	if !cs.srv.screening() {
+		return true
+	}
+
+	req := guard.Request{ClientIP: cs.peerIP, Role: cs.role, Radio: rc.addr, Line: line}
+
+	d := cs.srv.checkCommand(cs.id, req)
+	if d.Action != guard.ActionAllow {
+		engine.Record(req, d, "rejected")
+
+		return false
+	}
+
+	return true
+}
+
+// screening reports whether commands are checked before reaching the radio:
+// a guard is configured, a radio's TX is muted or keying is arbitrated.
+func (s *Server) screening() bool {
+	return s.guard != nil || s.anyMuted() || s.tokens != nil
+}
+
+// checkCommand is the guard's decision on req from session. Keying is
+// blocked before any rule is consulted on a muted radio, or when another
+// session holds the radio's TX token.
+func (s *Server) checkCommand(session string, req guard.Request) guard.Decision {
+	if keys(req.Line) {
+		if reason, muted := s.txMuted(req.Radio); muted {
+			return guard.Decision{Action: guard.ActionBlock, Category: guard.CategoryTX, Reason: reason}
+		}
+
+		if holder, ok := s.claimTXToken(req.Radio, session); !ok {
+			reason := "TX token is held by " + cmp.Or(holder, "another session")
+
+			return guard.Decision{Action: guard.ActionBlock, Category: guard.CategoryTX, Reason: reason}
+		}
+	}
+
+	return s.guard.Check(req)
+}
+
+func (cs *clientSession) holdCommand(req guard.Request, d guard.Decision) {
+	id := uuid.NewString()
+	held := &heldCommand{req: req, decision: d}
+
+	cs.mu.Lock()
+	if cs.held == nil {
+		cs.held = make(map[string]*heldCommand)
+	}
+
+	cs.held[id] = held
+	held.timer = time.AfterFunc(guardConfirmTimeout, func() { cs.resolveHeld(id, false, "expired") })
+	cs.mu.Unlock()
+
+	cs.srv.guard.Record(req, d, "held")
+
+	_, body, _ := guard.SplitCommand(req.Line)
+	cs.trySend(mustEncode(typeCommandGuard, commandGuardPayload{
+		ID:       id,
+		Command:  body,
+		Category: string(d.Category),
+		Reason:   d.Reason,
+		Expires:  time.Now().Add(guardConfirmTimeout).UnixMilli(),
+	}))
+}
+
+func (cs *clientSession) handleCommandConfirm(raw json.RawMessage) {
+	var p commandConfirmPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	outcome := "rejected"
+	if p.Approve {
+		outcome = "confirmed"
+	}
+
+	cs.resolveHeld(p.ID, p.Approve, outcome)
+}
+
+// resolveHeld releases or rejects a held command. Unknown IDs (already
+// resolved or expired) are ignored.
+func (cs *clientSession) resolveHeld(id string, approve bool, outcome string) {
+	cs.mu.Lock()
+	held, ok := cs.held[id]
+	delete(cs.held, id)
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	held.timer.Stop()
+	cs.srv.guard.Record(held.req, held.decision, outcome)
+
+	if rc == nil {
+		return
+	}
+
+	if !approve {
+		rc.rejectCommand(held.req.Line, fmt.Sprintf("%s (%s)", held.decision.Reason, outcome))
+
+		return
+	}
+
+	// The TX token may have moved while the command waited.
+	if keys(held.req.Line) && !cs.srv.tokens.holds(rc.addr, cs.id) {
+		rc.rejectCommand(held.req.Line, "TX token is held by another session")
+
+		return
+	}
+
+	rc.noteOutgoingCommand([]byte(held.req.Line))
+
+	err := rc.writeTCPString(held.req.Line)
+	if err != nil {
+		log.Printf("[rtc] tcp write: %v", err)
+	}
+}
+
+// dropHeld discards every pending confirmation, e.g. when the radio link closes.
+func (cs *clientSession) dropHeld() {
+	cs.mu.Lock()
+	held := cs.held
+	cs.held = nil
+	cs.mu.Unlock()
+
+	for _, h := range held {
+		h.timer.Stop()
+		cs.srv.guard.Record(h.req, h.decision, "dropped")
+	}
+}
+
+// rejectCommand answers a command line locally with an error reply so the
+// client's pending request resolves instead of waiting forever.
+func (rc *radioConn) rejectCommand(line, reason string) {
+	seq, _, ok := guard.SplitCommand(line)
+	if !ok {
+		return
+	}
+
+	rc.sendTCPLine(fmt.Sprintf("R%s|%s|blocked by bridge policy: %s\n", seq, guardBlockedCode, reason))
+}
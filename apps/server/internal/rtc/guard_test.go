@@ -0,0 +1,41 @@
+package rtc
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+	"github.com/gorilla/websocket"
+)
+
+func TestClientSession_RoleIgnoresForwardingHeaders(t *testing.T) {
+	t.Parallel()
+
+	engine, err := guard.New(guard.Policy{Roles: map[string][]string{"admin": {"192.168.0.0/16"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{guard: engine}
+
+	for name, tt := range map[string]struct {
+		remote, header, want string
+	}{
+		"forged X-Forwarded-For":  {remote: "203.0.113.9:5555", header: "X-Forwarded-For", want: guard.DefaultRole},
+		"forged CF-Connecting-IP": {remote: "203.0.113.9:5555", header: "CF-Connecting-IP", want: guard.DefaultRole},
+		"forged X-Real-IP":        {remote: "203.0.113.9:5555", header: "X-Real-IP", want: guard.DefaultRole},
+		"peer in range":           {remote: "192.168.1.5:5555", want: "admin"},
+	} {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.RemoteAddr = tt.remote
+
+		if tt.header != "" {
+			r.Header.Set(tt.header, "192.168.1.5")
+		}
+
+		cs := newClientSession(srv, &websocket.Conn{}, func() {}, clientIPFromRequest(r), peerIPFromRequest(r))
+		if cs.role != tt.want {
+			t.Errorf("%s: role %q, want %q", name, cs.role, tt.want)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package rtc
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// internalClientSequence tags the client registration and binding commands
+// the bridge sends so their replies are consumed instead of reaching the
+// browser.
+const internalClientSequence = 2147483640
+
+// clientReplyTimeout is how long a registration or binding waits for the
+// radio.
+const clientReplyTimeout = 3 * time.Second
+
+// Program is how the bridge names itself when it registers a GUI client.
+const Program = "solid-sdr"
+
+var (
+	errClientAction  = errors.New("unknown guiClient action")
+	errClientID      = errors.New("clientId is required")
+	errClientNoRadio = errors.New("no radio connection")
+	errClientTimeout = errors.New("radio did not answer")
+	errClientRefused = errors.New("radio refused")
+)
+
+// GUIClient is a GUI client on the radio, as its "client" status reports it.
+// On multiFLEX radios each GUI client owns its own slices and panadapters,
+// and other clients bind to one to work in its context.
+type GUIClient struct {
+	Handle   string `json:"handle"`
+	ClientID string `json:"clientId,omitempty"`
+	Program  string `json:"program,omitempty"`
+	Station  string `json:"station,omitempty"`
+	LocalPTT bool   `json:"localPtt,omitempty"`
+	// Self marks the session's own connection.
+	Self bool `json:"self,omitempty"`
+}
+
+// guiClientRequest is a client's "guiClient" message.
+type guiClientRequest struct {
+	// Action is "list", which also subscribes to changes, or "bind".
+	Action   string `json:"action"`
+	ClientID string `json:"clientId,omitempty"`
+}
+
+// guiClientsPayload answers "list" and "bind", and is sent again whenever
+// the list changes.
+type guiClientsPayload struct {
+	Clients []GUIClient `json:"clients"`
+	// Bound is the GUI client the session is bound to, if any.
+	Bound string `json:"bound,omitempty"`
+}
+
+// clientTable tracks the radio's GUI clients from "client" status.
+type clientTable struct {
+	mu      sync.Mutex
+	clients map[uint32]GUIClient
+}
+
+// observe applies a "client 0x... connected|disconnected ..." status body. It
+// reports whether the list changed.
+func (t *clientTable) observe(body string) bool {
+	rest, ok := strings.CutPrefix(body, "client ")
+	if !ok {
+		return false
+	}
+
+	id, attrs, _ := strings.Cut(rest, " ")
+
+	handle := parseHex32(id)
+	if handle == 0 {
+		return false
+	}
+
+	f := statusAttrs(attrs)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, gone := f["disconnected"]; gone {
+		_, known := t.clients[handle]
+		delete(t.clients, handle)
+
+		return known
+	}
+
+	if t.clients == nil {
+		t.clients = make(map[uint32]GUIClient)
+	}
+
+	old, known := t.clients[handle]
+	c := old
+	c.Handle = fmt.Sprintf("0x%08X", handle)
+
+	if v, ok := f["client_id"]; ok {
+		c.ClientID = v
+	}
+
+	if v, ok := f["program"]; ok {
+		c.Program = strings.ReplaceAll(v, "\x7f", " ")
+	}
+
+	if v, ok := f["station"]; ok {
+		c.Station = strings.ReplaceAll(v, "\x7f", " ")
+	}
+
+	if v, ok := f["local_ptt"]; ok {
+		c.LocalPTT = v == "1"
+	}
+
+	t.clients[handle] = c
+
+	return !known || c != old
+}
+
+// observeClients tracks the radio's GUI clients from a status body.
+func (rc *radioConn) observeClients(body string) {
+	if !rc.clients.observe(body) {
+		return
+	}
+
+	rc.mu.RLock()
+	onClients := rc.onClients
+	rc.mu.RUnlock()
+
+	if onClients != nil {
+		onClients()
+	}
+}
+
+// list returns the clients, in handle order, marking self's.
+func (t *clientTable) list(self uint32) []GUIClient {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]GUIClient, 0, len(t.clients))
+	for h, c := range t.clients {
+		c.Self = h == self
+		out = append(out, c)
+	}
+
+	slices.SortFunc(out, func(a, b GUIClient) int { return strings.Compare(a.Handle, b.Handle) })
+
+	return out
+}
+
+// clientID returns the GUI client ID of handle, if it has registered one.
+func (t *clientTable) clientID(handle uint32) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.clients[handle].ClientID
+}
+
+// clientCommand sends a client registration or binding command and waits for
+// the reply, returning its message, e.g. the client_id "client gui" assigns.
+// Only one is in flight per radio.
+func (rc *radioConn) clientCommand(body string) (string, error) {
+	rc.clientMu.Lock()
+	defer rc.clientMu.Unlock()
+
+	reply := make(chan string, 1)
+
+	rc.mu.Lock()
+	rc.clientReply = reply
+	rc.mu.Unlock()
+
+	defer func() {
+		rc.mu.Lock()
+		rc.clientReply = nil
+		rc.mu.Unlock()
+	}()
+
+	err := rc.writeTCPString(fmt.Sprintf("C%d|%s\n", internalClientSequence, body))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", body, err)
+	}
+
+	select {
+	case res := <-reply:
+		// R<seq>|<code>|<message>
+		parts := strings.SplitN(res, "|", 3)
+		if len(parts) < 2 {
+			return "", fmt.Errorf("%s: %w: %q", body, errClientRefused, res)
+		}
+
+		code, err := strconv.ParseUint(parts[1], 16, 32)
+		if err != nil || code != 0 {
+			return "", fmt.Errorf("%s: %w: %s", body, errClientRefused, parts[1])
+		}
+
+		if len(parts) == 3 {
+			return parts[2], nil
+		}
+
+		return "", nil
+	case <-time.After(clientReplyTimeout):
+		return "", fmt.Errorf("%s: %w", body, errClientTimeout)
+	}
+}
+
+// consumeClientReply hands the reply to a client command to its waiter.
+func (rc *radioConn) consumeClientReply(line string) bool {
+	if !strings.HasPrefix(line, fmt.Sprintf("R%d|", internalClientSequence)) {
+		return false
+	}
+
+	rc.mu.RLock()
+	reply := rc.clientReply
+	rc.mu.RUnlock()
+
+	if reply != nil {
+		select {
+		case reply <- line:
+		default:
+		}
+	}
+
+	return true
+}
+
+// registerGUI makes the connection a GUI client named as id, at station
+// unless id sets one, returning the client_id the radio assigned.
+func (rc *radioConn) registerGUI(id ClientIdentity, station string) (string, error) {
+	clientID, err := rc.clientCommand("client gui")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = rc.clientCommand("client program " + id.program())
+	if err != nil {
+		return "", err
+	}
+
+	_, err = rc.clientCommand("client station " + clientValue(cmp.Or(id.Station, station)))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(clientID), nil
+}
+
+// subscribeClients asks the radio for "client" status, once per connection.
+func (rc *radioConn) subscribeClients() error {
+	rc.mu.Lock()
+	done := rc.clientsSubscribed
+	rc.clientsSubscribed = true
+	rc.mu.Unlock()
+
+	if done {
+		return nil
+	}
+
+	_, err := rc.clientCommand("sub client all")
+	if err != nil {
+		rc.mu.Lock()
+		rc.clientsSubscribed = false
+		rc.mu.Unlock()
+	}
+
+	return err
+}
+
+// bindGUI binds the connection to the GUI client clientID.
+func (rc *radioConn) bindGUI(clientID string) error {
+	_, err := rc.clientCommand("client bind client_id=" + clientID)
+	if err != nil {
+		return err
+	}
+
+	rc.mu.Lock()
+	rc.boundClient = clientID
+	rc.mu.Unlock()
+
+	return nil
+}
+
+func (rc *radioConn) guiClients() guiClientsPayload {
+	rc.mu.RLock()
+	self, bound := rc.handleU32, rc.boundClient
+	rc.mu.RUnlock()
+
+	return guiClientsPayload{Clients: rc.clients.list(self), Bound: bound}
+}
+
+func (cs *clientSession) handleGUIClient(raw json.RawMessage) {
+	var req guiClientRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	switch {
+	case rc == nil:
+		err = errClientNoRadio
+	case req.Action == "list":
+		cs.guiClients.Store(true)
+		err = rc.subscribeClients()
+	case req.Action == "bind" && req.ClientID == "":
+		err = errClientID
+	case req.Action == "bind":
+		err = rc.bindGUI(req.ClientID)
+	default:
+		err = fmt.Errorf("%w %q", errClientAction, req.Action)
+	}
+
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "GUI_CLIENT_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeGUIClients, rc.guiClients()))
+}
+
+// guiClientsChanged updates a session that asked for the list.
+func (cs *clientSession) guiClientsChanged(rc *radioConn) {
+	if cs.guiClients.Load() {
+		cs.trySend(mustEncode(typeGUIClients, rc.guiClients()))
+	}
+}
@@ -0,0 +1,127 @@
+package rtc
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestClientTable_Observe(t *testing.T) {
+	t.Parallel()
+
+	var tbl clientTable
+
+	if !tbl.observe("client 0x1234ABCD connected local_ptt=1 client_id=A1B2 program=SmartSDR-Win station=Shack\x7fPC") {
+		t.Error("a new client is not a change")
+	}
+
+	if tbl.observe("client 0x1234ABCD connected local_ptt=1") {
+		t.Error("a repeated status is a change")
+	}
+
+	tbl.observe("client 0x00000042 connected client_id=C3D4 program=Maestro station=Kitchen")
+
+	got := tbl.list(0x42)
+	if len(got) != 2 {
+		t.Fatalf("clients = %+v", got)
+	}
+
+	want := GUIClient{Handle: "0x00000042", ClientID: "C3D4", Program: "Maestro", Station: "Kitchen", Self: true}
+	if got[0] != want {
+		t.Errorf("first = %+v, want %+v", got[0], want)
+	}
+
+	if got[1].Station != "Shack PC" || !got[1].LocalPTT || got[1].Self {
+		t.Errorf("second = %+v", got[1])
+	}
+
+	if tbl.clientID(0x1234ABCD) != "A1B2" {
+		t.Errorf("clientID = %q", tbl.clientID(0x1234ABCD))
+	}
+
+	if !tbl.observe("client 0x1234ABCD disconnected forced=0") || len(tbl.list(0)) != 1 {
+		t.Error("disconnect not applied")
+	}
+
+	if tbl.observe("slice 0 RF_frequency=14.074") || tbl.observe("client 0x00000000 connected") {
+		t.Error("not a client status")
+	}
+}
+
+func TestRadioConn_RegisterAndBind(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{handleHex: "1234ABCD"}
+	rc.out = newTCPWriter(client, func([]byte) {}, nil)
+	t.Cleanup(rc.out.close)
+
+	lines := make(chan string, 8)
+
+	// The radio assigns a client_id and refuses a bind to an unknown one.
+	go func() {
+		rd := bufio.NewReader(radio)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimSpace(line)
+			lines <- line
+
+			switch {
+			case strings.HasSuffix(line, "|client gui"):
+				rc.consumeClientReply("R2147483640|0|A1B2-C3D4")
+			case strings.Contains(line, "client_id=nope"):
+				rc.consumeClientReply("R2147483640|500000A3|")
+			default:
+				rc.consumeClientReply("R2147483640|0|")
+			}
+		}
+	}()
+
+	id, err := rc.registerGUI(ClientIdentity{Version: "v1.2.3"}, "Shack PC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "A1B2-C3D4" {
+		t.Errorf("client_id = %q", id)
+	}
+
+	for _, want := range []string{
+		"C2147483640|client gui",
+		"C2147483640|client program solid-sdr/v1.2.3",
+		"C2147483640|client station Shack\x7fPC",
+	} {
+		if l := nextLine(t, lines); l != want {
+			t.Errorf("sent %q, want %q", l, want)
+		}
+	}
+
+	err = rc.bindGUI("E5F6")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, lines); l != "C2147483640|client bind client_id=E5F6" {
+		t.Errorf("sent %q", l)
+	}
+
+	if !errors.Is(rc.bindGUI("nope"), errClientRefused) {
+		t.Error("refused bind succeeded")
+	}
+
+	if rc.guiClients().Bound != "E5F6" {
+		t.Errorf("bound = %q", rc.guiClients().Bound)
+	}
+
+	if rc.consumeClientReply("R2147483641|0|") {
+		t.Error("consumed another sequence's reply")
+	}
+}
@@ -0,0 +1,79 @@
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ReadyPath is where a bridge reports whether it accepts new sessions. A
+// draining bridge checks its peer's before handing clients over.
+const ReadyPath = "/api/ready"
+
+const peerCheckTimeout = 3 * time.Second
+
+var (
+	errBadPeer      = errors.New("peer must be an http(s) base URL")
+	errPeerNotReady = errors.New("peer is not accepting sessions")
+)
+
+type readyPayload struct {
+	Ready       bool   `json:"ready"`
+	Version     string `json:"version"`
+	Sessions    int    `json:"sessions"`
+	MaxSessions int    `json:"maxSessions,omitempty"`
+}
+
+// ReadyHandler serves ReadyPath: 200 when new sessions are accepted, 503
+// while draining or at the session limit.
+func (s *Server) ReadyHandler(w http.ResponseWriter, _ *http.Request) {
+	p := readyPayload{Version: s.version, Sessions: s.sessionCount(), MaxSessions: s.maxSessions}
+	p.Ready = !s.draining.Load() && (s.maxSessions == 0 || p.Sessions < s.maxSessions)
+
+	status := http.StatusOK
+	if !p.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// CheckPeer verifies that the bridge at peer (a base URL) is up and accepting
+// sessions, so clients are not handed to an instance that will refuse them.
+func CheckPeer(ctx context.Context, peer string) error {
+	u, err := url.Parse(peer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q", errBadPeer, peer)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, peerCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(peer, "/")+ReadyPath, nil)
+	if err != nil {
+		return fmt.Errorf("peer check: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("peer check: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var p readyPayload
+
+	err = json.NewDecoder(resp.Body).Decode(&p)
+	if err != nil || resp.StatusCode != http.StatusOK || !p.Ready {
+		return fmt.Errorf("%w: %s", errPeerNotReady, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// headlessBuffer is how many radio lines, or UDP packets, a headless client
+// may fall behind by before its session is closed. A browser's data channels
+// buffer without limit; a native client that cannot keep up must reconnect
+// rather than silently miss status.
+const headlessBuffer = 4096
+
+var (
+	errHeadlessBehind = errors.New("client is not keeping up with the radio")
+	errHeadlessSignal = errors.New("signal not available without WebRTC")
+)
+
+// headlessSignals are the signaling messages a headless client may send; the
+// rest negotiate WebRTC, which it has none of.
+var headlessSignals = map[string]bool{
+	typePing:           true,
+	typeVersion:        true,
+	typeCommandConfirm: true,
+	typeAudioGroup:     true,
+	typeSandbox:        true,
+	typeRecording:      true,
+	typeWSJTX:          true,
+	typePTT:            true,
+	typeTXToken:        true,
+	typeGUIClient:      true,
+	typeStreams:        true,
+	typeProfile:        true,
+	typeMixer:          true,
+	typeAntenna:        true,
+	typeAmplifier:      true,
+	typeGPS:            true,
+	typeLogbook:        true,
+	typeSpots:          true,
+	typeHistory:        true,
+	typeMeters:         true,
+}
+
+// HeadlessOptions describes a headless session.
+type HeadlessOptions struct {
+	// Radio is host:port of the radio's API.
+	Radio    string
+	ClientIP string
+	// PeerIP is the address the client connected from, which its role is
+	// resolved by; ClientIP if empty.
+	PeerIP string
+	// UDP receives the radio's UDP streams.
+	UDP     bool
+	Sandbox bool
+	// Station, when set, registers the session as a multiFLEX GUI client
+	// with that station name.
+	Station string
+	// BindClientID binds the session to a GUI client, by its client_id.
+	BindClientID string
+}
+
+// Notice is a signaling message, as a browser gets it on the WebSocket.
+type Notice = message
+
+// Packet is a VITA-49 packet from the radio other than audio.
+type Packet struct {
+	ClassCode uint16
+	StreamID  uint32
+	Payload   []byte
+	// Raw is the whole packet, as the "udp" data channel carries it.
+	Raw []byte
+}
+
+// Headless is a session without a browser: a native client driving a radio
+// through the bridge. It is listed, guarded, sandboxed and drained like any
+// other session; lines, packets and audio the data channels and WebRTC track
+// would carry are delivered on channels instead.
+type Headless struct {
+	cs *clientSession
+	rc *radioConn
+
+	lines   chan string
+	packets chan Packet
+	audio   chan []byte
+	done    chan struct{}
+
+	clientID string
+
+	stopOnce sync.Once
+}
+
+// OpenHeadless admits a session as the signaling endpoint would and connects
+// it to opt.Radio. The session ends when ctx is done or Close is called.
+func (s *Server) OpenHeadless(ctx context.Context, opt HeadlessOptions) (*Headless, error) {
+	if opt.Radio == "" {
+		return nil, &preflightError{http.StatusBadRequest, errorPayload{
+			Code: "BAD_RADIO_ADDR", Message: "radio must be host:port",
+		}}
+	}
+
+	opt.Radio = canonicalRadio(opt.Radio)
+
+	if perr := s.admit(ctx, opt.Radio); perr != nil {
+		log.Printf("[rtc] headless session from %s rejected: %s", opt.ClientIP, perr.body.Code)
+
+		return nil, perr
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	if opt.PeerIP == "" {
+		opt.PeerIP = opt.ClientIP
+	}
+
+	cs := newClientSession(s, nil, cancel, opt.ClientIP, opt.PeerIP)
+	h := &Headless{
+		cs:      cs,
+		lines:   make(chan string, headlessBuffer),
+		packets: make(chan Packet, headlessBuffer),
+		done:    make(chan struct{}),
+	}
+
+	rc, err := newRadioConn(ctx, h, opt.Radio, s.capture,
+		cs.reportServerToRadioDiagnostics, cs.reportTXEvent, func(err error) { h.fail(err) })
+	if err != nil {
+		cancel()
+
+		return nil, fmt.Errorf("headless session: %w", err)
+	}
+
+	h.rc = rc
+
+	rc.mu.Lock()
+	rc.recorder = s.recorder
+	rc.fair = s.fair
+	rc.flow = s.fair.join(rc.addr, cs.role)
+	rc.udpSink = h.packet
+	rc.onStream = cs.radioStream
+	rc.onClients = func() { cs.guiClientsChanged(rc) }
+	rc.onProfiles = func() { cs.profilesChanged(rc) }
+	rc.onMixer = func() { cs.mixerChanged(rc) }
+	rc.onAmplifiers = func() { cs.amplifiersChanged(rc) }
+	rc.onGPS = func() { cs.gpsChanged(rc) }
+	rc.audio = s.radioAudio(rc.addr)
+	rc.antennas = s.antennas
+	rc.logbook = s.logbook
+	rc.history = s.history
+	rc.meterAlerts = s.alerts
+	rc.onMeterAlert = cs.reportMeterAlert
+	rc.onHealth = cs.reportRadioHealth
+	rc.timeout = s.radioTimeout
+	rc.udpOptions = s.udpPipeline
+	rc.udpPorts = s.udpPorts
+	rc.mu.Unlock()
+
+	cs.mu.Lock()
+	cs.radio = rc
+	cs.mu.Unlock()
+
+	cs.auditRadio(rc)
+
+	if opt.UDP {
+		err = rc.openUDP(nil, opt.Radio)
+		if err != nil {
+			cancel()
+			rc.close()
+
+			return nil, fmt.Errorf("headless session: %w", err)
+		}
+
+		h.audio = rc.listeners.subscribe()
+		startUDPDemux(rc, nil)
+	}
+
+	if opt.Sandbox {
+		cs.setSandbox(true)
+	}
+
+	if opt.Station != "" {
+		h.clientID, err = rc.registerGUI(s.identity, opt.Station)
+	}
+
+	if err == nil && opt.BindClientID != "" {
+		err = rc.bindGUI(opt.BindClientID)
+	}
+
+	if err != nil {
+		cancel()
+		rc.close()
+
+		return nil, fmt.Errorf("headless session: %w", err)
+	}
+
+	s.addSession(cs)
+	s.bindRadio(cs, rc)
+	log.Printf("[rtc] headless session %s from %s on %s", cs.id, cs.clientIP, rc.key)
+
+	go func() {
+		<-ctx.Done()
+		h.stop()
+	}()
+
+	return h, nil
+}
+
+// ID is the session's ID, as listed by Sessions.
+func (h *Headless) ID() string { return h.cs.id }
+
+// Handle is the radio's client handle for the session, e.g. "0x1234ABCD".
+func (h *Headless) Handle() string { return "0x" + h.rc.handleHex }
+
+// ClientID is the client_id the radio assigned when the session registered
+// as a GUI client, or "".
+func (h *Headless) ClientID() string { return h.clientID }
+
+// Lines delivers every line from the radio, starting with its version and
+// handle, until Done.
+func (h *Headless) Lines() <-chan string { return h.lines }
+
+// Packets delivers the radio's UDP packets other than audio, until Done.
+func (h *Headless) Packets() <-chan Packet { return h.packets }
+
+// Audio delivers the Opus packets of the session's remote_audio_rx stream.
+// It is nil without UDP and closed when the session ends.
+func (h *Headless) Audio() <-chan []byte { return h.audio }
+
+// Notices delivers the signaling messages a browser would get, such as
+// "commandGuard" prompts and "txEvent"s.
+func (h *Headless) Notices() <-chan Notice { return h.cs.send }
+
+// Done is closed when the session has ended.
+func (h *Headless) Done() <-chan struct{} { return h.done }
+
+// Command sends one command line, e.g. "C12|slice list", through the
+// sandbox and the command guard to the radio.
+func (h *Headless) Command(line string) error {
+	return h.cs.forwardCommand(h.rc, []byte(line+"\n"))
+}
+
+// SendUDP sends a VITA-49 packet to the radio's UDP port, as the "udp" data
+// channel does. It fails without UDP.
+func (h *Headless) SendUDP(p []byte) error {
+	return h.rc.writeUDP(p)
+}
+
+// SendAudio sends an Opus packet on the session's remote_audio_tx stream, as
+// the browser's microphone track does. It is dropped while there is none,
+// and fails without UDP.
+func (h *Headless) SendAudio(opus []byte) error {
+	return h.rc.sendTXAudio(opus)
+}
+
+// Signal handles a signaling message as the WebSocket would.
+func (h *Headless) Signal(msgType string, payload json.RawMessage) error {
+	if !headlessSignals[msgType] {
+		return fmt.Errorf("%w: %q", errHeadlessSignal, msgType)
+	}
+
+	h.cs.dispatch(context.Background(), message{Type: msgType, Payload: payload})
+
+	return nil
+}
+
+// Close ends the session and releases the radio's client handle.
+func (h *Headless) Close() {
+	h.cs.cancel()
+	<-h.done
+}
+
+// SendText takes a line from the radio in place of the "tcp" data channel.
+func (h *Headless) SendText(line string) error {
+	select {
+	case h.lines <- line:
+		return nil
+	default:
+		h.fail(errHeadlessBehind)
+
+		return errHeadlessBehind
+	}
+}
+
+func (h *Headless) packet(p []byte) {
+	v, err := parseVITA(p)
+	if err != nil {
+		return
+	}
+
+	pkt := Packet{
+		ClassCode: v.ClassCode,
+		StreamID:  v.StreamID,
+		Payload:   append([]byte(nil), v.Payload...),
+		Raw:       append([]byte(nil), p...),
+	}
+
+	select {
+	case h.packets <- pkt:
+	default:
+		h.fail(errHeadlessBehind)
+	}
+}
+
+func (h *Headless) fail(err error) {
+	log.Printf("[rtc] headless session %s: %v", h.cs.id, err)
+	h.cs.cancel()
+}
+
+// stop tears the session down once its context is done. The radio is told
+// to drop our handle, as a browser's session is when the bridge drains.
+func (h *Headless) stop() {
+	h.stopOnce.Do(func() {
+		h.cs.srv.removeSession(h.cs)
+		h.cs.dropHeld()
+		h.cs.releaseStreams()
+
+		h.cs.mu.Lock()
+		h.cs.radio = nil
+		h.cs.mu.Unlock()
+
+		h.rc.disconnect()
+
+		log.Printf("[rtc] headless session %s closed", h.cs.id)
+		close(h.done)
+	})
+}
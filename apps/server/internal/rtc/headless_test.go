@@ -0,0 +1,114 @@
+package rtc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc/rtctest"
+)
+
+func nextLine(t *testing.T, ch <-chan string) string {
+	t.Helper()
+
+	select {
+	case l := <-ch:
+		return strings.TrimSpace(l)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+
+		return ""
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	t.Parallel()
+
+	addr, got := rtctest.FakeRadio(t)
+	srv := &Server{sessions: make(map[string]*clientSession)}
+
+	h, err := srv.OpenHeadless(t.Context(), HeadlessOptions{Radio: addr, ClientIP: "10.0.0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Handle() != "0x1234ABCD" {
+		t.Errorf("handle = %s", h.Handle())
+	}
+
+	if l := nextLine(t, h.Lines()); l != "V1.4.0.0" {
+		t.Errorf("first line = %q", l)
+	}
+
+	if l := nextLine(t, h.Lines()); l != "H1234ABCD" {
+		t.Errorf("second line = %q", l)
+	}
+
+	if s := srv.Sessions(); len(s) != 1 || s[0].ClientIP != "10.0.0.5" || s[0].Radio != addr {
+		t.Errorf("sessions = %+v", s)
+	}
+
+	err = h.Command("C7|slice list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, got); l != "C7|slice list" {
+		t.Errorf("radio got %q", l)
+	}
+
+	if l := nextLine(t, h.Lines()); l != "R7|0|" {
+		t.Errorf("reply = %q", l)
+	}
+
+	// Sandboxed, TX never reaches the radio.
+	err = h.Signal(typeSandbox, []byte(`{"enabled":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := range h.Notices() {
+		if n.Type == typeSandbox {
+			break
+		}
+	}
+
+	_ = h.Command("C8|xmit 1")
+
+	if l := nextLine(t, h.Lines()); !strings.HasPrefix(l, "R8|0|") {
+		t.Errorf("sandboxed reply = %q", l)
+	}
+
+	if !errors.Is(h.Signal(typeOffer, nil), errHeadlessSignal) {
+		t.Error("offer accepted without WebRTC")
+	}
+
+	h.Close()
+
+	if l := nextLine(t, got); l != "C2147483645|client disconnect 0x1234ABCD" {
+		t.Errorf("radio got %q on close", l)
+	}
+
+	if len(srv.Sessions()) != 0 {
+		t.Error("session listed after close")
+	}
+}
+
+func TestHeadless_Admission(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{sessions: make(map[string]*clientSession), allowedRadios: []string{"10.0.0.1"}}
+
+	_, err := srv.OpenHeadless(t.Context(), HeadlessOptions{Radio: "10.0.0.2:4992"})
+
+	var perr interface{ HTTPStatus() int }
+	if !errors.As(err, &perr) || perr.HTTPStatus() != 403 {
+		t.Errorf("disallowed radio = %v", err)
+	}
+
+	_, err = srv.OpenHeadless(t.Context(), HeadlessOptions{})
+	if !errors.As(err, &perr) || perr.HTTPStatus() != 400 {
+		t.Errorf("no radio = %v", err)
+	}
+}
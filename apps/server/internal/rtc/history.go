@@ -0,0 +1,142 @@
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/history"
+)
+
+// historyTimeout bounds a client's history query.
+const historyTimeout = 10 * time.Second
+
+var (
+	errHistoryDisabled = errors.New("activity history is not enabled")
+	errHistoryAction   = errors.New("unknown history action")
+)
+
+// historyRequest is a client's "history" message. Action is "at", for where
+// each slice was tuned at Time, "events", for the activity from From to To,
+// or "heatmap", for that activity summed by band into Bucket-wide buckets,
+// e.g. "1h". Radio defaults to the session's.
+type historyRequest struct {
+	Action string    `json:"action"`
+	Radio  string    `json:"radio,omitempty"`
+	Time   time.Time `json:"time,omitzero"`
+	From   time.Time `json:"from,omitzero"`
+	To     time.Time `json:"to,omitzero"`
+	Bucket string    `json:"bucket,omitempty"`
+	Limit  int       `json:"limit,omitempty"`
+}
+
+// historyPayload answers a history request with Events or Cells.
+type historyPayload struct {
+	Action string          `json:"action"`
+	Events []history.Event `json:"events,omitempty"`
+	Cells  []history.Cell  `json:"cells,omitempty"`
+}
+
+func (cs *clientSession) handleHistory(raw json.RawMessage) {
+	var req historyRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	if req.Radio == "" {
+		cs.mu.Lock()
+		if cs.radio != nil {
+			req.Radio = cs.radio.addr
+		}
+		cs.mu.Unlock()
+	}
+
+	// Queries can take a moment on a long history; the session's other
+	// messages needn't wait.
+	go func() {
+		p, err := cs.srv.queryHistory(req)
+		if err != nil {
+			cs.trySend(mustEncode(typeError, errorPayload{Code: "HISTORY_FAILED", Message: err.Error()}))
+
+			return
+		}
+
+		cs.trySend(mustEncode(typeHistory, p))
+	}()
+}
+
+func (s *Server) queryHistory(req historyRequest) (historyPayload, error) {
+	if s.history == nil {
+		return historyPayload{}, errHistoryDisabled
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+
+	p := historyPayload{Action: req.Action}
+
+	var err error
+
+	switch req.Action {
+	case "at":
+		p.Events, err = s.history.At(ctx, req.Radio, req.Time)
+	case "events":
+		p.Events, err = s.history.Events(ctx, req.Radio, req.From, req.To, req.Limit)
+	case "heatmap":
+		var bucket time.Duration
+
+		bucket, err = time.ParseDuration(req.Bucket)
+		if err == nil {
+			p.Cells, err = s.history.Heatmap(ctx, req.Radio, req.From, req.To, bucket)
+		}
+	default:
+		err = fmt.Errorf("%w %q", errHistoryAction, req.Action)
+	}
+
+	if err != nil {
+		return historyPayload{}, fmt.Errorf("history: %w", err)
+	}
+
+	return p, nil
+}
+
+// reportHistory records the radio's slices and transmissions after a slice
+// or interlock status body.
+func (rc *radioConn) reportHistory(body string) {
+	rc.mu.RLock()
+	store := rc.history
+	transmitting := rc.interlockState == "TRANSMITTING"
+	rc.mu.RUnlock()
+
+	if store == nil {
+		return
+	}
+
+	now := time.Now()
+
+	switch {
+	case strings.HasPrefix(body, "slice "):
+		for _, s := range rc.info.savedSlices() {
+			if s.Letter != "" {
+				store.Tune(rc.addr, s.Letter, mhzToHz(s.FreqMHz), s.Mode, now)
+			}
+		}
+	case strings.HasPrefix(body, "interlock "):
+		_, tx, ok := rc.info.focus()
+		if ok {
+			store.Transmit(rc.addr, transmitting, mhzToHz(tx.freqMHz), tx.mode, now)
+		}
+	}
+}
+
+func mhzToHz(mhz float64) int64 {
+	return int64(math.Round(mhz * 1e6))
+}
@@ -0,0 +1,342 @@
+package rtc
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/hls"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// AudioPath serves a session's RX audio over plain HTTP for clients that
+// cannot do WebRTC:
+//
+//	/audio/<session>.ogg             chunked Ogg/Opus, for as long as the session lasts
+//	/audio/<session>/index.m3u8      HLS (fMP4) playlist; init.mp4 and <n>.m4s beside it
+//
+// Like WHEP listeners, these hear whatever the session's operator hears.
+const AudioPath = "/audio/"
+
+const (
+	// audioSubscriberBuffer is how many packets (about a second) a slow HTTP
+	// client may lag before packets are dropped for it.
+	audioSubscriberBuffer = 100
+	// hlsIdle stops an HLS segmenter nobody has polled for this long.
+	hlsIdle = 30 * time.Second
+)
+
+// audioFanout copies RX Opus packets to HTTP listeners. Each subscriber has
+// its own buffer; a full one drops packets rather than stalling the demux.
+type audioFanout struct {
+	mu     sync.Mutex
+	subs   map[chan []byte]struct{}
+	closed bool
+}
+
+// subscribe returns a channel of packets, closed when the radio link closes.
+// It returns nil if the link is already closed.
+func (f *audioFanout) subscribe() chan []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	if f.subs == nil {
+		f.subs = make(map[chan []byte]struct{})
+	}
+
+	ch := make(chan []byte, audioSubscriberBuffer)
+	f.subs[ch] = struct{}{}
+
+	return ch
+}
+
+func (f *audioFanout) unsubscribe(ch chan []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subs[ch]; ok {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
+
+func (f *audioFanout) publish(payload []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.subs) == 0 {
+		return
+	}
+
+	pkt := append([]byte(nil), payload...)
+	for ch := range f.subs {
+		select {
+		case ch <- pkt:
+		default:
+		}
+	}
+}
+
+func (f *audioFanout) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for ch := range f.subs {
+		close(ch)
+	}
+
+	f.subs = nil
+}
+
+// tapAudio hands an RX Opus packet to everything besides the session's own
+// WebRTC track that consumes it.
+func (rc *radioConn) tapAudio(payload []byte) {
+	rc.tapCaptions(payload)
+	rc.listeners.publish(payload)
+}
+
+// ServeAudio handles GET AudioPath{file} and AudioPath{session}/{file}.
+func (s *Server) ServeAudio(w http.ResponseWriter, r *http.Request) {
+	session, file := r.PathValue("session"), r.PathValue("file")
+
+	if session == "" {
+		id, ok := strings.CutSuffix(file, ".ogg")
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		s.serveOgg(w, r, id)
+
+		return
+	}
+
+	s.serveHLS(w, r, session, file)
+}
+
+// audioSource returns the radio link carrying a session's audio.
+func (s *Server) audioSource(w http.ResponseWriter, id string) *radioConn {
+	cs := s.session(id)
+	if cs == nil {
+		writeHTTPError(w, http.StatusNotFound, "NO_SESSION", "no such session")
+
+		return nil
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		writeHTTPError(w, http.StatusConflict, "NO_RADIO", "session has no radio connection")
+
+		return nil
+	}
+
+	return rc
+}
+
+func (s *Server) serveOgg(w http.ResponseWriter, r *http.Request, id string) {
+	rc := s.audioSource(w, id)
+	if rc == nil {
+		return
+	}
+
+	ch := rc.listeners.subscribe()
+	if ch == nil {
+		writeHTTPError(w, http.StatusConflict, "NO_RADIO", "session has no radio connection")
+
+		return
+	}
+	defer rc.listeners.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "audio/ogg")
+	w.Header().Set("Cache-Control", "no-store")
+
+	rcw := http.NewResponseController(w)
+	out := flushWriter{w: w, rc: rcw}
+
+	ogg, err := oggwriter.NewWith(out, 48000, 2)
+	if err != nil {
+		log.Printf("[rtc] ogg stream: %v", err)
+
+		return
+	}
+
+	log.Printf("[rtc] HTTP audio listener %s on session %s", clientIPFromRequest(r), id)
+
+	ev := StreamEvent{Session: id, Kind: StreamHTTPAudio, ClientIP: clientIPFromRequest(r)}
+	s.publishStream(true, ev)
+
+	defer s.publishStream(false, ev)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case pkt, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			err := ogg.WriteRTP(&rtp.Packet{Payload: pkt})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// flushWriter pushes every Ogg page to the client as soon as it is written.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err //nolint:wrapcheck // pass the client's write error through
+	}
+
+	_ = f.rc.Flush()
+
+	return n, nil
+}
+
+// hlsFeed segments one session's audio while HLS players are polling it.
+type hlsFeed struct {
+	seg  hls.Segmenter
+	seen atomic.Int64 // unix nanoseconds of the last request
+}
+
+func (s *Server) serveHLS(w http.ResponseWriter, r *http.Request, id, file string) {
+	feed := s.hlsFeedFor(w, id)
+	if feed == nil {
+		return
+	}
+
+	feed.seen.Store(time.Now().UnixNano())
+
+	switch {
+	case file == hls.PlaylistName:
+		pl, ok := feed.seg.Playlist()
+		if !ok {
+			// The first segment is still being cut; players retry.
+			w.Header().Set("Retry-After", "1")
+			writeHTTPError(w, http.StatusServiceUnavailable, "HLS_STARTING", "first segment is not ready yet")
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = io.WriteString(w, pl)
+	case file == hls.InitName:
+		w.Header().Set("Content-Type", "video/mp4")
+		_, _ = w.Write(hls.InitSegment())
+	case strings.HasSuffix(file, ".m4s"):
+		seq, err := strconv.ParseUint(strings.TrimSuffix(file, ".m4s"), 10, 32)
+		if err != nil {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		data, ok := feed.seg.Segment(uint32(seq))
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "video/iso.segment")
+		_, _ = w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// hlsFeedFor returns the session's running segmenter, starting one on first use.
+func (s *Server) hlsFeedFor(w http.ResponseWriter, id string) *hlsFeed {
+	s.hlsMu.Lock()
+	defer s.hlsMu.Unlock()
+
+	if feed := s.hls[id]; feed != nil {
+		return feed
+	}
+
+	rc := s.audioSource(w, id)
+	if rc == nil {
+		return nil
+	}
+
+	ch := rc.listeners.subscribe()
+	if ch == nil {
+		writeHTTPError(w, http.StatusConflict, "NO_RADIO", "session has no radio connection")
+
+		return nil
+	}
+
+	if s.hls == nil {
+		s.hls = make(map[string]*hlsFeed)
+	}
+
+	feed := &hlsFeed{}
+	feed.seen.Store(time.Now().UnixNano())
+	s.hls[id] = feed
+
+	log.Printf("[rtc] HLS started for session %s", id)
+	s.publishStream(true, StreamEvent{Session: id, Kind: StreamHLS})
+
+	go s.runHLS(id, feed, rc, ch)
+
+	return feed
+}
+
+// runHLS feeds the segmenter until the radio link closes or players stop
+// polling.
+func (s *Server) runHLS(id string, feed *hlsFeed, rc *radioConn, ch chan []byte) {
+	defer func() {
+		rc.listeners.unsubscribe(ch)
+
+		s.hlsMu.Lock()
+		if s.hls[id] == feed {
+			delete(s.hls, id)
+		}
+		s.hlsMu.Unlock()
+
+		log.Printf("[rtc] HLS stopped for session %s", id)
+		s.publishStream(false, StreamEvent{Session: id, Kind: StreamHLS})
+	}()
+
+	idle := time.NewTicker(hlsIdle / 3)
+	defer idle.Stop()
+
+	for {
+		select {
+		case pkt, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			feed.seg.Write(pkt, opusDuration(pkt))
+		case now := <-idle.C:
+			if now.Sub(time.Unix(0, feed.seen.Load())) > hlsIdle {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package rtc
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAudioFanout(t *testing.T) {
+	t.Parallel()
+
+	var f audioFanout
+
+	f.publish([]byte{1}) // no subscribers
+
+	a, b := f.subscribe(), f.subscribe()
+	f.unsubscribe(b)
+
+	f.publish([]byte{2})
+
+	if got := <-a; len(got) != 1 || got[0] != 2 {
+		t.Errorf("got %v, want [2]", got)
+	}
+
+	if _, ok := <-b; ok {
+		t.Error("unsubscribed channel should be closed")
+	}
+
+	f.close()
+
+	if _, ok := <-a; ok {
+		t.Error("close should close every subscriber")
+	}
+
+	if f.subscribe() != nil {
+		t.Error("subscribe after close should fail")
+	}
+}
+
+func TestServeAudio_Ogg(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+	rc := &radioConn{}
+	s.addSession(&clientSession{id: "op", srv: s, radio: rc})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+AudioPath+"{file}", s.ServeAudio)
+	mux.HandleFunc("GET "+AudioPath+"{session}/{file}", s.ServeAudio)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	get := func(path string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return http.DefaultClient.Do(req)
+	}
+
+	resp, err := get(AudioPath + "nope.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session: %d, want 404", resp.StatusCode)
+	}
+
+	resp, err = get(AudioPath + "op.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "audio/ogg" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// The Opus headers arrive before any audio, then pages as packets come in.
+	br := bufio.NewReader(resp.Body)
+
+	magic := make([]byte, 4)
+
+	_, err = io.ReadFull(br, magic)
+	if err != nil || string(magic) != "OggS" {
+		t.Fatalf("stream starts with %q (%v), want OggS", magic, err)
+	}
+
+	go func() {
+		for range 50 {
+			rc.tapAudio([]byte{0x80, 0xff, 0xfe})
+			time.Sleep(time.Millisecond)
+		}
+
+		rc.listeners.close()
+	}()
+
+	rest, _ := io.ReadAll(br)
+	if len(rest) < 50*3 {
+		t.Errorf("read %d bytes of audio, want at least the packets", len(rest))
+	}
+}
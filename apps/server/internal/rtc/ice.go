@@ -0,0 +1,61 @@
+package rtc
+
+import (
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/dscp"
+	"github.com/pion/ice/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+// iceTCPWriteBuffer is what each ICE-TCP connection may queue before its
+// packets are dropped, as UDP's would be.
+const iceTCPWriteBuffer = 4 << 20
+
+// TURNServer is a TURN relay, e.g. "turns:turn.example.com:443?transport=tcp"
+// for TURN over TLS on 443, which networks that allow nothing else but web
+// traffic let through.
+type TURNServer struct {
+	URLs       []string
+	Username   string
+	Credential string
+}
+
+func (t TURNServer) iceServer() webrtc.ICEServer {
+	return webrtc.ICEServer{
+		URLs:           t.URLs,
+		Username:       t.Username,
+		Credential:     t.Credential,
+		CredentialType: webrtc.ICECredentialTypePassword,
+	}
+}
+
+// listenICETCP offers passive ICE-TCP candidates on port, which browsers
+// connect to when UDP is blocked. As with the UDP mux, failing to listen is
+// fatal. Connections accepted on it inherit its DSCP mark.
+func listenICETCP(se *webrtc.SettingEngine, port uint16, codePoint int) {
+	ln, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(int(port))))
+	if err != nil {
+		log.Fatalf("[rtc] ICE-TCP listen on port %d: %v", port, err)
+	}
+
+	if tl, ok := ln.(*net.TCPListener); ok {
+		err = dscp.Mark(tl, codePoint)
+		if err != nil {
+			log.Printf("[rtc] ICE-TCP: %v", err)
+		}
+	}
+
+	se.SetICETCPMux(ice.NewTCPMuxDefault(ice.TCPMuxParams{
+		Listener:        ln,
+		ReadBufferSize:  8,
+		WriteBufferSize: iceTCPWriteBuffer,
+	}))
+	se.SetNetworkTypes([]webrtc.NetworkType{
+		webrtc.NetworkTypeUDP4, webrtc.NetworkTypeUDP6, webrtc.NetworkTypeTCP4, webrtc.NetworkTypeTCP6,
+	})
+
+	log.Printf("[rtc] ICE-TCP on port %d", port)
+}
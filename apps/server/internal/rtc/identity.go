@@ -0,0 +1,71 @@
+package rtc
+
+import (
+	"bytes"
+	"cmp"
+	"strings"
+)
+
+// ClientIdentity is how sessions name themselves to the radio, in its client
+// list and on other multiFLEX clients' screens: the program, with its
+// version, and the station.
+type ClientIdentity struct {
+	// Program defaults to Program.
+	Program string
+	// Version defaults to the bridge's.
+	Version string
+	// Station, when set, replaces the station a client registers with.
+	Station string
+}
+
+// program is the "client program" value: "<program>/<version>".
+func (id ClientIdentity) program() string {
+	p := cmp.Or(id.Program, Program)
+	if id.Version != "" {
+		p += "/" + id.Version
+	}
+
+	return clientValue(p)
+}
+
+// rewrite replaces the program, and the station if one is set, in the
+// "client program" and "client station" commands in data, so every session
+// through the bridge is named as it is configured to be.
+func (id ClientIdentity) rewrite(data []byte) []byte {
+	if !bytes.Contains(data, []byte("|client ")) {
+		return data
+	}
+
+	var out strings.Builder
+
+	for line := range strings.SplitAfterSeq(string(data), "\n") {
+		out.WriteString(id.rewriteLine(line))
+	}
+
+	return []byte(out.String())
+}
+
+func (id ClientIdentity) rewriteLine(line string) string {
+	prefix, body, ok := strings.Cut(line, "|")
+	if !ok {
+		return line
+	}
+
+	trimmed := strings.TrimRight(body, "\r\n")
+	end := body[len(trimmed):]
+
+	switch {
+	case strings.HasPrefix(trimmed, "client program "):
+		return prefix + "|client program " + id.program() + end
+	case id.Station != "" && strings.HasPrefix(trimmed, "client station "):
+		return prefix + "|client station " + clientValue(id.Station) + end
+	default:
+		return line
+	}
+}
+
+// clientValue encodes a program or station name as the radio expects, with
+// its spaces as DEL.
+func clientValue(v string) string {
+	return strings.ReplaceAll(v, " ", "\x7f")
+}
@@ -0,0 +1,31 @@
+package rtc
+
+import "testing"
+
+func TestClientIdentity_Rewrite(t *testing.T) {
+	t.Parallel()
+
+	in := "C1|client program SolidSDR\nC2|client station Chrome\x7fon\x7fMac\nC3|client gui\n"
+
+	for _, tc := range []struct {
+		id   ClientIdentity
+		want string
+	}{
+		{
+			ClientIdentity{Version: "v0.3.0"},
+			"C1|client program solid-sdr/v0.3.0\nC2|client station Chrome\x7fon\x7fMac\nC3|client gui\n",
+		},
+		{
+			ClientIdentity{Program: "Remote Shack", Station: "W1AW Club"},
+			"C1|client program Remote\x7fShack\nC2|client station W1AW\x7fClub\nC3|client gui\n",
+		},
+	} {
+		if got := string(tc.id.rewrite([]byte(in))); got != tc.want {
+			t.Errorf("%+v rewrote to %q, want %q", tc.id, got, tc.want)
+		}
+	}
+
+	if got := string(ClientIdentity{}.rewrite([]byte("C4|slice list\n"))); got != "C4|slice list\n" {
+		t.Errorf("rewrote %q", got)
+	}
+}
@@ -0,0 +1,378 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/recorder"
+)
+
+var (
+	errNoIQStream  = errors.New("no DAX IQ stream is open on this session")
+	errIQAmbiguous = errors.New("several DAX IQ streams are open; pick one by channel")
+	errIQBusy      = errors.New("an IQ recording is already running")
+	errIQRate      = errors.New("DAX IQ sample rate changed")
+	errIQFormat    = errors.New("IQ format must be wav or sigmf")
+)
+
+// IQRecordingRequest starts recording one of a session's DAX IQ streams.
+type IQRecordingRequest struct {
+	Format   string `json:"format,omitempty"`   // wav (default) | sigmf
+	Channel  int    `json:"channel,omitempty"`  // DAX IQ channel; optional with a single stream
+	Duration string `json:"duration,omitempty"` // stop automatically after this long
+}
+
+// IQRecordingStatus reports a session's IQ recording.
+type IQRecordingStatus struct {
+	Active     bool       `json:"active"`
+	Key        string     `json:"key,omitempty"` // WAV file or SigMF metadata, once the first samples arrive
+	Format     string     `json:"format,omitempty"`
+	Channel    int        `json:"channel,omitempty"`
+	Stream     string     `json:"stream,omitempty"`
+	SampleRate int        `json:"sampleRate,omitempty"`
+	CenterMHz  float64    `json:"centerMHz,omitempty"`
+	Started    *time.Time `json:"started,omitempty"`
+	// FirstSample is when the first sample written was taken, by the radio's
+	// timestamps where it sends real time; see the session's clock stats.
+	FirstSample *time.Time `json:"firstSample,omitempty"`
+	Recorded    float64    `json:"recorded,omitempty"` // seconds of signal written
+	Error       string     `json:"error,omitempty"`
+}
+
+// iqTap records one DAX IQ stream. The file is opened on the first packet,
+// whose class code gives the sample rate.
+type iqTap struct {
+	stream  uint32
+	pan     uint32
+	format  recorder.IQFormat
+	meta    recorder.IQMeta
+	started time.Time
+	timer   *time.Timer
+
+	mu  sync.Mutex
+	rec *recorder.IQRecording
+	err error
+}
+
+// iqSampleRate returns the sample rate of a DAX IQ packet class, or 0 for
+// any other class.
+func iqSampleRate(class uint16) int {
+	switch class {
+	case 0x02E3:
+		return 24000
+	case 0x02E4:
+		return 48000
+	case 0x02E5:
+		return 96000
+	case 0x02E6:
+		return 192000
+	default:
+		return 0
+	}
+}
+
+// tapIQ hands a DAX IQ packet to the IQ recording, if one is running for
+// its stream.
+func (rc *radioConn) tapIQ(v vitaView, rate int) {
+	tap := rc.iq.Load()
+	if tap == nil || tap.stream != v.StreamID {
+		return
+	}
+
+	tap.mu.Lock()
+	defer tap.mu.Unlock()
+
+	if tap.err != nil {
+		return
+	}
+
+	if tap.rec == nil {
+		tap.meta.SampleRate = rate
+		tap.meta.Start = rc.clock.at(v, time.Now())
+
+		rec, err := rc.recorder.StartIQ(tap.meta, tap.format)
+		if err != nil {
+			tap.err = err
+			log.Printf("[rtc] IQ recording on %s: %v", rc.key, err)
+
+			return
+		}
+
+		tap.rec = rec
+		log.Printf("[rtc] IQ recording to %s", rec.Key())
+	}
+
+	if rate != tap.meta.SampleRate {
+		tap.err = fmt.Errorf("%w from %d to %d Hz", errIQRate, tap.meta.SampleRate, rate)
+		log.Printf("[rtc] IQ recording %s stopped: %v", tap.rec.Key(), tap.err)
+
+		return
+	}
+
+	tap.rec.WriteIQ(v.Payload)
+}
+
+// retuneIQ follows the recorded panadapter's center frequency.
+func (rc *radioConn) retuneIQ() {
+	tap := rc.iq.Load()
+	if tap == nil {
+		return
+	}
+
+	rc.info.mu.Lock()
+	center, ok := rc.info.pans[tap.pan]
+	rc.info.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	tap.mu.Lock()
+	defer tap.mu.Unlock()
+
+	tap.meta.CenterMHz = center
+	if tap.rec != nil {
+		tap.rec.SetCenter(center)
+	}
+}
+
+// startIQ begins recording the session's DAX IQ stream on channel (0 when the
+// session has only one).
+func (rc *radioConn) startIQ(req IQRecordingRequest, model string) (IQRecordingStatus, error) {
+	if rc.recorder == nil {
+		return IQRecordingStatus{}, errNoRecorder
+	}
+
+	format := recorder.IQFormat(req.Format)
+	if format == "" {
+		format = recorder.IQFormatWAV
+	}
+
+	if format != recorder.IQFormatWAV && format != recorder.IQFormatSigMF {
+		return IQRecordingStatus{}, errIQFormat
+	}
+
+	var d time.Duration
+
+	if req.Duration != "" {
+		var err error
+
+		d, err = time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return IQRecordingStatus{}, errRecordingTime
+		}
+	}
+
+	stream, info, err := rc.pickIQStream(req.Channel)
+	if err != nil {
+		return IQRecordingStatus{}, err
+	}
+
+	rc.info.mu.Lock()
+	meta := recorder.IQMeta{
+		Callsign:  rc.info.callsign,
+		Model:     model,
+		Channel:   info.channel,
+		CenterMHz: rc.info.pans[info.pan],
+	}
+	rc.info.mu.Unlock()
+
+	tap := &iqTap{stream: stream, pan: info.pan, format: format, meta: meta, started: time.Now()}
+
+	if !rc.iq.CompareAndSwap(nil, tap) {
+		return rc.iqStatus(), errIQBusy
+	}
+
+	if d > 0 {
+		tap.timer = time.AfterFunc(d, func() { rc.stopIQ() })
+	}
+
+	return rc.iqStatus(), nil
+}
+
+// pickIQStream finds the session's DAX IQ stream on channel.
+func (rc *radioConn) pickIQStream(channel int) (uint32, iqStreamInfo, error) {
+	rc.mu.RLock()
+	handle := rc.handleU32
+	rc.mu.RUnlock()
+
+	rc.info.mu.Lock()
+	defer rc.info.mu.Unlock()
+
+	var (
+		ids      []uint32
+		channels []int
+	)
+
+	for id, s := range rc.info.iq {
+		if s.client == handle && (channel == 0 || s.channel == channel) {
+			ids = append(ids, id)
+			channels = append(channels, s.channel)
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, iqStreamInfo{}, errNoIQStream
+	case 1:
+		return ids[0], rc.info.iq[ids[0]], nil
+	default:
+		slices.Sort(channels)
+
+		return 0, iqStreamInfo{}, fmt.Errorf("%w: %v", errIQAmbiguous, channels)
+	}
+}
+
+// stopIQ ends the IQ recording and returns its final status.
+func (rc *radioConn) stopIQ() IQRecordingStatus {
+	tap := rc.iq.Load()
+	if tap == nil || !rc.iq.CompareAndSwap(tap, nil) {
+		rc.mu.RLock()
+		defer rc.mu.RUnlock()
+
+		return rc.iqLast
+	}
+
+	if tap.timer != nil {
+		tap.timer.Stop()
+	}
+
+	tap.mu.Lock()
+	status := tap.statusLocked()
+	status.Active = false
+
+	if tap.rec != nil {
+		err := tap.rec.Close()
+		if err != nil && tap.err == nil {
+			tap.err = err
+		}
+
+		status.Recorded = tap.rec.Recorded().Seconds()
+		log.Printf("[rtc] IQ recording stopped: %s (%s)", tap.rec.Key(), tap.rec.Recorded().Round(time.Second))
+	}
+
+	if tap.err != nil {
+		status.Error = tap.err.Error()
+	}
+	tap.mu.Unlock()
+
+	rc.mu.Lock()
+	rc.iqLast = status
+	rc.mu.Unlock()
+
+	return status
+}
+
+// iqStatus reports the running IQ recording, or the last one.
+func (rc *radioConn) iqStatus() IQRecordingStatus {
+	tap := rc.iq.Load()
+	if tap == nil {
+		rc.mu.RLock()
+		defer rc.mu.RUnlock()
+
+		return rc.iqLast
+	}
+
+	tap.mu.Lock()
+	defer tap.mu.Unlock()
+
+	return tap.statusLocked()
+}
+
+func (t *iqTap) statusLocked() IQRecordingStatus {
+	started := t.started
+	status := IQRecordingStatus{
+		Active:     true,
+		Format:     string(t.format),
+		Channel:    t.meta.Channel,
+		Stream:     fmt.Sprintf("0x%08X", t.stream),
+		SampleRate: t.meta.SampleRate,
+		CenterMHz:  t.meta.CenterMHz,
+		Started:    &started,
+	}
+
+	if t.rec != nil {
+		first := t.meta.Start
+		status.Key = t.rec.Key()
+		status.FirstSample = &first
+		status.Recorded = t.rec.Recorded().Seconds()
+	}
+
+	if t.err != nil {
+		status.Error = t.err.Error()
+	}
+
+	return status
+}
+
+// StartIQRecording starts recording a DAX IQ stream of session id.
+func (s *Server) StartIQRecording(id string, req IQRecordingRequest) (IQRecordingStatus, error) {
+	rc, err := s.iqRadio(id)
+	if err != nil {
+		return IQRecordingStatus{}, err
+	}
+
+	return rc.startIQ(req, s.radioModel(rc.addr))
+}
+
+// StopIQRecording stops session id's IQ recording.
+func (s *Server) StopIQRecording(id string) (IQRecordingStatus, error) {
+	rc, err := s.iqRadio(id)
+	if err != nil {
+		return IQRecordingStatus{}, err
+	}
+
+	return rc.stopIQ(), nil
+}
+
+// IQRecording reports session id's IQ recording.
+func (s *Server) IQRecording(id string) (IQRecordingStatus, error) {
+	rc, err := s.iqRadio(id)
+	if err != nil {
+		return IQRecordingStatus{}, err
+	}
+
+	return rc.iqStatus(), nil
+}
+
+func (s *Server) iqRadio(id string) (*radioConn, error) {
+	cs := s.session(id)
+	if cs == nil {
+		return nil, ErrNoSession
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return nil, errRecordingRadio
+	}
+
+	return rc, nil
+}
+
+// radioModel looks addr up in discovery, for recording metadata.
+func (s *Server) radioModel(addr string) string {
+	if s.disco == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	for _, r := range s.disco.Registry().List(time.Now()) {
+		if r.Host == host {
+			return r.Model
+		}
+	}
+
+	return ""
+}
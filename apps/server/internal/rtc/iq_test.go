@@ -0,0 +1,108 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/recorder"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/storage"
+)
+
+func TestRadioInfo_IQStreams(t *testing.T) {
+	t.Parallel()
+
+	var ri radioInfo
+
+	ri.observe("display pan 0x40000000 center=14.100000 bandwidth=0.200000 autocenter=1")
+	ri.observe("stream 0x20000000 type=dax_iq daxiq_channel=1 pan=0x40000000 daxiq_rate=48000 client_handle=0x1234ABCD")
+	ri.observe("stream 0x04000008 type=remote_audio_rx compression=OPUS client_handle=0x1234ABCD")
+
+	if len(ri.iq) != 1 {
+		t.Fatalf("iq streams = %+v, want only the DAX IQ one", ri.iq)
+	}
+
+	s := ri.iq[0x20000000]
+	if s.channel != 1 || s.pan != 0x40000000 || s.client != 0x1234ABCD {
+		t.Errorf("stream = %+v", s)
+	}
+
+	if ri.pans[0x40000000] != 14.1 {
+		t.Errorf("pan center = %v, want 14.1 (not autocenter)", ri.pans[0x40000000])
+	}
+
+	ri.observe("stream 0x20000000 removed")
+
+	if len(ri.iq) != 0 {
+		t.Errorf("removed stream still tracked: %+v", ri.iq)
+	}
+}
+
+func TestIQRecording(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rc := &radioConn{handleU32: 0x1234ABCD, recorder: recorder.New(storage.NewLocal(dir), recorder.Options{})}
+
+	_, err := rc.startIQ(IQRecordingRequest{}, "")
+	if !errors.Is(err, errNoIQStream) {
+		t.Fatalf("start without a stream: %v", err)
+	}
+
+	rc.info.observe("radio callsign=N0CALL")
+	rc.info.observe("display pan 0x40000000 center=7.100000")
+	rc.info.observe("stream 0x20000000 type=dax_iq daxiq_channel=2 pan=0x40000000 client_handle=0x1234ABCD")
+
+	status, err := rc.startIQ(IQRecordingRequest{Format: "sigmf"}, "FLEX-6600")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.Active || status.Channel != 2 || status.CenterMHz != 7.1 {
+		t.Errorf("status = %+v", status)
+	}
+
+	_, err = rc.startIQ(IQRecordingRequest{}, "")
+	if !errors.Is(err, errIQBusy) {
+		t.Errorf("second start: %v", err)
+	}
+
+	payload := make([]byte, 0, 8*240)
+	for range 240 {
+		payload = binary.LittleEndian.AppendUint32(payload, math.Float32bits(100))
+		payload = binary.LittleEndian.AppendUint32(payload, math.Float32bits(-100))
+	}
+
+	// Other streams are ignored.
+	rc.tapIQ(vitaView{StreamID: 0x20000001, ClassCode: 0x02E3, Payload: payload}, 24000)
+
+	for range 100 {
+		rc.tapIQ(vitaView{StreamID: 0x20000000, ClassCode: 0x02E3, Payload: payload}, 24000)
+	}
+
+	rc.info.observe("display pan 0x40000000 center=7.150000")
+	rc.retuneIQ()
+
+	status = rc.stopIQ()
+	if status.Active || status.SampleRate != 24000 || status.Recorded != 1 || status.Error != "" {
+		t.Errorf("after stop: %+v", status)
+	}
+
+	meta, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(status.Key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(meta), `"core:frequency": 7150000`) ||
+		!strings.Contains(string(meta), "FLEX-6600 DAX IQ channel 2") {
+		t.Errorf("metadata missing the retune or description:\n%s", meta)
+	}
+
+	if rc.iqStatus() != status {
+		t.Errorf("status after stop = %+v, want the last recording", rc.iqStatus())
+	}
+}
@@ -0,0 +1,98 @@
+package rtc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// internalKeepaliveSequence tags the "keepalive enable" the bridge sends on
+// connecting so its reply is consumed instead of reaching the browser.
+const internalKeepaliveSequence = 2147483639
+
+// DefaultRadioTimeout is how long the radio may leave our pings unanswered
+// before its link is reported unhealthy.
+const DefaultRadioTimeout = 10 * time.Second
+
+// radioHealth is sent to the client when the radio stops answering pings,
+// and again when it answers one.
+type radioHealth struct {
+	Healthy bool `json:"healthy"`
+	// SilentMs is how long the radio had not answered.
+	SilentMs int64 `json:"silentMs,omitempty"`
+}
+
+// enableKeepalive asks the radio to drop us if our pings stop, so a
+// connection we lost is released on its side too.
+func (rc *radioConn) enableKeepalive() {
+	err := rc.writeTCPString(fmt.Sprintf("C%d|keepalive enable\n", internalKeepaliveSequence))
+	if err != nil {
+		log.Printf("[rtc] %s: keepalive enable: %v", rc.key, err)
+	}
+}
+
+// checkHealth reports the link unhealthy once the radio has not answered a
+// ping for the timeout.
+func (rc *radioConn) checkHealth(now time.Time) {
+	rc.mu.Lock()
+
+	silent := now.Sub(rc.pingAnsweredAt)
+	if rc.unhealthy || silent < rc.radioTimeout() {
+		rc.mu.Unlock()
+
+		return
+	}
+
+	rc.unhealthy = true
+	onHealth := rc.onHealth
+	rc.mu.Unlock()
+
+	log.Printf("[rtc] %s: radio has not answered pings for %s", rc.key, silent.Round(time.Second))
+
+	if onHealth != nil {
+		onHealth(radioHealth{SilentMs: silent.Milliseconds()})
+	}
+}
+
+// pingAnswered notes a ping reply, reporting the link healthy again if it
+// was not. It is called with rc.mu held and returns the callback to run
+// once it is released, if any.
+func (rc *radioConn) pingAnswered(now time.Time) func() {
+	silent := now.Sub(rc.pingAnsweredAt)
+	rc.pingAnsweredAt = now
+
+	if !rc.unhealthy {
+		return nil
+	}
+
+	rc.unhealthy = false
+	onHealth := rc.onHealth
+
+	log.Printf("[rtc] %s: radio answering again after %s", rc.key, silent.Round(time.Second))
+
+	if onHealth == nil {
+		return nil
+	}
+
+	return func() { onHealth(radioHealth{Healthy: true, SilentMs: silent.Milliseconds()}) }
+}
+
+// radioTimeout is the silence after which the link is unhealthy; called with
+// rc.mu held.
+func (rc *radioConn) radioTimeout() time.Duration {
+	if rc.timeout > 0 {
+		return rc.timeout
+	}
+
+	return DefaultRadioTimeout
+}
+
+// reportRadioHealth tells the client whether its radio is answering.
+func (cs *clientSession) reportRadioHealth(h radioHealth) {
+	cs.trySend(mustEncode(typeRadioHealth, h))
+}
+
+func isInternalKeepaliveReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalKeepaliveSequence))
+}
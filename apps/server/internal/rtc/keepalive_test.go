@@ -0,0 +1,49 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRadioConn_Health(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	got := make(chan radioHealth, 4)
+
+	rc := &radioConn{
+		key:            "radio/0x1",
+		pingAnsweredAt: now,
+		timeout:        time.Second,
+		onHealth:       func(h radioHealth) { got <- h },
+	}
+
+	rc.checkHealth(now.Add(500 * time.Millisecond))
+	rc.checkHealth(now.Add(2 * time.Second))
+	rc.checkHealth(now.Add(2500 * time.Millisecond))
+
+	if h := <-got; h.Healthy || h.SilentMs != 2000 {
+		t.Errorf("first report = %+v", h)
+	}
+
+	cs := &clientSession{srv: &Server{}, radio: rc}
+	if !cs.info().RadioUnhealthy {
+		t.Error("session not listed as unhealthy")
+	}
+
+	rc.consumeInternalPingReply("R2147483647|0|", now.Add(3*time.Second))
+
+	if h := <-got; !h.Healthy || h.SilentMs != 3000 {
+		t.Errorf("recovery report = %+v", h)
+	}
+
+	select {
+	case h := <-got:
+		t.Errorf("extra report %+v", h)
+	default:
+	}
+
+	if !isInternalKeepaliveReply("R2147483639|0|") || isInternalKeepaliveReply("R2147483647|0|") {
+		t.Error("isInternalKeepaliveReply")
+	}
+}
@@ -0,0 +1,124 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Latency profiles: how a session trades delay for resilience.
+const (
+	latencyLow      = "low"
+	latencyBalanced = "balanced"
+	latencyStable   = "stable"
+)
+
+var errLatencyProfile = errors.New("latency must be low, balanced or stable")
+
+// latencyProfile is what a profile sets. The exported fields are sent to the
+// client, which applies what only it can: its jitter buffer and whether the
+// "udp" channel it opens is ordered.
+type latencyProfile struct {
+	Profile string `json:"profile"`
+	// JitterBufferTargetMs is the receiver's jitter buffer target, zero to
+	// leave it to the browser.
+	JitterBufferTargetMs int `json:"jitterBufferTargetMs"`
+	// AudioPacketMs is how much audio each RTP packet carries.
+	AudioPacketMs int `json:"audioPacketMs"`
+	// OrderedUDP is whether the "udp" channel should be ordered; unordered,
+	// a lost message no longer holds up the ones behind it.
+	OrderedUDP bool `json:"orderedUdp"`
+
+	// frames is how many of the radio's 10 ms packets go in each RTP packet.
+	frames int
+	// udpBacklog is how much the "udp" channel may buffer, and udpDrop
+	// whether packets past it are dropped rather than waited on.
+	udpBacklog uint64
+	udpDrop    bool
+}
+
+var latencyProfiles = map[string]latencyProfile{
+	latencyLow: {
+		Profile: latencyLow, AudioPacketMs: 10,
+		frames: 1, udpBacklog: 64 << 10, udpDrop: true,
+	},
+	latencyBalanced: {
+		Profile: latencyBalanced, AudioPacketMs: 10, OrderedUDP: true,
+		frames: 1, udpBacklog: 1 << 20,
+	},
+	latencyStable: {
+		Profile: latencyStable, JitterBufferTargetMs: 200, AudioPacketMs: 40, OrderedUDP: true,
+		frames: 4, udpBacklog: 4 << 20,
+	},
+}
+
+// latencyPayload sets, or with no profile asks for, the session's latency
+// profile.
+type latencyPayload struct {
+	Profile string `json:"profile,omitempty"`
+}
+
+// latency is the session's profile.
+func (cs *clientSession) latency() latencyProfile {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	p, ok := latencyProfiles[cs.latencyProfile]
+	if !ok {
+		p = latencyProfiles[latencyBalanced]
+	}
+
+	return p
+}
+
+// setLatency applies a profile to the session's audio track and radio link.
+// An empty name keeps the current one.
+func (cs *clientSession) setLatency(name string) error {
+	if name != "" {
+		_, ok := latencyProfiles[name]
+		if !ok {
+			return errLatencyProfile
+		}
+
+		cs.mu.Lock()
+		cs.latencyProfile = name
+		cs.mu.Unlock()
+	}
+
+	p := cs.latency()
+
+	cs.mu.Lock()
+	track, rc := cs.audioTrack, cs.radio
+	cs.mu.Unlock()
+
+	if track != nil {
+		track.setFrames(p.frames)
+	}
+
+	if rc != nil {
+		rc.setUDPBacklog(p.udpBacklog, p.udpDrop)
+	}
+
+	return nil
+}
+
+// handleLatency sets the session's latency profile and answers with the
+// profile in force.
+func (cs *clientSession) handleLatency(raw json.RawMessage) {
+	var p latencyPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	err = cs.setLatency(p.Profile)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeLatency, cs.latency()))
+}
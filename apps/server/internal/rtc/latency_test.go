@@ -0,0 +1,44 @@
+package rtc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHandleLatency(t *testing.T) {
+	t.Parallel()
+
+	rc := &radioConn{}
+	track := newOpusTrack("a", "a")
+	cs := &clientSession{srv: &Server{}, radio: rc, audioTrack: track, send: make(chan message, 4)}
+
+	cs.handleLatency(json.RawMessage(`{"profile":"stable"}`))
+
+	var got latencyProfile
+
+	msg := <-cs.send
+	if msg.Type != typeLatency || json.Unmarshal(msg.Payload, &got) != nil || got.Profile != latencyStable {
+		t.Fatalf("reply %s %s", msg.Type, msg.Payload)
+	}
+
+	if track.frames != 4 || rc.udpBacklog != 4<<20 || rc.udpDrop {
+		t.Errorf("stable applied as frames %d, backlog %d, drop %v", track.frames, rc.udpBacklog, rc.udpDrop)
+	}
+
+	cs.handleLatency(json.RawMessage(`{"profile":"low"}`))
+	<-cs.send
+
+	if track.frames != 1 || !rc.udpDrop {
+		t.Errorf("low applied as frames %d, drop %v", track.frames, rc.udpDrop)
+	}
+
+	cs.handleLatency(json.RawMessage(`{"profile":"fast"}`))
+
+	if msg := <-cs.send; msg.Type != typeError {
+		t.Errorf("unknown profile answered with %s", msg.Type)
+	}
+
+	if cs.latency().Profile != latencyLow {
+		t.Errorf("profile %q after a bad one", cs.latency().Profile)
+	}
+}
@@ -0,0 +1,99 @@
+package rtc
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLineBatchBytes is how much of the radio's lines a batch holds
+	// before it is sent without waiting out its delay.
+	DefaultLineBatchBytes = 16 * 1024
+	// MaxLineBatchBytes keeps a batch within the 64 KiB data channel
+	// message that every browser accepts.
+	MaxLineBatchBytes = 64 * 1024
+)
+
+// LineBatchOptions batches the radio's lines on the "tcp" data channel. A
+// zero Delay sends each line as its own message.
+type LineBatchOptions struct {
+	// Delay is the longest a line waits for others to join it.
+	Delay time.Duration
+	// Bytes sends a batch as soon as it holds this much.
+	Bytes int
+}
+
+// lineBatcher joins the lines sent to it into one newline-delimited message,
+// sent once the first of them has waited Delay or the batch reaches Bytes.
+// Clients already split what they receive into lines, so a burst of status
+// after "sub all" or a band change costs a few messages rather than hundreds.
+type lineBatcher struct {
+	dc    textSender
+	delay time.Duration
+	bytes int
+
+	mu      sync.Mutex
+	pending strings.Builder
+	timer   *time.Timer
+}
+
+// newLineBatcher returns dc batched by opt, or dc itself when batching is off.
+func newLineBatcher(dc textSender, opt LineBatchOptions) textSender {
+	if opt.Delay <= 0 {
+		return dc
+	}
+
+	n := opt.Bytes
+	if n <= 0 {
+		n = DefaultLineBatchBytes
+	}
+
+	return &lineBatcher{dc: dc, delay: opt.Delay, bytes: min(n, MaxLineBatchBytes)}
+}
+
+// SendText adds line to the batch. Only a batch sent by this call can fail
+// it; one sent later by the timer fails as a single line would, unnoticed.
+func (b *lineBatcher) SendText(line string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending.WriteString(line)
+
+	if !strings.HasSuffix(line, "\n") {
+		b.pending.WriteByte('\n')
+	}
+
+	if b.pending.Len() >= b.bytes {
+		return b.flushLocked()
+	}
+
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.delay, b.flush)
+	}
+
+	return nil
+}
+
+func (b *lineBatcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_ = b.flushLocked()
+}
+
+func (b *lineBatcher) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if b.pending.Len() == 0 {
+		return nil
+	}
+
+	msg := b.pending.String()
+	b.pending.Reset()
+
+	return b.dc.SendText(msg) //nolint:wrapcheck // as the channel reports it
+}
@@ -0,0 +1,59 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineBatcher_JoinsLinesUntilDelay(t *testing.T) {
+	t.Parallel()
+
+	sink := make(lineSink, 4)
+	b := newLineBatcher(sink, LineBatchOptions{Delay: 20 * time.Millisecond})
+
+	for _, line := range []string{"S1|slice 0 RF_frequency=14.074\n", "S1|slice 0 mode=DIGU\n", "R3|0|"} {
+		err := b.SendText(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case got := <-sink:
+		if want := "S1|slice 0 RF_frequency=14.074\nS1|slice 0 mode=DIGU\nR3|0|\n"; got != want {
+			t.Errorf("batch = %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch never sent")
+	}
+
+	if len(sink) != 0 {
+		t.Errorf("%d more messages", len(sink))
+	}
+}
+
+func TestLineBatcher_SendsFullBatchAtOnce(t *testing.T) {
+	t.Parallel()
+
+	sink := make(lineSink, 4)
+	b := newLineBatcher(sink, LineBatchOptions{Delay: time.Hour, Bytes: 8})
+
+	_ = b.SendText("S1|a\n")
+	if len(sink) != 0 {
+		t.Fatal("sent before the batch was full")
+	}
+
+	_ = b.SendText("S1|b\n")
+	if got := <-sink; got != "S1|a\nS1|b\n" {
+		t.Errorf("batch = %q", got)
+	}
+}
+
+func TestNewLineBatcher_OffWithoutDelay(t *testing.T) {
+	t.Parallel()
+
+	sink := make(lineSink, 1)
+	if b := newLineBatcher(sink, LineBatchOptions{Bytes: 1024}); b != textSender(sink) {
+		t.Errorf("batcher = %T, want the channel itself", b)
+	}
+}
@@ -0,0 +1,207 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+)
+
+var (
+	errListenSelf      = errors.New("a session cannot listen to itself")
+	errListenChained   = errors.New("that session is itself listening to another")
+	errListenOwnAudio  = errors.New("session has its own RX audio stream")
+	errNoListenSource  = errors.New("no session is carrying audio")
+	errListenAmbiguous = errors.New("several sessions are carrying audio; name one")
+	errNoAudioTrack    = errors.New("no audio track yet")
+)
+
+// listenPayload starts hearing another session's RX audio, or with Stop
+// stops. An empty session picks the only one carrying audio. The bridge
+// answers with the session being heard, empty when none is.
+type listenPayload struct {
+	Session string `json:"session,omitempty"`
+	Stop    bool   `json:"stop,omitempty"`
+}
+
+// addRelay has r hear every packet of t's audio. Each relay packetizes for
+// its own listeners, so it keeps its own timeline, latency profile and
+// redundancy.
+func (t *opusTrack) addRelay(r *opusTrack) {
+	t.mu.Lock()
+	t.relays = append(slices.Clone(t.relays), r)
+	t.mu.Unlock()
+}
+
+func (t *opusTrack) removeRelay(r *opusTrack) {
+	t.mu.Lock()
+	t.relays = slices.DeleteFunc(slices.Clone(t.relays), func(x *opusTrack) bool { return x == r })
+	t.mu.Unlock()
+}
+
+// rxStream is the session's own RX audio stream, zero when it has none.
+func (cs *clientSession) rxStream() uint32 {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return 0
+	}
+
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	return rc.activeRXStream
+}
+
+// source is the session cs is listening to, or nil.
+func (cs *clientSession) source() *clientSession {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.listening
+}
+
+// listenSource picks the session cs will hear: the one named, or else the
+// only other session with an RX audio stream.
+func (s *Server) listenSource(cs *clientSession, id string) (*clientSession, error) {
+	if id != "" {
+		src := s.session(id)
+
+		switch {
+		case src == nil:
+			return nil, errNoListenSource
+		case src == cs:
+			return nil, errListenSelf
+		case src.source() != nil:
+			return nil, errListenChained
+		case src.audio() == nil:
+			return nil, errNoListenSource
+		}
+
+		return src, nil
+	}
+
+	var candidates []*clientSession
+
+	for _, other := range s.sessionList() {
+		if other != cs && other.source() == nil && other.audio() != nil && other.rxStream() != 0 {
+			candidates = append(candidates, other)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, errNoListenSource
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, errListenAmbiguous
+	}
+}
+
+// listen has cs hear src's RX audio on its own track, in place of any
+// session it heard before.
+func (cs *clientSession) listen(src *clientSession) error {
+	track := cs.audio()
+	if track == nil {
+		return errNoAudioTrack
+	}
+
+	if cs.rxStream() != 0 {
+		return errListenOwnAudio
+	}
+
+	cs.stopListening()
+
+	cs.mu.Lock()
+	cs.listening = src
+	cs.mu.Unlock()
+
+	src.audio().addRelay(track)
+
+	// The listener keeps src's RX audio stream up should src's own client
+	// stop wanting it.
+	if rc := src.currentRadio(); rc != nil {
+		rc.subs.hold(subAudio, listenerHolder(cs))
+	}
+
+	log.Printf("[rtc] session %s listening to %s", cs.id, src.id)
+
+	return nil
+}
+
+// stopListening stops cs hearing the session it listens to, if any.
+func (cs *clientSession) stopListening() {
+	cs.mu.Lock()
+	src := cs.listening
+	cs.listening = nil
+	track := cs.audioTrack
+	cs.mu.Unlock()
+
+	if src == nil {
+		return
+	}
+
+	if srcTrack := src.audio(); srcTrack != nil {
+		srcTrack.removeRelay(track)
+	}
+
+	if rc := src.currentRadio(); rc != nil {
+		rc.removeStreams(rc.subs.release(listenerHolder(cs)))
+	}
+}
+
+// listeners are the sessions listening to cs.
+func (s *Server) listeners(cs *clientSession) []*clientSession {
+	var out []*clientSession
+
+	for _, other := range s.sessionList() {
+		if other.source() == cs {
+			out = append(out, other)
+		}
+	}
+
+	return out
+}
+
+// endListeners stops every session hearing cs, which is going away, and
+// tells their clients.
+func (s *Server) endListeners(cs *clientSession) {
+	for _, l := range s.listeners(cs) {
+		l.stopListening()
+		l.trySend(mustEncode(typeListen, listenPayload{}))
+	}
+}
+
+func (cs *clientSession) handleListen(raw json.RawMessage) {
+	var p listenPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	if p.Stop {
+		cs.stopListening()
+		cs.trySend(mustEncode(typeListen, listenPayload{}))
+
+		return
+	}
+
+	src, err := cs.srv.listenSource(cs, p.Session)
+	if err == nil {
+		err = cs.listen(src)
+	}
+
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "LISTEN_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeListen, listenPayload{Session: src.id}))
+}
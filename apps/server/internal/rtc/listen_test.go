@@ -0,0 +1,94 @@
+package rtc
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+// rtpRecorder is a TrackLocalWriter that keeps what it is given.
+type rtpRecorder struct {
+	mu   sync.Mutex
+	pkts []rtp.Header
+}
+
+func (r *rtpRecorder) WriteRTP(h *rtp.Header, payload []byte) (int, error) {
+	r.mu.Lock()
+	r.pkts = append(r.pkts, *h)
+	r.mu.Unlock()
+
+	return len(payload), nil
+}
+
+func (r *rtpRecorder) Write(b []byte) (int, error) { return len(b), nil }
+
+func (r *rtpRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.pkts)
+}
+
+func TestListen(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+
+	newSession := func(id string, rx uint32) (*clientSession, *rtpRecorder) {
+		rec := &rtpRecorder{}
+		track := newOpusTrack(id, id)
+		track.bindings["pc"] = &opusBinding{ssrc: 1, opusPT: 111, w: rec}
+
+		cs := &clientSession{
+			id: id, srv: s, audioTrack: track, send: make(chan message, 4),
+			radio: &radioConn{activeRXStream: rx},
+		}
+		s.addSession(cs)
+
+		return cs, rec
+	}
+
+	op, opRec := newSession("op", 0x04000008)
+	member, memberRec := newSession("member", 0)
+
+	member.handleListen(json.RawMessage(`{}`))
+
+	var reply listenPayload
+
+	msg := <-member.send
+	if msg.Type != typeListen || json.Unmarshal(msg.Payload, &reply) != nil || reply.Session != "op" {
+		t.Fatalf("reply %s %s", msg.Type, msg.Payload)
+	}
+
+	op.audio().write(vitaView{Payload: []byte{26 << 3, 1}})
+
+	if opRec.count() != 1 || memberRec.count() != 1 {
+		t.Errorf("operator got %d packets, member %d", opRec.count(), memberRec.count())
+	}
+
+	if si := op.info(); si.Listeners != 1 {
+		t.Errorf("listeners = %d", si.Listeners)
+	}
+
+	// The operator cannot in turn listen to its listener.
+	op.handleListen(json.RawMessage(`{"session":"member"}`))
+
+	if msg := <-op.send; msg.Type != typeError {
+		t.Errorf("chained listen answered with %s", msg.Type)
+	}
+
+	// When the operator leaves, the member is told and hears nothing more.
+	s.removeSession(op)
+
+	if msg := <-member.send; msg.Type != typeListen || string(msg.Payload) != "{}" {
+		t.Errorf("on leaving: %s %s", msg.Type, msg.Payload)
+	}
+
+	op.audio().write(vitaView{Payload: []byte{26 << 3, 2}})
+
+	if memberRec.count() != 1 || member.info().ListeningTo != "" {
+		t.Errorf("member still listening: %d packets", memberRec.count())
+	}
+}
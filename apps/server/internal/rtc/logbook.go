@@ -0,0 +1,102 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/logbook"
+)
+
+var (
+	errLogbookDisabled = errors.New("logbook integration is not enabled")
+	errLogbookAction   = errors.New("unknown logbook action")
+)
+
+// logbookRequest is a client's "logbook" message.
+type logbookRequest struct {
+	// Action is "subscribe" or "unsubscribe" to the QSOs logged, or "log".
+	Action string `json:"action"`
+	// Record, for log, is the QSO's ADIF fields, e.g. {"call":"K1ABC"}.
+	Record logbook.Record `json:"record,omitempty"`
+}
+
+// publishQSO sends a logged QSO to the sessions that asked for them and to
+// the event stream.
+func (s *Server) publishQSO(rec logbook.Record) {
+	msg := mustEncode(typeQSO, rec)
+
+	for _, cs := range s.sessionList() {
+		if cs.logbook.Load() {
+			cs.trySend(msg)
+		}
+	}
+
+	s.events.Publish(EventQSOLogged, rec)
+}
+
+func (cs *clientSession) handleLogbook(raw json.RawMessage) {
+	var req logbookRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	svc := cs.srv.logbook
+	if svc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "LOGBOOK_FAILED", Message: errLogbookDisabled.Error()}))
+
+		return
+	}
+
+	switch req.Action {
+	case "subscribe":
+		cs.logbook.Store(true)
+	case "unsubscribe":
+		cs.logbook.Store(false)
+	case "log":
+		err = svc.Log(req.Record)
+	default:
+		err = fmt.Errorf("%w %q", errLogbookAction, req.Action)
+	}
+
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "LOGBOOK_FAILED", Message: err.Error()}))
+	}
+}
+
+// reportLogbook tells the logbook what the radio is tuned to after a slice
+// or interlock status body.
+func (rc *radioConn) reportLogbook(body string) {
+	rc.mu.RLock()
+	svc := rc.logbook
+	transmitting := rc.interlockState == "TRANSMITTING"
+	rc.mu.RUnlock()
+
+	if svc == nil || (!strings.HasPrefix(body, "slice ") && !strings.HasPrefix(body, "interlock ")) {
+		return
+	}
+
+	active, tx, ok := rc.info.focus()
+	if !ok {
+		return
+	}
+
+	rc.info.mu.Lock()
+	callsign := rc.info.callsign
+	rc.info.mu.Unlock()
+
+	svc.Radio(logbook.Radio{
+		Radio:        rc.addr,
+		Callsign:     callsign,
+		FreqHz:       int64(math.Round(active.freqMHz * 1e6)),
+		TXFreqHz:     int64(math.Round(tx.freqMHz * 1e6)),
+		Mode:         active.mode,
+		Transmitting: transmitting,
+	})
+}
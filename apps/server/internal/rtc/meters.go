@@ -0,0 +1,464 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// meterWindow is how much meter history is kept.
+	meterWindow = 10 * time.Minute
+	// meterCapacity bounds a meter's samples: meterWindow at 20 a second,
+	// the fastest the radio sends them.
+	meterCapacity = 20 * 600
+	// alertClear is how long a meter must stay within an alert's limit for
+	// the alert to clear, so a noisy meter doesn't raise it over and over.
+	alertClear = 2 * time.Second
+	// defaultChartPoints and maxChartPoints are how many points a meter's
+	// downsampled history has by default and at most.
+	defaultChartPoints = 300
+	maxChartPoints     = 2000
+)
+
+// chartedMeters are the meters kept for charting, as well as any an alert
+// watches.
+var chartedMeters = []string{"SWR", "FWDPWR", "REFPWR", "PATEMP", "+13.8A", "+13.8B"}
+
+// MeterAlert raises a meter.alert event while a meter reads over Limit, or
+// under it when Below is set. A TX alert only applies while the radio
+// transmits.
+type MeterAlert struct {
+	Meter string
+	Limit float64
+	Below bool
+	TX    bool
+}
+
+// MeterAlertEvent is the data of a meter.alert event: a meter going beyond an
+// alert's limit, when Raised is set, or settling back within it.
+type MeterAlertEvent struct {
+	Session string    `json:"session"`
+	Radio   string    `json:"radio"`
+	At      time.Time `json:"at"`
+	Meter   string    `json:"meter"`
+	Unit    string    `json:"unit"`
+	Value   float64   `json:"value"`
+	Limit   float64   `json:"limit"`
+	Below   bool      `json:"below,omitempty"`
+	TX      bool      `json:"tx,omitempty"`
+	Raised  bool      `json:"raised"`
+}
+
+// MeterQuery picks the meter history to return. Names defaults to every meter
+// kept; Since, how far back to go, to the whole 10 minutes kept; and Points,
+// how many buckets each meter is reduced to, to 300, at most 2000.
+type MeterQuery struct {
+	Names  []string
+	Since  time.Duration
+	Points int
+}
+
+// MeterSeries is one meter's downsampled history, oldest first.
+type MeterSeries struct {
+	Name   string       `json:"name"`
+	Unit   string       `json:"unit"`
+	Points []MeterPoint `json:"points"`
+}
+
+// MeterPoint sums up a meter's samples from At for one bucket.
+type MeterPoint struct {
+	At  time.Time `json:"at"`
+	Min float64   `json:"min"`
+	Max float64   `json:"max"`
+	Avg float64   `json:"avg"`
+}
+
+// meterDef is what the radio's "meter" status says about a meter.
+type meterDef struct {
+	name string
+	unit string
+}
+
+type meterSample struct {
+	at    time.Time
+	value float64
+}
+
+// meterRing is a meter's latest samples, the oldest overwritten first.
+type meterRing struct {
+	unit    string
+	samples []meterSample
+	next    int
+}
+
+func (r *meterRing) add(s meterSample) {
+	if len(r.samples) < meterCapacity {
+		r.samples = append(r.samples, s)
+
+		return
+	}
+
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % meterCapacity
+}
+
+// each calls fn on the samples from since on, oldest first.
+func (r *meterRing) each(since time.Time, fn func(meterSample)) {
+	for i := range r.samples {
+		s := r.samples[(r.next+i)%len(r.samples)]
+		if !s.at.Before(since) {
+			fn(s)
+		}
+	}
+}
+
+// alertState is where an alert stands: raised, and since when its meter has
+// been back within the limit.
+type alertState struct {
+	raised bool
+	within time.Time
+}
+
+// meterLog keeps a radio's meters: their definitions, the history of those
+// charted or watched, and the state of the alerts on them.
+type meterLog struct {
+	mu     sync.Mutex
+	defs   map[uint16]meterDef
+	rings  map[string]*meterRing
+	alerts map[int]*alertState // by index in the alerts
+}
+
+// observe applies a "meter ..." status body, e.g.
+// "meter 7.src=TX-#7.num=1#7.nam=SWR#7.unit=SWR#" or "meter 7 removed".
+func (m *meterLog) observe(body string) {
+	attrs, ok := strings.CutPrefix(body, "meter ")
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.defs == nil {
+		m.defs = make(map[uint16]meterDef)
+	}
+
+	if idText, ok := strings.CutSuffix(attrs, " removed"); ok {
+		id, err := strconv.ParseUint(idText, 10, 16)
+		if err == nil {
+			delete(m.defs, uint16(id))
+		}
+
+		return
+	}
+
+	for field := range strings.SplitSeq(attrs, "#") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+
+		idText, attr, ok := strings.Cut(key, ".")
+		if !ok {
+			continue
+		}
+
+		id, err := strconv.ParseUint(idText, 10, 16)
+		if err != nil {
+			continue
+		}
+
+		d := m.defs[uint16(id)]
+
+		switch attr {
+		case "nam":
+			d.name = value
+		case "unit":
+			d.unit = value
+		default:
+			continue
+		}
+
+		m.defs[uint16(id)] = d
+	}
+}
+
+// add records a meter packet's readings, pairs of a 16-bit meter id and a
+// signed 16-bit value, and returns the alerts it raised or cleared.
+func (m *meterLog) add(payload []byte, now time.Time, transmitting bool, alerts []MeterAlert) []MeterAlertEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var fired []MeterAlertEvent
+
+	for b := payload; len(b) >= 4; b = b[4:] {
+		d, ok := m.defs[binary.BigEndian.Uint16(b)]
+		if !ok || d.name == "" {
+			continue
+		}
+
+		raw := int16(binary.BigEndian.Uint16(b[2:])) //nolint:gosec // signed on the wire
+		value, unit := meterValue(d.name, raw, d.unit)
+
+		watched := false
+
+		for i, a := range alerts {
+			if a.Meter != d.name {
+				continue
+			}
+
+			watched = true
+
+			if ev, ok := m.check(i, a, value, now, transmitting); ok {
+				ev.Unit = unit
+				fired = append(fired, ev)
+			}
+		}
+
+		if !watched && !slices.Contains(chartedMeters, d.name) {
+			continue
+		}
+
+		if m.rings == nil {
+			m.rings = make(map[string]*meterRing)
+		}
+
+		r := m.rings[d.name]
+		if r == nil {
+			r = &meterRing{}
+			m.rings[d.name] = r
+		}
+
+		r.unit = unit
+		r.add(meterSample{at: now, value: value})
+	}
+
+	return fired
+}
+
+// check moves alert i on with a reading, reporting an event when it is raised
+// or cleared.
+func (m *meterLog) check(i int, a MeterAlert, value float64, now time.Time, transmitting bool) (MeterAlertEvent, bool) {
+	if m.alerts == nil {
+		m.alerts = make(map[int]*alertState)
+	}
+
+	st := m.alerts[i]
+	if st == nil {
+		st = &alertState{}
+		m.alerts[i] = st
+	}
+
+	beyond := value > a.Limit
+	if a.Below {
+		beyond = value < a.Limit
+	}
+
+	if a.TX && !transmitting {
+		beyond = false
+	}
+
+	ev := MeterAlertEvent{At: now, Meter: a.Meter, Value: value, Limit: a.Limit, Below: a.Below, TX: a.TX}
+
+	switch {
+	case beyond:
+		st.within = time.Time{}
+
+		if st.raised {
+			return MeterAlertEvent{}, false
+		}
+
+		st.raised = true
+		ev.Raised = true
+
+		return ev, true
+	case !st.raised:
+		return MeterAlertEvent{}, false
+	case st.within.IsZero():
+		st.within = now
+
+		return MeterAlertEvent{}, false
+	case now.Sub(st.within) < alertClear:
+		return MeterAlertEvent{}, false
+	}
+
+	st.raised = false
+	st.within = time.Time{}
+
+	return ev, true
+}
+
+// history downsamples the meters q names, as of now.
+func (m *meterLog) history(q MeterQuery, now time.Time) []MeterSeries {
+	since := q.Since
+	if since <= 0 || since > meterWindow {
+		since = meterWindow
+	}
+
+	points := q.Points
+	if points <= 0 {
+		points = defaultChartPoints
+	}
+
+	points = min(points, maxChartPoints)
+
+	from := now.Add(-since)
+	bucket := max(since/time.Duration(points), time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := q.Names
+	if len(names) == 0 {
+		for name := range m.rings {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+	}
+
+	out := []MeterSeries{}
+
+	for _, name := range names {
+		r := m.rings[name]
+		if r == nil {
+			continue
+		}
+
+		series := MeterSeries{Name: name, Unit: r.unit, Points: []MeterPoint{}}
+
+		var (
+			cur   *MeterPoint
+			n     int
+			start time.Time
+		)
+
+		r.each(from, func(s meterSample) {
+			at := from.Add(s.at.Sub(from) / bucket * bucket)
+			if cur == nil || !at.Equal(start) {
+				if cur != nil {
+					cur.Avg /= float64(n)
+				}
+
+				series.Points = append(series.Points, MeterPoint{At: at.UTC(), Min: s.value, Max: s.value})
+				cur, n, start = &series.Points[len(series.Points)-1], 0, at
+			}
+
+			cur.Min = min(cur.Min, s.value)
+			cur.Max = max(cur.Max, s.value)
+			cur.Avg += s.value
+			n++
+		})
+
+		if cur != nil {
+			cur.Avg /= float64(n)
+		}
+
+		out = append(out, series)
+	}
+
+	return out
+}
+
+// meterValue scales a raw meter reading by its unit, as FlexLib does, giving
+// forward and reflected power in watts rather than dBm.
+func meterValue(name string, raw int16, unit string) (float64, string) {
+	switch unit {
+	case "dBm", "dBFS", "SWR":
+		v := float64(raw) / 128
+		if unit == "dBm" && (name == "FWDPWR" || name == "REFPWR") {
+			return math.Round(math.Pow(10, v/10)/10) / 100, "W"
+		}
+
+		return v, unit
+	case "Volts", "Amps":
+		return float64(raw) / 256, unit
+	case "degF", "degC":
+		return float64(raw) / 64, unit
+	default:
+		return float64(raw), unit
+	}
+}
+
+// noteMeters records a meter packet and reports the alerts it moved.
+func (rc *radioConn) noteMeters(v vitaView, now time.Time) {
+	rc.mu.RLock()
+	alerts := rc.meterAlerts
+	onAlert := rc.onMeterAlert
+	transmitting := rc.interlockState == "TRANSMITTING"
+	rc.mu.RUnlock()
+
+	for _, ev := range rc.meters.add(v.Payload, now, transmitting, alerts) {
+		ev.Radio = rc.addr
+		if onAlert != nil {
+			onAlert(ev)
+		}
+	}
+}
+
+// Meters returns the session's downsampled meter history. It reports false
+// when there is no such session.
+func (s *Server) Meters(id string, q MeterQuery) ([]MeterSeries, bool) {
+	cs := s.session(id)
+	if cs == nil {
+		return nil, false
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return []MeterSeries{}, true
+	}
+
+	return rc.meters.history(q, time.Now()), true
+}
+
+// reportMeterAlert tells the client, and the event stream, of an alert being
+// raised or cleared.
+func (cs *clientSession) reportMeterAlert(ev MeterAlertEvent) {
+	ev.Session = cs.id
+
+	cs.trySend(mustEncode(typeMeterAlert, ev))
+	cs.srv.events.Publish(EventMeterAlert, ev)
+}
+
+// metersRequest is a client's "meters" message, asking for its meter history
+// as MeterQuery describes, with Since as a duration, e.g. "5m".
+type metersRequest struct {
+	Names  []string `json:"names,omitempty"`
+	Since  string   `json:"since,omitempty"`
+	Points int      `json:"points,omitempty"`
+}
+
+func (cs *clientSession) handleMeters(raw json.RawMessage) {
+	var req metersRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	q := MeterQuery{Names: req.Names, Points: req.Points}
+
+	if req.Since != "" {
+		q.Since, err = time.ParseDuration(req.Since)
+		if err != nil {
+			cs.trySend(mustEncode(typeError, errorPayload{Code: "METERS_FAILED", Message: err.Error()}))
+
+			return
+		}
+	}
+
+	series, _ := cs.srv.Meters(cs.id, q)
+	cs.trySend(mustEncode(typeMeters, series))
+}
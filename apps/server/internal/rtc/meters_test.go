@@ -0,0 +1,164 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// meterPacket builds a meter packet payload of id, raw value pairs.
+func meterPacket(readings ...int) []byte {
+	var b []byte
+
+	for i := 0; i+1 < len(readings); i += 2 {
+		b = binary.BigEndian.AppendUint16(b, uint16(readings[i]))   //nolint:gosec // test ids are small
+		b = binary.BigEndian.AppendUint16(b, uint16(readings[i+1])) //nolint:gosec // signed on the wire
+	}
+
+	return b
+}
+
+func meterLogWithDefs() *meterLog {
+	m := &meterLog{}
+	m.observe("meter 3.src=TX-#3.num=1#3.nam=SWR#3.unit=SWR#3.fps=20#")
+	m.observe("meter 4.src=TX-#4.num=2#4.nam=FWDPWR#4.unit=dBm#")
+	m.observe("meter 9.src=SLC#9.num=0#9.nam=LEVEL#9.unit=dBm#")
+
+	return m
+}
+
+func TestMeterLog_KeepsChartedMeters(t *testing.T) {
+	t.Parallel()
+
+	m := meterLogWithDefs()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	// SWR 1.5:1, 50 dBm (100 W), and a slice level that isn't charted.
+	m.add(meterPacket(3, 192, 4, 50*128, 9, -100*128), now, false, nil)
+
+	got := m.history(MeterQuery{}, now.Add(time.Second))
+	if len(got) != 2 || got[0].Name != "FWDPWR" || got[1].Name != "SWR" {
+		t.Fatalf("history = %+v", got)
+	}
+
+	if got[0].Unit != "W" || got[0].Points[0].Max != 100 {
+		t.Errorf("FWDPWR = %+v", got[0])
+	}
+
+	if got[1].Points[0].Avg != 1.5 {
+		t.Errorf("SWR = %+v", got[1])
+	}
+}
+
+func TestMeterLog_Downsamples(t *testing.T) {
+	t.Parallel()
+
+	m := meterLogWithDefs()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	// Ten seconds at 20 a second, SWR stepping from 1.0 to 2.0 after three
+	// and a half.
+	for i := range 200 {
+		raw := 128
+		if i >= 70 {
+			raw = 256
+		}
+
+		m.add(meterPacket(3, raw), now.Add(time.Duration(i)*50*time.Millisecond), false, nil)
+	}
+
+	got := m.history(MeterQuery{Names: []string{"SWR", "ALC"}, Since: 10 * time.Second, Points: 4}, now.Add(10*time.Second))
+	if len(got) != 1 || len(got[0].Points) != 4 {
+		t.Fatalf("history = %+v", got)
+	}
+
+	p := got[0].Points
+	if p[0].Avg != 1 || p[1].Min != 1 || p[1].Max != 2 || p[3].Avg != 2 {
+		t.Errorf("points = %+v", p)
+	}
+
+	if !p[1].At.Equal(now.Add(2500 * time.Millisecond)) {
+		t.Errorf("second bucket at %s", p[1].At)
+	}
+}
+
+func TestMeterLog_RingOverwritesOldest(t *testing.T) {
+	t.Parallel()
+
+	m := meterLogWithDefs()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	for i := range meterCapacity + 10 {
+		m.add(meterPacket(3, 128+i%2), now.Add(time.Duration(i)*time.Millisecond), false, nil)
+	}
+
+	r := m.rings["SWR"]
+	if len(r.samples) != meterCapacity {
+		t.Fatalf("%d samples", len(r.samples))
+	}
+
+	var first time.Time
+
+	r.each(time.Time{}, func(s meterSample) {
+		if first.IsZero() {
+			first = s.at
+		}
+	})
+
+	if !first.Equal(now.Add(10 * time.Millisecond)) {
+		t.Errorf("oldest sample at %s", first)
+	}
+}
+
+func TestMeterLog_Alerts(t *testing.T) {
+	t.Parallel()
+
+	m := meterLogWithDefs()
+	alerts := []MeterAlert{{Meter: "SWR", Limit: 3, TX: true}}
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	// High SWR on receive doesn't count.
+	if fired := m.add(meterPacket(3, 4*128), now, false, alerts); len(fired) != 0 {
+		t.Fatalf("fired on receive: %+v", fired)
+	}
+
+	fired := m.add(meterPacket(3, 4*128), now.Add(time.Second), true, alerts)
+	if len(fired) != 1 || !fired[0].Raised || fired[0].Value != 4 || fired[0].Unit != "SWR" {
+		t.Fatalf("fired = %+v", fired)
+	}
+
+	if fired = m.add(meterPacket(3, 5*128), now.Add(2*time.Second), true, alerts); len(fired) != 0 {
+		t.Fatalf("raised again: %+v", fired)
+	}
+
+	// Back within the limit, it clears only once it has stayed there.
+	if fired = m.add(meterPacket(3, 128), now.Add(3*time.Second), true, alerts); len(fired) != 0 {
+		t.Fatalf("cleared at once: %+v", fired)
+	}
+
+	fired = m.add(meterPacket(3, 128), now.Add(3*time.Second+alertClear), true, alerts)
+	if len(fired) != 1 || fired[0].Raised {
+		t.Fatalf("fired = %+v", fired)
+	}
+}
+
+func TestMeterLog_BelowAlertAndRemovedMeter(t *testing.T) {
+	t.Parallel()
+
+	m := &meterLog{}
+	m.observe("meter 12.src=RAD#12.num=0#12.nam=+13.8A#12.unit=Volts#")
+
+	alerts := []MeterAlert{{Meter: "+13.8A", Limit: 12.5, Below: true}}
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	fired := m.add(meterPacket(12, 12*256), now, false, alerts)
+	if len(fired) != 1 || !fired[0].Raised || fired[0].Value != 12 {
+		t.Fatalf("fired = %+v", fired)
+	}
+
+	m.observe("meter 12 removed")
+
+	if fired = m.add(meterPacket(12, 11*256), now.Add(time.Minute), false, alerts); len(fired) != 0 {
+		t.Fatalf("removed meter fired: %+v", fired)
+	}
+}
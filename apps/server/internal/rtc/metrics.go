@@ -0,0 +1,130 @@
+package rtc
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/metrics"
+)
+
+// MetricsPath serves the bridge's metrics for Prometheus to scrape.
+const MetricsPath = "/metrics"
+
+// ServeMetrics handles GET MetricsPath.
+func (s *Server) ServeMetrics(w http.ResponseWriter, _ *http.Request) {
+	var e metrics.Exposition
+
+	s.addMetrics(&e, time.Now())
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = e.WriteTo(w)
+}
+
+// addMetrics adds a series per discovered radio and per session, rather
+// than totals, so that dashboards can tell apart two radios served by one
+// bridge, or two sessions on one radio. A session's series are labelled
+// with its radio's address, serial and nickname, and its ID and radio
+// client handle.
+func (s *Server) addMetrics(e *metrics.Exposition, now time.Time) {
+	byHost := make(map[string]discovery.Radio)
+
+	if s.disco != nil {
+		for _, r := range s.disco.Registry().List(now) {
+			e.Add("solidsdr_radio_online", metrics.Gauge, "Whether a discovered radio is online.", flag(r.Online),
+				radioLabels(r, "")...)
+
+			if r.Host != "" {
+				byHost[r.Host] = r
+			}
+		}
+	}
+
+	sessions := s.Sessions()
+
+	e.Add("solidsdr_sessions", metrics.Gauge, "Sessions open on the bridge.", float64(len(sessions)))
+
+	for _, si := range sessions {
+		host, _, err := net.SplitHostPort(si.Radio)
+		if err != nil {
+			host = si.Radio
+		}
+
+		labels := append(radioLabels(byHost[host], si.Radio),
+			metrics.Label{Name: "session", Value: si.ID}, metrics.Label{Name: "handle", Value: si.Handle})
+
+		e.Add("solidsdr_session_info", metrics.Gauge, "A session, with its role and user as labels.", 1,
+			append(labels, metrics.Label{Name: "role", Value: si.Role}, metrics.Label{Name: "user", Value: si.User})...)
+		e.Add("solidsdr_session_listeners", metrics.Gauge, "WHEP players and sessions listening to a session.",
+			float64(si.Listeners), labels...)
+
+		if si.Radio == "" {
+			continue
+		}
+
+		e.Add("solidsdr_session_radio_unhealthy", metrics.Gauge, "Whether a session's radio has stopped answering pings.",
+			flag(si.RadioUnhealthy), labels...)
+		e.Add("solidsdr_session_tx_token", metrics.Gauge, "Whether a session holds its radio's TX token.",
+			flag(si.TXToken), labels...)
+
+		counter := func(name, help string, v uint64) {
+			e.Add(name, metrics.Counter, help, float64(v), labels...)
+		}
+
+		counter("solidsdr_radio_tcp_received_bytes_total", "API bytes received from the radio.", si.Bytes.TCPFromRadio)
+		counter("solidsdr_radio_tcp_sent_bytes_total", "API bytes sent to the radio.", si.Bytes.TCPToRadio)
+		counter("solidsdr_radio_udp_received_bytes_total", "VITA-49 bytes received from the radio.", si.Bytes.UDPFromRadio)
+		counter("solidsdr_radio_udp_sent_bytes_total", "VITA-49 bytes sent to the radio.", si.Bytes.UDPToRadio)
+		counter("solidsdr_radio_udp_dropped_packets_total",
+			"Radio packets withheld from the client by fairness, a full queue or backlog, or the bandwidth budget.",
+			si.Bytes.UDPDropped)
+
+		if p := si.UDP; p != nil {
+			e.Add("solidsdr_udp_pipeline_queued", metrics.Gauge, "Radio packets waiting for a worker.", float64(p.Queued), labels...)
+			counter("solidsdr_udp_pipeline_queue_drops_total", "Radio packets dropped because the queue was full.", p.QueueDrops)
+			counter("solidsdr_udp_pipeline_parse_errors_total", "Radio packets that were not VITA-49.", p.ParseErrors)
+
+			if p.KernelDrops != nil {
+				counter("solidsdr_udp_pipeline_kernel_drops_total",
+					"Datagrams the kernel dropped with the socket's buffer full.", *p.KernelDrops)
+			}
+		}
+
+		if st, ok := s.Stats(si.ID); ok && !st.At.IsZero() {
+			e.Add("solidsdr_webrtc_rtt_seconds", metrics.Gauge, "Round trip on the session's selected candidate pair.",
+				st.RTTMs/1000, labels...)
+			e.Add("solidsdr_webrtc_rx_jitter_seconds", metrics.Gauge, "RX audio jitter as the client reports it.",
+				st.RXJitterMs/1000, labels...)
+			e.Add("solidsdr_webrtc_rx_packets_lost_total", metrics.Counter, "RX audio packets the client reports lost.",
+				float64(st.RXPacketsLost), labels...)
+			e.Add("solidsdr_webrtc_tx_packets_lost_total", metrics.Counter, "TX audio packets lost on the way to the bridge.",
+				float64(st.TXPacketsLost), labels...)
+		}
+	}
+}
+
+// radioLabels labels a radio's series; addr, as the bridge dialled it,
+// stands in for the discovered host and port when set.
+func radioLabels(r discovery.Radio, addr string) []metrics.Label {
+	if addr == "" && r.Port != 0 {
+		addr = net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
+	}
+
+	return []metrics.Label{
+		{Name: "radio", Value: addr},
+		{Name: "serial", Value: r.Serial},
+		{Name: "nickname", Value: r.Nickname},
+		{Name: "model", Value: r.Model},
+	}
+}
+
+func flag(on bool) float64 {
+	if on {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,41 @@
+package rtc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeMetrics_LabelsEachSession(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+
+	for id, radio := range map[string]*radioConn{
+		"a": {addr: "10.0.0.5:4992", handleHex: "11111111"},
+		"b": {addr: "10.0.0.6:4992", handleHex: "22222222"},
+	} {
+		s.addSession(&clientSession{id: id, srv: s, role: "operator", radio: radio})
+		radio.tcpIn.Store(100)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeMetrics(w, httptest.NewRequest(http.MethodGet, MetricsPath, nil))
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"solidsdr_sessions 2\n",
+		`solidsdr_radio_tcp_received_bytes_total{radio="10.0.0.5:4992",session="a",handle="0x11111111"} 100`,
+		`solidsdr_radio_tcp_received_bytes_total{radio="10.0.0.6:4992",session="b",handle="0x22222222"} 100`,
+		`solidsdr_session_info{radio="10.0.0.5:4992",session="a",handle="0x11111111",role="operator"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s in:\n%s", want, body)
+		}
+	}
+
+	if strings.Count(body, "# TYPE solidsdr_radio_tcp_received_bytes_total") != 1 {
+		t.Errorf("family split:\n%s", body)
+	}
+}
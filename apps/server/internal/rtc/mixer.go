@@ -0,0 +1,303 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	errMixerRequest = errors.New("bad mixer request")
+	errMixerGuard   = errors.New("refused by the command guard")
+)
+
+// agcModes are the radio's AGC modes.
+var agcModes = []string{"off", "slow", "med", "fast"}
+
+// SliceMix is a slice's audio, as its "slice" status reports it. Gain, pan
+// and the AGC levels run from 0 to 100; a pan of 50 is centred.
+type SliceMix struct {
+	Slice        int    `json:"slice"`
+	Gain         int    `json:"gain"`
+	Pan          int    `json:"pan"`
+	Mute         bool   `json:"mute"`
+	AGCMode      string `json:"agcMode,omitempty"`
+	AGCThreshold int    `json:"agcThreshold"`
+	AGCOffLevel  int    `json:"agcOffLevel"`
+}
+
+// RadioMixer answers a "mixer" message, as "mixerSlices", and the mixer
+// endpoint, and is sent again whenever a slice's audio changes.
+type RadioMixer struct {
+	Slices []SliceMix `json:"slices"`
+}
+
+// MixerRequest is a client's "mixer" message. Action is "list", which also
+// subscribes to changes, or "set", which changes only the fields given on
+// Slice.
+type MixerRequest struct {
+	Action       string `json:"action"`
+	Slice        int    `json:"slice"`
+	Gain         *int   `json:"gain,omitempty"`
+	Pan          *int   `json:"pan,omitempty"`
+	Mute         *bool  `json:"mute,omitempty"`
+	AGCMode      string `json:"agcMode,omitempty"`
+	AGCThreshold *int   `json:"agcThreshold,omitempty"`
+	AGCOffLevel  *int   `json:"agcOffLevel,omitempty"`
+}
+
+// mixerTable tracks each in-use slice's audio from "slice" status.
+type mixerTable struct {
+	mu     sync.Mutex
+	slices map[int]SliceMix
+}
+
+// observe applies a "slice <n> key=value ..." status body. It reports
+// whether any slice's audio changed.
+func (t *mixerTable) observe(body string) bool {
+	rest, ok := strings.CutPrefix(body, "slice ")
+	if !ok {
+		return false
+	}
+
+	idx, attrs, _ := strings.Cut(rest, " ")
+
+	index, err := strconv.Atoi(idx)
+	if err != nil {
+		return false
+	}
+
+	f := statusAttrs(attrs)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if f["in_use"] == "0" {
+		_, known := t.slices[index]
+		delete(t.slices, index)
+
+		return known
+	}
+
+	if t.slices == nil {
+		t.slices = make(map[int]SliceMix)
+	}
+
+	old, known := t.slices[index]
+	m := old
+	m.Slice = index
+
+	for k, v := range f {
+		switch k {
+		case "audio_level":
+			m.Gain, _ = strconv.Atoi(v)
+		case "audio_pan":
+			m.Pan, _ = strconv.Atoi(v)
+		case "audio_mute":
+			m.Mute = v == "1"
+		case "agc_mode":
+			m.AGCMode = v
+		case "agc_threshold":
+			m.AGCThreshold, _ = strconv.Atoi(v)
+		case "agc_off_level":
+			m.AGCOffLevel, _ = strconv.Atoi(v)
+		}
+	}
+
+	t.slices[index] = m
+
+	return !known || m != old
+}
+
+// has reports whether slice index is in use, as far as status has said.
+func (t *mixerTable) has(index int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.slices[index]
+
+	return ok
+}
+
+// snapshot returns the slices in index order.
+func (t *mixerTable) snapshot() RadioMixer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := RadioMixer{Slices: make([]SliceMix, 0, len(t.slices))}
+	for _, s := range t.slices {
+		m.Slices = append(m.Slices, s)
+	}
+
+	slices.SortFunc(m.Slices, func(a, b SliceMix) int { return a.Slice - b.Slice })
+
+	return m
+}
+
+// observeMixer tracks the slices' audio from a status body.
+func (rc *radioConn) observeMixer(body string) {
+	if !rc.mixer.observe(body) {
+		return
+	}
+
+	rc.mu.RLock()
+	onMixer := rc.onMixer
+	rc.mu.RUnlock()
+
+	if onMixer != nil {
+		onMixer()
+	}
+}
+
+// subscribeSlices asks the radio for "slice" status, once per connection.
+func (rc *radioConn) subscribeSlices() error {
+	rc.mu.Lock()
+	done := rc.slicesSubscribed
+	rc.slicesSubscribed = true
+	rc.mu.Unlock()
+
+	if done {
+		return nil
+	}
+
+	_, err := rc.clientCommand("sub slice all")
+	if err != nil {
+		rc.mu.Lock()
+		rc.slicesSubscribed = false
+		rc.mu.Unlock()
+	}
+
+	return err
+}
+
+// mixerCommand is the radio command for a "set" request.
+func mixerCommand(req MixerRequest) (string, error) {
+	var b strings.Builder
+
+	for _, level := range []struct {
+		name  string
+		value *int
+	}{
+		{"audio_level", req.Gain},
+		{"audio_pan", req.Pan},
+		{"agc_threshold", req.AGCThreshold},
+		{"agc_off_level", req.AGCOffLevel},
+	} {
+		if level.value == nil {
+			continue
+		}
+
+		if *level.value < 0 || *level.value > 100 {
+			return "", fmt.Errorf("%w: %s %d is not 0 to 100", errMixerRequest, level.name, *level.value)
+		}
+
+		fmt.Fprintf(&b, " %s=%d", level.name, *level.value)
+	}
+
+	if req.Mute != nil {
+		b.WriteString(" audio_mute=" + boolFlag(*req.Mute))
+	}
+
+	if req.AGCMode != "" {
+		if !slices.Contains(agcModes, req.AGCMode) {
+			return "", fmt.Errorf("%w: agcMode %q", errMixerRequest, req.AGCMode)
+		}
+
+		b.WriteString(" agc_mode=" + req.AGCMode)
+	}
+
+	if b.Len() == 0 {
+		return "", fmt.Errorf("%w: nothing to set", errMixerRequest)
+	}
+
+	return fmt.Sprintf("slice set %d%s", req.Slice, b.String()), nil
+}
+
+// runMixer lists the slices' audio, or sets one's, for the session. Each
+// command must pass the guard as if the client had sent it.
+func (cs *clientSession) runMixer(req MixerRequest) (RadioMixer, error) {
+	rc := cs.currentRadio()
+	if rc == nil {
+		return RadioMixer{}, errClientNoRadio
+	}
+
+	switch req.Action {
+	case "list":
+		err := rc.subscribeSlices()
+
+		return rc.mixer.snapshot(), err
+	case "set":
+	default:
+		return RadioMixer{}, fmt.Errorf("%w: action %q", errMixerRequest, req.Action)
+	}
+
+	cmd, err := mixerCommand(req)
+	if err != nil {
+		return RadioMixer{}, err
+	}
+
+	if !rc.mixer.has(req.Slice) {
+		return RadioMixer{}, fmt.Errorf("%w %d", errUnknownSlice, req.Slice)
+	}
+
+	if !cs.allowImmediate(rc, fmt.Sprintf("C%d|%s\n", internalClientSequence, cmd)) {
+		return RadioMixer{}, errMixerGuard
+	}
+
+	_, err = rc.clientCommand(cmd)
+	if err != nil {
+		return RadioMixer{}, err
+	}
+
+	return rc.mixer.snapshot(), nil
+}
+
+func (cs *clientSession) handleMixer(raw json.RawMessage) {
+	var req MixerRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	if req.Action == "list" {
+		cs.mixer.Store(true)
+	}
+
+	m, err := cs.runMixer(req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "MIXER_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeMixerSlices, m))
+}
+
+// mixerChanged updates a session that listed the slices' audio.
+func (cs *clientSession) mixerChanged(rc *radioConn) {
+	if cs.mixer.Load() {
+		cs.trySend(mustEncode(typeMixerSlices, rc.mixer.snapshot()))
+	}
+}
+
+// Mixer lists session id's radio's slices' audio.
+func (s *Server) Mixer(id string) (RadioMixer, error) {
+	return s.MixerAction(id, MixerRequest{Action: "list"})
+}
+
+// MixerAction runs req for session id as its client's "mixer" message would.
+func (s *Server) MixerAction(id string, req MixerRequest) (RadioMixer, error) {
+	cs := s.session(id)
+	if cs == nil {
+		return RadioMixer{}, ErrNoSession
+	}
+
+	return cs.runMixer(req)
+}
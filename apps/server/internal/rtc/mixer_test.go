@@ -0,0 +1,128 @@
+package rtc
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestMixerTable_Observe(t *testing.T) {
+	t.Parallel()
+
+	var tbl mixerTable
+
+	if !tbl.observe("slice 1 in_use=1 RF_frequency=7.074000 audio_level=40 audio_pan=20 audio_mute=1 agc_mode=fast agc_threshold=65 agc_off_level=10") {
+		t.Error("a new slice is not a change")
+	}
+
+	tbl.observe("slice 0 in_use=1 audio_level=50 audio_pan=50 audio_mute=0 agc_mode=med")
+
+	if tbl.observe("slice 0 RF_frequency=14.074000") {
+		t.Error("a tune is a change")
+	}
+
+	m := tbl.snapshot()
+	if len(m.Slices) != 2 || m.Slices[0].Slice != 0 {
+		t.Fatalf("slices = %+v", m.Slices)
+	}
+
+	want := SliceMix{Slice: 1, Gain: 40, Pan: 20, Mute: true, AGCMode: "fast", AGCThreshold: 65, AGCOffLevel: 10}
+	if m.Slices[1] != want {
+		t.Errorf("slice 1 = %+v, want %+v", m.Slices[1], want)
+	}
+
+	if !tbl.observe("slice 1 in_use=0") || tbl.has(1) {
+		t.Error("removal not applied")
+	}
+
+	if tbl.observe("profile global current=A") {
+		t.Error("not a slice status")
+	}
+}
+
+func TestMixerCommand(t *testing.T) {
+	t.Parallel()
+
+	level, on := 30, true
+
+	got, err := mixerCommand(MixerRequest{Action: "set", Slice: 2, Gain: &level, Mute: &on, AGCMode: "slow"})
+	if err != nil || got != "slice set 2 audio_level=30 audio_mute=1 agc_mode=slow" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	loud := 101
+
+	for _, req := range []MixerRequest{
+		{Action: "set", Slice: 0},
+		{Action: "set", Slice: 0, Pan: &loud},
+		{Action: "set", Slice: 0, AGCMode: "medium"},
+	} {
+		_, err := mixerCommand(req)
+		if !errors.Is(err, errMixerRequest) {
+			t.Errorf("%+v: err = %v", req, err)
+		}
+	}
+}
+
+func TestClientSession_RunMixer(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{handleHex: "1234ABCD"}
+	rc.out = newTCPWriter(client, func([]byte) {}, nil)
+	t.Cleanup(rc.out.close)
+
+	cs := &clientSession{srv: &Server{}, radio: rc}
+
+	lines := make(chan string, 8)
+
+	// The radio answers "sub slice all" with each slice's status.
+	go func() {
+		rd := bufio.NewReader(radio)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimSpace(line)
+			lines <- line
+
+			if strings.HasSuffix(line, "|sub slice all") {
+				rc.observeMixer("slice 0 in_use=1 audio_level=50 audio_pan=50 audio_mute=0")
+			}
+
+			rc.consumeClientReply("R2147483640|0|")
+		}
+	}()
+
+	m, err := cs.runMixer(MixerRequest{Action: "list"})
+	if err != nil || len(m.Slices) != 1 {
+		t.Fatalf("list = %+v, %v", m, err)
+	}
+
+	pan := 0
+
+	_, err = cs.runMixer(MixerRequest{Action: "set", Slice: 0, Pan: &pan})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"C2147483640|sub slice all",
+		"C2147483640|slice set 0 audio_pan=0",
+	} {
+		if l := nextLine(t, lines); l != want {
+			t.Errorf("sent %q, want %q", l, want)
+		}
+	}
+
+	_, err = cs.runMixer(MixerRequest{Action: "set", Slice: 3, Pan: &pan})
+	if !errors.Is(err, errUnknownSlice) {
+		t.Errorf("unknown slice: err = %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/pion/webrtc/v4"
+)
+
+var errNegotiating = errors.New("session is not connected or is negotiating")
+
+// negotiation runs the server's half of the WebRTC perfect-negotiation
+// pattern. Either side may start an offer/answer exchange at any time: the
+// client when it adds or removes its microphone track, the bridge when it
+// changes its own tracks. pion cannot roll back a local offer, so the bridge
+// is the impolite peer: when offers cross it ignores the client's, and the
+// client rolls its own back and answers ours, renegotiating afterwards.
+type negotiation struct {
+	mu          sync.Mutex // serializes every description change on the PeerConnection
+	armed       bool       // server-initiated offers are enabled after the first answer
+	ignoreOffer bool       // the last client offer lost a collision
+}
+
+// answerOffer applies a client offer, initial or renegotiation, and sends the
+// answer.
+func (cs *clientSession) answerOffer(offer webrtc.SessionDescription) {
+	cs.neg.mu.Lock()
+	defer cs.neg.mu.Unlock()
+
+	cs.mu.Lock()
+	pc := cs.pc
+	cs.mu.Unlock()
+
+	cs.neg.ignoreOffer = pc.SignalingState() == webrtc.SignalingStateHaveLocalOffer
+	if cs.neg.ignoreOffer {
+		log.Printf("[rtc] session %s: offer collision, ignoring the client's", cs.id)
+
+		return
+	}
+
+	err := pc.SetRemoteDescription(offer)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "SET_REMOTE_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	answer, err := pc.CreateAnswer(&webrtc.AnswerOptions{
+		OfferAnswerOptions: webrtc.OfferAnswerOptions{
+			ICETricklingSupported: true,
+		},
+	})
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "ANSWER_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	err = pc.SetLocalDescription(answer)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "SET_LOCAL_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeAnswer, pc.LocalDescription()))
+
+	// Only now may the bridge offer: negotiationneeded fires while the
+	// initial answer is being built, and an offer then would race it.
+	if !cs.neg.armed {
+		cs.neg.armed = true
+
+		pc.OnNegotiationNeeded(func() { go cs.offer() })
+	}
+}
+
+// offer starts a server-initiated exchange if none is in progress.
+func (cs *clientSession) offer() {
+	err := cs.sendOffer(nil)
+	if err != nil && !errors.Is(err, errNegotiating) {
+		log.Printf("[rtc] session %s: %v", cs.id, err)
+	}
+}
+
+// sendOffer makes an offer with opts and sends it to the client. It fails
+// with errNegotiating before the first exchange has completed or while
+// another is in progress.
+func (cs *clientSession) sendOffer(opts *webrtc.OfferOptions) error {
+	cs.neg.mu.Lock()
+	defer cs.neg.mu.Unlock()
+
+	cs.mu.Lock()
+	pc := cs.pc
+	cs.mu.Unlock()
+
+	if pc == nil || !cs.neg.armed || pc.SignalingState() != webrtc.SignalingStateStable {
+		return errNegotiating
+	}
+
+	offer, err := pc.CreateOffer(opts)
+	if err != nil {
+		return fmt.Errorf("create offer: %w", err)
+	}
+
+	err = pc.SetLocalDescription(offer)
+	if err != nil {
+		return fmt.Errorf("set local offer: %w", err)
+	}
+
+	cs.trySend(mustEncode(typeOffer, pc.LocalDescription()))
+
+	return nil
+}
+
+// ignoringOffer reports whether the client's last offer lost a collision, in
+// which case its trickled candidates are expected to fail.
+func (cs *clientSession) ignoringOffer() bool {
+	cs.neg.mu.Lock()
+	defer cs.neg.mu.Unlock()
+
+	return cs.neg.ignoreOffer
+}
+
+// handleAnswer completes a server-initiated exchange. An answer with no offer
+// outstanding is stale and dropped.
+func (cs *clientSession) handleAnswer(raw json.RawMessage) {
+	var answer webrtc.SessionDescription
+
+	err := json.Unmarshal(raw, &answer)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	cs.neg.mu.Lock()
+	defer cs.neg.mu.Unlock()
+
+	cs.mu.Lock()
+	pc := cs.pc
+	cs.mu.Unlock()
+
+	if pc == nil || pc.SignalingState() != webrtc.SignalingStateHaveLocalOffer {
+		log.Printf("[rtc] session %s: ignoring stale answer", cs.id)
+
+		return
+	}
+
+	err = pc.SetRemoteDescription(answer)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "SET_REMOTE_FAILED", Message: err.Error()}))
+	}
+}
@@ -0,0 +1,297 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// negotiationPair returns a bridge-side session with its own PeerConnection
+// and a client PeerConnection, with no network between them: descriptions are
+// exchanged by hand.
+func negotiationPair(t *testing.T) (*clientSession, *webrtc.PeerConnection) {
+	t.Helper()
+
+	server, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	_, err = client.CreateDataChannel("tcp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &clientSession{id: "test", pc: server, send: make(chan message, 16)}, client
+}
+
+// clientOffer creates and applies a client offer.
+func clientOffer(t *testing.T, client *webrtc.PeerConnection) webrtc.SessionDescription {
+	t.Helper()
+
+	offer, err := client.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.SetLocalDescription(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return offer
+}
+
+// next returns the next message of type want sent to the client.
+func next(t *testing.T, cs *clientSession, want string) webrtc.SessionDescription {
+	t.Helper()
+
+	select {
+	case msg := <-cs.send:
+		if msg.Type != want {
+			t.Fatalf("got %s message %s, want %s", msg.Type, msg.Payload, want)
+		}
+
+		var sd webrtc.SessionDescription
+
+		err := json.Unmarshal(msg.Payload, &sd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return sd
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s message", want)
+	}
+
+	return webrtc.SessionDescription{}
+}
+
+func TestNegotiation_ClientRenegotiatesWithMicrophone(t *testing.T) {
+	t.Parallel()
+
+	cs, client := negotiationPair(t)
+
+	cs.answerOffer(clientOffer(t, client))
+
+	err := client.SetRemoteDescription(next(t, cs, typeAnswer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mic, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "mic", "mic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.AddTrack(mic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs.answerOffer(clientOffer(t, client))
+
+	err = client.SetRemoteDescription(next(t, cs, typeAnswer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(cs.pc.GetTransceivers()); got != 1 {
+		t.Errorf("server has %d transceivers after renegotiation, want 1 (the microphone)", got)
+	}
+}
+
+func TestNegotiation_ImpoliteOnCollision(t *testing.T) {
+	t.Parallel()
+
+	cs, client := negotiationPair(t)
+
+	cs.answerOffer(clientOffer(t, client))
+
+	err := client.SetRemoteDescription(next(t, cs, typeAnswer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bridge adds a track and offers while the client offers too.
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "a", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cs.pc.AddTrack(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs.offer()
+	serverOffer := next(t, cs, typeOffer)
+
+	_, err = client.CreateDataChannel("udp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs.answerOffer(clientOffer(t, client))
+
+	if cs.pc.SignalingState() != webrtc.SignalingStateHaveLocalOffer || !cs.ignoringOffer() {
+		t.Fatalf("server state %s, want its own offer kept", cs.pc.SignalingState())
+	}
+
+	select {
+	case msg := <-cs.send:
+		t.Fatalf("colliding offer should be ignored silently, got %s %s", msg.Type, msg.Payload)
+	default:
+	}
+
+	// Candidates from the ignored offer are dropped without an error.
+	cand, _ := json.Marshal(webrtc.ICECandidateInit{Candidate: "candidate:1 1 udp 1 192.0.2.1 9 typ host"})
+	cs.handleICE(cand)
+
+	select {
+	case msg := <-cs.send:
+		t.Fatalf("unexpected %s %s", msg.Type, msg.Payload)
+	default:
+	}
+
+	// The polite client answers ours; a fresh PeerConnection stands in for
+	// it, since pion cannot roll back either.
+	polite, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = polite.Close() })
+
+	err = polite.SetRemoteDescription(serverOffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := polite.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = polite.SetLocalDescription(answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(answer)
+	cs.handleAnswer(raw)
+
+	if cs.pc.SignalingState() != webrtc.SignalingStateStable {
+		t.Errorf("server state %s, want stable", cs.pc.SignalingState())
+	}
+
+	// A second answer has no offer to complete and is dropped.
+	cs.handleAnswer(raw)
+
+	select {
+	case msg := <-cs.send:
+		t.Fatalf("stale answer should be dropped, got %s %s", msg.Type, msg.Payload)
+	default:
+	}
+}
+
+func TestNegotiation_ICERestart(t *testing.T) {
+	t.Parallel()
+
+	cs, client := negotiationPair(t)
+	restart := &webrtc.OfferOptions{ICERestart: true}
+
+	if err := cs.sendOffer(restart); !errors.Is(err, errNegotiating) {
+		t.Fatalf("restart before connecting: err = %v", err)
+	}
+
+	cs.answerOffer(clientOffer(t, client))
+
+	answer := next(t, cs, typeAnswer)
+
+	err := client.SetRemoteDescription(answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cs.sendOffer(restart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offer := next(t, cs, typeOffer)
+	if iceUfrag(offer.SDP) == "" || iceUfrag(offer.SDP) == iceUfrag(answer.SDP) {
+		t.Errorf("restart offer keeps ICE credentials %q", iceUfrag(offer.SDP))
+	}
+
+	// The client answers it as any server offer.
+	err = client.SetRemoteDescription(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := client.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.SetLocalDescription(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(reply)
+	cs.handleAnswer(raw)
+
+	if cs.pc.SignalingState() != webrtc.SignalingStateStable {
+		t.Errorf("server state %s after the restart, want stable", cs.pc.SignalingState())
+	}
+}
+
+// iceUfrag is the first ICE username fragment in sdp.
+func iceUfrag(sdp string) string {
+	_, rest, ok := strings.Cut(sdp, "a=ice-ufrag:")
+	if !ok {
+		return ""
+	}
+
+	ufrag, _, _ := strings.Cut(rest, "\r\n")
+
+	return ufrag
+}
+
+func TestHandleOffer_RefusesAnotherRadioConnection(t *testing.T) {
+	t.Parallel()
+
+	cs, client := negotiationPair(t)
+	cs.radio = &radioConn{key: radioKey("192.0.2.10:4992", "6A2B1C00")}
+
+	raw, _ := json.Marshal(offerPayload{
+		SessionDescription: clientOffer(t, client),
+		Radio:              radioKey("192.0.2.10:4992", "11111111"),
+	})
+	cs.handleOffer(t.Context(), raw)
+
+	msg := <-cs.send
+	if msg.Type != typeError || !strings.Contains(string(msg.Payload), "RADIO_CHANGED") {
+		t.Fatalf("got %s %s, want a RADIO_CHANGED error", msg.Type, msg.Payload)
+	}
+
+	if cs.pc.RemoteDescription() != nil {
+		t.Error("the offer was applied")
+	}
+}
@@ -0,0 +1,740 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// opusClockRate is the RTP clock of every Opus stream (RFC 7587).
+	opusClockRate = 48000
+	// maxAudioGap is the longest silence the radio's timestamps may claim
+	// between two packets; a longer one is taken as the stream restarting
+	// and played on from the last packet.
+	maxAudioGap = opusClockRate
+	// maxOpusPacket is the most audio one Opus packet may carry: 120 ms.
+	maxOpusPacket = opusClockRate * 120 / 1000
+	// VITA-49 TSF types the radio's audio may carry.
+	tsfSampleCount = 1
+	tsfRealTime    = 2
+	picosPerSecond = 1_000_000_000_000
+)
+
+// Redundancy modes: when RX audio carries RFC 2198 redundancy to listeners
+// that negotiated it.
+const (
+	RedundancyAuto = "auto" // while the listener reports loss
+	RedundancyOn   = "on"
+	RedundancyOff  = "off"
+)
+
+var errRedundancyMode = errors.New("redundancy mode must be auto, on or off")
+
+// audioRedundancyPayload sets, or with no mode asks for, the session's
+// redundancy mode.
+type audioRedundancyPayload struct {
+	Mode string `json:"mode,omitempty"`
+}
+
+const (
+	mimeTypeRED = "audio/red"
+	// redPayloadType is the payload type browsers offer RED for Opus on.
+	redPayloadType = 63
+	// redDistance is how many earlier packets each RED packet repeats, so
+	// a burst of that many losses is recovered.
+	redDistance = 2
+	// redLossOn is the fraction lost, out of 256, in a receiver report that
+	// turns redundancy on in RedundancyAuto: about 1%.
+	redLossOn = 3
+	// redHold is how long redundancy stays on after the last lossy report.
+	redHold = 30 * time.Second
+)
+
+// newMediaEngine registers the default codecs, whose Opus fmtp asks for
+// in-band FEC, and RED for Opus.
+func newMediaEngine() (*webrtc.MediaEngine, error) {
+	me := &webrtc.MediaEngine{}
+
+	err := me.RegisterDefaultCodecs()
+	if err != nil {
+		return nil, fmt.Errorf("register codecs: %w", err)
+	}
+
+	err = me.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType: mimeTypeRED, ClockRate: opusClockRate, Channels: 2, SDPFmtpLine: "111/111",
+		},
+		PayloadType: redPayloadType,
+	}, webrtc.RTPCodecTypeAudio)
+	if err != nil {
+		return nil, fmt.Errorf("register red: %w", err)
+	}
+
+	return me, nil
+}
+
+// opusTrack is the session's RX audio track. It packetizes the radio's Opus
+// itself rather than through a sample track, so RTP timestamps follow the
+// radio's clock, not the time packets happen to arrive: a late packet keeps
+// its place, a lost one leaves a gap for the receiver to conceal, and RTCP
+// sender reports map the radio's timeline. Its state outlives reconnects to
+// the radio, so listeners see one continuous stream.
+//
+// Each listener it is bound to, the session's PeerConnection and any WHEP
+// listeners, gets plain Opus or, per the redundancy mode and its own loss,
+// RED repeating the last redDistance packets.
+type opusTrack struct {
+	id, streamID string
+
+	mu       sync.Mutex
+	bindings map[string]*opusBinding
+	mode     string
+	history  []redBlock
+	started  bool
+	seq      uint16
+	ts       uint32
+	// samples is the duration of the last packet, and clock its VITA
+	// timestamp at the Opus clock rate, when it had one.
+	samples  uint32
+	clock    int64
+	hasClock bool
+	// sent is whether seq has been used.
+	sent bool
+	// frames is how many of the radio's packets each RTP packet carries,
+	// and pending those waiting for the rest.
+	frames  int
+	pending []pendingOpus
+	// relays are the tracks of sessions listening to this one's audio,
+	// replaced rather than changed so write can range over them unlocked.
+	relays []*opusTrack
+}
+
+// pendingOpus is one of the radio's packets, placed on the timeline and
+// waiting to be sent.
+type pendingOpus struct {
+	ts      uint32
+	marker  bool
+	payload []byte
+}
+
+// opusBinding is the track on one PeerConnection.
+type opusBinding struct {
+	ssrc   webrtc.SSRC
+	opusPT uint8
+	// redPT is RED's payload type, zero when the listener did not
+	// negotiate it.
+	redPT uint8
+	w     webrtc.TrackLocalWriter
+	// lossAt is when the listener last reported loss.
+	lossAt time.Time
+	// jitter, in samples, and lost are from its latest report.
+	jitter uint32
+	lost   uint32
+}
+
+// redBlock is an earlier packet, kept to repeat.
+type redBlock struct {
+	ts      uint32
+	payload []byte
+}
+
+func newOpusTrack(id, streamID string) *opusTrack {
+	return &opusTrack{
+		id:       id,
+		streamID: streamID,
+		bindings: make(map[string]*opusBinding),
+		mode:     RedundancyAuto,
+		frames:   1,
+		seq:      uint16(rand.N(1 << 16)), //nolint:gosec // RTP wants unpredictable, not secret
+		ts:       rand.Uint32(),           //nolint:gosec // likewise
+	}
+}
+
+func (t *opusTrack) ID() string                { return t.id }
+func (t *opusTrack) RID() string               { return "" }
+func (t *opusTrack) StreamID() string          { return t.streamID }
+func (t *opusTrack) Kind() webrtc.RTPCodecType { return webrtc.RTPCodecTypeAudio }
+
+// Bind sends the track on a PeerConnection that negotiated Opus.
+func (t *opusTrack) Bind(ctx webrtc.TrackLocalContext) (webrtc.RTPCodecParameters, error) {
+	var (
+		opus  webrtc.RTPCodecParameters
+		found bool
+		redPT uint8
+	)
+
+	for _, c := range ctx.CodecParameters() {
+		switch {
+		case strings.EqualFold(c.MimeType, webrtc.MimeTypeOpus) && !found:
+			opus, found = c, true
+		case strings.EqualFold(c.MimeType, mimeTypeRED):
+			redPT = uint8(c.PayloadType)
+		}
+	}
+
+	if !found {
+		return webrtc.RTPCodecParameters{}, webrtc.ErrUnsupportedCodec
+	}
+
+	t.mu.Lock()
+	t.bindings[ctx.ID()] = &opusBinding{
+		ssrc:   ctx.SSRC(),
+		opusPT: uint8(opus.PayloadType),
+		redPT:  redPT,
+		w:      ctx.WriteStream(),
+	}
+	t.mu.Unlock()
+
+	return opus, nil
+}
+
+func (t *opusTrack) Unbind(ctx webrtc.TrackLocalContext) error {
+	t.mu.Lock()
+	delete(t.bindings, ctx.ID())
+	t.mu.Unlock()
+
+	return nil
+}
+
+// setRedundancy sets the redundancy mode.
+func (t *opusTrack) setRedundancy(mode string) {
+	t.mu.Lock()
+	t.mode = mode
+	t.mu.Unlock()
+}
+
+func (t *opusTrack) redundancy() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.mode
+}
+
+// readRTCP notes the loss the listener on sender reports, until the
+// sender is closed. It also keeps the sender's interceptors running.
+func (t *opusTrack) readRTCP(sender *webrtc.RTPSender) {
+	for {
+		pkts, _, err := sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+
+		for _, p := range pkts {
+			if rr, ok := p.(*rtcp.ReceiverReport); ok {
+				for _, r := range rr.Reports {
+					t.reportLoss(webrtc.SSRC(r.SSRC), r.FractionLost, time.Now())
+					t.noteReport(r)
+				}
+			}
+		}
+	}
+}
+
+func (t *opusTrack) reportLoss(ssrc webrtc.SSRC, fractionLost uint8, now time.Time) {
+	if fractionLost < redLossOn {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range t.bindings {
+		if b.ssrc == ssrc {
+			b.lossAt = now
+		}
+	}
+}
+
+// noteReport keeps the jitter and loss a listener reports.
+func (t *opusTrack) noteReport(r rtcp.ReceptionReport) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range t.bindings {
+		if b.ssrc == webrtc.SSRC(r.SSRC) {
+			b.jitter, b.lost = r.Jitter, r.TotalLost
+		}
+	}
+}
+
+// reception is the jitter, in samples, and loss the listener on ssrc last
+// reported.
+func (t *opusTrack) reception(ssrc webrtc.SSRC) (uint32, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range t.bindings {
+		if b.ssrc == ssrc {
+			return b.jitter, b.lost
+		}
+	}
+
+	return 0, 0
+}
+
+// sendsRED is whether b gets RED now; t.mu is held.
+func (t *opusTrack) sendsRED(b *opusBinding, now time.Time) bool {
+	if b.redPT == 0 {
+		return false
+	}
+
+	switch t.mode {
+	case RedundancyOn:
+		return true
+	case RedundancyAuto:
+		return !b.lossAt.IsZero() && now.Sub(b.lossAt) < redHold
+	default:
+		return false
+	}
+}
+
+// write sends the Opus payload of a VITA audio packet, once enough have
+// arrived to fill an RTP packet. No-op when there is no track or payload.
+func (t *opusTrack) write(v vitaView) {
+	if t == nil || len(v.Payload) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	relays := t.relays
+	t.mu.Unlock()
+
+	for _, r := range relays {
+		r.write(v)
+	}
+
+	pkts := t.packets(v)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, pkt := range pkts {
+		for _, b := range t.bindings {
+			hdr := pkt.Header
+			hdr.SSRC = uint32(b.ssrc)
+			hdr.PayloadType = b.opusPT
+			payload := pkt.Payload
+
+			if t.sendsRED(b, now) {
+				hdr.PayloadType = b.redPT
+				payload = encodeRED(b.opusPT, hdr.Timestamp, t.history, pkt.Payload)
+			}
+
+			_, _ = b.w.WriteRTP(&hdr, payload)
+		}
+
+		t.history = append(t.history, redBlock{ts: pkt.Timestamp, payload: append([]byte(nil), pkt.Payload...)})
+		if len(t.history) > redDistance {
+			t.history = t.history[1:]
+		}
+	}
+}
+
+// setFrames sets how many of the radio's packets each RTP packet carries.
+func (t *opusTrack) setFrames(n int) {
+	t.mu.Lock()
+	t.frames = max(n, 1)
+	t.mu.Unlock()
+}
+
+// packets places the payload of v on the stream's timeline and returns the
+// RTP packets it completes: none while it waits for more to aggregate with,
+// usually one.
+func (t *opusTrack) packets(v vitaView) []*rtp.Packet {
+	samples := uint32(opusFrameSamples(v.Payload)) //nolint:gosec // at most 120 ms of samples
+	if samples == 0 {
+		samples = opusClockRate / 100 // the radio's 10 ms frames
+	}
+
+	clock, hasClock := vitaClock(v)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	marker := !t.started
+	if t.started {
+		advance := t.samples
+
+		// Timestamps can only move the stream on by at least the last
+		// packet's duration; anything more is audio that never arrived.
+		if hasClock && t.hasClock {
+			if gap := clock - t.clock; gap > int64(t.samples) && gap <= maxAudioGap {
+				advance = uint32(gap)
+				marker = true
+			}
+		}
+
+		t.ts += advance
+	}
+
+	t.started = true
+	t.samples = samples
+	t.clock, t.hasClock = clock, hasClock
+
+	var out []*rtp.Packet
+
+	// A gap or a change of mode ends the packet being aggregated, as does
+	// reaching the 120 ms one Opus packet may hold.
+	if len(t.pending) > 0 {
+		first := t.pending[0]
+		if marker || first.payload[0]&0xFC != v.Payload[0]&0xFC || t.ts-first.ts+samples > maxOpusPacket {
+			out = t.flush()
+		}
+	}
+
+	// The payload is the reader's buffer, so one held for later is copied.
+	payload := v.Payload
+	if t.frames > 1 {
+		payload = append([]byte(nil), payload...)
+	}
+
+	t.pending = append(t.pending, pendingOpus{ts: t.ts, marker: marker, payload: payload})
+	if len(t.pending) >= t.frames {
+		out = append(out, t.flush()...)
+	}
+
+	return out
+}
+
+// flush packs the pending payloads into one RTP packet, or sends them one
+// to a packet when they cannot be repacked; t.mu is held.
+func (t *opusTrack) flush() []*rtp.Packet {
+	pending := t.pending
+	t.pending = nil
+
+	if len(pending) > 1 {
+		if payload := opusRepack(pending); payload != nil {
+			pending = []pendingOpus{{ts: pending[0].ts, marker: pending[0].marker, payload: payload}}
+		}
+	}
+
+	out := make([]*rtp.Packet, len(pending))
+	for i, p := range pending {
+		if t.sent {
+			t.seq++
+		}
+
+		t.sent = true
+		out[i] = &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         p.marker,
+				SequenceNumber: t.seq,
+				Timestamp:      p.ts,
+			},
+			Payload: p.payload,
+		}
+	}
+
+	return out
+}
+
+// opusRepack joins the frames of several Opus packets of one mode into a
+// single code 3 packet with its frame sizes given (RFC 6716 section 3.2.5).
+// Nil when a packet cannot be parsed or the frames do not fit one packet.
+func opusRepack(pending []pendingOpus) []byte {
+	var frames [][]byte
+
+	for _, p := range pending {
+		f := opusFrames(p.payload)
+		if f == nil {
+			return nil
+		}
+
+		frames = append(frames, f...)
+	}
+
+	if len(frames) > 48 {
+		return nil
+	}
+
+	b := []byte{pending[0].payload[0] | 0x03, 0x80 | byte(len(frames))}
+
+	for _, f := range frames[:len(frames)-1] {
+		n := len(f)
+		if n < 252 {
+			b = append(b, byte(n))
+
+			continue
+		}
+
+		first := 252 + n&0x03
+		b = append(b, byte(first), byte((n-first)/4)) //nolint:gosec // frames are at most 1275 bytes
+	}
+
+	for _, f := range frames {
+		b = append(b, f...)
+	}
+
+	return b
+}
+
+// opusFrames splits an Opus packet into its frames (RFC 6716 section 3.2).
+// Nil when it is malformed.
+func opusFrames(b []byte) [][]byte {
+	if len(b) < 1 {
+		return nil
+	}
+
+	data := b[1:]
+
+	switch b[0] & 0x03 {
+	case 0:
+		return [][]byte{data}
+	case 1:
+		if len(data)%2 != 0 {
+			return nil
+		}
+
+		return [][]byte{data[:len(data)/2], data[len(data)/2:]}
+	case 2:
+		n, size := opusFrameLength(data)
+		if size == 0 || n > len(data)-size {
+			return nil
+		}
+
+		data = data[size:]
+
+		return [][]byte{data[:n], data[n:]}
+	}
+
+	if len(data) < 1 {
+		return nil
+	}
+
+	count := int(data[0] & 0x3F)
+	vbr, padded := data[0]&0x80 != 0, data[0]&0x40 != 0
+	data = data[1:]
+
+	if count == 0 {
+		return nil
+	}
+
+	if padded {
+		pad := 0
+
+		for {
+			if len(data) == 0 {
+				return nil
+			}
+
+			p := int(data[0])
+			data = data[1:]
+
+			if p < 255 {
+				pad += p
+
+				break
+			}
+
+			pad += 254
+		}
+
+		if pad > len(data) {
+			return nil
+		}
+
+		data = data[:len(data)-pad]
+	}
+
+	sizes := make([]int, count)
+
+	if vbr {
+		total := 0
+
+		for i := range count - 1 {
+			n, size := opusFrameLength(data)
+			if size == 0 {
+				return nil
+			}
+
+			sizes[i] = n
+			total += n
+			data = data[size:]
+		}
+
+		if total > len(data) {
+			return nil
+		}
+
+		sizes[count-1] = len(data) - total
+	} else {
+		if len(data)%count != 0 {
+			return nil
+		}
+
+		for i := range sizes {
+			sizes[i] = len(data) / count
+		}
+	}
+
+	frames := make([][]byte, count)
+	for i, n := range sizes {
+		frames[i], data = data[:n], data[n:]
+	}
+
+	return frames
+}
+
+// opusFrameLength reads a frame length (RFC 6716 section 3.2.1), returning it
+// and the bytes it took; zero bytes when b is too short.
+func opusFrameLength(b []byte) (int, int) {
+	switch {
+	case len(b) < 1:
+		return 0, 0
+	case b[0] < 252:
+		return int(b[0]), 1
+	case len(b) < 2:
+		return 0, 0
+	default:
+		return 4*int(b[1]) + int(b[0]), 2
+	}
+}
+
+// encodeRED builds an RFC 2198 payload: a header for each earlier packet
+// that still fits its offset and length fields, then the primary's, then
+// the packets' data in the same order.
+func encodeRED(pt uint8, ts uint32, history []redBlock, primary []byte) []byte {
+	const (
+		maxOffset = 1<<14 - 1
+		maxLength = 1<<10 - 1
+	)
+
+	var (
+		hdr  []byte
+		data []byte
+	)
+
+	for _, h := range history {
+		offset := ts - h.ts
+		if offset == 0 || offset > maxOffset || len(h.payload) > maxLength {
+			continue
+		}
+
+		// F=1, block PT, 14-bit timestamp offset, 10-bit block length.
+		word := 1<<31 | uint32(pt&0x7F)<<24 | offset<<10 | uint32(len(h.payload)) //nolint:gosec // bounded above
+		hdr = binary.BigEndian.AppendUint32(hdr, word)
+		data = append(data, h.payload...)
+	}
+
+	hdr = append(hdr, pt&0x7F)
+
+	return append(append(hdr, data...), primary...)
+}
+
+// redPrimary is the primary block of an RFC 2198 payload, nil when it is
+// malformed.
+func redPrimary(b []byte) []byte {
+	skip := 0
+	i := 0
+
+	for i < len(b) && b[i]&0x80 != 0 {
+		if i+4 > len(b) {
+			return nil
+		}
+
+		skip += int(binary.BigEndian.Uint16(b[i+2:]) & 0x3FF)
+		i += 4
+	}
+
+	i++ // the primary's header
+
+	if i+skip > len(b) {
+		return nil
+	}
+
+	return b[i+skip:]
+}
+
+// vitaClock is a packet's timestamp at the Opus clock rate: a sample count
+// as it is, or real time from its seconds and picoseconds.
+func vitaClock(v vitaView) (int64, bool) {
+	switch v.TSF {
+	case tsfSampleCount:
+		return int64(v.FractionalTimestamp), true
+	case tsfRealTime:
+		if v.TSI == 0 {
+			return 0, false
+		}
+
+		ps := uint64(v.FractionalTimestampMSB)<<32 | uint64(v.FractionalTimestamp)
+
+		return int64(v.IntegerTimestamp)*opusClockRate + int64(ps*opusClockRate/picosPerSecond), true //nolint:gosec // ps < 1e12
+	default:
+		return 0, false
+	}
+}
+
+// opusFrameSamples is the duration of an Opus packet in samples at 48 kHz:
+// its frame count times the frame size its TOC byte configures (RFC 6716
+// section 3.1). Zero when the packet cannot be parsed, or claims more than
+// the 120 ms a packet may hold, so a corrupt count cannot push timestamps
+// seconds ahead.
+func opusFrameSamples(b []byte) int {
+	frames := opusFrameCount(b)
+	if frames == 0 {
+		return 0
+	}
+
+	var size int
+
+	switch config := b[0] >> 3; {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		size = [...]int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid: 10, 20 ms
+		size = [...]int{480, 960}[config%2]
+	default: // CELT: 2.5, 5, 10, 20 ms
+		size = [...]int{120, 240, 480, 960}[config%4]
+	}
+
+	if frames*size > maxOpusPacket {
+		return 0
+	}
+
+	return frames * size
+}
+
+// handleAudioRedundancy sets the session's redundancy mode and answers with
+// the mode in force.
+func (cs *clientSession) handleAudioRedundancy(raw json.RawMessage) {
+	var p audioRedundancyPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	switch p.Mode {
+	case "", RedundancyAuto, RedundancyOn, RedundancyOff:
+	default:
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: errRedundancyMode.Error()}))
+
+		return
+	}
+
+	track := cs.audio()
+	if track == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "NO_AUDIO", Message: "no audio track yet"}))
+
+		return
+	}
+
+	if p.Mode != "" {
+		track.setRedundancy(p.Mode)
+	}
+
+	cs.trySend(mustEncode(typeAudioRedundancy, audioRedundancyPayload{Mode: track.redundancy()}))
+}
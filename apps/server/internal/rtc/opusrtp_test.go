@@ -0,0 +1,267 @@
+package rtc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+func TestOpusFrameSamples(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		name string
+		b    []byte
+		want int
+	}{
+		{"CELT 10 ms", []byte{26 << 3}, 480},
+		{"CELT 2.5 ms, two frames", []byte{16<<3 | 1}, 240},
+		{"SILK 60 ms", []byte{3 << 3}, 2880},
+		{"Hybrid 20 ms", []byte{13 << 3}, 960},
+		{"Hybrid 10 ms, two frames of different sizes", []byte{12<<3 | 2, 1}, 960},
+		{"CELT 20 ms, three frames", []byte{31<<3 | 3, 0x80 | 3}, 2880},
+		{"SILK 40 ms, three frames: 120 ms", []byte{2<<3 | 3, 3}, 5760},
+		{"SILK 60 ms, three frames: over 120 ms", []byte{3<<3 | 3, 3}, 0},
+		{"code 3 without a count", []byte{31<<3 | 3}, 0},
+		{"empty", nil, 0},
+	} {
+		if got := opusFrameSamples(c.b); got != c.want {
+			t.Errorf("%s: %d samples, want %d", c.name, got, c.want)
+		}
+	}
+
+	if d := opusDuration([]byte{31 << 3}); d != 20*time.Millisecond {
+		t.Errorf("opusDuration = %v", d)
+	}
+}
+
+func TestOpusTrack_Timestamps(t *testing.T) {
+	t.Parallel()
+
+	track := newOpusTrack("a", "a")
+	frame := []byte{26 << 3} // 10 ms
+
+	at := func(count uint32) vitaView {
+		return vitaView{TSF: tsfSampleCount, FractionalTimestamp: count, Payload: frame}
+	}
+
+	packet := func(v vitaView) *rtp.Packet {
+		pkts := track.packets(v)
+		if len(pkts) != 1 {
+			t.Fatalf("%d packets", len(pkts))
+		}
+
+		return pkts[0]
+	}
+
+	first := packet(at(1000))
+	if !first.Marker {
+		t.Error("first packet not marked")
+	}
+
+	// Arriving late or early changes nothing; the radio's clock sets the pace.
+	next := packet(at(1480))
+	if next.Timestamp-first.Timestamp != 480 || next.SequenceNumber != first.SequenceNumber+1 || next.Marker {
+		t.Errorf("next packet %+v after %+v", next.Header, first.Header)
+	}
+
+	// A lost packet leaves its 10 ms in the timeline.
+	gap := packet(at(2440))
+	if gap.Timestamp-next.Timestamp != 960 || !gap.Marker {
+		t.Errorf("after a gap %+v", gap.Header)
+	}
+
+	// A clock that jumps back or too far is ignored.
+	for _, count := range []uint32{0, 2440 + 10*opusClockRate} {
+		prev := packet(at(count))
+		if prev.Timestamp-gap.Timestamp != 480 {
+			t.Errorf("clock at %d: %+v", count, prev.Header)
+		}
+
+		gap = prev
+	}
+}
+
+func TestOpusTrack_Aggregation(t *testing.T) {
+	t.Parallel()
+
+	track := newOpusTrack("a", "a")
+	track.setFrames(3)
+
+	at := func(count uint32, data byte) vitaView {
+		return vitaView{TSF: tsfSampleCount, FractionalTimestamp: count, Payload: []byte{26 << 3, data, data}}
+	}
+
+	if pkts := track.packets(at(0, 1)); len(pkts) != 0 {
+		t.Fatalf("sent %d packets before the frames were in", len(pkts))
+	}
+
+	_ = track.packets(at(480, 2))
+
+	pkts := track.packets(at(960, 3))
+	if len(pkts) != 1 {
+		t.Fatalf("%d packets", len(pkts))
+	}
+
+	first := pkts[0]
+	if !bytes.Equal(first.Payload, []byte{26<<3 | 3, 0x80 | 3, 2, 2, 1, 1, 2, 2, 3, 3}) {
+		t.Errorf("payload %x", first.Payload)
+	}
+
+	if opusFrameSamples(first.Payload) != 1440 {
+		t.Errorf("packet of %d samples", opusFrameSamples(first.Payload))
+	}
+
+	// A gap sends what has gathered, and starts the next packet marked.
+	_ = track.packets(at(1440, 4))
+
+	pkts = track.packets(at(2880, 5))
+	if len(pkts) != 1 || pkts[0].Timestamp-first.Timestamp != 1440 || pkts[0].SequenceNumber != first.SequenceNumber+1 {
+		t.Fatalf("after a gap %+v", pkts)
+	}
+
+	if !bytes.Equal(pkts[0].Payload, []byte{26 << 3, 4, 4}) {
+		t.Errorf("lone packet repacked: %x", pkts[0].Payload)
+	}
+
+	track.setFrames(1)
+
+	pkts = track.packets(at(3360, 6))
+	if len(pkts) != 1 || !pkts[0].Marker || pkts[0].Timestamp-first.Timestamp != 2880 {
+		t.Errorf("after the gap %+v", pkts)
+	}
+}
+
+func TestOpusFrames(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		name string
+		b    []byte
+		want []string
+	}{
+		{"one frame", []byte{0, 'a', 'b'}, []string{"ab"}},
+		{"two equal", []byte{1, 'a', 'b'}, []string{"a", "b"}},
+		{"two sized", []byte{2, 1, 'a', 'b', 'c'}, []string{"a", "bc"}},
+		{"CBR", []byte{3, 2, 'a', 'b'}, []string{"a", "b"}},
+		{"VBR, padded", []byte{3, 0xC0 | 2, 1, 2, 'a', 'b', 'c', 0}, []string{"ab", "c"}},
+	} {
+		got := opusFrames(c.b)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: %q", c.name, got)
+
+			continue
+		}
+
+		for i := range got {
+			if string(got[i]) != c.want[i] {
+				t.Errorf("%s: %q", c.name, got)
+			}
+		}
+	}
+
+	for _, b := range [][]byte{nil, {1, 'a'}, {2, 5, 'a'}, {3}, {3, 0x80 | 2, 9, 'a'}} {
+		if opusFrames(b) != nil {
+			t.Errorf("opusFrames(%x) accepted", b)
+		}
+	}
+
+	long := make([]byte, 300)
+	packed := opusRepack([]pendingOpus{{payload: append([]byte{0}, long...)}, {payload: []byte{0, 'z'}}})
+
+	if got := opusFrames(packed); len(got) != 2 || len(got[0]) != 300 || string(got[1]) != "z" {
+		t.Errorf("repacked long frame: %d frames", len(got))
+	}
+}
+
+func TestVITAClock_RealTime(t *testing.T) {
+	t.Parallel()
+
+	half := uint64(picosPerSecond / 2)
+
+	got, ok := vitaClock(vitaView{
+		TSI: 1, TSF: tsfRealTime, IntegerTimestamp: 2,
+		FractionalTimestampMSB: uint32(half >> 32), FractionalTimestamp: uint32(half),
+	})
+	if !ok || got != 2*opusClockRate+opusClockRate/2 {
+		t.Errorf("vitaClock = %d, %v", got, ok)
+	}
+}
+
+func TestRED(t *testing.T) {
+	t.Parallel()
+
+	history := []redBlock{
+		{ts: 1000, payload: []byte("old")},
+		{ts: 1480, payload: []byte("prev")},
+	}
+
+	b := encodeRED(111, 1960, history, []byte("now"))
+
+	// Two 4-byte block headers, the primary's byte, then the data in order.
+	if b[0] != 0x80|111 || b[4] != 0x80|111 || b[8] != 111 || string(b[9:]) != "oldprevnow" {
+		t.Fatalf("encodeRED = %x", b)
+	}
+
+	if offset := uint32(b[1])<<6 | uint32(b[2])>>2; offset != 960 {
+		t.Errorf("first block offset %d", offset)
+	}
+
+	if got := redPrimary(b); !bytes.Equal(got, []byte("now")) {
+		t.Errorf("redPrimary = %q", got)
+	}
+
+	if got := redPrimary(encodeRED(111, 1000, nil, []byte("only"))); string(got) != "only" {
+		t.Errorf("redPrimary without redundancy = %q", got)
+	}
+
+	if redPrimary([]byte{0x80 | 111, 0, 0}) != nil {
+		t.Error("truncated header accepted")
+	}
+}
+
+func TestOpusTrack_Redundancy(t *testing.T) {
+	t.Parallel()
+
+	track := newOpusTrack("a", "a")
+	b := &opusBinding{ssrc: 7, opusPT: 111, redPT: 63}
+	plain := &opusBinding{ssrc: 8, opusPT: 111}
+	track.bindings["b"], track.bindings["plain"] = b, plain
+
+	now := time.Now()
+
+	if track.sendsRED(b, now) {
+		t.Error("RED before any loss")
+	}
+
+	track.reportLoss(7, 1, now)
+
+	if track.sendsRED(b, now) {
+		t.Error("RED on negligible loss")
+	}
+
+	track.reportLoss(7, 8, now)
+	track.reportLoss(8, 8, now)
+
+	if !track.sendsRED(b, now) || track.sendsRED(plain, now) {
+		t.Error("RED not sent to exactly the lossy listener that negotiated it")
+	}
+
+	if track.sendsRED(b, now.Add(redHold)) {
+		t.Error("RED outlasted the loss")
+	}
+
+	track.setRedundancy(RedundancyOff)
+
+	if track.sendsRED(b, now) {
+		t.Error("RED while off")
+	}
+
+	track.setRedundancy(RedundancyOn)
+
+	if !track.sendsRED(&opusBinding{redPT: 63}, now) {
+		t.Error("no RED while on")
+	}
+}
@@ -0,0 +1,204 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for UDPPipelineOptions.
+const (
+	DefaultUDPWorkers = 2
+	DefaultUDPQueue   = 1024
+)
+
+// UDPPipelineOptions shapes how each session's radio UDP is received. One
+// goroutine reads the socket into a bounded queue, and Workers goroutines
+// parse and dispatch what it queues, so a burst of FFT and IQ no longer
+// leaves the socket unread while a packet is forwarded.
+type UDPPipelineOptions struct {
+	// Workers is how many goroutines parse and dispatch; zero means
+	// DefaultUDPWorkers.
+	Workers int
+	// Queue is how many packets may wait for the workers before the reader
+	// drops them; zero means DefaultUDPQueue.
+	Queue int
+	// ReceiveBuffer is the socket's SO_RCVBUF in bytes, which holds what
+	// arrives while the reader is behind; zero leaves the OS default.
+	ReceiveBuffer int
+	// DSCP marks what the socket sends the radio, TX audio among it; zero
+	// leaves it unmarked.
+	DSCP int
+}
+
+// UDPPipelineStats reports a session's receive pipeline stage by stage.
+type UDPPipelineStats struct {
+	// Read is packets read from the radio's socket.
+	Read uint64 `json:"read"`
+	// Queued is packets waiting for a worker now, and QueueDrops those
+	// dropped because the queue was full.
+	Queued     int    `json:"queued"`
+	QueueDrops uint64 `json:"queueDrops"`
+	// Dispatched is packets parsed and routed, ParseErrors those that were
+	// not VITA-49, and DispatchUs the mean time a packet took.
+	Dispatched  uint64  `json:"dispatched"`
+	ParseErrors uint64  `json:"parseErrors"`
+	DispatchUs  float64 `json:"dispatchUs"`
+	// Port is the bridge's port the radio sends to.
+	Port int `json:"port,omitempty"`
+	// ReceiveBuffer is the socket's SO_RCVBUF as the OS reports it, and
+	// KernelDrops the datagrams the kernel dropped with it full, where the
+	// OS says.
+	ReceiveBuffer int     `json:"receiveBuffer,omitempty"`
+	KernelDrops   *uint64 `json:"kernelDrops,omitempty"`
+}
+
+// udpPipeline is one radio connection's queue and workers. Each worker has
+// its own share of the queue and takes every packet of the streams it is
+// given, so each stream is dispatched in order.
+type udpPipeline struct {
+	rc     *radioConn
+	audio  *opusTrack
+	queues []chan *[]byte
+	bufs   sync.Pool
+
+	read          atomic.Uint64
+	queueDrops    atomic.Uint64
+	dispatched    atomic.Uint64
+	parseErrors   atomic.Uint64
+	dispatchNanos atomic.Uint64
+}
+
+func newUDPPipeline(rc *radioConn, audio *opusTrack, opt UDPPipelineOptions) *udpPipeline {
+	workers, queue := opt.Workers, opt.Queue
+	if workers <= 0 {
+		workers = DefaultUDPWorkers
+	}
+
+	if queue <= 0 {
+		queue = DefaultUDPQueue
+	}
+
+	pl := &udpPipeline{rc: rc, audio: audio, queues: make([]chan *[]byte, workers)}
+	pl.bufs.New = func() any {
+		b := make([]byte, 0, 2048)
+
+		return &b
+	}
+
+	for i := range pl.queues {
+		pl.queues[i] = make(chan *[]byte, max(queue/workers, 1))
+	}
+
+	return pl
+}
+
+// start runs the workers until stop.
+func (pl *udpPipeline) start() {
+	for _, q := range pl.queues {
+		go pl.work(q)
+	}
+}
+
+// stop ends the workers once they have dispatched what is queued.
+func (pl *udpPipeline) stop() {
+	for _, q := range pl.queues {
+		close(q)
+	}
+}
+
+// enqueue copies p, which the reader reuses, to its stream's worker,
+// dropping it when that worker's queue is full.
+func (pl *udpPipeline) enqueue(p []byte) {
+	pl.read.Add(1)
+
+	bp := pl.bufs.Get().(*[]byte) //nolint:forcetypeassert // the pool holds nothing else
+	*bp = append((*bp)[:0], p...)
+
+	select {
+	case pl.queues[udpShard(p, len(pl.queues))] <- bp:
+	default:
+		pl.bufs.Put(bp)
+		pl.queueDrops.Add(1)
+		pl.rc.dropped.Add(1)
+	}
+}
+
+func (pl *udpPipeline) work(q chan *[]byte) {
+	for bp := range q {
+		start := time.Now()
+
+		if pl.rc.dispatch(*bp, pl.audio) {
+			pl.dispatched.Add(1)
+			pl.dispatchNanos.Add(uint64(time.Since(start))) //nolint:gosec // a positive duration
+		} else {
+			pl.parseErrors.Add(1)
+		}
+
+		pl.bufs.Put(bp)
+	}
+}
+
+func (pl *udpPipeline) stats() *UDPPipelineStats {
+	if pl == nil {
+		return nil
+	}
+
+	st := &UDPPipelineStats{
+		Read:        pl.read.Load(),
+		QueueDrops:  pl.queueDrops.Load(),
+		Dispatched:  pl.dispatched.Load(),
+		ParseErrors: pl.parseErrors.Load(),
+	}
+
+	for _, q := range pl.queues {
+		st.Queued += len(q)
+	}
+
+	if st.Dispatched > 0 {
+		st.DispatchUs = float64(pl.dispatchNanos.Load()) / float64(st.Dispatched) / 1000
+	}
+
+	pl.rc.mu.RLock()
+	u := pl.rc.udpConn
+	st.ReceiveBuffer = pl.rc.udpRcvBuf
+	pl.rc.mu.RUnlock()
+
+	if ua, ok := udpLocalAddr(u); ok {
+		st.Port = ua.Port
+
+		if drops, ok := kernelDrops(ua.Port); ok {
+			st.KernelDrops = &drops
+		}
+	}
+
+	return st
+}
+
+func udpLocalAddr(u *net.UDPConn) (*net.UDPAddr, bool) {
+	if u == nil {
+		return nil, false
+	}
+
+	ua, ok := u.LocalAddr().(*net.UDPAddr)
+
+	return ua, ok
+}
+
+// udpShard picks the worker for packet p. Opus audio always goes to the
+// first, as the audio track takes one stream at a time; any other stream
+// goes to the worker its ID picks. It reads the stream ID and class code
+// where parseVITA finds them, without parsing the rest.
+func udpShard(p []byte, workers int) int {
+	if workers <= 1 || len(p) < 16 {
+		return 0
+	}
+
+	if p[0]&0x08 != 0 && binary.BigEndian.Uint16(p[14:]) == 0x8005 {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint32(p[4:]) % uint32(workers)) //nolint:gosec // workers is small
+}
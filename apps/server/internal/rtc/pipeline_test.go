@@ -0,0 +1,69 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestUDPShard(t *testing.T) {
+	t.Parallel()
+
+	packet := func(stream uint32, class uint16) []byte {
+		p := []byte{0x18, 0, 0, 0}
+		p = binary.BigEndian.AppendUint32(p, stream)
+		p = binary.BigEndian.AppendUint32(p, 0x001C2D)
+		p = binary.BigEndian.AppendUint32(p, uint32(class))
+
+		return p
+	}
+
+	if got := udpShard(packet(0x04000009, 0x8005), 4); got != 0 {
+		t.Errorf("audio on worker %d", got)
+	}
+
+	if got := udpShard(packet(0x40000003, vitaFFTClass), 4); got != 3 {
+		t.Errorf("FFT stream on worker %d", got)
+	}
+
+	if udpShard(packet(0x40000003, vitaFFTClass), 4) != udpShard(packet(0x40000003, vitaFFTClass), 4) {
+		t.Error("one stream on two workers")
+	}
+
+	if got := udpShard([]byte{1, 2, 3}, 4); got != 0 {
+		t.Errorf("runt on worker %d", got)
+	}
+}
+
+func TestUDPPipeline(t *testing.T) {
+	t.Parallel()
+
+	rc := &radioConn{}
+	pl := newUDPPipeline(rc, nil, UDPPipelineOptions{Workers: 1, Queue: 1})
+
+	// With the workers not yet running, the second packet finds the queue
+	// full.
+	pl.enqueue([]byte("not a vita packet"))
+	pl.enqueue([]byte("nor this"))
+
+	st := pl.stats()
+	if st.Read != 2 || st.Queued != 1 || st.QueueDrops != 1 || rc.dropped.Load() != 1 {
+		t.Fatalf("stats %+v, dropped %d", st, rc.dropped.Load())
+	}
+
+	pl.start()
+	pl.stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pl.stats().ParseErrors != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats %+v", pl.stats())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if st := pl.stats(); st.Queued != 0 || st.Dispatched != 0 {
+		t.Errorf("stats %+v", st)
+	}
+}
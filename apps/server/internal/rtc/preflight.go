@@ -0,0 +1,169 @@
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Preflight modes for verifying the target radio before accepting a client.
+const (
+	PreflightOff      = "off"
+	PreflightRegistry = "registry" // radio must be present in discovery
+	PreflightTCP      = "tcp"      // radio's API port must accept a TCP connection
+)
+
+const preflightDialTimeout = 3 * time.Second
+
+// preflightError is returned to the client as a JSON HTTP error before the
+// WebSocket upgrade, so it can show a specific reason instead of a bare close.
+type preflightError struct {
+	status int
+	body   errorPayload
+}
+
+func (e *preflightError) Error() string { return e.body.Message }
+
+// HTTPStatus is the status the error is served with, for callers outside
+// HTTP mapping it onto their own codes.
+func (e *preflightError) HTTPStatus() int { return e.status }
+
+// preflight validates a signaling request. The radio to check is taken from
+// the optional ?radio=host:port query parameter.
+func (s *Server) preflight(r *http.Request) *preflightError {
+	return s.admit(r.Context(), r.URL.Query().Get("radio"))
+}
+
+// admit checks whether a new session on radio may start; an empty radio is
+// checked against the bridge's state alone.
+func (s *Server) admit(ctx context.Context, radio string) *preflightError {
+	if s.draining.Load() {
+		return &preflightError{http.StatusServiceUnavailable, errorPayload{
+			Code: "DRAINING", Message: "bridge is shutting down",
+		}}
+	}
+
+	if s.maxSessions > 0 && s.sessionCount() >= s.maxSessions {
+		return &preflightError{http.StatusServiceUnavailable, errorPayload{
+			Code: "SESSION_LIMIT", Message: "bridge is at its session limit",
+		}}
+	}
+
+	if radio == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(radio)
+	if err != nil {
+		return &preflightError{http.StatusBadRequest, errorPayload{
+			Code: "BAD_RADIO_ADDR", Message: "radio must be host:port, with an IPv6 host in brackets",
+		}}
+	}
+
+	host = canonicalHost(host)
+
+	if !s.radioAllowed(radio) {
+		return &preflightError{http.StatusForbidden, errorPayload{
+			Code: "RADIO_NOT_ALLOWED", Message: "this bridge does not serve " + radio,
+		}}
+	}
+
+	switch s.preflightMode {
+	case PreflightRegistry:
+		if !s.disco.Registry().HasHost(host, time.Now()) {
+			return &preflightError{http.StatusNotFound, errorPayload{
+				Code: "RADIO_NOT_DISCOVERED", Message: "radio " + radio + " is not currently discovered",
+			}}
+		}
+	case PreflightTCP:
+		ctx, cancel := context.WithTimeout(ctx, preflightDialTimeout)
+		defer cancel()
+
+		var d net.Dialer
+
+		conn, err := d.DialContext(ctx, "tcp", radio)
+		if err != nil {
+			return &preflightError{http.StatusBadGateway, errorPayload{
+				Code: "RADIO_UNREACHABLE", Message: err.Error(),
+			}}
+		}
+
+		_ = conn.Close()
+	}
+
+	return nil
+}
+
+// radioAllowed reports whether addr (host:port) may be dialed. An empty
+// allowlist permits every radio.
+func (s *Server) radioAllowed(addr string) bool {
+	if len(s.allowedRadios) == 0 {
+		return true
+	}
+
+	addr = canonicalRadio(addr)
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return slices.Contains(s.allowedRadios, addr) || slices.Contains(s.allowedRadios, host)
+}
+
+func allowedRadios(addrs []string) []string {
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = canonicalRadio(strings.TrimSpace(a))
+	}
+
+	return out
+}
+
+// canonicalRadio writes addr, a radio's host:port or bare host, with its host
+// as canonicalHost does, so that one radio has one address however it was
+// typed: "[2001:DB8:0::1]:4992" becomes "[2001:db8::1]:4992".
+func canonicalRadio(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return canonicalHost(addr)
+	}
+
+	return net.JoinHostPort(canonicalHost(host), port)
+}
+
+// canonicalHost writes an IP address without brackets, in netip's form and
+// with an IPv4-mapped IPv6 address as IPv4. A name is returned unchanged.
+func canonicalHost(host string) string {
+	ip, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err != nil {
+		return host
+	}
+
+	return ip.Unmap().String()
+}
+
+func (s *Server) sessionCount() int {
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+
+	return len(s.sessions)
+}
+
+func (e *preflightError) write(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[rtc] preflight rejected %s: %s", clientIPFromRequest(r), e.body.Code)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	_ = json.NewEncoder(w).Encode(e.body)
+}
@@ -0,0 +1,135 @@
+package rtc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRadioAllowed(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{allowedRadios: []string{"192.0.2.10", "192.0.2.20:4992"}}
+
+	cases := map[string]bool{
+		"192.0.2.10:4992": true,
+		"192.0.2.10:5000": true,
+		"192.0.2.20:4992": true,
+		"192.0.2.20:5000": false,
+		"192.0.2.30:4992": false,
+	}
+	for addr, want := range cases {
+		if got := s.radioAllowed(addr); got != want {
+			t.Errorf("radioAllowed(%q) = %v, want %v", addr, got, want)
+		}
+	}
+
+	if !(&Server{}).radioAllowed("198.51.100.1:4992") {
+		t.Error("empty allowlist should permit every radio")
+	}
+}
+
+func TestRadioAllowed_IPv6(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{allowedRadios: allowedRadios([]string{"[2001:DB8::10]", "[2001:db8:0::20]:4992"})}
+
+	cases := map[string]bool{
+		"[2001:db8::10]:4992":      true,
+		"[2001:db8:0:0::10]:5000":  true,
+		"[2001:db8::20]:4992":      true,
+		"[2001:db8::20]:5000":      false,
+		"[::ffff:192.0.2.10]:4992": false,
+		"[2001:db8::30]:4992":      false,
+	}
+	for addr, want := range cases {
+		if got := s.radioAllowed(addr); got != want {
+			t.Errorf("radioAllowed(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCanonicalRadio(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"[2001:DB8:0::1]:4992":    "[2001:db8::1]:4992",
+		"[::ffff:192.0.2.1]:4992": "192.0.2.1:4992",
+		"[fe80::1%eth0]:4992":     "[fe80::1%eth0]:4992",
+		"[2001:db8::1]":           "2001:db8::1",
+		"radio.lan:4992":          "radio.lan:4992",
+		"192.0.2.1":               "192.0.2.1",
+	}
+	for in, want := range cases {
+		if got := canonicalRadio(in); got != want {
+			t.Errorf("canonicalRadio(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPreflight_RejectsBadRadio(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}, allowedRadios: []string{"192.0.2.10"}}
+
+	r := httptest.NewRequest(http.MethodGet, "/ws/signal?radio=nope", nil)
+	if perr := s.preflight(r); perr == nil || perr.status != http.StatusBadRequest {
+		t.Errorf("malformed radio: got %+v, want 400", perr)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/ws/signal?radio=192.0.2.99:4992", nil)
+	if perr := s.preflight(r); perr == nil || perr.status != http.StatusForbidden {
+		t.Errorf("disallowed radio: got %+v, want 403", perr)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/ws/signal", nil)
+	if perr := s.preflight(r); perr != nil {
+		t.Errorf("no radio param: got %+v, want nil", perr)
+	}
+}
+
+func TestPreflight_RefusesWhileDraining(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+	s.Drain(t.Context(), DrainOptions{})
+
+	if !s.Draining() {
+		t.Fatal("Drain should mark the server as draining")
+	}
+
+	perr := s.preflight(httptest.NewRequest(http.MethodGet, "/ws/signal", nil))
+	if perr == nil || perr.status != http.StatusServiceUnavailable || perr.body.Code != "DRAINING" {
+		t.Errorf("got %+v, want 503 DRAINING", perr)
+	}
+}
+
+func TestReadyAndCheckPeer(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+	peer := httptest.NewServer(http.HandlerFunc(s.ReadyHandler))
+	t.Cleanup(peer.Close)
+
+	err := CheckPeer(t.Context(), peer.URL+"/")
+	if err != nil {
+		t.Fatalf("idle peer should be ready: %v", err)
+	}
+
+	s.Drain(t.Context(), DrainOptions{})
+
+	err = CheckPeer(t.Context(), peer.URL)
+	if err == nil {
+		t.Fatal("draining peer should not be ready")
+	}
+
+	s.Resume()
+
+	if s.Draining() || CheckPeer(t.Context(), peer.URL) != nil {
+		t.Fatal("Resume should accept sessions again")
+	}
+
+	if CheckPeer(t.Context(), "ftp://example.com") == nil {
+		t.Fatal("non-http peer should be rejected")
+	}
+}
@@ -0,0 +1,391 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	errProfileRequest = errors.New("bad profile request")
+	errProfileSandbox = errors.New("profiles cannot be changed in a sandboxed session")
+	errProfileGuard   = errors.New("refused by the command guard")
+)
+
+// profileKinds are the radio's profile lists, by the name the API gives them.
+var profileKinds = []string{"global", "tx", "mic"}
+
+// ProfileList is one kind of profile: the names saved on the radio and the
+// one loaded.
+type ProfileList struct {
+	Current string   `json:"current"`
+	Names   []string `json:"names"`
+}
+
+// Memory is a memory channel saved on the radio, as its "memory" status
+// reports it.
+type Memory struct {
+	Index        int     `json:"index"`
+	Name         string  `json:"name,omitempty"`
+	Group        string  `json:"group,omitempty"`
+	Owner        string  `json:"owner,omitempty"`
+	FreqMHz      float64 `json:"freqMHz"`
+	Mode         string  `json:"mode,omitempty"`
+	RXFilterLow  int     `json:"rxFilterLow"`
+	RXFilterHigh int     `json:"rxFilterHigh"`
+}
+
+// RadioProfiles answers a "profile" message and the profiles endpoint, and is
+// sent again whenever it changes.
+type RadioProfiles struct {
+	Global   ProfileList `json:"global"`
+	TX       ProfileList `json:"tx"`
+	Mic      ProfileList `json:"mic"`
+	Memories []Memory    `json:"memories"`
+}
+
+// ProfileRequest is a client's "profile" message. Action is "list", which
+// also subscribes to changes, or "load", "save" or "delete". Kind is
+// "global", "tx", "mic" or "memory"; profiles are named by Name, and
+// memories by Index except a new one, which Name labels.
+type ProfileRequest struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Index  int    `json:"index,omitempty"`
+}
+
+// profileTable tracks the radio's profiles and memories from "profile" and
+// "memory" status.
+type profileTable struct {
+	mu       sync.Mutex
+	lists    map[string]ProfileList
+	memories map[int]Memory
+}
+
+// observe applies a "profile ..." or "memory ..." status body. It reports
+// whether anything changed.
+func (t *profileTable) observe(body string) bool {
+	if rest, ok := strings.CutPrefix(body, "profile "); ok {
+		return t.observeProfile(rest)
+	}
+
+	if rest, ok := strings.CutPrefix(body, "memory "); ok {
+		return t.observeMemory(rest)
+	}
+
+	return false
+}
+
+// observeProfile applies "<kind> list=A^B^C^" or "<kind> current=A". Names
+// may hold spaces, so the value runs to the end of the line.
+func (t *profileTable) observeProfile(rest string) bool {
+	kind, attr, _ := strings.Cut(rest, " ")
+	if kind == "transmit" {
+		kind = "tx"
+	}
+
+	if !slices.Contains(profileKinds, kind) {
+		return false
+	}
+
+	key, value, ok := strings.Cut(attr, "=")
+	if !ok {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.lists == nil {
+		t.lists = make(map[string]ProfileList)
+	}
+
+	l := t.lists[kind]
+
+	switch key {
+	case "list":
+		var names []string
+
+		for n := range strings.SplitSeq(value, "^") {
+			if n != "" {
+				names = append(names, n)
+			}
+		}
+
+		if slices.Equal(l.Names, names) {
+			return false
+		}
+
+		l.Names = names
+	case "current":
+		if l.Current == value {
+			return false
+		}
+
+		l.Current = value
+	default:
+		return false
+	}
+
+	t.lists[kind] = l
+
+	return true
+}
+
+// observeMemory applies "<index> removed" or "<index> key=value ...".
+func (t *profileTable) observeMemory(rest string) bool {
+	idx, attrs, _ := strings.Cut(rest, " ")
+
+	index, err := strconv.Atoi(idx)
+	if err != nil {
+		return false
+	}
+
+	f := statusAttrs(attrs)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, gone := f["removed"]; gone {
+		_, known := t.memories[index]
+		delete(t.memories, index)
+
+		return known
+	}
+
+	if t.memories == nil {
+		t.memories = make(map[int]Memory)
+	}
+
+	old, known := t.memories[index]
+	m := old
+	m.Index = index
+
+	for k, v := range f {
+		switch k {
+		case "name":
+			m.Name = strings.ReplaceAll(v, "\x7f", " ")
+		case "group":
+			m.Group = strings.ReplaceAll(v, "\x7f", " ")
+		case "owner":
+			m.Owner = strings.ReplaceAll(v, "\x7f", " ")
+		case "freq":
+			m.FreqMHz, _ = strconv.ParseFloat(v, 64)
+		case "mode":
+			m.Mode = v
+		case "rx_filter_low":
+			m.RXFilterLow, _ = strconv.Atoi(v)
+		case "rx_filter_high":
+			m.RXFilterHigh, _ = strconv.Atoi(v)
+		}
+	}
+
+	t.memories[index] = m
+
+	return !known || m != old
+}
+
+// snapshot returns the profiles, and the memories in index order.
+func (t *profileTable) snapshot() RadioProfiles {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := RadioProfiles{
+		Global:   t.lists["global"],
+		TX:       t.lists["tx"],
+		Mic:      t.lists["mic"],
+		Memories: make([]Memory, 0, len(t.memories)),
+	}
+
+	for _, m := range t.memories {
+		p.Memories = append(p.Memories, m)
+	}
+
+	slices.SortFunc(p.Memories, func(a, b Memory) int { return a.Index - b.Index })
+
+	return p
+}
+
+// observeProfiles tracks the radio's profiles and memories from a status
+// body.
+func (rc *radioConn) observeProfiles(body string) {
+	if !rc.profiles.observe(body) {
+		return
+	}
+
+	rc.mu.RLock()
+	onProfiles := rc.onProfiles
+	rc.mu.RUnlock()
+
+	if onProfiles != nil {
+		onProfiles()
+	}
+}
+
+// refreshProfiles asks the radio for its profile lists, and subscribes to
+// its memories once per connection.
+func (rc *radioConn) refreshProfiles() error {
+	rc.mu.Lock()
+	done := rc.memoriesSubscribed
+	rc.memoriesSubscribed = true
+	rc.mu.Unlock()
+
+	if !done {
+		_, err := rc.clientCommand("sub memories all")
+		if err != nil {
+			rc.mu.Lock()
+			rc.memoriesSubscribed = false
+			rc.mu.Unlock()
+
+			return err
+		}
+	}
+
+	for _, kind := range profileKinds {
+		_, err := rc.clientCommand("profile " + kind + " info")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// profileCommand is the radio command for req, other than "list".
+func profileCommand(req ProfileRequest) (string, error) {
+	if req.Kind == "memory" {
+		switch req.Action {
+		case "load":
+			return fmt.Sprintf("memory apply %d", req.Index), nil
+		case "save":
+			return "memory create", nil
+		case "delete":
+			return fmt.Sprintf("memory remove %d", req.Index), nil
+		}
+
+		return "", fmt.Errorf("%w: action %q", errProfileRequest, req.Action)
+	}
+
+	if !slices.Contains(profileKinds, req.Kind) {
+		return "", fmt.Errorf("%w: kind %q", errProfileRequest, req.Kind)
+	}
+
+	if req.Name == "" || strings.ContainsAny(req.Name, "\"^\r\n") {
+		return "", fmt.Errorf("%w: name %q", errProfileRequest, req.Name)
+	}
+
+	// The radio loads a TX profile as "tx" but writes one as "transmit".
+	kind := req.Kind
+
+	switch req.Action {
+	case "load":
+	case "save", "delete":
+		if kind == "tx" {
+			kind = "transmit"
+		}
+	default:
+		return "", fmt.Errorf("%w: action %q", errProfileRequest, req.Action)
+	}
+
+	return fmt.Sprintf("profile %s %s \"%s\"", kind, req.Action, req.Name), nil
+}
+
+// runProfile lists, loads, saves or deletes a profile or memory for the
+// session. Everything but loading a memory changes the radio's saved
+// configuration, so a sandboxed session may not, and each command must pass
+// the guard as if the client had sent it.
+func (cs *clientSession) runProfile(req ProfileRequest) (RadioProfiles, error) {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return RadioProfiles{}, errClientNoRadio
+	}
+
+	if req.Action == "list" {
+		err := rc.refreshProfiles()
+
+		return rc.profiles.snapshot(), err
+	}
+
+	cmd, err := profileCommand(req)
+	if err != nil {
+		return RadioProfiles{}, err
+	}
+
+	if cs.sandbox.isEnabled() && (req.Kind != "memory" || req.Action != "load") {
+		return RadioProfiles{}, errProfileSandbox
+	}
+
+	if !cs.allowImmediate(rc, fmt.Sprintf("C%d|%s\n", internalClientSequence, cmd)) {
+		return RadioProfiles{}, errProfileGuard
+	}
+
+	reply, err := rc.clientCommand(cmd)
+	if err != nil {
+		return RadioProfiles{}, err
+	}
+
+	if req.Kind == "memory" && req.Action == "save" && req.Name != "" {
+		_, err = rc.clientCommand(fmt.Sprintf("memory set %s name=%s",
+			strings.TrimSpace(reply), strings.ReplaceAll(req.Name, " ", "\x7f")))
+		if err != nil {
+			return RadioProfiles{}, err
+		}
+	}
+
+	return rc.profiles.snapshot(), nil
+}
+
+func (cs *clientSession) handleProfile(raw json.RawMessage) {
+	var req ProfileRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	if req.Action == "list" {
+		cs.profiles.Store(true)
+	}
+
+	p, err := cs.runProfile(req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "PROFILE_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeProfiles, p))
+}
+
+// profilesChanged updates a session that listed the profiles.
+func (cs *clientSession) profilesChanged(rc *radioConn) {
+	if cs.profiles.Load() {
+		cs.trySend(mustEncode(typeProfiles, rc.profiles.snapshot()))
+	}
+}
+
+// Profiles lists session id's radio's profiles and memories.
+func (s *Server) Profiles(id string) (RadioProfiles, error) {
+	return s.ProfileAction(id, ProfileRequest{Action: "list"})
+}
+
+// ProfileAction runs req for session id as its client's "profile" message
+// would.
+func (s *Server) ProfileAction(id string, req ProfileRequest) (RadioProfiles, error) {
+	cs := s.session(id)
+	if cs == nil {
+		return RadioProfiles{}, ErrNoSession
+	}
+
+	return cs.runProfile(req)
+}
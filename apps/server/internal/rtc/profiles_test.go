@@ -0,0 +1,155 @@
+package rtc
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestProfileTable_Observe(t *testing.T) {
+	t.Parallel()
+
+	var tbl profileTable
+
+	if !tbl.observe("profile global list=Default^Contest Setup^DX^") {
+		t.Error("a new list is not a change")
+	}
+
+	if tbl.observe("profile global list=Default^Contest Setup^DX^") {
+		t.Error("a repeated list is a change")
+	}
+
+	tbl.observe("profile global current=Contest Setup")
+	tbl.observe("profile transmit list=SSB^CW^")
+	tbl.observe("profile tx current=CW")
+	tbl.observe("memory 3 owner=W1AW group=Nets freq=7.255000 name=Morning\x7fNet mode=LSB rx_filter_low=-2800 rx_filter_high=-100")
+	tbl.observe("memory 1 freq=14.074000 mode=DIGU")
+
+	p := tbl.snapshot()
+
+	if p.Global.Current != "Contest Setup" || len(p.Global.Names) != 3 || p.Global.Names[1] != "Contest Setup" {
+		t.Errorf("global = %+v", p.Global)
+	}
+
+	if p.TX.Current != "CW" || len(p.TX.Names) != 2 {
+		t.Errorf("tx = %+v", p.TX)
+	}
+
+	if len(p.Memories) != 2 || p.Memories[0].Index != 1 {
+		t.Fatalf("memories = %+v", p.Memories)
+	}
+
+	want := Memory{Index: 3, Name: "Morning Net", Group: "Nets", Owner: "W1AW", FreqMHz: 7.255, Mode: "LSB", RXFilterLow: -2800, RXFilterHigh: -100}
+	if p.Memories[1] != want {
+		t.Errorf("memory = %+v, want %+v", p.Memories[1], want)
+	}
+
+	if !tbl.observe("memory 3 removed") || len(tbl.snapshot().Memories) != 1 {
+		t.Error("removal not applied")
+	}
+
+	if tbl.observe("profile displays list=A^") || tbl.observe("slice 0 RF_frequency=14.074") {
+		t.Error("not a profile status")
+	}
+}
+
+func TestProfileCommand(t *testing.T) {
+	t.Parallel()
+
+	cases := map[ProfileRequest]string{
+		{Action: "load", Kind: "global", Name: "Contest Setup"}: `profile global load "Contest Setup"`,
+		{Action: "load", Kind: "tx", Name: "CW"}:                `profile tx load "CW"`,
+		{Action: "save", Kind: "tx", Name: "CW"}:                `profile transmit save "CW"`,
+		{Action: "delete", Kind: "mic", Name: "Heil"}:           `profile mic delete "Heil"`,
+		{Action: "load", Kind: "memory", Index: 4}:              "memory apply 4",
+		{Action: "save", Kind: "memory"}:                        "memory create",
+		{Action: "delete", Kind: "memory", Index: 4}:            "memory remove 4",
+	}
+
+	for req, want := range cases {
+		got, err := profileCommand(req)
+		if err != nil || got != want {
+			t.Errorf("%+v: got %q, %v; want %q", req, got, err, want)
+		}
+	}
+
+	for _, req := range []ProfileRequest{
+		{Action: "load", Kind: "display", Name: "A"},
+		{Action: "load", Kind: "global"},
+		{Action: "load", Kind: "global", Name: "a\" b"},
+		{Action: "reset", Kind: "global", Name: "A"},
+		{Action: "rename", Kind: "memory", Index: 1},
+	} {
+		_, err := profileCommand(req)
+		if !errors.Is(err, errProfileRequest) {
+			t.Errorf("%+v: err = %v", req, err)
+		}
+	}
+}
+
+func TestClientSession_RunProfile(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{handleHex: "1234ABCD"}
+	rc.out = newTCPWriter(client, func([]byte) {}, nil)
+	t.Cleanup(rc.out.close)
+
+	cs := &clientSession{srv: &Server{}, radio: rc}
+
+	lines := make(chan string, 8)
+
+	// The radio answers "memory create" with the new memory's index.
+	go func() {
+		rd := bufio.NewReader(radio)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimSpace(line)
+			lines <- line
+
+			if strings.HasSuffix(line, "|memory create") {
+				rc.consumeClientReply("R2147483640|0|7")
+			} else {
+				rc.consumeClientReply("R2147483640|0|")
+			}
+		}
+	}()
+
+	_, err := cs.runProfile(ProfileRequest{Action: "save", Kind: "memory", Name: "Morning Net"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"C2147483640|memory create",
+		"C2147483640|memory set 7 name=Morning\x7fNet",
+	} {
+		if l := nextLine(t, lines); l != want {
+			t.Errorf("sent %q, want %q", l, want)
+		}
+	}
+
+	cs.sandbox.set(true)
+
+	_, err = cs.runProfile(ProfileRequest{Action: "load", Kind: "global", Name: "Default"})
+	if !errors.Is(err, errProfileSandbox) {
+		t.Errorf("sandboxed load: %v", err)
+	}
+
+	_, err = cs.runProfile(ProfileRequest{Action: "load", Kind: "memory", Index: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, lines); l != "C2147483640|memory apply 7" {
+		t.Errorf("sent %q", l)
+	}
+}
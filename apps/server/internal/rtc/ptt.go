@@ -0,0 +1,188 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// internalPTTSequence tags the xmit commands sent for "ptt" messages so their
+// replies are consumed instead of reaching the browser.
+const internalPTTSequence = 2147483641
+
+const (
+	// DefaultPTTKeepalive is how long a keyed PTT lasts without the client
+	// repeating it.
+	DefaultPTTKeepalive = 2 * time.Second
+	// DefaultPTTMaxTX is the longest a PTT stays keyed, however often it
+	// is repeated.
+	DefaultPTTMaxTX = 3 * time.Minute
+)
+
+// Why a PTT was released, as reported to the client.
+const (
+	pttReleasedClient     = "client"
+	pttReleasedKeepalive  = "keepalive"
+	pttReleasedMaxTX      = "maxTx"
+	pttReleasedConnection = "connection"
+	pttReleasedClosed     = "closed"
+)
+
+var (
+	errPTTSandbox  = errors.New("transmitting is disabled in the sandbox")
+	errPTTRejected = errors.New("transmit rejected by the command guard")
+	errPTTNoRadio  = errors.New("no radio connection")
+)
+
+// pttRequest is a client's "ptt" message. While transmitting, the client
+// repeats {"on": true} well within the keepalive it was told.
+type pttRequest struct {
+	On bool `json:"on"`
+}
+
+// pttStatus is sent to the client when its PTT is keyed or released.
+type pttStatus struct {
+	On bool `json:"on"`
+	// Reason is why TX ended: "client", "keepalive", "maxTx", "connection"
+	// or "closed".
+	Reason string `json:"reason,omitempty"`
+	// KeepaliveMs and MaxTXMs are the watchdog's limits while keyed.
+	KeepaliveMs int64 `json:"keepaliveMs,omitempty"`
+	MaxTXMs     int64 `json:"maxTxMs,omitempty"`
+}
+
+// pttState is a session's PTT and the watchdog releasing it.
+type pttState struct {
+	mu sync.Mutex
+	on bool
+	// gen tells the watchdog's timers from an earlier over apart.
+	gen       uint64
+	keepalive *time.Timer
+	maxTX     *time.Timer
+}
+
+func (cs *clientSession) handlePTT(raw json.RawMessage) {
+	var req pttRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	if !req.On {
+		cs.releasePTT(pttReleasedClient)
+
+		return
+	}
+
+	err = cs.keyPTT()
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "PTT_REFUSED", Message: err.Error()}))
+	}
+}
+
+// keyPTT keys the transmitter, or feeds the watchdog if it already is.
+func (cs *clientSession) keyPTT() error {
+	p := &cs.ptt
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.on {
+		p.keepalive.Reset(cs.srv.pttKeepalive)
+
+		return nil
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return errPTTNoRadio
+	}
+
+	if cs.sandbox.isEnabled() {
+		return errPTTSandbox
+	}
+
+	line := fmt.Sprintf("C%d|xmit 1\n", internalPTTSequence)
+	if !cs.allowImmediate(rc, line) {
+		return errPTTRejected
+	}
+
+	rc.noteOutgoingCommand([]byte(line))
+
+	err := rc.writeTCPString(line)
+	if err != nil {
+		return fmt.Errorf("key: %w", err)
+	}
+
+	p.on = true
+	p.gen++
+	gen := p.gen
+	p.keepalive = time.AfterFunc(cs.srv.pttKeepalive, func() { cs.releasePTTGen(gen, pttReleasedKeepalive) })
+	p.maxTX = time.AfterFunc(cs.srv.pttMaxTX, func() { cs.releasePTTGen(gen, pttReleasedMaxTX) })
+
+	log.Printf("[rtc] session %s: PTT on", cs.id)
+	cs.trySend(mustEncode(typePTT, pttStatus{
+		On:          true,
+		KeepaliveMs: cs.srv.pttKeepalive.Milliseconds(),
+		MaxTXMs:     cs.srv.pttMaxTX.Milliseconds(),
+	}))
+
+	return nil
+}
+
+// releasePTT unkeys the transmitter if this session keyed it.
+func (cs *clientSession) releasePTT(reason string) {
+	cs.ptt.mu.Lock()
+	gen := cs.ptt.gen
+	cs.ptt.mu.Unlock()
+
+	cs.releasePTTGen(gen, reason)
+}
+
+// releasePTTGen unkeys the transmitter if it is still keyed for over gen.
+// Unkeying skips the sandbox and the guard: stopping TX is always allowed.
+func (cs *clientSession) releasePTTGen(gen uint64, reason string) {
+	p := &cs.ptt
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.on || p.gen != gen {
+		return
+	}
+
+	p.on = false
+	p.keepalive.Stop()
+	p.maxTX.Stop()
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc != nil {
+		line := fmt.Sprintf("C%d|xmit 0\n", internalPTTSequence)
+		rc.noteOutgoingCommand([]byte(line))
+
+		err := rc.writeTCPString(line)
+		if err != nil {
+			log.Printf("[rtc] session %s: PTT release: %v", cs.id, err)
+		}
+	}
+
+	log.Printf("[rtc] session %s: PTT off (%s)", cs.id, reason)
+	cs.trySend(mustEncode(typePTT, pttStatus{Reason: reason}))
+}
+
+func isInternalPTTReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalPTTSequence))
+}
@@ -0,0 +1,145 @@
+package rtc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pttSession is a session on a radio that records the commands it gets.
+func pttSession(t *testing.T, keepalive, maxTX time.Duration) (*clientSession, <-chan string) {
+	t.Helper()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{handleHex: "1234ABCD"}
+	rc.out = newTCPWriter(client, func([]byte) {}, nil)
+	t.Cleanup(rc.out.close)
+
+	lines := make(chan string, 16)
+
+	go func() {
+		rd := bufio.NewReader(radio)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			lines <- strings.TrimSpace(line)
+		}
+	}()
+
+	srv := &Server{sessions: make(map[string]*clientSession), pttKeepalive: keepalive, pttMaxTX: maxTX}
+	cs := &clientSession{id: "s1", srv: srv, radio: rc, send: make(chan message, 16)}
+
+	return cs, lines
+}
+
+func nextPTT(t *testing.T, cs *clientSession) pttStatus {
+	t.Helper()
+
+	for {
+		select {
+		case msg := <-cs.send:
+			if msg.Type != typePTT {
+				continue
+			}
+
+			var st pttStatus
+
+			err := json.Unmarshal(msg.Payload, &st)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return st
+		case <-time.After(5 * time.Second):
+			t.Fatal("no ptt status")
+
+			return pttStatus{}
+		}
+	}
+}
+
+func TestPTT_Keepalive(t *testing.T) {
+	t.Parallel()
+
+	cs, lines := pttSession(t, 50*time.Millisecond, time.Minute)
+
+	cs.handlePTT(json.RawMessage(`{"on":true}`))
+
+	if l := nextLine(t, lines); l != "C2147483641|xmit 1" {
+		t.Errorf("keyed with %q", l)
+	}
+
+	if st := nextPTT(t, cs); !st.On || st.KeepaliveMs != 50 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Repeats within the keepalive hold TX without keying again.
+	for range 4 {
+		time.Sleep(20 * time.Millisecond)
+		cs.handlePTT(json.RawMessage(`{"on":true}`))
+	}
+
+	if st := nextPTT(t, cs); st.On || st.Reason != pttReleasedKeepalive {
+		t.Errorf("status after the client went quiet = %+v", st)
+	}
+
+	if l := nextLine(t, lines); l != "C2147483641|xmit 0" {
+		t.Errorf("released with %q", l)
+	}
+}
+
+func TestPTT_MaxTX(t *testing.T) {
+	t.Parallel()
+
+	cs, lines := pttSession(t, time.Minute, 50*time.Millisecond)
+
+	cs.handlePTT(json.RawMessage(`{"on":true}`))
+	_ = nextLine(t, lines)
+	_ = nextPTT(t, cs)
+
+	if st := nextPTT(t, cs); st.On || st.Reason != pttReleasedMaxTX {
+		t.Errorf("status = %+v", st)
+	}
+
+	if l := nextLine(t, lines); l != "C2147483641|xmit 0" {
+		t.Errorf("released with %q", l)
+	}
+}
+
+func TestPTT_ClientAndSandbox(t *testing.T) {
+	t.Parallel()
+
+	cs, lines := pttSession(t, time.Minute, time.Minute)
+
+	cs.handlePTT(json.RawMessage(`{"on":true}`))
+	_ = nextLine(t, lines)
+	cs.handlePTT(json.RawMessage(`{"on":false}`))
+
+	if l := nextLine(t, lines); l != "C2147483641|xmit 0" {
+		t.Errorf("released with %q", l)
+	}
+
+	// Releasing again, as the session closing does, sends nothing more.
+	cs.releasePTT(pttReleasedClosed)
+
+	cs.setSandbox(true)
+	cs.handlePTT(json.RawMessage(`{"on":true}`))
+
+	select {
+	case l := <-lines:
+		t.Errorf("sandboxed session sent %q", l)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !isInternalPTTReply("R2147483641|0|") || isInternalPTTReply("R2147483642|0|") {
+		t.Error("isInternalPTTReply")
+	}
+}
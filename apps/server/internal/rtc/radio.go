@@ -10,38 +10,201 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/daveisadork/solid-sdr/apps/server/internal/antenna"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/captions"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/dscp"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/history"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/logbook"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/recorder"
 	"github.com/pion/webrtc/v4"
 )
 
 const internalPingSequence = 2147483647
 
+// internalUDPPortSequence tags the "client udpport" command that tells the
+// radio where to send a session's UDP, so its reply is swallowed.
+const internalUDPPortSequence = 2147483635
+
 type radioConn struct {
 	mu sync.RWMutex
 
+	addr      string
 	handleHex string
 	handleU32 uint32
-
-	tcpConn    net.Conn
-	udpConn    *net.UDPConn
-	udpRaddr   *net.UDPAddr
-	tcpDC      *webrtc.DataChannel
-	udpDC      *webrtc.DataChannel
-	tcpWriteMu sync.Mutex
+	// key identifies the connection across radios. Handles are only unique
+	// per radio, so two radios can hand out the same one.
+	key string
+
+	tcpConn  net.Conn
+	udpConn  *net.UDPConn
+	udpRaddr *net.UDPAddr
+	// tcpDC is the "tcp" data channel, or a headless session standing in
+	// for one.
+	tcpDC textSender
+	udpDC *webrtc.DataChannel
+	// udpHeld is the latest packet of each stream that arrived before udpDC
+	// was attached, sent once it is.
+	udpHeld map[uint32][]byte
+	// udpSink takes a headless session's UDP packets in place of udpDC.
+	udpSink func([]byte)
+	// streamDCs are the channels the bridge opened for streams, which carry
+	// those streams' packets in place of udpDC.
+	streamDCs map[string]*webrtc.DataChannel
+	// out orders every write to tcpConn.
+	out *tcpWriter
+
+	// owned are the streams the radio reports for our handle.
+	owned map[uint32]bool
 
 	activeRXStream uint32
 	activeTXStream uint32
 	txPacketCount  uint8
+	// onStream hears the session's audio streams start and stop.
+	onStream func(started bool, kind string, streamID uint32)
 
 	pingCancel           context.CancelFunc
 	internalPingSentAt   time.Time
 	serverToRadioRTTMax  time.Duration
 	onNetworkDiagnostics func(serverRadioNetworkDiagnostics)
 
+	// pingAnsweredAt is when the radio last answered a ping; past timeout
+	// the link is unhealthy, and onHealth hears it change.
+	pingAnsweredAt time.Time
+	unhealthy      bool
+	timeout        time.Duration
+	onHealth       func(radioHealth)
+
+	interlockState  string
+	interlockAt     time.Time
+	xmitRequestedAt time.Time
+	onTXEvent       func(guard.TXTransition)
+
+	rigMu    sync.Mutex  // one rigctl command in flight
+	rigReply chan string // waiting for the reply to it; guarded by mu
+
+	// clients are the radio's GUI clients; onClients hears them change.
+	clients     clientTable
+	onClients   func()
+	clientMu    sync.Mutex  // one registration or binding in flight
+	clientReply chan string // waiting for the reply to it; guarded by mu
+	boundClient string      // GUI client bound to, by client_id
+
+	// subs are the streams sessions declared; streamMu keeps one stream
+	// command in flight, whose reply streamReply waits for.
+	subs        streamSubs
+	streamMu    sync.Mutex
+	streamReply chan string // guarded by mu
+
+	clientsSubscribed bool
+
+	// profiles are the radio's profiles and memories; onProfiles hears
+	// them change.
+	profiles           profileTable
+	onProfiles         func()
+	memoriesSubscribed bool
+
+	// mixer is each slice's audio; onMixer hears it change.
+	mixer            mixerTable
+	onMixer          func()
+	slicesSubscribed bool
+
+	// amplifiers are the radio's amplifiers, tuners, ATU and interlock;
+	// onAmplifiers hears them change.
+	amplifiers           amplifierTable
+	onAmplifiers         func()
+	amplifiersSubscribed bool
+
+	// gps is the radio's GPS; onGPS hears it change.
+	gps           gpsState
+	onGPS         func()
+	gpsSubscribed bool
+
+	// antennas follow the radio's active slice.
+	antennas *antenna.Service
+	// logbook is told what the radio is tuned to.
+	logbook *logbook.Service
+	// history records where the radio is tuned and when it transmits.
+	history *history.Store
+
+	// meters keeps the radio's meter history; meterAlerts are checked
+	// against it, and onMeterAlert hears them raised and cleared.
+	meters       meterLog
+	meterAlerts  []MeterAlert
+	onMeterAlert func(MeterAlertEvent)
+
 	downloadDC           *webrtc.DataChannel
 	pendingDownloadSeq   uint32
 	pendingDownloadSeqOk bool
+
+	audio *audioGroups
+	info  radioInfo
+
+	// clock maps the radio's packet timestamps to wall-clock time, and
+	// lastAudio is when the latest RX audio packet's samples were taken, in
+	// Unix nanoseconds.
+	clock     radioClock
+	lastAudio atomic.Int64
+
+	recorder *recorder.Recorder
+	iq       atomic.Pointer[iqTap]
+	iqLast   IQRecordingStatus
+
+	captions  atomic.Pointer[captions.Stream]
+	listeners audioFanout
+	tunnels   udpTunnels
+
+	// waterfall renders the waterfall in place of sending it raw, while the
+	// session has a "waterfall" channel open.
+	waterfall atomic.Pointer[waterfallTiles]
+	// dax sends DAX audio decoded from its packets, while the session has
+	// an "audio" channel open.
+	dax atomic.Pointer[daxAudio]
+	// fft narrows panadapter frames to the client's display width.
+	fft *fftBinner
+
+	// udpOptions shapes, and pipeline is, the UDP receive pipeline, and
+	// udpRcvBuf is the socket's receive buffer as the OS reports it.
+	udpOptions UDPPipelineOptions
+	pipeline   atomic.Pointer[udpPipeline]
+	udpRcvBuf  int
+	// udpPorts, when set, lends the session a shared socket, udpPort, in
+	// place of one of its own.
+	udpPorts *UDPPorts
+	udpPort  *udpPort
+	// udpStreams are the streams the radio sends this connection alone,
+	// which a shared port tells its sessions of one radio apart by.
+	udpStreams streamSet
+
+	// flow is this session's share of the radio's outbound data, and fair
+	// the scheduler it belongs to; both nil when fairness is off.
+	flow *fairFlow
+	fair *fairness
+
+	capture *apiCapture
+	// audit, once set, records commands in the audit trail.
+	audit   atomic.Pointer[auditTap]
+	tcpIn   atomic.Uint64
+	tcpOut  atomic.Uint64
+	udpIn   atomic.Uint64
+	udpOut  atomic.Uint64
+	dropped atomic.Uint64 // UDP packets withheld by fairness, a full queue or backlog, or the budget
+
+	// udpBacklog is how much udpDC may buffer before forwarding waits, or
+	// with udpDrop drops the packet; zero is 1 MiB and waiting.
+	udpBacklog uint64
+	udpDrop    bool
+	// budget thins FFT, waterfall and IQ while the client's link is
+	// congested; nil when off.
+	budget *bandwidthBudget
+}
+
+// textSender is where lines from the radio go.
+type textSender interface {
+	SendText(s string) error
 }
 
 type serverRadioNetworkDiagnostics struct {
@@ -63,24 +226,23 @@ func (rc *radioConn) sendTCPLine(line string) {
 	_ = dc.SendText(line)
 }
 
+// writeTCP queues data for the radio behind everything queued before it.
+// Write failures are reported to the session asynchronously.
 func (rc *radioConn) writeTCP(data []byte) error {
-	rc.mu.RLock()
-	tcp := rc.tcpConn
-	rc.mu.RUnlock()
-
-	if tcp == nil {
-		return net.ErrClosed
-	}
+	return rc.out.write(data)
+}
 
-	rc.tcpWriteMu.Lock()
-	defer rc.tcpWriteMu.Unlock()
+// tcpWritten accounts for a command once it has reached the socket.
+func (rc *radioConn) tcpWritten(data []byte) {
+	rc.tcpOut.Add(uint64(len(data)))
 
-	_, err := tcp.Write(data)
-	if err != nil {
-		return fmt.Errorf("write to radio: %w", err)
+	if rc.capture != nil {
+		rc.capture.record(rc.key, ">>", string(data))
 	}
 
-	return nil
+	if a := rc.audit.Load(); a != nil {
+		a.record(rc, data)
+	}
 }
 
 func (rc *radioConn) writeTCPString(line string) error {
@@ -116,8 +278,13 @@ func (rc *radioConn) noteStreamCreated(streamID uint32, typ, compression string)
 		rc.mu.Lock()
 		rc.activeTXStream = streamID
 		rc.txPacketCount = 0
+		onStream := rc.onStream
 		rc.mu.Unlock()
-		log.Printf("[rtc] tx audio stream %s registered (handle 0x%s)", stream, rc.handleHex)
+		log.Printf("[rtc] tx audio stream %s registered (%s)", stream, rc.key)
+
+		if onStream != nil {
+			onStream(true, StreamTXAudio, streamID)
+		}
 	case "remote_audio_rx":
 		if compression != compressionOPUS {
 			return
@@ -125,34 +292,53 @@ func (rc *radioConn) noteStreamCreated(streamID uint32, typ, compression string)
 
 		rc.mu.Lock()
 		rc.activeRXStream = streamID
+		onStream := rc.onStream
 		rc.mu.Unlock()
-		log.Printf("[rtc] rx audio stream %s activated (handle 0x%s)", stream, rc.handleHex)
+		log.Printf("[rtc] rx audio stream %s activated (%s)", stream, rc.key)
+
+		if onStream != nil {
+			onStream(true, StreamRXAudio, streamID)
+		}
 	}
 }
 
 func (rc *radioConn) noteStreamRemoved(streamID uint32) {
 	rc.mu.Lock()
-	defer rc.mu.Unlock()
+
+	kind := ""
 
 	if rc.activeRXStream == streamID {
 		rc.activeRXStream = 0
+		kind = StreamRXAudio
 	}
 
 	if rc.activeTXStream == streamID {
 		rc.activeTXStream = 0
 		rc.txPacketCount = 0
+		kind = StreamTXAudio
 	}
 
-	log.Printf("[rtc] audio stream 0x%08X removed (handle 0x%s)", streamID, rc.handleHex)
+	onStream := rc.onStream
+	rc.mu.Unlock()
+
+	log.Printf("[rtc] audio stream 0x%08X removed (%s)", streamID, rc.key)
+
+	if kind != "" && onStream != nil {
+		onStream(false, kind, streamID)
+	}
 }
 
 // newRadioConn dials TCP to addr, reads the 2-line radio handshake, and starts
-// the TCP forwarder goroutine. dc must be the "tcp" data channel.
+// the TCP forwarder goroutine. dc must be the "tcp" data channel, or whatever
+// stands in for it.
 func newRadioConn(
 	ctx context.Context,
-	dc *webrtc.DataChannel,
+	dc textSender,
 	addr string,
+	capture *apiCapture,
 	onNetworkDiagnostics func(serverRadioNetworkDiagnostics),
+	onTXEvent func(guard.TXTransition),
+	onWriteError func(error),
 ) (*radioConn, error) {
 	dialer := net.Dialer{Timeout: 10 * time.Second}
 
@@ -192,26 +378,46 @@ func newRadioConn(
 	pingCtx, pingCancel := context.WithCancel(ctx)
 
 	rc := &radioConn{
+		addr:                 addr,
 		handleHex:            handleHex,
+		key:                  radioKey(addr, handleHex),
 		handleU32:            uint32(handleU32),
 		tcpConn:              tcp,
 		tcpDC:                dc,
 		pingCancel:           pingCancel,
+		pingAnsweredAt:       time.Now(),
 		onNetworkDiagnostics: onNetworkDiagnostics,
+		onTXEvent:            onTXEvent,
+		capture:              capture,
+		audio:                newAudioGroups(),
+		fft:                  newFFTBinner(),
 	}
+	rc.out = newTCPWriter(tcp, rc.tcpWritten, onWriteError)
 
 	rc.sendTCPLine(line1)
 	rc.sendTCPLine(line2)
 	rc.reportServerToRadioRTT(nil, nil, time.Now())
 
-	log.Printf("[rtc] radio connected handle=0x%s", handleHex)
+	log.Printf("[rtc] radio connected %s", rc.key)
 
 	go rc.tcpForwarder(ctx, rd)
+
+	rc.enableKeepalive()
+
 	go rc.internalPingLoop(pingCtx)
 
 	return rc, nil
 }
 
+// radioKey returns the composite "host:port/0xHANDLE" key for a connection.
+func radioKey(addr, handleHex string) string {
+	return addr + "/0x" + handleHex
+}
+
+func isInternalUDPPortReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalUDPPortSequence))
+}
+
 // openUDP binds an unconnected UDP socket, tells the radio our local port, and
 // remembers the radio's address for outgoing writes. We use ListenUDP (not
 // DialUDP) so we can accept incoming packets from any source port the radio
@@ -223,26 +429,70 @@ func (rc *radioConn) openUDP(dc *webrtc.DataChannel, addr string) error {
 		return fmt.Errorf("resolve radio udp addr %s: %w", addr, err)
 	}
 
-	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	rc.mu.RLock()
+	ports := rc.udpPorts
+	want := rc.udpOptions.ReceiveBuffer
+	codePoint := rc.udpOptions.DSCP
+	rc.mu.RUnlock()
+
+	if ports != nil {
+		return rc.openSharedUDP(ports, dc, raddr)
+	}
+
+	u, err := listenRadioUDP(raddr)
 	if err != nil {
 		return fmt.Errorf("listen udp: %w", err)
 	}
 
+	// A band scan can outrun the default buffer while a worker is busy.
+	if want > 0 {
+		err = u.SetReadBuffer(want)
+		if err != nil {
+			log.Printf("[rtc] %s: udp receive buffer: %v", rc.key, err)
+		}
+	}
+
+	got := receiveBuffer(u)
+	if got > 0 && got < want {
+		log.Printf("[rtc] %s: udp receive buffer is %d bytes, not %d; raise the OS limit (net.core.rmem_max on Linux)", rc.key, got, want)
+	}
+
+	err = dscp.Mark(u, codePoint)
+	if err != nil {
+		log.Printf("[rtc] %s: udp %v", rc.key, err)
+	}
+
 	rc.mu.Lock()
 	rc.udpConn = u
 	rc.udpRaddr = raddr
 	rc.udpDC = dc
+	rc.udpRcvBuf = got
 	rc.mu.Unlock()
 
 	if ua, ok := u.LocalAddr().(*net.UDPAddr); ok {
-		_ = rc.writeTCPString(fmt.Sprintf("C0|client udpport %d\n", ua.Port))
+		_ = rc.writeTCPString(fmt.Sprintf("C%d|client udpport %d\n", internalUDPPortSequence, ua.Port))
 	}
 
 	return nil
 }
 
-// close shuts down TCP and UDP connections.
+// listenRadioUDP binds a socket on any port of the radio's address family,
+// as the radio sends its streams back over the family we register from.
+func listenRadioUDP(raddr *net.UDPAddr) (*net.UDPConn, error) {
+	network := "udp4"
+	if raddr.IP.To4() == nil {
+		network = "udp6"
+	}
+
+	return net.ListenUDP(network, nil) //nolint:wrapcheck // openUDP wraps it
+}
+
+// close shuts down TCP and UDP connections, after writing whatever commands
+// are still queued.
 func (rc *radioConn) close() {
+	rc.out.close()
+	rc.stopIQ()
+
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 
@@ -256,10 +506,15 @@ func (rc *radioConn) close() {
 		rc.pingCancel = nil
 	}
 
-	if rc.udpConn != nil {
-		_ = rc.udpConn.Close()
-		rc.udpConn = nil
-	}
+	rc.releaseUDP(rc.udpConn, rc.udpPort)
+	rc.udpConn, rc.udpPort = nil, nil
+
+	rc.setCaptions(nil)
+	rc.listeners.close()
+	rc.tunnels.close()
+
+	rc.fair.leave(rc.flow)
+	rc.flow = nil
 }
 
 func (rc *radioConn) setDownloadDC(dc *webrtc.DataChannel) {
@@ -274,8 +529,11 @@ var (
 )
 
 // noteOutgoingCommand inspects data the client is about to send to the radio
-// and records the sequence number of any `file download` command.
+// and records the sequence number of any `file download` command and the time
+// of any `xmit` request.
 func (rc *radioConn) noteOutgoingCommand(data []byte) {
+	rc.noteXmitRequest(data, time.Now())
+
 	line := strings.TrimRight(string(data), "\r\n")
 
 	m := reFileDownloadCmd.FindStringSubmatch(line)
@@ -350,12 +608,36 @@ func (rc *radioConn) tcpForwarder(ctx context.Context, rd *bufio.Reader) {
 			return
 		}
 
+		readAt := time.Now()
+
+		rc.tcpIn.Add(uint64(len(b)))
+
 		trimmed := strings.TrimSpace(b)
 
-		if rc.consumeInternalPingReply(trimmed, time.Now()) {
+		if rc.consumeInternalPingReply(trimmed, time.Now()) || isInternalKeepaliveReply(trimmed) ||
+			isInternalAudioGroupReply(trimmed) || isInternalSandboxReply(trimmed) || rc.consumeRigctlReply(trimmed) ||
+			isInternalCWReply(trimmed) || isInternalPTTReply(trimmed) || rc.consumeClientReply(trimmed) ||
+			rc.consumeStreamReply(trimmed) || isInternalCleanupReply(trimmed) || isInternalMuteReply(trimmed) ||
+			isInternalUDPPortReply(trimmed) {
 			continue
 		}
 
+		if _, body, ok := strings.Cut(trimmed, "|"); ok && strings.HasPrefix(trimmed, "S") {
+			rc.noteInterlock(body, readAt)
+			rc.info.observe(body)
+			rc.observeClients(body)
+			rc.observeProfiles(body)
+			rc.observeMixer(body)
+			rc.observeAmplifiers(body)
+			rc.observeGPS(body)
+			rc.followAntennas(body)
+			rc.reportLogbook(body)
+			rc.reportHistory(body)
+			rc.meters.observe(body)
+			rc.retuneIQ()
+			rc.sendAudioGroupCommands(rc.audioGroups().observe(body))
+		}
+
 		// Intercept file download replies: start the TCP listener BEFORE
 		// forwarding the reply to the client so the radio never connects to
 		// a port we haven't opened yet.
@@ -379,11 +661,19 @@ func (rc *radioConn) tcpForwarder(ctx context.Context, rd *bufio.Reader) {
 
 		rc.sendTCPLine(b)
 
+		if rc.capture != nil {
+			rc.capture.record(rc.key, "<<", b)
+		}
+
+		rc.noteUDPStream(b)
+
 		stream, ok := parseAudioStream(b)
 		if !ok {
 			continue
 		}
 
+		rc.noteOwnedStream(stream)
+
 		if stream.Removed {
 			rc.noteStreamRemoved(stream.StreamID)
 
@@ -411,6 +701,7 @@ func (rc *radioConn) internalPingLoop(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case tick := <-ticker.C:
+			rc.checkHealth(tick)
 			rc.sendInternalPing(tick)
 		}
 	}
@@ -444,10 +735,16 @@ func (rc *radioConn) consumeInternalPingReply(line string, now time.Time) bool {
 
 	rc.mu.Lock()
 
+	healthy := rc.pingAnswered(now)
+
 	sentAt := rc.internalPingSentAt
 	if sentAt.IsZero() {
 		rc.mu.Unlock()
 
+		if healthy != nil {
+			healthy()
+		}
+
 		return false
 	}
 
@@ -462,6 +759,10 @@ func (rc *radioConn) consumeInternalPingReply(line string, now time.Time) bool {
 	maxMs := int64(rc.serverToRadioRTTMax / time.Millisecond)
 	rc.mu.Unlock()
 
+	if healthy != nil {
+		healthy()
+	}
+
 	rc.reportServerToRadioRTT(&currentMs, &maxMs, now)
 
 	return true
@@ -472,13 +773,25 @@ func (rc *radioConn) reportServerToRadioRTT(
 	maxMs *int64,
 	now time.Time,
 ) {
-	if rc.onNetworkDiagnostics == nil {
+	rc.mu.RLock()
+	onNetworkDiagnostics := rc.onNetworkDiagnostics
+	rc.mu.RUnlock()
+
+	if onNetworkDiagnostics == nil {
 		return
 	}
 
-	rc.onNetworkDiagnostics(serverRadioNetworkDiagnostics{
+	onNetworkDiagnostics(serverRadioNetworkDiagnostics{
 		ServerToRadioRttMs:    currentMs,
 		ServerToRadioRttMaxMs: maxMs,
 		SampledAt:             now.UnixMilli(),
 	})
 }
+
+// setUDPBacklog sets how much the "udp" channel may buffer, and whether
+// packets past that are dropped rather than waited on.
+func (rc *radioConn) setUDPBacklog(limit uint64, drop bool) {
+	rc.mu.Lock()
+	rc.udpBacklog, rc.udpDrop = limit, drop
+	rc.mu.Unlock()
+}
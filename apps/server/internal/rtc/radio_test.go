@@ -1,6 +1,10 @@
 package rtc
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
 	"testing"
 	"time"
 )
@@ -52,6 +56,54 @@ func TestNextTXPacket_WrapsAt16(t *testing.T) {
 	}
 }
 
+func TestSendTXAudio(t *testing.T) {
+	t.Parallel()
+
+	radio, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{activeTXStream: 0x84000001}
+	if err := rc.sendTXAudio([]byte{0x78}); !errors.Is(err, errNoUDP) {
+		t.Fatalf("without UDP: %v", err)
+	}
+
+	u, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = u.Close() })
+
+	rc.udpConn, rc.udpRaddr = u, radio.LocalAddr().(*net.UDPAddr) //nolint:forcetypeassert // udp listener
+
+	err = rc.sendTXAudio([]byte{0x78, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = radio.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 1500)
+
+	n, _, err := radio.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := parseVITA(buf[:n])
+	if err != nil || v.StreamID != 0x84000001 || !slices.Equal(v.Payload[:2], []byte{0x78, 0x01}) {
+		t.Errorf("radio got %+v, %v", v, err)
+	}
+
+	if rc.udpOut.Load() != uint64(n) {
+		t.Errorf("udpOut = %d, sent %d", rc.udpOut.Load(), n)
+	}
+}
+
 func TestNoteStreamCreated_RX(t *testing.T) {
 	t.Parallel()
 
@@ -190,3 +242,114 @@ func TestConsumeInternalPingReply_IgnoresNonInternalReply(t *testing.T) {
 		t.Fatal("unexpected diagnostics callback for non-internal reply")
 	}
 }
+
+func TestRadioKey_DistinguishesRadiosSharingAHandle(t *testing.T) {
+	t.Parallel()
+
+	a := radioKey("192.0.2.10:4992", "6A2B1C00")
+	b := radioKey("192.0.2.11:4992", "6A2B1C00")
+
+	if a == b {
+		t.Errorf("keys for different radios collide: %q", a)
+	}
+
+	if a != "192.0.2.10:4992/0x6A2B1C00" {
+		t.Errorf("unexpected key format %q", a)
+	}
+}
+
+func TestSessionLookup_ByRadioKey(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{sessions: make(map[string]*clientSession)}
+	a, b := &clientSession{id: "a", srv: srv}, &clientSession{id: "b", srv: srv}
+	rcA := &radioConn{addr: "192.0.2.10:4992", handleHex: "6A2B1C00"}
+	rcB := &radioConn{addr: "192.0.2.11:4992", handleHex: "6A2B1C00"}
+	rcA.key, rcB.key = radioKey(rcA.addr, rcA.handleHex), radioKey(rcB.addr, rcB.handleHex)
+
+	for cs, rc := range map[*clientSession]*radioConn{a: rcA, b: rcB} {
+		srv.sessions[cs.id] = cs
+		srv.bindRadio(cs, rc)
+	}
+
+	if got := srv.session(rcA.key); got != a {
+		t.Errorf("session(%q) = %v, want a", rcA.key, got)
+	}
+
+	if got := srv.session(rcB.key); got != b {
+		t.Errorf("session(%q) = %v, want b", rcB.key, got)
+	}
+
+	srv.unbindRadio(a, rcA)
+
+	if got := srv.session(rcA.key); got != nil {
+		t.Errorf("after unbinding, session(%q) = %v", rcA.key, got)
+	}
+
+	srv.removeSession(b)
+
+	if got := srv.session(rcB.key); got != nil {
+		t.Errorf("after removal, session(%q) = %v", rcB.key, got)
+	}
+}
+
+func TestNoteStream_Events(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+
+	rc := &radioConn{handleHex: testHandleHex, onStream: func(started bool, kind string, id uint32) {
+		got = append(got, fmt.Sprintf("%t %s 0x%X", started, kind, id))
+	}}
+
+	rc.noteStreamCreated(0x04000008, "remote_audio_rx", compressionOPUS)
+	rc.noteStreamCreated(0x08000001, "remote_audio_tx", "PCM")
+	rc.noteStreamRemoved(0x999)
+	rc.noteStreamRemoved(0x04000008)
+
+	want := []string{"true rx_audio 0x4000008", "false rx_audio 0x4000008"}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestListenRadioUDP_RadioFamily(t *testing.T) {
+	t.Parallel()
+
+	radio, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer func() { _ = radio.Close() }()
+
+	raddr := radio.LocalAddr().(*net.UDPAddr) //nolint:forcetypeassert // a UDP socket
+
+	u, err := listenRadioUDP(raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = u.Close() }()
+
+	port := u.LocalAddr().(*net.UDPAddr).Port //nolint:forcetypeassert // a UDP socket
+
+	// The radio's streams come back to the port we register, from its
+	// own address.
+	_, err = radio.WriteToUDP([]byte("vita"), &net.UDPAddr{IP: net.IPv6loopback, Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = u.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	buf := make([]byte, 16)
+
+	n, src, err := u.ReadFromUDP(buf)
+	if err != nil || string(buf[:n]) != "vita" || !src.IP.Equal(raddr.IP) {
+		t.Errorf("read %q from %v, %v", buf[:n], src, err)
+	}
+
+	_, err = u.WriteToUDP([]byte("cmd"), raddr)
+	if err != nil {
+		t.Errorf("write to the radio: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package rtc
+
+import (
+	"sync"
+	"time"
+)
+
+// Where a radio's packet times come from.
+const (
+	// ClockUTC is the radio's own UTC timestamps.
+	ClockUTC = "utc"
+	// ClockGPS is the radio's GPS timestamps, less the leap seconds GPS
+	// time does not count.
+	ClockGPS = "gps"
+	// ClockBridge is the bridge's clock: a stream's sample count counted on
+	// from when a packet of it arrived, or the arrival time itself.
+	ClockBridge = "bridge"
+)
+
+const (
+	// VITA-49 TSI types: what a packet's integer timestamp counts.
+	tsiUTC = 1
+	tsiGPS = 2
+	// gpsLeapSeconds is how far GPS time runs ahead of UTC, since 2017.
+	gpsLeapSeconds = 18
+	// clockSlack is how far behind its arrival a sample count may put a
+	// packet before the stream is anchored again, after a gap or as the
+	// radio's clock drifts from the bridge's.
+	clockSlack = 200 * time.Millisecond
+	// maxClockStreams bounds the streams anchored.
+	maxClockStreams = 64
+)
+
+// gpsEpoch is when GPS time began.
+var gpsEpoch = time.Date(1980, time.January, 6, 0, 0, 0, 0, time.UTC)
+
+// ClockStats is how a session's radio timestamps map to wall-clock time.
+type ClockStats struct {
+	Source string `json:"source"`
+	// OffsetMs is how far the bridge's clock was ahead of the radio's when
+	// its packets arrived, at least, over the last interval: the difference
+	// of the two clocks plus the quickest delivery. Only the radio's own
+	// clocks have one.
+	OffsetMs *float64 `json:"offsetMs,omitempty"`
+}
+
+// clockAnchor pins a stream's sample count to the bridge's clock.
+type clockAnchor struct {
+	count uint32
+	wall  time.Time
+}
+
+// radioClock maps the radio's VITA-49 timestamps to wall-clock time, so what
+// two radios, or a radio and a log, captured can be lined up afterwards.
+// Packets stamped with real UTC or GPS time keep it; a sample count is laid
+// on the bridge's clock from the packet that arrived soonest, so network
+// jitter does not spread the times it gives.
+type radioClock struct {
+	mu      sync.Mutex
+	anchors map[uint32]clockAnchor
+	source  string
+	// offset is the least the bridge's clock was ahead of an absolute
+	// packet time since the last stats, and offsets how many were seen.
+	offset  time.Duration
+	offsets int
+}
+
+// at is when v's samples were taken, for a packet that arrived now.
+func (c *radioClock) at(v vitaView, now time.Time) time.Time {
+	if t, source, ok := vitaTime(v); ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.source = source
+		if off := now.Sub(t); c.offsets == 0 || off < c.offset {
+			c.offset = off
+		}
+
+		c.offsets++
+
+		return t
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// An absolute time seen in the interval says more of the clock.
+	if c.offsets == 0 {
+		c.source = ClockBridge
+	}
+
+	rate := sampleRate(v.ClassCode)
+	if rate == 0 || v.TSF != tsfSampleCount {
+		return now
+	}
+
+	a, ok := c.anchors[v.StreamID]
+	if !ok && len(c.anchors) >= maxClockStreams {
+		return now
+	}
+
+	samples := int64(int32(v.FractionalTimestamp - a.count)) //nolint:gosec // a late packet counts back
+	t := a.wall.Add(time.Duration(samples * int64(time.Second) / int64(rate)))
+
+	// Arriving sooner than its count says means the anchor's packet was
+	// held up on the way; this one is the better anchor.
+	if !ok || t.After(now) || now.Sub(t) > clockSlack {
+		if c.anchors == nil {
+			c.anchors = make(map[uint32]clockAnchor)
+		}
+
+		c.anchors[v.StreamID] = clockAnchor{count: v.FractionalTimestamp, wall: now}
+
+		return now
+	}
+
+	return t
+}
+
+// stats reports the clock, starting a new interval for its offset. It is
+// nil before any packet has been timed.
+func (c *radioClock) stats() *ClockStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.source == "" {
+		return nil
+	}
+
+	st := &ClockStats{Source: c.source}
+
+	if c.offsets > 0 && c.source != ClockBridge {
+		ms := float64(c.offset) / float64(time.Millisecond)
+		st.OffsetMs = &ms
+	}
+
+	c.offsets = 0
+
+	return st
+}
+
+// vitaTime is the absolute time a packet is stamped with, when it carries
+// real UTC or GPS time, and which.
+func vitaTime(v vitaView) (time.Time, string, bool) {
+	if v.TSF != tsfRealTime || v.IntegerTimestamp == 0 {
+		return time.Time{}, "", false
+	}
+
+	ps := uint64(v.FractionalTimestampMSB)<<32 | uint64(v.FractionalTimestamp)
+	if ps >= picosPerSecond {
+		return time.Time{}, "", false
+	}
+
+	frac := time.Duration(ps / 1000) //nolint:gosec // under a second
+
+	switch v.TSI {
+	case tsiUTC:
+		return time.Unix(int64(v.IntegerTimestamp), 0).Add(frac).UTC(), ClockUTC, true
+	case tsiGPS:
+		return gpsEpoch.Add(time.Duration(int64(v.IntegerTimestamp)-gpsLeapSeconds)*time.Second + frac), ClockGPS, true
+	default:
+		return time.Time{}, "", false
+	}
+}
+
+// sampleRate is the sample clock a packet class's sample count runs at, or
+// 0 when it has none.
+func sampleRate(class uint16) int {
+	switch class {
+	case 0x8005:
+		return opusClockRate
+	case daxAudioClass, daxReducedClass:
+		return daxAudioRate
+	default:
+		return iqSampleRate(class)
+	}
+}
@@ -0,0 +1,110 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVitaTime(t *testing.T) {
+	t.Parallel()
+
+	half := uint64(picosPerSecond / 2)
+	at := func(tsi uint8, secs uint32) vitaView {
+		return vitaView{
+			TSI: tsi, TSF: tsfRealTime, IntegerTimestamp: secs,
+			FractionalTimestampMSB: uint32(half >> 32), FractionalTimestamp: uint32(half),
+		}
+	}
+
+	utc, source, ok := vitaTime(at(tsiUTC, 1_700_000_000))
+	if want := time.Unix(1_700_000_000, 5e8).UTC(); !ok || source != ClockUTC || !utc.Equal(want) {
+		t.Errorf("UTC = %v %q %v, want %v", utc, source, ok, want)
+	}
+
+	// The same instant in GPS time: 315964800 s between the epochs, and 18
+	// leap seconds.
+	gps, source, ok := vitaTime(at(tsiGPS, 1_700_000_000-315_964_800+18))
+	if !ok || source != ClockGPS || !gps.Equal(utc) {
+		t.Errorf("GPS = %v %q %v, want %v", gps, source, ok, utc)
+	}
+
+	for name, v := range map[string]vitaView{
+		"sample count": {TSI: tsiUTC, TSF: tsfSampleCount, IntegerTimestamp: 1},
+		"other clock":  at(3, 1_700_000_000),
+		"no seconds":   at(tsiUTC, 0),
+	} {
+		if _, _, ok := vitaTime(v); ok {
+			t.Errorf("%s: has an absolute time", name)
+		}
+	}
+}
+
+func TestRadioClock_SampleCount(t *testing.T) {
+	t.Parallel()
+
+	var c radioClock
+
+	start := time.Unix(1_700_000_000, 0)
+	packet := func(count uint32) vitaView {
+		return vitaView{ClassCode: 0x8005, StreamID: 1, TSF: tsfSampleCount, FractionalTimestamp: count}
+	}
+
+	if got := c.at(packet(1000), start); !got.Equal(start) {
+		t.Fatalf("first packet at %v, want its arrival", got)
+	}
+
+	// 10 ms on, but held up 30 ms on the way: timed by its count.
+	if got, want := c.at(packet(1480), start.Add(40*time.Millisecond)), start.Add(10*time.Millisecond); !got.Equal(want) {
+		t.Errorf("late packet at %v, want %v", got, want)
+	}
+
+	// One that arrives sooner than its count says becomes the anchor.
+	early := start.Add(15 * time.Millisecond)
+	if got := c.at(packet(1960), early); !got.Equal(early) {
+		t.Errorf("early packet at %v, want %v", got, early)
+	}
+
+	if got, want := c.at(packet(2440), early.Add(25*time.Millisecond)), early.Add(10*time.Millisecond); !got.Equal(want) {
+		t.Errorf("after re-anchoring at %v, want %v", got, want)
+	}
+
+	// After a gap the count is laid on the clock again.
+	later := start.Add(time.Minute)
+	if got := c.at(packet(2920), later); !got.Equal(later) {
+		t.Errorf("after a gap at %v, want %v", got, later)
+	}
+
+	if st := c.stats(); st == nil || st.Source != ClockBridge || st.OffsetMs != nil {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestRadioClock_Stats(t *testing.T) {
+	t.Parallel()
+
+	var c radioClock
+
+	if st := c.stats(); st != nil {
+		t.Fatalf("stats before any packet = %+v", st)
+	}
+
+	stamp := time.Unix(1_700_000_000, 0)
+	v := vitaView{ClassCode: vitaMeterClass, TSI: tsiUTC, TSF: tsfRealTime, IntegerTimestamp: 1_700_000_000}
+
+	c.at(v, stamp.Add(30*time.Millisecond))
+	c.at(v, stamp.Add(12*time.Millisecond))
+	// Sample counts in the same interval leave the radio's clock reported.
+	c.at(vitaView{ClassCode: 0x8005, TSF: tsfSampleCount}, stamp)
+
+	st := c.stats()
+	if st == nil || st.Source != ClockUTC || st.OffsetMs == nil || *st.OffsetMs != 12 {
+		t.Fatalf("stats = %+v", st)
+	}
+
+	// A new interval without absolute times has no offset.
+	c.at(vitaView{ClassCode: 0x8005, TSF: tsfSampleCount}, stamp)
+
+	if st := c.stats(); st.Source != ClockBridge || st.OffsetMs != nil {
+		t.Errorf("next interval = %+v", st)
+	}
+}
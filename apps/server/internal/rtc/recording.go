@@ -0,0 +1,613 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/recorder"
+)
+
+var (
+	errNoRecorder     = errors.New("recording is not configured on this bridge")
+	errRecordingBusy  = errors.New("a recording is already running or scheduled")
+	errRecordingRadio = errors.New("session has no radio connection")
+	errRecordingTime  = errors.New("duration must be a positive Go duration")
+	errRecordingVerb  = errors.New("unknown recording action")
+
+	// ErrNoSession is returned by actions on a session the bridge doesn't
+	// have.
+	ErrNoSession = errors.New("no such session")
+)
+
+// RecordingRequest starts or stops a session's recording. It arrives as the
+// payload of a "recording" message or through the admin API.
+type RecordingRequest struct {
+	Action   string     `json:"action"`             // start | stop
+	Format   string     `json:"format,omitempty"`   // ogg (default) | wav
+	Slice    *int       `json:"slice,omitempty"`    // names the file after this slice's letter and frequency
+	Squelch  bool       `json:"squelch,omitempty"`  // skip silence, keeping only overs
+	At       *time.Time `json:"at,omitempty"`       // start later instead of now
+	Duration string     `json:"duration,omitempty"` // stop automatically after this long
+}
+
+// RecordingStatus reports a session's recording.
+type RecordingStatus struct {
+	Active    bool       `json:"active"`
+	Scheduled *time.Time `json:"scheduled,omitempty"`
+	Key       string     `json:"key,omitempty"` // store key of the current or last recording
+	Format    string     `json:"format,omitempty"`
+	Squelch   bool       `json:"squelch,omitempty"`
+	Started   *time.Time `json:"started,omitempty"`
+	// FirstSample is when the first audio written was taken, by the radio's
+	// timestamps where it sends real time; see the session's clock stats.
+	FirstSample *time.Time `json:"firstSample,omitempty"`
+	Recorded    float64    `json:"recorded,omitempty"` // seconds of audio written
+	Error       string     `json:"error,omitempty"`
+}
+
+// recordingState is a session's recording, running or scheduled, fed from
+// the radio link's audio fan-out.
+type recordingState struct {
+	mu        sync.Mutex
+	rec       *recorder.Recording
+	req       RecordingRequest
+	started   time.Time
+	first     time.Time // when the first packet written was taken
+	scheduled time.Time
+	timer     *time.Timer // pending scheduled start or automatic stop
+	feed      chan []byte
+	rc        *radioConn
+	done      chan error // receives the result once the feed goroutine has finished the file
+	last      RecordingStatus
+}
+
+// radioInfo is what recordings are named after, picked up from status.
+type radioInfo struct {
+	mu       sync.Mutex
+	callsign string
+	slices   map[int]sliceInfo
+	iq       map[uint32]iqStreamInfo // DAX IQ streams by stream ID
+	pans     map[uint32]float64      // panadapter center frequencies (MHz) by ID
+	views    map[uint32]panView      // panadapter widths and owners by ID
+}
+
+type sliceInfo struct {
+	letter   string
+	freqMHz  float64
+	mode     string
+	filterLo int // Hz relative to the slice frequency
+	filterHi int
+	tx       bool
+	active   bool
+}
+
+// panView is what a panadapter shows besides its center.
+type panView struct {
+	bandwidthMHz float64
+	client       uint32
+}
+
+type iqStreamInfo struct {
+	channel int
+	pan     uint32
+	client  uint32
+}
+
+// observe updates the info from a status body.
+func (ri *radioInfo) observe(body string) {
+	switch {
+	case strings.HasPrefix(body, "radio "):
+		if cs := extractString(body, "callsign="); cs != "" {
+			ri.mu.Lock()
+			ri.callsign = cs
+			ri.mu.Unlock()
+		}
+	case strings.HasPrefix(body, "slice "):
+		idxStr, attrs, _ := strings.Cut(strings.TrimPrefix(body, "slice "), " ")
+
+		idx, err := strconv.Atoi(idxStr)
+		if err != nil {
+			return
+		}
+
+		ri.mu.Lock()
+		defer ri.mu.Unlock()
+
+		if ri.slices == nil {
+			ri.slices = make(map[int]sliceInfo)
+		}
+
+		f := statusAttrs(attrs)
+		if f["in_use"] == "0" {
+			delete(ri.slices, idx)
+
+			return
+		}
+
+		s := ri.slices[idx]
+		if l := f["index_letter"]; l != "" {
+			s.letter = l
+		}
+
+		if v, err := strconv.ParseFloat(f["RF_frequency"], 64); err == nil {
+			s.freqMHz = v
+		}
+
+		if m := f["mode"]; m != "" {
+			s.mode = m
+		}
+
+		if v, err := strconv.Atoi(f["filter_lo"]); err == nil {
+			s.filterLo = v
+		}
+
+		if v, err := strconv.Atoi(f["filter_hi"]); err == nil {
+			s.filterHi = v
+		}
+
+		if v, ok := f["tx"]; ok {
+			s.tx = v == "1"
+		}
+
+		if v, ok := f["active"]; ok {
+			s.active = v == "1"
+		}
+
+		ri.slices[idx] = s
+	case strings.HasPrefix(body, "stream "):
+		ri.observeStream(body)
+	case strings.HasPrefix(body, "display pan "):
+		id, attrs, _ := strings.Cut(strings.TrimPrefix(body, "display pan "), " ")
+
+		pan := parseHex32(id)
+		if pan == 0 {
+			return
+		}
+
+		f := statusAttrs(attrs)
+
+		ri.mu.Lock()
+		defer ri.mu.Unlock()
+
+		if ri.pans == nil {
+			ri.pans = make(map[uint32]float64)
+			ri.views = make(map[uint32]panView)
+		}
+
+		if _, ok := f["removed"]; ok {
+			delete(ri.pans, pan)
+			delete(ri.views, pan)
+
+			return
+		}
+
+		if c, err := strconv.ParseFloat(f["center"], 64); err == nil {
+			ri.pans[pan] = c
+		}
+
+		view := ri.views[pan]
+
+		if bw, err := strconv.ParseFloat(f["bandwidth"], 64); err == nil {
+			view.bandwidthMHz = bw
+		}
+
+		if v, ok := f["client_handle"]; ok {
+			view.client = parseHex32(v)
+		}
+
+		ri.views[pan] = view
+	}
+}
+
+// observeStream tracks DAX IQ streams and the panadapters they tap.
+func (ri *radioInfo) observeStream(body string) {
+	id, attrs, _ := strings.Cut(strings.TrimPrefix(body, "stream "), " ")
+
+	stream := parseHex32(id)
+	if stream == 0 {
+		return
+	}
+
+	f := statusAttrs(attrs)
+
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	if _, ok := f["removed"]; ok {
+		delete(ri.iq, stream)
+
+		return
+	}
+
+	s, known := ri.iq[stream]
+	if !known && f["type"] != "dax_iq" {
+		return
+	}
+
+	if ch, err := strconv.Atoi(f["daxiq_channel"]); err == nil {
+		s.channel = ch
+	}
+
+	if p := parseHex32(f["pan"]); p != 0 {
+		s.pan = p
+	}
+
+	if c := parseHex32(f["client_handle"]); c != 0 {
+		s.client = c
+	}
+
+	if ri.iq == nil {
+		ri.iq = make(map[uint32]iqStreamInfo)
+	}
+
+	ri.iq[stream] = s
+}
+
+// statusAttrs splits "key=value" attributes; keys without a value map to "".
+func statusAttrs(attrs string) map[string]string {
+	f := make(map[string]string)
+
+	for kv := range strings.FieldsSeq(attrs) {
+		k, v, _ := strings.Cut(kv, "=")
+		f[k] = v
+	}
+
+	return f
+}
+
+// parseHex32 parses "0x40000000"; it returns 0 for anything else.
+func parseHex32(s string) uint32 {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 32)
+	if err != nil {
+		return 0
+	}
+
+	return uint32(v)
+}
+
+// meta names a recording of slice (nil for the whole mix) starting now.
+func (ri *radioInfo) meta(slice *int) recorder.Meta {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	m := recorder.Meta{Callsign: ri.callsign, Start: time.Now()}
+
+	if slice != nil {
+		s, ok := ri.slices[*slice]
+		m.Slice, m.FreqMHz = s.letter, s.freqMHz
+
+		if !ok || s.letter == "" {
+			m.Slice = strconv.Itoa(*slice)
+		}
+	}
+
+	return m
+}
+
+// StartRecording starts, or schedules, session id's recording.
+func (s *Server) StartRecording(id string, req RecordingRequest) (RecordingStatus, error) {
+	cs := s.session(id)
+	if cs == nil {
+		return RecordingStatus{}, ErrNoSession
+	}
+
+	return cs.startRecording(req)
+}
+
+// StopRecording stops or unschedules session id's recording.
+func (s *Server) StopRecording(id string) (RecordingStatus, error) {
+	cs := s.session(id)
+	if cs == nil {
+		return RecordingStatus{}, ErrNoSession
+	}
+
+	return cs.stopRecording(), nil
+}
+
+// Recording reports session id's recording.
+func (s *Server) Recording(id string) (RecordingStatus, bool) {
+	cs := s.session(id)
+	if cs == nil {
+		return RecordingStatus{}, false
+	}
+
+	return cs.recordingStatus(), true
+}
+
+func (cs *clientSession) startRecording(req RecordingRequest) (RecordingStatus, error) {
+	if cs.srv.recorder == nil {
+		return RecordingStatus{}, errNoRecorder
+	}
+
+	if req.Format == "" {
+		req.Format = string(recorder.FormatOgg)
+	}
+
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return RecordingStatus{}, errRecordingTime
+		}
+	}
+
+	rs := &cs.recording
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.rec != nil || !rs.scheduled.IsZero() {
+		return rs.statusLocked(), errRecordingBusy
+	}
+
+	rs.req = req
+
+	if req.At != nil && time.Until(*req.At) > 0 {
+		rs.scheduled = *req.At
+		rs.timer = time.AfterFunc(time.Until(*req.At), cs.beginScheduledRecording)
+
+		log.Printf("[rtc] session %s recording scheduled for %s", cs.id, rs.scheduled.Format(time.RFC3339))
+
+		return rs.statusLocked(), nil
+	}
+
+	err := cs.beginRecordingLocked()
+
+	return rs.statusLocked(), err
+}
+
+func (cs *clientSession) beginScheduledRecording() {
+	rs := &cs.recording
+
+	rs.mu.Lock()
+	if rs.scheduled.IsZero() {
+		rs.mu.Unlock()
+
+		return
+	}
+
+	rs.scheduled = time.Time{}
+
+	err := cs.beginRecordingLocked()
+	if err != nil {
+		rs.last.Error = err.Error()
+	}
+
+	status := rs.statusLocked()
+	rs.mu.Unlock()
+
+	cs.trySend(mustEncode(typeRecording, status))
+}
+
+// beginRecordingLocked opens the file and starts feeding it.
+func (cs *clientSession) beginRecordingLocked() error {
+	rs := &cs.recording
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return errRecordingRadio
+	}
+
+	rec, err := cs.srv.recorder.Start(rc.info.meta(rs.req.Slice), recorder.Format(rs.req.Format), rs.req.Squelch)
+	if err != nil {
+		return fmt.Errorf("start recording: %w", err)
+	}
+
+	feed := rc.listeners.subscribe()
+	if feed == nil {
+		_ = rec.Close()
+
+		return errRecordingRadio
+	}
+
+	rs.rec, rs.rc, rs.feed, rs.started = rec, rc, feed, time.Now()
+	rs.first = time.Time{}
+	rs.done = make(chan error, 1)
+	rs.last = RecordingStatus{}
+
+	if rs.req.Duration != "" {
+		d, _ := time.ParseDuration(rs.req.Duration)
+		rs.timer = time.AfterFunc(d, func() {
+			cs.trySend(mustEncode(typeRecording, cs.stopRecording()))
+		})
+	}
+
+	go cs.feedRecording(rc, rec, feed, rs.done)
+
+	log.Printf("[rtc] session %s recording to %s", cs.id, rec.Key())
+	cs.srv.publishStream(true, StreamEvent{Session: cs.id, Kind: StreamRecording, ID: rec.Key()})
+
+	return nil
+}
+
+// feedRecording writes audio until the feed closes, on stop or when the radio
+// link goes away, then finishes the file. The first packet is timed by the
+// latest the radio link saw, which it is but for a backlog on the feed.
+func (cs *clientSession) feedRecording(rc *radioConn, rec *recorder.Recording, feed chan []byte, done chan error) {
+	first := true
+
+	for pkt := range feed {
+		if first {
+			first = false
+
+			cs.recording.mu.Lock()
+			if at := rc.lastAudio.Load(); at != 0 && cs.recording.rec == rec {
+				cs.recording.first = time.Unix(0, at).UTC()
+			}
+			cs.recording.mu.Unlock()
+		}
+
+		rec.WriteOpus(pkt, opusDuration(pkt))
+	}
+
+	err := rec.Close()
+	if err != nil {
+		log.Printf("[rtc] session %s recording: %v", cs.id, err)
+	}
+
+	done <- err
+
+	rs := &cs.recording
+
+	rs.mu.Lock()
+
+	// Unless stopRecording is waiting for us, the radio link closed
+	// underneath the recording.
+	if rs.rec != rec {
+		rs.mu.Unlock()
+
+		return
+	}
+
+	rs.finishLocked(rec, err)
+	status := rs.statusLocked()
+	rs.mu.Unlock()
+
+	cs.srv.publishStream(false, StreamEvent{Session: cs.id, Kind: StreamRecording, ID: rec.Key()})
+	cs.trySend(mustEncode(typeRecording, status))
+}
+
+// stopRecording ends the running recording, waiting for its file to be
+// committed, or cancels a scheduled one.
+func (cs *clientSession) stopRecording() RecordingStatus {
+	rs := &cs.recording
+
+	rs.mu.Lock()
+
+	if rs.timer != nil {
+		rs.timer.Stop()
+		rs.timer = nil
+	}
+
+	rs.scheduled = time.Time{}
+
+	rec, rc, feed, done := rs.rec, rs.rc, rs.feed, rs.done
+	if rec == nil {
+		status := rs.statusLocked()
+		rs.mu.Unlock()
+
+		return status
+	}
+
+	rs.rec = nil
+	rs.mu.Unlock()
+
+	rc.listeners.unsubscribe(feed)
+	err := <-done
+
+	log.Printf("[rtc] session %s recording stopped: %s (%s)", cs.id, rec.Key(), rec.Recorded().Round(time.Second))
+	cs.srv.publishStream(false, StreamEvent{Session: cs.id, Kind: StreamRecording, ID: rec.Key()})
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.finishLocked(rec, err)
+
+	return rs.statusLocked()
+}
+
+// finishLocked records the outcome of rec and clears the running state.
+func (rs *recordingState) finishLocked(rec *recorder.Recording, err error) {
+	started := rs.started
+	rs.last = RecordingStatus{
+		Key:         rec.Key(),
+		Format:      rs.req.Format,
+		Squelch:     rs.req.Squelch,
+		Started:     &started,
+		FirstSample: rs.firstSample(),
+		Recorded:    rec.Recorded().Seconds(),
+	}
+
+	if err != nil {
+		rs.last.Error = err.Error()
+	}
+
+	if rs.timer != nil {
+		rs.timer.Stop()
+		rs.timer = nil
+	}
+
+	rs.rec, rs.rc, rs.feed = nil, nil, nil
+}
+
+func (cs *clientSession) recordingStatus() RecordingStatus {
+	cs.recording.mu.Lock()
+	defer cs.recording.mu.Unlock()
+
+	return cs.recording.statusLocked()
+}
+
+func (rs *recordingState) statusLocked() RecordingStatus {
+	switch {
+	case rs.rec != nil:
+		started := rs.started
+
+		return RecordingStatus{
+			Active:      true,
+			Key:         rs.rec.Key(),
+			Format:      rs.req.Format,
+			Squelch:     rs.req.Squelch,
+			Started:     &started,
+			FirstSample: rs.firstSample(),
+			Recorded:    rs.rec.Recorded().Seconds(),
+		}
+	case !rs.scheduled.IsZero():
+		at := rs.scheduled
+
+		return RecordingStatus{Scheduled: &at, Format: rs.req.Format, Squelch: rs.req.Squelch}
+	default:
+		return rs.last
+	}
+}
+
+// firstSample is when the first packet written was taken, nil before one
+// has been.
+func (rs *recordingState) firstSample() *time.Time {
+	if rs.first.IsZero() {
+		return nil
+	}
+
+	first := rs.first
+
+	return &first
+}
+
+func (cs *clientSession) handleRecording(raw json.RawMessage) {
+	var req RecordingRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	var status RecordingStatus
+
+	switch req.Action {
+	case "start":
+		status, err = cs.startRecording(req)
+	case "stop":
+		status = cs.stopRecording()
+	case "":
+		status = cs.recordingStatus()
+	default:
+		err = fmt.Errorf("%w %q", errRecordingVerb, req.Action)
+	}
+
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "RECORDING_FAILED", Message: err.Error()}))
+
+		return
+	}
+
+	cs.trySend(mustEncode(typeRecording, status))
+}
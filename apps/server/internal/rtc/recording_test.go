@@ -0,0 +1,105 @@
+package rtc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/recorder"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/storage"
+)
+
+func TestRadioInfo(t *testing.T) {
+	t.Parallel()
+
+	var ri radioInfo
+
+	ri.observe("radio slices=4 callsign=N0CALL nickname=shack")
+	ri.observe("slice 1 in_use=1 RF_frequency=7.074000 index_letter=B")
+	ri.observe("slice 1 mode=USB")
+
+	slice := 1
+	if m := ri.meta(&slice); m.Callsign != "N0CALL" || m.Slice != "B" || m.FreqMHz != 7.074 {
+		t.Errorf("meta = %+v", m)
+	}
+
+	ri.observe("slice 1 in_use=0")
+
+	if m := ri.meta(&slice); m.Slice != "1" || m.FreqMHz != 0 {
+		t.Errorf("meta after slice removed = %+v", m)
+	}
+}
+
+func TestRecording_StartStop(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s := &Server{sessions: map[string]*clientSession{}, recorder: recorder.New(storage.NewLocal(dir), recorder.Options{})}
+	rc := &radioConn{}
+	cs := &clientSession{id: "op", srv: s, radio: rc, send: make(chan message, 16)}
+	s.addSession(cs)
+
+	rc.info.observe("radio callsign=N0CALL")
+	rc.info.observe("slice 0 in_use=1 RF_frequency=14.074000 index_letter=A")
+
+	slice := 0
+
+	status, err := s.StartRecording("op", RecordingRequest{Action: "start", Slice: &slice})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !status.Active || !strings.Contains(status.Key, "N0CALL_14.074000_A_") {
+		t.Fatalf("status = %+v", status)
+	}
+
+	_, err = s.StartRecording("op", RecordingRequest{Action: "start"})
+	if !errors.Is(err, errRecordingBusy) {
+		t.Errorf("second start: %v, want busy", err)
+	}
+
+	for range 50 {
+		rc.tapAudio(append([]byte{0x80}, make([]byte, 40)...))
+	}
+
+	status, err = s.StopRecording("op")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Active || status.Recorded <= 0 {
+		t.Errorf("after stop: %+v", status)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, filepath.FromSlash(status.Key)))
+	if err != nil {
+		t.Errorf("recording not committed: %v", err)
+	}
+
+	_, err = s.StartRecording("nope", RecordingRequest{})
+	if !errors.Is(err, ErrNoSession) {
+		t.Errorf("unknown session: %v", err)
+	}
+}
+
+func TestRecording_EndsWithRadio(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}, recorder: recorder.New(storage.NewLocal(t.TempDir()), recorder.Options{})}
+	rc := &radioConn{}
+	cs := &clientSession{id: "op", srv: s, radio: rc, send: make(chan message, 16)}
+
+	_, err := cs.startRecording(RecordingRequest{Action: "start"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc.listeners.close()
+
+	msg := <-cs.send
+	if msg.Type != typeRecording || !strings.Contains(string(msg.Payload), `"active":false`) {
+		t.Errorf("got %s %s, want an inactive recording status", msg.Type, msg.Payload)
+	}
+}
@@ -0,0 +1,542 @@
+package rtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// DefaultResumeWindow is how long a restarted bridge holds a saved session's
+// radio connection for its client to resume.
+const DefaultResumeWindow = 2 * time.Minute
+
+// sessionSaveInterval is how often the session state file is rewritten, so a
+// crash loses little.
+const sessionSaveInterval = 15 * time.Second
+
+var (
+	errResumeToken = errors.New("no saved session with that token")
+	errResumeRadio = errors.New("session already has a radio")
+)
+
+// savedSession is what the state file keeps of a session: enough to dial its
+// radio again and hand its client back what it had.
+type savedSession struct {
+	Token   string `json:"token"`
+	Radio   string `json:"radio"`
+	Latency string `json:"latency,omitempty"`
+	Sandbox bool   `json:"sandbox,omitempty"`
+	// Subscriptions are the signaling subscriptions in force, e.g. "gps".
+	Subscriptions []string `json:"subscriptions,omitempty"`
+	// Prefs are the client's own, as it last sent them.
+	Prefs  json.RawMessage `json:"prefs,omitempty"`
+	Slices []savedSlice    `json:"slices,omitempty"`
+	// Handle and Streams are the radio's client handle and the streams it
+	// reported for it, for a restart to clear away should this run end
+	// without letting go.
+	Handle  string    `json:"handle,omitempty"`
+	Streams []string  `json:"streams,omitempty"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// savedSlice is where a slice was tuned.
+type savedSlice struct {
+	Letter  string  `json:"letter"`
+	FreqMHz float64 `json:"freqMHz"`
+	Mode    string  `json:"mode"`
+}
+
+// sessionPayload tells a client, once its radio is connected, the key of
+// that connection and, with a state file, the token that resumes its session
+// after a restart.
+type sessionPayload struct {
+	ID          string `json:"id"`
+	ResumeToken string `json:"resumeToken,omitempty"`
+	// Radio is the radioKey of the session's radio connection, which the
+	// client names in the offers it makes after connecting.
+	Radio string `json:"radio,omitempty"`
+}
+
+// sessionStatePayload is a client's "sessionState" message: preferences kept
+// with the session, handed back when it resumes.
+type sessionStatePayload struct {
+	Prefs json.RawMessage `json:"prefs"`
+}
+
+// resumeRequest is a client's "resume" message.
+type resumeRequest struct {
+	Token string `json:"token"`
+}
+
+// resumePayload answers a resume with the session's saved state. Warm is set
+// when the bridge has its radio connected already, for the client's "tcp"
+// channel to take over.
+type resumePayload struct {
+	savedSession
+
+	Warm bool `json:"warm"`
+}
+
+// restoredSession is a saved session waiting for its client.
+type restoredSession struct {
+	saved savedSession
+	warm  *warmRadio // nil until dialed, or if dialing failed
+	timer *time.Timer
+}
+
+// warmRadio is a radio connection dialed for a restored session before its
+// client is back. Lines from the radio are held, starting with its version
+// and handle, and replayed to the "tcp" channel that adopts it.
+type warmRadio struct {
+	rc *radioConn
+
+	mu    sync.Mutex
+	lines []string
+	// stale is set once lines were lost or a write failed, so the
+	// connection is no use to a client.
+	stale  bool
+	target textSender
+	onFail func(error)
+}
+
+// SendText holds a line until the connection is adopted, then passes it on.
+func (w *warmRadio) SendText(line string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.target != nil {
+		return w.target.SendText(line)
+	}
+
+	if len(w.lines) >= headlessBuffer {
+		w.stale = true
+
+		return errHeadlessBehind
+	}
+
+	w.lines = append(w.lines, line)
+
+	return nil
+}
+
+func (w *warmRadio) writeFailed(err error) {
+	w.mu.Lock()
+	onFail := w.onFail
+	w.stale = w.stale || onFail == nil
+	w.mu.Unlock()
+
+	if onFail != nil {
+		onFail(err)
+	} else {
+		log.Printf("[rtc] resume: %v", err)
+	}
+}
+
+// adopt replays the held lines to dc and hands it the connection. It reports
+// false if the connection went stale while it waited.
+func (w *warmRadio) adopt(dc textSender, onFail func(error)) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stale {
+		return false
+	}
+
+	for _, line := range w.lines {
+		_ = dc.SendText(line)
+	}
+
+	w.lines, w.target, w.onFail = nil, dc, onFail
+
+	w.rc.mu.Lock()
+	w.rc.tcpDC = dc
+	w.rc.mu.Unlock()
+
+	return true
+}
+
+// SaveSessions writes every browser session with a radio to the state file.
+func (s *Server) SaveSessions() error {
+	if s.stateFile == "" {
+		return nil
+	}
+
+	saved := []savedSession{}
+
+	for _, cs := range s.sessionList() {
+		if st, ok := cs.saveState(); ok {
+			saved = append(saved, st)
+		}
+	}
+
+	b, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("session state: %w", err)
+	}
+
+	// Write and rename, so a crash mid-write leaves the last good file.
+	tmp, err := os.CreateTemp(filepath.Dir(s.stateFile), ".sessions-*")
+	if err != nil {
+		return fmt.Errorf("session state: %w", err)
+	}
+
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.stateFile)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("session state: %w", err)
+	}
+
+	return nil
+}
+
+// PersistSessions saves the sessions every so often until ctx is done. Call
+// SaveSessions once more before draining on shutdown.
+func (s *Server) PersistSessions(ctx context.Context) {
+	if s.stateFile == "" {
+		return
+	}
+
+	t := time.NewTicker(sessionSaveInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			err := s.SaveSessions()
+			if err != nil {
+				log.Printf("[rtc] %v", err)
+			}
+		}
+	}
+}
+
+// RestoreSessions reads the state file a previous run saved and dials each
+// session's radio again, holding the connection for the resume window.
+func (s *Server) RestoreSessions() error {
+	if s.stateFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(s.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("session state: %w", err)
+	}
+
+	var saved []savedSession
+
+	err = json.Unmarshal(b, &saved)
+	if err != nil {
+		return fmt.Errorf("session state: %w", err)
+	}
+
+	held := 0
+
+	for _, st := range saved {
+		if !s.radioAllowed(st.Radio) {
+			continue
+		}
+
+		left := s.resumeWindow - time.Since(st.SavedAt)
+		if st.Token == "" || left <= 0 {
+			if s.staleCleanup && st.Handle != "" {
+				go s.sweepSaved(st)
+			}
+
+			continue
+		}
+
+		r := &restoredSession{saved: st}
+
+		s.resumeMu.Lock()
+		s.restored[st.Token] = r
+		r.timer = time.AfterFunc(left, func() { s.expireRestored(st.Token) })
+		s.resumeMu.Unlock()
+
+		held++
+
+		go s.warmUp(r)
+	}
+
+	if held > 0 {
+		log.Printf("[rtc] holding %d saved session(s) for %s", held, s.resumeWindow)
+	}
+
+	return nil
+}
+
+// warmUp dials a restored session's radio.
+func (s *Server) warmUp(r *restoredSession) {
+	w := &warmRadio{}
+
+	rc, err := newRadioConn(context.Background(), w, r.saved.Radio, s.capture, nil, nil, w.writeFailed)
+	if err != nil {
+		log.Printf("[rtc] resume: %v", err)
+
+		return
+	}
+
+	if s.staleCleanup {
+		rc.sweepStale(r.saved)
+	}
+
+	w.rc = rc
+
+	s.resumeMu.Lock()
+	_, waiting := s.restored[r.saved.Token]
+	if waiting {
+		r.warm = w
+	}
+	s.resumeMu.Unlock()
+
+	// Claimed or expired while dialing.
+	if !waiting {
+		rc.disconnect()
+	}
+}
+
+// expireRestored lets a restored session's radio go once the window passes.
+func (s *Server) expireRestored(token string) {
+	s.resumeMu.Lock()
+	r := s.restored[token]
+	delete(s.restored, token)
+	s.resumeMu.Unlock()
+
+	if r != nil && r.warm != nil {
+		log.Printf("[rtc] saved session on %s was not resumed", r.saved.Radio)
+		r.warm.rc.disconnect()
+	}
+}
+
+// claimRestored takes the restored session with token, if it is waiting.
+func (s *Server) claimRestored(token string) (*restoredSession, bool) {
+	s.resumeMu.Lock()
+	defer s.resumeMu.Unlock()
+
+	r, ok := s.restored[token]
+	if ok {
+		delete(s.restored, token)
+		r.timer.Stop()
+	}
+
+	return r, ok
+}
+
+// saveState is what the state file keeps of the session, if it is a browser
+// session with a radio.
+func (cs *clientSession) saveState() (savedSession, bool) {
+	cs.mu.Lock()
+	rc := cs.radio
+	st := savedSession{
+		Token:   cs.resumeToken,
+		Latency: cs.latencyProfile,
+		Prefs:   cs.prefs,
+		SavedAt: time.Now(),
+	}
+	cs.mu.Unlock()
+
+	if rc == nil || cs.ws == nil {
+		return savedSession{}, false
+	}
+
+	st.Radio = rc.addr
+	st.Sandbox = cs.sandbox.isEnabled()
+	st.Slices = rc.info.savedSlices()
+	if rc.handleHex != "" {
+		st.Handle = "0x" + rc.handleHex
+	}
+
+	for _, id := range rc.ownedStreams() {
+		st.Streams = append(st.Streams, fmt.Sprintf("0x%08X", id))
+	}
+
+	for name, on := range cs.subscriptions() {
+		if on.Load() {
+			st.Subscriptions = append(st.Subscriptions, name)
+		}
+	}
+
+	slices.Sort(st.Subscriptions)
+
+	return st, true
+}
+
+// subscriptions are the session's signaling subscriptions a resume restores.
+func (cs *clientSession) subscriptions() map[string]*atomic.Bool {
+	return map[string]*atomic.Bool{
+		typeWSJTX:   &cs.wsjtx,
+		typeAntenna: &cs.antennas,
+		typeGPS:     &cs.gps,
+		typeLogbook: &cs.logbook,
+		typeSpots:   &cs.spots,
+	}
+}
+
+// savedSlices are the radio's slices, in slice order.
+func (ri *radioInfo) savedSlices() []savedSlice {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	idx := make([]int, 0, len(ri.slices))
+	for i := range ri.slices {
+		idx = append(idx, i)
+	}
+
+	slices.Sort(idx)
+
+	out := make([]savedSlice, 0, len(idx))
+	for _, i := range idx {
+		s := ri.slices[i]
+		out = append(out, savedSlice{Letter: s.letter, FreqMHz: s.freqMHz, Mode: s.mode})
+	}
+
+	return out
+}
+
+func (cs *clientSession) handleSessionState(raw json.RawMessage) {
+	var p sessionStatePayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	cs.mu.Lock()
+	cs.prefs = p.Prefs
+	cs.mu.Unlock()
+}
+
+func (cs *clientSession) handleResume(raw json.RawMessage) {
+	var req resumeRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	cs.mu.Lock()
+	busy := cs.radio != nil || cs.warm != nil
+	cs.mu.Unlock()
+
+	if busy {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "RESUME_FAILED", Message: errResumeRadio.Error()}))
+
+		return
+	}
+
+	r, ok := cs.srv.claimRestored(req.Token)
+	if !ok {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "RESUME_FAILED", Message: errResumeToken.Error()}))
+
+		return
+	}
+
+	st := r.saved
+
+	cs.mu.Lock()
+	cs.resumeToken = st.Token
+	cs.prefs = st.Prefs
+	cs.warm = r.warm
+	cs.mu.Unlock()
+
+	_ = cs.setLatency(st.Latency)
+
+	if st.Sandbox {
+		cs.setSandbox(true)
+	}
+
+	subs := cs.subscriptions()
+	for _, name := range st.Subscriptions {
+		if on, ok := subs[name]; ok {
+			on.Store(true)
+		}
+	}
+
+	log.Printf("[rtc] session %s resumed a session on %s", cs.id, st.Radio)
+
+	cs.trySend(mustEncode(typeResume, resumePayload{savedSession: st, Warm: r.warm != nil}))
+}
+
+// takeWarm hands over the radio connection a resume left waiting, if it is
+// to addr; one to another radio is let go.
+func (cs *clientSession) takeWarm(addr string) *warmRadio {
+	cs.mu.Lock()
+	w := cs.warm
+	cs.warm = nil
+	cs.mu.Unlock()
+
+	if w == nil {
+		return nil
+	}
+
+	if w.rc.addr != addr {
+		w.rc.disconnect()
+
+		return nil
+	}
+
+	return w
+}
+
+// dropWarm lets go of a radio connection the client resumed but never took.
+func (cs *clientSession) dropWarm() {
+	cs.mu.Lock()
+	w := cs.warm
+	cs.warm = nil
+	cs.mu.Unlock()
+
+	if w != nil {
+		w.rc.disconnect()
+	}
+}
+
+// connectRadio dials the radio for the "tcp" channel dc, or adopts the
+// connection a resume left waiting for it.
+func (cs *clientSession) connectRadio(ctx context.Context, dc *webrtc.DataChannel) (*radioConn, error) {
+	onFail := func(err error) { cs.radioWriteFailed(dc, err) }
+	lines := newLineBatcher(dc, cs.srv.lineBatch)
+
+	if w := cs.takeWarm(dc.Label()); w != nil {
+		if w.adopt(lines, onFail) {
+			w.rc.mu.Lock()
+			w.rc.onNetworkDiagnostics = cs.reportServerToRadioDiagnostics
+			w.rc.onTXEvent = cs.reportTXEvent
+			w.rc.mu.Unlock()
+
+			return w.rc, nil
+		}
+
+		log.Printf("[rtc] resume %s: the held connection went stale; dialing again", w.rc.key)
+		w.rc.disconnect()
+	}
+
+	return newRadioConn(ctx, lines, dc.Label(), cs.srv.capture, cs.reportServerToRadioDiagnostics, cs.reportTXEvent, onFail)
+}
@@ -0,0 +1,163 @@
+package rtc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc/rtctest"
+	"github.com/gorilla/websocket"
+)
+
+// lineSink collects the lines a radio connection sends its client.
+type lineSink chan string
+
+func (l lineSink) SendText(s string) error {
+	l <- s
+
+	return nil
+}
+
+func nextNotice(t *testing.T, cs *clientSession) message {
+	t.Helper()
+
+	select {
+	case m := <-cs.send:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+
+		return message{}
+	}
+}
+
+func TestSessions_SaveAndResume(t *testing.T) {
+	t.Parallel()
+
+	addr, got := rtctest.FakeRadio(t)
+	file := filepath.Join(t.TempDir(), "sessions.json")
+
+	// A session saved by the previous run.
+	old := &Server{sessions: make(map[string]*clientSession), stateFile: file}
+	prev := newClientSession(old, &websocket.Conn{}, func() {}, "10.0.0.5", "10.0.0.5")
+	prev.radio = &radioConn{addr: addr}
+	prev.radio.info.observe("slice 0 RF_frequency=14.074000 mode=DIGU index_letter=A")
+	prev.latencyProfile = latencyLow
+	prev.prefs = json.RawMessage(`{"theme":"dark"}`)
+	prev.gps.Store(true)
+	prev.spots.Store(true)
+	old.addSession(prev)
+
+	err := old.SaveSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{
+		sessions: make(map[string]*clientSession), restored: make(map[string]*restoredSession),
+		stateFile: file, resumeWindow: time.Minute,
+	}
+
+	err = srv.RestoreSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The radio is dialed before the client is back.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		srv.resumeMu.Lock()
+		r := srv.restored[prev.resumeToken]
+		warm := r != nil && r.warm != nil
+		srv.resumeMu.Unlock()
+
+		if warm {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("radio not dialed")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cs := newClientSession(srv, &websocket.Conn{}, func() {}, "10.0.0.5", "10.0.0.5")
+	cs.handleResume(json.RawMessage(`{"token":"` + prev.resumeToken + `"}`))
+
+	msg := nextNotice(t, cs)
+	if msg.Type != typeResume {
+		t.Fatalf("got %s: %s", msg.Type, msg.Payload)
+	}
+
+	var p resumePayload
+
+	_ = json.Unmarshal(msg.Payload, &p)
+
+	if !p.Warm || p.Radio != addr || p.Latency != latencyLow || string(p.Prefs) != `{"theme":"dark"}` ||
+		len(p.Slices) != 1 || p.Slices[0] != (savedSlice{Letter: "A", FreqMHz: 14.074, Mode: "DIGU"}) {
+		t.Errorf("resume = %+v", p)
+	}
+
+	if !cs.gps.Load() || !cs.spots.Load() || cs.logbook.Load() {
+		t.Error("subscriptions not restored")
+	}
+
+	// A token resumes once.
+	other := newClientSession(srv, &websocket.Conn{}, func() {}, "10.0.0.6", "10.0.0.6")
+	other.handleResume(json.RawMessage(`{"token":"` + prev.resumeToken + `"}`))
+
+	if msg := nextNotice(t, other); msg.Type != typeError {
+		t.Errorf("second resume got %s", msg.Type)
+	}
+
+	// The "tcp" channel takes over the connection, starting with the
+	// radio's greeting.
+	w := cs.takeWarm(addr)
+	if w == nil {
+		t.Fatal("no warm connection for the radio")
+	}
+
+	lines := make(lineSink, 16)
+	if !w.adopt(lines, nil) {
+		t.Fatal("adopt failed")
+	}
+
+	for _, want := range []string{"V1.4.0.0", "H1234ABCD"} {
+		if l := nextLine(t, lines); l != want {
+			t.Errorf("line = %q, want %q", l, want)
+		}
+	}
+
+	w.rc.disconnect()
+
+	if l := nextLine(t, got); l != "C2147483645|client disconnect 0x1234ABCD" {
+		t.Errorf("radio got %q", l)
+	}
+}
+
+func TestRestoreSessions_Expired(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "sessions.json")
+	saved := []savedSession{{Token: "t", Radio: "192.0.2.1:4992", SavedAt: time.Now().Add(-time.Hour)}}
+
+	b, _ := json.Marshal(saved)
+
+	err := os.WriteFile(file, b, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{restored: make(map[string]*restoredSession), stateFile: file, resumeWindow: time.Minute}
+
+	err = srv.RestoreSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(srv.restored) != 0 {
+		t.Error("an hour-old session was restored")
+	}
+}
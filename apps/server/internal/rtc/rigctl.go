@@ -0,0 +1,487 @@
+package rtc
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rigctl"
+)
+
+// internalRigctlSequence tags commands sent for rigctl clients so their
+// replies are consumed instead of reaching the browser.
+const internalRigctlSequence = 2147483643
+
+// rigctlReplyTimeout is how long a rigctl command waits for the radio.
+const rigctlReplyTimeout = 2 * time.Second
+
+// hamlibModes maps the radio's modes onto Hamlib's names; modes not listed
+// are the same in both.
+var hamlibModes = map[string]string{
+	"DIGU": "PKTUSB",
+	"DIGL": "PKTLSB",
+	"DFM":  "PKTFM",
+	"NFM":  "FM",
+}
+
+// flexModes maps Hamlib's modes onto the radio's.
+var flexModes = map[string]string{
+	"USB": "USB", "LSB": "LSB", "CW": "CW", "CWR": "CW",
+	"AM": "AM", "SAM": "SAM", "AMS": "SAM", "DSB": "DSB",
+	"FM": "FM", "WFM": "FM",
+	"PKTUSB": "DIGU", "PKTLSB": "DIGL", "PKTFM": "DFM",
+	"RTTY": "RTTY", "RTTYR": "RTTY",
+}
+
+// Rig returns the radio a rigctl client at clientIP controls: the session
+// opened from the same address, or else the only session with a radio. The
+// session is looked up again for every command, so a client that reconnects
+// its browser keeps working.
+func (s *Server) Rig(clientIP string) rigctl.Rig {
+	return &rigAdapter{srv: s, ip: clientIP}
+}
+
+// ActiveRig is Rig with VFOA following the radio's active slice and VFOB the
+// first other slice, for CAT clients that expect a single-receiver radio.
+func (s *Server) ActiveRig(clientIP string) rigctl.Rig {
+	return &rigAdapter{srv: s, ip: clientIP, active: true}
+}
+
+func rigSlices(letters []string) []string {
+	if len(letters) == 0 {
+		return []string{"A", "B"}
+	}
+
+	out := make([]string, len(letters))
+	for i, l := range letters {
+		out[i] = strings.ToUpper(strings.TrimSpace(l))
+	}
+
+	return out
+}
+
+type rigAdapter struct {
+	srv    *Server
+	ip     string
+	active bool // VFOA is the active slice rather than a fixed letter
+}
+
+func (r *rigAdapter) target() (*clientSession, *radioConn, error) {
+	var own, all []*clientSession
+
+	for _, cs := range r.srv.sessionList() {
+		cs.mu.Lock()
+		rc := cs.radio
+		cs.mu.Unlock()
+
+		if rc == nil {
+			continue
+		}
+
+		all = append(all, cs)
+		if cs.clientIP == r.ip {
+			own = append(own, cs)
+		}
+	}
+
+	pick := own
+	if len(pick) == 0 {
+		pick = all
+	}
+
+	if len(pick) != 1 {
+		return nil, nil, rigctl.ErrIO
+	}
+
+	cs := pick[0]
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return nil, nil, rigctl.ErrIO
+	}
+
+	return cs, rc, nil
+}
+
+// slice finds the slice behind vfo.
+func (r *rigAdapter) slice(rc *radioConn, vfo string) (int, sliceInfo, error) {
+	i := 0
+	if vfo == rigctl.VFOB {
+		i = 1
+	}
+
+	if r.active {
+		return rc.activeSlice(i)
+	}
+
+	if i >= len(r.srv.rigSlices) {
+		return 0, sliceInfo{}, rigctl.ErrVFO
+	}
+
+	letter := r.srv.rigSlices[i]
+
+	rc.info.mu.Lock()
+	defer rc.info.mu.Unlock()
+
+	for idx, s := range rc.info.slices {
+		if s.letter == letter {
+			return idx, s, nil
+		}
+	}
+
+	return 0, sliceInfo{}, rigctl.ErrVFO
+}
+
+// activeSlice returns the active slice for i 0, or the first other slice for
+// i 1. A radio that reports no active slice has its first slice used.
+func (rc *radioConn) activeSlice(i int) (int, sliceInfo, error) {
+	rc.info.mu.Lock()
+	defer rc.info.mu.Unlock()
+
+	idxs := slices.Sorted(maps.Keys(rc.info.slices))
+	if len(idxs) == 0 {
+		return 0, sliceInfo{}, rigctl.ErrVFO
+	}
+
+	active := idxs[0]
+	for _, idx := range idxs {
+		if rc.info.slices[idx].active {
+			active = idx
+
+			break
+		}
+	}
+
+	if i == 0 {
+		return active, rc.info.slices[active], nil
+	}
+
+	for _, idx := range idxs {
+		if idx != active {
+			return idx, rc.info.slices[idx], nil
+		}
+	}
+
+	return 0, sliceInfo{}, rigctl.ErrVFO
+}
+
+// updateSlice applies a change the radio accepted to the cached slice, so a
+// read straight after a write sees it before the status arrives.
+func (rc *radioConn) updateSlice(idx int, fn func(*sliceInfo)) {
+	rc.info.mu.Lock()
+	defer rc.info.mu.Unlock()
+
+	if s, ok := rc.info.slices[idx]; ok {
+		fn(&s)
+		rc.info.slices[idx] = s
+	}
+}
+
+func (r *rigAdapter) Freq(vfo string) (float64, error) {
+	_, rc, err := r.target()
+	if err != nil {
+		return 0, err
+	}
+
+	_, s, err := r.slice(rc, vfo)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.freqMHz * 1e6, nil
+}
+
+func (r *rigAdapter) SetFreq(vfo string, hz float64) error {
+	cs, rc, err := r.target()
+	if err != nil {
+		return err
+	}
+
+	idx, _, err := r.slice(rc, vfo)
+	if err != nil {
+		return err
+	}
+
+	mhz := hz / 1e6
+
+	err = r.command(cs, rc, fmt.Sprintf("slice tune %d %.6f", idx, mhz))
+	if err != nil {
+		return err
+	}
+
+	rc.updateSlice(idx, func(s *sliceInfo) { s.freqMHz = mhz })
+
+	return nil
+}
+
+func (r *rigAdapter) Mode(vfo string) (string, int, error) {
+	_, rc, err := r.target()
+	if err != nil {
+		return "", 0, err
+	}
+
+	_, s, err := r.slice(rc, vfo)
+	if err != nil {
+		return "", 0, err
+	}
+
+	mode := s.mode
+	if m, ok := hamlibModes[mode]; ok {
+		mode = m
+	}
+
+	return mode, s.filterHi - s.filterLo, nil
+}
+
+func (r *rigAdapter) SetMode(vfo, mode string, passband int) error {
+	flex, ok := flexModes[mode]
+	if !ok {
+		return rigctl.ErrInvalid
+	}
+
+	cs, rc, err := r.target()
+	if err != nil {
+		return err
+	}
+
+	idx, s, err := r.slice(rc, vfo)
+	if err != nil {
+		return err
+	}
+
+	if flex != s.mode {
+		err = r.command(cs, rc, fmt.Sprintf("slice set %d mode=%s", idx, flex))
+		if err != nil {
+			return err
+		}
+
+		rc.updateSlice(idx, func(s *sliceInfo) { s.mode = flex })
+	}
+
+	// 0 asks for the mode's normal width and -1 for no change; either way
+	// the radio's own filter for the mode stands.
+	if passband <= 0 {
+		return nil
+	}
+
+	lo, hi := filterFor(flex, s.filterLo, s.filterHi, passband)
+
+	err = r.command(cs, rc, fmt.Sprintf("filt %d %d %d", idx, lo, hi))
+	if err != nil {
+		return err
+	}
+
+	rc.updateSlice(idx, func(s *sliceInfo) { s.filterLo, s.filterHi = lo, hi })
+
+	return nil
+}
+
+// filterFor resizes a filter to width Hz. Upper-sideband modes keep the low
+// edge, lower-sideband modes the high edge and everything else the center.
+func filterFor(mode string, lo, hi, width int) (int, int) {
+	switch mode {
+	case "USB", "DIGU":
+		return lo, lo + width
+	case "LSB", "DIGL":
+		return hi - width, hi
+	default:
+		center := (lo + hi) / 2
+
+		return center - width/2, center + width - width/2
+	}
+}
+
+func (r *rigAdapter) PTT() (bool, error) {
+	_, rc, err := r.target()
+	if err != nil {
+		return false, err
+	}
+
+	rc.mu.RLock()
+	state := rc.interlockState
+	rc.mu.RUnlock()
+
+	return state == "TRANSMITTING" || state == "PTT_REQUESTED", nil
+}
+
+func (r *rigAdapter) SetPTT(on bool) error {
+	cs, rc, err := r.target()
+	if err != nil {
+		return err
+	}
+
+	return r.command(cs, rc, "xmit "+boolFlag(on))
+}
+
+func (r *rigAdapter) Split() (bool, string, error) {
+	_, rc, err := r.target()
+	if err != nil {
+		return false, "", err
+	}
+
+	_, a, err := r.slice(rc, rigctl.VFOA)
+	if err != nil {
+		return false, "", err
+	}
+
+	if a.tx {
+		return false, rigctl.VFOA, nil
+	}
+
+	_, b, err := r.slice(rc, rigctl.VFOB)
+	if err == nil && b.tx {
+		return true, rigctl.VFOB, nil
+	}
+
+	return false, rigctl.VFOA, nil
+}
+
+// SetSplit moves transmit to txVFO's slice, or back to VFOA's.
+func (r *rigAdapter) SetSplit(on bool, txVFO string) error {
+	cs, rc, err := r.target()
+	if err != nil {
+		return err
+	}
+
+	vfo := rigctl.VFOA
+	if on {
+		vfo = txVFO
+	}
+
+	idx, s, err := r.slice(rc, vfo)
+	if err != nil {
+		return err
+	}
+
+	if s.tx {
+		return nil
+	}
+
+	err = r.command(cs, rc, fmt.Sprintf("slice set %d tx=1", idx))
+	if err != nil {
+		return err
+	}
+
+	rc.info.mu.Lock()
+	for i, s := range rc.info.slices {
+		s.tx = i == idx
+		rc.info.slices[i] = s
+	}
+	rc.info.mu.Unlock()
+
+	return nil
+}
+
+// command sends body to the radio for the rigctl client and waits for the
+// reply. It goes through the session's sandbox and the guard like a command
+// typed in the browser; anything needing confirmation is refused, since a
+// rigctl client has no way to ask for it.
+func (r *rigAdapter) command(cs *clientSession, rc *radioConn, body string) error {
+	line := fmt.Sprintf("C%d|%s\n", internalRigctlSequence, body)
+
+	pass, simulated := cs.sandbox.filter([]byte(line), rc.handleHex)
+	for _, l := range simulated {
+		if st, ok := strings.CutPrefix(l, "S"+rc.handleHex+"|"); ok {
+			rc.info.observe(strings.TrimSpace(st))
+			rc.sendTCPLine(l)
+		}
+	}
+
+	if len(pass) == 0 {
+		return nil
+	}
+
+	if engine := r.srv.guard; r.srv.screening() {
+		req := guard.Request{ClientIP: r.ip, Role: engine.RoleFor(r.ip), Radio: rc.addr, Line: line}
+
+		d := r.srv.checkCommand(cs.id, req)
+		switch d.Action {
+		case guard.ActionBlock:
+			engine.Record(req, d, "blocked")
+
+			return rigctl.ErrRejected
+		case guard.ActionConfirm:
+			engine.Record(req, d, "rejected")
+
+			return rigctl.ErrRejected
+		case guard.ActionAllow:
+		}
+	}
+
+	return rc.rigctlCommand(pass)
+}
+
+// rigctlCommand writes one command and waits for its reply. rigctl commands
+// share a sequence number, so only one is in flight per radio.
+func (rc *radioConn) rigctlCommand(line []byte) error {
+	rc.rigMu.Lock()
+	defer rc.rigMu.Unlock()
+
+	reply := make(chan string, 1)
+
+	rc.mu.Lock()
+	rc.rigReply = reply
+	rc.mu.Unlock()
+
+	defer func() {
+		rc.mu.Lock()
+		rc.rigReply = nil
+		rc.mu.Unlock()
+	}()
+
+	rc.noteOutgoingCommand(line)
+
+	err := rc.writeTCP(line)
+	if err != nil {
+		return rigctl.ErrIO
+	}
+
+	select {
+	case res := <-reply:
+		// R<seq>|<code>|<message>
+		parts := strings.SplitN(res, "|", 3)
+		if len(parts) < 2 {
+			return rigctl.ErrProtocol
+		}
+
+		code, err := strconv.ParseUint(parts[1], 16, 32)
+		if err != nil {
+			return rigctl.ErrProtocol
+		}
+
+		if code != 0 {
+			return rigctl.ErrRejected
+		}
+
+		return nil
+	case <-time.After(rigctlReplyTimeout):
+		return rigctl.ErrTimeout
+	}
+}
+
+// consumeRigctlReply hands the reply to a rigctl command to its waiter.
+func (rc *radioConn) consumeRigctlReply(line string) bool {
+	if !strings.HasPrefix(line, fmt.Sprintf("R%d|", internalRigctlSequence)) {
+		return false
+	}
+
+	rc.mu.RLock()
+	reply := rc.rigReply
+	rc.mu.RUnlock()
+
+	if reply != nil {
+		select {
+		case reply <- line:
+		default:
+		}
+	}
+
+	return true
+}
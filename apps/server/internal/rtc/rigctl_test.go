@@ -0,0 +1,203 @@
+package rtc
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rigctl"
+)
+
+// rigctlRadio is a session whose radio answers every command with code.
+func rigctlRadio(t *testing.T, code string) (*Server, *radioConn, func() []string) {
+	t.Helper()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	rc := &radioConn{handleHex: "1234ABCD"}
+	rc.out = newTCPWriter(client, func([]byte) {}, nil)
+	t.Cleanup(rc.out.close)
+
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+
+	go func() {
+		rd := bufio.NewReader(radio)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			sent = append(sent, strings.TrimSpace(line))
+			mu.Unlock()
+
+			seq, _, _ := strings.Cut(strings.TrimPrefix(line, "C"), "|")
+			rc.consumeRigctlReply("R" + seq + "|" + code + "|")
+		}
+	}()
+
+	rc.info.observe("slice 0 in_use=1 index_letter=A RF_frequency=14.074000 mode=DIGU filter_lo=100 filter_hi=3100 tx=1")
+	rc.info.observe("slice 1 in_use=1 index_letter=B RF_frequency=7.074000 mode=LSB filter_lo=-2800 filter_hi=-100 tx=0")
+
+	srv := &Server{sessions: make(map[string]*clientSession), rigSlices: rigSlices(nil)}
+	srv.addSession(&clientSession{id: "s1", srv: srv, clientIP: "10.0.0.2", radio: rc})
+
+	return srv, rc, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), sent...)
+	}
+}
+
+func TestRigAdapter(t *testing.T) {
+	t.Parallel()
+
+	srv, _, sent := rigctlRadio(t, "0")
+	rig := srv.Rig("10.0.0.9")
+
+	hz, err := rig.Freq(rigctl.VFOB)
+	if err != nil || hz != 7074000 {
+		t.Errorf("VFOB = %v, %v", hz, err)
+	}
+
+	mode, pb, _ := rig.Mode(rigctl.VFOA)
+	if mode != "PKTUSB" || pb != 3000 {
+		t.Errorf("VFOA mode = %s %d", mode, pb)
+	}
+
+	err = rig.SetFreq(rigctl.VFOA, 14076000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hz, _ := rig.Freq(rigctl.VFOA); hz != 14076000 {
+		t.Errorf("after tune VFOA = %v", hz)
+	}
+
+	err = rig.SetMode(rigctl.VFOB, "PKTLSB", 2400)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = rig.SetSplit(true, rigctl.VFOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if on, tx, _ := rig.Split(); !on || tx != rigctl.VFOB {
+		t.Errorf("split = %v %s", on, tx)
+	}
+
+	err = rig.SetPTT(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"C2147483643|slice tune 0 14.076000",
+		"C2147483643|slice set 1 mode=DIGL",
+		"C2147483643|filt 1 -2500 -100",
+		"C2147483643|slice set 1 tx=1",
+		"C2147483643|xmit 1",
+	}
+	if got := sent(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("radio got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if !errors.Is(rig.SetMode(rigctl.VFOA, "FAX", 0), rigctl.ErrInvalid) {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestRigAdapter_Rejected(t *testing.T) {
+	t.Parallel()
+
+	srv, rc, _ := rigctlRadio(t, "50000015")
+	rig := srv.Rig("10.0.0.2")
+
+	err := rig.SetFreq(rigctl.VFOA, 99e9)
+	if !errors.Is(err, rigctl.ErrRejected) {
+		t.Errorf("SetFreq = %v, want rejected", err)
+	}
+
+	rc.info.mu.Lock()
+	f := rc.info.slices[0].freqMHz
+	rc.info.mu.Unlock()
+
+	if f != 14.074 {
+		t.Errorf("rejected tune changed the cache to %v", f)
+	}
+}
+
+func TestRigAdapter_NoSession(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{sessions: make(map[string]*clientSession), rigSlices: rigSlices(nil)}
+
+	_, err := srv.Rig("10.0.0.2").Freq(rigctl.VFOA)
+	if !errors.Is(err, rigctl.ErrIO) {
+		t.Errorf("Freq without a session = %v", err)
+	}
+}
+
+func TestFilterFor(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		mode           string
+		lo, hi, width  int
+		wantLo, wantHi int
+	}{
+		{"USB", 100, 2800, 3000, 100, 3100},
+		{"DIGL", -2800, -100, 2000, -2100, -100},
+		{"AM", -3000, 3000, 8000, -4000, 4000},
+		{"CW", -250, 250, 101, -50, 51},
+	}
+	for _, c := range cases {
+		lo, hi := filterFor(c.mode, c.lo, c.hi, c.width)
+		if lo != c.wantLo || hi != c.wantHi {
+			t.Errorf("%s %d..%d to %d = %d..%d, want %d..%d", c.mode, c.lo, c.hi, c.width, lo, hi, c.wantLo, c.wantHi)
+		}
+	}
+}
+
+func TestActiveRig(t *testing.T) {
+	t.Parallel()
+
+	srv, rc, sent := rigctlRadio(t, "0")
+	rig := srv.ActiveRig("10.0.0.2")
+
+	// Without an active slice the first one stands in.
+	if hz, _ := rig.Freq(rigctl.VFOA); hz != 14074000 {
+		t.Errorf("VFOA = %v, want slice 0", hz)
+	}
+
+	rc.info.observe("slice 1 active=1")
+	rc.info.observe("slice 0 active=0")
+
+	if hz, _ := rig.Freq(rigctl.VFOA); hz != 7074000 {
+		t.Errorf("VFOA = %v, want the active slice", hz)
+	}
+
+	if hz, _ := rig.Freq(rigctl.VFOB); hz != 14074000 {
+		t.Errorf("VFOB = %v, want the other slice", hz)
+	}
+
+	err := rig.SetFreq(rigctl.VFOA, 7076000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := sent(); len(got) != 1 || got[0] != "C2147483643|slice tune 1 7.076000" {
+		t.Errorf("radio got %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+// Package rtctest has test fixtures for the rtc package and the APIs built
+// on it.
+package rtctest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// Handle is the client handle FakeRadio gives its client.
+const Handle = "0x1234ABCD"
+
+// FakeRadio accepts one client, greets it as a radio would and answers
+// every command but pings with success. It returns its address and the
+// commands received, pings and keepalives left out. Commands past 256
+// unread ones are dropped, so tests need not read them.
+func FakeRadio(t testing.TB) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	got := make(chan string, 256)
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = c.Close() }()
+
+		_, _ = c.Write([]byte("V1.4.0.0\nH" + strings.TrimPrefix(Handle, "0x") + "\n"))
+
+		rd := bufio.NewReader(c)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimSpace(line)
+			seq, body, _ := strings.Cut(strings.TrimPrefix(line, "C"), "|")
+
+			switch {
+			case strings.HasPrefix(body, "ping"):
+				continue
+			case strings.HasPrefix(body, "keepalive"):
+			default:
+				select {
+				case got <- line:
+				default:
+				}
+			}
+
+			_, _ = c.Write([]byte("R" + seq + "|0|\n"))
+		}
+	}()
+
+	return ln.Addr().String(), got
+}
@@ -0,0 +1,200 @@
+package rtc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+)
+
+// internalSandboxSequence tags the subscriptions the bridge re-sends when a
+// session leaves the sandbox, so the radio's full status reaches the client
+// again and replaces the simulated state.
+const internalSandboxSequence = 2147483644
+
+// reSandboxed matches commands that key the transmitter, change power or
+// alter persistent radio configuration. In a sandboxed session they never
+// reach the radio.
+var reSandboxed = regexp.MustCompile(`^(?:xmit|transmit|atu|interlock|cwx?\s+(?:send|key)|` +
+	`radio\s+(?:set|reboot|callsign|name)|profile\s+\S+\s+(?:save|create|reset|delete|load)|` +
+	`amplifier)\b`)
+
+// sandboxResync are re-subscribed on leaving the sandbox; the radio answers a
+// subscription with the current state of the objects we simulated.
+var sandboxResync = []string{"sub tx all", "sub atu all", "sub radio all"}
+
+type sandboxPayload struct {
+	Enabled   bool                         `json:"enabled"`
+	Simulated int                          `json:"simulated,omitempty"` // commands answered by the bridge so far
+	State     map[string]map[string]string `json:"state,omitempty"`     // simulated values by object
+}
+
+// sandbox answers configuration and TX commands locally with simulated
+// success and keeps a model of the state they would have set, reported to
+// the client as ordinary status lines.
+type sandbox struct {
+	mu        sync.Mutex
+	enabled   bool
+	simulated int
+	state     map[string]map[string]string // object -> key -> value
+}
+
+func (sb *sandbox) set(enabled bool) (changed bool) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	changed = sb.enabled != enabled
+	sb.enabled = enabled
+
+	if !enabled {
+		sb.state = nil
+	}
+
+	return changed
+}
+
+func (sb *sandbox) isEnabled() bool {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	return sb.enabled
+}
+
+func (sb *sandbox) payload() sandboxPayload {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	p := sandboxPayload{Enabled: sb.enabled, Simulated: sb.simulated}
+	if len(sb.state) > 0 {
+		p.State = make(map[string]map[string]string, len(sb.state))
+		for obj, kv := range sb.state {
+			p.State[obj] = maps.Clone(kv)
+		}
+	}
+
+	return p
+}
+
+// filter removes sandboxed lines from data and returns the rest, along with
+// the reply and status lines that simulate the removed ones.
+func (sb *sandbox) filter(data []byte, handleHex string) (pass []byte, replies []string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if !sb.enabled {
+		return data, nil
+	}
+
+	for line := range strings.SplitAfterSeq(string(data), "\n") {
+		seq, body, ok := guard.SplitCommand(line)
+		if !ok || !reSandboxed.MatchString(body) {
+			pass = append(pass, line...)
+
+			continue
+		}
+
+		sb.simulated++
+
+		replies = append(replies, fmt.Sprintf("R%s|0|\n", seq))
+		for _, st := range sb.applyLocked(body) {
+			replies = append(replies, fmt.Sprintf("S%s|%s\n", handleHex, st))
+		}
+	}
+
+	return pass, replies
+}
+
+// applyLocked updates the model for body and returns the status bodies the
+// radio would have broadcast for it.
+func (sb *sandbox) applyLocked(body string) []string {
+	fields := strings.Fields(body)
+
+	switch {
+	case fields[0] == "xmit" && len(fields) > 1:
+		state := "READY"
+		if fields[1] == "1" {
+			state = "TRANSMITTING"
+		}
+
+		return []string{sb.updateLocked("interlock", []string{"state=" + state, "source=SW"})}
+	case fields[0] == "transmit" && len(fields) > 2 && fields[1] == "tune":
+		on := boolFlag(fields[2] == "1" || fields[2] == "on")
+
+		return []string{sb.updateLocked("transmit", []string{"tune=" + on})}
+	case len(fields) > 2 && fields[1] == "set":
+		return []string{sb.updateLocked(fields[0], fields[2:])}
+	}
+
+	return nil
+}
+
+func (sb *sandbox) updateLocked(object string, kvs []string) string {
+	if sb.state == nil {
+		sb.state = make(map[string]map[string]string)
+	}
+
+	obj := sb.state[object]
+	if obj == nil {
+		obj = make(map[string]string)
+		sb.state[object] = obj
+	}
+
+	for _, kv := range kvs {
+		k, v, _ := strings.Cut(kv, "=")
+		obj[k] = v
+	}
+
+	return object + " " + strings.Join(kvs, " ")
+}
+
+// setSandbox turns the session's sandbox on or off and tells the client.
+// Leaving the sandbox re-subscribes to the simulated objects so the client's
+// view is corrected from the radio.
+func (cs *clientSession) setSandbox(enabled bool) {
+	if !cs.sandbox.set(enabled) {
+		cs.trySend(mustEncode(typeSandbox, cs.sandbox.payload()))
+
+		return
+	}
+
+	log.Printf("[rtc] session %s sandbox %v", cs.id, enabled)
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if !enabled && rc != nil {
+		for _, cmd := range sandboxResync {
+			err := rc.writeTCPString(fmt.Sprintf("C%d|%s\n", internalSandboxSequence, cmd))
+			if err != nil {
+				log.Printf("[rtc] sandbox resync: %v", err)
+
+				break
+			}
+		}
+	}
+
+	cs.trySend(mustEncode(typeSandbox, cs.sandbox.payload()))
+}
+
+func (cs *clientSession) handleSandbox(raw json.RawMessage) {
+	var p sandboxPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	cs.setSandbox(p.Enabled)
+}
+
+func isInternalSandboxReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalSandboxSequence))
+}
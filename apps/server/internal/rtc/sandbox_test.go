@@ -0,0 +1,71 @@
+package rtc
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSandboxFilter(t *testing.T) {
+	t.Parallel()
+
+	var sb sandbox
+
+	data := []byte("C1|xmit 1\nC2|slice tune 0 14.074\nC3|transmit set rfpower=50 tunepower=10\n")
+
+	pass, replies := sb.filter(data, "ABCD")
+	if string(pass) != string(data) || replies != nil {
+		t.Fatalf("disabled sandbox should pass everything, got %q %q", pass, replies)
+	}
+
+	sb.set(true)
+
+	pass, replies = sb.filter(data, "ABCD")
+	if string(pass) != "C2|slice tune 0 14.074\n" {
+		t.Errorf("pass = %q, want only the slice command", pass)
+	}
+
+	want := []string{
+		"R1|0|\n",
+		"SABCD|interlock state=TRANSMITTING source=SW\n",
+		"R3|0|\n",
+		"SABCD|transmit rfpower=50 tunepower=10\n",
+	}
+	if !slices.Equal(replies, want) {
+		t.Errorf("replies = %q, want %q", replies, want)
+	}
+
+	p := sb.payload()
+	if p.Simulated != 2 || p.State["transmit"]["rfpower"] != "50" || p.State["interlock"]["state"] != "TRANSMITTING" {
+		t.Errorf("payload = %+v", p)
+	}
+
+	sb.set(false)
+
+	if p := sb.payload(); p.Enabled || p.State != nil {
+		t.Errorf("leaving the sandbox should drop simulated state, got %+v", p)
+	}
+}
+
+func TestSandboxedCommands(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]bool{
+		"xmit 0":                      true,
+		"transmit tune 1":             true,
+		"atu start":                   true,
+		"radio set callsign=N0CALL":   true,
+		"profile global save default": true,
+		"profile tx load default":     true,
+		"cwx send \"CQ\"":             true,
+		"slice tune 0 7.074":          false,
+		"display pan set 0x40000000":  false,
+		"sub tx all":                  false,
+		"radio uptime":                false,
+		"profile global info":         false,
+	}
+	for body, want := range cases {
+		if got := reSandboxed.MatchString(body); got != want {
+			t.Errorf("%q sandboxed = %v, want %v", body, got, want)
+		}
+	}
+}
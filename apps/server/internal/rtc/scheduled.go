@@ -0,0 +1,68 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errNoRadioSession = errors.New("no session is connected to the radio")
+
+// LoadProfile loads the global profile name on radio, by host:port or host,
+// through the oldest session connected to it, as the session's client would.
+func (s *Server) LoadProfile(radio, name string) error {
+	cs := s.radioSession(radio)
+	if cs == nil {
+		return fmt.Errorf("%w: %s", errNoRadioSession, radio)
+	}
+
+	_, err := cs.runProfile(ProfileRequest{Action: "load", Kind: "global", Name: name})
+
+	return err
+}
+
+// RecordRadio starts recording radio's RX audio, by host:port or host, in
+// the oldest session connected to it, stopping after d unless it is zero.
+// The session's client is told, as if it had started the recording.
+func (s *Server) RecordRadio(radio, format string, d time.Duration) error {
+	cs := s.radioSession(radio)
+	if cs == nil {
+		return fmt.Errorf("%w: %s", errNoRadioSession, radio)
+	}
+
+	req := RecordingRequest{Action: "start", Format: format}
+	if d > 0 {
+		req.Duration = d.String()
+	}
+
+	status, err := cs.startRecording(req)
+	if err != nil {
+		return err
+	}
+
+	cs.trySend(mustEncode(typeRecording, status))
+
+	return nil
+}
+
+// radioSession is the oldest session connected to radio that is not
+// sandboxed, or nil.
+func (s *Server) radioSession(radio string) *clientSession {
+	var found *clientSession
+
+	for _, cs := range s.sessionList() {
+		cs.mu.Lock()
+		rc := cs.radio
+		cs.mu.Unlock()
+
+		if rc == nil || !radioMatches(radio, rc.addr) || cs.sandbox.isEnabled() {
+			continue
+		}
+
+		if found == nil || cs.createdAt.Before(found.createdAt) {
+			found = cs
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,459 @@
+package rtc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+)
+
+// The self-test's simulated radio, its streams, and the Opus frame it sends:
+// a 10 ms CELT fullband mono frame of silence.
+const (
+	selfTestHandle     = "5E1F7E57"
+	selfTestAudio      = 0x04000008
+	selfTestData       = 0x42000001
+	selfTestDataClass  = 0x0123
+	selfTestInterval   = 10 * time.Millisecond
+	selfTestICEPortMin = 49152
+	selfTestICEPortMax = 65535
+)
+
+var (
+	selfTestOpus = []byte{0xF0, 0xFF, 0xFE}
+
+	errSelfTest = errors.New("self-test failed")
+)
+
+// SelfTestStep is one check of SelfTest, reported as it finishes.
+type SelfTestStep struct {
+	Name    string
+	Err     error
+	Elapsed time.Duration
+}
+
+// SelfTest runs a bridge on loopback against a simulated radio and an
+// in-process WebRTC client. It negotiates as a browser would, then checks
+// that the radio's greeting and a command's reply cross the "tcp" channel,
+// that its VITA-49 data reaches the "udp" channel, and that its Opus audio
+// reaches the audio track. No radio or network is needed, so it smoke-tests
+// a build on a new platform. Each step is passed to report; the first to
+// fail ends the test.
+func SelfTest(ctx context.Context, timeout time.Duration, report func(SelfTestStep)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var st selfTest
+
+	defer st.close()
+
+	for _, step := range []struct {
+		name string
+		run  func(context.Context) error
+	}{
+		{"simulated radio", st.startRadio},
+		{"bridge", st.startBridge},
+		{"signaling", st.dial},
+		{"negotiation", st.negotiate},
+		{"tcp channel", st.checkTCP},
+		{"udp channel", st.checkUDP},
+		{"audio track", st.checkAudio},
+	} {
+		start := time.Now()
+		err := step.run(ctx)
+
+		report(SelfTestStep{Name: step.name, Err: err, Elapsed: time.Since(start)})
+
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", errSelfTest, step.name, err)
+		}
+	}
+
+	return nil
+}
+
+type selfTest struct {
+	radio  net.Listener
+	radioU *net.UDPConn
+	http   *http.Server
+	url    string
+
+	wsMu sync.Mutex
+	ws   *websocket.Conn
+	pc   *webrtc.PeerConnection
+
+	tcpDC *webrtc.DataChannel
+	tcp   chan string
+	udp   chan []byte
+	audio chan []byte
+}
+
+func (st *selfTest) close() {
+	if st.pc != nil {
+		_ = st.pc.Close()
+	}
+
+	if st.ws != nil {
+		_ = st.ws.Close()
+	}
+
+	if st.http != nil {
+		_ = st.http.Close()
+	}
+
+	if st.radio != nil {
+		_ = st.radio.Close()
+	}
+
+	if st.radioU != nil {
+		_ = st.radioU.Close()
+	}
+}
+
+// startRadio listens as a radio would: it greets each API session, answers
+// every command, and once told the bridge's UDP port streams audio and data
+// to it.
+func (st *selfTest) startRadio(ctx context.Context) error {
+	var err error
+
+	st.radio, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	st.radioU, err = net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	go func() {
+		for {
+			c, err := st.radio.Accept()
+			if err != nil {
+				return
+			}
+
+			go st.serveRadio(ctx, c)
+		}
+	}()
+
+	return nil
+}
+
+func (st *selfTest) serveRadio(ctx context.Context, c net.Conn) {
+	defer func() { _ = c.Close() }()
+
+	_, _ = fmt.Fprintf(c, "V1.4.0.0\nH%s\n", selfTestHandle)
+
+	rd := bufio.NewReader(c)
+
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		seq, body, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "C"), "|")
+		_, _ = fmt.Fprintf(c, "R%s|0|\n", seq)
+
+		var port int
+		if _, err := fmt.Sscanf(body, "client udpport %d", &port); err == nil {
+			go st.streamUDP(ctx, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+		}
+	}
+}
+
+func (st *selfTest) streamUDP(ctx context.Context, to *net.UDPAddr) {
+	t := time.NewTicker(selfTestInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			_, _ = st.radioU.WriteToUDP(selfTestPacket(selfTestAudio, 0x8005, selfTestOpus), to)
+			_, _ = st.radioU.WriteToUDP(selfTestPacket(selfTestData, selfTestDataClass, []byte("solid-sdr self-test")), to)
+		}
+	}
+}
+
+// selfTestPacket is a VITA-49 packet with a stream and class ID, padded to
+// the least the bridge parses.
+func selfTestPacket(stream uint32, class uint16, payload []byte) []byte {
+	p := []byte{0x18, 0, 0, 0}
+	p = binary.BigEndian.AppendUint32(p, stream)
+	p = binary.BigEndian.AppendUint32(p, 0x001C2D)
+	p = binary.BigEndian.AppendUint32(p, uint32(class))
+
+	p = append(p, payload...)
+	for len(p) < 28 {
+		p = append(p, 0)
+	}
+
+	return p
+}
+
+// startBridge serves signaling on loopback, as the bridge does.
+func (st *selfTest) startBridge(context.Context) error {
+	s := New(nil, Options{Version: "selftest", ICEPortStart: selfTestICEPortMin, ICEPortEnd: selfTestICEPortMax})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	st.http = &http.Server{Handler: s, ReadHeaderTimeout: 5 * time.Second}
+	st.url = "ws://" + ln.Addr().String() + "/ws/signal"
+
+	go func() { _ = st.http.Serve(ln) }()
+
+	return nil
+}
+
+// dial opens the signaling WebSocket and waits for the bridge's version.
+func (st *selfTest) dial(ctx context.Context) error {
+	ws, resp, err := websocket.DefaultDialer.DialContext(ctx, st.url, nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	_ = resp.Body.Close()
+	st.ws = ws
+
+	var msg message
+
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	err = ws.ReadJSON(&msg)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	if msg.Type != typeVersion {
+		return fmt.Errorf("got %q before the version", msg.Type)
+	}
+
+	_ = ws.SetReadDeadline(time.Time{})
+
+	return nil
+}
+
+func (st *selfTest) send(msgType string, payload any) error {
+	st.wsMu.Lock()
+	defer st.wsMu.Unlock()
+
+	err := st.ws.WriteJSON(mustEncode(msgType, payload))
+	if err != nil {
+		return fmt.Errorf("send %s: %w", msgType, err)
+	}
+
+	return nil
+}
+
+// negotiate offers the radio's channels and an audio track, as a browser
+// does, and waits for the connection.
+func (st *selfTest) negotiate(ctx context.Context) error {
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return fmt.Errorf("peer connection: %w", err)
+	}
+
+	st.pc = pc
+	st.tcp, st.udp, st.audio = make(chan string, 64), make(chan []byte, 64), make(chan []byte, 64)
+
+	_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio,
+		webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly})
+	if err != nil {
+		return fmt.Errorf("audio transceiver: %w", err)
+	}
+
+	protocol := "tcp"
+
+	st.tcpDC, err = pc.CreateDataChannel(st.radio.Addr().String(), &webrtc.DataChannelInit{Protocol: &protocol})
+	if err != nil {
+		return fmt.Errorf("tcp channel: %w", err)
+	}
+
+	st.tcpDC.OnMessage(func(msg webrtc.DataChannelMessage) { trySend(st.tcp, string(msg.Data)) })
+
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		for {
+			pkt, _, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+
+			trySend(st.audio, pkt.Payload)
+		}
+	})
+
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c != nil {
+			_ = st.send(typeICE, c.ToJSON())
+		}
+	})
+
+	connected := make(chan struct{})
+
+	var once sync.Once
+
+	pc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		if s == webrtc.PeerConnectionStateConnected {
+			once.Do(func() { close(connected) })
+		}
+	})
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return fmt.Errorf("offer: %w", err)
+	}
+
+	err = pc.SetLocalDescription(offer)
+	if err != nil {
+		return fmt.Errorf("offer: %w", err)
+	}
+
+	err = st.send(typeOffer, offer)
+	if err != nil {
+		return err
+	}
+
+	go st.readSignaling()
+
+	select {
+	case <-connected:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("not connected: %w", ctx.Err())
+	}
+}
+
+// readSignaling applies the bridge's descriptions and candidates until the
+// WebSocket closes.
+func (st *selfTest) readSignaling() {
+	for {
+		var msg message
+
+		err := st.ws.ReadJSON(&msg)
+		if err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case typeAnswer, typeOffer:
+			var sd webrtc.SessionDescription
+			if json.Unmarshal(msg.Payload, &sd) != nil || st.pc.SetRemoteDescription(sd) != nil {
+				continue
+			}
+
+			if sd.Type == webrtc.SDPTypeOffer {
+				answer, err := st.pc.CreateAnswer(nil)
+				if err == nil && st.pc.SetLocalDescription(answer) == nil {
+					_ = st.send(typeAnswer, answer)
+				}
+			}
+		case typeICE:
+			var c webrtc.ICECandidateInit
+			if json.Unmarshal(msg.Payload, &c) == nil {
+				_ = st.pc.AddICECandidate(c)
+			}
+		}
+	}
+}
+
+// checkTCP waits for the radio's greeting on the "tcp" channel, then sends
+// a command and waits for its reply.
+func (st *selfTest) checkTCP(ctx context.Context) error {
+	err := awaitLine(ctx, st.tcp, "H"+selfTestHandle)
+	if err != nil {
+		return err
+	}
+
+	err = st.tcpDC.SendText("C1|info\n")
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+
+	return awaitLine(ctx, st.tcp, "R1|0|")
+}
+
+// checkUDP opens the "udp" channel, as a browser does once the radio has
+// greeted it, and waits for the radio's data packet on it.
+func (st *selfTest) checkUDP(ctx context.Context) error {
+	label, err := radioUDPAddr(st.radio.Addr().String())
+	if err != nil {
+		return err
+	}
+
+	protocol := "udp"
+
+	dc, err := st.pc.CreateDataChannel(label, &webrtc.DataChannelInit{Protocol: &protocol})
+	if err != nil {
+		return fmt.Errorf("udp channel: %w", err)
+	}
+
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) { trySend(st.udp, msg.Data) })
+
+	for {
+		select {
+		case p := <-st.udp:
+			v, err := parseVITA(p)
+			if err == nil && v.StreamID == selfTestData && v.ClassCode == selfTestDataClass {
+				return nil
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("no data packet: %w", ctx.Err())
+		}
+	}
+}
+
+// checkAudio waits for the radio's Opus frames on the audio track.
+func (st *selfTest) checkAudio(ctx context.Context) error {
+	for {
+		select {
+		case p := <-st.audio:
+			// Frames may be joined into one packet; its TOC byte still
+			// names the radio's mode.
+			if len(p) > 0 && p[0]&0xFC == selfTestOpus[0]&0xFC {
+				return nil
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("no audio: %w", ctx.Err())
+		}
+	}
+}
+
+// awaitLine waits for want among the lines on ch, which may arrive batched.
+func awaitLine(ctx context.Context, ch <-chan string, want string) error {
+	for {
+		select {
+		case msg := <-ch:
+			for line := range strings.Lines(msg) {
+				if strings.TrimSpace(line) == want {
+					return nil
+				}
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("no %q: %w", want, ctx.Err())
+		}
+	}
+}
+
+// trySend drops v rather than block a pion callback on a full channel.
+func trySend[T any](ch chan<- T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
@@ -0,0 +1,27 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+
+	var steps []string
+
+	err := SelfTest(t.Context(), 20*time.Second, func(s SelfTestStep) {
+		if s.Err != nil {
+			t.Logf("%s: %v", s.Name, s.Err)
+		}
+
+		steps = append(steps, s.Name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(steps) != 7 || steps[6] != "audio track" {
+		t.Errorf("steps = %q", steps)
+	}
+}
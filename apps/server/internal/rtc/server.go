@@ -1,13 +1,32 @@
 package rtc
 
 import (
+	"cmp"
 	"context"
 	"log"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/daveisadork/solid-sdr/apps/server/internal/antenna"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/audit"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/captions"
 	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/dscp"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/events"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/history"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/logbook"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/recorder"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spectate"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spots"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/txaudio"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/version"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsdeflate"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsjtx"
 	"github.com/gorilla/websocket"
 	"github.com/pion/ice/v4"
 	"github.com/pion/webrtc/v4"
@@ -16,9 +35,131 @@ import (
 type Options struct {
 	ICEPortStart uint16
 	ICEPortEnd   uint16
-	STUN         []string
-	NAT1To1IPs   []string
-	Version      string
+	// ICETCPPort, when set, also offers passive ICE-TCP candidates on this
+	// TCP port, for networks that block UDP.
+	ICETCPPort uint16
+	STUN       []string
+	// TURN is a relay for networks that allow neither UDP nor ICE-TCP;
+	// clients are sent it with the bridge's version.
+	TURN TURNServer
+	// MediaDSCP marks the ICE sockets, which carry audio and the data
+	// channels bundled with it; zero leaves them unmarked.
+	MediaDSCP  int
+	NAT1To1IPs []string
+	// PublicIPs are advertised as extra server-reflexive candidates, e.g. the
+	// gateway's external address learned via UPnP.
+	PublicIPs []string
+	Version   string
+	// Build is sent to clients with the version, so bug reports name the
+	// exact build; nil sends the version alone.
+	Build      *version.Info
+	APILogFile string
+	Guard      *guard.Engine
+	// Captions transcribes RX audio for clients that open a "captions" data
+	// channel; nil disables captioning.
+	Captions *captions.Client
+	// Fairness throttles spectators' radio data so the operator's is never
+	// starved; the zero value disables it.
+	Fairness FairnessOptions
+	// Recorder writes session recordings; nil disables recording.
+	Recorder *recorder.Recorder
+	// Preflight is one of PreflightOff, PreflightRegistry or PreflightTCP.
+	Preflight     string
+	MaxSessions   int
+	AllowedRadios []string
+	// CheckOrigin validates the Origin of signaling WebSocket upgrades. nil
+	// falls back to gorilla's same-origin check.
+	CheckOrigin func(*http.Request) bool
+	// Deflate picks the WebSocket messages compressed with
+	// permessage-deflate; the zero Policy compresses none.
+	Deflate wsdeflate.Policy
+	// RigctlSlices are the slice letters rigctl clients see as VFOA and
+	// VFOB; empty means A and B.
+	RigctlSlices []string
+	// WSJTX bridges WSJT-X's decodes to clients that subscribe; nil
+	// disables the bridge.
+	WSJTX *wsjtx.Service
+	// Antennas are the network antenna switches clients may watch and
+	// switch, and that follow each radio's band; nil means none.
+	Antennas *antenna.Service
+	// Logbook is told what each radio is tuned to and hands clients that
+	// subscribe the QSOs logged; nil when logbook integration is off.
+	Logbook *logbook.Service
+	// Spots hands clients that subscribe the feed spots their panadapters
+	// show; nil when no spot feeds are followed.
+	Spots *spots.Service
+	// History records where each radio is tuned and when it transmits, and
+	// answers clients' "history" queries; nil when it is off.
+	History *history.Store
+	// MeterAlerts are checked against every session's meters, raising
+	// meter.alert events.
+	MeterAlerts []MeterAlert
+	// CWBuffer is how long "cw" data channel key events are held to even
+	// out network jitter; zero means DefaultCWBuffer.
+	CWBuffer time.Duration
+	// PTTKeepalive and PTTMaxTX bound a "ptt" message's transmission: it
+	// ends when the client stops repeating it for PTTKeepalive, or after
+	// PTTMaxTX in any case. Zero means DefaultPTTKeepalive and
+	// DefaultPTTMaxTX.
+	PTTKeepalive time.Duration
+	PTTMaxTX     time.Duration
+	// RadioTimeout is how long a radio may leave the bridge's pings
+	// unanswered before its sessions are told the link is unhealthy; zero
+	// means DefaultRadioTimeout.
+	RadioTimeout time.Duration
+	// Events receives session and stream lifecycle events; nil drops them.
+	Events *events.Bus
+	// AudioRedundancy is the RX audio redundancy mode sessions start in:
+	// RedundancyAuto, RedundancyOn or RedundancyOff. Empty means
+	// RedundancyAuto.
+	AudioRedundancy string
+	// BandwidthShed lists the streams, of BandwidthFFT, BandwidthWaterfall
+	// and BandwidthIQ, reduced in that order while a session's link is
+	// congested; empty disables the budget.
+	BandwidthShed []string
+	// UDPPipeline shapes how each session's radio UDP is received.
+	UDPPipeline UDPPipelineOptions
+	// LineBatch joins the radio's lines into fewer "tcp" data channel
+	// messages; off when its Delay is zero.
+	LineBatch LineBatchOptions
+	// StreamChannels sets how the channel of each of StreamFFT,
+	// StreamWaterfall, StreamMeters and StreamIQ delivers, when a client asks
+	// for channels of their own: ChannelReliable, ChannelUnordered,
+	// ChannelLossy, or ChannelShared to keep it on "udp". A stream not set
+	// takes its DefaultStreamChannels mode.
+	StreamChannels map[string]string
+	// UDPPorts, when set, carries every session's radio UDP in place of a
+	// socket per session.
+	UDPPorts *UDPPorts
+	// SessionState is the file sessions are saved to, for a restarted
+	// bridge to dial their radios again and let clients resume them; empty
+	// saves none.
+	SessionState string
+	// ResumeWindow is how long a restarted bridge holds a saved session for
+	// its client; zero means DefaultResumeWindow.
+	ResumeWindow time.Duration
+	// StaleCleanup has a restarted bridge remove the streams and client
+	// handles the sessions in SessionState left on their radios.
+	StaleCleanup bool
+	// Identity is how sessions name themselves to the radio.
+	Identity ClientIdentity
+	// TXAudio processes browser TX audio before it reaches the radio; nil
+	// passes it through untouched.
+	TXAudio *txaudio.Service
+	// TXToken is how the sessions on a radio share its TX token, which only
+	// the holder may key with: TXTokenGrant or TXTokenSteal. Empty or
+	// TXTokenOff lets every session key.
+	TXToken string
+	// Audit is the trail every command sent to a radio is recorded in; nil
+	// records none.
+	Audit *audit.Log
+	// UserHeader is the request header in which a proxy in front of the
+	// bridge names the user it authenticated, e.g. Remote-User; empty
+	// trusts none.
+	UserHeader string
+	// Spectators signs spectator links; nil signs them with a random key,
+	// so they die with the bridge.
+	Spectators *spectate.Signer
 }
 
 type Server struct {
@@ -26,17 +167,103 @@ type Server struct {
 	api        *webrtc.API
 	iceServers []webrtc.ICEServer
 	version    string
+	build      *version.Info
+	guard      *guard.Engine
+	captions   *captions.Client
+	fair       *fairness
+	recorder   *recorder.Recorder
+	wsjtx      *wsjtx.Service
+	antennas   *antenna.Service
+	logbook    *logbook.Service
+	spots      *spots.Service
+	history    *history.Store
+	alerts     []MeterAlert
+	events     *events.Bus
+	cwBuffer   time.Duration
+	upgrader   websocket.Upgrader
+	deflate    wsdeflate.Policy
+	capture    *apiCapture
+	verbose    atomic.Bool
+	draining   atomic.Bool
+
+	drainMu     sync.Mutex
+	drainPeer   string
+	drainCancel context.CancelFunc
+
+	preflightMode string
+	maxSessions   int
+	allowedRadios []string
+	rigSlices     []string
+	pttKeepalive  time.Duration
+	pttMaxTX      time.Duration
+	radioTimeout  time.Duration
+	// audioRedundancy is the redundancy mode new sessions start in.
+	audioRedundancy string
+	bandwidthShed   []string
+	udpPipeline     UDPPipelineOptions
+	lineBatch       LineBatchOptions
+	streamChannels  map[string]webrtc.DataChannelInit
+	udpPorts        *UDPPorts
+
+	sessMu   sync.Mutex
+	sessions map[string]*clientSession
+	// byRadio holds the sessions with a radio connection, by its radioKey.
+	// Radios hand out handles independently, so the handle alone is not
+	// enough to tell two radios' connections apart.
+	byRadio map[string]*clientSession
+
+	stateFile    string
+	resumeWindow time.Duration
+	staleCleanup bool
+	identity     ClientIdentity
+	txAudio      *txaudio.Service
+	resumeMu     sync.Mutex
+	restored     map[string]*restoredSession // by resume token
+
+	whepMu sync.Mutex
+	whep   map[string]*whepListener
+
+	hlsMu sync.Mutex
+	hls   map[string]*hlsFeed
+
+	muteMu sync.Mutex
+	muted  map[string]string // radio whose TX is muted -> reason
+
+	audioMu sync.Mutex
+	audio   map[string]*audioGroups // radio host:port -> its sessions' groups
+
+	tokens *txTokens
+
+	spectators *spectate.Signer
+
+	audit      *audit.Log
+	userHeader string
 }
 
 func New(disco *discovery.Service, opt Options) *Server {
 	var se webrtc.SettingEngine
 	se.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4, webrtc.NetworkTypeUDP6})
 
+	if opt.ICETCPPort != 0 {
+		listenICETCP(&se, opt.ICETCPPort, opt.MediaDSCP)
+	}
+
+	muxOpts := []ice.UDPMuxFromPortOption{ice.UDPMuxFromPortWithNetworks(ice.NetworkTypeUDP4, ice.NetworkTypeUDP6)}
+
+	if opt.MediaDSCP != 0 {
+		n, err := dscp.Net(opt.MediaDSCP)
+		if err != nil {
+			log.Fatalf("[rtc] %v", err)
+		}
+
+		se.SetNet(n)
+		muxOpts = append(muxOpts, ice.UDPMuxFromPortWithNet(n))
+	}
+
 	if opt.ICEPortStart == opt.ICEPortEnd {
 		port := int(opt.ICEPortStart)
 
-		mux, err := ice.NewMultiUDPMuxFromPort(port,
-			ice.UDPMuxFromPortWithNetworks(ice.NetworkTypeUDP4, ice.NetworkTypeUDP6))
+		mux, err := ice.NewMultiUDPMuxFromPort(port, muxOpts...)
 		if err != nil {
 			log.Fatalf("[rtc] failed to create UDP mux on port %d: %v", port, err)
 		}
@@ -56,36 +283,139 @@ func New(disco *discovery.Service, opt Options) *Server {
 		}
 	}
 
+	var rewrites []webrtc.ICEAddressRewriteRule
 	if len(opt.NAT1To1IPs) > 0 {
-		err := se.SetICEAddressRewriteRules(webrtc.ICEAddressRewriteRule{
-			External:        append([]string(nil), opt.NAT1To1IPs...),
+		// Each address replaces the host candidates of its own family, so an
+		// IPv6 address is advertised for the bridge's IPv6 candidates and an
+		// IPv4 one for its IPv4 candidates.
+		rewrites = append(rewrites, webrtc.ICEAddressRewriteRule{
+			External:        natIPs(opt.NAT1To1IPs),
 			AsCandidateType: webrtc.ICECandidateTypeHost,
 			Mode:            webrtc.ICEAddressRewriteReplace,
 		})
+	}
+
+	if len(opt.PublicIPs) > 0 {
+		rewrites = append(rewrites, webrtc.ICEAddressRewriteRule{
+			External:        append([]string(nil), opt.PublicIPs...),
+			AsCandidateType: webrtc.ICECandidateTypeSrflx,
+			Mode:            webrtc.ICEAddressRewriteAppend,
+		})
+	}
+
+	if len(rewrites) > 0 {
+		err := se.SetICEAddressRewriteRules(rewrites...)
 		if err != nil {
 			log.Fatalf("[rtc] invalid ICE address rewrite config: %v", err)
 		}
 	}
 
-	api := webrtc.NewAPI(webrtc.WithSettingEngine(se))
+	me, err := newMediaEngine()
+	if err != nil {
+		log.Fatalf("[rtc] %v", err)
+	}
+
+	api := webrtc.NewAPI(webrtc.WithSettingEngine(se), webrtc.WithMediaEngine(me))
 
 	var iceServers []webrtc.ICEServer
 	if len(opt.STUN) > 0 {
 		iceServers = append(iceServers, webrtc.ICEServer{URLs: opt.STUN})
 	}
 
-	return &Server{disco: disco, api: api, iceServers: iceServers, version: opt.Version}
-}
+	if len(opt.TURN.URLs) > 0 {
+		iceServers = append(iceServers, opt.TURN.iceServer())
+	}
 
-var upgrader = websocket.Upgrader{ //nolint:gochecknoglobals
-	ReadBufferSize:    64 * 1024,
-	WriteBufferSize:   64 * 1024,
-	CheckOrigin:       func(*http.Request) bool { return true },
-	EnableCompression: false,
+	identity := opt.Identity
+	identity.Version = cmp.Or(identity.Version, opt.Version)
+
+	s := &Server{
+		disco:      disco,
+		api:        api,
+		iceServers: iceServers,
+		version:    opt.Version,
+		build:      opt.Build,
+		guard:      opt.Guard,
+		captions:   opt.Captions,
+		fair:       newFairness(opt.Fairness),
+		recorder:   opt.Recorder,
+		wsjtx:      opt.WSJTX,
+		antennas:   opt.Antennas,
+		logbook:    opt.Logbook,
+		spots:      opt.Spots,
+		history:    opt.History,
+		alerts:     opt.MeterAlerts,
+		events:     opt.Events,
+		cwBuffer:   cmp.Or(opt.CWBuffer, DefaultCWBuffer),
+		capture:    newAPICapture(opt.APILogFile),
+		sessions:   make(map[string]*clientSession),
+		restored:   make(map[string]*restoredSession),
+
+		preflightMode: opt.Preflight,
+		maxSessions:   opt.MaxSessions,
+		allowedRadios: allowedRadios(opt.AllowedRadios),
+		rigSlices:     rigSlices(opt.RigctlSlices),
+		pttKeepalive:  cmp.Or(opt.PTTKeepalive, DefaultPTTKeepalive),
+		pttMaxTX:      cmp.Or(opt.PTTMaxTX, DefaultPTTMaxTX),
+		radioTimeout:  cmp.Or(opt.RadioTimeout, DefaultRadioTimeout),
+
+		audioRedundancy: cmp.Or(opt.AudioRedundancy, RedundancyAuto),
+		bandwidthShed:   opt.BandwidthShed,
+		udpPipeline:     opt.UDPPipeline,
+		lineBatch:       opt.LineBatch,
+		streamChannels:  streamChannels(opt.StreamChannels),
+		udpPorts:        opt.UDPPorts,
+		stateFile:       opt.SessionState,
+		resumeWindow:    cmp.Or(opt.ResumeWindow, DefaultResumeWindow),
+		staleCleanup:    opt.StaleCleanup,
+		identity:        identity,
+		txAudio:         opt.TXAudio,
+		tokens:          newTXTokens(opt.TXToken),
+		spectators:      opt.Spectators,
+		audit:           opt.Audit,
+		userHeader:      opt.UserHeader,
+
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  64 * 1024,
+			WriteBufferSize: 64 * 1024,
+			CheckOrigin:     opt.CheckOrigin,
+		},
+		deflate: opt.Deflate,
+	}
+
+	s.deflate.Negotiate(&s.upgrader)
+
+	if s.spectators == nil {
+		s.spectators, _ = spectate.New("") // a random key never fails
+	}
+
+	if s.wsjtx != nil {
+		s.wsjtx.Subscribe(s.publishWSJTX)
+	}
+
+	if s.antennas != nil {
+		s.antennas.Subscribe(s.publishAntennas)
+	}
+
+	if s.logbook != nil {
+		s.logbook.Subscribe(s.publishQSO)
+	}
+
+	if s.spots != nil {
+		s.spots.Subscribe(s.publishSpot)
+	}
+
+	return s
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
+	if perr := s.preflight(r); perr != nil {
+		perr.write(w, r)
+
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
@@ -97,11 +427,31 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	cs := newClientSession(s, ws, cancel, clientIP)
-	cs.trySend(mustEncode(typeVersion, versionPayload{Version: s.version}))
+	cs := newClientSession(s, ws, cancel, clientIP, peerIPFromRequest(r))
+	cs.user = s.userFor(r)
+
+	s.addSession(cs)
+	defer s.removeSession(cs)
+
+	cs.trySend(mustEncode(typeVersion, versionPayload{Version: s.version, Build: s.build, ICEServers: s.iceServers}))
+
+	if r.URL.Query().Get("sandbox") == "1" {
+		cs.setSandbox(true)
+	}
 	cs.serve(ctx)
 }
 
+// natIPs writes NAT 1:1 addresses as the ICE agent parses them, without the
+// brackets an IPv6 address is often given with.
+func natIPs(ips []string) []string {
+	out := make([]string, len(ips))
+	for i, ip := range ips {
+		out[i] = canonicalHost(strings.TrimSpace(ip))
+	}
+
+	return out
+}
+
 func summarizeMuxListeners(addrs []net.Addr) (hasUDP4, hasUDP6 bool, listeners []string) {
 	listeners = make([]string, 0, len(addrs))
 	for _, addr := range addrs {
@@ -3,15 +3,22 @@ package rtc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/daveisadork/solid-sdr/apps/server/internal/heartbeat"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/txaudio"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/version"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v4"
 )
 
@@ -24,6 +31,46 @@ const (
 	typePing               = "ping"
 	typePong               = "pong"
 	typeVersion            = "version"
+	typeCommandGuard       = "commandGuard"
+	typeCommandConfirm     = "commandConfirm"
+	typeAudioGroup         = "audioGroup"
+	typeAudioGroups        = "audioGroups"
+	typeTXEvent            = "txEvent"
+	typeShutdown           = "shutdown"
+	typeSandbox            = "sandbox"
+	typeRecording          = "recording"
+	typeWSJTX              = "wsjtx"
+	typePTT                = "ptt"
+	typeGUIClient          = "guiClient"
+	typeGUIClients         = "guiClients"
+	typeRadioHealth        = "radioHealth"
+	typeAudioRedundancy    = "audioRedundancy"
+	typeLatency            = "latency"
+	typeListen             = "listen"
+	typeStats              = "stats"
+	typeFFTWidth           = "fftWidth"
+	typeProfile            = "profile"
+	typeProfiles           = "profiles"
+	typeAntenna            = "antenna"
+	typeAntennas           = "antennas"
+	typeAmplifier          = "amplifier"
+	typeAmplifiers         = "amplifiers"
+	typeGPS                = "gps"
+	typeLogbook            = "logbook"
+	typeQSO                = "qso"
+	typeSpots              = "spots"
+	typeSpot               = "spot"
+	typeSession            = "session"
+	typeSessionState       = "sessionState"
+	typeResume             = "resume"
+	typeHistory            = "history"
+	typeMeters             = "meters"
+	typeMeterAlert         = "meterAlert"
+	typeTXToken            = "txToken"
+	typeStreamChannels     = "streamChannels"
+	typeStreams            = "streams"
+	typeMixer              = "mixer"
+	typeMixerSlices        = "mixerSlices"
 )
 
 type message struct {
@@ -37,7 +84,11 @@ type errorPayload struct {
 }
 
 type versionPayload struct {
-	Version string `json:"version"`
+	Version string        `json:"version"`
+	Build   *version.Info `json:"build,omitempty"`
+	// ICEServers are the STUN and TURN servers the bridge gathers with, for
+	// the client to gather with too.
+	ICEServers []webrtc.ICEServer `json:"iceServers,omitempty"`
 }
 
 func encode(msgType string, payload any) (message, error) {
@@ -59,29 +110,84 @@ func mustEncode(msgType string, payload any) message {
 }
 
 type clientSession struct {
+	id         string
+	createdAt  time.Time
 	srv        *Server
 	ws         *websocket.Conn
 	cancel     context.CancelFunc
 	send       chan message
-	audioTrack *webrtc.TrackLocalStaticSample
+	audioTrack *opusTrack
 	clientIP   string
+	// peerIP is the address the client connected from. Unlike clientIP,
+	// which a proxy's headers may set, it can't be forged, so the session's
+	// role goes by it.
+	peerIP string
+	role   string
+	// user is who the client authenticated as, per Options.UserHeader.
+	user string
 
 	mu    sync.Mutex
 	pc    *webrtc.PeerConnection
 	radio *radioConn
+	held  map[string]*heldCommand
+	neg   negotiation
+	// latencyProfile names the session's latency profile; empty is
+	// balanced.
+	latencyProfile string
+	// listening is the session whose RX audio this one hears, if any.
+	listening *clientSession
+	// audioSSRC is what the audio track is sent on.
+	audioSSRC webrtc.SSRC
+	// stats are the PeerConnection's latest stats.
+	stats atomic.Pointer[PeerStats]
+
+	sandbox   sandbox
+	recording recordingState
+	wsjtx     atomic.Bool // subscribed to WSJT-X events
+	ptt       pttState
+	// guiClients is set once the client has listed the radio's GUI
+	// clients, to be sent the list again as it changes.
+	guiClients atomic.Bool
+	// profiles is set once the client has listed the radio's profiles, to
+	// be sent them again as they change.
+	profiles atomic.Bool
+	// mixer is set once the client has listed the slices' audio, to be
+	// sent it again as it changes.
+	mixer    atomic.Bool
+	antennas atomic.Bool // subscribed to antenna switch changes
+	// amplifiers is set once the client has listed the radio's amplifiers,
+	// to be sent them again as they change.
+	amplifiers atomic.Bool
+	gps        atomic.Bool // subscribed to GPS changes
+	logbook    atomic.Bool // subscribed to logged QSOs
+	spots      atomic.Bool // subscribed to in-view spots
+
+	// resumeToken resumes the session after a restart, with prefs, the
+	// client's own preferences; warm is the radio connection a resume left
+	// for the "tcp" channel to take. All guarded by mu.
+	resumeToken string
+	prefs       json.RawMessage
+	warm        *warmRadio
 }
 
-func newClientSession(srv *Server, ws *websocket.Conn, cancel context.CancelFunc, clientIP string) *clientSession {
+func newClientSession(srv *Server, ws *websocket.Conn, cancel context.CancelFunc, clientIP, peerIP string) *clientSession {
 	return &clientSession{
-		srv:      srv,
-		ws:       ws,
-		cancel:   cancel,
-		send:     make(chan message, 64),
-		clientIP: clientIP,
+		id:          uuid.NewString(),
+		resumeToken: uuid.NewString(),
+		createdAt:   time.Now(),
+		srv:         srv,
+		ws:          ws,
+		cancel:      cancel,
+		send:        make(chan message, 64),
+		clientIP:    clientIP,
+		peerIP:      peerIP,
+		role:        srv.guard.RoleFor(peerIP),
 	}
 }
 
 func (cs *clientSession) serve(ctx context.Context) {
+	heartbeat.Keep(ctx, cs.ws)
+
 	var wg sync.WaitGroup
 	wg.Go(func() {
 		for {
@@ -91,9 +197,14 @@ func (cs *clientSession) serve(ctx context.Context) {
 					return
 				}
 
+				b, err := json.Marshal(msg)
+				if err != nil {
+					continue
+				}
+
 				_ = cs.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
 
-				err := cs.ws.WriteJSON(msg)
+				err = cs.srv.deflate.Write(cs.ws, websocket.TextMessage, b, true)
 				if err != nil {
 					cs.cancel()
 
@@ -142,12 +253,64 @@ func (cs *clientSession) dispatch(ctx context.Context, msg message) {
 	switch msg.Type {
 	case typeOffer:
 		cs.handleOffer(ctx, msg.Payload)
+	case typeAnswer:
+		cs.handleAnswer(msg.Payload)
 	case typeICE:
 		cs.handleICE(msg.Payload)
 	case typePing:
 		cs.trySend(mustEncode(typePong, nil))
 	case typeVersion:
 		cs.handleVersion(msg.Payload)
+	case typeCommandConfirm:
+		cs.handleCommandConfirm(msg.Payload)
+	case typeAudioGroup:
+		cs.handleAudioGroup(msg.Payload)
+	case typeSandbox:
+		cs.handleSandbox(msg.Payload)
+	case typeRecording:
+		cs.handleRecording(msg.Payload)
+	case typeWSJTX:
+		cs.handleWSJTX(msg.Payload)
+	case typePTT:
+		cs.handlePTT(msg.Payload)
+	case typeGUIClient:
+		cs.handleGUIClient(msg.Payload)
+	case typeAudioRedundancy:
+		cs.handleAudioRedundancy(msg.Payload)
+	case typeLatency:
+		cs.handleLatency(msg.Payload)
+	case typeListen:
+		cs.handleListen(msg.Payload)
+	case typeFFTWidth:
+		cs.handleFFTWidth(msg.Payload)
+	case typeProfile:
+		cs.handleProfile(msg.Payload)
+	case typeAntenna:
+		cs.handleAntenna(msg.Payload)
+	case typeAmplifier:
+		cs.handleAmplifier(msg.Payload)
+	case typeGPS:
+		cs.handleGPS(msg.Payload)
+	case typeLogbook:
+		cs.handleLogbook(msg.Payload)
+	case typeSpots:
+		cs.handleSpots(msg.Payload)
+	case typeSessionState:
+		cs.handleSessionState(msg.Payload)
+	case typeResume:
+		cs.handleResume(msg.Payload)
+	case typeHistory:
+		cs.handleHistory(msg.Payload)
+	case typeTXToken:
+		cs.handleTXToken(msg.Payload)
+	case typeMeters:
+		cs.handleMeters(msg.Payload)
+	case typeStreamChannels:
+		cs.handleStreamChannels(msg.Payload)
+	case typeStreams:
+		cs.handleStreams(msg.Payload)
+	case typeMixer:
+		cs.handleMixer(msg.Payload)
 	default:
 		log.Printf("[rtc] unknown message type: %q", msg.Type)
 	}
@@ -173,8 +336,22 @@ func (cs *clientSession) reportServerToRadioDiagnostics(
 	cs.trySend(mustEncode(typeNetworkDiagnostics, diagnostics))
 }
 
+var errRadioChanged = errors.New("the offer names a radio connection the session no longer holds")
+
+// offerPayload is an offer and, optionally, the latency profile to use.
+type offerPayload struct {
+	webrtc.SessionDescription
+
+	Latency string `json:"latency,omitempty"`
+	// Radio is the radioKey of the connection a client renegotiating
+	// already holds, as the "session" message gave it. An offer for a
+	// connection the session no longer has is refused, so the client
+	// reopens its channels instead of driving another radio's handle.
+	Radio string `json:"radio,omitempty"`
+}
+
 func (cs *clientSession) handleOffer(ctx context.Context, raw json.RawMessage) {
-	var offer webrtc.SessionDescription
+	var offer offerPayload
 
 	err := json.Unmarshal(raw, &offer)
 	if err != nil {
@@ -183,6 +360,18 @@ func (cs *clientSession) handleOffer(ctx context.Context, raw json.RawMessage) {
 		return
 	}
 
+	if _, ok := latencyProfiles[offer.Latency]; offer.Latency != "" && !ok {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: errLatencyProfile.Error()}))
+
+		return
+	}
+
+	if rc := cs.currentRadio(); offer.Radio != "" && (rc == nil || rc.key != offer.Radio) {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "RADIO_CHANGED", Message: errRadioChanged.Error()}))
+
+		return
+	}
+
 	cs.mu.Lock()
 	if cs.pc == nil {
 		pc, err := cs.srv.api.NewPeerConnection(webrtc.Configuration{ICEServers: cs.srv.iceServers})
@@ -200,32 +389,12 @@ func (cs *clientSession) handleOffer(ctx context.Context, raw json.RawMessage) {
 		cs.mu.Unlock()
 	}
 
-	err = cs.pc.SetRemoteDescription(offer)
-	if err != nil {
-		cs.trySend(mustEncode(typeError, errorPayload{Code: "SET_REMOTE_FAILED", Message: err.Error()}))
-
-		return
-	}
-
-	answer, err := cs.pc.CreateAnswer(&webrtc.AnswerOptions{
-		OfferAnswerOptions: webrtc.OfferAnswerOptions{
-			ICETricklingSupported: true,
-		},
-	})
-	if err != nil {
-		cs.trySend(mustEncode(typeError, errorPayload{Code: "ANSWER_FAILED", Message: err.Error()}))
-
-		return
-	}
-
-	err = cs.pc.SetLocalDescription(answer)
-	if err != nil {
-		cs.trySend(mustEncode(typeError, errorPayload{Code: "SET_LOCAL_FAILED", Message: err.Error()}))
-
-		return
+	if offer.Latency != "" {
+		_ = cs.setLatency(offer.Latency)
+		cs.trySend(mustEncode(typeLatency, cs.latency()))
 	}
 
-	cs.trySend(mustEncode(typeAnswer, cs.pc.LocalDescription()))
+	cs.answerOffer(offer.SessionDescription)
 }
 
 func (cs *clientSession) handleICE(raw json.RawMessage) {
@@ -247,30 +416,36 @@ func (cs *clientSession) handleICE(raw json.RawMessage) {
 	}
 
 	err = pc.AddICECandidate(candidate)
-	if err != nil {
+	if err != nil && !cs.ignoringOffer() {
 		cs.trySend(mustEncode(typeError, errorPayload{Code: "ADD_ICE_FAILED", Message: err.Error()}))
 	}
 }
 
 func (cs *clientSession) setupPeerConnection(ctx context.Context) {
-	track, err := webrtc.NewTrackLocalStaticSample(
-		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
-		"remote_audio", "remote_audio",
-	)
-	if err != nil {
-		log.Printf("[rtc] failed to create audio track: %v", err)
+	track := newOpusTrack("remote_audio", "remote_audio")
+	track.setRedundancy(cs.srv.audioRedundancy)
+	track.setFrames(cs.latency().frames)
 
-		return
-	}
-
-	_, err = cs.pc.AddTrack(track)
+	sender, err := cs.pc.AddTrack(track)
 	if err != nil {
 		log.Printf("[rtc] failed to add audio track: %v", err)
 
 		return
 	}
 
+	go track.readRTCP(sender)
+
+	cs.mu.Lock()
 	cs.audioTrack = track
+	cs.mu.Unlock()
+
+	if enc := sender.GetParameters().Encodings; len(enc) > 0 {
+		cs.mu.Lock()
+		cs.audioSSRC = enc[0].SSRC
+		cs.mu.Unlock()
+
+		go cs.collectStats(ctx, cs.pc, track, enc[0].SSRC)
+	}
 	cs.pc.OnICECandidate(func(c *webrtc.ICECandidate) {
 		if c == nil {
 			return
@@ -279,7 +454,13 @@ func (cs *clientSession) setupPeerConnection(ctx context.Context) {
 		cs.trySend(mustEncode(typeICE, c.ToJSON()))
 	})
 	cs.pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		// A client whose connection is failing may not be able to let go.
+		if state == webrtc.PeerConnectionStateDisconnected || state == webrtc.PeerConnectionStateFailed {
+			cs.releasePTT(pttReleasedConnection)
+		}
+
 		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			cs.releaseStreams()
 			cs.cancel()
 			_ = cs.pc.Close()
 		}
@@ -294,6 +475,14 @@ func (cs *clientSession) setupPeerConnection(ctx context.Context) {
 			dc.OnOpen(func() { cs.openUDP(dc) })
 		case "upload":
 			dc.OnOpen(func() { go cs.openUploadProxy(ctx, dc) })
+		case "captions":
+			dc.OnOpen(func() { cs.openCaptions(dc) })
+		case "waterfall":
+			dc.OnOpen(func() { cs.openWaterfall(dc) })
+		case "audio":
+			dc.OnOpen(func() { cs.openAudio(dc) })
+		case "cw":
+			dc.OnOpen(func() { cs.openCW(ctx, dc) })
 		case "download":
 			dc.OnOpen(func() {
 				cs.mu.Lock()
@@ -309,19 +498,19 @@ func (cs *clientSession) setupPeerConnection(ctx context.Context) {
 		}
 	})
 	cs.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
-		go cs.handleTXTrack(track)
+		go cs.handleTXTrack(ctx, track)
 	})
 }
 
 func (cs *clientSession) serveDiscovery(ctx context.Context, dc *webrtc.DataChannel) {
 	defer func() { _ = dc.Close() }()
 
-	ch := cs.srv.disco.Subscribe()
-	defer cs.srv.disco.Unsubscribe(ch)
+	feed := cs.srv.disco.Subscribe("session " + cs.id)
+	defer feed.Close()
 
 	for {
 		select {
-		case pkt, ok := <-ch:
+		case pkt, ok := <-feed.C:
 			if !ok {
 				return
 			}
@@ -337,7 +526,14 @@ func (cs *clientSession) serveDiscovery(ctx context.Context, dc *webrtc.DataChan
 }
 
 func (cs *clientSession) openTCP(ctx context.Context, dc *webrtc.DataChannel) {
-	rc, err := newRadioConn(ctx, dc, dc.Label(), cs.reportServerToRadioDiagnostics)
+	if !cs.srv.radioAllowed(dc.Label()) {
+		log.Printf("[rtc] tcp dial %q: radio not allowed", dc.Label())
+		_ = dc.Close()
+
+		return
+	}
+
+	rc, err := cs.connectRadio(ctx, dc)
 	if err != nil {
 		log.Printf("[rtc] tcp dial %q: %v", dc.Label(), err)
 		_ = dc.Close()
@@ -345,9 +541,53 @@ func (cs *clientSession) openTCP(ctx context.Context, dc *webrtc.DataChannel) {
 		return
 	}
 
+	latency := cs.latency()
+
+	rc.mu.Lock()
+	rc.recorder = cs.srv.recorder
+	rc.fair = cs.srv.fair
+	rc.flow = cs.srv.fair.join(rc.addr, cs.role)
+	rc.onStream = cs.radioStream
+	rc.onClients = func() { cs.guiClientsChanged(rc) }
+	rc.onProfiles = func() { cs.profilesChanged(rc) }
+	rc.onMixer = func() { cs.mixerChanged(rc) }
+	rc.onAmplifiers = func() { cs.amplifiersChanged(rc) }
+	rc.onGPS = func() { cs.gpsChanged(rc) }
+	rc.audio = cs.srv.radioAudio(rc.addr)
+	rc.antennas = cs.srv.antennas
+	rc.logbook = cs.srv.logbook
+	rc.history = cs.srv.history
+	rc.meterAlerts = cs.srv.alerts
+	rc.onMeterAlert = cs.reportMeterAlert
+	rc.onHealth = cs.reportRadioHealth
+	rc.timeout = cs.srv.radioTimeout
+	rc.udpBacklog, rc.udpDrop = latency.udpBacklog, latency.udpDrop
+	rc.budget = newBandwidthBudget(cs.srv.bandwidthShed, cs.audioLossSince)
+	rc.udpOptions = cs.srv.udpPipeline
+	rc.udpPorts = cs.srv.udpPorts
+	rc.mu.Unlock()
+
 	cs.mu.Lock()
 	cs.radio = rc
+	token := cs.resumeToken
 	cs.mu.Unlock()
+
+	cs.srv.bindRadio(cs, rc)
+	cs.auditRadio(rc)
+
+	cs.plumbUDP(rc)
+	cs.sendTXToken(rc.addr)
+
+	if cs.gps.Load() {
+		go func() { _ = rc.subscribeGPS() }()
+	}
+
+	if cs.srv.stateFile == "" {
+		token = ""
+	}
+
+	cs.trySend(mustEncode(typeSession, sessionPayload{ID: cs.id, ResumeToken: token, Radio: rc.key}))
+
 	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
 		cs.mu.Lock()
 		r := cs.radio
@@ -361,27 +601,41 @@ func (cs *clientSession) openTCP(ctx context.Context, dc *webrtc.DataChannel) {
 			return
 		}
 
-		r.noteOutgoingCommand(msg.Data)
+		cs.srv.debugf("[rtc] %s >> %q", r.key, msg.Data)
 
-		err := r.writeTCP(msg.Data)
+		err := cs.forwardCommand(r, msg.Data)
 		if err != nil {
-			log.Printf("[rtc] tcp write: %v", err)
-
-			_ = dc.Close()
+			cs.radioWriteFailed(dc, err)
 		}
 	})
 	dc.OnClose(func() {
+		cs.releaseStreams()
+
 		cs.mu.Lock()
 		r := cs.radio
 		cs.radio = nil
 		cs.mu.Unlock()
 
+		cs.dropHeld()
+		cs.srv.leaveTXTokens(cs)
+
 		if r != nil {
-			r.close()
+			cs.srv.unbindRadio(cs, r)
+			r.cleanup()
 		}
 	})
 }
 
+// radioWriteFailed tells the client its commands can no longer reach the radio
+// and closes the "tcp" channel, which tears the radio link down.
+func (cs *clientSession) radioWriteFailed(dc *webrtc.DataChannel, err error) {
+	log.Printf("[rtc] tcp write: %v", err)
+
+	cs.trySend(mustEncode(typeError, errorPayload{Code: "RADIO_WRITE_FAILED", Message: err.Error()}))
+
+	_ = dc.Close()
+}
+
 func (cs *clientSession) openUDP(dc *webrtc.DataChannel) {
 	cs.mu.Lock()
 	rc := cs.radio
@@ -395,81 +649,134 @@ func (cs *clientSession) openUDP(dc *webrtc.DataChannel) {
 		return
 	}
 
-	err := rc.openUDP(dc, dc.Label())
-	if err != nil {
-		log.Printf("[rtc] udp dial %q: %v", dc.Label(), err)
-		_ = dc.Close()
+	// The radio's UDP is normally plumbed with its TCP; if that failed,
+	// try again at the channel's address.
+	rc.mu.RLock()
+	plumbed := rc.udpConn != nil
+	rc.mu.RUnlock()
 
-		return
+	if !plumbed {
+		err := rc.openUDP(nil, dc.Label())
+		if err != nil {
+			log.Printf("[rtc] udp dial %q: %v", dc.Label(), err)
+			_ = dc.Close()
+
+			return
+		}
+
+		startUDPDemux(rc, cs.audioTrack)
 	}
 
 	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
-		rc.mu.RLock()
-		u := rc.udpConn
-		raddr := rc.udpRaddr
-		rc.mu.RUnlock()
-
-		if u == nil || raddr == nil || len(msg.Data) == 0 {
+		if len(msg.Data) == 0 {
 			return
 		}
 
-		_, err := u.WriteToUDP(msg.Data, raddr)
-		if err != nil {
+		err := rc.writeUDP(msg.Data)
+		if err != nil && !errors.Is(err, errNoUDP) {
 			log.Printf("[rtc] udp write: %v", err)
 
 			_ = dc.Close()
 		}
 	})
+	dc.OnClose(func() { rc.detachUDP(dc) })
+	rc.attachUDP(dc)
+}
+
+// plumbUDP registers a UDP port with the radio as soon as its TCP link is
+// up, so its meters and status flow, held for the "udp" channel, however
+// late the client opens it.
+func (cs *clientSession) plumbUDP(rc *radioConn) {
+	addr, err := radioUDPAddr(rc.addr)
+	if err == nil {
+		err = rc.openUDP(nil, addr)
+	}
+
+	if err != nil {
+		log.Printf("[rtc] session %s: radio udp: %v", cs.id, err)
+
+		return
+	}
+
 	startUDPDemux(rc, cs.audioTrack)
 }
 
-func (cs *clientSession) handleTXTrack(track *webrtc.TrackRemote) {
+func (cs *clientSession) handleTXTrack(ctx context.Context, track *webrtc.TrackRemote) {
+	chain := cs.startTXAudio(ctx)
+	if chain != nil {
+		defer chain.Close()
+	}
+
 	for {
 		packet, _, err := track.ReadRTP()
 		if err != nil {
 			return
 		}
 
-		if len(packet.Payload) == 0 {
-			continue
+		// A browser sending RED for the microphone repeats earlier packets;
+		// the radio only wants each once.
+		payload := packet.Payload
+		if strings.EqualFold(track.Codec().MimeType, mimeTypeRED) {
+			payload = redPrimary(payload)
 		}
 
-		cs.mu.Lock()
-		rc := cs.radio
-		cs.mu.Unlock()
-
-		if rc == nil {
+		if len(payload) == 0 {
 			continue
 		}
 
-		streamID, count, ok := rc.nextTXPacket()
-		if !ok {
+		if chain != nil && chain.Write(&rtp.Packet{Header: packet.Header, Payload: payload}) {
 			continue
 		}
 
-		rc.mu.RLock()
-		u := rc.udpConn
-		raddr := rc.udpRaddr
-		rc.mu.RUnlock()
-
-		if u == nil || raddr == nil {
+		rc := cs.currentRadio()
+		if rc == nil {
 			continue
 		}
 
-		pkt := buildTXOpusPacket(streamID, count, packet.Payload)
-
-		_, err = u.WriteToUDP(pkt, raddr)
-		if err != nil {
+		err = rc.sendTXAudio(payload)
+		if err != nil && !errors.Is(err, errNoUDP) {
 			return
 		}
 	}
 }
 
+// startTXAudio runs the session's TX audio through its user's processing
+// chain, if they have one, or returns nil to send it untouched.
+func (cs *clientSession) startTXAudio(ctx context.Context) *txaudio.Processor {
+	c, name, ok := cs.srv.txAudio.For(cs.user)
+	if !ok {
+		return nil
+	}
+
+	p, err := cs.srv.txAudio.Start(ctx, c, func(opus []byte) {
+		if rc := cs.currentRadio(); rc != nil {
+			_ = rc.sendTXAudio(opus)
+		}
+	})
+	if err != nil {
+		log.Printf("[rtc] session %s: tx audio profile %q: %v; sending it unprocessed", cs.id, name, err)
+
+		return nil
+	}
+
+	log.Printf("[rtc] session %s: tx audio through profile %q", cs.id, name)
+
+	return p
+}
+
 // openUploadProxy dials the radio's upload TCP port, signals the client when
 // ready, and forwards incoming data channel messages to the TCP connection.
 func (cs *clientSession) openUploadProxy(ctx context.Context, dc *webrtc.DataChannel) {
 	addr := dc.Label()
 
+	if !cs.srv.radioAllowed(addr) {
+		log.Printf("[rtc] upload dial %q: radio not allowed", addr)
+		_ = dc.SendText("error:radio not allowed")
+		_ = dc.Close()
+
+		return
+	}
+
 	dialer := net.Dialer{Timeout: 10 * time.Second}
 
 	tcp, err := dialer.DialContext(ctx, "tcp", addr)
@@ -543,6 +850,20 @@ func clientIPFromRequest(r *http.Request) string {
 	return "unknown"
 }
 
+// peerIPFromRequest is the address r came from, ignoring any forwarding
+// headers.
+func peerIPFromRequest(r *http.Request) string {
+	if r == nil {
+		return "unknown"
+	}
+
+	if ip := parsePotentialIP(r.RemoteAddr); ip != "" {
+		return ip
+	}
+
+	return "unknown"
+}
+
 func firstValidIP(raw string) string {
 	if raw == "" {
 		return ""
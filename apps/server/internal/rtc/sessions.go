@@ -0,0 +1,280 @@
+package rtc
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/tz"
+	"github.com/pion/webrtc/v4"
+)
+
+// ByteCounters reports traffic relayed for one radio connection.
+type ByteCounters struct {
+	TCPFromRadio uint64 `json:"tcpFromRadio"`
+	TCPToRadio   uint64 `json:"tcpToRadio"`
+	UDPFromRadio uint64 `json:"udpFromRadio"`
+	UDPToRadio   uint64 `json:"udpToRadio"`
+	// UDPDropped counts packets withheld from the client by the fairness
+	// scheduler, a full receive queue or backlog, or the bandwidth budget.
+	UDPDropped uint64 `json:"udpDropped,omitempty"`
+}
+
+// SessionInfo is a point-in-time snapshot of one client session.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	ClientIP  string    `json:"clientIp"`
+	User      string    `json:"user,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Clients is how many of the bridge's sessions are connected to the
+	// session's radio, this one included; 0 without a radio.
+	Clients   int    `json:"clients"`
+	PeerState string `json:"peerState"`
+	ICEState  string `json:"iceState"`
+	Radio     string `json:"radio,omitempty"`
+	Handle    string `json:"handle,omitempty"`
+	RadioKey  string `json:"radioKey,omitempty"`
+	Sandbox   bool   `json:"sandbox,omitempty"`
+	// GUIClientID is the session's client_id when it registered as a GUI
+	// client, and BoundClient the GUI client it bound to.
+	GUIClientID string `json:"guiClientId,omitempty"`
+	BoundClient string `json:"boundClient,omitempty"`
+	// RadioUnhealthy is set while the radio is not answering pings.
+	RadioUnhealthy bool `json:"radioUnhealthy,omitempty"`
+	Listeners      int  `json:"listeners,omitempty"` // WHEP players and listening sessions
+	// ListeningTo is the session whose RX audio this one hears.
+	ListeningTo string       `json:"listeningTo,omitempty"`
+	RXStream    string       `json:"rxStream,omitempty"`
+	TXStream    string       `json:"txStream,omitempty"`
+	Bytes       ByteCounters `json:"bytes"`
+	// UDP is the radio UDP receive pipeline, once it is running.
+	UDP *UDPPipelineStats `json:"udp,omitempty"`
+	// TXToken is set while the session holds its radio's TX token.
+	TXToken bool `json:"txToken,omitempty"`
+}
+
+func (s *Server) addSession(cs *clientSession) {
+	s.sessMu.Lock()
+	s.sessions[cs.id] = cs
+	s.sessMu.Unlock()
+
+	if s.events != nil {
+		s.events.Publish(EventSessionCreated, cs.info())
+	}
+}
+
+func (s *Server) removeSession(cs *clientSession) {
+	s.sessMu.Lock()
+	delete(s.sessions, cs.id)
+	for key, other := range s.byRadio {
+		if other == cs {
+			delete(s.byRadio, key)
+		}
+	}
+	s.sessMu.Unlock()
+
+	s.closeSessionWHEP(cs.id)
+	s.endListeners(cs)
+	cs.stopListening()
+	cs.stopRecording()
+	cs.releasePTT(pttReleasedClosed)
+	cs.dropWarm()
+	s.leaveTXTokens(cs)
+	s.tokens.forget(cs.id)
+
+	if s.events != nil {
+		s.events.Publish(EventSessionClosed, cs.info())
+	}
+}
+
+// session looks a session up by its ID or by the radioKey of its radio
+// connection.
+func (s *Server) session(id string) *clientSession {
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+
+	if cs, ok := s.sessions[id]; ok {
+		return cs
+	}
+
+	return s.byRadio[id]
+}
+
+// bindRadio records that cs holds the radio connection rc.
+func (s *Server) bindRadio(cs *clientSession, rc *radioConn) {
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+
+	if s.byRadio == nil {
+		s.byRadio = make(map[string]*clientSession)
+	}
+
+	s.byRadio[rc.key] = cs
+}
+
+// unbindRadio forgets that cs holds rc, once it has let go of it.
+func (s *Server) unbindRadio(cs *clientSession, rc *radioConn) {
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+
+	if s.byRadio[rc.key] == cs {
+		delete(s.byRadio, rc.key)
+	}
+}
+
+// Sessions returns a snapshot of every active session, oldest first.
+func (s *Server) Sessions() []SessionInfo {
+	all := s.sessionList()
+
+	out := make([]SessionInfo, 0, len(all))
+	for _, cs := range all {
+		out = append(out, cs.info())
+	}
+
+	slices.SortFunc(out, func(a, b SessionInfo) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return out
+}
+
+// CloseSession tears down the session's WebSocket, PeerConnection and radio
+// link. It reports false when no such session exists.
+func (s *Server) CloseSession(id string) bool {
+	cs := s.session(id)
+	if cs == nil {
+		return false
+	}
+
+	log.Printf("[rtc] closing session %s (%s) on request", id, cs.clientIP)
+	cs.cancel()
+
+	if cs.ws != nil {
+		_ = cs.ws.Close()
+	}
+
+	return true
+}
+
+// RestartICE renegotiates the session with an ICE restart, offering new
+// credentials so both ends gather and check candidates afresh. It fails
+// with ErrNoSession, or errNegotiating when the session has not connected
+// or is in the middle of another exchange.
+func (s *Server) RestartICE(id string) error {
+	cs := s.session(id)
+	if cs == nil {
+		return ErrNoSession
+	}
+
+	err := cs.sendOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[rtc] session %s: ICE restart on request", id)
+
+	return nil
+}
+
+// SetVerbose toggles per-command debug logging.
+func (s *Server) SetVerbose(on bool) { s.verbose.Store(on) }
+
+// Verbose reports whether per-command debug logging is enabled.
+func (s *Server) Verbose() bool { return s.verbose.Load() }
+
+// SetCapture toggles writing raw TCP API traffic to the api log file. It
+// reports false when no api log file is configured.
+func (s *Server) SetCapture(on bool) bool { return s.capture.setEnabled(on) }
+
+// Capture reports whether raw TCP API capture is active.
+func (s *Server) Capture() bool { return s.capture.enabled() }
+
+func (s *Server) debugf(format string, args ...any) {
+	if s.verbose.Load() {
+		log.Printf(format, args...)
+	}
+}
+
+func (cs *clientSession) info() SessionInfo {
+	cs.mu.Lock()
+	pc := cs.pc
+	rc := cs.radio
+	src := cs.listening
+	cs.mu.Unlock()
+
+	si := SessionInfo{
+		ID:        cs.id,
+		ClientIP:  cs.clientIP,
+		User:      cs.user,
+		Role:      cs.role,
+		CreatedAt: tz.In(cs.createdAt),
+		PeerState: "none",
+		ICEState:  "none",
+		Sandbox:   cs.sandbox.isEnabled(),
+		Listeners: len(cs.srv.whepListeners(cs.id)) + len(cs.srv.listeners(cs)),
+	}
+
+	if src != nil {
+		si.ListeningTo = src.id
+	}
+
+	if pc != nil {
+		si.PeerState = pc.ConnectionState().String()
+		si.ICEState = pc.ICEConnectionState().String()
+	}
+
+	if rc == nil {
+		return si
+	}
+
+	rc.mu.RLock()
+	si.Radio = rc.addr
+	si.Handle = "0x" + rc.handleHex
+	si.RadioKey = rc.key
+	si.RXStream = streamIDString(rc.activeRXStream)
+	si.TXStream = streamIDString(rc.activeTXStream)
+	si.BoundClient = rc.boundClient
+	si.RadioUnhealthy = rc.unhealthy
+	handle := rc.handleU32
+	rc.mu.RUnlock()
+
+	si.GUIClientID = rc.clients.clientID(handle)
+	si.Clients = cs.srv.radioSessions(si.Radio)
+
+	si.Bytes = rc.counters()
+	si.UDP = rc.pipeline.Load().stats()
+	si.TXToken = cs.srv.tokens != nil && cs.srv.tokens.holds(rc.addr, cs.id)
+
+	return si
+}
+
+// radioSessions counts the sessions connected to the radio at addr.
+func (s *Server) radioSessions(addr string) int {
+	n := 0
+
+	for _, cs := range s.sessionList() {
+		if rc := cs.currentRadio(); rc != nil && rc.addr == addr {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (rc *radioConn) counters() ByteCounters {
+	return ByteCounters{
+		TCPFromRadio: rc.tcpIn.Load(),
+		TCPToRadio:   rc.tcpOut.Load(),
+		UDPFromRadio: rc.udpIn.Load(),
+		UDPToRadio:   rc.udpOut.Load(),
+		UDPDropped:   rc.dropped.Load(),
+	}
+}
+
+func streamIDString(id uint32) string {
+	if id == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("0x%08X", id)
+}
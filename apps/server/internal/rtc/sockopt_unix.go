@@ -0,0 +1,26 @@
+//go:build !windows
+
+package rtc
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// receiveBuffer reports u's SO_RCVBUF, or zero when it cannot be read.
+// Linux reports twice what was set, the rest being its bookkeeping.
+func receiveBuffer(u *net.UDPConn) int {
+	rc, err := u.SyscallConn()
+	if err != nil {
+		return 0
+	}
+
+	var n int
+
+	_ = rc.Control(func(fd uintptr) {
+		n, _ = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF)
+	})
+
+	return n
+}
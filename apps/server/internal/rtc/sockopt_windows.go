@@ -0,0 +1,25 @@
+//go:build windows
+
+package rtc
+
+import (
+	"net"
+
+	"golang.org/x/sys/windows"
+)
+
+// receiveBuffer reports u's SO_RCVBUF, or zero when it cannot be read.
+func receiveBuffer(u *net.UDPConn) int {
+	rc, err := u.SyscallConn()
+	if err != nil {
+		return 0
+	}
+
+	var n int
+
+	_ = rc.Control(func(fd uintptr) {
+		n, _ = windows.GetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_RCVBUF)
+	})
+
+	return n
+}
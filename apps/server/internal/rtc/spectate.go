@@ -0,0 +1,111 @@
+package rtc
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spectate"
+)
+
+// SpectatePath serves spectator links, minted by SpectateLink, which let
+// anyone holding one hear and watch a session without controlling it:
+//
+//	/spectate/<token>/             viewer page
+//	/spectate/<token>/audio.ogg    RX audio, as at AudioPath
+//	/spectate/<token>/index.m3u8   HLS, with init.mp4 and <n>.m4s beside it
+//	/spectate/<token>/ws           panadapter, waterfall and meters, as at UDPTunnelPath
+//
+// Nothing here reaches the radio: a spectator is not a session and has no
+// command channel. Every request checks the link's expiry, and streams end
+// when it passes.
+const SpectatePath = "/spectate/"
+
+// spectatorKinds are the tunnel kinds a spectator may ask for, and gets
+// when it asks for none. Audio comes over HTTP instead.
+var spectatorKinds = []string{"fft", "waterfall", "meter"}
+
+// SpectatorLink is a minted spectator link.
+type SpectatorLink struct {
+	Session string    `json:"session"`
+	Path    string    `json:"path"`
+	Expires time.Time `json:"expires"`
+}
+
+// SpectateLink mints a link to session id that lasts ttl. It returns false
+// if there is no such session.
+func (s *Server) SpectateLink(id string, ttl time.Duration) (SpectatorLink, bool) {
+	if s.session(id) == nil {
+		return SpectatorLink{}, false
+	}
+
+	c := spectate.Claim{Session: id, Expires: time.Now().Add(ttl).Truncate(time.Second)}
+	link := SpectatorLink{Session: id, Path: SpectatePath + s.spectators.Mint(c) + "/", Expires: c.Expires}
+
+	log.Printf("[rtc] spectator link to session %s until %s", id, c.Expires.Format(time.RFC3339))
+
+	return link, true
+}
+
+// AdmitsSpectator reports whether r is for SpectatePath with a link that
+// has not expired, so that it may pass the access list.
+func (s *Server) AdmitsSpectator(r *http.Request) bool {
+	rest, ok := strings.CutPrefix(r.URL.Path, SpectatePath)
+	if !ok {
+		return false
+	}
+
+	token, _, _ := strings.Cut(rest, "/")
+	_, err := s.spectators.Verify(token, time.Now())
+
+	return err == nil
+}
+
+// ServeSpectator handles GET SpectatePath{token}/{file...}.
+func (s *Server) ServeSpectator(w http.ResponseWriter, r *http.Request) {
+	c, err := s.spectators.Verify(r.PathValue("token"), time.Now())
+	if err != nil {
+		writeHTTPError(w, http.StatusForbidden, "BAD_LINK", err.Error())
+
+		return
+	}
+
+	if s.session(c.Session) == nil {
+		writeHTTPError(w, http.StatusNotFound, "NO_SESSION", "the session has ended")
+
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(r.Context(), c.Expires)
+	defer cancel()
+
+	r = r.WithContext(ctx)
+
+	switch file := r.PathValue("file"); file {
+	case "":
+		spectate.ServePage(w, c)
+	case "audio.ogg":
+		s.serveOgg(w, r, c.Session)
+	case "ws":
+		kinds := r.URL.Query().Get("kind")
+		if kinds == "" {
+			kinds = strings.Join(spectatorKinds, ",")
+		}
+
+		for k := range strings.SplitSeq(kinds, ",") {
+			if !slices.Contains(spectatorKinds, strings.TrimSpace(k)) {
+				writeHTTPError(w, http.StatusForbidden, "BAD_KIND", "spectators may not take "+k)
+
+				return
+			}
+		}
+
+		want, _ := parseTunnelKinds(kinds) // every kind was checked above
+		s.serveTunnel(w, r, c.Session, want)
+	default:
+		s.serveHLS(w, r, c.Session, file)
+	}
+}
@@ -0,0 +1,66 @@
+package rtc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spectate"
+)
+
+func TestServeSpectator(t *testing.T) {
+	t.Parallel()
+
+	signer, err := spectate.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{sessions: map[string]*clientSession{}, spectators: signer}
+	s.addSession(&clientSession{id: "op", srv: s, radio: &radioConn{}})
+
+	if _, ok := s.SpectateLink("nope", time.Hour); ok {
+		t.Error("minted a link to no session")
+	}
+
+	link, ok := s.SpectateLink("op", time.Hour)
+	if !ok || !strings.HasPrefix(link.Path, SpectatePath) || !strings.HasSuffix(link.Path, "/") {
+		t.Fatalf("link = %+v, %v", link, ok)
+	}
+
+	expired := SpectatePath + signer.Mint(spectate.Claim{Session: "op", Expires: time.Now().Add(-time.Second)}) + "/"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+SpectatePath+"{token}/{file...}", s.ServeSpectator)
+
+	for _, tc := range []struct {
+		path  string
+		admit bool
+		want  int
+	}{
+		{link.Path, true, http.StatusOK},
+		{link.Path + "ws?kind=fft,iq", true, http.StatusForbidden},
+		{link.Path + "nope.txt", true, http.StatusNotFound},
+		{expired, false, http.StatusForbidden},
+		{SpectatePath + "forged.token/", false, http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+
+		if got := s.AdmitsSpectator(r); got != tc.admit {
+			t.Errorf("%s: admitted %v", tc.path, got)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: %d, want %d", tc.path, w.Code, tc.want)
+		}
+	}
+
+	if s.AdmitsSpectator(httptest.NewRequest(http.MethodGet, AudioPath+"op.ogg", nil)) {
+		t.Error("admitted a request outside SpectatePath")
+	}
+}
@@ -0,0 +1,104 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/spots"
+)
+
+var (
+	errSpotsDisabled = errors.New("spot feeds are not enabled")
+	errSpotsAction   = errors.New("unknown spots action")
+)
+
+// spotsRequest is a client's "spots" message: Action is "subscribe", which
+// is answered with the recent spots its radio's panadapters show and sends
+// each new one they show, or "unsubscribe". Subscribing again after moving a
+// panadapter fetches the spots now in view.
+type spotsRequest struct {
+	Action string `json:"action"`
+}
+
+// inView reports whether freqMHz is within a panadapter of client's, or of
+// no client in particular.
+func (ri *radioInfo) inView(freqMHz float64, client uint32) bool {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+
+	for pan, center := range ri.pans {
+		view := ri.views[pan]
+		if view.client != 0 && view.client != client {
+			continue
+		}
+
+		if freqMHz >= center-view.bandwidthMHz/2 && freqMHz <= center+view.bandwidthMHz/2 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shows reports whether the session's radio's panadapters show sp.
+func (cs *clientSession) shows(sp spots.Spot) bool {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	return rc != nil && rc.info.inView(sp.FreqKHz/1000, rc.handleU32)
+}
+
+// publishSpot sends a new spot to the subscribed sessions whose panadapters
+// show it.
+func (s *Server) publishSpot(sp spots.Spot) {
+	msg := mustEncode(typeSpot, sp)
+
+	for _, cs := range s.sessionList() {
+		if cs.spots.Load() && cs.shows(sp) {
+			cs.trySend(msg)
+		}
+	}
+}
+
+func (cs *clientSession) handleSpots(raw json.RawMessage) {
+	var req spotsRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	svc := cs.srv.spots
+	if svc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "SPOTS_FAILED", Message: errSpotsDisabled.Error()}))
+
+		return
+	}
+
+	switch req.Action {
+	case "subscribe":
+		cs.spots.Store(true)
+	case "unsubscribe":
+		cs.spots.Store(false)
+
+		return
+	default:
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "SPOTS_FAILED", Message: fmt.Sprintf("%v %q", errSpotsAction, req.Action)}))
+
+		return
+	}
+
+	shown := []spots.Spot{}
+
+	for _, sp := range svc.Spots() {
+		if cs.shows(sp) {
+			shown = append(shown, sp)
+		}
+	}
+
+	cs.trySend(mustEncode(typeSpots, shown))
+}
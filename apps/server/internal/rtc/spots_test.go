@@ -0,0 +1,41 @@
+package rtc
+
+import "testing"
+
+func TestRadioInfo_InView(t *testing.T) {
+	t.Parallel()
+
+	var ri radioInfo
+
+	ri.observe("display pan 0x40000000 center=14.100000 bandwidth=0.200000 client_handle=0x1234ABCD")
+	ri.observe("display pan 0x40000001 center=7.050000 bandwidth=0.100000 client_handle=0x55555555")
+
+	cases := []struct {
+		freqMHz float64
+		want    bool
+	}{
+		{14.0, true},
+		{14.025, true},
+		{14.2, true},
+		{14.201, false},
+		{7.05, false}, // another client's panadapter
+	}
+
+	for _, c := range cases {
+		if got := ri.inView(c.freqMHz, 0x1234abcd); got != c.want {
+			t.Errorf("inView(%v) = %v, want %v", c.freqMHz, got, c.want)
+		}
+	}
+
+	ri.observe("display pan 0x40000000 center=21.050000")
+
+	if !ri.inView(21.1, 0x1234abcd) || ri.inView(14.1, 0x1234abcd) {
+		t.Error("a new center keeps the bandwidth")
+	}
+
+	ri.observe("display pan 0x40000000 removed")
+
+	if ri.inView(21.05, 0x1234abcd) {
+		t.Error("a removed panadapter is still in view")
+	}
+}
@@ -0,0 +1,64 @@
+package rtc
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/status"
+)
+
+// Station reports the station for the public status page: whether a radio is
+// reachable, who is connected and what each radio in use is tuned to.
+func (s *Server) Station() status.Station {
+	var st status.Station
+
+	if s.disco != nil {
+		st.Online = len(s.disco.Registry().List(time.Now())) > 0
+	}
+
+	seen := make(map[string]bool)
+
+	for _, cs := range s.sessionList() {
+		st.Sessions++
+		st.Players += len(s.whepListeners(cs.id))
+
+		cs.mu.Lock()
+		rc := cs.radio
+		cs.mu.Unlock()
+
+		// Every session has its own connection to the radio; they all see
+		// the same slices.
+		if rc == nil || seen[rc.addr] {
+			continue
+		}
+
+		seen[rc.addr] = true
+		st.Online = true
+		st.Radios = append(st.Radios, rc.station(s.radioModel(rc.addr)))
+	}
+
+	slices.SortFunc(st.Radios, func(a, b status.Radio) int { return strings.Compare(a.Callsign, b.Callsign) })
+
+	return st
+}
+
+func (rc *radioConn) station(model string) status.Radio {
+	rc.mu.RLock()
+	state := rc.interlockState
+	rc.mu.RUnlock()
+
+	r := status.Radio{Model: model, Transmitting: state == "TRANSMITTING"}
+
+	rc.info.mu.Lock()
+	r.Callsign = rc.info.callsign
+
+	for _, s := range rc.info.slices {
+		r.Slices = append(r.Slices, status.Slice{Letter: s.letter, FreqMHz: s.freqMHz, Mode: s.mode, TX: s.tx})
+	}
+	rc.info.mu.Unlock()
+
+	slices.SortFunc(r.Slices, func(a, b status.Slice) int { return strings.Compare(a.Letter, b.Letter) })
+
+	return r
+}
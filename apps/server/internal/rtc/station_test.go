@@ -0,0 +1,31 @@
+package rtc
+
+import "testing"
+
+func TestServerStation(t *testing.T) {
+	t.Parallel()
+
+	rc := &radioConn{addr: "192.0.2.10:4992", interlockState: "TRANSMITTING"}
+	rc.info.observe("radio callsign=N0CALL")
+	rc.info.observe("slice 1 in_use=1 index_letter=B RF_frequency=7.074000 mode=DIGU tx=0")
+	rc.info.observe("slice 0 in_use=1 index_letter=A RF_frequency=14.074000 mode=USB tx=1")
+
+	srv := &Server{sessions: make(map[string]*clientSession)}
+	srv.addSession(&clientSession{id: "s1", srv: srv, radio: rc})
+	srv.addSession(&clientSession{id: "s2", srv: srv, radio: rc})
+	srv.addSession(&clientSession{id: "s3", srv: srv})
+
+	st := srv.Station()
+	if !st.Online || st.Sessions != 3 || len(st.Radios) != 1 {
+		t.Fatalf("station = %+v", st)
+	}
+
+	r := st.Radios[0]
+	if r.Callsign != "N0CALL" || !r.Transmitting || len(r.Slices) != 2 {
+		t.Fatalf("radio = %+v", r)
+	}
+
+	if s := r.Slices[0]; s.Letter != "A" || s.FreqMHz != 14.074 || s.Mode != "USB" || !s.TX {
+		t.Errorf("slice A = %+v", s)
+	}
+}
@@ -0,0 +1,207 @@
+package rtc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// statsInterval is how often a session's PeerConnection stats are collected
+// and sent to its client.
+const statsInterval = 2 * time.Second
+
+// PeerStats summarizes a session's PeerConnection for a connection-quality
+// indicator.
+type PeerStats struct {
+	At time.Time `json:"at"`
+	// RTTMs is the round trip on the selected candidate pair.
+	RTTMs float64 `json:"rttMs"`
+	// RX audio as the client reports receiving it, and TX audio as the
+	// bridge receives it.
+	RXJitterMs    float64 `json:"rxJitterMs"`
+	RXPacketsLost int64   `json:"rxPacketsLost"`
+	TXJitterMs    float64 `json:"txJitterMs"`
+	TXPacketsLost int64   `json:"txPacketsLost"`
+	// Bytes and bitrates are the selected candidate pair's, so they count
+	// data channels as well as audio. Bitrates are over the last interval.
+	BytesSent      uint64              `json:"bytesSent"`
+	BytesReceived  uint64              `json:"bytesReceived"`
+	SendBitrate    float64             `json:"sendBitrate"`
+	ReceiveBitrate float64             `json:"receiveBitrate"`
+	CandidatePair  *CandidatePairStats `json:"candidatePair,omitempty"`
+	// Bandwidth is the "udp" channel's budget, when it has one.
+	Bandwidth *BandwidthStats `json:"bandwidth,omitempty"`
+	// Clock is how the radio's timestamps are put on the wall clock, once
+	// its packets arrive.
+	Clock *ClockStats `json:"clock,omitempty"`
+}
+
+// Paths a session's media may take, from the most direct.
+const (
+	PathUDP     = "udp"
+	PathTCP     = "tcp"      // ICE-TCP to the bridge
+	PathTURNUDP = "turn-udp" // relayed, over UDP to the relay
+	PathTURNTCP = "turn-tcp" // relayed, over TCP to the relay
+	PathTURNTLS = "turn-tls" // relayed, over TLS to the relay
+	PathTURN    = "turn"     // relayed on the client's side, over what the client knows
+)
+
+// CandidatePairStats describes the candidate pair carrying a session.
+type CandidatePairStats struct {
+	// Path is one of the Path constants, for telling at a glance whether a
+	// session fell back from UDP.
+	Path   string         `json:"path"`
+	Local  CandidateStats `json:"local"`
+	Remote CandidateStats `json:"remote"`
+}
+
+// CandidateStats is one end of a candidate pair.
+type CandidateStats struct {
+	Type     string `json:"type"` // host, srflx, prflx or relay
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	// RelayProtocol is how a local relay candidate reaches its relay: udp,
+	// tcp or tls.
+	RelayProtocol string `json:"relayProtocol,omitempty"`
+}
+
+// Stats reports the session's latest PeerConnection stats, zero before its
+// PeerConnection has connected. It reports false when there is no such
+// session.
+func (s *Server) Stats(id string) (PeerStats, bool) {
+	cs := s.session(id)
+	if cs == nil {
+		return PeerStats{}, false
+	}
+
+	if st := cs.stats.Load(); st != nil {
+		return *st, true
+	}
+
+	return PeerStats{}, true
+}
+
+// collectStats summarizes pc's stats every statsInterval while it is
+// connected, keeping the latest for the admin API and sending it to the
+// client, until ctx ends. The client's reports on track, sent on ssrc, give
+// the RX audio figures, which pion's stats lack.
+func (cs *clientSession) collectStats(ctx context.Context, pc *webrtc.PeerConnection, track *opusTrack, ssrc webrtc.SSRC) {
+	t := time.NewTicker(statsInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case now := <-t.C:
+			if pc.ConnectionState() != webrtc.PeerConnectionStateConnected {
+				continue
+			}
+
+			st := summarizeStats(pc.GetStats(), cs.stats.Load(), now)
+
+			jitter, lost := track.reception(ssrc)
+			st.RXJitterMs = float64(jitter) * 1000 / opusClockRate
+			st.RXPacketsLost = int64(lost)
+
+			cs.mu.Lock()
+			rc := cs.radio
+			cs.mu.Unlock()
+
+			if rc != nil {
+				rc.mu.RLock()
+				st.Bandwidth = rc.budget.stats()
+				rc.mu.RUnlock()
+
+				st.Clock = rc.clock.stats()
+			}
+
+			cs.stats.Store(&st)
+			cs.trySend(mustEncode(typeStats, st))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// summarizeStats reduces a stats report to PeerStats, working out bitrates
+// from prev, the summary before it, when there is one.
+func summarizeStats(report webrtc.StatsReport, prev *PeerStats, now time.Time) PeerStats {
+	st := PeerStats{At: now}
+
+	var pair *webrtc.ICECandidatePairStats
+
+	for _, s := range report {
+		switch s := s.(type) {
+		case webrtc.ICECandidatePairStats:
+			if s.Nominated && s.State == webrtc.StatsICECandidatePairStateSucceeded {
+				pair = &s
+			}
+		case webrtc.InboundRTPStreamStats:
+			if s.Kind == "audio" {
+				st.TXJitterMs = s.Jitter * 1000
+				st.TXPacketsLost = int64(s.PacketsLost)
+			}
+		}
+	}
+
+	if pair == nil {
+		return st
+	}
+
+	st.RTTMs = pair.CurrentRoundTripTime * 1000
+	st.BytesSent = pair.BytesSent
+	st.BytesReceived = pair.BytesReceived
+	st.CandidatePair = &CandidatePairStats{
+		Local:  candidateStats(report, pair.LocalCandidateID),
+		Remote: candidateStats(report, pair.RemoteCandidateID),
+	}
+	st.CandidatePair.Path = st.CandidatePair.path()
+
+	if prev != nil && now.After(prev.At) && st.BytesSent >= prev.BytesSent && st.BytesReceived >= prev.BytesReceived {
+		secs := now.Sub(prev.At).Seconds()
+		st.SendBitrate = float64(st.BytesSent-prev.BytesSent) * 8 / secs
+		st.ReceiveBitrate = float64(st.BytesReceived-prev.BytesReceived) * 8 / secs
+	}
+
+	return st
+}
+
+func candidateStats(report webrtc.StatsReport, id string) CandidateStats {
+	c, ok := report[id].(webrtc.ICECandidateStats)
+	if !ok {
+		return CandidateStats{}
+	}
+
+	return CandidateStats{
+		Type:          c.CandidateType.String(),
+		Protocol:      c.Protocol,
+		Address:       net.JoinHostPort(c.IP, strconv.Itoa(int(c.Port))),
+		RelayProtocol: c.RelayProtocol,
+	}
+}
+
+// path names the way the pair's packets travel. The bridge's own relay says
+// how it is reached; the client's does not, so it is only PathTURN.
+func (p CandidatePairStats) path() string {
+	relay := webrtc.ICECandidateTypeRelay.String()
+
+	switch {
+	case p.Local.Type == relay:
+		switch p.Local.RelayProtocol {
+		case "tls":
+			return PathTURNTLS
+		case "tcp":
+			return PathTURNTCP
+		default:
+			return PathTURNUDP
+		}
+	case p.Remote.Type == relay:
+		return PathTURN
+	case p.Local.Protocol == "tcp":
+		return PathTCP
+	default:
+		return PathUDP
+	}
+}
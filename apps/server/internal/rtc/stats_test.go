@@ -0,0 +1,89 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestSummarizeStats(t *testing.T) {
+	t.Parallel()
+
+	report := webrtc.StatsReport{
+		"pair": webrtc.ICECandidatePairStats{
+			Nominated: true, State: webrtc.StatsICECandidatePairStateSucceeded,
+			LocalCandidateID: "local", RemoteCandidateID: "remote",
+			CurrentRoundTripTime: 0.042, BytesSent: 300_000, BytesReceived: 20_000,
+		},
+		"stale": webrtc.ICECandidatePairStats{State: webrtc.StatsICECandidatePairStateFailed},
+		"local": webrtc.ICECandidateStats{
+			CandidateType: webrtc.ICECandidateTypeHost, Protocol: "udp", IP: "192.0.2.1", Port: 50000,
+		},
+		"remote": webrtc.ICECandidateStats{
+			CandidateType: webrtc.ICECandidateTypeSrflx, Protocol: "udp", IP: "2001:db8::1", Port: 6000,
+		},
+		"mic": webrtc.InboundRTPStreamStats{Kind: "audio", Jitter: 0.005, PacketsLost: 3},
+	}
+
+	now := time.Now()
+	prev := &PeerStats{At: now.Add(-2 * time.Second), BytesSent: 100_000, BytesReceived: 10_000}
+
+	st := summarizeStats(report, prev, now)
+
+	if st.RTTMs != 42 || st.TXJitterMs != 5 || st.TXPacketsLost != 3 {
+		t.Errorf("rtt %v, tx jitter %v, tx lost %d", st.RTTMs, st.TXJitterMs, st.TXPacketsLost)
+	}
+
+	if st.SendBitrate != 800_000 || st.ReceiveBitrate != 40_000 {
+		t.Errorf("bitrates %v, %v", st.SendBitrate, st.ReceiveBitrate)
+	}
+
+	want := CandidatePairStats{
+		Path:  PathUDP,
+		Local:  CandidateStats{Type: "host", Protocol: "udp", Address: "192.0.2.1:50000"},
+		Remote: CandidateStats{Type: "srflx", Protocol: "udp", Address: "[2001:db8::1]:6000"},
+	}
+	if st.CandidatePair == nil || *st.CandidatePair != want {
+		t.Errorf("candidate pair %+v", st.CandidatePair)
+	}
+
+	if first := summarizeStats(report, nil, now); first.SendBitrate != 0 {
+		t.Errorf("bitrate without a previous summary: %v", first.SendBitrate)
+	}
+}
+
+func TestCandidatePairStats_Path(t *testing.T) {
+	t.Parallel()
+
+	for want, p := range map[string]CandidatePairStats{
+		PathUDP:     {Local: CandidateStats{Type: "host", Protocol: "udp"}, Remote: CandidateStats{Type: "srflx"}},
+		PathTCP:     {Local: CandidateStats{Type: "host", Protocol: "tcp"}, Remote: CandidateStats{Type: "prflx"}},
+		PathTURNTLS: {Local: CandidateStats{Type: "relay", Protocol: "udp", RelayProtocol: "tls"}},
+		PathTURNTCP: {Local: CandidateStats{Type: "relay", Protocol: "udp", RelayProtocol: "tcp"}},
+		PathTURNUDP: {Local: CandidateStats{Type: "relay", Protocol: "udp", RelayProtocol: "udp"}},
+		PathTURN:    {Local: CandidateStats{Type: "srflx", Protocol: "udp"}, Remote: CandidateStats{Type: "relay"}},
+	} {
+		if got := p.path(); got != want {
+			t.Errorf("%+v: path %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestOpusTrack_Reception(t *testing.T) {
+	t.Parallel()
+
+	track := newOpusTrack("a", "a")
+	track.bindings["pc"] = &opusBinding{ssrc: 7}
+
+	track.noteReport(rtcp.ReceptionReport{SSRC: 7, Jitter: 240, TotalLost: 5})
+
+	if jitter, lost := track.reception(7); jitter != 240 || lost != 5 {
+		t.Errorf("reception = %d, %d", jitter, lost)
+	}
+
+	if jitter, lost := track.reception(8); jitter != 0 || lost != 0 {
+		t.Errorf("unknown listener: %d, %d", jitter, lost)
+	}
+}
@@ -0,0 +1,203 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// Streams that may be given data channels of their own, apart from the
+// client's "udp" channel, whose ordering and retransmission the bridge
+// cannot choose.
+const (
+	StreamFFT       = "fft"
+	StreamWaterfall = "waterfall"
+	StreamMeters    = "meters"
+	StreamIQ        = "iq"
+)
+
+// How a stream's channel delivers.
+const (
+	// ChannelReliable is ordered and retransmitted until delivered, so a
+	// recording of IQ has no gaps.
+	ChannelReliable = "reliable"
+	// ChannelUnordered is retransmitted, but a loss holds up nothing behind
+	// it.
+	ChannelUnordered = "unordered"
+	// ChannelLossy is unordered and never retransmitted: a lost panadapter
+	// frame is better skipped than late.
+	ChannelLossy = "lossy"
+	// ChannelShared leaves the stream on the "udp" channel.
+	ChannelShared = "udp"
+)
+
+// streamChannelProtocol is the protocol of the channels the bridge opens;
+// each is labelled with its stream.
+const streamChannelProtocol = "stream"
+
+// DefaultStreamChannels is how each stream's channel delivers unless
+// Options.StreamChannels says otherwise.
+var DefaultStreamChannels = map[string]string{
+	StreamFFT:       ChannelLossy,
+	StreamWaterfall: ChannelLossy,
+	StreamMeters:    ChannelLossy,
+	StreamIQ:        ChannelReliable,
+}
+
+var errStreamChannels = errors.New("stream channels need a WebRTC session")
+
+// streamChannelsPayload asks for channels of the named streams, or of every
+// stream with one when empty. The answer lists the channels opened.
+type streamChannelsPayload struct {
+	Streams  []string        `json:"streams,omitempty"`
+	Channels []streamChannel `json:"channels,omitempty"`
+}
+
+// streamChannel is one channel the bridge opened, as the client sees it.
+type streamChannel struct {
+	Stream         string  `json:"stream"`
+	Ordered        bool    `json:"ordered"`
+	MaxRetransmits *uint16 `json:"maxRetransmits,omitempty"`
+}
+
+// streamChannelInit is how a channel delivering as mode is created, or false
+// for ChannelShared or a mode it does not know.
+func streamChannelInit(mode string) (webrtc.DataChannelInit, bool) {
+	ordered := true
+	none := uint16(0)
+
+	switch mode {
+	case ChannelReliable:
+		return webrtc.DataChannelInit{Ordered: &ordered}, true
+	case ChannelUnordered:
+		ordered = false
+
+		return webrtc.DataChannelInit{Ordered: &ordered}, true
+	case ChannelLossy:
+		ordered = false
+
+		return webrtc.DataChannelInit{Ordered: &ordered, MaxRetransmits: &none}, true
+	default:
+		return webrtc.DataChannelInit{}, false
+	}
+}
+
+// streamChannels resolves opt over DefaultStreamChannels, leaving out the
+// streams that stay on the "udp" channel.
+func streamChannels(opt map[string]string) map[string]webrtc.DataChannelInit {
+	out := make(map[string]webrtc.DataChannelInit)
+
+	for stream, mode := range DefaultStreamChannels {
+		if m, ok := opt[stream]; ok {
+			mode = m
+		}
+
+		if dcInit, ok := streamChannelInit(mode); ok {
+			out[stream] = dcInit
+		}
+	}
+
+	return out
+}
+
+// streamOf is the stream a packet belongs to, or "" for one that only ever
+// goes on the "udp" channel.
+func streamOf(v vitaView) string {
+	switch {
+	case v.ClassCode == vitaFFTClass:
+		return StreamFFT
+	case v.ClassCode == vitaWaterfallClass:
+		return StreamWaterfall
+	case v.ClassCode == vitaMeterClass:
+		return StreamMeters
+	case iqSampleRate(v.ClassCode) != 0:
+		return StreamIQ
+	default:
+		return ""
+	}
+}
+
+// handleStreamChannels opens a channel for each stream asked for, and
+// answers with those it opened. The radio's packets move to a channel once
+// it is open, and back to "udp" if it closes.
+func (cs *clientSession) handleStreamChannels(raw json.RawMessage) {
+	var p streamChannelsPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	if cs.pc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: errStreamChannels.Error()}))
+
+		return
+	}
+
+	var opened []streamChannel
+
+	for stream, dcInit := range cs.srv.streamChannels {
+		if len(p.Streams) > 0 && !slices.Contains(p.Streams, stream) {
+			continue
+		}
+
+		protocol := streamChannelProtocol
+		dcInit.Protocol = &protocol
+
+		dc, err := cs.pc.CreateDataChannel(stream, &dcInit)
+		if err != nil {
+			log.Printf("[rtc] session %s: %s channel: %v", cs.id, stream, err)
+
+			continue
+		}
+
+		dc.OnOpen(func() { cs.attachStream(stream, dc) })
+
+		opened = append(opened, streamChannel{Stream: stream, Ordered: *dcInit.Ordered, MaxRetransmits: dcInit.MaxRetransmits})
+	}
+
+	slices.SortFunc(opened, func(a, b streamChannel) int { return strings.Compare(a.Stream, b.Stream) })
+
+	cs.trySend(mustEncode(typeStreamChannels, streamChannelsPayload{Channels: opened}))
+}
+
+// attachStream sends stream's packets on dc from now on, until it closes.
+func (cs *clientSession) attachStream(stream string, dc *webrtc.DataChannel) {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		_ = dc.Close()
+
+		return
+	}
+
+	rc.mu.Lock()
+	if rc.streamDCs == nil {
+		rc.streamDCs = make(map[string]*webrtc.DataChannel)
+	}
+
+	old := rc.streamDCs[stream]
+	rc.streamDCs[stream] = dc
+	rc.mu.Unlock()
+
+	// Asked for again, the channel opened before is done with.
+	if old != nil {
+		_ = old.Close()
+	}
+
+	dc.OnClose(func() {
+		rc.mu.Lock()
+		if rc.streamDCs[stream] == dc {
+			delete(rc.streamDCs, stream)
+		}
+		rc.mu.Unlock()
+	})
+}
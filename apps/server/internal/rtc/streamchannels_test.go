@@ -0,0 +1,80 @@
+package rtc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStreamChannels_OverDefaults(t *testing.T) {
+	t.Parallel()
+
+	got := streamChannels(map[string]string{StreamFFT: ChannelShared, StreamIQ: ChannelUnordered})
+
+	if _, ok := got[StreamFFT]; ok {
+		t.Error("fft has a channel though set to stay on udp")
+	}
+
+	if c := got[StreamIQ]; c.Ordered == nil || *c.Ordered || c.MaxRetransmits != nil {
+		t.Errorf("iq = %+v, want unordered and reliable", c)
+	}
+
+	if c := got[StreamMeters]; c.Ordered == nil || *c.Ordered || c.MaxRetransmits == nil || *c.MaxRetransmits != 0 {
+		t.Errorf("meters = %+v, want the lossy default", c)
+	}
+}
+
+func TestStreamOf(t *testing.T) {
+	t.Parallel()
+
+	cases := map[uint16]string{
+		vitaFFTClass:       StreamFFT,
+		vitaWaterfallClass: StreamWaterfall,
+		vitaMeterClass:     StreamMeters,
+		0x02E4:             StreamIQ,
+		0x8005:             "", // Opus audio
+	}
+	for class, want := range cases {
+		if got := streamOf(vitaView{ClassCode: class}); got != want {
+			t.Errorf("streamOf(%#x) = %q, want %q", class, got, want)
+		}
+	}
+}
+
+func TestHandleStreamChannels_OpensThoseAskedFor(t *testing.T) {
+	t.Parallel()
+
+	cs, _ := negotiationPair(t)
+	cs.srv = &Server{streamChannels: streamChannels(nil)}
+
+	cs.handleStreamChannels(json.RawMessage(`{"streams":["iq","fft"]}`))
+
+	var p streamChannelsPayload
+
+	select {
+	case msg := <-cs.send:
+		if msg.Type != typeStreamChannels {
+			t.Fatalf("got %s %s", msg.Type, msg.Payload)
+		}
+
+		err := json.Unmarshal(msg.Payload, &p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer")
+	}
+
+	if len(p.Channels) != 2 {
+		t.Fatalf("channels = %+v", p.Channels)
+	}
+
+	fft, iq := p.Channels[0], p.Channels[1]
+	if fft.Stream != StreamFFT || fft.Ordered || fft.MaxRetransmits == nil || *fft.MaxRetransmits != 0 {
+		t.Errorf("fft = %+v", fft)
+	}
+
+	if iq.Stream != StreamIQ || !iq.Ordered || iq.MaxRetransmits != nil {
+		t.Errorf("iq = %+v", iq)
+	}
+}
@@ -0,0 +1,371 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// internalStreamSequence tags the stream commands the bridge sends for a
+// session's "streams" subscription, so their replies are consumed instead of
+// reaching the browser.
+const internalStreamSequence = 2147483638
+
+// Subscription keys: RX audio, and "dax:<n>" or "daxiq:<n>" for a DAX audio
+// or IQ channel.
+const (
+	subAudio = "audio"
+	subDAX   = "dax:"
+	subDAXIQ = "daxiq:"
+)
+
+// maxDAXChannel is the highest DAX audio or IQ channel.
+const maxDAXChannel = 8
+
+var (
+	errStreamsNoRadio = errors.New("no radio connection")
+	errDAXChannel     = fmt.Errorf("DAX channels are 1 to %d", maxDAXChannel)
+	errStreamRefused  = errors.New("radio refused")
+	errStreamTimeout  = errors.New("radio did not answer")
+)
+
+// streamsPayload declares every stream a session wants. The bridge creates
+// those it lacks, removes those no longer wanted that nothing else holds,
+// and answers with the radio connection's subscriptions.
+type streamsPayload struct {
+	Audio bool  `json:"audio,omitempty"`
+	DAX   []int `json:"dax,omitempty"`
+	DAXIQ []int `json:"daxIq,omitempty"`
+	// Pans sets panadapters' frame rate and size; they belong to the
+	// client's slices, so they are set but never removed.
+	Pans []panSettings `json:"pans,omitempty"`
+
+	Subscribed []subscribedStream `json:"subscribed,omitempty"`
+}
+
+type panSettings struct {
+	ID     string `json:"id"`
+	FPS    int    `json:"fps,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// subscribedStream is a stream the bridge created and who holds it.
+type subscribedStream struct {
+	Key     string `json:"key"`
+	Stream  string `json:"stream"`
+	Holders int    `json:"holders"`
+}
+
+// streamSubs tracks the streams the bridge created on a radio connection
+// and what holds each: the session that declared it, and the sessions
+// listening to its audio. A stream is removed when its last holder lets go,
+// so none outlives every client that wanted it.
+type streamSubs struct {
+	mu   sync.Mutex
+	subs map[string]*streamSub
+}
+
+type streamSub struct {
+	id      uint32
+	holders map[string]bool
+}
+
+// plan moves holder's holds to want, returning the keys to create and the
+// streams to remove.
+func (s *streamSubs) plan(holder string, want []string) (create []string, remove []uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, sub := range s.subs {
+		if sub.holders[holder] && !slices.Contains(want, key) {
+			delete(sub.holders, holder)
+
+			if len(sub.holders) == 0 {
+				delete(s.subs, key)
+				remove = append(remove, sub.id)
+			}
+		}
+	}
+
+	for _, key := range want {
+		if sub, ok := s.subs[key]; ok {
+			sub.holders[holder] = true
+		} else {
+			create = append(create, key)
+		}
+	}
+
+	return create, remove
+}
+
+// created records the stream the radio made for key on holder's behalf.
+func (s *streamSubs) created(key, holder string, id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subs == nil {
+		s.subs = make(map[string]*streamSub)
+	}
+
+	s.subs[key] = &streamSub{id: id, holders: map[string]bool{holder: true}}
+}
+
+// hold adds holder to key's stream, if there is one.
+func (s *streamSubs) hold(key, holder string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sub, ok := s.subs[key]; ok {
+		sub.holders[holder] = true
+	}
+}
+
+// release lets go of everything holder holds, returning the streams nothing
+// holds any more.
+func (s *streamSubs) release(holder string) []uint32 {
+	_, remove := s.plan(holder, nil)
+
+	return remove
+}
+
+func (s *streamSubs) list() []subscribedStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]subscribedStream, 0, len(s.subs))
+	for key, sub := range s.subs {
+		out = append(out, subscribedStream{Key: key, Stream: fmt.Sprintf("0x%08X", sub.id), Holders: len(sub.holders)})
+	}
+
+	slices.SortFunc(out, func(a, b subscribedStream) int { return strings.Compare(a.Key, b.Key) })
+
+	return out
+}
+
+// streamKeys are the subscription keys p declares.
+func streamKeys(p streamsPayload) ([]string, error) {
+	var keys []string
+
+	if p.Audio {
+		keys = append(keys, subAudio)
+	}
+
+	for _, set := range []struct {
+		prefix   string
+		channels []int
+	}{{subDAX, p.DAX}, {subDAXIQ, p.DAXIQ}} {
+		for _, ch := range set.channels {
+			if ch < 1 || ch > maxDAXChannel {
+				return nil, fmt.Errorf("%w: %d", errDAXChannel, ch)
+			}
+
+			keys = append(keys, set.prefix+strconv.Itoa(ch))
+		}
+	}
+
+	return keys, nil
+}
+
+// createCommand is the radio command that creates key's stream.
+func createCommand(key string) string {
+	if ch, ok := strings.CutPrefix(key, subDAXIQ); ok {
+		return "stream create type=dax_iq daxiq_channel=" + ch
+	}
+
+	if ch, ok := strings.CutPrefix(key, subDAX); ok {
+		return "stream create type=dax_rx dax_channel=" + ch
+	}
+
+	return "stream create type=remote_audio_rx compression=" + compressionOPUS
+}
+
+// panCommand is the radio command that applies p, or "" if it sets nothing.
+func panCommand(p panSettings) string {
+	id := parseHex32(p.ID)
+	if id == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	for _, set := range []struct {
+		name  string
+		value int
+	}{{"fps", p.FPS}, {"xpixels", p.Width}, {"ypixels", p.Height}} {
+		if set.value > 0 {
+			fmt.Fprintf(&b, " %s=%d", set.name, set.value)
+		}
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("display pan set 0x%08X%s", id, b.String())
+}
+
+// streamCommand sends one stream command and returns the message of the
+// radio's reply, such as a new stream's ID.
+func (rc *radioConn) streamCommand(body string) (string, error) {
+	rc.streamMu.Lock()
+	defer rc.streamMu.Unlock()
+
+	reply := make(chan string, 1)
+
+	rc.mu.Lock()
+	rc.streamReply = reply
+	rc.mu.Unlock()
+
+	defer func() {
+		rc.mu.Lock()
+		rc.streamReply = nil
+		rc.mu.Unlock()
+	}()
+
+	err := rc.writeTCPString(fmt.Sprintf("C%d|%s\n", internalStreamSequence, body))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", body, err)
+	}
+
+	select {
+	case res := <-reply:
+		// R<seq>|<code>|<message>
+		parts := strings.SplitN(res, "|", 3)
+		if len(parts) < 2 || strings.TrimLeft(parts[1], "0") != "" {
+			return "", fmt.Errorf("%s: %w: %q", body, errStreamRefused, res)
+		}
+
+		if len(parts) == 3 {
+			return parts[2], nil
+		}
+
+		return "", nil
+	case <-time.After(clientReplyTimeout):
+		return "", fmt.Errorf("%s: %w", body, errStreamTimeout)
+	}
+}
+
+// consumeStreamReply hands the reply to a stream command to its waiter.
+func (rc *radioConn) consumeStreamReply(line string) bool {
+	if !strings.HasPrefix(line, fmt.Sprintf("R%d|", internalStreamSequence)) {
+		return false
+	}
+
+	rc.mu.RLock()
+	reply := rc.streamReply
+	rc.mu.RUnlock()
+
+	if reply != nil {
+		select {
+		case reply <- line:
+		default:
+		}
+	}
+
+	return true
+}
+
+// removeStreams removes streams from the radio without waiting on it.
+func (rc *radioConn) removeStreams(ids []uint32) {
+	rc.mu.Lock()
+	for _, id := range ids {
+		delete(rc.owned, id)
+	}
+	rc.mu.Unlock()
+
+	for _, id := range ids {
+		err := rc.writeTCPString(fmt.Sprintf("C%d|stream remove 0x%08X\n", internalStreamSequence, id))
+		if err != nil {
+			log.Printf("[rtc] %s: stream remove: %v", rc.key, err)
+
+			return
+		}
+	}
+}
+
+// handleStreams applies a session's declaration of the streams it wants.
+func (cs *clientSession) handleStreams(raw json.RawMessage) {
+	var p streamsPayload
+
+	err := json.Unmarshal(raw, &p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	keys, err := streamKeys(p)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	rc := cs.currentRadio()
+	if rc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "NO_RADIO", Message: errStreamsNoRadio.Error()}))
+
+		return
+	}
+
+	create, remove := rc.subs.plan(cs.id, keys)
+	rc.removeStreams(remove)
+
+	for _, key := range create {
+		msg, err := rc.streamCommand(createCommand(key))
+
+		id := parseHex32(strings.TrimSpace(msg))
+		if err == nil && id == 0 {
+			err = fmt.Errorf("%s: %w: no stream ID", key, errStreamRefused)
+		}
+
+		if err != nil {
+			cs.trySend(mustEncode(typeError, errorPayload{Code: "STREAM_FAILED", Message: err.Error()}))
+
+			continue
+		}
+
+		rc.subs.created(key, cs.id, id)
+	}
+
+	for _, pan := range p.Pans {
+		cmd := panCommand(pan)
+		if cmd == "" {
+			continue
+		}
+
+		_, err := rc.streamCommand(cmd)
+		if err != nil {
+			cs.trySend(mustEncode(typeError, errorPayload{Code: "STREAM_FAILED", Message: err.Error()}))
+		}
+	}
+
+	cs.trySend(mustEncode(typeStreams, streamsPayload{Subscribed: rc.subs.list()}))
+}
+
+// releaseStreams lets go of the streams cs declared, removing those nothing
+// else holds, as its client is gone.
+func (cs *clientSession) releaseStreams() {
+	if rc := cs.currentRadio(); rc != nil {
+		rc.removeStreams(rc.subs.release(cs.id))
+	}
+}
+
+// listenerHolder is what a session listening to another holds that
+// session's RX audio stream as.
+func listenerHolder(cs *clientSession) string { return "listen:" + cs.id }
+
+// currentRadio is cs's radio connection, or nil.
+func (cs *clientSession) currentRadio() *radioConn {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.radio
+}
@@ -0,0 +1,212 @@
+package rtc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStreamSubs(t *testing.T) {
+	t.Parallel()
+
+	var s streamSubs
+
+	create, remove := s.plan("a", []string{subAudio, "dax:1"})
+	if !slices.Equal(create, []string{subAudio, "dax:1"}) || len(remove) != 0 {
+		t.Fatalf("first plan = %v, %v", create, remove)
+	}
+
+	s.created(subAudio, "a", 1)
+	s.created("dax:1", "a", 2)
+
+	// b shares a's audio; only its DAX channel is new.
+	create, _ = s.plan("b", []string{subAudio, "dax:2"})
+	if !slices.Equal(create, []string{"dax:2"}) {
+		t.Fatalf("second plan creates %v", create)
+	}
+
+	s.created("dax:2", "b", 3)
+	s.hold(subAudio, "listen:c")
+
+	// a no longer wants audio, which b still holds.
+	_, remove = s.plan("a", []string{"dax:1"})
+	if len(remove) != 0 {
+		t.Errorf("removed %v while held", remove)
+	}
+
+	if got := s.release("b"); !slices.Equal(got, []uint32{3}) {
+		t.Errorf("releasing b removes %v", got)
+	}
+
+	// The listener alone keeps audio up until it goes too.
+	if got := s.release("listen:c"); !slices.Equal(got, []uint32{1}) {
+		t.Errorf("releasing the listener removes %v", got)
+	}
+
+	if l := s.list(); len(l) != 1 || l[0] != (subscribedStream{"dax:1", "0x00000002", 1}) {
+		t.Errorf("list = %+v", l)
+	}
+}
+
+func TestStreamCommands(t *testing.T) {
+	t.Parallel()
+
+	keys, err := streamKeys(streamsPayload{Audio: true, DAX: []int{2}, DAXIQ: []int{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cmds []string
+	for _, k := range keys {
+		cmds = append(cmds, createCommand(k))
+	}
+
+	want := []string{
+		"stream create type=remote_audio_rx compression=OPUS",
+		"stream create type=dax_rx dax_channel=2",
+		"stream create type=dax_iq daxiq_channel=1",
+	}
+	if !slices.Equal(cmds, want) {
+		t.Errorf("commands = %q", cmds)
+	}
+
+	if _, err := streamKeys(streamsPayload{DAX: []int{9}}); err == nil {
+		t.Error("DAX channel 9 accepted")
+	}
+
+	for _, tc := range []struct {
+		pan  panSettings
+		want string
+	}{
+		{panSettings{ID: "0x40000000", FPS: 25, Width: 1024}, "display pan set 0x40000000 fps=25 xpixels=1024"},
+		{panSettings{ID: "0x40000000"}, ""},
+		{panSettings{ID: "pan", FPS: 25}, ""},
+	} {
+		if got := panCommand(tc.pan); got != tc.want {
+			t.Errorf("panCommand(%+v) = %q, want %q", tc.pan, got, tc.want)
+		}
+	}
+}
+
+// streamRadio is a radio that answers each "stream create" with the next
+// stream ID.
+func streamRadio(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	got := make(chan string, 16)
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = c.Close() }()
+
+		_, _ = c.Write([]byte("V1.4.0.0\nH1234ABCD\n"))
+
+		next := uint32(0x04000000)
+		rd := bufio.NewReader(c)
+
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimSpace(line)
+			if strings.Contains(line, "|ping ") || strings.Contains(line, "|keepalive ") {
+				continue
+			}
+
+			got <- line
+
+			seq, body, _ := strings.Cut(strings.TrimPrefix(line, "C"), "|")
+
+			msg := ""
+			if strings.HasPrefix(body, "stream create ") {
+				next++
+				msg = fmt.Sprintf("%08X", next)
+			}
+
+			_, _ = fmt.Fprintf(c, "R%s|0|%s\n", seq, msg)
+		}
+	}()
+
+	return ln.Addr().String(), got
+}
+
+func TestHeadless_Streams(t *testing.T) {
+	t.Parallel()
+
+	addr, got := streamRadio(t)
+	srv := &Server{sessions: make(map[string]*clientSession)}
+
+	h, err := srv.OpenHeadless(t.Context(), HeadlessOptions{Radio: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = h.Signal(typeStreams, []byte(`{"audio":true,"dax":[1],"pans":[{"id":"0x40000000","fps":10}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"C2147483638|stream create type=remote_audio_rx compression=OPUS",
+		"C2147483638|stream create type=dax_rx dax_channel=1",
+		"C2147483638|display pan set 0x40000000 fps=10",
+	} {
+		if l := nextLine(t, got); l != want {
+			t.Errorf("radio got %q, want %q", l, want)
+		}
+	}
+
+	for n := range h.Notices() {
+		if n.Type != typeStreams {
+			continue
+		}
+
+		var p streamsPayload
+
+		_ = json.Unmarshal(n.Payload, &p)
+		if len(p.Subscribed) != 2 || p.Subscribed[0].Key != subAudio || p.Subscribed[0].Stream != "0x04000001" {
+			t.Errorf("subscribed = %+v", p.Subscribed)
+		}
+
+		break
+	}
+
+	// Dropping DAX removes its stream.
+	err = h.Signal(typeStreams, []byte(`{"audio":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, got); l != "C2147483638|stream remove 0x04000002" {
+		t.Errorf("radio got %q", l)
+	}
+
+	// Closing removes what the session still holds before letting go.
+	h.Close()
+
+	for _, want := range []string{
+		"C2147483638|stream remove 0x04000001",
+		"C2147483645|client disconnect 0x1234ABCD",
+	} {
+		if l := nextLine(t, got); l != want {
+			t.Errorf("radio got %q on close, want %q", l, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// tcpQueueLen bounds the commands waiting for the radio. A radio this far
+	// behind is not reading; failing the link beats growing the queue forever.
+	tcpQueueLen = 1024
+	// tcpWriteTimeout is how long one write may take before the link is
+	// considered dead.
+	tcpWriteTimeout = 5 * time.Second
+	// tcpCoalesce is the most queued data joined into a single write.
+	tcpCoalesce = 16 * 1024
+)
+
+var errTCPQueueFull = errors.New("radio command queue is full")
+
+// tcpWriter is the only writer to a radio's API connection. Client commands,
+// approved guard holds and the bridge's own pings and audio-group commands are
+// written in the order they were queued, by one goroutine that joins whatever
+// is waiting into a single write, so no caller ever blocks on the radio.
+type tcpWriter struct {
+	conn    net.Conn
+	written func(cmd []byte) // after each command reaches the socket
+	failed  func(err error)  // once, when a write fails
+
+	mu    sync.Mutex
+	queue chan []byte
+	err   error // why the writer stopped; set once
+	done  chan struct{}
+}
+
+func newTCPWriter(conn net.Conn, written func([]byte), failed func(error)) *tcpWriter {
+	w := &tcpWriter{
+		conn:    conn,
+		written: written,
+		failed:  failed,
+		queue:   make(chan []byte, tcpQueueLen),
+		done:    make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// write queues data. It fails only if the writer has stopped or the queue is
+// full; errors writing to the socket are reported through failed instead.
+func (w *tcpWriter) write(data []byte) error {
+	if w == nil {
+		return net.ErrClosed
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	select {
+	case w.queue <- append([]byte(nil), data...):
+		return nil
+	default:
+		return errTCPQueueFull
+	}
+}
+
+// close stops accepting commands and waits until those already queued have
+// been written, so a final "client disconnect" still reaches the radio.
+func (w *tcpWriter) close() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = net.ErrClosed
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+func (w *tcpWriter) run() {
+	defer close(w.done)
+
+	var buf []byte
+
+	for cmd := range w.queue {
+		batch := [][]byte{cmd}
+		buf = append(buf[:0], cmd...)
+
+	fill:
+		for len(buf) < tcpCoalesce {
+			select {
+			case more, ok := <-w.queue:
+				if !ok {
+					break fill
+				}
+
+				batch = append(batch, more)
+				buf = append(buf, more...)
+			default:
+				break fill
+			}
+		}
+
+		_ = w.conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+
+		_, err := w.conn.Write(buf)
+		if err != nil {
+			w.fail(fmt.Errorf("write to radio: %w", err))
+
+			return
+		}
+
+		for _, c := range batch {
+			w.written(c)
+		}
+	}
+}
+
+// fail stops the writer and closes the connection, which ends the reader too.
+func (w *tcpWriter) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+
+	_ = w.conn.Close()
+
+	if w.failed != nil {
+		// Not inline: the handler closes the radio link, which waits for us.
+		go w.failed(err)
+	}
+}
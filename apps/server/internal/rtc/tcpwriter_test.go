@@ -0,0 +1,126 @@
+package rtc
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTCPWriter_OrderAndCoalesce(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+	t.Cleanup(func() { _ = radio.Close() })
+
+	var (
+		mu      sync.Mutex
+		written []string
+	)
+
+	w := newTCPWriter(client, func(b []byte) {
+		mu.Lock()
+		written = append(written, string(b))
+		mu.Unlock()
+	}, nil)
+
+	// net.Pipe is unbuffered, so the first write blocks until the radio
+	// reads and everything queued meanwhile goes out in one write.
+	for i := range 5 {
+		err := w.write([]byte("C" + string(rune('0'+i)) + "|info\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := make([]byte, 1024)
+
+	var got strings.Builder
+
+	for got.Len() < 5*len("C0|info\n") {
+		n, err := radio.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got.Write(buf[:n])
+	}
+
+	want := "C0|info\nC1|info\nC2|info\nC3|info\nC4|info\n"
+	if got.String() != want {
+		t.Errorf("radio read %q, want %q", got.String(), want)
+	}
+
+	go func() { _, _ = io.Copy(io.Discard, radio) }()
+
+	w.close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(written) != 5 || written[0] != "C0|info\n" || written[4] != "C4|info\n" {
+		t.Errorf("written = %q", written)
+	}
+}
+
+func TestTCPWriter_CloseFlushes(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+
+	w := newTCPWriter(client, func([]byte) {}, nil)
+
+	read := make(chan string)
+
+	go func() {
+		b, _ := io.ReadAll(radio)
+		read <- string(b)
+	}()
+
+	_ = w.write([]byte("C1|client disconnect 0x1234\n"))
+	w.close()
+	_ = client.Close()
+
+	if got := <-read; got != "C1|client disconnect 0x1234\n" {
+		t.Errorf("radio read %q", got)
+	}
+
+	err := w.write([]byte("C2|info\n"))
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("write after close: %v", err)
+	}
+}
+
+func TestTCPWriter_Failure(t *testing.T) {
+	t.Parallel()
+
+	client, radio := net.Pipe()
+	_ = radio.Close()
+
+	failed := make(chan error, 1)
+	w := newTCPWriter(client, func([]byte) {}, func(err error) { failed <- err })
+
+	err := w.write([]byte("C1|info\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Fatal("failure reported without an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write failure was not reported")
+	}
+
+	err = w.write([]byte("C2|info\n"))
+	if err == nil {
+		t.Error("write accepted after the link failed")
+	}
+
+	w.close()
+}
@@ -0,0 +1,112 @@
+package rtc
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+)
+
+// reXmitCmd matches an xmit command's body as the guard does, in any case and
+// spacing.
+var reXmitCmd = regexp.MustCompile(`(?i)^xmit\s+[01]$`)
+
+// txEventPayload reports an interlock transition to the client. AtUs is the
+// bridge's wall clock in microseconds when the status line was read, and
+// ReasonText explains why transmit is inhibited.
+type txEventPayload struct {
+	AtUs       int64  `json:"atUs"`
+	ReasonText string `json:"reasonText,omitempty"`
+	guard.TXTransition
+}
+
+// noteXmitRequest remembers when an xmit command was forwarded so the next
+// interlock transition can report the radio's keying latency.
+func (rc *radioConn) noteXmitRequest(data []byte, now time.Time) {
+	for line := range strings.SplitSeq(string(data), "\n") {
+		_, body, ok := guard.SplitCommand(line)
+		if !ok || !reXmitCmd.MatchString(body) {
+			continue
+		}
+
+		rc.mu.Lock()
+		rc.xmitRequestedAt = now
+		rc.mu.Unlock()
+
+		return
+	}
+}
+
+// noteInterlock timestamps an "interlock ..." status body. now should be taken
+// as soon as the line is read so queuing in the bridge does not skew it.
+func (rc *radioConn) noteInterlock(body string, now time.Time) {
+	attrs, ok := strings.CutPrefix(body, "interlock ")
+	if !ok {
+		return
+	}
+
+	state := extractString(attrs, "state=")
+	if state == "" {
+		return
+	}
+
+	rc.mu.Lock()
+
+	prev, prevAt := rc.interlockState, rc.interlockAt
+	if state == prev {
+		rc.mu.Unlock()
+
+		return
+	}
+
+	rc.interlockState, rc.interlockAt = state, now
+
+	t := guard.TXTransition{
+		At:     now,
+		Radio:  rc.addr,
+		State:  state,
+		Prev:   prev,
+		Reason: extractString(attrs, "reason="),
+		Source: extractString(attrs, "source="),
+	}
+
+	if !prevAt.IsZero() {
+		t.SincePrevUs = now.Sub(prevAt).Microseconds()
+	}
+
+	if !rc.xmitRequestedAt.IsZero() {
+		t.SinceRequestUs = now.Sub(rc.xmitRequestedAt).Microseconds()
+
+		// The request is answered once the radio settles in a terminal state.
+		if state == "TRANSMITTING" || state == "READY" || state == "RECEIVE" {
+			rc.xmitRequestedAt = time.Time{}
+		}
+	}
+
+	onTXEvent := rc.onTXEvent
+	rc.mu.Unlock()
+
+	log.Printf("[rtc] interlock %s -> %s after %dus (%s)", prev, state, t.SincePrevUs, rc.key)
+
+	if onTXEvent != nil {
+		onTXEvent(t)
+	}
+}
+
+func (cs *clientSession) reportTXEvent(t guard.TXTransition) {
+	text := interlockText(t.State, t.Reason)
+
+	cs.srv.guard.RecordTransition(t)
+	cs.trySend(mustEncode(typeTXEvent, txEventPayload{AtUs: t.At.UnixMicro(), ReasonText: text, TXTransition: t}))
+	cs.srv.events.Publish(EventTXInterlock, InterlockEvent{
+		Session:    cs.id,
+		Radio:      t.Radio,
+		State:      t.State,
+		Prev:       t.Prev,
+		Reason:     t.Reason,
+		ReasonText: text,
+		Source:     t.Source,
+	})
+}
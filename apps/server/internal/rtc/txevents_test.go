@@ -0,0 +1,73 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+)
+
+func TestNoteInterlock_Timestamps(t *testing.T) {
+	t.Parallel()
+
+	var events []guard.TXTransition
+
+	rc := &radioConn{handleHex: testHandleHex, addr: "192.0.2.1:4992"}
+	rc.onTXEvent = func(ev guard.TXTransition) { events = append(events, ev) }
+
+	t0 := time.Unix(1700000000, 0)
+
+	rc.noteInterlock("interlock state=READY reason= source=", t0)
+	rc.noteXmitRequest([]byte("C41|xmit 1\n"), t0.Add(10*time.Millisecond))
+	rc.noteInterlock("interlock state=PTT_REQUESTED source=SW", t0.Add(12*time.Millisecond))
+	rc.noteInterlock("interlock state=PTT_REQUESTED source=SW", t0.Add(13*time.Millisecond))
+	rc.noteInterlock("interlock state=TRANSMITTING source=SW", t0.Add(40*time.Millisecond))
+
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3 (duplicates suppressed)", len(events))
+	}
+
+	ptt := events[1]
+	if ptt.Prev != "READY" || ptt.State != "PTT_REQUESTED" || ptt.Source != "SW" {
+		t.Errorf("ptt event: got %+v", ptt)
+	}
+
+	if ptt.SincePrevUs != 12000 || ptt.SinceRequestUs != 2000 {
+		t.Errorf("ptt timings: sincePrev=%d sinceRequest=%d", ptt.SincePrevUs, ptt.SinceRequestUs)
+	}
+
+	tx := events[2]
+	if tx.SincePrevUs != 28000 || tx.SinceRequestUs != 30000 {
+		t.Errorf("tx timings: sincePrev=%d sinceRequest=%d", tx.SincePrevUs, tx.SinceRequestUs)
+	}
+
+	rc.noteInterlock("interlock state=UNKEY_REQUESTED", t0.Add(time.Second))
+
+	if got := events[3].SinceRequestUs; got != 0 {
+		t.Errorf("request should clear once transmitting, got sinceRequest=%d", got)
+	}
+}
+
+func TestNoteXmitRequest_AnyCaseAndSpacing(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1700000000, 0)
+
+	for _, line := range []string{"C1|xmit 1", "C2|XMIT 1\r", "C3|Xmit\t0 ", "C4|  xmit   1", "CD5|xmit 1"} {
+		rc := &radioConn{}
+		rc.noteXmitRequest([]byte("C9|slice list\n"+line+"\n"), now)
+
+		if !rc.xmitRequestedAt.Equal(now) {
+			t.Errorf("%q not noted", line)
+		}
+	}
+
+	for _, line := range []string{"C1|xmit 2", "C2|xmitter 1", "R3|xmit 1"} {
+		rc := &radioConn{}
+		rc.noteXmitRequest([]byte(line+"\n"), now)
+
+		if !rc.xmitRequestedAt.IsZero() {
+			t.Errorf("%q noted", line)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package rtc
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+)
+
+// pttReleasedMuted is why a session's PTT is released when its radio's TX is
+// muted.
+const pttReleasedMuted = "muted"
+
+// internalMuteSequence tags the xmit command that unkeys a radio as its TX is
+// muted, so its reply is swallowed.
+const internalMuteSequence = 2147483636
+
+// reCWKeying matches the CW commands that key a muted radio, besides those the
+// guard counts as transmitting.
+var reCWKeying = regexp.MustCompile(`^(?:cw\s+key\s+1|cwx\s+send)\b`)
+
+// MuteTX refuses keying on radio, by host:port or host, or on every radio
+// when radio is empty, until UnmuteTX. Refused commands are answered with
+// reason. A radio transmitting when it is muted is unkeyed.
+func (s *Server) MuteTX(radio, reason string) {
+	s.muteMu.Lock()
+	if s.muted == nil {
+		s.muted = make(map[string]string)
+	}
+
+	s.muted[radio] = reason
+	s.muteMu.Unlock()
+
+	log.Printf("[rtc] TX muted on %s: %s", cmp.Or(radio, "every radio"), reason)
+
+	for _, cs := range s.sessionList() {
+		cs.mu.Lock()
+		rc := cs.radio
+		cs.mu.Unlock()
+
+		if rc == nil || !radioMatches(radio, rc.addr) {
+			continue
+		}
+
+		cs.releasePTT(pttReleasedMuted)
+		rc.unkey(internalMuteSequence)
+	}
+}
+
+// UnmuteTX lifts MuteTX for radio, as it was muted.
+func (s *Server) UnmuteTX(radio string) {
+	s.muteMu.Lock()
+	_, ok := s.muted[radio]
+	delete(s.muted, radio)
+	s.muteMu.Unlock()
+
+	if ok {
+		log.Printf("[rtc] TX unmuted on %s", cmp.Or(radio, "every radio"))
+	}
+}
+
+// TXMuted returns the radios whose TX is muted, "" for every radio, with the
+// reason given.
+func (s *Server) TXMuted() map[string]string {
+	s.muteMu.Lock()
+	defer s.muteMu.Unlock()
+
+	return maps.Clone(s.muted)
+}
+
+// txMuted reports whether keying addr (host:port) is refused, and why.
+func (s *Server) txMuted(addr string) (string, bool) {
+	s.muteMu.Lock()
+	defer s.muteMu.Unlock()
+
+	for radio, reason := range s.muted {
+		if radioMatches(radio, addr) {
+			return cmp.Or(reason, "TX is muted"), true
+		}
+	}
+
+	return "", false
+}
+
+// anyMuted reports whether any radio's TX is muted.
+func (s *Server) anyMuted() bool {
+	s.muteMu.Lock()
+	defer s.muteMu.Unlock()
+
+	return len(s.muted) > 0
+}
+
+// keys reports whether a command line keys the transmitter.
+func keys(line string) bool {
+	if guard.IsTX(line) {
+		return true
+	}
+
+	_, body, ok := guard.SplitCommand(line)
+
+	return ok && reCWKeying.MatchString(body)
+}
+
+// unkey stops a transmission under way, with an xmit command tagged seq.
+func (rc *radioConn) unkey(seq uint64) {
+	rc.mu.Lock()
+	state := rc.interlockState
+	rc.mu.Unlock()
+
+	if state != "TRANSMITTING" && state != "PTT_REQUESTED" {
+		return
+	}
+
+	line := fmt.Sprintf("C%d|xmit 0\n", seq)
+	rc.noteOutgoingCommand([]byte(line))
+
+	err := rc.writeTCPString(line)
+	if err != nil {
+		log.Printf("[rtc] unkey %s: %v", rc.key, err)
+	}
+}
+
+func isInternalMuteReply(line string) bool {
+	return strings.HasPrefix(line, fmt.Sprintf("R%d|", internalMuteSequence))
+}
+
+// radioMatches reports whether radio, as host:port or host, names addr;
+// empty names every radio.
+func radioMatches(radio, addr string) bool {
+	if radio == "" || radio == addr {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+
+	return err == nil && host == radio
+}
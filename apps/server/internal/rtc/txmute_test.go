@@ -0,0 +1,80 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/guard"
+)
+
+func TestMuteTX_BlocksKeying(t *testing.T) {
+	t.Parallel()
+
+	cs, lines := pttSession(t, 0, 0)
+	cs.radio.addr = "192.168.1.20:4992"
+	cs.radio.interlockState = "TRANSMITTING"
+	cs.srv.sessions[cs.id] = cs
+
+	cs.srv.MuteTX("192.168.1.20", "quiet hours")
+
+	if l := nextLine(t, lines); l != "C2147483636|xmit 0" {
+		t.Errorf("muting a transmitting radio sent %q", l)
+	}
+
+	err := cs.forwardCommand(cs.radio, []byte("C5|xmit 1\nC6|slice list\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, lines); l != "C6|slice list" {
+		t.Errorf("sent %q while muted", l)
+	}
+
+	if got := cs.srv.TXMuted(); got["192.168.1.20"] != "quiet hours" {
+		t.Errorf("muted = %v", got)
+	}
+
+	cs.srv.UnmuteTX("192.168.1.20")
+
+	err = cs.forwardCommand(cs.radio, []byte("C7|xmit 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, lines); l != "C7|xmit 1" {
+		t.Errorf("sent %q after unmuting", l)
+	}
+}
+
+func TestCheckCommand_MutedRadios(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{}
+	s.MuteTX("10.0.0.5:4992", "")
+
+	cases := []struct {
+		radio, line string
+		blocked     bool
+	}{
+		{"10.0.0.5:4992", "C1|xmit 1", true},
+		{"10.0.0.5:4992", "C2|transmit tune 1", true},
+		{"10.0.0.5:4992", "C3|xmit 0", false},
+		{"10.0.0.5:4992", "C8|cw key 1 time=0x0010 index=3 client_handle=0x1234ABCD", true},
+		{"10.0.0.5:4992", "C9|cw key 0 time=0x0020 index=4 client_handle=0x1234ABCD", false},
+		{"10.0.0.5:4992", "C10|cwx send \"CQ TEST\"", true},
+		{"10.0.0.5:5000", "C4|xmit 1", false},
+		{"10.0.0.6:4992", "C5|xmit 1", false},
+	}
+
+	for _, c := range cases {
+		d := s.checkCommand("", guard.Request{Radio: c.radio, Line: c.line})
+		if blocked := d.Action == guard.ActionBlock; blocked != c.blocked {
+			t.Errorf("%s %q: %+v", c.radio, c.line, d)
+		}
+	}
+
+	s.MuteTX("", "maintenance")
+
+	if d := s.checkCommand("", guard.Request{Radio: "10.0.0.6:4992", Line: "C6|xmit 1"}); d.Reason != "maintenance" {
+		t.Errorf("every radio muted: %+v", d)
+	}
+}
@@ -0,0 +1,545 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TX token policies: how the token that lets a session key a radio shared
+// with other sessions passes between them.
+const (
+	// TXTokenOff lets every session key.
+	TXTokenOff = "off"
+	// TXTokenGrant queues requests for a held token until its holder grants
+	// or releases it.
+	TXTokenGrant = "grant"
+	// TXTokenSteal hands a held token to whoever requests it, unkeying the
+	// holder.
+	TXTokenSteal = "steal"
+)
+
+// Why a radio's TX token last moved, as reported to its sessions.
+const (
+	tokenMovedKey     = "key"
+	tokenMovedRequest = "request"
+	tokenMovedRelease = "release"
+	tokenMovedGrant   = "grant"
+	tokenMovedSteal   = "steal"
+	tokenMovedLeft    = "left"
+)
+
+// pttReleasedToken is why a session's PTT is released when its TX token is
+// taken.
+const pttReleasedToken = "token"
+
+var (
+	errTXTokenOff    = errors.New("TX token arbitration is off")
+	errTXTokenAction = errors.New("unknown txToken action")
+	errNotHolder     = errors.New("only the TX token's holder may grant it")
+	errGrantee       = errors.New("no such session on this radio")
+)
+
+// txTokenRequest is a client's "txToken" message.
+type txTokenRequest struct {
+	// Action is "status", "request", "release" or "grant".
+	Action string `json:"action"`
+	// Name is how other sessions see this one, e.g. the operator's
+	// callsign. It sticks until changed.
+	Name string `json:"name,omitempty"`
+	// Session is who "grant" hands the token to; empty is the first in
+	// line.
+	Session string `json:"session,omitempty"`
+}
+
+// txTokenOperator is a session holding or waiting for a TX token.
+type txTokenOperator struct {
+	Session string    `json:"session"`
+	Name    string    `json:"name,omitempty"`
+	Since   time.Time `json:"since"`
+	// Self marks the session the status is sent to.
+	Self bool `json:"self,omitempty"`
+}
+
+// txTokenStatus is sent to every session on a radio whenever its TX token
+// moves or is asked for.
+type txTokenStatus struct {
+	Policy string           `json:"policy"`
+	Holder *txTokenOperator `json:"holder,omitempty"`
+	// Requests are the sessions waiting for the token, first in line first.
+	Requests []txTokenOperator `json:"requests"`
+	// Reason is why the token last moved: "key", "request", "release",
+	// "grant", "steal" or "left".
+	Reason string `json:"reason,omitempty"`
+}
+
+// TXTokenEvent is the data of a tx.token event: a radio's TX token moving.
+// Holder is empty when nobody holds it.
+type TXTokenEvent struct {
+	Radio  string `json:"radio"`
+	Holder string `json:"holder,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// txTokens arbitrates keying among the sessions connected to each radio.
+// A nil *txTokens lets every session key.
+type txTokens struct {
+	policy string
+
+	mu     sync.Mutex
+	radios map[string]*txToken // by radio host:port
+	names  map[string]string   // session -> name given
+}
+
+// txToken is one radio's token: who holds it and who asked for it.
+type txToken struct {
+	holder   *txTokenOperator
+	requests []txTokenOperator
+	reason   string
+}
+
+func newTXTokens(policy string) *txTokens {
+	if policy == "" || policy == TXTokenOff {
+		return nil
+	}
+
+	return &txTokens{policy: policy, radios: make(map[string]*txToken), names: make(map[string]string)}
+}
+
+// radioLocked returns addr's token, creating it.
+func (t *txTokens) radioLocked(addr string) *txToken {
+	tok := t.radios[addr]
+	if tok == nil {
+		tok = &txToken{}
+		t.radios[addr] = tok
+	}
+
+	return tok
+}
+
+func (t *txTokens) operatorLocked(session string, now time.Time) txTokenOperator {
+	return txTokenOperator{Session: session, Name: t.names[session], Since: now}
+}
+
+// claim reports whether session may key addr, taking the token if nobody
+// holds it; otherwise holder says who does.
+func (t *txTokens) claim(addr, session string, now time.Time) (holder string, ok, moved bool) {
+	if t == nil {
+		return "", true, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tok := t.radioLocked(addr)
+
+	switch {
+	case tok.holder == nil:
+		tok.take(t.operatorLocked(session, now), tokenMovedKey)
+
+		return "", true, true
+	case tok.holder.Session == session:
+		return "", true, false
+	default:
+		return tok.holder.Name, false, false
+	}
+}
+
+// holds reports whether session may key addr without taking the token.
+func (t *txTokens) holds(addr, session string) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tok := t.radios[addr]
+
+	return tok != nil && tok.holder != nil && tok.holder.Session == session
+}
+
+// request asks for addr's token for session. It returns the session the
+// token was stolen from, if any, and whether anything changed.
+func (t *txTokens) request(addr, session string, now time.Time) (stolen string, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tok := t.radioLocked(addr)
+	op := t.operatorLocked(session, now)
+
+	switch {
+	case tok.holder == nil:
+		tok.take(op, tokenMovedRequest)
+
+		return "", true
+	case tok.holder.Session == session:
+		return "", false
+	case t.policy == TXTokenSteal:
+		stolen = tok.holder.Session
+		tok.take(op, tokenMovedSteal)
+
+		return stolen, true
+	case tok.waiting(session) >= 0:
+		return "", false
+	default:
+		tok.requests = append(tok.requests, op)
+
+		return "", true
+	}
+}
+
+// release gives up session's hold on, or request for, addr's token. A
+// released token passes to the first in line.
+func (t *txTokens) release(addr, session, reason string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tok := t.radios[addr]
+	if tok == nil {
+		return false
+	}
+
+	if i := tok.waiting(session); i >= 0 {
+		tok.requests = slices.Delete(tok.requests, i, i+1)
+
+		return true
+	}
+
+	if tok.holder == nil || tok.holder.Session != session {
+		return false
+	}
+
+	tok.holder = nil
+	tok.reason = reason
+
+	if len(tok.requests) > 0 {
+		next := tok.requests[0]
+		next.Since = now
+		tok.take(next, reason)
+	}
+
+	return true
+}
+
+// grant hands addr's token from its holder, from, to session to, or the
+// first in line when to is empty. It reports whether the token moved.
+func (t *txTokens) grant(addr, from, to string, now time.Time) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tok := t.radios[addr]
+	if tok == nil || tok.holder == nil || tok.holder.Session != from {
+		return false, errNotHolder
+	}
+
+	if to == "" {
+		if len(tok.requests) == 0 {
+			return false, fmt.Errorf("%w: nobody is waiting", errGrantee)
+		}
+
+		to = tok.requests[0].Session
+	}
+
+	if to == from {
+		return false, nil
+	}
+
+	tok.take(t.operatorLocked(to, now), tokenMovedGrant)
+
+	return true, nil
+}
+
+// leave drops session from every radio's token, as when it disconnects,
+// returning the radios whose token changed.
+func (t *txTokens) leave(session string, now time.Time) []string {
+	if t == nil {
+		return nil
+	}
+
+	var changed []string
+
+	t.mu.Lock()
+	addrs := make([]string, 0, len(t.radios))
+
+	for addr := range t.radios {
+		addrs = append(addrs, addr)
+	}
+	t.mu.Unlock()
+
+	for _, addr := range addrs {
+		if t.release(addr, session, tokenMovedLeft, now) {
+			changed = append(changed, addr)
+		}
+	}
+
+	return changed
+}
+
+// forget drops the name session gave, once it has closed.
+func (t *txTokens) forget(session string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.names, session)
+	t.mu.Unlock()
+}
+
+// setName records the name session is shown by, on the token it holds and
+// the requests it has made too.
+func (t *txTokens) setName(session, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.names[session] = name
+
+	for _, tok := range t.radios {
+		if tok.holder != nil && tok.holder.Session == session {
+			tok.holder.Name = name
+		}
+
+		if i := tok.waiting(session); i >= 0 {
+			tok.requests[i].Name = name
+		}
+	}
+}
+
+// status describes addr's token to session.
+func (t *txTokens) status(addr, session string) txTokenStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st := txTokenStatus{Policy: t.policy, Requests: []txTokenOperator{}}
+
+	tok := t.radios[addr]
+	if tok == nil {
+		return st
+	}
+
+	if tok.holder != nil {
+		h := *tok.holder
+		h.Self = h.Session == session
+		st.Holder = &h
+	}
+
+	for _, r := range tok.requests {
+		r.Self = r.Session == session
+		st.Requests = append(st.Requests, r)
+	}
+
+	st.Reason = tok.reason
+
+	return st
+}
+
+// event describes addr's token for the event bus.
+func (t *txTokens) event(addr string) TXTokenEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ev := TXTokenEvent{Radio: addr}
+	if tok := t.radios[addr]; tok != nil {
+		ev.Reason = tok.reason
+		if tok.holder != nil {
+			ev.Holder, ev.Name = tok.holder.Session, tok.holder.Name
+		}
+	}
+
+	return ev
+}
+
+// take makes op the holder, dropping any request it made.
+func (tok *txToken) take(op txTokenOperator, reason string) {
+	if i := tok.waiting(op.Session); i >= 0 {
+		tok.requests = slices.Delete(tok.requests, i, i+1)
+	}
+
+	tok.holder = &op
+	tok.reason = reason
+}
+
+// waiting is where session is in line, or -1.
+func (tok *txToken) waiting(session string) int {
+	return slices.IndexFunc(tok.requests, func(r txTokenOperator) bool { return r.Session == session })
+}
+
+func (cs *clientSession) handleTXToken(raw json.RawMessage) {
+	var req txTokenRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	err = cs.txToken(req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "TX_TOKEN_FAILED", Message: err.Error()}))
+	}
+}
+
+func (cs *clientSession) txToken(req txTokenRequest) error {
+	tokens := cs.srv.tokens
+	if tokens == nil {
+		return errTXTokenOff
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		return errPTTNoRadio
+	}
+
+	if req.Name != "" {
+		tokens.setName(cs.id, req.Name)
+	}
+
+	now := time.Now()
+
+	switch req.Action {
+	case "status":
+		cs.sendTXToken(rc.addr)
+
+		return nil
+	case "request":
+		stolen, changed := tokens.request(rc.addr, cs.id, now)
+		if stolen != "" {
+			cs.srv.unkeySession(stolen)
+		}
+
+		if !changed {
+			cs.sendTXToken(rc.addr)
+
+			return nil
+		}
+	case "release":
+		held := tokens.holds(rc.addr, cs.id)
+		if !tokens.release(rc.addr, cs.id, tokenMovedRelease, now) {
+			return nil
+		}
+
+		if held {
+			cs.srv.unkeySession(cs.id)
+		}
+	case "grant":
+		if req.Session != "" && !cs.srv.onRadio(req.Session, rc.addr) {
+			return fmt.Errorf("%w: %q", errGrantee, req.Session)
+		}
+
+		moved, err := tokens.grant(rc.addr, cs.id, req.Session, now)
+		if err != nil || !moved {
+			return err
+		}
+
+		cs.srv.unkeySession(cs.id)
+	default:
+		return fmt.Errorf("%w: %q", errTXTokenAction, req.Action)
+	}
+
+	cs.srv.txTokenMoved(rc.addr)
+
+	return nil
+}
+
+// sendTXToken tells the session where addr's TX token is, when keying is
+// arbitrated.
+func (cs *clientSession) sendTXToken(addr string) {
+	if cs.srv.tokens != nil {
+		cs.trySend(mustEncode(typeTXToken, cs.srv.tokens.status(addr, cs.id)))
+	}
+}
+
+// claimTXToken reports whether session may key addr, taking the radio's
+// free TX token for it, or else who holds the token.
+func (s *Server) claimTXToken(addr, session string) (string, bool) {
+	holder, ok, moved := s.tokens.claim(addr, session, time.Now())
+	if moved {
+		s.txTokenMoved(addr)
+	}
+
+	return holder, ok
+}
+
+// leaveTXTokens gives up whatever TX token the session holds or waits for,
+// as when its radio link closes.
+func (s *Server) leaveTXTokens(cs *clientSession) {
+	for _, addr := range s.tokens.leave(cs.id, time.Now()) {
+		s.txTokenMoved(addr)
+	}
+}
+
+// txTokenMoved tells every session on addr, and the event bus, where its TX
+// token is now.
+func (s *Server) txTokenMoved(addr string) {
+	ev := s.tokens.event(addr)
+	log.Printf("[rtc] TX token on %s: %s (%s)", addr, holderText(ev), ev.Reason)
+
+	for _, cs := range s.sessionList() {
+		cs.mu.Lock()
+		rc := cs.radio
+		cs.mu.Unlock()
+
+		if rc != nil && rc.addr == addr {
+			cs.sendTXToken(addr)
+		}
+	}
+
+	if s.events != nil {
+		s.events.Publish(EventTXToken, ev)
+	}
+}
+
+// unkeySession releases the session's PTT and unkeys its radio, as when its
+// TX token is taken.
+func (s *Server) unkeySession(id string) {
+	cs := s.session(id)
+	if cs == nil {
+		return
+	}
+
+	cs.releasePTT(pttReleasedToken)
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc != nil {
+		rc.unkey(internalPTTSequence)
+	}
+}
+
+// onRadio reports whether the session id is connected to addr.
+func (s *Server) onRadio(id, addr string) bool {
+	cs := s.session(id)
+	if cs == nil {
+		return false
+	}
+
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	return rc != nil && rc.addr == addr
+}
+
+// holderText names a token's holder for the log.
+func holderText(ev TXTokenEvent) string {
+	switch {
+	case ev.Holder == "":
+		return "free"
+	case ev.Name != "":
+		return fmt.Sprintf("session %s (%q)", ev.Holder, ev.Name)
+	default:
+		return "session " + ev.Holder
+	}
+}
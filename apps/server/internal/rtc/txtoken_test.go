@@ -0,0 +1,166 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func nextTXToken(t *testing.T, cs *clientSession) txTokenStatus {
+	t.Helper()
+
+	for {
+		select {
+		case msg := <-cs.send:
+			if msg.Type != typeTXToken {
+				continue
+			}
+
+			var st txTokenStatus
+
+			err := json.Unmarshal(msg.Payload, &st)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return st
+		case <-time.After(5 * time.Second):
+			t.Fatal("no txToken message")
+
+			return txTokenStatus{}
+		}
+	}
+}
+
+func TestTXTokens_Grant(t *testing.T) {
+	t.Parallel()
+
+	const radio = "10.0.0.5:4992"
+
+	now := time.Unix(1000, 0)
+	tok := newTXTokens(TXTokenGrant)
+	tok.setName("b", "W1AW")
+
+	if _, ok, moved := tok.claim(radio, "a", now); !ok || !moved {
+		t.Fatal("a could not take the free token")
+	}
+
+	if holder, ok, _ := tok.claim(radio, "b", now); ok || holder != "" {
+		t.Fatalf("b keyed a's radio: %q %v", holder, ok)
+	}
+
+	if stolen, changed := tok.request(radio, "b", now); stolen != "" || !changed {
+		t.Fatalf("request = %q, %v", stolen, changed)
+	}
+
+	if _, err := tok.grant(radio, "b", "a", now); !errors.Is(err, errNotHolder) {
+		t.Errorf("b granted a's token: %v", err)
+	}
+
+	st := tok.status(radio, "b")
+	if st.Holder == nil || st.Holder.Session != "a" || len(st.Requests) != 1 || !st.Requests[0].Self || st.Requests[0].Name != "W1AW" {
+		t.Fatalf("status = %+v", st)
+	}
+
+	// Releasing passes the token to the first in line.
+	if !tok.release(radio, "a", tokenMovedRelease, now) {
+		t.Fatal("a could not release")
+	}
+
+	if !tok.holds(radio, "b") || tok.holds(radio, "a") {
+		t.Errorf("after release: %+v", tok.status(radio, ""))
+	}
+
+	if changed := tok.leave("b", now); len(changed) != 1 {
+		t.Errorf("leave changed %v", changed)
+	}
+
+	if st := tok.status(radio, ""); st.Holder != nil || st.Reason != tokenMovedLeft {
+		t.Errorf("after leaving: %+v", st)
+	}
+}
+
+func TestTXTokens_Steal(t *testing.T) {
+	t.Parallel()
+
+	const radio = "10.0.0.5:4992"
+
+	now := time.Unix(1000, 0)
+	tok := newTXTokens(TXTokenSteal)
+	tok.claim(radio, "a", now)
+
+	if stolen, changed := tok.request(radio, "b", now); stolen != "a" || !changed {
+		t.Fatalf("request = %q, %v", stolen, changed)
+	}
+
+	if st := tok.status(radio, "a"); st.Holder == nil || st.Holder.Session != "b" || st.Reason != tokenMovedSteal {
+		t.Errorf("status = %+v", st)
+	}
+
+	if newTXTokens(TXTokenOff) != nil {
+		t.Error("off arbitrates")
+	}
+}
+
+func TestTXToken_OnlyHolderKeys(t *testing.T) {
+	t.Parallel()
+
+	a, aLines := pttSession(t, 0, 0)
+	b, bLines := pttSession(t, 0, 0)
+	b.id = "s2"
+	b.srv = a.srv
+	a.srv.tokens = newTXTokens(TXTokenSteal)
+	a.srv.sessions[a.id] = a
+	a.srv.sessions[b.id] = b
+
+	for _, cs := range []*clientSession{a, b} {
+		cs.radio.addr = "10.0.0.5:4992"
+		cs.radio.interlockState = "TRANSMITTING"
+	}
+
+	err := a.forwardCommand(a.radio, []byte("C5|xmit 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, aLines); l != "C5|xmit 1" {
+		t.Errorf("holder sent %q", l)
+	}
+
+	if st := nextTXToken(t, b); st.Holder == nil || st.Holder.Session != a.id || st.Reason != tokenMovedKey {
+		t.Errorf("b told %+v", st)
+	}
+
+	if st := nextTXToken(t, a); st.Holder == nil || !st.Holder.Self {
+		t.Errorf("a told %+v", st)
+	}
+
+	err = b.forwardCommand(b.radio, []byte("C6|xmit 1\nC7|slice list\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, bLines); l != "C7|slice list" {
+		t.Errorf("sent %q without the token", l)
+	}
+
+	b.handleTXToken(json.RawMessage(`{"action":"request","name":"K1ABC"}`))
+
+	if l := nextLine(t, aLines); l != "C2147483641|xmit 0" {
+		t.Errorf("stealing sent %q to the holder's radio", l)
+	}
+
+	if st := nextTXToken(t, a); st.Holder == nil || st.Holder.Name != "K1ABC" || st.Holder.Self {
+		t.Errorf("a told %+v", st)
+	}
+
+	err = b.forwardCommand(b.radio, []byte("C8|xmit 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, bLines); l != "C8|xmit 1" {
+		t.Errorf("new holder sent %q", l)
+	}
+}
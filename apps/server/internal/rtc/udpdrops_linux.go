@@ -0,0 +1,59 @@
+package rtc
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// kernelDrops reports how many datagrams the kernel has dropped for the UDP
+// socket bound to port, for want of room in its receive buffer.
+func kernelDrops(port int) (uint64, bool) {
+	for _, name := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+
+		drops, ok := procUDPDrops(f, port)
+		_ = f.Close()
+
+		if ok {
+			return drops, true
+		}
+	}
+
+	return 0, false
+}
+
+// procUDPDrops finds port's socket in a /proc/net/udp table and returns its
+// drops, the last column.
+func procUDPDrops(r io.Reader, port int) (uint64, bool) {
+	want := strings.ToUpper(strconv.FormatInt(int64(port), 16))
+
+	sc := bufio.NewScanner(r)
+	sc.Scan() // the header
+
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 13 {
+			continue
+		}
+
+		_, local, _ := strings.Cut(fields[1], ":")
+		if strings.TrimLeft(local, "0") != want {
+			continue
+		}
+
+		drops, err := strconv.ParseUint(fields[len(fields)-1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return drops, true
+	}
+
+	return 0, false
+}
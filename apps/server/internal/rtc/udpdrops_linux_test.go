@@ -0,0 +1,43 @@
+package rtc
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const procNetUDP = `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  135: 00000000:14BB 00000000:0000 07 00000000 00000000 00:00000000 00000000   100        0 21310 2 0000000000000000 0
+  791: 00000000:C34B 00000000:0000 07 00000000 00040000 00:00000000 00000000     0        0 48812 2 0000000000000000 173
+`
+
+func TestProcUDPDrops(t *testing.T) {
+	t.Parallel()
+
+	drops, ok := procUDPDrops(strings.NewReader(procNetUDP), 0xC34B)
+	if !ok || drops != 173 {
+		t.Errorf("drops = %d, %v", drops, ok)
+	}
+
+	if _, ok := procUDPDrops(strings.NewReader(procNetUDP), 4992); ok {
+		t.Error("found a socket on an unused port")
+	}
+}
+
+func TestKernelDrops(t *testing.T) {
+	t.Parallel()
+
+	u, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = u.Close() }()
+
+	if _, ok := kernelDrops(u.LocalAddr().(*net.UDPAddr).Port); !ok { //nolint:forcetypeassert // a UDP socket
+		t.Skip("no /proc/net/udp here")
+	}
+
+	if n := receiveBuffer(u); n <= 0 {
+		t.Errorf("receive buffer %d", n)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package rtc
+
+// kernelDrops reports nothing where the kernel does not publish a socket's
+// drops.
+func kernelDrops(int) (uint64, bool) { return 0, false }
@@ -0,0 +1,321 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/dscp"
+	"github.com/pion/webrtc/v4"
+)
+
+var errNoUDPPort = errors.New("no shared UDP ports")
+
+// UDPPorts is a fixed set of UDP sockets the bridge shares among sessions in
+// place of a socket per session, so the radios' data arrives on a few known
+// ports a firewall can allow.
+//
+// A radio sends each client's streams to the port that client registered,
+// and some, like meters, to every client alike. A port routes what it reads
+// by the radio's address and, when it serves more than one session of that
+// radio, by the VITA-49 stream ID: a stream the radio reported for one
+// session's handle goes to that session, and the copies of a stream sent to
+// every client are dealt out among them in turn, one copy each.
+type UDPPorts struct {
+	ports []*udpPort
+}
+
+// udpPort is one shared socket and the sessions it serves.
+type udpPort struct {
+	conn *net.UDPConn
+
+	mu     sync.RWMutex
+	routes map[netip.Addr]*udpRoute
+}
+
+// udpRoute is the sessions of one radio a port serves, in the order they
+// leased it.
+type udpRoute struct {
+	conns []*radioConn
+	// turns is which of conns gets the next copy of each stream the radio
+	// sends every client. Only the port's reader touches it.
+	turns map[uint32]int
+}
+
+// maxRouteTurns bounds the streams a route deals out in turn.
+const maxRouteTurns = 256
+
+// streamSet is a set of stream IDs with its own lock, so a port's reader can
+// consult it without taking the connection's.
+type streamSet struct {
+	mu  sync.RWMutex
+	ids map[uint32]bool
+}
+
+func (ss *streamSet) set(id uint32, on bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if !on {
+		delete(ss.ids, id)
+
+		return
+	}
+
+	if ss.ids == nil {
+		ss.ids = make(map[uint32]bool)
+	}
+
+	ss.ids[id] = true
+}
+
+func (ss *streamSet) has(id uint32) bool {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	return ss.ids[id]
+}
+
+// ListenUDPPorts opens a socket on each port from start to end, with
+// receiveBuffer bytes of SO_RCVBUF unless zero and marked with codePoint
+// unless zero, and starts reading them.
+func ListenUDPPorts(start, end uint16, receiveBuffer, codePoint int) (*UDPPorts, error) {
+	p := &UDPPorts{}
+
+	for port := int(start); port <= int(end); port++ {
+		// Dual-stack, so one port serves IPv4 and IPv6 radios alike.
+		u, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+		if err != nil {
+			p.Close()
+
+			return nil, fmt.Errorf("listen udp %d: %w", port, err)
+		}
+
+		if receiveBuffer > 0 {
+			_ = u.SetReadBuffer(receiveBuffer)
+		}
+
+		err = dscp.Mark(u, codePoint)
+		if err != nil && port == int(start) {
+			log.Printf("[rtc] shared udp ports: %v", err)
+		}
+
+		up := &udpPort{conn: u, routes: make(map[netip.Addr]*udpRoute)}
+		p.ports = append(p.ports, up)
+
+		go up.readLoop()
+	}
+
+	return p, nil
+}
+
+// Close closes every socket.
+func (p *UDPPorts) Close() {
+	for _, up := range p.ports {
+		_ = up.conn.Close()
+	}
+}
+
+// lease gives rc a port for its radio at raddr: the one it already has, or
+// the least busy, preferring one without another session of that radio so
+// its packets need no sorting.
+func (p *UDPPorts) lease(rc *radioConn, raddr *net.UDPAddr) (*udpPort, error) {
+	addr := raddr.AddrPort().Addr().Unmap()
+
+	var best *udpPort
+
+	bestShared, bestLoad := false, 0
+
+	for _, up := range p.ports {
+		up.mu.RLock()
+		r := up.routes[addr]
+		load := up.load()
+		up.mu.RUnlock()
+
+		if r != nil && slices.Contains(r.conns, rc) {
+			return up, nil
+		}
+
+		shared := r != nil
+		if best == nil || !shared && bestShared || shared == bestShared && load < bestLoad {
+			best, bestShared, bestLoad = up, shared, load
+		}
+	}
+
+	if best == nil {
+		return nil, errNoUDPPort
+	}
+
+	best.mu.Lock()
+	defer best.mu.Unlock()
+
+	r := best.routes[addr]
+	if r == nil {
+		r = &udpRoute{}
+		best.routes[addr] = r
+	}
+
+	if !slices.Contains(r.conns, rc) {
+		r.conns = append(r.conns, rc)
+	}
+
+	return best, nil
+}
+
+// load is how many sessions the port serves. The caller holds up.mu.
+func (up *udpPort) load() int {
+	n := 0
+	for _, r := range up.routes {
+		n += len(r.conns)
+	}
+
+	return n
+}
+
+// release stops routing to rc. Once it returns the reader is no longer
+// handing rc packets.
+func (up *udpPort) release(rc *radioConn) {
+	up.mu.Lock()
+	defer up.mu.Unlock()
+
+	for addr, r := range up.routes {
+		r.conns = slices.DeleteFunc(r.conns, func(c *radioConn) bool { return c == rc })
+		if len(r.conns) == 0 {
+			delete(up.routes, addr)
+		}
+	}
+}
+
+// pick is the session packet p is for.
+func (r *udpRoute) pick(p []byte) *radioConn {
+	if len(r.conns) == 1 {
+		return r.conns[0]
+	}
+
+	v, err := parseVITA(p)
+	if err != nil {
+		return r.conns[0]
+	}
+
+	for _, rc := range r.conns {
+		if rc.udpStreams.has(v.StreamID) {
+			return rc
+		}
+	}
+
+	// The radio sends this one to every client: one copy for each of them.
+	if r.turns == nil || len(r.turns) >= maxRouteTurns {
+		r.turns = make(map[uint32]int)
+	}
+
+	i := r.turns[v.StreamID] % len(r.conns)
+	r.turns[v.StreamID] = i + 1
+
+	return r.conns[i]
+}
+
+// noteUDPStream keeps track, from a status line, of the streams the radio
+// sends this connection alone: its own streams, panadapters and waterfalls.
+func (rc *radioConn) noteUDPStream(line string) {
+	var id uint32
+
+	for _, key := range []string{"|stream 0x", "|display pan 0x", "|display waterfall 0x"} {
+		if id = extractUint32(line, key); id != 0 {
+			break
+		}
+	}
+
+	switch {
+	case id == 0:
+	case strings.Contains(line, " removed"):
+		rc.udpStreams.set(id, false)
+	case rc.handleU32 != 0 && extractUint32(line, "client_handle=0x") == rc.handleU32:
+		rc.udpStreams.set(id, true)
+	}
+}
+
+// openSharedUDP has the radio at raddr send rc's streams to a shared port.
+func (rc *radioConn) openSharedUDP(ports *UDPPorts, dc *webrtc.DataChannel, raddr *net.UDPAddr) error {
+	up, err := ports.lease(rc, raddr)
+	if err != nil {
+		return err
+	}
+
+	rc.mu.Lock()
+	rc.udpConn = up.conn
+	rc.udpPort = up
+	rc.udpRaddr = raddr
+	rc.udpDC = dc
+	rc.udpRcvBuf = receiveBuffer(up.conn)
+	rc.mu.Unlock()
+
+	_ = rc.writeTCPString(fmt.Sprintf("C%d|client udpport %d\n", internalUDPPortSequence, up.port()))
+
+	return nil
+}
+
+// releaseUDP closes rc's own socket u, or gives back its shared port up and
+// stops the pipeline the port was feeding.
+func (rc *radioConn) releaseUDP(u *net.UDPConn, up *udpPort) {
+	if up == nil {
+		if u != nil {
+			_ = u.Close()
+		}
+
+		return
+	}
+
+	up.release(rc)
+
+	if pl := rc.pipeline.Swap(nil); pl != nil {
+		pl.stop()
+	}
+}
+
+func (up *udpPort) port() int {
+	ua, ok := udpLocalAddr(up.conn)
+	if !ok {
+		return 0
+	}
+
+	return ua.Port
+}
+
+// readLoop hands each packet to the session its radio's address and stream
+// lead to, until the socket is closed.
+func (up *udpPort) readLoop() {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, src, err := up.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			log.Printf("[rtc] shared udp port %d: %v", up.port(), err)
+
+			continue
+		}
+
+		// The lock is held across enqueue, which never blocks, so release
+		// can promise rc gets nothing more.
+		up.mu.RLock()
+
+		if r := up.routes[src.Addr().Unmap()]; r != nil {
+			rc := r.pick(buf[:n])
+			rc.udpIn.Add(uint64(n)) //nolint:gosec // n is never negative
+
+			if pl := rc.pipeline.Load(); pl != nil {
+				pl.enqueue(buf[:n])
+			}
+		}
+
+		up.mu.RUnlock()
+	}
+}
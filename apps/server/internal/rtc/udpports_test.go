@@ -0,0 +1,217 @@
+package rtc
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPPortsLease(t *testing.T) {
+	t.Parallel()
+
+	ports, err := ListenUDPPorts(0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ports.Close()
+
+	radioA := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4991}
+	radioB := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 4991}
+	a1, a2, b := &radioConn{}, &radioConn{}, &radioConn{}
+
+	up, err := ports.lease(a1, radioA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, _ := ports.lease(a1, radioA); again != up {
+		t.Error("a session's second lease moved it")
+	}
+
+	if shared, err := ports.lease(a2, radioA); err != nil || shared != up {
+		t.Errorf("second session of one radio: %v", err)
+	}
+
+	if _, err := ports.lease(b, radioB); err != nil {
+		t.Errorf("another radio: %v", err)
+	}
+
+	up.release(a1)
+
+	up.mu.RLock()
+	left := up.routes[radioA.AddrPort().Addr().Unmap()].conns
+	up.mu.RUnlock()
+
+	if len(left) != 1 || left[0] != a2 {
+		t.Errorf("after release, routes %v", left)
+	}
+
+	if _, err := (&UDPPorts{}).lease(a1, radioA); !errors.Is(err, errNoUDPPort) {
+		t.Errorf("no ports: %v", err)
+	}
+}
+
+func TestUDPPortsLease_SpreadsARadio(t *testing.T) {
+	t.Parallel()
+
+	ports, err := ListenUDPPorts(0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ports.Close()
+
+	more, err := ListenUDPPorts(0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer more.Close()
+
+	ports.ports = append(ports.ports, more.ports...)
+
+	radio := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4991}
+	other := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 4991}
+
+	first, _ := ports.lease(&radioConn{}, radio)
+	busy := ports.ports[1]
+
+	only := &UDPPorts{ports: []*udpPort{busy}}
+	_, _ = only.lease(&radioConn{}, other)
+	_, _ = only.lease(&radioConn{}, other)
+
+	// The other port is busier, but has no session of this radio to sort
+	// its packets from.
+	if up, _ := ports.lease(&radioConn{}, radio); up != busy || up == first {
+		t.Error("second session of a radio shares a port while another has none of it")
+	}
+}
+
+func TestUDPRoutePick(t *testing.T) {
+	t.Parallel()
+
+	a := &radioConn{handleU32: 0x1234ABCD}
+	b := &radioConn{handleU32: 0x0BADF00D}
+	r := &udpRoute{conns: []*radioConn{a, b}}
+
+	a.noteUDPStream("S1234ABCD|display pan 0x40000000 center=14.1 client_handle=0x1234ABCD")
+	b.noteUDPStream("S0BADF00D|display pan 0x40000001 center=7.1 client_handle=0x0BADF00D")
+	b.noteUDPStream("S0BADF00D|stream 0x04000008 type=remote_audio_rx compression=OPUS client_handle=0x0BADF00D")
+	// Another client's stream is not ours.
+	a.noteUDPStream("S0BADF00D|stream 0x04000009 type=remote_audio_rx client_handle=0x0BADF00D")
+
+	for stream, want := range map[uint32]*radioConn{0x40000000: a, 0x40000001: b, 0x04000008: b} {
+		p, _ := panPacket(t, stream, 1, 0, 1, []uint16{1})
+		if got := r.pick(p); got != want {
+			t.Errorf("stream 0x%08X went to the wrong session", stream)
+		}
+	}
+
+	// A stream the radio sends every client arrives once per session: each
+	// gets one of the copies.
+	meters, _ := panPacket(t, 0x00000700, 1, 0, 1, []uint16{1})
+
+	got := map[*radioConn]int{}
+	for range 4 {
+		got[r.pick(meters)]++
+	}
+
+	if got[a] != 2 || got[b] != 2 {
+		t.Errorf("broadcast copies dealt a=%d b=%d, want 2 each", got[a], got[b])
+	}
+
+	b.noteUDPStream("S0BADF00D|stream 0x04000008 removed")
+
+	if b.udpStreams.has(0x04000008) {
+		t.Error("removed stream still routed")
+	}
+}
+
+func TestUDPPortsRoute(t *testing.T) {
+	t.Parallel()
+
+	ports, err := ListenUDPPorts(0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ports.Close()
+
+	radio, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = radio.Close() }()
+
+	rc := &radioConn{}
+	rc.pipeline.Store(newUDPPipeline(rc, nil, UDPPipelineOptions{Workers: 1, Queue: 8}))
+
+	up, err := ports.lease(rc, radio.LocalAddr().(*net.UDPAddr)) //nolint:forcetypeassert // a UDP socket
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bridge := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: up.port()}
+
+	_, err = radio.WriteToUDP([]byte("packet"), bridge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for rc.pipeline.Load().stats().Read != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("packet not routed to its session")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if rc.udpIn.Load() != 6 {
+		t.Errorf("udpIn = %d", rc.udpIn.Load())
+	}
+
+	// Released, the session's pipeline stops and the port no longer feeds it.
+	rc.releaseUDP(nil, up)
+
+	if rc.pipeline.Load() != nil {
+		t.Error("pipeline still running")
+	}
+}
+
+func TestUDPPortsRoute_IPv6(t *testing.T) {
+	t.Parallel()
+
+	radio, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer func() { _ = radio.Close() }()
+
+	ports, err := ListenUDPPorts(0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ports.Close()
+
+	rc := &radioConn{}
+	rc.pipeline.Store(newUDPPipeline(rc, nil, UDPPipelineOptions{Workers: 1, Queue: 8}))
+
+	up, err := ports.lease(rc, radio.LocalAddr().(*net.UDPAddr)) //nolint:forcetypeassert // a UDP socket
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.releaseUDP(nil, up)
+
+	_, err = radio.WriteToUDP([]byte("packet"), &net.UDPAddr{IP: net.IPv6loopback, Port: up.port()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for rc.pipeline.Load().stats().Read != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("packet from an IPv6 radio not routed to its session")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -23,9 +23,11 @@ type vitaView struct {
 	ClassInfo uint16
 	ClassCode uint16
 
-	// Timestamps (as in your AS impl; only frac LSB is kept)
-	IntegerTimestamp    uint32
-	FractionalTimestamp uint32
+	// Timestamps (as in your AS impl, FractionalTimestamp is the frac LSB;
+	// FractionalTimestampMSB completes it for real-time picoseconds)
+	IntegerTimestamp       uint32
+	FractionalTimestamp    uint32
+	FractionalTimestampMSB uint32
 
 	// Raw payload slice
 	Payload []byte
@@ -115,7 +117,7 @@ func parseVITA(b []byte) (vitaView, error) {
 		optWordIndex++
 	}
 
-	var fracTS uint32
+	var fracTS, fracMSB uint32
 
 	if tsfType != 0 {
 		offMSB := kOffsetOptionalsBytes + (optWordIndex << 2)
@@ -124,7 +126,7 @@ func parseVITA(b []byte) (vitaView, error) {
 		if offLSB+4 > packetSizeBytes {
 			return vitaView{}, errShort
 		}
-		// msb := binary.BigEndian.Uint32(b[offMSB:offMSB+4]) // ignored (as in AS)
+		fracMSB = binary.BigEndian.Uint32(b[offMSB : offMSB+4])
 		lsb := binary.BigEndian.Uint32(b[offLSB : offLSB+4])
 		fracTS = lsb
 		optWordIndex += 2
@@ -153,17 +155,18 @@ func parseVITA(b []byte) (vitaView, error) {
 	payload := b[start:end]
 
 	return vitaView{
-		TSI:                 tsiType,
-		TSF:                 tsfType,
-		HasClassID:          classIDPresent,
-		HasTrailer:          trailerPresent,
-		StreamID:            streamID,
-		OUI:                 oui,
-		ClassInfo:           infoCode,
-		ClassCode:           pktClass,
-		IntegerTimestamp:    intTS,
-		FractionalTimestamp: fracTS,
-		Payload:             payload,
+		TSI:                    tsiType,
+		TSF:                    tsfType,
+		HasClassID:             classIDPresent,
+		HasTrailer:             trailerPresent,
+		StreamID:               streamID,
+		OUI:                    oui,
+		ClassInfo:              infoCode,
+		ClassCode:              pktClass,
+		IntegerTimestamp:       intTS,
+		FractionalTimestamp:    fracTS,
+		FractionalTimestampMSB: fracMSB,
+		Payload:                payload,
 	}, nil
 }
 
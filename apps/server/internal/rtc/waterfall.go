@@ -0,0 +1,84 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/waterfall"
+	"github.com/pion/webrtc/v4"
+)
+
+// maxTileBacklog is how much the "waterfall" channel may buffer before
+// tiles are dropped; a late waterfall line is worth nothing.
+const maxTileBacklog = 256 << 10
+
+// waterfallTiles renders a session's waterfall for its "waterfall" channel.
+type waterfallTiles struct {
+	dc *webrtc.DataChannel
+
+	mu       sync.Mutex
+	renderer *waterfall.Renderer
+}
+
+// openWaterfall has the bridge render the session's waterfall into tiles
+// on dc instead of sending the raw lines on the "udp" channel. The label is
+// the tiles' options as a query string.
+func (cs *clientSession) openWaterfall(dc *webrtc.DataChannel) {
+	cs.mu.Lock()
+	rc := cs.radio
+	cs.mu.Unlock()
+
+	if rc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "WATERFALL_UNAVAILABLE", Message: "no radio connection"}))
+		_ = dc.Close()
+
+		return
+	}
+
+	opt, err := waterfall.ParseOptions(dc.Label())
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_WATERFALL", Message: err.Error()}))
+		_ = dc.Close()
+
+		return
+	}
+
+	w := &waterfallTiles{dc: dc, renderer: waterfall.New(opt)}
+
+	rc.waterfall.Store(w)
+	dc.OnClose(func() { rc.waterfall.CompareAndSwap(w, nil) })
+
+	log.Printf("[rtc] session %s: rendering the waterfall (%q)", cs.id, dc.Label())
+}
+
+// add renders a waterfall packet, sending each tile it completes.
+func (w *waterfallTiles) add(v vitaView) {
+	w.mu.Lock()
+	tiles, err := w.renderer.Add(v.StreamID, v.Payload)
+	w.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	for _, t := range tiles {
+		if w.dc.BufferedAmount() > maxTileBacklog {
+			continue
+		}
+
+		_ = w.dc.Send(encodeTile(t))
+	}
+}
+
+// encodeTile frames a tile as a big-endian uint16 length, that many bytes of
+// JSON describing it, then the PNG.
+func encodeTile(t *waterfall.Tile) []byte {
+	hdr, _ := json.Marshal(t) //nolint:errchkjson // plain numbers
+
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(hdr))) //nolint:gosec // a few dozen bytes
+	b = append(b, hdr...)
+
+	return append(b, t.PNG...)
+}
@@ -0,0 +1,28 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/waterfall"
+)
+
+func TestEncodeTile(t *testing.T) {
+	t.Parallel()
+
+	b := encodeTile(&waterfall.Tile{Stream: 0x42000000, Rows: 4, PNG: []byte("png")})
+
+	n := int(binary.BigEndian.Uint16(b))
+
+	var hdr waterfall.Tile
+
+	err := json.Unmarshal(b[2:2+n], &hdr)
+	if err != nil || hdr.Stream != 0x42000000 || hdr.Rows != 4 {
+		t.Errorf("header %s: %v", b[2:2+n], err)
+	}
+
+	if string(b[2+n:]) != "png" {
+		t.Errorf("image %q", b[2+n:])
+	}
+}
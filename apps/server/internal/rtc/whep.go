@@ -0,0 +1,245 @@
+package rtc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+)
+
+// WHEPPath is the WebRTC-HTTP Egress Protocol (RFC 9725) endpoint. A player
+// POSTs an SDP offer and gets the RX audio of a running session as a
+// send-only track; DELETE on the returned Location ends playback.
+//
+// WHEP listeners ride on an existing session: they hear exactly what that
+// session's operator hears, so a slice is selected by soloing it there.
+const WHEPPath = "/whep"
+
+const maxWHEPOffer = 64 * 1024
+
+// whepListener is one WHEP playback resource.
+type whepListener struct {
+	id      string
+	session string
+	pc      *webrtc.PeerConnection
+}
+
+// ServeWHEP handles POST WHEPPath. The session to listen to is given by
+// ?session=<id>; it may be omitted when exactly one session is carrying audio.
+func (s *Server) ServeWHEP(w http.ResponseWriter, r *http.Request) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != "application/sdp" {
+		writeHTTPError(w, http.StatusUnsupportedMediaType, "BAD_CONTENT_TYPE", "offer must be application/sdp")
+
+		return
+	}
+
+	if s.draining.Load() {
+		writeHTTPError(w, http.StatusServiceUnavailable, "DRAINING", "bridge is shutting down")
+
+		return
+	}
+
+	cs, track, status, msg := s.whepSource(r.URL.Query().Get("session"))
+	if cs == nil {
+		writeHTTPError(w, status, "NO_SESSION", msg)
+
+		return
+	}
+
+	sdp, err := io.ReadAll(io.LimitReader(r.Body, maxWHEPOffer))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, "BAD_OFFER", err.Error())
+
+		return
+	}
+
+	l, answer, err := s.newWHEPListener(cs.id, track, string(sdp))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, "BAD_OFFER", err.Error())
+
+		return
+	}
+
+	log.Printf("[rtc] WHEP listener %s (%s) on session %s", l.id, clientIPFromRequest(r), cs.id)
+	s.publishStream(true, StreamEvent{Session: cs.id, Kind: StreamWHEP, ID: l.id, ClientIP: clientIPFromRequest(r)})
+
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", WHEPPath+"/"+l.id)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, answer)
+}
+
+// EndWHEP handles DELETE WHEPPath/{id}.
+func (s *Server) EndWHEP(w http.ResponseWriter, r *http.Request) {
+	if !s.closeWHEP(r.PathValue("id")) {
+		writeHTTPError(w, http.StatusNotFound, "NO_RESOURCE", "no such WHEP resource")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// whepSource picks the session a listener will hear and its audio track. On
+// failure the session is nil and status and msg describe why.
+func (s *Server) whepSource(id string) (*clientSession, *opusTrack, int, string) {
+	var candidates []*clientSession
+
+	if id != "" {
+		if cs := s.session(id); cs != nil {
+			candidates = append(candidates, cs)
+		}
+	} else {
+		for _, cs := range s.sessionList() {
+			if cs.audio() != nil {
+				candidates = append(candidates, cs)
+			}
+		}
+	}
+
+	switch {
+	case len(candidates) == 0 && id != "":
+		return nil, nil, http.StatusNotFound, "no such session"
+	case len(candidates) == 0:
+		return nil, nil, http.StatusNotFound, "no session is carrying audio"
+	case len(candidates) > 1:
+		return nil, nil, http.StatusConflict, "several sessions are carrying audio; pass ?session=<id>"
+	}
+
+	cs := candidates[0]
+
+	track := cs.audio()
+	if track == nil {
+		return nil, nil, http.StatusConflict, "session has no audio yet"
+	}
+
+	return cs, track, 0, ""
+}
+
+// audio returns the session's RX audio track, or nil before the client has
+// connected its PeerConnection.
+func (cs *clientSession) audio() *opusTrack {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.audioTrack
+}
+
+// newWHEPListener answers offer with a PeerConnection sending track. WHEP
+// has no trickle in this server, so the answer waits for ICE gathering and
+// carries every candidate.
+func (s *Server) newWHEPListener(session string, track *opusTrack, offer string) (*whepListener, string, error) {
+	pc, err := s.api.NewPeerConnection(webrtc.Configuration{ICEServers: s.iceServers})
+	if err != nil {
+		return nil, "", fmt.Errorf("create peer connection: %w", err)
+	}
+
+	l := &whepListener{id: uuid.NewString(), session: session, pc: pc}
+
+	tr, err := pc.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionSendonly,
+	})
+	if err != nil {
+		_ = pc.Close()
+
+		return nil, "", fmt.Errorf("add audio track: %w", err)
+	}
+
+	go track.readRTCP(tr.Sender())
+
+	err = pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer})
+	if err != nil {
+		_ = pc.Close()
+
+		return nil, "", fmt.Errorf("set remote description: %w", err)
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		_ = pc.Close()
+
+		return nil, "", fmt.Errorf("create answer: %w", err)
+	}
+
+	gathered := webrtc.GatheringCompletePromise(pc)
+
+	err = pc.SetLocalDescription(answer)
+	if err != nil {
+		_ = pc.Close()
+
+		return nil, "", fmt.Errorf("set local description: %w", err)
+	}
+
+	<-gathered
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			s.closeWHEP(l.id)
+		}
+	})
+
+	s.whepMu.Lock()
+	if s.whep == nil {
+		s.whep = make(map[string]*whepListener)
+	}
+
+	s.whep[l.id] = l
+	s.whepMu.Unlock()
+
+	return l, pc.LocalDescription().SDP, nil
+}
+
+// closeWHEP ends one WHEP resource. It reports false when there is none.
+func (s *Server) closeWHEP(id string) bool {
+	s.whepMu.Lock()
+	l := s.whep[id]
+	delete(s.whep, id)
+	s.whepMu.Unlock()
+
+	if l == nil {
+		return false
+	}
+
+	log.Printf("[rtc] WHEP listener %s closed", id)
+	_ = l.pc.Close()
+
+	s.publishStream(false, StreamEvent{Session: l.session, Kind: StreamWHEP, ID: id})
+
+	return true
+}
+
+// closeSessionWHEP ends every listener of a session that has gone away.
+func (s *Server) closeSessionWHEP(session string) {
+	for _, id := range s.whepListeners(session) {
+		s.closeWHEP(id)
+	}
+}
+
+// whepListeners returns the ids of a session's WHEP listeners.
+func (s *Server) whepListeners(session string) []string {
+	s.whepMu.Lock()
+	defer s.whepMu.Unlock()
+
+	var ids []string
+
+	for id, l := range s.whep {
+		if l.session == session {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// writeHTTPError writes an errorPayload as a JSON HTTP error.
+func writeHTTPError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorPayload{Code: code, Message: msg})
+}
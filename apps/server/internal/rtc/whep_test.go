@@ -0,0 +1,157 @@
+package rtc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// whepServer returns a Server with one session carrying an audio track.
+func whepServer(t *testing.T) (*Server, *clientSession) {
+	t.Helper()
+
+	track := newOpusTrack("remote_audio", "remote_audio")
+
+	s := &Server{api: webrtc.NewAPI(), sessions: map[string]*clientSession{}}
+	cs := &clientSession{id: "op", srv: s, audioTrack: track}
+	s.addSession(cs)
+
+	return s, cs
+}
+
+// whepOffer returns a receive-only audio offer from a player.
+func whepOffer(t *testing.T) string {
+	t.Helper()
+
+	player, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = player.Close() })
+
+	_, err = player.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
+		Direction: webrtc.RTPTransceiverDirectionRecvonly,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := player.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = player.SetLocalDescription(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return offer.SDP
+}
+
+func TestWHEP_CreateAndDelete(t *testing.T) {
+	t.Parallel()
+
+	s, cs := whepServer(t)
+
+	r := httptest.NewRequest(http.MethodPost, WHEPPath, strings.NewReader(whepOffer(t)))
+	r.Header.Set("Content-Type", "application/sdp")
+
+	w := httptest.NewRecorder()
+	s.ServeWHEP(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d %s, want 201", w.Code, w.Body)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/sdp" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	answer, _ := io.ReadAll(w.Body)
+	if !strings.Contains(string(answer), "a=sendonly") || !strings.Contains(string(answer), "opus/48000/2") {
+		t.Errorf("answer should send Opus audio:\n%s", answer)
+	}
+
+	loc := w.Header().Get("Location")
+	if !strings.HasPrefix(loc, WHEPPath+"/") {
+		t.Fatalf("Location = %q", loc)
+	}
+
+	if n := len(s.whepListeners(cs.id)); n != 1 {
+		t.Fatalf("%d listeners, want 1", n)
+	}
+
+	del := func() int {
+		r := httptest.NewRequest(http.MethodDelete, loc, nil)
+		r.SetPathValue("id", strings.TrimPrefix(loc, WHEPPath+"/"))
+
+		w := httptest.NewRecorder()
+		s.EndWHEP(w, r)
+
+		return w.Code
+	}
+
+	if code := del(); code != http.StatusOK {
+		t.Errorf("DELETE = %d, want 200", code)
+	}
+
+	if code := del(); code != http.StatusNotFound {
+		t.Errorf("second DELETE = %d, want 404", code)
+	}
+}
+
+func TestWHEP_Rejects(t *testing.T) {
+	t.Parallel()
+
+	s, _ := whepServer(t)
+	s.addSession(&clientSession{id: "other", srv: s, audioTrack: s.session("op").audioTrack})
+
+	post := func(query, contentType string) int {
+		r := httptest.NewRequest(http.MethodPost, WHEPPath+query, strings.NewReader("v=0"))
+		r.Header.Set("Content-Type", contentType)
+
+		w := httptest.NewRecorder()
+		s.ServeWHEP(w, r)
+
+		return w.Code
+	}
+
+	if code := post("", "text/plain"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("wrong content type: %d, want 415", code)
+	}
+
+	if code := post("", "application/sdp"); code != http.StatusConflict {
+		t.Errorf("ambiguous session: %d, want 409", code)
+	}
+
+	if code := post("?session=nope", "application/sdp"); code != http.StatusNotFound {
+		t.Errorf("unknown session: %d, want 404", code)
+	}
+
+	if code := post("?session=op", "application/sdp"); code != http.StatusBadRequest {
+		t.Errorf("malformed offer: %d, want 400", code)
+	}
+}
+
+func TestWHEP_ClosedWithSession(t *testing.T) {
+	t.Parallel()
+
+	s, cs := whepServer(t)
+
+	_, _, err := s.newWHEPListener(cs.id, cs.audioTrack, whepOffer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.removeSession(cs)
+
+	if n := len(s.whepListeners(cs.id)); n != 0 {
+		t.Errorf("%d listeners left after the session ended", n)
+	}
+}
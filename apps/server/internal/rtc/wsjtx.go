@@ -0,0 +1,120 @@
+package rtc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsjtx"
+)
+
+var (
+	errWSJTXDisabled  = errors.New("WSJT-X bridge is not enabled")
+	errWSJTXVerb      = errors.New("unknown wsjtx action")
+	errWSJTXSandboxed = errors.New("replying to a decode can transmit; leave the sandbox first")
+	errWSJTXNoRadio   = errors.New("no radio to tune")
+)
+
+// wsjtxRequest is a client's "wsjtx" message.
+type wsjtxRequest struct {
+	// Action is "subscribe", "unsubscribe", "reply" or "haltTx".
+	Action string `json:"action"`
+	// ID names the WSJT-X instance, as in its events.
+	ID string `json:"id,omitempty"`
+	// Decode, for reply, is the decode clicked, as received.
+	Decode    wsjtx.Decode `json:"decode"`
+	Modifiers uint8        `json:"modifiers,omitempty"`
+	// Slice, for reply, is first tuned to the instance's dial frequency, so
+	// clicking a decode from an instance on another band follows it there.
+	Slice *int `json:"slice,omitempty"`
+	// AutoOnly, for haltTx, only turns off Enable Tx.
+	AutoOnly bool `json:"autoOnly,omitempty"`
+}
+
+// publishWSJTX forwards an event to the sessions that asked for them.
+func (s *Server) publishWSJTX(ev wsjtx.Event) {
+	msg := mustEncode(typeWSJTX, ev)
+
+	for _, cs := range s.sessionList() {
+		if cs.wsjtx.Load() {
+			cs.trySend(msg)
+		}
+	}
+}
+
+func (cs *clientSession) handleWSJTX(raw json.RawMessage) {
+	var req wsjtxRequest
+
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "BAD_PAYLOAD", Message: err.Error()}))
+
+		return
+	}
+
+	svc := cs.srv.wsjtx
+	if svc == nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "WSJTX_FAILED", Message: errWSJTXDisabled.Error()}))
+
+		return
+	}
+
+	switch req.Action {
+	case "subscribe":
+		cs.wsjtx.Store(true)
+
+		// Catch the client up with the instances already running.
+		for _, in := range svc.Instances() {
+			if in.Status != nil {
+				cs.trySend(mustEncode(typeWSJTX, wsjtx.Event{Event: wsjtx.EventStatus, ID: in.ID, Status: in.Status}))
+			}
+		}
+	case "unsubscribe":
+		cs.wsjtx.Store(false)
+	case "reply":
+		err = cs.replyWSJTX(svc, req)
+	case "haltTx":
+		err = svc.HaltTx(req.ID, req.AutoOnly)
+	default:
+		err = fmt.Errorf("%w %q", errWSJTXVerb, req.Action)
+	}
+
+	if err != nil {
+		cs.trySend(mustEncode(typeError, errorPayload{Code: "WSJTX_FAILED", Message: err.Error()}))
+	}
+}
+
+// replyWSJTX answers a decode. WSJT-X may start transmitting on its own, so
+// a sandboxed session may not, and a QSY goes through the command guard like
+// any other tune.
+func (cs *clientSession) replyWSJTX(svc *wsjtx.Service, req wsjtxRequest) error {
+	if cs.sandbox.isEnabled() {
+		return errWSJTXSandboxed
+	}
+
+	if req.Slice != nil {
+		st, err := svc.Status(req.ID)
+		if err != nil {
+			return fmt.Errorf("qsy: %w", err)
+		}
+
+		cs.mu.Lock()
+		rc := cs.radio
+		cs.mu.Unlock()
+
+		if rc == nil {
+			return errWSJTXNoRadio
+		}
+
+		mhz := float64(st.DialHz) / 1e6
+
+		err = (&rigAdapter{srv: cs.srv, ip: cs.clientIP}).command(cs, rc, fmt.Sprintf("slice tune %d %.6f", *req.Slice, mhz))
+		if err != nil {
+			return fmt.Errorf("qsy slice %d: %w", *req.Slice, err)
+		}
+
+		rc.updateSlice(*req.Slice, func(s *sliceInfo) { s.freqMHz = mhz })
+	}
+
+	return svc.Reply(req.ID, req.Decode, req.Modifiers) //nolint:wrapcheck // already says wsjtx
+}
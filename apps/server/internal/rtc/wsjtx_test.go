@@ -0,0 +1,145 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsjtx"
+)
+
+// wsjtxStatus is the datagram WSJT-X sends for a status on dialHz.
+func wsjtxStatus(id string, dialHz uint64) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 0xADBCCBDA)
+	b = binary.BigEndian.AppendUint32(b, 2)
+	b = binary.BigEndian.AppendUint32(b, 1)
+
+	for i, s := range []string{id, "", "FT8", "", "", "FT8"} {
+		if i == 1 {
+			b = binary.BigEndian.AppendUint64(b, dialHz)
+
+			continue
+		}
+
+		b = binary.BigEndian.AppendUint32(b, uint32(len(s))) //nolint:gosec // short
+		b = append(b, s...)
+	}
+
+	return append(b, 0, 0, 1) // tx enabled, transmitting, decoding
+}
+
+func TestWSJTXBridge(t *testing.T) {
+	t.Parallel()
+
+	srv, _, sent := rigctlRadio(t, "0")
+
+	svc, err := wsjtx.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = svc.Run(t.Context()) }()
+
+	srv.wsjtx = svc
+	svc.Subscribe(srv.publishWSJTX)
+
+	cs := srv.sessionList()[0]
+	cs.send = make(chan message, 8)
+	cs.handleWSJTX(json.RawMessage(`{"action":"subscribe"}`))
+
+	app, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = app.Close() }()
+
+	_, err = app.WriteToUDP(wsjtxStatus("WSJT-X", 7074000), svc.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-cs.send:
+		if msg.Type != typeWSJTX || !strings.Contains(string(msg.Payload), `"dialHz":7074000`) {
+			t.Errorf("event = %s %s", msg.Type, msg.Payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("status not forwarded")
+	}
+
+	cs.handleWSJTX(json.RawMessage(`{"action":"reply","id":"WSJT-X","slice":0,` +
+		`"decode":{"time":60000,"snr":-5,"dt":0.2,"df":900,"mode":"~","message":"CQ W1AW FN31"}}`))
+
+	_ = app.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 512)
+
+	n, _, err := app.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(buf[:n]), "CQ W1AW FN31") || binary.BigEndian.Uint32(buf[8:]) != 4 {
+		t.Errorf("reply datagram %q", buf[:n])
+	}
+
+	if got := sent(); len(got) != 1 || got[0] != "C2147483643|slice tune 0 7.074000" {
+		t.Errorf("radio got %q", got)
+	}
+}
+
+func TestWSJTXBridge_Refusals(t *testing.T) {
+	t.Parallel()
+
+	srv, _, sent := rigctlRadio(t, "0")
+	cs := srv.sessionList()[0]
+	cs.send = make(chan message, 8)
+
+	errorCode := func() string {
+		select {
+		case msg := <-cs.send:
+			var p errorPayload
+			_ = json.Unmarshal(msg.Payload, &p)
+
+			return p.Code
+		default:
+			return ""
+		}
+	}
+
+	cs.handleWSJTX(json.RawMessage(`{"action":"subscribe"}`))
+
+	if code := errorCode(); code != "WSJTX_FAILED" {
+		t.Errorf("subscribe without a bridge = %q", code)
+	}
+
+	svc, err := wsjtx.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = svc.Run(t.Context()) }()
+
+	srv.wsjtx = svc
+
+	cs.handleWSJTX(json.RawMessage(`{"action":"reply","id":"nobody","slice":0}`))
+
+	if code := errorCode(); code != "WSJTX_FAILED" {
+		t.Errorf("reply to an unknown instance = %q", code)
+	}
+
+	cs.sandbox.set(true)
+	cs.handleWSJTX(json.RawMessage(`{"action":"reply","id":"nobody"}`))
+
+	if code := errorCode(); code != "WSJTX_FAILED" {
+		t.Errorf("sandboxed reply = %q", code)
+	}
+
+	if got := sent(); len(got) != 0 {
+		t.Errorf("radio got %q", got)
+	}
+}
@@ -0,0 +1,315 @@
+package rtc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wsdeflate"
+	"github.com/gorilla/websocket"
+)
+
+// RadioWSPath drives a radio over a plain WebSocket, for scripts and native
+// clients that speak neither WebRTC nor WebTransport:
+//
+//	/ws/radio?radio=<host:port>[&sandbox=1][&station=<name>][&bind=<client_id>]
+//
+// It opens a headless session without UDP. Under RadioWSLines, or when the
+// client names no sub-protocol, each text message is one line, commands one
+// way and the radio's lines the other. Under RadioWSFrames, each binary
+// message is one or more frames: a tag byte, a big-endian uint32 length and
+// that many bytes. Lines are framed without their newline, so a frame's
+// bounds never depend on what it holds.
+const RadioWSPath = "/ws/radio"
+
+// Sub-protocols of RadioWSPath.
+const (
+	RadioWSLines  = "solidsdr.lines"
+	RadioWSFrames = "solidsdr.frames.v1"
+)
+
+// Frame tags under RadioWSFrames. The radio's lines are tagged by their
+// first character; FrameLine is any other. FrameSignal carries one JSON
+// signaling message, such as a "commandGuard" prompt to the client or its
+// "commandConfirm" back.
+const (
+	FrameLine    byte = 0
+	FrameCommand byte = 1 // client to radio
+	FrameReply   byte = 2 // R
+	FrameStatus  byte = 3 // S
+	FrameVersion byte = 4 // V
+	FrameHandle  byte = 5 // H
+	FrameMessage byte = 6 // M
+	FrameSignal  byte = 7
+)
+
+const (
+	// frameHeader is the tag and length before each frame.
+	frameHeader = 5
+	// maxFramedMessage bounds the lines joined into one binary message.
+	maxFramedMessage = 64 * 1024
+)
+
+var errFrame = errors.New("bad frame")
+
+// lineTags tags the radio's lines by their first character.
+var lineTags = map[byte]byte{
+	'R': FrameReply,
+	'S': FrameStatus,
+	'V': FrameVersion,
+	'H': FrameHandle,
+	'M': FrameMessage,
+}
+
+// ServeRadioWS handles GET RadioWSPath.
+func (s *Server) ServeRadioWS(w http.ResponseWriter, r *http.Request) {
+	up := s.upgrader
+	up.Subprotocols = []string{RadioWSFrames, RadioWSLines}
+
+	// The upgrade checks the request and its origin before a radio is
+	// dialed on its behalf.
+	ws, err := up.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	q := r.URL.Query()
+	clientIP := clientIPFromRequest(r)
+
+	hs, err := s.OpenHeadless(r.Context(), HeadlessOptions{
+		Radio:        q.Get("radio"),
+		ClientIP:     clientIP,
+		PeerIP:       peerIPFromRequest(r),
+		Sandbox:      q.Get("sandbox") == "1",
+		Station:      q.Get("station"),
+		BindClientID: q.Get("bind"),
+	})
+	if err != nil {
+		var perr *preflightError
+		if errors.As(err, &perr) {
+			closeRadioWS(ws, websocket.ClosePolicyViolation, perr.body.Code+": "+perr.body.Message)
+		} else {
+			closeRadioWS(ws, websocket.CloseInternalServerErr, "RADIO_UNREACHABLE: "+err.Error())
+		}
+
+		return
+	}
+
+	defer hs.Close()
+
+	framed := ws.Subprotocol() == RadioWSFrames
+	log.Printf("[rtc] radio WebSocket %s on session %s (framed=%t)", clientIP, hs.ID(), framed)
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+
+	go func() {
+		cancel(readRadioWS(ws, hs, framed))
+	}()
+
+	err = writeRadioWS(ctx, ws, hs, framed, s.deflate)
+
+	code, reason := websocket.CloseNormalClosure, ""
+
+	switch {
+	case errors.Is(err, errFrame):
+		code, reason = websocket.CloseProtocolError, err.Error()
+	case err != nil:
+		code, reason = websocket.CloseGoingAway, err.Error()
+	case ctx.Err() == nil:
+		code, reason = websocket.CloseGoingAway, "radio link closed"
+	}
+
+	closeRadioWS(ws, code, reason)
+}
+
+// closeRadioWS closes ws with code and as much of reason as fits.
+func closeRadioWS(ws *websocket.Conn, code int, reason string) {
+	if len(reason) > 120 {
+		reason = reason[:120] // a close frame's payload is at most 125 bytes
+	}
+
+	_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
+
+// writeRadioWS sends the radio's lines, and when framed the session's
+// notices, until the session or ctx ends. It returns why ctx ended, if the
+// reader ended it over a bad message.
+func writeRadioWS(ctx context.Context, ws *websocket.Conn, hs *Headless, framed bool, deflate wsdeflate.Policy) error {
+	var notices <-chan Notice
+	if framed {
+		notices = hs.Notices()
+	}
+
+	for {
+		var (
+			msgType = websocket.TextMessage
+			msg     []byte
+		)
+
+		select {
+		case <-ctx.Done():
+			err := context.Cause(ctx)
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+
+			return err
+		case <-hs.Done():
+			return nil
+		case line := <-hs.Lines():
+			if !framed {
+				msg = []byte(strings.TrimRight(line, "\r\n"))
+
+				break
+			}
+
+			msgType, msg = websocket.BinaryMessage, appendLineFrame(nil, line)
+
+			// Join whatever else is waiting, as a burst of status would
+			// otherwise cost a message per line.
+			for more := true; more && len(msg) < maxFramedMessage; {
+				select {
+				case line := <-hs.Lines():
+					msg = appendLineFrame(msg, line)
+				default:
+					more = false
+				}
+			}
+		case n := <-notices:
+			b, err := json.Marshal(n)
+			if err != nil {
+				continue
+			}
+
+			msgType, msg = websocket.BinaryMessage, appendFrame(nil, FrameSignal, b)
+		}
+
+		_ = ws.SetWriteDeadline(time.Now().Add(tunnelWriteTimeout))
+
+		err := deflate.Write(ws, msgType, msg, true)
+		if err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+}
+
+// readRadioWS passes the client's commands, and when framed its signaling
+// messages, to the session until the client goes away or sends a message it
+// should not.
+func readRadioWS(ws *websocket.Conn, hs *Headless, framed bool) error {
+	for {
+		msgType, msg, err := ws.ReadMessage()
+		if err != nil {
+			return nil //nolint:nilerr // the client went away
+		}
+
+		if !framed {
+			if msgType != websocket.TextMessage {
+				return fmt.Errorf("%w: binary message without %s", errFrame, RadioWSFrames)
+			}
+
+			err = radioWSCommand(hs, string(msg))
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if msgType != websocket.BinaryMessage {
+			return fmt.Errorf("%w: text message under %s", errFrame, RadioWSFrames)
+		}
+
+		err = eachFrame(msg, func(tag byte, data []byte) error {
+			switch tag {
+			case FrameCommand:
+				return radioWSCommand(hs, string(data))
+			case FrameSignal:
+				var n Notice
+
+				err := json.Unmarshal(data, &n)
+				if err != nil {
+					return fmt.Errorf("%w: signal: %w", errFrame, err)
+				}
+
+				err = hs.Signal(n.Type, n.Payload)
+				if err != nil {
+					return fmt.Errorf("signal: %w", err)
+				}
+
+				return nil
+			default:
+				return fmt.Errorf("%w: tag %d from the client", errFrame, tag)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func radioWSCommand(hs *Headless, line string) error {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil
+	}
+
+	err := hs.Command(line)
+	if err != nil {
+		return fmt.Errorf("command: %w", err)
+	}
+
+	return nil
+}
+
+// appendLineFrame appends a frame of one of the radio's lines, tagged by
+// its first character.
+func appendLineFrame(b []byte, line string) []byte {
+	line = strings.TrimRight(line, "\r\n")
+
+	tag := FrameLine
+	if line != "" {
+		tag = lineTags[line[0]]
+	}
+
+	return appendFrame(b, tag, []byte(line))
+}
+
+func appendFrame(b []byte, tag byte, data []byte) []byte {
+	b = append(b, tag)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data))) //nolint:gosec // within a WebSocket message
+
+	return append(b, data...)
+}
+
+// eachFrame calls f with each frame of msg, stopping at its first error.
+func eachFrame(msg []byte, f func(tag byte, data []byte) error) error {
+	for len(msg) > 0 {
+		if len(msg) < frameHeader {
+			return fmt.Errorf("%w: %d bytes left over", errFrame, len(msg))
+		}
+
+		n := binary.BigEndian.Uint32(msg[1:frameHeader])
+		if uint64(n) > uint64(len(msg)-frameHeader) {
+			return fmt.Errorf("%w: length %d overruns the message", errFrame, n)
+		}
+
+		err := f(msg[0], msg[frameHeader:frameHeader+int(n)])
+		if err != nil {
+			return err
+		}
+
+		msg = msg[frameHeader+int(n):]
+	}
+
+	return nil
+}
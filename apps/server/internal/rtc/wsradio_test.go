@@ -0,0 +1,256 @@
+package rtc
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc/rtctest"
+	"github.com/gorilla/websocket"
+)
+
+type frame struct {
+	tag  byte
+	data string
+}
+
+func dialRadioWS(t *testing.T, protocols ...string) (*websocket.Conn, <-chan string) {
+	t.Helper()
+
+	addr, got := rtctest.FakeRadio(t)
+	s := &Server{sessions: map[string]*clientSession{}}
+
+	srv := httptest.NewServer(http.HandlerFunc(s.ServeRadioWS))
+	t.Cleanup(srv.Close)
+
+	dialer := websocket.Dialer{Subprotocols: protocols}
+
+	ws, resp, err := dialer.DialContext(t.Context(), "ws"+strings.TrimPrefix(srv.URL, "http")+"?radio="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	t.Cleanup(func() { _ = ws.Close() })
+
+	return ws, got
+}
+
+// nextFrames reads frames of the radio's lines until it has n, skipping the
+// session's notices.
+func nextFrames(t *testing.T, ws *websocket.Conn, n int) []frame {
+	t.Helper()
+
+	var out []frame
+
+	for len(out) < n {
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		typ, msg, err := ws.ReadMessage()
+		if err != nil || typ != websocket.BinaryMessage {
+			t.Fatalf("read %d, %v", typ, err)
+		}
+
+		err = eachFrame(msg, func(tag byte, data []byte) error {
+			if tag != FrameSignal {
+				out = append(out, frame{tag, string(data)})
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return out
+}
+
+func TestServeRadioWS_Frames(t *testing.T) {
+	t.Parallel()
+
+	ws, got := dialRadioWS(t, RadioWSFrames)
+	if ws.Subprotocol() != RadioWSFrames {
+		t.Fatalf("sub-protocol %q", ws.Subprotocol())
+	}
+
+	want := []frame{{FrameVersion, "V1.4.0.0"}, {FrameHandle, "H1234ABCD"}}
+	if f := nextFrames(t, ws, 2); f[0] != want[0] || f[1] != want[1] {
+		t.Errorf("greeting = %+v", f)
+	}
+
+	err := ws.WriteMessage(websocket.BinaryMessage, appendFrame(nil, FrameCommand, []byte("C7|info")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, got); l != "C7|info" {
+		t.Errorf("radio got %q", l)
+	}
+
+	if f := nextFrames(t, ws, 1); f[0] != (frame{FrameReply, "R7|0|"}) {
+		t.Errorf("reply = %+v", f)
+	}
+}
+
+func TestServeRadioWS_Lines(t *testing.T) {
+	t.Parallel()
+
+	ws, got := dialRadioWS(t)
+
+	for _, want := range []string{"V1.4.0.0", "H1234ABCD"} {
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		typ, msg, err := ws.ReadMessage()
+		if err != nil || typ != websocket.TextMessage || string(msg) != want {
+			t.Fatalf("read %d %q, %v; want %q", typ, msg, err, want)
+		}
+	}
+
+	err := ws.WriteMessage(websocket.TextMessage, []byte("C8|slice list\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := nextLine(t, got); l != "C8|slice list" {
+		t.Errorf("radio got %q", l)
+	}
+}
+
+func TestServeRadioWS_BadFrameCloses(t *testing.T) {
+	t.Parallel()
+
+	ws, _ := dialRadioWS(t, RadioWSFrames)
+	nextFrames(t, ws, 2)
+
+	// A length past the end of the message.
+	err := ws.WriteMessage(websocket.BinaryMessage, []byte{FrameCommand, 0, 0, 0, 9, 'C'})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		_, _, err = ws.ReadMessage()
+		if err != nil {
+			break
+		}
+	}
+
+	if !websocket.IsCloseError(err, websocket.CloseProtocolError) {
+		t.Errorf("closed with %v", err)
+	}
+}
+
+func TestServeRadioWS_NoRadioBeforeUpgrade(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	dialed := make(chan struct{}, 1)
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = c.Close()
+
+			select {
+			case dialed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+
+	for name, r := range map[string]*http.Request{
+		"plain GET":    httptest.NewRequest(http.MethodGet, "/ws/radio?radio="+ln.Addr().String(), nil),
+		"cross-origin": wsRequest("/ws/radio?radio="+ln.Addr().String(), "https://elsewhere.example"),
+	} {
+		w := httptest.NewRecorder()
+		s.ServeRadioWS(w, r)
+
+		if w.Code < 400 {
+			t.Errorf("%s: status %d", name, w.Code)
+		}
+	}
+
+	// The radio would have been dialed before the handler returned.
+	select {
+	case <-dialed:
+		t.Error("radio dialed for a request that wasn't upgraded")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestServeRadioWS_RefusedRadioCloses(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}, allowedRadios: []string{"192.0.2.10"}}
+
+	srv := httptest.NewServer(http.HandlerFunc(s.ServeRadioWS))
+	t.Cleanup(srv.Close)
+
+	ws, resp, err := websocket.DefaultDialer.DialContext(t.Context(), "ws"+strings.TrimPrefix(srv.URL, "http")+"?radio=192.0.2.11:4992", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	defer func() { _ = ws.Close() }()
+
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	_, _, err = ws.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) || !strings.Contains(err.Error(), "RADIO_NOT_ALLOWED") {
+		t.Errorf("closed with %v", err)
+	}
+}
+
+// wsRequest is a WebSocket upgrade request for target from origin.
+func wsRequest(target, origin string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("Origin", origin)
+
+	return r
+}
+
+func TestEachFrame(t *testing.T) {
+	t.Parallel()
+
+	msg := appendLineFrame(appendLineFrame(nil, "S1|radio slices=4\n"), "\n")
+
+	var got []frame
+
+	err := eachFrame(msg, func(tag byte, data []byte) error {
+		got = append(got, frame{tag, string(data)})
+
+		return nil
+	})
+	if err != nil || len(got) != 2 || got[0] != (frame{FrameStatus, "S1|radio slices=4"}) || got[1] != (frame{FrameLine, ""}) {
+		t.Errorf("frames %+v, %v", got, err)
+	}
+
+	if err := eachFrame(msg[:3], func(byte, []byte) error { return nil }); err == nil {
+		t.Error("short frame accepted")
+	}
+}
@@ -0,0 +1,241 @@
+package rtc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// UDPTunnelPath streams a session's radio data over a plain WebSocket, for
+// loggers and dashboards that want meters or the panadapter without
+// negotiating WebRTC:
+//
+//	/ws/udp?session=<id>[&kind=meter,fft,...]
+//
+// Each binary message is one radio packet: its VITA-49 class code (uint16)
+// and stream ID (uint32), both big-endian, then its payload.
+const UDPTunnelPath = "/ws/udp"
+
+const (
+	// tunnelBuffer is how many packets a slow tunnel may lag before
+	// packets are dropped for it.
+	tunnelBuffer = 256
+	// tunnelWriteTimeout ends a tunnel whose client stops reading.
+	tunnelWriteTimeout = 10 * time.Second
+	// VITA-49 class code of the radio's meters.
+	vitaMeterClass = 0x8002
+)
+
+var errTunnelKind = errors.New("unknown kind")
+
+// tunnelKinds maps the kinds a tunnel may ask for to the packets they are.
+var tunnelKinds = map[string]func(class uint16) bool{
+	"meter":     func(c uint16) bool { return c == vitaMeterClass },
+	"fft":       func(c uint16) bool { return c == vitaFFTClass },
+	"waterfall": func(c uint16) bool { return c == vitaWaterfallClass },
+	"audio":     func(c uint16) bool { return c == vitaFlexOpusClass },
+	"iq":        func(c uint16) bool { return iqSampleRate(c) != 0 },
+}
+
+// udpTunnels copies a radio link's packets to its tunnels. A full tunnel
+// drops packets rather than stalling the pipeline.
+type udpTunnels struct {
+	mu     sync.Mutex
+	subs   map[*udpTunnel]struct{}
+	closed bool
+}
+
+// udpTunnel is one WebSocket's packets; a nil want takes every kind.
+type udpTunnel struct {
+	ch   chan []byte
+	want []func(class uint16) bool
+}
+
+// subscribe returns a tunnel, whose channel is closed when the radio link
+// closes, or nil if the link is already closed.
+func (t *udpTunnels) subscribe(want []func(class uint16) bool) *udpTunnel {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	if t.subs == nil {
+		t.subs = make(map[*udpTunnel]struct{})
+	}
+
+	sub := &udpTunnel{ch: make(chan []byte, tunnelBuffer), want: want}
+	t.subs[sub] = struct{}{}
+
+	return sub
+}
+
+func (t *udpTunnels) unsubscribe(sub *udpTunnel) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.subs[sub]; ok {
+		delete(t.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// publish frames v for every tunnel that wants it.
+func (t *udpTunnels) publish(v vitaView) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var msg []byte
+
+	for sub := range t.subs {
+		if !sub.wants(v.ClassCode) {
+			continue
+		}
+
+		if msg == nil {
+			msg = binary.BigEndian.AppendUint16(make([]byte, 0, 6+len(v.Payload)), v.ClassCode)
+			msg = binary.BigEndian.AppendUint32(msg, v.StreamID)
+			msg = append(msg, v.Payload...)
+		}
+
+		select {
+		case sub.ch <- msg:
+		default:
+		}
+	}
+}
+
+func (t *udpTunnels) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+
+	for sub := range t.subs {
+		close(sub.ch)
+	}
+
+	t.subs = nil
+}
+
+func (sub *udpTunnel) wants(class uint16) bool {
+	if sub.want == nil {
+		return true
+	}
+
+	for _, w := range sub.want {
+		if w(class) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTunnelKinds reads a comma-separated kind list; empty means every kind.
+func parseTunnelKinds(list string) ([]func(class uint16) bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var want []func(class uint16) bool
+
+	for k := range strings.SplitSeq(list, ",") {
+		f, ok := tunnelKinds[strings.TrimSpace(k)]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", errTunnelKind, k)
+		}
+
+		want = append(want, f)
+	}
+
+	return want, nil
+}
+
+// ServeUDPTunnel handles GET UDPTunnelPath.
+func (s *Server) ServeUDPTunnel(w http.ResponseWriter, r *http.Request) {
+	want, err := parseTunnelKinds(r.URL.Query().Get("kind"))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, "BAD_KIND", err.Error())
+
+		return
+	}
+
+	s.serveTunnel(w, r, r.URL.Query().Get("session"), want)
+}
+
+// serveTunnel streams the packets of session id that want takes.
+func (s *Server) serveTunnel(w http.ResponseWriter, r *http.Request, id string, want []func(class uint16) bool) {
+	rc := s.audioSource(w, id)
+	if rc == nil {
+		return
+	}
+
+	sub := rc.tunnels.subscribe(want)
+	if sub == nil {
+		writeHTTPError(w, http.StatusConflict, "NO_RADIO", "session has no radio connection")
+
+		return
+	}
+	defer rc.tunnels.unsubscribe(sub)
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Reading handles the client's pings and close.
+	go func() {
+		defer cancel()
+
+		for {
+			_, _, err := ws.NextReader()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	clientIP := clientIPFromRequest(r)
+	log.Printf("[rtc] UDP tunnel %s on session %s", clientIP, id)
+
+	ev := StreamEvent{Session: id, Kind: StreamUDPTunnel, ClientIP: clientIP}
+	s.publishStream(true, ev)
+
+	defer s.publishStream(false, ev)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-sub.ch:
+			if !ok {
+				_ = ws.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "radio link closed"), time.Now().Add(time.Second))
+
+				return
+			}
+
+			_ = ws.SetWriteDeadline(time.Now().Add(tunnelWriteTimeout))
+
+			err := s.deflate.Write(ws, websocket.BinaryMessage, msg, false)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestServeUDPTunnel(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{sessions: map[string]*clientSession{}}
+	rc := &radioConn{}
+	s.addSession(&clientSession{id: "op", srv: s, radio: rc})
+
+	srv := httptest.NewServer(http.HandlerFunc(s.ServeUDPTunnel))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.DialContext(t.Context(), url+"?session=op&kind=bogus", nil)
+	if err == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad kind accepted: %v", err)
+	}
+
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+
+	ws, resp, err := websocket.DefaultDialer.DialContext(t.Context(), url+"?session=op&kind=meter,fft", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	defer func() { _ = ws.Close() }()
+
+	// The subscription is made before the upgrade, so publishing now
+	// reaches it.
+	rc.tunnels.publish(vitaView{ClassCode: vitaFlexOpusClass, StreamID: 0x04000008, Payload: []byte{1}})
+	rc.tunnels.publish(vitaView{ClassCode: vitaMeterClass, StreamID: 0x700, Payload: []byte{2, 3}})
+
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	typ, msg, err := ws.ReadMessage()
+	if err != nil || typ != websocket.BinaryMessage {
+		t.Fatalf("read %d, %v", typ, err)
+	}
+
+	if binary.BigEndian.Uint16(msg) != vitaMeterClass || binary.BigEndian.Uint32(msg[2:]) != 0x700 || string(msg[6:]) != "\x02\x03" {
+		t.Errorf("message % x", msg)
+	}
+
+	// Closing the radio link ends the tunnel.
+	rc.tunnels.close()
+
+	_, _, err = ws.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("after the link closed: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errSpec = errors.New("invalid cron expression")
+
+// maxLookahead bounds the search for a spec's next time; a spec such as
+// "0 0 30 2 *" never fires.
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+// shorthands are the named specs.
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Spec is a cron expression: minute, hour, day of month, month and day of
+// week, e.g. "30 22 * * mon-fri", or one of @hourly, @daily, @weekly,
+// @monthly and @yearly. Fields take *, numbers, names (jan, mon), ranges,
+// lists and steps (*/15). When both day fields are restricted, either may
+// match, as in cron.
+type Spec struct {
+	expr string
+
+	minute, hour, dom, month, dow uint64
+	// anyDOM and anyDOW are set when the field is *.
+	anyDOM, anyDOW bool
+}
+
+// ParseSpec parses a cron expression.
+func ParseSpec(expr string) (Spec, error) {
+	s := Spec{expr: expr}
+
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		full, ok := shorthands[strings.ToLower(fields[0])]
+		if !ok {
+			return Spec{}, fmt.Errorf("%w: %q", errSpec, expr)
+		}
+
+		fields = strings.Fields(full)
+	}
+
+	if len(fields) != 5 {
+		return Spec{}, fmt.Errorf("%w: %q needs 5 fields", errSpec, expr)
+	}
+
+	var err error
+
+	for i, f := range []struct {
+		set      *uint64
+		min, max int
+		names    []string
+	}{
+		{&s.minute, 0, 59, nil},
+		{&s.hour, 0, 23, nil},
+		{&s.dom, 1, 31, nil},
+		{&s.month, 1, 12, monthNames},
+		{&s.dow, 0, 7, dayNames},
+	} {
+		*f.set, err = parseField(fields[i], f.min, f.max, f.names)
+		if err != nil {
+			return Spec{}, fmt.Errorf("%w: %q: %w", errSpec, expr, err)
+		}
+	}
+
+	// 7 is Sunday too.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	s.anyDOM = fields[2] == "*"
+	s.anyDOW = fields[4] == "*"
+
+	return s, nil
+}
+
+// parseField parses one field into a set of values between lo and hi.
+func parseField(field string, lo, hi int, names []string) (uint64, error) {
+	var set uint64
+
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepText, stepped := strings.Cut(part, "/")
+
+		step := 1
+
+		if stepped {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("step %q", stepText)
+			}
+
+			step = n
+		}
+
+		first, last := lo, hi
+
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+
+			var err error
+
+			first, err = fieldValue(from, lo, hi, names)
+			if err != nil {
+				return 0, err
+			}
+
+			last = first
+			if isRange {
+				last, err = fieldValue(to, lo, hi, names)
+				if err != nil {
+					return 0, err
+				}
+			} else if stepped {
+				last = hi
+			}
+
+			if last < first {
+				return 0, fmt.Errorf("range %q", rng)
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+func fieldValue(text string, lo, hi int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(text, name) {
+			return i + lo, nil
+		}
+	}
+
+	n, err := strconv.Atoi(text)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("value %q out of %d-%d", text, lo, hi)
+	}
+
+	return n, nil
+}
+
+// String is the expression the spec was parsed from.
+func (s Spec) String() string {
+	return s.expr
+}
+
+// Next is the first time after t the spec fires, in t's location, or zero
+// if it never does.
+func (s Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.Add(maxLookahead)
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// matches reports whether the spec fires in t's minute.
+func (s Spec) matches(t time.Time) bool {
+	return s.month&(1<<int(t.Month())) != 0 && s.dayMatches(t) &&
+		s.hour&(1<<t.Hour()) != 0 && s.minute&(1<<t.Minute()) != 0
+}
+
+func (s Spec) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+
+	switch {
+	case s.anyDOM && s.anyDOW:
+		return true
+	case s.anyDOM:
+		return dow
+	case s.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// prev is the last minute, up to and including t's, in which the spec fired
+// within catchUp of t, or zero.
+func (s Spec) prev(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+
+	for range int(catchUp / time.Minute) {
+		if s.matches(t) {
+			return t
+		}
+
+		t = t.Add(-time.Minute)
+	}
+
+	return time.Time{}
+}
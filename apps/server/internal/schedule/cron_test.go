@@ -0,0 +1,93 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSpec_Rejects(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@often", "* * * foo *"} {
+		if _, err := ParseSpec(expr); !errors.Is(err, errSpec) {
+			t.Errorf("ParseSpec(%q) = %v", expr, err)
+		}
+	}
+}
+
+func TestSpec_Next(t *testing.T) {
+	t.Parallel()
+
+	// A Thursday.
+	from := time.Date(2026, 10, 15, 12, 34, 56, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 15, 12, 35, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 15, 12, 45, 0, 0, time.UTC)},
+		{"0 22 * * *", time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC)},
+		{"30 6 * * mon-fri", time.Date(2026, 10, 16, 6, 30, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are set: the 20th, or a Monday.
+		{"0 12 20 * mon", time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, c := range cases {
+		s, err := ParseSpec(c.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", c.expr, err)
+		}
+
+		if got := s.Next(from); !got.Equal(c.want) {
+			t.Errorf("%q: next = %s, want %s", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestSpec_NextInLocation(t *testing.T) {
+	t.Parallel()
+
+	loc := time.FixedZone("EST", -5*60*60)
+
+	s, err := ParseSpec("0 22 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := s.Next(time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC).In(loc))
+	if want := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("next = %s, want %s", got.UTC(), want)
+	}
+}
+
+func TestSpec_Prev(t *testing.T) {
+	t.Parallel()
+
+	s, err := ParseSpec("0 22 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2026, 10, 15, 3, 10, 0, 0, time.UTC)
+	if got, want := s.prev(at), time.Date(2026, 10, 14, 22, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("prev = %s, want %s", got, want)
+	}
+
+	s, err = ParseSpec("0 0 1 jan *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.prev(at); !got.IsZero() {
+		t.Errorf("prev beyond catch-up = %s", got)
+	}
+}
@@ -0,0 +1,60 @@
+package schedule
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/wol"
+)
+
+// postTimeout bounds a power job's request.
+const postTimeout = 10 * time.Second
+
+var errStatus = errors.New("unexpected response")
+
+// powerOn wakes j's radio by wake-on-LAN and its URL, whichever are set.
+func (s *Service) powerOn(ctx context.Context, j Job) error {
+	var errs []error
+
+	if j.MAC != "" {
+		errs = append(errs, wol.Send(j.MAC, cmp.Or(s.opt.Broadcast, wol.DefaultBroadcast)))
+	}
+
+	if j.URL != "" {
+		errs = append(errs, post(ctx, j.URL))
+	}
+
+	return errors.Join(errs...)
+}
+
+// post POSTs an empty body to target, e.g. a relay's or smart plug's switch
+// URL.
+func post(ctx context.Context, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, postTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: %s", errStatus, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,390 @@
+// Package schedule runs actions on the bridge's radios at the times of cron
+// expressions: switching a radio on or off, loading a profile, recording, or
+// muting TX for quiet hours.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/events"
+)
+
+// Actions of a Job.
+const (
+	// ActionPowerOn wakes the radio with a wake-on-LAN packet to the job's
+	// MAC, and POSTs to its URL, e.g. a remote-on relay or a smart plug.
+	ActionPowerOn = "power-on"
+	// ActionPowerOff POSTs to the job's URL.
+	ActionPowerOff = "power-off"
+	// ActionProfile loads the job's global profile.
+	ActionProfile = "profile"
+	// ActionRecord records the radio's RX audio for the job's Duration.
+	ActionRecord = "record"
+	// ActionMuteTX refuses keying the radio until an unmute-tx job runs or,
+	// when set, the job's Duration has passed.
+	ActionMuteTX = "mute-tx"
+	// ActionUnmuteTX lifts mute-tx.
+	ActionUnmuteTX = "unmute-tx"
+)
+
+// EventRun is published each time a job runs.
+const EventRun = "schedule.run"
+
+const (
+	// checkInterval is how often due jobs are looked for.
+	checkInterval = time.Second
+	// catchUp is how far back a restarted bridge looks for the mute-tx or
+	// unmute-tx job that last ran on a radio.
+	catchUp = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrUnknownJob is returned for a job name that is not configured.
+	ErrUnknownJob = errors.New("no such job")
+
+	errAction = errors.New("unknown action")
+)
+
+// Radios carries out actions on the bridge's radios, named by host:port or
+// host.
+type Radios interface {
+	LoadProfile(radio, name string) error
+	RecordRadio(radio, format string, d time.Duration) error
+	// MuteTX and UnmuteTX take an empty radio as every radio.
+	MuteTX(radio, reason string)
+	UnmuteTX(radio string)
+}
+
+// Job is one scheduled action.
+type Job struct {
+	Name   string
+	Spec   Spec
+	Action string
+	Radio  string
+	// Profile is the global profile a profile job loads.
+	Profile string
+	// Duration is how long a record job records, or a mute-tx job mutes;
+	// zero records until stopped, or mutes until an unmute-tx job runs.
+	Duration time.Duration
+	// Format is a record job's file format, ogg or wav.
+	Format string
+	// MAC is the radio's, woken by a power-on job.
+	MAC string
+	// URL is POSTed to by a power-on or power-off job.
+	URL      string
+	Disabled bool
+}
+
+// Options configures the service.
+type Options struct {
+	Jobs   []Job
+	Radios Radios
+	// Location is where jobs' times are read; nil means time.Local.
+	Location *time.Location
+	// Broadcast is where wake-on-LAN packets are sent; empty means
+	// wol.DefaultBroadcast.
+	Broadcast string
+	Events    *events.Bus
+}
+
+// JobStatus describes a job and when it runs.
+type JobStatus struct {
+	Name    string     `json:"name"`
+	Cron    string     `json:"cron"`
+	Action  string     `json:"action"`
+	Radio   string     `json:"radio,omitempty"`
+	Enabled bool       `json:"enabled"`
+	Next    *time.Time `json:"next,omitempty"`
+	Last    *Result    `json:"last,omitempty"`
+}
+
+// Result is how a job's last run went.
+type Result struct {
+	At time.Time `json:"at"`
+	// Manual is set when the run was asked for rather than scheduled.
+	Manual bool   `json:"manual,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runEvent is EventRun's data.
+type runEvent struct {
+	Job    string `json:"job"`
+	Action string `json:"action"`
+	Radio  string `json:"radio,omitempty"`
+	Result
+}
+
+type jobState struct {
+	Job
+
+	enabled bool
+	next    time.Time
+	last    *Result
+	// unmute lifts a mute-tx job's mute after its Duration.
+	unmute *time.Timer
+}
+
+// Service runs jobs when they are due.
+type Service struct {
+	opt Options
+	loc *time.Location
+
+	mu   sync.Mutex
+	jobs []*jobState
+}
+
+// New returns a service running opt's jobs once Run is called.
+func New(opt Options) *Service {
+	s := &Service{opt: opt, loc: opt.Location}
+	if s.loc == nil {
+		s.loc = time.Local
+	}
+
+	for _, j := range opt.Jobs {
+		s.jobs = append(s.jobs, &jobState{Job: j, enabled: !j.Disabled})
+	}
+
+	return s
+}
+
+// Run restores the TX mutes that should be in force, then runs jobs as they
+// fall due until ctx is done.
+func (s *Service) Run(ctx context.Context) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		if j.enabled {
+			j.next = j.Spec.Next(now.In(s.loc))
+		}
+	}
+	s.mu.Unlock()
+
+	s.restoreMutes(now)
+
+	t := time.NewTicker(checkInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			for _, j := range s.jobs {
+				if j.unmute != nil {
+					j.unmute.Stop()
+				}
+			}
+			s.mu.Unlock()
+
+			return nil
+		case now := <-t.C:
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+// runDue runs, in order, the jobs due at now.
+func (s *Service) runDue(ctx context.Context, now time.Time) {
+	var due []*jobState
+
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		if j.enabled && !j.next.IsZero() && !now.Before(j.next) {
+			due = append(due, j)
+			j.next = j.Spec.Next(now.In(s.loc))
+		}
+	}
+	s.mu.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	go func() {
+		for _, j := range due {
+			_ = s.run(ctx, j, now, false)
+		}
+	}()
+}
+
+// restoreMutes applies, for each radio, the last mute-tx or unmute-tx job
+// that would have run before now, so a bridge restarted during quiet hours
+// keeps TX muted.
+func (s *Service) restoreMutes(now time.Time) {
+	type lastRun struct {
+		job *jobState
+		at  time.Time
+	}
+
+	latest := make(map[string]lastRun)
+
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		if !j.enabled || (j.Action != ActionMuteTX && j.Action != ActionUnmuteTX) {
+			continue
+		}
+
+		at := j.Spec.prev(now.In(s.loc))
+		if !at.IsZero() && at.After(latest[j.Radio].at) {
+			latest[j.Radio] = lastRun{job: j, at: at}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, l := range latest {
+		if l.job.Action != ActionMuteTX {
+			continue
+		}
+
+		left := l.job.Duration - now.Sub(l.at)
+		if l.job.Duration > 0 && left <= 0 {
+			continue
+		}
+
+		log.Printf("[schedule] %s: TX muted since %s", l.job.Name, l.at.Format(time.RFC3339))
+		s.mute(l.job, left)
+	}
+}
+
+// Jobs describes every job, in configured order.
+func (s *Service) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j.status())
+	}
+
+	return out
+}
+
+// RunNow runs the job called name at once, whether or not it is enabled.
+func (s *Service) RunNow(ctx context.Context, name string) (JobStatus, error) {
+	j := s.job(name)
+	if j == nil {
+		return JobStatus{}, fmt.Errorf("%w: %q", ErrUnknownJob, name)
+	}
+
+	err := s.run(ctx, j, time.Now(), true)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return j.status(), err
+}
+
+// SetEnabled enables or disables the job called name.
+func (s *Service) SetEnabled(name string, on bool) (JobStatus, error) {
+	j := s.job(name)
+	if j == nil {
+		return JobStatus{}, fmt.Errorf("%w: %q", ErrUnknownJob, name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j.enabled = on
+	j.next = time.Time{}
+
+	if on {
+		j.next = j.Spec.Next(time.Now().In(s.loc))
+	}
+
+	return j.status(), nil
+}
+
+func (s *Service) job(name string) *jobState {
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return j
+		}
+	}
+
+	return nil
+}
+
+// run carries out j and records how it went.
+func (s *Service) run(ctx context.Context, j *jobState, now time.Time, manual bool) error {
+	err := s.do(ctx, j)
+
+	res := Result{At: now, Manual: manual}
+	if err != nil {
+		res.Error = err.Error()
+		log.Printf("[schedule] %s (%s): %v", j.Name, j.Action, err)
+	} else {
+		log.Printf("[schedule] %s: %s done", j.Name, j.Action)
+	}
+
+	s.mu.Lock()
+	j.last = &res
+	s.mu.Unlock()
+
+	s.opt.Events.Publish(EventRun, runEvent{Job: j.Name, Action: j.Action, Radio: j.Radio, Result: res})
+
+	return err
+}
+
+func (s *Service) do(ctx context.Context, j *jobState) error {
+	switch j.Action {
+	case ActionPowerOn:
+		return s.powerOn(ctx, j.Job)
+	case ActionPowerOff:
+		return post(ctx, j.URL)
+	case ActionProfile:
+		return s.opt.Radios.LoadProfile(j.Radio, j.Profile)
+	case ActionRecord:
+		return s.opt.Radios.RecordRadio(j.Radio, j.Format, j.Duration)
+	case ActionMuteTX:
+		s.mute(j, j.Duration)
+
+		return nil
+	case ActionUnmuteTX:
+		s.opt.Radios.UnmuteTX(j.Radio)
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q", errAction, j.Action)
+}
+
+// mute mutes j's radio, lifting the mute after d unless it is zero.
+func (s *Service) mute(j *jobState, d time.Duration) {
+	s.opt.Radios.MuteTX(j.Radio, fmt.Sprintf("TX muted by schedule %q", j.Name))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.unmute != nil {
+		j.unmute.Stop()
+		j.unmute = nil
+	}
+
+	if d > 0 {
+		j.unmute = time.AfterFunc(d, func() { s.opt.Radios.UnmuteTX(j.Radio) })
+	}
+}
+
+// status describes j; s.mu is held.
+func (j *jobState) status() JobStatus {
+	st := JobStatus{
+		Name:    j.Name,
+		Cron:    j.Spec.String(),
+		Action:  j.Action,
+		Radio:   j.Radio,
+		Enabled: j.enabled,
+		Last:    j.last,
+	}
+
+	if !j.next.IsZero() {
+		next := j.next
+		st.Next = &next
+	}
+
+	return st
+}
@@ -0,0 +1,248 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRadios records the actions it is asked for.
+type fakeRadios struct {
+	mu    sync.Mutex
+	calls []string
+	err   error
+}
+
+func (f *fakeRadios) note(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeRadios) LoadProfile(radio, name string) error {
+	f.note("profile %s %s", radio, name)
+
+	return f.err
+}
+
+func (f *fakeRadios) RecordRadio(radio, format string, d time.Duration) error {
+	f.note("record %s %s %s", radio, format, d)
+
+	return f.err
+}
+
+func (f *fakeRadios) MuteTX(radio, reason string) { f.note("mute %s %s", radio, reason) }
+
+func (f *fakeRadios) UnmuteTX(radio string) { f.note("unmute %s", radio) }
+
+func (f *fakeRadios) got() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.calls)
+}
+
+func mustSpec(t *testing.T, expr string) Spec {
+	t.Helper()
+
+	s, err := ParseSpec(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestRunNow_Actions(t *testing.T) {
+	t.Parallel()
+
+	radios := &fakeRadios{}
+	s := New(Options{Radios: radios, Jobs: []Job{
+		{Name: "contest", Spec: mustSpec(t, "0 0 * * sat"), Action: ActionProfile, Radio: "shack", Profile: "Contest"},
+		{Name: "net", Spec: mustSpec(t, "0 19 * * tue"), Action: ActionRecord, Radio: "shack", Format: "wav", Duration: time.Hour, Disabled: true},
+		{Name: "quiet", Spec: mustSpec(t, "0 22 * * *"), Action: ActionMuteTX},
+		{Name: "morning", Spec: mustSpec(t, "0 7 * * *"), Action: ActionUnmuteTX},
+	}})
+
+	for _, name := range []string{"contest", "net", "quiet", "morning"} {
+		st, err := s.RunNow(t.Context(), name)
+		if err != nil || st.Last == nil || !st.Last.Manual {
+			t.Fatalf("%s: %+v (%v)", name, st, err)
+		}
+	}
+
+	want := []string{"profile shack Contest", "record shack wav 1h0m0s", `mute  TX muted by schedule "quiet"`, "unmute "}
+	if got := radios.got(); !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+
+	if _, err := s.RunNow(t.Context(), "bogus"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("unknown job: %v", err)
+	}
+
+	radios.err = errors.New("no session")
+
+	st, err := s.RunNow(t.Context(), "contest")
+	if err == nil || st.Last.Error != "no session" {
+		t.Errorf("failed run: %+v (%v)", st, err)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{Radios: &fakeRadios{}, Jobs: []Job{
+		{Name: "quiet", Spec: mustSpec(t, "0 22 * * *"), Action: ActionMuteTX, Disabled: true},
+	}})
+
+	if jobs := s.Jobs(); jobs[0].Enabled || jobs[0].Next != nil || jobs[0].Cron != "0 22 * * *" {
+		t.Fatalf("jobs = %+v", jobs)
+	}
+
+	st, err := s.SetEnabled("quiet", true)
+	if err != nil || !st.Enabled || st.Next == nil || st.Next.Hour() != 22 {
+		t.Fatalf("enabled: %+v (%v)", st, err)
+	}
+
+	st, _ = s.SetEnabled("quiet", false)
+	if st.Enabled || st.Next != nil {
+		t.Errorf("disabled: %+v", st)
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	t.Parallel()
+
+	radios := &fakeRadios{}
+	s := New(Options{Radios: radios, Location: time.UTC, Jobs: []Job{
+		{Name: "quiet", Spec: mustSpec(t, "0 22 * * *"), Action: ActionMuteTX, Radio: "10.0.0.5"},
+		{Name: "later", Spec: mustSpec(t, "0 23 * * *"), Action: ActionUnmuteTX, Radio: "10.0.0.5"},
+	}})
+
+	now := time.Date(2026, 10, 15, 21, 59, 0, 0, time.UTC)
+	for _, j := range s.jobs {
+		j.next = j.Spec.Next(now)
+	}
+
+	s.runDue(t.Context(), now.Add(30*time.Second))
+	s.runDue(t.Context(), now.Add(time.Minute))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(radios.got()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if got := radios.got(); len(got) != 1 || got[0] != `mute 10.0.0.5 TX muted by schedule "quiet"` {
+		t.Fatalf("calls = %q", got)
+	}
+
+	if next := s.Jobs()[0].Next; next == nil || !next.Equal(time.Date(2026, 10, 16, 22, 0, 0, 0, time.UTC)) {
+		t.Errorf("next = %v", next)
+	}
+}
+
+func TestRestoreMutes(t *testing.T) {
+	t.Parallel()
+
+	radios := &fakeRadios{}
+	s := New(Options{Radios: radios, Location: time.UTC, Jobs: []Job{
+		{Name: "quiet", Spec: mustSpec(t, "0 22 * * *"), Action: ActionMuteTX, Radio: "a"},
+		{Name: "morning", Spec: mustSpec(t, "0 7 * * *"), Action: ActionUnmuteTX, Radio: "a"},
+		{Name: "field-day", Spec: mustSpec(t, "0 6 * * *"), Action: ActionMuteTX, Radio: "b", Duration: time.Hour},
+		{Name: "lunch", Spec: mustSpec(t, "0 2 * * *"), Action: ActionMuteTX, Radio: "c", Duration: 2 * time.Hour},
+	}})
+
+	s.restoreMutes(time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC))
+
+	// a is in quiet hours; b's mute ran out yesterday; c's has an hour left.
+	want := []string{`mute a TX muted by schedule "quiet"`, `mute c TX muted by schedule "lunch"`}
+
+	got := radios.got()
+	slices.Sort(got)
+
+	if !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if (j.unmute != nil) != (j.Name == "lunch") {
+			t.Errorf("%s: unmute timer %v", j.Name, j.unmute)
+		}
+
+		if j.unmute != nil {
+			j.unmute.Stop()
+		}
+	}
+}
+
+func TestPowerOn_WakeAndURL(t *testing.T) {
+	t.Parallel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = pc.Close() }()
+
+	posted := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted <- r.Method + " " + r.URL.Path
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	s := New(Options{Radios: &fakeRadios{}, Broadcast: pc.LocalAddr().String(), Jobs: []Job{
+		{Name: "on", Spec: mustSpec(t, "0 6 * * *"), Action: ActionPowerOn, MAC: "00:1c:2d:05:37:2a", URL: ts.URL + "/relay/on"},
+	}})
+
+	_, err = s.RunNow(t.Context(), "on")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 200)
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 102 || buf[5] != 0xff || buf[6] != 0x00 || buf[11] != 0x2a || buf[101] != 0x2a {
+		t.Errorf("packet = % x", buf[:n])
+	}
+
+	if got := <-posted; got != "POST /relay/on" {
+		t.Errorf("request = %s", got)
+	}
+}
+
+func TestPowerOff_RejectsErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	s := New(Options{Radios: &fakeRadios{}, Jobs: []Job{
+		{Name: "off", Spec: mustSpec(t, "0 23 * * *"), Action: ActionPowerOff, URL: ts.URL},
+	}})
+
+	_, err := s.RunNow(t.Context(), "off")
+	if !errors.Is(err, errStatus) {
+		t.Errorf("err = %v", err)
+	}
+}
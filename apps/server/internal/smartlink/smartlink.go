@@ -0,0 +1,186 @@
+// Package smartlink fetches the radios registered to a FlexRadio SmartLink
+// account so they can be listed alongside LAN-discovered radios.
+package smartlink
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+)
+
+var (
+	errNoRadioList   = errors.New("smartlink: no radio list received")
+	errInvalidToken  = errors.New("smartlink: registration rejected (token invalid or expired)")
+	errTokenRequired = errors.New("smartlink: token required")
+)
+
+const DefaultServer = "smartlink.flexradio.com:443"
+
+type Options struct {
+	Server   string        // host:port, default DefaultServer
+	Token    string        // SmartLink (Auth0) id_token
+	AppName  string        // reported to the SmartLink server
+	Interval time.Duration // poll interval, default 60s
+	Timeout  time.Duration // per-poll timeout, default 15s
+}
+
+type Poller struct {
+	opt Options
+	reg *discovery.Registry
+	// tls is the base of the connection's TLS config; ServerName is set
+	// from the server.
+	tls *tls.Config
+}
+
+func New(reg *discovery.Registry, opt Options) (*Poller, error) {
+	if opt.Token == "" {
+		return nil, errTokenRequired
+	}
+
+	if opt.Server == "" {
+		opt.Server = DefaultServer
+	}
+
+	if opt.AppName == "" {
+		opt.AppName = "solid-sdr"
+	}
+
+	if opt.Interval == 0 {
+		opt.Interval = 60 * time.Second
+	}
+
+	if opt.Timeout == 0 {
+		opt.Timeout = 15 * time.Second
+	}
+
+	// A radio the account no longer lists, or one last listed before the
+	// fetches started failing, counts as offline after a poll is missed.
+	reg.ExpireWAN(opt.Interval + opt.Timeout)
+
+	return &Poller{opt: opt, reg: reg, tls: &tls.Config{MinVersion: tls.VersionTLS12}}, nil
+}
+
+// Run polls the account's radio list until ctx is done.
+func (p *Poller) Run(ctx context.Context) {
+	t := time.NewTicker(p.opt.Interval)
+	defer t.Stop()
+
+	for {
+		radios, err := p.Fetch(ctx)
+		if err != nil {
+			log.Printf("[smartlink] fetch: %v", err)
+		} else {
+			p.reg.ReplaceWAN(radios, time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Fetch registers with the SmartLink server and returns the first radio list
+// it sends.
+func (p *Poller) Fetch(ctx context.Context) ([]discovery.WANRadio, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.opt.Timeout)
+	defer cancel()
+
+	host, _, err := net.SplitHostPort(p.opt.Server)
+	if err != nil {
+		return nil, fmt.Errorf("smartlink server %q: %w", p.opt.Server, err)
+	}
+
+	cfg := p.tls.Clone()
+	cfg.ServerName = host
+	d := tls.Dialer{Config: cfg}
+
+	conn, err := d.DialContext(ctx, "tcp", p.opt.Server)
+	if err != nil {
+		return nil, fmt.Errorf("dial smartlink: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	_, err = fmt.Fprintf(conn, "application register name=%s platform=%s token=%s\n",
+		encodeValue(p.opt.AppName), runtime.GOOS, p.opt.Token)
+	if err != nil {
+		return nil, fmt.Errorf("register: %w", err)
+	}
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case strings.HasPrefix(line, "application registration_invalid"):
+			return nil, errInvalidToken
+		case strings.HasPrefix(line, "radio list"):
+			return parseRadioList(strings.TrimPrefix(line, "radio list")), nil
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read smartlink: %w", err)
+	}
+
+	return nil, errNoRadioList
+}
+
+// parseRadioList parses the body of a "radio list" message: radios separated
+// by "|", each a run of space-separated key=value pairs.
+func parseRadioList(body string) []discovery.WANRadio {
+	var radios []discovery.WANRadio
+
+	for chunk := range strings.SplitSeq(body, "|") {
+		f := make(map[string]string)
+
+		for pair := range strings.FieldsSeq(chunk) {
+			k, v, ok := strings.Cut(pair, "=")
+			if ok {
+				f[k] = decodeValue(v)
+			}
+		}
+
+		if f["serial"] == "" {
+			continue
+		}
+
+		tlsPort, _ := strconv.Atoi(f["public_tls_port"])
+		udpPort, _ := strconv.Atoi(f["public_udp_port"])
+		status := f["status"]
+
+		radios = append(radios, discovery.WANRadio{
+			Serial:   f["serial"],
+			Model:    f["model"],
+			Nickname: f["radio_name"],
+			Callsign: f["callsign"],
+			Version:  f["version"],
+			Status:   status,
+			Online:   status != "" && !strings.EqualFold(status, "Offline"),
+			Public:   discovery.PublicEndpoint{Host: f["public_ip"], TLSPort: tlsPort, UDPPort: udpPort},
+		})
+	}
+
+	return radios
+}
+
+// SmartLink, like the radio, encodes spaces inside values as 0x7F.
+func decodeValue(v string) string { return strings.ReplaceAll(v, "\x7f", " ") }
+func encodeValue(v string) string { return strings.ReplaceAll(v, " ", "\x7f") }
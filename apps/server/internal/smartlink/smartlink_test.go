@@ -0,0 +1,178 @@
+package smartlink
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/discovery"
+)
+
+func TestParseRadioList(t *testing.T) {
+	t.Parallel()
+
+	for name, tt := range map[string]struct {
+		body string
+		want []discovery.WANRadio
+	}{
+		"empty": {body: "", want: nil},
+		"one radio": {
+			body: " serial=1234-5678 model=FLEX-6600 radio_name=Shack\x7fRadio callsign=N0CALL version=3.8.19.36458" +
+				" status=Available public_ip=198.51.100.7 public_tls_port=4994 public_udp_port=4993",
+			want: []discovery.WANRadio{{
+				Serial: "1234-5678", Model: "FLEX-6600", Nickname: "Shack Radio", Callsign: "N0CALL",
+				Version: "3.8.19.36458", Status: "Available", Online: true,
+				Public: discovery.PublicEndpoint{Host: "198.51.100.7", TLSPort: 4994, UDPPort: 4993},
+			}},
+		},
+		"offline and unknown status": {
+			body: " serial=A status=Offline|serial=B",
+			want: []discovery.WANRadio{{Serial: "A", Status: "Offline"}, {Serial: "B"}},
+		},
+		"entry without serial skipped": {
+			body: " model=FLEX-8400 status=Available|serial=C status=In_Use public_tls_port=x",
+			want: []discovery.WANRadio{{Serial: "C", Status: "In_Use", Online: true}},
+		},
+	} {
+		if got := parseRadioList(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", name, got, tt.want)
+		}
+	}
+}
+
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// fakeServer answers each registration with reply, then hangs up. It
+// returns a poller for it.
+func fakeServer(t *testing.T, reply string) *Poller {
+	t.Helper()
+
+	cert, pool := selfSigned(t)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			line, _ := bufio.NewReader(c).ReadString('\n')
+			if strings.HasPrefix(line, "application register ") {
+				_, _ = c.Write([]byte(reply))
+			}
+
+			_ = c.Close()
+		}
+	}()
+
+	p, err := New(discovery.NewRegistry(), Options{Server: ln.Addr().String(), Token: "token", Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.tls.RootCAs = pool
+
+	return p
+}
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+
+	p := fakeServer(t, "application info public_ip=203.0.113.9\nradio list serial=A status=Available\n")
+
+	radios, err := p.Fetch(t.Context())
+	if err != nil || len(radios) != 1 || radios[0].Serial != "A" || !radios[0].Online {
+		t.Errorf("Fetch = %+v, %v", radios, err)
+	}
+}
+
+func TestFetch_Fails(t *testing.T) {
+	t.Parallel()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refused := closed.Addr().String()
+	_ = closed.Close()
+
+	for name, tt := range map[string]struct {
+		poller *Poller
+		want   error
+		text   string
+	}{
+		"invalid token": {poller: fakeServer(t, "application registration_invalid\n"), want: errInvalidToken},
+		"no radio list": {poller: fakeServer(t, "application info public_ip=203.0.113.9\n"), want: errNoRadioList},
+		"refused":       {poller: &Poller{opt: Options{Server: refused, Timeout: time.Second}, tls: &tls.Config{MinVersion: tls.VersionTLS12}}, text: "dial smartlink"},
+		"bad server":    {poller: &Poller{opt: Options{Server: "smartlink", Timeout: time.Second}}, text: "smartlink server"},
+	} {
+		radios, err := tt.poller.Fetch(t.Context())
+
+		switch {
+		case radios != nil:
+			t.Errorf("%s: radios %+v", name, radios)
+		case tt.want != nil && !errors.Is(err, tt.want):
+			t.Errorf("%s: err = %v, want %v", name, err, tt.want)
+		case tt.text != "" && (err == nil || !strings.Contains(err.Error(), tt.text)):
+			t.Errorf("%s: err = %v, want %q", name, err, tt.text)
+		}
+	}
+}
+
+func TestFetch_UntrustedServer(t *testing.T) {
+	t.Parallel()
+
+	p := fakeServer(t, "radio list serial=A status=Available\n")
+	p.tls.RootCAs = nil
+
+	if _, err := p.Fetch(t.Context()); err == nil {
+		t.Error("fetched from a server with an untrusted certificate")
+	}
+}
@@ -0,0 +1,20 @@
+// Package sockopt sets the socket options the bridge needs that the net
+// package has no call for.
+package sockopt
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Broadcast lets a UDP socket send to broadcast addresses. It suits both
+// net.Dialer.Control and net.ListenConfig.Control.
+func Broadcast(_, _ string, rc syscall.RawConn) error {
+	var err error
+
+	ctlErr := rc.Control(func(fd uintptr) {
+		err = setBroadcast(fd)
+	})
+
+	return errors.Join(ctlErr, err)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package sockopt
+
+import "golang.org/x/sys/unix"
+
+func setBroadcast(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1) //nolint:wrapcheck // Broadcast's callers wrap it
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package sockopt
+
+import "syscall"
+
+func setBroadcast(fd uintptr) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1) //nolint:wrapcheck // Broadcast's callers wrap it
+}
@@ -0,0 +1,32 @@
+package spectate
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed viewer.html
+var viewerHTML string
+
+var viewerTmpl = template.Must(template.New("viewer").Parse(viewerHTML))
+
+type viewerData struct {
+	Claim
+
+	ExpiresMS int64
+}
+
+// ServePage serves the viewer for c: a player for the session's audio and
+// a panadapter trace, fetched beside the page's own URL.
+func ServePage(w http.ResponseWriter, c Claim) {
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; "+
+		"media-src 'self'; connect-src 'self'; frame-ancestors 'none'")
+
+	c.Expires = c.Expires.UTC()
+	_ = viewerTmpl.Execute(w, viewerData{Claim: c, ExpiresMS: c.Expires.UnixMilli()})
+}
@@ -0,0 +1,80 @@
+package spectate
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	s, err := New("0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1_000_000, 0)
+	want := Claim{Session: "s-42", Expires: now.Add(time.Hour)}
+	token := s.Mint(want)
+
+	got, err := s.Verify(token, now)
+	if err != nil || got.Session != want.Session || !got.Expires.Equal(want.Expires) {
+		t.Fatalf("Verify = %+v, %v", got, err)
+	}
+
+	if _, err := s.Verify(token, want.Expires); !errors.Is(err, ErrExpired) {
+		t.Errorf("at expiry: %v", err)
+	}
+
+	other, _ := New("")
+	if _, err := other.Verify(token, now); !errors.Is(err, ErrToken) {
+		t.Errorf("other key: %v", err)
+	}
+
+	// Swapping the session breaks the signature.
+	b, sig, _ := strings.Cut(token, ".")
+
+	body, err := encoding.DecodeString(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := encoding.EncodeToString(append(body[:8], "s-43"...)) + "." + sig
+
+	if _, err := s.Verify(tampered, now); !errors.Is(err, ErrToken) {
+		t.Errorf("tampered: %v", err)
+	}
+
+	for _, bad := range []string{"", "abc", "abc.def", "." + sig} {
+		if _, err := s.Verify(bad, now); !errors.Is(err, ErrToken) {
+			t.Errorf("Verify(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestNew_ShortSecret(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New("short"); err == nil {
+		t.Error("accepted a short secret")
+	}
+}
+
+func TestServePage(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	ServePage(w, Claim{Session: "s-1", Expires: time.Unix(1_000_000, 0)})
+
+	body := w.Body.String()
+	if !strings.Contains(body, "1000000000") || !strings.Contains(body, "audio.ogg") {
+		t.Errorf("page:\n%s", body)
+	}
+
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-ancestors 'none'") {
+		t.Errorf("CSP %q", csp)
+	}
+}
@@ -0,0 +1,245 @@
+// Package spots follows DX cluster style telnet feeds, such as the Reverse
+// Beacon Network's or a local CW Skimmer Server's, and keeps the recent
+// spots, so the bridge can hand clients the ones their panadapters show
+// without the browser connecting to third parties itself.
+package spots
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RBN is the Reverse Beacon Network's CW and RTTY feed; port 7001
+	// carries FT8 and FT4.
+	RBN = "telnet.reversebeacon.net:7000"
+	// DefaultMaxAge is how long a spot is kept.
+	DefaultMaxAge = 10 * time.Minute
+
+	// redialDelay is how long a lost feed is left before reconnecting.
+	redialDelay = 10 * time.Second
+)
+
+// Spot is one spot from a feed.
+type Spot struct {
+	Spotter string  `json:"spotter"`
+	FreqKHz float64 `json:"freqKHz"`
+	Call    string  `json:"call"`
+	Mode    string  `json:"mode,omitempty"`
+	SNR     *int    `json:"snr,omitempty"`
+	// Speed is in words per minute for CW, or baud for RTTY.
+	Speed int `json:"speed,omitempty"`
+	// Kind is e.g. "CQ", "BEACON", "NCDXF B" or "DX".
+	Kind    string `json:"kind,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	// Time is when the feed saw it, to the minute.
+	Time time.Time `json:"time"`
+}
+
+// Options configures the service.
+type Options struct {
+	// Feeds are the telnet host:port addresses to follow.
+	Feeds []string
+	// Callsign logs in to each feed.
+	Callsign string
+	// MaxAge is how long a spot is kept; zero means DefaultMaxAge.
+	MaxAge time.Duration
+}
+
+// Service follows the feeds.
+type Service struct {
+	opt Options
+
+	mu    sync.Mutex
+	spots map[string]Spot // by call and frequency to the kHz
+	subs  []func(Spot)
+}
+
+// New returns a service for opt; Run connects it.
+func New(opt Options) *Service {
+	if opt.MaxAge <= 0 {
+		opt.MaxAge = DefaultMaxAge
+	}
+
+	return &Service{opt: opt, spots: make(map[string]Spot)}
+}
+
+// Subscribe calls fn with every spot from now on. fn must not block.
+func (s *Service) Subscribe(fn func(Spot)) {
+	s.mu.Lock()
+	s.subs = append(s.subs, fn)
+	s.mu.Unlock()
+}
+
+// Spots returns the spots kept, newest first.
+func (s *Service) Spots() []Spot {
+	s.mu.Lock()
+	s.expire(time.Now())
+
+	out := make([]Spot, 0, len(s.spots))
+	for _, sp := range s.spots {
+		out = append(out, sp)
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b Spot) int { return b.Time.Compare(a.Time) })
+
+	return out
+}
+
+// Run follows every feed until ctx is done.
+func (s *Service) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	for _, addr := range s.opt.Feeds {
+		wg.Go(func() { s.follow(ctx, addr) })
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// follow keeps one feed connected until ctx is done.
+func (s *Service) follow(ctx context.Context, addr string) {
+	for ctx.Err() == nil {
+		err := s.session(ctx, addr)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[spots] %s: %v", addr, err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(redialDelay):
+		}
+	}
+}
+
+// session logs in to a feed and reads spots until the connection fails.
+// Feeds prompt for a callsign without ending the line, so it is sent at
+// once rather than in answer.
+func (s *Service) session(ctx context.Context, addr string) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	defer func() { _ = conn.Close() }()
+
+	_, err = fmt.Fprintf(conn, "%s\r\n", s.opt.Callsign)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	log.Printf("[spots] following %s", addr)
+
+	rd := bufio.NewScanner(conn)
+	for rd.Scan() {
+		sp, ok := parseSpot(rd.Text(), time.Now().UTC())
+		if ok {
+			s.add(sp)
+		}
+	}
+
+	if err := rd.Err(); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	return nil
+}
+
+// add keeps a spot and tells the subscribers, unless it repeats one kept.
+func (s *Service) add(sp Spot) {
+	key := sp.Call + "/" + strconv.Itoa(int(math.Round(sp.FreqKHz)))
+
+	s.mu.Lock()
+	s.expire(sp.Time)
+
+	_, seen := s.spots[key]
+	s.spots[key] = sp
+	subs := slices.Clone(s.subs)
+	s.mu.Unlock()
+
+	if seen {
+		return
+	}
+
+	for _, fn := range subs {
+		fn(sp)
+	}
+}
+
+// expire drops spots older than MaxAge. s.mu must be held.
+func (s *Service) expire(now time.Time) {
+	for key, sp := range s.spots {
+		if now.Sub(sp.Time) > s.opt.MaxAge {
+			delete(s.spots, key)
+		}
+	}
+}
+
+var (
+	reSpot  = regexp.MustCompile(`^DX de ([A-Z0-9/#-]+):?\s+(\d+(?:\.\d+)?)\s+(\S+)\s+(.*?)\s*(\d{4})Z`)
+	reSNR   = regexp.MustCompile(`(-?\d+) dB`)
+	reSpeed = regexp.MustCompile(`(\d+) (?:WPM|BPS)`)
+	reKind  = regexp.MustCompile(`(CQ|BEACON|NCDXF B|DX)$`)
+)
+
+// parseSpot reads a spot line, e.g. "DX de EA5WU-#:     7012.0  DL1ABC
+// CW    16 dB  22 WPM  CQ      1234Z", seen at now.
+func parseSpot(line string, now time.Time) (Spot, bool) {
+	m := reSpot.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return Spot{}, false
+	}
+
+	khz, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return Spot{}, false
+	}
+
+	sp := Spot{Spotter: m[1], FreqKHz: khz, Call: m[3], Comment: m[4]}
+
+	if fields := strings.Fields(m[4]); len(fields) > 0 && reSNR.MatchString(m[4]) {
+		sp.Mode = fields[0]
+	}
+
+	if v := reSNR.FindStringSubmatch(m[4]); v != nil {
+		snr, _ := strconv.Atoi(v[1])
+		sp.SNR = &snr
+	}
+
+	if v := reSpeed.FindStringSubmatch(m[4]); v != nil {
+		sp.Speed, _ = strconv.Atoi(v[1])
+	}
+
+	if v := reKind.FindStringSubmatch(m[4]); v != nil {
+		sp.Kind = v[1]
+	}
+
+	hh, _ := strconv.Atoi(m[5][:2])
+	mm, _ := strconv.Atoi(m[5][2:])
+	sp.Time = time.Date(now.Year(), now.Month(), now.Day(), hh, mm, 0, 0, time.UTC)
+
+	// A spot from just before midnight arrives just after it.
+	if sp.Time.After(now.Add(time.Minute)) {
+		sp.Time = sp.Time.AddDate(0, 0, -1)
+	}
+
+	return sp, true
+}
@@ -0,0 +1,116 @@
+package spots
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSpot(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 14, 12, 40, 0, 0, time.UTC)
+
+	sp, ok := parseSpot("DX de EA5WU-#:     7012.0  DL1ABC         CW    16 dB  22 WPM  CQ      1234Z", now)
+	if !ok {
+		t.Fatal("RBN spot not parsed")
+	}
+
+	if sp.Spotter != "EA5WU-#" || sp.FreqKHz != 7012 || sp.Call != "DL1ABC" || sp.Mode != "CW" ||
+		sp.SNR == nil || *sp.SNR != 16 || sp.Speed != 22 || sp.Kind != "CQ" {
+		t.Errorf("spot = %+v", sp)
+	}
+
+	if want := time.Date(2026, 3, 14, 12, 34, 0, 0, time.UTC); !sp.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", sp.Time, want)
+	}
+
+	sp, ok = parseSpot("DX de W3LPL-#:   14080.3  K1ABC          RTTY  -3 dB  45 BPS  CQ      2359Z", now.Add(12*time.Hour))
+	if !ok || sp.Mode != "RTTY" || *sp.SNR != -3 || sp.Speed != 45 {
+		t.Errorf("RTTY spot = %+v, %v", sp, ok)
+	}
+
+	if sp.Time.Day() != 14 {
+		t.Errorf("a spot from before midnight is dated %v", sp.Time)
+	}
+
+	sp, ok = parseSpot("DX de K1TTT:     14025.0  JA1XYZ       up 2                    0915Z", now)
+	if !ok || sp.Mode != "" || sp.SNR != nil || sp.Comment != "up 2" {
+		t.Errorf("cluster spot = %+v, %v", sp, ok)
+	}
+
+	if _, ok := parseSpot("Please enter your call:", now); ok {
+		t.Error("the login prompt parsed as a spot")
+	}
+}
+
+func TestService_Follow(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = ln.Close() })
+
+	login := make(chan string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = conn.Close() }()
+
+		_, _ = conn.Write([]byte("Please enter your call: "))
+
+		call, _ := bufio.NewReader(conn).ReadString('\n')
+		login <- strings.TrimSpace(call)
+
+		at := time.Now().UTC().Format("1504")
+		_, _ = conn.Write([]byte("DX de EA5WU-#:     7012.0  DL1ABC         CW    16 dB  22 WPM  CQ      " + at + "Z\r\n"))
+		_, _ = conn.Write([]byte("DX de DK9IP-#:     7012.1  DL1ABC         CW    12 dB  22 WPM  CQ      " + at + "Z\r\n"))
+		_, _ = conn.Write([]byte("DX de DK9IP-#:    14021.0  OH2XYZ         CW     8 dB  28 WPM  CQ      " + at + "Z\r\n"))
+
+		<-time.After(2 * time.Second)
+	}()
+
+	s := New(Options{Feeds: []string{ln.Addr().String()}, Callsign: "N0CALL"})
+
+	got := make(chan Spot, 4)
+	s.Subscribe(func(sp Spot) { got <- sp })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go func() { _ = s.Run(ctx) }()
+
+	select {
+	case call := <-login:
+		if call != "N0CALL" {
+			t.Errorf("logged in as %q", call)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no login")
+	}
+
+	for _, want := range []string{"DL1ABC", "OH2XYZ"} {
+		select {
+		case sp := <-got:
+			if sp.Call != want {
+				t.Errorf("spot for %s, want %s (a repeat is not new)", sp.Call, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no spot for %s", want)
+		}
+	}
+
+	if kept := s.Spots(); len(kept) != 2 {
+		t.Errorf("kept %d spots, want 2", len(kept))
+	}
+}
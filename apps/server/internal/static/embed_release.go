@@ -19,5 +19,6 @@ func Handler() http.Handler {
 	if err != nil {
 		panic("static: failed to sub embedded FS: " + err.Error())
 	}
-	return http.FileServer(http.FS(sub))
+
+	return spa(sub)
 }
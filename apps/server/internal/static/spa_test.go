@@ -0,0 +1,48 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSPA(t *testing.T) {
+	t.Parallel()
+
+	h := spa(fstest.MapFS{
+		"index.html":        {Data: []byte("<html>app</html>")},
+		"assets/app-abc.js": {Data: []byte("console.log(1)")},
+	})
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+		cache  string
+	}{
+		{"/", http.StatusOK, "<html>app</html>", "no-cache"},
+		{"/radio/settings", http.StatusOK, "<html>app</html>", "no-cache"},
+		{"/assets/app-abc.js", http.StatusOK, "console.log(1)", "public, max-age=31536000, immutable"},
+		{"/assets/missing.js", http.StatusNotFound, "", ""},
+	}
+
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.path, rec.Code, tc.status)
+
+			continue
+		}
+
+		if tc.body != "" && rec.Body.String() != tc.body {
+			t.Errorf("%s: body = %q, want %q", tc.path, rec.Body.String(), tc.body)
+		}
+
+		if tc.cache != "" && rec.Header().Get("Cache-Control") != tc.cache {
+			t.Errorf("%s: Cache-Control = %q, want %q", tc.path, rec.Header().Get("Cache-Control"), tc.cache)
+		}
+	}
+}
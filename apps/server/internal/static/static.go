@@ -0,0 +1,48 @@
+// Package static serves the compiled web UI.
+package static
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// Dir returns a handler serving the web UI from a directory on disk. It is
+// used with --static-dir to override the embedded copy during development.
+func Dir(dir string) http.Handler {
+	return spa(os.DirFS(dir))
+}
+
+// spa serves files from fsys, falling back to index.html for paths that do
+// not exist so client-side routes survive a reload. Vite emits content-hashed
+// files under assets/, which are safe to cache forever; everything else must
+// be revalidated so a new build is picked up immediately.
+func spa(fsys fs.FS) http.Handler {
+	files := http.FileServer(http.FS(fsys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
+
+		_, err := fs.Stat(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) && path.Ext(name) == "" {
+			w.Header().Set("Cache-Control", "no-cache")
+			http.ServeFileFS(w, r, fsys, "index.html")
+
+			return
+		}
+
+		if strings.HasPrefix(name, "assets/") {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
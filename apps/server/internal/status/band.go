@@ -0,0 +1,45 @@
+package status
+
+// bands are the amateur allocations the page names, in MHz (ITU region 2
+// edges; the widest of the regions where they differ).
+var bands = []struct {
+	name     string
+	low, top float64
+}{
+	{"2200m", 0.1357, 0.1378},
+	{"630m", 0.472, 0.479},
+	{"160m", 1.8, 2.0},
+	{"80m", 3.5, 4.0},
+	{"60m", 5.25, 5.45},
+	{"40m", 7.0, 7.3},
+	{"30m", 10.1, 10.15},
+	{"20m", 14.0, 14.35},
+	{"17m", 18.068, 18.168},
+	{"15m", 21.0, 21.45},
+	{"12m", 24.89, 24.99},
+	{"10m", 28.0, 29.7},
+	{"6m", 50.0, 54.0},
+	{"4m", 70.0, 70.5},
+	{"2m", 144.0, 148.0},
+}
+
+// Band names the amateur band mhz falls in, or returns "" outside them.
+func Band(mhz float64) string {
+	for _, b := range bands {
+		if mhz >= b.low && mhz <= b.top {
+			return b.name
+		}
+	}
+
+	return ""
+}
+
+// Bands names the bands Band knows, lowest first.
+func Bands() []string {
+	names := make([]string, len(bands))
+	for i, b := range bands {
+		names[i] = b.name
+	}
+
+	return names
+}
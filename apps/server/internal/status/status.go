@@ -0,0 +1,214 @@
+// Package status serves a public, read-only station status page and its JSON
+// twin, for embedding on a club website. Only whether the station is online
+// is shown unless the owner opts in to more.
+package status
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Paths the page and its JSON are served on.
+const (
+	PagePath = "/status"
+	JSONPath = "/api/status"
+)
+
+// cacheFor lets proxies and the embedding site share a response briefly;
+// the page refreshes itself every refreshSeconds.
+const (
+	cacheFor       = "public, max-age=5"
+	refreshSeconds = 30
+)
+
+// Station is what the bridge knows about the station right now.
+type Station struct {
+	Online   bool // a radio is reachable
+	Sessions int  // operators connected through the web UI
+	Players  int  // WHEP listeners
+	Radios   []Radio
+}
+
+// Radio is one radio in use.
+type Radio struct {
+	Callsign     string
+	Model        string
+	Transmitting bool
+	Slices       []Slice
+}
+
+// Slice is one receiver on a radio.
+type Slice struct {
+	Letter  string
+	FreqMHz float64
+	Mode    string
+	TX      bool
+}
+
+// Show selects what the page reveals beyond online/offline.
+type Show struct {
+	Callsign     bool
+	Model        bool
+	Band         bool
+	Mode         bool
+	Frequency    bool // the exact frequency, not just the band
+	Listeners    bool
+	Transmitting bool
+}
+
+type Options struct {
+	// Title heads the page; the radio's callsign is used when it is shown
+	// and no title is set.
+	Title string
+	Show  Show
+	// EmbedOrigins may frame the page, e.g. https://club.example.org; "*"
+	// allows any site. Empty forbids framing.
+	EmbedOrigins []string
+	Station      func() Station
+}
+
+// Page serves the status page.
+type Page struct {
+	opt Options
+}
+
+func New(opt Options) *Page {
+	return &Page{opt: opt}
+}
+
+// Report is the public view of the station, as served at JSONPath.
+type Report struct {
+	Title     string        `json:"title,omitempty"`
+	Online    bool          `json:"online"`
+	InUse     bool          `json:"inUse"`
+	Listeners *int          `json:"listeners,omitempty"`
+	Radios    []RadioReport `json:"radios,omitempty"`
+	Updated   time.Time     `json:"updated"`
+}
+
+type RadioReport struct {
+	Callsign     string        `json:"callsign,omitempty"`
+	Model        string        `json:"model,omitempty"`
+	Transmitting *bool         `json:"transmitting,omitempty"`
+	Slices       []SliceReport `json:"slices,omitempty"`
+}
+
+type SliceReport struct {
+	Slice        string  `json:"slice"`
+	Band         string  `json:"band,omitempty"`
+	Mode         string  `json:"mode,omitempty"`
+	FrequencyMHz float64 `json:"frequencyMHz,omitempty"`
+	TX           bool    `json:"tx,omitempty"`
+}
+
+// Report builds the public view, leaving out everything not opted in to.
+func (p *Page) Report(now time.Time) Report {
+	st := p.opt.Station()
+	show := p.opt.Show
+
+	r := Report{Title: p.opt.Title, Online: st.Online, InUse: st.Sessions > 0, Updated: now.UTC()}
+
+	if show.Listeners {
+		n := st.Sessions + st.Players
+		r.Listeners = &n
+	}
+
+	for _, radio := range st.Radios {
+		var rr RadioReport
+
+		if show.Callsign {
+			rr.Callsign = radio.Callsign
+		}
+
+		if show.Model {
+			rr.Model = radio.Model
+		}
+
+		if show.Transmitting {
+			tx := radio.Transmitting
+			rr.Transmitting = &tx
+		}
+
+		if show.Band || show.Mode || show.Frequency {
+			for _, s := range radio.Slices {
+				sr := SliceReport{Slice: s.Letter}
+
+				if show.Transmitting {
+					sr.TX = s.TX
+				}
+
+				if show.Band || show.Frequency {
+					sr.Band = Band(s.FreqMHz)
+				}
+
+				if show.Mode {
+					sr.Mode = s.Mode
+				}
+
+				if show.Frequency {
+					sr.FrequencyMHz = s.FreqMHz
+				}
+
+				rr.Slices = append(rr.Slices, sr)
+			}
+		}
+
+		if rr.Callsign != "" || rr.Model != "" || rr.Transmitting != nil || len(rr.Slices) > 0 {
+			r.Radios = append(r.Radios, rr)
+		}
+	}
+
+	if r.Title == "" && show.Callsign && len(r.Radios) > 0 {
+		r.Title = r.Radios[0].Callsign
+	}
+
+	return r
+}
+
+// ServeJSON serves the report. Any site may fetch it.
+func (p *Page) ServeJSON(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheFor)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cross-Origin-Resource-Policy", "cross-origin")
+	_ = json.NewEncoder(w).Encode(p.Report(time.Now()))
+}
+
+//go:embed status.html
+var pageHTML string
+
+var pageTmpl = template.Must(template.New("status").Funcs(template.FuncMap{
+	"mhz": func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) },
+}).Parse(pageHTML))
+
+type pageData struct {
+	Report
+	Refresh int
+}
+
+// ServeHTML serves the page, which refreshes itself and needs no script.
+func (p *Page) ServeHTML(w http.ResponseWriter, _ *http.Request) {
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", cacheFor)
+	// The bridge marks its responses same-origin for the web UI; this one is
+	// meant to be framed elsewhere.
+	h.Set("Cross-Origin-Resource-Policy", "cross-origin")
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors "+
+		frameAncestors(p.opt.EmbedOrigins))
+
+	_ = pageTmpl.Execute(w, pageData{Report: p.Report(time.Now()), Refresh: refreshSeconds})
+}
+
+func frameAncestors(origins []string) string {
+	if len(origins) == 0 {
+		return "'none'"
+	}
+
+	return strings.Join(origins, " ")
+}
@@ -0,0 +1,130 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func station() Station {
+	return Station{
+		Online:   true,
+		Sessions: 1,
+		Players:  2,
+		Radios: []Radio{{
+			Callsign:     "N0CALL",
+			Model:        "FLEX-6600",
+			Transmitting: true,
+			Slices: []Slice{
+				{Letter: "A", FreqMHz: 14.074, Mode: "DIGU", TX: true},
+				{Letter: "B", FreqMHz: 7.2, Mode: "LSB"},
+			},
+		}},
+	}
+}
+
+func TestReport_Private(t *testing.T) {
+	t.Parallel()
+
+	p := New(Options{Station: station})
+
+	b, err := json.Marshal(p.Report(time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"online":true,"inUse":true,"updated":"1970-01-01T00:00:00Z"}`
+	if string(b) != want {
+		t.Errorf("report = %s\nwant     %s", b, want)
+	}
+}
+
+func TestReport_OptIn(t *testing.T) {
+	t.Parallel()
+
+	p := New(Options{Station: station, Show: Show{Callsign: true, Band: true, Listeners: true}})
+	r := p.Report(time.Now())
+
+	if r.Title != "N0CALL" || r.Listeners == nil || *r.Listeners != 3 {
+		t.Errorf("report = %+v", r)
+	}
+
+	radio := r.Radios[0]
+	if radio.Model != "" || radio.Transmitting != nil {
+		t.Errorf("radio shows more than asked: %+v", radio)
+	}
+
+	if len(radio.Slices) != 2 || radio.Slices[0] != (SliceReport{Slice: "A", Band: "20m"}) {
+		t.Errorf("slices = %+v", radio.Slices)
+	}
+
+	p = New(Options{Station: station, Show: Show{Frequency: true, Mode: true, Transmitting: true}})
+
+	got := p.Report(time.Now()).Radios[0].Slices[0]
+	if got != (SliceReport{Slice: "A", Band: "20m", Mode: "DIGU", FrequencyMHz: 14.074, TX: true}) {
+		t.Errorf("slice = %+v", got)
+	}
+}
+
+func TestServeHTML(t *testing.T) {
+	t.Parallel()
+
+	p := New(Options{
+		Title:        "Club <station>",
+		Station:      station,
+		EmbedOrigins: []string{"https://club.example.org"},
+		Show:         Show{Frequency: true, Transmitting: true},
+	})
+
+	w := httptest.NewRecorder()
+	p.ServeHTML(w, httptest.NewRequest("GET", PagePath, nil))
+
+	body := w.Body.String()
+	for _, want := range []string{"Club &lt;station&gt;", "Online", "On air", "14.074 MHz (20m)", "A (TX)"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %q:\n%s", want, body)
+		}
+	}
+
+	if strings.Contains(body, "N0CALL") {
+		t.Error("page shows the callsign without opting in")
+	}
+
+	csp := w.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "frame-ancestors https://club.example.org") {
+		t.Errorf("CSP = %q", csp)
+	}
+}
+
+func TestServeJSON(t *testing.T) {
+	t.Parallel()
+
+	p := New(Options{Station: func() Station { return Station{} }})
+
+	w := httptest.NewRecorder()
+	p.ServeJSON(w, httptest.NewRequest("GET", JSONPath, nil))
+
+	var r Report
+
+	err := json.Unmarshal(w.Body.Bytes(), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Online || r.InUse || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("report = %+v, headers = %v", r, w.Header())
+	}
+}
+
+func TestBand(t *testing.T) {
+	t.Parallel()
+
+	cases := map[float64]string{1.84: "160m", 14.074: "20m", 28.5: "10m", 50.313: "6m", 12.0: "", 0.475: "630m"}
+	for mhz, want := range cases {
+		if got := Band(mhz); got != want {
+			t.Errorf("Band(%v) = %q, want %q", mhz, got, want)
+		}
+	}
+}
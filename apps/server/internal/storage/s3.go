@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errS3Config = errors.New("storage: s3 needs endpoint, bucket and credentials")
+	errS3Status = errors.New("storage: s3 upload failed")
+)
+
+const (
+	s3RetryMin   = 5 * time.Second
+	s3RetryMax   = 5 * time.Minute
+	s3PutTimeout = 30 * time.Minute
+
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+type S3Options struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com or http://minio.lan:9000
+	Region    string // default us-east-1
+	Bucket    string
+	Prefix    string // prepended to every key
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket as endpoint/bucket/key instead of
+	// bucket.endpoint/key. MinIO and most self-hosted servers need it.
+	PathStyle bool
+	// SpillDir holds objects until they are uploaded. Leftovers from a
+	// previous run are uploaded on start.
+	SpillDir string
+}
+
+// S3 spills objects to a local directory and uploads them in the background,
+// deleting each local copy once the upload succeeds. Failed uploads are
+// retried with backoff, so a site that loses its uplink keeps recording.
+type S3 struct {
+	opt    S3Options
+	base   *url.URL
+	client *http.Client
+
+	queue  chan string
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewS3(opt S3Options) (*S3, error) {
+	if opt.Endpoint == "" || opt.Bucket == "" || opt.AccessKey == "" || opt.SecretKey == "" {
+		return nil, errS3Config
+	}
+
+	if opt.Region == "" {
+		opt.Region = "us-east-1"
+	}
+
+	base, err := url.Parse(opt.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("storage: s3 endpoint: %w", err)
+	}
+
+	err = os.MkdirAll(opt.SpillDir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &S3{
+		opt:    opt,
+		base:   base,
+		client: &http.Client{Timeout: s3PutTimeout},
+		queue:  make(chan string, 1024),
+		cancel: cancel,
+	}
+
+	s.wg.Go(func() { s.uploadLoop(ctx) })
+	s.enqueueLeftovers()
+
+	return s, nil
+}
+
+func (s *S3) Create(key string) (io.WriteCloser, error) {
+	return createAtomic(s.opt.SpillDir, key, func() { s.enqueue(key) })
+}
+
+func (s *S3) Close() error {
+	s.cancel()
+	s.wg.Wait()
+
+	return nil
+}
+
+func (s *S3) String() string {
+	return "s3://" + s.opt.Bucket + "/" + s.opt.Prefix
+}
+
+func (s *S3) enqueue(key string) {
+	select {
+	case s.queue <- key:
+	default:
+		// The spill directory is the queue of record; the file is picked up
+		// on the next start.
+		log.Printf("[storage] upload queue full, %s stays in %s", key, s.opt.SpillDir)
+	}
+}
+
+func (s *S3) enqueueLeftovers() {
+	_ = filepath.WalkDir(s.opt.SpillDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, partSuffix) {
+			return nil //nolint:nilerr // unreadable entries are skipped
+		}
+
+		rel, err := filepath.Rel(s.opt.SpillDir, path)
+		if err == nil {
+			s.enqueue(filepath.ToSlash(rel))
+		}
+
+		return nil
+	})
+}
+
+func (s *S3) uploadLoop(ctx context.Context) {
+	backoff := s3RetryMin
+
+	for {
+		var key string
+
+		select {
+		case <-ctx.Done():
+			return
+		case key = <-s.queue:
+		}
+
+		err := s.upload(ctx, key)
+		if err == nil {
+			backoff = s3RetryMin
+
+			continue
+		}
+
+		log.Printf("[storage] upload %s: %v (retrying in %s)", key, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, s3RetryMax)
+		s.enqueue(key)
+	}
+}
+
+func (s *S3) upload(ctx context.Context, key string) error {
+	path, err := keyPath(s.opt.SpillDir, key)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path) //nolint:gosec // path is confined to the spill dir by keyPath
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // already uploaded
+		}
+
+		return fmt.Errorf("open spill: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	st, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat spill: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), f)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+
+	req.ContentLength = st.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	signV4(req, s.opt.AccessKey, s.opt.SecretKey, s.opt.Region, unsignedPayload, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("%w: %s: %s", errS3Status, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	_ = f.Close()
+
+	err = os.Remove(path)
+	if err != nil {
+		log.Printf("[storage] remove uploaded %s: %v", key, err)
+	}
+
+	log.Printf("[storage] uploaded %s (%d bytes)", key, st.Size())
+
+	return nil
+}
+
+func (s *S3) objectURL(key string) string {
+	u := *s.base
+	object := strings.TrimPrefix(s.opt.Prefix+key, "/")
+
+	if s.opt.PathStyle {
+		u.Path = "/" + s.opt.Bucket + "/" + object
+	} else {
+		u.Host = s.opt.Bucket + "." + u.Host
+		u.Path = "/" + object
+	}
+
+	u.RawPath = s3EscapePath(u.Path)
+
+	return u.String()
+}
+
+// signV4 adds AWS Signature Version 4 headers to req. Every header already
+// set on req, plus host, is signed.
+func signV4(req *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		names = append(names, lk)
+		values[lk] = strings.Join(v, ",")
+	}
+
+	sort.Strings(names)
+
+	var canonHeaders strings.Builder
+	for _, n := range names {
+		canonHeaders.WriteString(n + ":" + strings.TrimSpace(values[n]) + "\n")
+	}
+
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var parts []string
+
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+
+		for _, v := range vs {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// s3EscapePath URI-encodes each path segment as SigV4 requires.
+func s3EscapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = s3Escape(s)
+	}
+
+	return strings.Join(segs, "/")
+}
+
+func s3Escape(s string) string {
+	var b strings.Builder
+
+	for i := range len(s) {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
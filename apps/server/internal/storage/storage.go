@@ -0,0 +1,100 @@
+// Package storage writes recordings and other bulky artifacts either to a
+// local directory or to S3-compatible object storage via a local spill
+// directory.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var errBadKey = errors.New("storage: key must be a relative path without ..")
+
+// Store creates objects by key. Keys use forward slashes, e.g.
+// "2024-05-01/slice-a.ogg".
+type Store interface {
+	// Create returns a writer for key. The object becomes visible (or is
+	// queued for upload) when the writer is closed.
+	Create(key string) (io.WriteCloser, error)
+	// Close releases the store. Objects not yet uploaded stay in the spill
+	// directory and are retried by the next store using it.
+	Close() error
+	// String describes the destination for logs.
+	String() string
+}
+
+// Local stores objects as files under a directory, created on first use.
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+func (l *Local) Create(key string) (io.WriteCloser, error) {
+	return createAtomic(l.dir, key, nil)
+}
+
+func (l *Local) Close() error { return nil }
+
+func (l *Local) String() string { return l.dir }
+
+// atomicFile writes to "<path>.part" and renames it into place on Close so
+// readers (and the uploader) never see a partial object.
+type atomicFile struct {
+	*os.File
+	path     string
+	onCommit func()
+}
+
+func createAtomic(dir, key string, onCommit func()) (*atomicFile, error) {
+	path, err := keyPath(dir, key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+
+	f, err := os.Create(path + partSuffix) //nolint:gosec // path is confined to dir by keyPath
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+
+	return &atomicFile{File: f, path: path, onCommit: onCommit}, nil
+}
+
+func (a *atomicFile) Close() error {
+	err := a.File.Close()
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+
+	err = os.Rename(a.path+partSuffix, a.path)
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+
+	if a.onCommit != nil {
+		a.onCommit()
+	}
+
+	return nil
+}
+
+const partSuffix = ".part"
+
+func keyPath(dir, key string) (string, error) {
+	rel := filepath.FromSlash(key)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %q", errBadKey, key)
+	}
+
+	return filepath.Join(dir, rel), nil
+}
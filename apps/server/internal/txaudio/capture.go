@@ -0,0 +1,98 @@
+package txaudio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Capture records a sound device on the bridge's host through a chain.
+type Capture struct {
+	cmd    *exec.Cmd
+	done   chan struct{}
+	once   sync.Once
+	closed atomic.Bool
+}
+
+// captureInput is ffmpeg's input for device: an ALSA PCM on Linux, an
+// AVFoundation audio device (a name or index) on macOS and a DirectShow one,
+// by name, on Windows.
+func captureInput(device string) []string {
+	in := []string{"-nostdin", "-loglevel", "error", "-fflags", "nobuffer"}
+
+	switch runtime.GOOS {
+	case "windows":
+		return append(in, "-f", "dshow", "-i", "audio="+device)
+	case "darwin":
+		return append(in, "-f", "avfoundation", "-i", ":"+device)
+	default:
+		return append(in, "-f", "alsa", "-i", device)
+	}
+}
+
+// StartCapture records device with ffmpeg through c, calling out with each
+// Opus packet, until ctx ends or Close is called.
+func StartCapture(ctx context.Context, ffmpeg, device string, c Chain, out func([]byte)) (*Capture, error) {
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+
+	_, err := exec.LookPath(ffmpeg)
+	if err != nil {
+		return nil, errNoFFmpeg
+	}
+
+	cmd := exec.CommandContext(ctx, ffmpeg, append(captureInput(device), c.encode()...)...) //nolint:gosec // binary is operator configuration
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("txaudio: %w", err)
+	}
+
+	var stderr strings.Builder
+
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("txaudio: %w", err)
+	}
+
+	cp := &Capture{cmd: cmd, done: make(chan struct{})}
+
+	go func() {
+		defer close(cp.done)
+
+		err := readOggPackets(stdout, out)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Printf("[txaudio] capture: %v", err)
+		}
+
+		err = cmd.Wait()
+		if err != nil && ctx.Err() == nil && !cp.closed.Load() {
+			log.Printf("[txaudio] capture of %s: %v: %s", device, err, strings.TrimSpace(stderr.String()))
+		}
+	}()
+
+	return cp, nil
+}
+
+// Done is closed once ffmpeg has exited.
+func (cp *Capture) Done() <-chan struct{} { return cp.done }
+
+// Close stops recording and waits for ffmpeg to exit.
+func (cp *Capture) Close() {
+	cp.once.Do(func() {
+		cp.closed.Store(true)
+		_ = cp.cmd.Process.Kill()
+	})
+
+	<-cp.done
+}
@@ -0,0 +1,70 @@
+package txaudio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	oggHeaderLen = 27
+	// oggOpusHeaders are the OpusHead and OpusTags packets that open a
+	// stream.
+	oggOpusHeaders = 2
+)
+
+var errOggSync = errors.New("txaudio: lost Ogg page sync")
+
+// readOggPackets reads an Ogg/Opus stream, calling fn with each Opus packet
+// after the stream's headers, until r ends.
+func readOggPackets(r io.Reader, fn func([]byte)) error {
+	br := bufio.NewReader(r)
+	hdr := make([]byte, oggHeaderLen)
+
+	var (
+		pkt  []byte
+		seen int
+	)
+
+	for {
+		_, err := io.ReadFull(br, hdr)
+		if err != nil {
+			return fmt.Errorf("txaudio: %w", err)
+		}
+
+		if string(hdr[:4]) != "OggS" {
+			return errOggSync
+		}
+
+		lacing := make([]byte, hdr[26])
+
+		_, err = io.ReadFull(br, lacing)
+		if err != nil {
+			return fmt.Errorf("txaudio: %w", err)
+		}
+
+		// A packet ends at the first segment shorter than 255 bytes, and may
+		// run on into the next page.
+		for _, n := range lacing {
+			seg := make([]byte, n)
+
+			_, err = io.ReadFull(br, seg)
+			if err != nil {
+				return fmt.Errorf("txaudio: %w", err)
+			}
+
+			pkt = append(pkt, seg...)
+			if n == 255 {
+				continue
+			}
+
+			if seen >= oggOpusHeaders && len(pkt) > 0 {
+				fn(pkt)
+			}
+
+			seen++
+			pkt = nil
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Package txaudio runs browser TX audio through a processing chain (input
+// gain, an 8-band EQ, a compressor and a limiter) before it reaches the
+// radio, evening out how differently browsers and microphones set their
+// levels. The bridge passes Opus through and has no codec of its own, so the
+// chain is an ffmpeg process per transmitting session: Ogg/Opus in, filtered
+// and re-encoded Ogg/Opus out.
+package txaudio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// Bands are the EQ's centre frequencies in Hz, an octave apart as in
+// SmartSDR's TX equalizer.
+var Bands = [8]int{63, 125, 250, 500, 1000, 2000, 4000, 8000} //nolint:gochecknoglobals
+
+// queueLen is how many packets wait for ffmpeg before new ones are dropped,
+// rather than stall the track they are read from.
+const queueLen = 64
+
+var errNoFFmpeg = errors.New("txaudio: ffmpeg was not found")
+
+// Chain is one processing profile. Zero values leave a stage out.
+type Chain struct {
+	// Gain is the input gain in dB.
+	Gain float64
+	// EQ is the gain in dB of each of Bands, in order; missing bands are
+	// flat.
+	EQ         []float64
+	Compressor Compressor
+	// Limit is the limiter's ceiling in dBFS, e.g. -1.
+	Limit float64
+}
+
+// Compressor squeezes levels above Threshold (dBFS) by Ratio.
+type Compressor struct {
+	Threshold float64
+	Ratio     float64
+	Attack    time.Duration
+	Release   time.Duration
+	// Makeup is the gain in dB added back after compressing.
+	Makeup float64
+}
+
+// Filter is the chain as an ffmpeg audio filter graph, or "anull" when it
+// leaves everything out.
+func (c Chain) Filter() string {
+	var stages []string
+
+	if c.Gain != 0 {
+		stages = append(stages, "volume="+num(c.Gain)+"dB")
+	}
+
+	for i, g := range c.EQ {
+		if i < len(Bands) && g != 0 {
+			stages = append(stages, fmt.Sprintf("equalizer=f=%d:t=o:w=1:g=%s", Bands[i], num(g)))
+		}
+	}
+
+	if cp := c.Compressor; cp.Threshold < 0 && cp.Ratio > 1 {
+		s := "acompressor=threshold=" + num(linear(cp.Threshold)) + ":ratio=" + num(cp.Ratio)
+		if cp.Attack > 0 {
+			s += ":attack=" + num(float64(cp.Attack)/float64(time.Millisecond))
+		}
+
+		if cp.Release > 0 {
+			s += ":release=" + num(float64(cp.Release)/float64(time.Millisecond))
+		}
+
+		if cp.Makeup > 0 {
+			s += ":makeup=" + num(linear(cp.Makeup))
+		}
+
+		stages = append(stages, s)
+	}
+
+	if c.Limit < 0 {
+		stages = append(stages, "alimiter=limit="+num(linear(c.Limit))+":level=disabled")
+	}
+
+	if len(stages) == 0 {
+		return "anull"
+	}
+
+	return strings.Join(stages, ",")
+}
+
+// Args are ffmpeg's arguments to run the chain between stdin and stdout
+// with as little buffering as it allows.
+func (c Chain) Args() []string {
+	return append([]string{
+		"-nostdin", "-loglevel", "error",
+		"-fflags", "nobuffer", "-flags", "low_delay", "-probesize", "32", "-analyzeduration", "0",
+		"-f", "ogg", "-i", "pipe:0",
+	}, c.encode()...)
+}
+
+// encode are ffmpeg's arguments to filter its input through the chain and
+// write it to stdout as 20 ms mono Ogg/Opus, a page per packet.
+func (c Chain) encode() []string {
+	return []string{
+		"-af", c.Filter(),
+		"-c:a", "libopus", "-application", "voip", "-b:a", "64k", "-frame_duration", "20",
+		"-ar", "48000", "-ac", "1",
+		"-flush_packets", "1", "-page_duration", "20000",
+		"-f", "ogg", "pipe:1",
+	}
+}
+
+func linear(db float64) float64 { return math.Pow(10, db/20) }
+
+func num(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+// Options are the chain's profiles and who gets which.
+type Options struct {
+	// FFmpeg is the ffmpeg binary; default "ffmpeg" on PATH.
+	FFmpeg   string
+	Profiles map[string]Chain
+	// Users maps a user, as the bridge's user header names them, to a
+	// profile.
+	Users map[string]string
+	// Default is the profile for everyone else; empty leaves their audio
+	// untouched.
+	Default string
+}
+
+// Service hands each transmitting session its profile's chain.
+type Service struct {
+	opt Options
+}
+
+// New returns a Service, or nil when there are no profiles.
+func New(opt Options) *Service {
+	if len(opt.Profiles) == 0 {
+		return nil
+	}
+
+	if opt.FFmpeg == "" {
+		opt.FFmpeg = "ffmpeg"
+	}
+
+	return &Service{opt: opt}
+}
+
+// For returns user's profile and its name, if they have one.
+func (s *Service) For(user string) (Chain, string, bool) {
+	if s == nil {
+		return Chain{}, "", false
+	}
+
+	name, ok := s.opt.Users[user]
+	if !ok || user == "" {
+		name = s.opt.Default
+	}
+
+	c, ok := s.opt.Profiles[name]
+
+	return c, name, ok
+}
+
+// Processor feeds one session's TX audio through a chain.
+type Processor struct {
+	cmd   *exec.Cmd
+	queue chan *rtp.Packet
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Start runs c in ffmpeg, calling out with each processed Opus packet,
+// until ctx ends or Close is called.
+func (s *Service) Start(ctx context.Context, c Chain, out func([]byte)) (*Processor, error) {
+	_, err := exec.LookPath(s.opt.FFmpeg)
+	if err != nil {
+		return nil, errNoFFmpeg
+	}
+
+	cmd := exec.CommandContext(ctx, s.opt.FFmpeg, c.Args()...) //nolint:gosec // binary is operator configuration
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("txaudio: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("txaudio: %w", err)
+	}
+
+	var stderr strings.Builder
+
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("txaudio: %w", err)
+	}
+
+	p := &Processor{cmd: cmd, queue: make(chan *rtp.Packet, queueLen), done: make(chan struct{})}
+
+	go p.feed(stdin)
+
+	go func() {
+		defer close(p.done)
+
+		err := readOggPackets(stdout, out)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Printf("[txaudio] %v", err)
+		}
+
+		err = cmd.Wait()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[txaudio] ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	}()
+
+	return p, nil
+}
+
+// feed writes queued packets to ffmpeg as Ogg/Opus.
+func (p *Processor) feed(stdin io.WriteCloser) {
+	defer func() { _ = stdin.Close() }()
+
+	ogg, err := oggwriter.NewWith(stdin, 48000, 2)
+	if err != nil {
+		log.Printf("[txaudio] %v", err)
+
+		return
+	}
+
+	for pkt := range p.queue {
+		err := ogg.WriteRTP(pkt)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Write queues a browser TX packet for the chain. It reports false once
+// the chain has stopped, so the caller can send its audio untouched.
+func (p *Processor) Write(pkt *rtp.Packet) bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+
+	select {
+	case p.queue <- pkt:
+	default: // ffmpeg is behind; drop rather than stall the track
+	}
+
+	return true
+}
+
+// Close stops the chain and waits for ffmpeg to exit.
+func (p *Processor) Close() {
+	p.once.Do(func() { close(p.queue) })
+
+	select {
+	case <-p.done:
+	case <-time.After(2 * time.Second):
+		_ = p.cmd.Process.Kill()
+
+		<-p.done
+	}
+}
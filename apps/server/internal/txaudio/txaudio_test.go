@@ -0,0 +1,158 @@
+package txaudio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+func TestChain_Filter(t *testing.T) {
+	t.Parallel()
+
+	c := Chain{
+		Gain:       6,
+		EQ:         []float64{0, -3, 0, 0, 2.5},
+		Compressor: Compressor{Threshold: -20, Ratio: 4, Attack: 5 * time.Millisecond, Release: 80 * time.Millisecond},
+		Limit:      -6.020599913279624,
+	}
+
+	want := "volume=6dB,equalizer=f=125:t=o:w=1:g=-3,equalizer=f=1000:t=o:w=1:g=2.5," +
+		"acompressor=threshold=0.1:ratio=4:attack=5:release=80,alimiter=limit=0.5:level=disabled"
+	if got := c.Filter(); got != want {
+		t.Errorf("filter = %q\nwant %q", got, want)
+	}
+
+	if got := (Chain{}).Filter(); got != "anull" {
+		t.Errorf("empty chain = %q", got)
+	}
+}
+
+func TestService_For(t *testing.T) {
+	t.Parallel()
+
+	s := New(Options{
+		Profiles: map[string]Chain{"loud": {Gain: -6}, "quiet": {Gain: 12}},
+		Users:    map[string]string{"alice": "loud"},
+		Default:  "quiet",
+	})
+
+	for user, want := range map[string]string{"alice": "loud", "bob": "quiet", "": "quiet"} {
+		if _, name, ok := s.For(user); !ok || name != want {
+			t.Errorf("%q gets %q, %v; want %q", user, name, ok, want)
+		}
+	}
+
+	if _, _, ok := New(Options{}).For("alice"); ok {
+		t.Error("a chain without profiles")
+	}
+}
+
+// TestProcessor runs the chain through a stand-in ffmpeg that copies its
+// input, so the Ogg framing both ways is what is tested.
+func TestProcessor(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script")
+	}
+
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+
+	err := os.WriteFile(ffmpeg, []byte("#!/bin/sh\nexec cat\n"), 0o700) //nolint:gosec // test script
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan []byte, 8)
+
+	p, err := New(Options{FFmpeg: ffmpeg, Profiles: map[string]Chain{"": {}}}).Start(t.Context(), Chain{}, func(b []byte) { got <- b })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 ms CELT frames, one long enough to span two Ogg segments.
+	sent := [][]byte{{0xF8, 1, 2, 3}, append([]byte{0xF8}, bytes.Repeat([]byte{7}, 300)...)}
+	for i, b := range sent {
+		p.Write(&rtp.Packet{Header: rtp.Header{SequenceNumber: uint16(i), Timestamp: uint32(i * 960)}, Payload: b}) //nolint:gosec // small
+	}
+
+	for _, want := range sent {
+		select {
+		case b := <-got:
+			if !bytes.Equal(b, want) {
+				t.Errorf("got %d bytes, want %d", len(b), len(want))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no packet out of the chain")
+		}
+	}
+
+	p.Close()
+
+	if p.Write(&rtp.Packet{Payload: sent[0]}) {
+		t.Error("a stopped chain took a packet")
+	}
+}
+
+// TestCapture replays a recorded Ogg/Opus stream in place of a sound device.
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script")
+	}
+
+	dir := t.TempDir()
+	rec := filepath.Join(dir, "mic.ogg")
+
+	f, err := os.Create(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ogg, err := oggwriter.NewWith(f, 48000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := []byte{0xF8, 9, 9, 9}
+
+	err = ogg.WriteRTP(&rtp.Packet{Payload: sent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = ogg.Close()
+
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+
+	err = os.WriteFile(ffmpeg, []byte("#!/bin/sh\nexec cat "+rec+"\n"), 0o700) //nolint:gosec // test script
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan []byte, 1)
+
+	cp, err := StartCapture(t.Context(), ffmpeg, "default", Chain{}, func(b []byte) { got <- b })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case b := <-got:
+		if !bytes.Equal(b, sent) {
+			t.Errorf("got % x, want % x", b, sent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing captured")
+	}
+
+	<-cp.Done()
+	cp.Close()
+}
@@ -0,0 +1,65 @@
+// Package tz renders timestamps in the operator's display timezone. Times are
+// kept and compared in UTC everywhere else; only what people read changes,
+// and every rendering carries its UTC offset so it stays unambiguous.
+package tz
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Layout is RFC 3339 with milliseconds and an explicit offset.
+const Layout = "2006-01-02T15:04:05.000Z07:00"
+
+var display atomic.Pointer[time.Location]
+
+// Set changes the display timezone. nil resets it to UTC.
+func Set(loc *time.Location) {
+	display.Store(loc)
+}
+
+// Location returns the display timezone, UTC by default.
+func Location() *time.Location {
+	if loc := display.Load(); loc != nil {
+		return loc
+	}
+
+	return time.UTC
+}
+
+// In converts t to the display timezone, e.g. before encoding it to JSON.
+func In(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+
+	return t.In(Location())
+}
+
+// Format renders t in the display timezone using Layout.
+func Format(t time.Time) string {
+	return t.In(Location()).Format(Layout)
+}
+
+// logWriter prefixes each log line with a display-zone timestamp, replacing
+// the standard logger's local-time date and time flags.
+type logWriter struct {
+	w io.Writer
+}
+
+// LogWriter wraps w for log.SetOutput; use it with log.SetFlags(0).
+func LogWriter(w io.Writer) io.Writer {
+	return logWriter{w: w}
+}
+
+func (l logWriter) Write(p []byte) (int, error) {
+	stamp := Format(time.Now()) + " "
+
+	_, err := io.WriteString(l.w, stamp+string(p))
+	if err != nil {
+		return 0, err //nolint:wrapcheck // pass the underlying writer's error through
+	}
+
+	return len(p), nil
+}
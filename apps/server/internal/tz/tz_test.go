@@ -0,0 +1,44 @@
+package tz
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisplayZone(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, 5, 1, 13, 30, 0, 123e6, time.UTC)
+
+	if got := Format(at); got != "2024-05-01T13:30:00.123Z" {
+		t.Errorf("default Format = %q, want UTC", got)
+	}
+
+	Set(time.FixedZone("EDT", -4*3600))
+	defer Set(nil)
+
+	if got := Format(at); got != "2024-05-01T09:30:00.123-04:00" {
+		t.Errorf("Format = %q", got)
+	}
+
+	if got := In(at); !got.Equal(at) || got.Location().String() != "EDT" {
+		t.Errorf("In = %v, want the same instant in EDT", got)
+	}
+
+	if !In(time.Time{}).IsZero() {
+		t.Error("In should leave the zero time alone")
+	}
+
+	var buf bytes.Buffer
+
+	_, err := LogWriter(&buf).Write([]byte("[rtc] hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(buf.String(), "-04:00 [rtc] hello\n") {
+		t.Errorf("log line = %q", buf.String())
+	}
+}
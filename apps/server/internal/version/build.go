@@ -0,0 +1,86 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Commit and Date are overridden at build time by GoReleaser via -X ldflags;
+// otherwise they come from the VCS stamp Go embeds in a checkout's build.
+var (
+	Commit = "" //nolint:gochecknoglobals
+	Date   = "" //nolint:gochecknoglobals
+)
+
+// Path is where the bridge serves its build.
+const Path = "/api/version"
+
+const pionModule = "github.com/pion/webrtc/v4"
+
+// Info identifies the exact build, for bug reports.
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`
+	Go      string `json:"go"`
+	Pion    string `json:"pion,omitempty"`
+}
+
+// Build returns the running binary's build, with v as its version.
+func Build(v string) Info {
+	info := Info{Version: v, Commit: Commit, Date: Date, Go: runtime.Version()}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.Date == "":
+			info.Date = s.Value
+		}
+	}
+
+	for _, dep := range bi.Deps {
+		if dep.Path == pionModule {
+			info.Pion = dep.Version
+		}
+	}
+
+	return info
+}
+
+// String is the build on one line, as the bridge logs it at startup.
+func (i Info) String() string {
+	parts := []string{"solid-sdr-server " + i.Version}
+
+	if i.Commit != "" {
+		parts = append(parts, "commit "+i.Commit)
+	}
+
+	if i.Date != "" {
+		parts = append(parts, "built "+i.Date)
+	}
+
+	parts = append(parts, i.Go)
+
+	if i.Pion != "" {
+		parts = append(parts, "pion "+i.Pion)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Handler serves i as JSON.
+func Handler(i Info) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(i)
+	}
+}
@@ -0,0 +1,346 @@
+// Package waterfall renders the radio's waterfall into PNG tiles, so a
+// client can draw an image instead of colouring every bin of every line
+// itself. Tiles are a few lines each, palettized and optionally narrowed,
+// which costs a phone far less CPU and a slow link far fewer bytes than the
+// raw lines.
+package waterfall
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strconv"
+)
+
+// tileHeader is the size of the header before a waterfall packet's bins:
+// low frequency and bin bandwidth (64-bit, Hz with 20 fractional bits), line
+// duration in ms, width and height in bins, timecode, auto black level, and
+// the frame's total bins and this packet's first bin.
+const tileHeader = 36
+
+const (
+	// DefaultRows is how many lines a tile holds unless asked otherwise.
+	DefaultRows = 4
+	maxRows     = 64
+	// peakDecay is how much of the gap to a quieter tile's peak the
+	// brightness scale closes each tile, so it adapts without flickering.
+	peakDecay = 0.1
+)
+
+// Palettes.
+const (
+	PaletteColor = "color"
+	PaletteGray  = "gray"
+)
+
+var (
+	errShortPacket = errors.New("waterfall packet too short")
+	errOption      = errors.New("invalid waterfall option")
+)
+
+// Options shape the tiles.
+type Options struct {
+	// Width narrows each line to this many columns, keeping each column's
+	// strongest bin; zero keeps every bin.
+	Width int
+	// Rows is how many lines each tile holds; zero means DefaultRows.
+	Rows    int
+	Palette string
+}
+
+// ParseOptions reads Options from a query string such as
+// "width=512&rows=8&palette=gray".
+func ParseOptions(query string) (Options, error) {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return Options{}, fmt.Errorf("%w: %w", errOption, err)
+	}
+
+	opt := Options{Palette: PaletteColor}
+
+	for key, dst := range map[string]*int{"width": &opt.Width, "rows": &opt.Rows} {
+		s := q.Get(key)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return Options{}, fmt.Errorf("%w: %s=%q", errOption, key, s)
+		}
+
+		*dst = n
+	}
+
+	if opt.Rows > maxRows {
+		return Options{}, fmt.Errorf("%w: rows above %d", errOption, maxRows)
+	}
+
+	switch p := q.Get("palette"); p {
+	case "", PaletteColor:
+	case PaletteGray:
+		opt.Palette = p
+	default:
+		return Options{}, fmt.Errorf("%w: palette=%q", errOption, p)
+	}
+
+	return opt, nil
+}
+
+// Tile is a rendered run of lines from one waterfall.
+type Tile struct {
+	Stream uint32 `json:"stream"`
+	// Timecode is the first line's.
+	Timecode uint32 `json:"timecode"`
+	Rows     int    `json:"rows"`
+	// LowFreqHz is the frequency of the left edge, and BinHz the width of
+	// a column, after any narrowing.
+	LowFreqHz float64 `json:"lowFreqHz"`
+	BinHz     float64 `json:"binHz"`
+	LineMs    uint32  `json:"lineMs"`
+	PNG       []byte  `json:"-"`
+}
+
+// Renderer turns waterfall packets into tiles. It is not safe for
+// concurrent use.
+type Renderer struct {
+	opt     Options
+	palette color.Palette
+	streams map[uint32]*stream
+}
+
+// stream is one waterfall's line being assembled and the lines waiting to
+// fill a tile.
+type stream struct {
+	timecode uint32
+	line     []uint16
+	filled   int
+	black    uint32
+	lowFreq  float64
+	binHz    float64
+	lineMs   uint32
+	started  bool
+
+	// tile describes the waiting lines.
+	tile   Tile
+	rows   [][]uint16
+	blacks []uint32
+	peak   float64
+}
+
+func New(opt Options) *Renderer {
+	if opt.Rows <= 0 {
+		opt.Rows = DefaultRows
+	}
+
+	pal := colorPalette()
+	if opt.Palette == PaletteGray {
+		pal = grayPalette()
+	}
+
+	return &Renderer{opt: opt, palette: pal, streams: make(map[uint32]*stream)}
+}
+
+// Add takes the payload of a waterfall packet of stream and returns the
+// tiles it completes, usually none or one.
+func (r *Renderer) Add(streamID uint32, payload []byte) ([]*Tile, error) {
+	if len(payload) < tileHeader {
+		return nil, errShortPacket
+	}
+
+	lowFreq := int64(binary.BigEndian.Uint64(payload[0:])) //nolint:gosec // a signed field
+	binHz := int64(binary.BigEndian.Uint64(payload[8:]))   //nolint:gosec // likewise
+	lineMs := binary.BigEndian.Uint32(payload[16:])
+	width := int(binary.BigEndian.Uint16(payload[20:]))
+	height := int(binary.BigEndian.Uint16(payload[22:]))
+	timecode := binary.BigEndian.Uint32(payload[24:])
+	black := binary.BigEndian.Uint32(payload[28:])
+	total := int(binary.BigEndian.Uint16(payload[32:]))
+	first := int(binary.BigEndian.Uint16(payload[34:]))
+
+	bins := payload[tileHeader:]
+	if len(bins) < 2*width*height || first+width > total {
+		return nil, errShortPacket
+	}
+
+	s := r.streams[streamID]
+	if s == nil {
+		s = &stream{}
+		r.streams[streamID] = s
+	}
+
+	var tiles []*Tile
+
+	for row := range height {
+		code := timecode + uint32(row) //nolint:gosec // height is a uint16
+
+		// A line cut short by loss is drawn with what arrived.
+		if s.started && (code != s.timecode || len(s.line) != total) {
+			tiles = r.finishLine(tiles, streamID, s)
+		}
+
+		if !s.started {
+			s.started = true
+			s.timecode = code
+			s.line = make([]uint16, total)
+			s.filled = 0
+			s.black = black
+			s.lowFreq = float64(lowFreq) / (1 << 20)
+			s.binHz = float64(binHz) / (1 << 20)
+			s.lineMs = lineMs
+		}
+
+		for i := range width {
+			s.line[first+i] = binary.BigEndian.Uint16(bins[2*(row*width+i):])
+		}
+
+		s.filled += width
+		if s.filled >= total {
+			tiles = r.finishLine(tiles, streamID, s)
+		}
+	}
+
+	return tiles, nil
+}
+
+// finishLine moves the line being assembled to the tile being filled,
+// adding the tile to tiles once it is full. A line of another width, as
+// when the panadapter is zoomed, ends the tile early.
+func (r *Renderer) finishLine(tiles []*Tile, streamID uint32, s *stream) []*Tile {
+	row := narrow(s.line, r.opt.Width)
+	s.started = false
+
+	if len(s.rows) > 0 && len(row) != len(s.rows[0]) {
+		tiles = append(tiles, r.render(s))
+	}
+
+	if len(s.rows) == 0 {
+		s.tile = Tile{
+			Stream:    streamID,
+			Timecode:  s.timecode,
+			LowFreqHz: s.lowFreq,
+			BinHz:     s.binHz * float64(len(s.line)) / float64(max(len(row), 1)),
+			LineMs:    s.lineMs,
+		}
+	}
+
+	s.rows = append(s.rows, row)
+	s.blacks = append(s.blacks, s.black)
+
+	if len(s.rows) < r.opt.Rows {
+		return tiles
+	}
+
+	return append(tiles, r.render(s))
+}
+
+// render draws a stream's waiting lines, scaling each from its black level
+// to the stream's recent peak, and clears them.
+func (r *Renderer) render(s *stream) *Tile {
+	width := len(s.rows[0])
+	img := image.NewPaletted(image.Rect(0, 0, width, len(s.rows)), r.palette)
+
+	var peak float64
+
+	for y, row := range s.rows {
+		for _, v := range row {
+			peak = max(peak, float64(v)-float64(s.blacks[y]))
+		}
+	}
+
+	if peak > s.peak {
+		s.peak = peak
+	} else {
+		s.peak -= (s.peak - peak) * peakDecay
+	}
+
+	scale := float64(len(r.palette)-1) / max(s.peak, 1)
+
+	for y, row := range s.rows {
+		black := float64(s.blacks[y])
+
+		for x, v := range row {
+			level := (float64(v) - black) * scale
+			img.Pix[y*img.Stride+x] = uint8(min(max(level, 0), float64(len(r.palette)-1)))
+		}
+	}
+
+	var buf bytes.Buffer
+
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	_ = enc.Encode(&buf, img) // writing to a bytes.Buffer cannot fail
+
+	tile := s.tile
+	tile.Rows = len(s.rows)
+	tile.PNG = buf.Bytes()
+
+	s.rows, s.blacks = nil, nil
+
+	return &tile
+}
+
+// narrow reduces line to width columns, each the strongest of the bins it
+// covers, so a narrow signal is not averaged away. It returns line as it
+// is when width is zero or no narrower.
+func narrow(line []uint16, width int) []uint16 {
+	if width <= 0 || width >= len(line) {
+		return line
+	}
+
+	out := make([]uint16, width)
+
+	for x := range out {
+		lo := x * len(line) / width
+		hi := max((x+1)*len(line)/width, lo+1)
+
+		out[x] = line[lo]
+		for _, v := range line[lo+1 : hi] {
+			out[x] = max(out[x], v)
+		}
+	}
+
+	return out
+}
+
+func grayPalette() color.Palette {
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.Gray{Y: uint8(i)} //nolint:gosec // i < 256
+	}
+
+	return pal
+}
+
+// colorPalette runs black, blue, cyan, green, yellow, red to white, the
+// usual waterfall scale.
+func colorPalette() color.Palette {
+	stops := []color.RGBA{
+		{0, 0, 0, 255},
+		{0, 0, 160, 255},
+		{0, 160, 224, 255},
+		{0, 192, 0, 255},
+		{240, 240, 0, 255},
+		{240, 0, 0, 255},
+		{255, 255, 255, 255},
+	}
+
+	pal := make(color.Palette, 256)
+	segments := len(stops) - 1
+
+	for i := range pal {
+		pos := float64(i) * float64(segments) / 255
+		seg := min(int(pos), segments-1)
+		f := pos - float64(seg)
+		a, b := stops[seg], stops[seg+1]
+
+		mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+		pal[i] = color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 255}
+	}
+
+	return pal
+}
@@ -0,0 +1,109 @@
+package waterfall
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// packet builds a waterfall packet of one line's bins from first, of a frame
+// of total bins.
+func packet(timecode uint32, first, total int, bins []uint16) []byte {
+	b := make([]byte, tileHeader+2*len(bins))
+	binary.BigEndian.PutUint64(b[0:], uint64(14_000_000)<<20)
+	binary.BigEndian.PutUint64(b[8:], uint64(100)<<20)
+	binary.BigEndian.PutUint32(b[16:], 50)
+	binary.BigEndian.PutUint16(b[20:], uint16(len(bins)))
+	binary.BigEndian.PutUint16(b[22:], 1)
+	binary.BigEndian.PutUint32(b[24:], timecode)
+	binary.BigEndian.PutUint32(b[28:], 1000)
+	binary.BigEndian.PutUint16(b[32:], uint16(total))
+	binary.BigEndian.PutUint16(b[34:], uint16(first))
+
+	for i, v := range bins {
+		binary.BigEndian.PutUint16(b[tileHeader+2*i:], v)
+	}
+
+	return b
+}
+
+func TestRenderer(t *testing.T) {
+	t.Parallel()
+
+	r := New(Options{Rows: 2, Width: 2})
+
+	var tiles []*Tile
+
+	for code := range uint32(2) {
+		// Each line arrives in two packets.
+		for _, p := range [][]byte{
+			packet(code, 0, 4, []uint16{1000, 1000}),
+			packet(code, 2, 4, []uint16{1000, 3000}),
+		} {
+			got, err := r.Add(7, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tiles = append(tiles, got...)
+		}
+	}
+
+	if len(tiles) != 1 {
+		t.Fatalf("%d tiles", len(tiles))
+	}
+
+	tile := tiles[0]
+	if tile.Stream != 7 || tile.Rows != 2 || tile.LowFreqHz != 14_000_000 || tile.BinHz != 200 || tile.LineMs != 50 {
+		t.Errorf("tile %+v", tile)
+	}
+
+	img, err := png.Decode(bytes.NewReader(tile.PNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pal, ok := img.(*image.Paletted)
+	if !ok || pal.Bounds().Dx() != 2 || pal.Bounds().Dy() != 2 {
+		t.Fatalf("image %T %v", img, img.Bounds())
+	}
+
+	// The left column is at the black level, the right the peak.
+	if pal.ColorIndexAt(0, 0) != 0 || pal.ColorIndexAt(1, 1) != 255 {
+		t.Errorf("pixels %d, %d", pal.ColorIndexAt(0, 0), pal.ColorIndexAt(1, 1))
+	}
+}
+
+func TestRenderer_ZoomEndsTile(t *testing.T) {
+	t.Parallel()
+
+	r := New(Options{Rows: 4})
+
+	_, _ = r.Add(1, packet(0, 0, 2, []uint16{1, 2}))
+
+	tiles, err := r.Add(1, packet(1, 0, 3, []uint16{1, 2, 3}))
+	if err != nil || len(tiles) != 1 || tiles[0].Rows != 1 {
+		t.Fatalf("tiles %v, %v", tiles, err)
+	}
+
+	if _, err := r.Add(1, []byte{1, 2}); err == nil {
+		t.Error("short packet accepted")
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	t.Parallel()
+
+	opt, err := ParseOptions("width=512&rows=8&palette=gray")
+	if err != nil || opt != (Options{Width: 512, Rows: 8, Palette: PaletteGray}) {
+		t.Errorf("ParseOptions = %+v, %v", opt, err)
+	}
+
+	for _, q := range []string{"rows=-1", "rows=1000", "palette=pink", "width=x"} {
+		if _, err := ParseOptions(q); err == nil {
+			t.Errorf("%q accepted", q)
+		}
+	}
+}
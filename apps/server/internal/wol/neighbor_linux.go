@@ -0,0 +1,44 @@
+package wol
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// Neighbor returns the MAC address the kernel's ARP table holds for ip, or
+// "" when it has none, as for a host on another subnet.
+func Neighbor(ip string) string {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return ""
+	}
+
+	defer func() { _ = f.Close() }()
+
+	return procARP(f, ip)
+}
+
+// procARP finds ip's complete entry in a /proc/net/arp table.
+func procARP(r io.Reader, ip string) string {
+	sc := bufio.NewScanner(r)
+	sc.Scan() // the header
+
+	for sc.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 || fields[0] != ip {
+			continue
+		}
+
+		// 0x2 is ATF_COM: the entry is resolved.
+		if fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			return ""
+		}
+
+		return fields[3]
+	}
+
+	return ""
+}
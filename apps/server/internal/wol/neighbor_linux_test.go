@@ -0,0 +1,29 @@
+package wol
+
+import (
+	"strings"
+	"testing"
+)
+
+const procNetARP = `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         a4:91:b1:0c:22:10     *        eth0
+192.168.1.20     0x1         0x2         00:1c:2d:05:37:2a     *        eth0
+192.168.1.31     0x1         0x0         00:00:00:00:00:00     *        eth0
+`
+
+func TestProcARP(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"192.168.1.20": "00:1c:2d:05:37:2a",
+		"192.168.1.31": "",
+		"192.168.1.2":  "",
+		"10.0.0.5":     "",
+	}
+
+	for ip, want := range cases {
+		if got := procARP(strings.NewReader(procNetARP), ip); got != want {
+			t.Errorf("procARP(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package wol wakes sleeping machines, such as a radio, with wake-on-LAN
+// magic packets, and finds the MAC address to wake from the host's neighbour
+// table while the machine is up.
+package wol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/sockopt"
+)
+
+// DefaultBroadcast is where magic packets go unless told otherwise.
+const DefaultBroadcast = "255.255.255.255:9"
+
+// ErrMAC is returned for an address that is not a 48-bit MAC.
+var ErrMAC = errors.New("invalid MAC address")
+
+// Send sends a magic packet for mac to addr, a broadcast host:port: six 0xff
+// bytes and then the MAC address sixteen times.
+func Send(mac, addr string) error {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return fmt.Errorf("%w: %q", ErrMAC, mac)
+	}
+
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for range 16 {
+		packet = append(packet, hw...)
+	}
+
+	d := net.Dialer{Control: sockopt.Broadcast}
+
+	conn, err := d.Dial("udp4", addr)
+	if err != nil {
+		return fmt.Errorf("wake: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write(packet)
+	if err != nil {
+		return fmt.Errorf("wake: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package wol
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = pc.Close() }()
+
+	err = Send("00:1C:2D:05:37:2A", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 200)
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 102 || buf[5] != 0xff || buf[6] != 0x00 || buf[11] != 0x2a || buf[101] != 0x2a {
+		t.Errorf("packet = % x", buf[:n])
+	}
+}
+
+func TestSend_RejectsMAC(t *testing.T) {
+	t.Parallel()
+
+	for _, mac := range []string{"", "not-a-mac", "00:00:5e:10:00:00:00:01"} {
+		if err := Send(mac, DefaultBroadcast); !errors.Is(err, ErrMAC) {
+			t.Errorf("Send(%q) = %v", mac, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package wsdeflate decides which WebSocket messages are compressed with
+// permessage-deflate. On a metered link, JSON status and the radio's lines
+// compress well; panadapter, waterfall and audio packets are already dense,
+// and small messages cost more to compress than they save, so neither is.
+//
+// Compression is negotiated without context takeover, the only mode gorilla
+// supports: each message is compressed on its own, so a connection holds no
+// compressor state between messages.
+package wsdeflate
+
+import (
+	"compress/flate"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// Policy compresses text messages of at least MinSize bytes. The zero
+// Policy compresses nothing and does not offer compression at all.
+type Policy struct {
+	MinSize int
+}
+
+// Enabled reports whether the policy offers compression to clients.
+func (p Policy) Enabled() bool { return p.MinSize > 0 }
+
+// Negotiate offers compression on up's upgrades when the policy is enabled.
+func (p Policy) Negotiate(up *websocket.Upgrader) {
+	up.EnableCompression = p.Enabled()
+}
+
+// Write writes one message, compressed when it is text, or status framed in
+// binary, that reaches MinSize. Media must pass compressible false, as a
+// connection that negotiated compression otherwise compresses every message.
+func (p Policy) Write(ws *websocket.Conn, msgType int, data []byte, compressible bool) error {
+	compress := compressible && p.Enabled() && len(data) >= p.MinSize
+
+	ws.EnableWriteCompression(compress)
+
+	if compress {
+		_ = ws.SetCompressionLevel(flate.BestSpeed) // a valid level never fails
+	}
+
+	err := ws.WriteMessage(msgType, data)
+	if err != nil {
+		return fmt.Errorf("wsdeflate: %w", err)
+	}
+
+	return nil
+}
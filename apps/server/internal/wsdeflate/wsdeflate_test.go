@@ -0,0 +1,127 @@
+package wsdeflate
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// countingConn counts the bytes read from the wire.
+type countingConn struct {
+	net.Conn
+
+	n *atomic.Int64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.n.Add(int64(n))
+
+	return n, err //nolint:wrapcheck // a test double
+}
+
+// exchange serves one connection under p, which writes one message, and
+// returns the bytes the client read, handshake included, and whether it
+// negotiated compression.
+func exchange(t *testing.T, p Policy, write func(ws *websocket.Conn) error) (int64, bool) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var up websocket.Upgrader
+
+		p.Negotiate(&up)
+
+		ws, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = ws.Close() }()
+
+		_ = write(ws)
+
+		_, _, _ = ws.ReadMessage()
+	}))
+	t.Cleanup(srv.Close)
+
+	var read atomic.Int64
+
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			c, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+
+			return countingConn{Conn: c, n: &read}, err
+		},
+	}
+
+	ws, resp, err := dialer.DialContext(t.Context(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	defer func() { _ = ws.Close() }()
+
+	negotiated := strings.Contains(resp.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate")
+
+	_, _, err = ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return read.Load(), negotiated
+}
+
+func TestPolicy_CompressesOnlyLargeText(t *testing.T) {
+	t.Parallel()
+
+	status := []byte(strings.Repeat(`{"type":"status","line":"S1|slice 0 mode=USB"}`, 100))
+	media := []byte(strings.Repeat("\x00\x01", 2000))
+	p := Policy{MinSize: 1024}
+
+	n, negotiated := exchange(t, p, func(ws *websocket.Conn) error {
+		return p.Write(ws, websocket.TextMessage, status, true)
+	})
+	if !negotiated {
+		t.Fatal("compression not negotiated")
+	}
+
+	if n >= int64(len(status))/4 {
+		t.Errorf("status read as %d of %d bytes", n, len(status))
+	}
+
+	n, _ = exchange(t, p, func(ws *websocket.Conn) error {
+		return p.Write(ws, websocket.BinaryMessage, media, false)
+	})
+	if n < int64(len(media)) {
+		t.Errorf("media compressed to %d bytes", n)
+	}
+
+	n, _ = exchange(t, p, func(ws *websocket.Conn) error {
+		return p.Write(ws, websocket.TextMessage, status[:512], true)
+	})
+	if n < 512 {
+		t.Errorf("small message compressed to %d bytes", n)
+	}
+}
+
+func TestPolicy_OffDoesNotNegotiate(t *testing.T) {
+	t.Parallel()
+
+	var p Policy
+
+	_, negotiated := exchange(t, p, func(ws *websocket.Conn) error {
+		return p.Write(ws, websocket.TextMessage, []byte("hi"), true)
+	})
+	if negotiated {
+		t.Error("compression negotiated while off")
+	}
+}
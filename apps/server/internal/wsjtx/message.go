@@ -0,0 +1,266 @@
+package wsjtx
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// magic starts every datagram; schema 2 is the Qt 5.0+ QDataStream layout
+// every WSJT-X since 1.5 reads.
+const (
+	magic  = 0xADBCCBDA
+	schema = 2
+)
+
+// Message types used by the bridge. WSJT-X sends the first five; the bridge
+// sends Reply and HaltTx.
+const (
+	typeHeartbeat = 0
+	typeStatus    = 1
+	typeDecode    = 2
+	typeClear     = 3
+	typeReply     = 4
+	typeClose     = 6
+	typeHaltTx    = 8
+)
+
+var (
+	errMagic     = errors.New("wsjtx: not a WSJT-X datagram")
+	errTruncated = errors.New("wsjtx: truncated message")
+)
+
+// Status is WSJT-X's state, sent whenever it changes.
+type Status struct {
+	DialHz       uint64 `json:"dialHz"`
+	Mode         string `json:"mode"`
+	DXCall       string `json:"dxCall,omitempty"`
+	Report       string `json:"report,omitempty"`
+	TXMode       string `json:"txMode,omitempty"`
+	TXEnabled    bool   `json:"txEnabled"`
+	Transmitting bool   `json:"transmitting"`
+	Decoding     bool   `json:"decoding"`
+	RXDF         uint32 `json:"rxDF"`
+	TXDF         uint32 `json:"txDF"`
+	DECall       string `json:"deCall,omitempty"`
+	DEGrid       string `json:"deGrid,omitempty"`
+	DXGrid       string `json:"dxGrid,omitempty"`
+	TXWatchdog   bool   `json:"txWatchdog,omitempty"`
+	SubMode      string `json:"subMode,omitempty"`
+	FastMode     bool   `json:"fastMode,omitempty"`
+	SpecialOp    uint8  `json:"specialOp,omitempty"`
+	Tolerance    uint32 `json:"tolerance,omitempty"`
+	TRPeriod     uint32 `json:"trPeriod,omitempty"`
+	Config       string `json:"config,omitempty"`
+	TXMessage    string `json:"txMessage,omitempty"`
+}
+
+// Decode is one decoded message. Replying with it starts a QSO as a double
+// click in WSJT-X's band activity window does.
+type Decode struct {
+	New           bool    `json:"new"`
+	Time          uint32  `json:"time"` // ms since midnight UTC
+	SNR           int32   `json:"snr"`
+	DT            float64 `json:"dt"` // seconds
+	DF            uint32  `json:"df"` // audio offset, Hz
+	Mode          string  `json:"mode"`
+	Message       string  `json:"message"`
+	LowConfidence bool    `json:"lowConfidence,omitempty"`
+	OffAir        bool    `json:"offAir,omitempty"`
+}
+
+// reader decodes QDataStream fields; after the first error every read
+// returns zero and err stays set.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		r.err = errTruncated
+
+		return nil
+	}
+
+	b := r.b[:n]
+	r.b = r.b[n:]
+
+	return b
+}
+
+func (r *reader) more() bool { return r.err == nil && len(r.b) > 0 }
+
+func (r *reader) u8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (r *reader) boolean() bool { return r.u8() != 0 }
+
+func (r *reader) u32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+
+	return 0
+}
+
+func (r *reader) u64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+
+	return 0
+}
+
+func (r *reader) f64() float64 { return math.Float64frombits(r.u64()) }
+
+// utf8 reads a QByteArray; 0xFFFFFFFF is a null array.
+func (r *reader) utf8() string {
+	n := r.u32()
+	if n == math.MaxUint32 {
+		return ""
+	}
+
+	return string(r.take(int(n)))
+}
+
+// header reads the envelope and returns the message type and instance id.
+func (r *reader) header() (uint32, string, error) {
+	if r.u32() != magic {
+		return 0, "", errMagic
+	}
+
+	_ = r.u32() // schema; later fields are read only while data remains
+	typ := r.u32()
+	id := r.utf8()
+
+	return typ, id, r.err
+}
+
+func (r *reader) status() Status {
+	var s Status
+
+	s.DialHz = r.u64()
+	s.Mode = r.utf8()
+	s.DXCall = r.utf8()
+	s.Report = r.utf8()
+	s.TXMode = r.utf8()
+	s.TXEnabled = r.boolean()
+	s.Transmitting = r.boolean()
+	s.Decoding = r.boolean()
+
+	// Fields added over WSJT-X releases; older versions stop early.
+	if r.more() {
+		s.RXDF = r.u32()
+		s.TXDF = r.u32()
+		s.DECall = r.utf8()
+		s.DEGrid = r.utf8()
+		s.DXGrid = r.utf8()
+	}
+
+	if r.more() {
+		s.TXWatchdog = r.boolean()
+		s.SubMode = r.utf8()
+		s.FastMode = r.boolean()
+	}
+
+	if r.more() {
+		s.SpecialOp = r.u8()
+	}
+
+	if r.more() {
+		s.Tolerance = r.u32()
+		s.TRPeriod = r.u32()
+		s.Config = r.utf8()
+	}
+
+	if r.more() {
+		s.TXMessage = r.utf8()
+	}
+
+	return s
+}
+
+func (r *reader) decode() Decode {
+	var d Decode
+
+	d.New = r.boolean()
+	d.Time = r.u32()
+	d.SNR = int32(r.u32()) //nolint:gosec // qint32 on the wire
+	d.DT = r.f64()
+	d.DF = r.u32()
+	d.Mode = r.utf8()
+	d.Message = r.utf8()
+	d.LowConfidence = r.boolean()
+
+	if r.more() {
+		d.OffAir = r.boolean()
+	}
+
+	return d
+}
+
+// writer encodes QDataStream fields.
+type writer struct {
+	b []byte
+}
+
+func newMessage(typ uint32, id string) *writer {
+	w := &writer{}
+	w.u32(magic)
+	w.u32(schema)
+	w.u32(typ)
+	w.utf8(id)
+
+	return w
+}
+
+func (w *writer) u8(v uint8) { w.b = append(w.b, v) }
+
+func (w *writer) boolean(v bool) {
+	if v {
+		w.u8(1)
+	} else {
+		w.u8(0)
+	}
+}
+
+func (w *writer) u32(v uint32) { w.b = binary.BigEndian.AppendUint32(w.b, v) }
+
+func (w *writer) u64(v uint64) { w.b = binary.BigEndian.AppendUint64(w.b, v) }
+
+func (w *writer) f64(v float64) { w.u64(math.Float64bits(v)) }
+
+func (w *writer) utf8(s string) {
+	w.u32(uint32(len(s))) //nolint:gosec // datagram-sized
+	w.b = append(w.b, s...)
+}
+
+// replyMessage asks WSJT-X to answer d, as a double click on it would.
+// modifiers are Qt keyboard modifiers, e.g. 0x02000000 for Shift.
+func replyMessage(id string, d Decode, modifiers uint8) []byte {
+	w := newMessage(typeReply, id)
+	w.u32(d.Time)
+	w.u32(uint32(d.SNR)) //nolint:gosec // qint32 on the wire
+	w.f64(d.DT)
+	w.u32(d.DF)
+	w.utf8(d.Mode)
+	w.utf8(d.Message)
+	w.boolean(d.LowConfidence)
+	w.u8(modifiers)
+
+	return w.b
+}
+
+// haltTxMessage stops transmitting, or with autoOnly just disables auto TX.
+func haltTxMessage(id string, autoOnly bool) []byte {
+	w := newMessage(typeHaltTx, id)
+	w.boolean(autoOnly)
+
+	return w.b
+}
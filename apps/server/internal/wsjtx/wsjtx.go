@@ -0,0 +1,255 @@
+// Package wsjtx speaks WSJT-X's UDP message protocol: it receives the status
+// and decodes WSJT-X (and JTDX, JS8Call) broadcast to their "UDP server", and
+// sends the Reply and Halt Tx messages those programs accept back.
+package wsjtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultAddr is WSJT-X's default UDP server address.
+const DefaultAddr = "127.0.0.1:2237"
+
+// Event types.
+const (
+	EventStatus = "status"
+	EventDecode = "decode"
+	EventClear  = "clear"
+	EventClose  = "close"
+)
+
+// Event is one message from a WSJT-X instance. Status is set for status
+// events and Decode for decodes.
+type Event struct {
+	Event  string  `json:"event"`
+	ID     string  `json:"id"`
+	Status *Status `json:"status,omitempty"`
+	Decode *Decode `json:"decode,omitempty"`
+}
+
+// Instance is a WSJT-X instance that has been heard from.
+type Instance struct {
+	ID       string    `json:"id"`
+	Status   *Status   `json:"status,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+
+	addr *net.UDPAddr
+}
+
+// ErrUnknownInstance is returned for an id that has not been heard from.
+var ErrUnknownInstance = errors.New("wsjtx: unknown instance")
+
+// instanceTTL forgets instances that stopped without a Close; WSJT-X sends
+// a heartbeat every 15 seconds.
+const instanceTTL = time.Minute
+
+// Service listens for WSJT-X instances.
+type Service struct {
+	conn *net.UDPConn
+
+	mu        sync.Mutex
+	instances map[string]*Instance
+	subs      []func(Event)
+}
+
+// Listen opens the UDP socket WSJT-X sends to. A multicast addr joins the
+// group on every interface, so several programs can share WSJT-X's output.
+func Listen(addr string) (*Service, error) {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("wsjtx address: %w", err)
+	}
+
+	var conn *net.UDPConn
+	if ua.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, ua)
+	} else {
+		conn, err = net.ListenUDP("udp", ua)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("wsjtx listen: %w", err)
+	}
+
+	return newService(conn), nil
+}
+
+func newService(conn *net.UDPConn) *Service {
+	return &Service{conn: conn, instances: make(map[string]*Instance)}
+}
+
+// Addr is the address the service listens on.
+func (s *Service) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Subscribe calls fn with every event from now on. fn runs on the receive
+// loop and must not block.
+func (s *Service) Subscribe(fn func(Event)) {
+	s.mu.Lock()
+	s.subs = append(s.subs, fn)
+	s.mu.Unlock()
+}
+
+// Run receives messages until ctx is done, then closes the socket.
+func (s *Service) Run(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() { _ = s.conn.Close() })
+	defer stop()
+
+	log.Printf("[wsjtx] listening on %s", s.conn.LocalAddr())
+
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("wsjtx read: %w", err)
+		}
+
+		s.handle(buf[:n], from, time.Now())
+	}
+}
+
+// handle decodes one datagram, tracks its sender and publishes it.
+// Messages the bridge has no use for are dropped.
+func (s *Service) handle(b []byte, from *net.UDPAddr, now time.Time) {
+	r := &reader{b: b}
+
+	typ, id, err := r.header()
+	if err != nil {
+		return
+	}
+
+	ev := Event{ID: id}
+
+	switch typ {
+	case typeHeartbeat:
+	case typeStatus:
+		st := r.status()
+		ev.Event, ev.Status = EventStatus, &st
+	case typeDecode:
+		d := r.decode()
+		ev.Event, ev.Decode = EventDecode, &d
+	case typeClear:
+		ev.Event = EventClear
+	case typeClose:
+		ev.Event = EventClose
+	default:
+		return
+	}
+
+	if r.err != nil {
+		log.Printf("[wsjtx] %s: message %d: %v", id, typ, r.err)
+
+		return
+	}
+
+	s.mu.Lock()
+	s.track(ev, from, now)
+	subs := s.subs
+	s.mu.Unlock()
+
+	if ev.Event == "" {
+		return
+	}
+
+	for _, fn := range subs {
+		fn(ev)
+	}
+}
+
+// track records where an instance sends from, which is where it expects
+// replies, and its latest status. Callers hold s.mu.
+func (s *Service) track(ev Event, from *net.UDPAddr, now time.Time) {
+	if ev.Event == EventClose {
+		delete(s.instances, ev.ID)
+
+		return
+	}
+
+	in := s.instances[ev.ID]
+	if in == nil {
+		in = &Instance{ID: ev.ID}
+		s.instances[ev.ID] = in
+		log.Printf("[wsjtx] instance %q at %s", ev.ID, from)
+	}
+
+	in.addr, in.LastSeen = from, now
+	if ev.Status != nil {
+		in.Status = ev.Status
+	}
+
+	for id, in := range s.instances {
+		if now.Sub(in.LastSeen) > instanceTTL {
+			delete(s.instances, id)
+		}
+	}
+}
+
+// Instances lists the instances heard from, by id.
+func (s *Service) Instances() []Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Instance, 0, len(s.instances))
+	for _, in := range s.instances {
+		out = append(out, *in)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
+
+// Status is the latest status of instance id.
+func (s *Service) Status(id string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	in := s.instances[id]
+	if in == nil || in.Status == nil {
+		return Status{}, ErrUnknownInstance
+	}
+
+	return *in.Status, nil
+}
+
+// Reply asks instance id to answer d, as a double click on the decode in
+// WSJT-X would.
+func (s *Service) Reply(id string, d Decode, modifiers uint8) error {
+	return s.send(id, replyMessage(id, d, modifiers))
+}
+
+// HaltTx stops instance id transmitting, or with autoOnly just turns off
+// its Enable Tx.
+func (s *Service) HaltTx(id string, autoOnly bool) error {
+	return s.send(id, haltTxMessage(id, autoOnly))
+}
+
+func (s *Service) send(id string, msg []byte) error {
+	s.mu.Lock()
+	in := s.instances[id]
+	s.mu.Unlock()
+
+	if in == nil {
+		return ErrUnknownInstance
+	}
+
+	_, err := s.conn.WriteToUDP(msg, in.addr)
+	if err != nil {
+		return fmt.Errorf("wsjtx send: %w", err)
+	}
+
+	return nil
+}
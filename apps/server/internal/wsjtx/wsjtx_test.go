@@ -0,0 +1,170 @@
+package wsjtx
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func statusMessage(id string, dialHz uint64) []byte {
+	w := newMessage(typeStatus, id)
+	w.u64(dialHz)
+	w.utf8("FT8")
+	w.utf8("K1ABC")
+	w.utf8("-10")
+	w.utf8("FT8")
+	w.boolean(true)  // tx enabled
+	w.boolean(false) // transmitting
+	w.boolean(true)  // decoding
+	w.u32(1500)
+	w.u32(1200)
+	w.utf8("N0CALL")
+	w.utf8("FN42")
+	w.utf8("")
+
+	return w.b
+}
+
+func decodeMessage(id string, d Decode) []byte {
+	w := newMessage(typeDecode, id)
+	w.boolean(d.New)
+	w.u32(d.Time)
+	w.u32(uint32(d.SNR)) //nolint:gosec // qint32 on the wire
+	w.f64(d.DT)
+	w.u32(d.DF)
+	w.utf8(d.Mode)
+	w.utf8(d.Message)
+	w.boolean(d.LowConfidence)
+	w.boolean(d.OffAir)
+
+	return w.b
+}
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	s := newService(nil)
+
+	var got []Event
+
+	s.Subscribe(func(ev Event) { got = append(got, ev) })
+
+	from := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}
+	now := time.Unix(1000, 0)
+
+	s.handle(statusMessage("WSJT-X", 14074000), from, now)
+
+	d := Decode{New: true, Time: 3600000, SNR: -12, DT: 0.3, DF: 1234, Mode: "~", Message: "CQ K1ABC FN42"}
+	s.handle(decodeMessage("WSJT-X", d), from, now)
+
+	s.handle([]byte("not a datagram"), from, now)
+	s.handle(decodeMessage("WSJT-X", d)[:30], from, now)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(got), got)
+	}
+
+	st := got[0].Status
+	if got[0].Event != EventStatus || st.DialHz != 14074000 || st.DXCall != "K1ABC" || !st.Decoding || st.RXDF != 1500 || st.DEGrid != "FN42" {
+		t.Errorf("status = %+v", st)
+	}
+
+	if got[1].Event != EventDecode || *got[1].Decode != d {
+		t.Errorf("decode = %+v", got[1].Decode)
+	}
+
+	if st, err := s.Status("WSJT-X"); err != nil || st.DialHz != 14074000 {
+		t.Errorf("Status = %+v, %v", st, err)
+	}
+
+	s.handle(newMessage(typeClose, "WSJT-X").b, from, now)
+
+	if len(s.Instances()) != 0 {
+		t.Errorf("instance kept after close: %+v", s.Instances())
+	}
+}
+
+func TestHandle_ForgetsSilentInstances(t *testing.T) {
+	t.Parallel()
+
+	s := newService(nil)
+	from := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}
+	now := time.Unix(1000, 0)
+
+	s.handle(newMessage(typeHeartbeat, "A").b, from, now)
+	s.handle(newMessage(typeHeartbeat, "B").b, from, now.Add(2*instanceTTL))
+
+	if in := s.Instances(); len(in) != 1 || in[0].ID != "B" {
+		t.Errorf("instances = %+v", in)
+	}
+}
+
+func TestService_Reply(t *testing.T) {
+	t.Parallel()
+
+	s, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = s.Run(t.Context()) }()
+
+	// The fake WSJT-X sends from its own socket and reads replies there.
+	app, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = app.Close() }()
+
+	err = s.Reply("WSJT-X", Decode{}, 0)
+	if err != ErrUnknownInstance { //nolint:errorlint // returned unwrapped
+		t.Errorf("Reply before heartbeat = %v", err)
+	}
+
+	seen := make(chan struct{})
+
+	s.Subscribe(func(ev Event) {
+		if ev.Event == EventStatus {
+			close(seen)
+		}
+	})
+
+	_, err = app.WriteToUDP(statusMessage("WSJT-X", 7074000), s.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-seen:
+	case <-time.After(5 * time.Second):
+		t.Fatal("status not received")
+	}
+
+	d := Decode{Time: 60000, SNR: 3, DT: -0.1, DF: 800, Mode: "~", Message: "CQ DX W1AW FN31"}
+
+	err = s.Reply("WSJT-X", d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = app.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	n, _, err := app.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &reader{b: buf[:n]}
+
+	typ, id, err := r.header()
+	if err != nil || typ != typeReply || id != "WSJT-X" {
+		t.Fatalf("header = %d %q %v", typ, id, err)
+	}
+
+	got := Decode{Time: r.u32(), SNR: int32(r.u32()), DT: r.f64(), DF: r.u32(), Mode: r.utf8(), Message: r.utf8()} //nolint:gosec // qint32
+	if got != d || r.boolean() || r.u8() != 0 || r.more() {
+		t.Errorf("reply = %+v", got)
+	}
+}
@@ -0,0 +1,366 @@
+// Package wtapi serves radio sessions over WebTransport, an alternative to
+// WebRTC for clients that can speak HTTP/3: the same session the signaling
+// endpoint gives a browser, with its streams on one QUIC connection instead
+// of data channels, so there is no ICE to negotiate and no TURN to relay.
+//
+// The client opens the session at Path with the query parameters radio
+// (host:port, required), udp, sandbox, station and bind, as for the gRPC
+// API's open request. It then opens bidirectional streams, each naming
+// itself by its first line:
+//
+//   - "tcp" carries command lines to the radio and the radio's lines back,
+//     as the "tcp" data channel does.
+//   - "signal" carries signaling messages, one JSON object per line, either
+//     way, as the signaling WebSocket does; messages that negotiate WebRTC
+//     are refused.
+//
+// The radio's lines and notices wait in the session's buffers until the
+// stream that carries them is open.
+//
+// UDP travels in datagrams, each a kind byte and its data: a whole VITA-49
+// packet (kindVITA) or an Opus packet of the radio's audio (kindOpus), either
+// way. Packets too large for a datagram, such as FFT and waterfall frames on
+// a small path MTU, go instead on the one unidirectional stream the server
+// opens, as records of a kind byte, a big-endian uint32 length and the data.
+package wtapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/webtransport-go"
+)
+
+// Path is where sessions are opened.
+const Path = "/wt"
+
+// Datagram and record kinds.
+const (
+	kindVITA byte = iota
+	kindOpus
+)
+
+// Stream names, each a stream's first line.
+const (
+	streamTCP    = "tcp"
+	streamSignal = "signal"
+)
+
+// recordHeader is the kind byte and length before each record on the
+// packets stream.
+const recordHeader = 5
+
+// Options configures the handler.
+type Options struct {
+	RTC *rtc.Server
+	// Server upgrades requests; it must share the HTTP/3 server this
+	// handler is served on.
+	Server *webtransport.Server
+}
+
+// Handler opens radio sessions over WebTransport.
+type Handler struct {
+	opt Options
+}
+
+func New(opt Options) *Handler {
+	return &Handler{opt: opt}
+}
+
+// ServeHTTP admits the session before upgrading, so a refusal is an HTTP
+// status the client can read, then serves it until either side ends it.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	hs, err := h.opt.RTC.OpenHeadless(r.Context(), rtc.HeadlessOptions{
+		Radio:        q.Get("radio"),
+		ClientIP:     ip,
+		UDP:          q.Get("udp") == "1",
+		Sandbox:      q.Get("sandbox") == "1",
+		Station:      q.Get("station"),
+		BindClientID: q.Get("bind"),
+	})
+	if err != nil {
+		code := http.StatusServiceUnavailable
+
+		var perr interface{ HTTPStatus() int }
+		if errors.As(err, &perr) {
+			code = perr.HTTPStatus()
+		}
+
+		http.Error(w, err.Error(), code)
+
+		return
+	}
+
+	defer hs.Close()
+
+	sess, err := h.opt.Server.Upgrade(w, r)
+	if err != nil {
+		log.Printf("[wt] upgrade from %s: %v", ip, err)
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	log.Printf("[wt] session %s from %s", hs.ID(), ip)
+
+	err = serve(sess, hs)
+	if err != nil {
+		log.Printf("[wt] session %s: %v", hs.ID(), err)
+	}
+
+	_ = sess.CloseWithError(0, "")
+}
+
+// conn is a WebTransport session carrying a radio session.
+type conn struct {
+	sess *webtransport.Session
+	hs   *rtc.Headless
+	// packets is the stream for oversized packets, opened on first use.
+	packets *webtransport.SendStream
+}
+
+// serve carries the radio session until it or the WebTransport session
+// ends. Everything sent to the client is sent from this goroutine.
+func serve(sess *webtransport.Session, hs *rtc.Headless) error {
+	ctx := sess.Context()
+	c := &conn{sess: sess, hs: hs}
+
+	tcpc := make(chan io.Writer, 1)
+	sigc := make(chan io.Writer, 1)
+	failed := make(chan error, 1)
+
+	go c.acceptStreams(ctx, tcpc, sigc, failed)
+	go c.receiveDatagrams(ctx)
+
+	var (
+		tcp, sig io.Writer
+		lines    <-chan string
+		notices  <-chan rtc.Notice
+	)
+
+	audio := hs.Audio()
+
+	for {
+		var err error
+
+		select {
+		case tcp = <-tcpc:
+			lines, tcpc = hs.Lines(), nil
+		case sig = <-sigc:
+			notices, sigc = hs.Notices(), nil
+		case line := <-lines:
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+
+			_, err = io.WriteString(tcp, line)
+		case n := <-notices:
+			err = writeJSONLine(sig, n)
+		case p := <-hs.Packets():
+			err = c.send(kindVITA, p.Raw)
+		case opus, ok := <-audio:
+			if !ok {
+				audio = nil
+
+				continue
+			}
+
+			err = c.send(kindOpus, opus)
+		case err = <-failed:
+		case <-hs.Done():
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// acceptStreams names each stream the client opens and hands its writing
+// side to serve. Streams of other names, or of a name already open, are
+// refused.
+func (c *conn) acceptStreams(ctx context.Context, tcpc, sigc chan<- io.Writer, failed chan<- error) {
+	seen := make(map[string]bool)
+
+	for {
+		str, err := c.sess.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+
+		rd := bufio.NewReader(str)
+
+		name, err := rd.ReadString('\n')
+		if err != nil {
+			str.CancelRead(0)
+			str.CancelWrite(0)
+
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+		if seen[name] || (name != streamTCP && name != streamSignal) {
+			str.CancelRead(0)
+			str.CancelWrite(0)
+
+			continue
+		}
+
+		seen[name] = true
+
+		read, out := c.readCommands, tcpc
+		if name == streamSignal {
+			read, out = c.readSignals, sigc
+		}
+
+		out <- str
+
+		go func() {
+			err := read(rd)
+			if err != nil {
+				select {
+				case failed <- fmt.Errorf("%s stream: %w", name, err):
+				default:
+				}
+			}
+		}()
+	}
+}
+
+// readCommands sends each line from the "tcp" stream to the radio.
+func (c *conn) readCommands(rd *bufio.Reader) error {
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil //nolint:nilerr // the client closed its side
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+
+		err = c.hs.Command(line)
+		if err != nil {
+			return fmt.Errorf("command: %w", err)
+		}
+	}
+}
+
+// readSignals handles each message from the "signal" stream.
+func (c *conn) readSignals(rd *bufio.Reader) error {
+	dec := json.NewDecoder(rd)
+
+	for {
+		var msg rtc.Notice
+
+		err := dec.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("signal: %w", err)
+		}
+
+		err = c.hs.Signal(msg.Type, msg.Payload)
+		if err != nil {
+			return fmt.Errorf("signal: %w", err)
+		}
+	}
+}
+
+// receiveDatagrams passes the client's packets to the radio. Like the UDP
+// they stand in for, they are dropped when they cannot be delivered.
+func (c *conn) receiveDatagrams(ctx context.Context) {
+	for {
+		b, err := c.sess.ReceiveDatagram(ctx)
+		if err != nil {
+			return
+		}
+
+		if len(b) < 2 {
+			continue
+		}
+
+		switch b[0] {
+		case kindVITA:
+			_ = c.hs.SendUDP(b[1:])
+		case kindOpus:
+			_ = c.hs.SendAudio(b[1:])
+		}
+	}
+}
+
+// send sends a packet in a datagram, or as a record on the packets stream
+// when it is too large for one.
+func (c *conn) send(kind byte, data []byte) error {
+	b := make([]byte, 1+len(data))
+	b[0] = kind
+	copy(b[1:], data)
+
+	err := c.sess.SendDatagram(b)
+
+	var tooLarge *quic.DatagramTooLargeError
+	if !errors.As(err, &tooLarge) {
+		return nil // datagrams are unreliable; a lost one is not an error
+	}
+
+	if c.packets == nil {
+		c.packets, err = c.sess.OpenUniStream()
+		if err != nil {
+			return fmt.Errorf("packets stream: %w", err)
+		}
+	}
+
+	_, err = c.packets.Write(encodeRecord(kind, data))
+	if err != nil {
+		return fmt.Errorf("packets stream: %w", err)
+	}
+
+	return nil
+}
+
+func encodeRecord(kind byte, data []byte) []byte {
+	b := make([]byte, recordHeader+len(data))
+	b[0] = kind
+	binary.BigEndian.PutUint32(b[1:recordHeader], uint32(len(data))) //nolint:gosec // a UDP packet
+	copy(b[recordHeader:], data)
+
+	return b
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("signal: %w", err)
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	if err != nil {
+		return fmt.Errorf("signal: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package wtapi
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc"
+	"github.com/daveisadork/solid-sdr/apps/server/internal/rtc/rtctest"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+func TestEncodeRecord(t *testing.T) {
+	t.Parallel()
+
+	got := encodeRecord(kindOpus, []byte("abc"))
+	if string(got) != "\x01\x00\x00\x00\x03abc" {
+		t.Errorf("encodeRecord = %q", got)
+	}
+}
+
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// listen starts a WebTransport server with the handler at Path and returns
+// its address.
+func listen(t *testing.T, rtcServer *rtc.Server) string {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	h3 := &http3.Server{
+		Handler:   mux,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{selfSigned(t)}}), //nolint:gosec // a test server
+	}
+	webtransport.ConfigureHTTP3Server(h3)
+
+	wt := &webtransport.Server{H3: h3}
+	mux.Handle(Path, New(Options{RTC: rtcServer, Server: wt}))
+
+	go func() { _ = wt.Serve(conn) }()
+
+	t.Cleanup(func() { _ = wt.Close() })
+
+	return conn.LocalAddr().String()
+}
+
+func dial(t *testing.T, addr, query string) (*http.Response, *webtransport.Session, error) {
+	t.Helper()
+
+	d := &webtransport.Dialer{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} //nolint:gosec // a self-signed test server
+	t.Cleanup(func() { _ = d.Close() })
+
+	return d.Dial(t.Context(), "https://"+addr+Path+"?"+query, nil) //nolint:wrapcheck // a test helper
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	rtcServer := rtc.New(nil, rtc.Options{ICEPortStart: 50000, ICEPortEnd: 50100})
+	addr := listen(t, rtcServer)
+	radio, _ := rtctest.FakeRadio(t)
+
+	_, sess, err := dial(t, addr, "radio="+radio)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = sess.CloseWithError(0, "") })
+
+	str, err := sess.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = str.Write([]byte("tcp\nC5|info\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = str.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	rd := bufio.NewReader(str)
+
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.HasPrefix(line, "R5|") {
+			if line != "R5|0|\n" {
+				t.Errorf("reply = %q", line)
+			}
+
+			break
+		}
+	}
+
+	if len(rtcServer.Sessions()) != 1 {
+		t.Errorf("sessions = %v", rtcServer.Sessions())
+	}
+}
+
+func TestServe_Refused(t *testing.T) {
+	t.Parallel()
+
+	rtcServer := rtc.New(nil, rtc.Options{ICEPortStart: 50000, ICEPortEnd: 50100, AllowedRadios: []string{"10.0.0.1"}})
+	addr := listen(t, rtcServer)
+	radio, _ := rtctest.FakeRadio(t)
+
+	resp, _, err := dial(t, addr, "radio="+radio)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("dial = %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,1673 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: solidsdr/bridge/v1/bridge.proto
+
+// The bridge's API for native clients: the radio list, the session list and
+// radio sessions, as the web client gets them over HTTP, the signaling
+// WebSocket and its data channels.
+
+package bridgev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListRadiosRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRadiosRequest) Reset() {
+	*x = ListRadiosRequest{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRadiosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRadiosRequest) ProtoMessage() {}
+
+func (x *ListRadiosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRadiosRequest.ProtoReflect.Descriptor instead.
+func (*ListRadiosRequest) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{0}
+}
+
+type ListRadiosResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Radios        []*Radio               `protobuf:"bytes,1,rep,name=radios,proto3" json:"radios,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRadiosResponse) Reset() {
+	*x = ListRadiosResponse{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRadiosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRadiosResponse) ProtoMessage() {}
+
+func (x *ListRadiosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRadiosResponse.ProtoReflect.Descriptor instead.
+func (*ListRadiosResponse) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListRadiosResponse) GetRadios() []*Radio {
+	if x != nil {
+		return x.Radios
+	}
+	return nil
+}
+
+type Radio struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Serial   string                 `protobuf:"bytes,1,opt,name=serial,proto3" json:"serial,omitempty"`
+	Model    string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	Nickname string                 `protobuf:"bytes,3,opt,name=nickname,proto3" json:"nickname,omitempty"`
+	Callsign string                 `protobuf:"bytes,4,opt,name=callsign,proto3" json:"callsign,omitempty"`
+	Version  string                 `protobuf:"bytes,5,opt,name=version,proto3" json:"version,omitempty"`
+	// host:port of the radio's API on the LAN; empty for SmartLink-only radios.
+	Address string `protobuf:"bytes,6,opt,name=address,proto3" json:"address,omitempty"`
+	Status  string `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	Online  bool   `protobuf:"varint,8,opt,name=online,proto3" json:"online,omitempty"`
+	// "lan", "smartlink" or both.
+	Sources       []string               `protobuf:"bytes,9,rep,name=sources,proto3" json:"sources,omitempty"`
+	LastSeen      *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Radio) Reset() {
+	*x = Radio{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Radio) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Radio) ProtoMessage() {}
+
+func (x *Radio) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Radio.ProtoReflect.Descriptor instead.
+func (*Radio) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Radio) GetSerial() string {
+	if x != nil {
+		return x.Serial
+	}
+	return ""
+}
+
+func (x *Radio) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Radio) GetNickname() string {
+	if x != nil {
+		return x.Nickname
+	}
+	return ""
+}
+
+func (x *Radio) GetCallsign() string {
+	if x != nil {
+		return x.Callsign
+	}
+	return ""
+}
+
+func (x *Radio) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Radio) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Radio) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Radio) GetOnline() bool {
+	if x != nil {
+		return x.Online
+	}
+	return false
+}
+
+func (x *Radio) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *Radio) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+type ListSessionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionsRequest) Reset() {
+	*x = ListSessionsRequest{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionsRequest) ProtoMessage() {}
+
+func (x *ListSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{3}
+}
+
+type ListSessionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sessions      []*Session             `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionsResponse) Reset() {
+	*x = ListSessionsResponse{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionsResponse) ProtoMessage() {}
+
+func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListSessionsResponse) GetSessions() []*Session {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+type Session struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ClientIp  string                 `protobuf:"bytes,2,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	Role      string                 `protobuf:"bytes,3,opt,name=role,proto3" json:"role,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	PeerState string                 `protobuf:"bytes,5,opt,name=peer_state,json=peerState,proto3" json:"peer_state,omitempty"`
+	// host:port of the radio, empty before the session connects to one.
+	Radio        string `protobuf:"bytes,6,opt,name=radio,proto3" json:"radio,omitempty"`
+	Handle       string `protobuf:"bytes,7,opt,name=handle,proto3" json:"handle,omitempty"`
+	Sandbox      bool   `protobuf:"varint,8,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	TcpFromRadio uint64 `protobuf:"varint,9,opt,name=tcp_from_radio,json=tcpFromRadio,proto3" json:"tcp_from_radio,omitempty"`
+	TcpToRadio   uint64 `protobuf:"varint,10,opt,name=tcp_to_radio,json=tcpToRadio,proto3" json:"tcp_to_radio,omitempty"`
+	UdpFromRadio uint64 `protobuf:"varint,11,opt,name=udp_from_radio,json=udpFromRadio,proto3" json:"udp_from_radio,omitempty"`
+	UdpToRadio   uint64 `protobuf:"varint,12,opt,name=udp_to_radio,json=udpToRadio,proto3" json:"udp_to_radio,omitempty"`
+	// client_id of the GUI client the session registered as, and of the one
+	// it is bound to.
+	GuiClientId string `protobuf:"bytes,13,opt,name=gui_client_id,json=guiClientId,proto3" json:"gui_client_id,omitempty"`
+	BoundClient string `protobuf:"bytes,14,opt,name=bound_client,json=boundClient,proto3" json:"bound_client,omitempty"`
+	// The radio has not answered the bridge's pings for --radio-timeout.
+	RadioUnhealthy bool `protobuf:"varint,15,opt,name=radio_unhealthy,json=radioUnhealthy,proto3" json:"radio_unhealthy,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Session) Reset() {
+	*x = Session{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Session) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Session) ProtoMessage() {}
+
+func (x *Session) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Session.ProtoReflect.Descriptor instead.
+func (*Session) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Session) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Session) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *Session) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *Session) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Session) GetPeerState() string {
+	if x != nil {
+		return x.PeerState
+	}
+	return ""
+}
+
+func (x *Session) GetRadio() string {
+	if x != nil {
+		return x.Radio
+	}
+	return ""
+}
+
+func (x *Session) GetHandle() string {
+	if x != nil {
+		return x.Handle
+	}
+	return ""
+}
+
+func (x *Session) GetSandbox() bool {
+	if x != nil {
+		return x.Sandbox
+	}
+	return false
+}
+
+func (x *Session) GetTcpFromRadio() uint64 {
+	if x != nil {
+		return x.TcpFromRadio
+	}
+	return 0
+}
+
+func (x *Session) GetTcpToRadio() uint64 {
+	if x != nil {
+		return x.TcpToRadio
+	}
+	return 0
+}
+
+func (x *Session) GetUdpFromRadio() uint64 {
+	if x != nil {
+		return x.UdpFromRadio
+	}
+	return 0
+}
+
+func (x *Session) GetUdpToRadio() uint64 {
+	if x != nil {
+		return x.UdpToRadio
+	}
+	return 0
+}
+
+func (x *Session) GetGuiClientId() string {
+	if x != nil {
+		return x.GuiClientId
+	}
+	return ""
+}
+
+func (x *Session) GetBoundClient() string {
+	if x != nil {
+		return x.BoundClient
+	}
+	return ""
+}
+
+func (x *Session) GetRadioUnhealthy() bool {
+	if x != nil {
+		return x.RadioUnhealthy
+	}
+	return false
+}
+
+type ConnectRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Request:
+	//
+	//	*ConnectRequest_Open
+	//	*ConnectRequest_Command
+	//	*ConnectRequest_Signal
+	Request       isConnectRequest_Request `protobuf_oneof:"request"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectRequest) Reset() {
+	*x = ConnectRequest{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectRequest) ProtoMessage() {}
+
+func (x *ConnectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectRequest.ProtoReflect.Descriptor instead.
+func (*ConnectRequest) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ConnectRequest) GetRequest() isConnectRequest_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetOpen() *Open {
+	if x != nil {
+		if x, ok := x.Request.(*ConnectRequest_Open); ok {
+			return x.Open
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetCommand() *Command {
+	if x != nil {
+		if x, ok := x.Request.(*ConnectRequest_Command); ok {
+			return x.Command
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetSignal() *Signal {
+	if x != nil {
+		if x, ok := x.Request.(*ConnectRequest_Signal); ok {
+			return x.Signal
+		}
+	}
+	return nil
+}
+
+type isConnectRequest_Request interface {
+	isConnectRequest_Request()
+}
+
+type ConnectRequest_Open struct {
+	Open *Open `protobuf:"bytes,1,opt,name=open,proto3,oneof"`
+}
+
+type ConnectRequest_Command struct {
+	Command *Command `protobuf:"bytes,2,opt,name=command,proto3,oneof"`
+}
+
+type ConnectRequest_Signal struct {
+	Signal *Signal `protobuf:"bytes,3,opt,name=signal,proto3,oneof"`
+}
+
+func (*ConnectRequest_Open) isConnectRequest_Request() {}
+
+func (*ConnectRequest_Command) isConnectRequest_Request() {}
+
+func (*ConnectRequest_Signal) isConnectRequest_Request() {}
+
+// Open connects the session to a radio.
+type Open struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// host:port of the radio's API, e.g. "192.168.1.50:4992".
+	Radio string `protobuf:"bytes,1,opt,name=radio,proto3" json:"radio,omitempty"`
+	// Receive the radio's UDP streams: meters, panadapter and waterfall
+	// packets and, once an Opus remote_audio_rx stream is created, audio.
+	Udp bool `protobuf:"varint,2,opt,name=udp,proto3" json:"udp,omitempty"`
+	// Start in the sandbox, where TX and configuration commands are answered
+	// by the bridge and never reach the radio.
+	Sandbox bool `protobuf:"varint,3,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	// Register as a multiFLEX GUI client with this station name.
+	Station string `protobuf:"bytes,4,opt,name=station,proto3" json:"station,omitempty"`
+	// Bind to a GUI client, by its client_id.
+	BindClientId  string `protobuf:"bytes,5,opt,name=bind_client_id,json=bindClientId,proto3" json:"bind_client_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Open) Reset() {
+	*x = Open{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Open) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Open) ProtoMessage() {}
+
+func (x *Open) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Open.ProtoReflect.Descriptor instead.
+func (*Open) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Open) GetRadio() string {
+	if x != nil {
+		return x.Radio
+	}
+	return ""
+}
+
+func (x *Open) GetUdp() bool {
+	if x != nil {
+		return x.Udp
+	}
+	return false
+}
+
+func (x *Open) GetSandbox() bool {
+	if x != nil {
+		return x.Sandbox
+	}
+	return false
+}
+
+func (x *Open) GetStation() string {
+	if x != nil {
+		return x.Station
+	}
+	return ""
+}
+
+func (x *Open) GetBindClientId() string {
+	if x != nil {
+		return x.BindClientId
+	}
+	return ""
+}
+
+// Command is sent to the radio as "C<sequence>|<command>". Its reply comes
+// back as a Reply with the same sequence.
+type Command struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sequence      uint32                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Command       string                 `protobuf:"bytes,2,opt,name=command,proto3" json:"command,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Command) Reset() {
+	*x = Command{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Command) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Command) ProtoMessage() {}
+
+func (x *Command) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Command.ProtoReflect.Descriptor instead.
+func (*Command) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Command) GetSequence() uint32 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Command) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+// Signal is a message of the signaling WebSocket, with its JSON payload:
+// e.g. "commandConfirm" to answer a "commandGuard", or "sandbox",
+// "audioGroup", "recording" and "wsjtx". WebRTC negotiation is not
+// available.
+type Signal struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Signal) Reset() {
+	*x = Signal{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Signal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Signal) ProtoMessage() {}
+
+func (x *Signal) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Signal.ProtoReflect.Descriptor instead.
+func (*Signal) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Signal) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Signal) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type ConnectResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Response:
+	//
+	//	*ConnectResponse_Connected
+	//	*ConnectResponse_Reply
+	//	*ConnectResponse_Status
+	//	*ConnectResponse_Message
+	//	*ConnectResponse_Line
+	//	*ConnectResponse_Meters
+	//	*ConnectResponse_Packet
+	//	*ConnectResponse_Audio
+	//	*ConnectResponse_Signal
+	Response      isConnectResponse_Response `protobuf_oneof:"response"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectResponse) Reset() {
+	*x = ConnectResponse{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectResponse) ProtoMessage() {}
+
+func (x *ConnectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectResponse.ProtoReflect.Descriptor instead.
+func (*ConnectResponse) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ConnectResponse) GetResponse() isConnectResponse_Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetConnected() *Connected {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Connected); ok {
+			return x.Connected
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetReply() *Reply {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Reply); ok {
+			return x.Reply
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetStatus() *Status {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Status); ok {
+			return x.Status
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetMessage() *RadioMessage {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Message); ok {
+			return x.Message
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetLine() *Line {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Line); ok {
+			return x.Line
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetMeters() *Meters {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Meters); ok {
+			return x.Meters
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetPacket() *Packet {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Packet); ok {
+			return x.Packet
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetAudio() *Audio {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Audio); ok {
+			return x.Audio
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetSignal() *Signal {
+	if x != nil {
+		if x, ok := x.Response.(*ConnectResponse_Signal); ok {
+			return x.Signal
+		}
+	}
+	return nil
+}
+
+type isConnectResponse_Response interface {
+	isConnectResponse_Response()
+}
+
+type ConnectResponse_Connected struct {
+	Connected *Connected `protobuf:"bytes,1,opt,name=connected,proto3,oneof"`
+}
+
+type ConnectResponse_Reply struct {
+	Reply *Reply `protobuf:"bytes,2,opt,name=reply,proto3,oneof"`
+}
+
+type ConnectResponse_Status struct {
+	Status *Status `protobuf:"bytes,3,opt,name=status,proto3,oneof"`
+}
+
+type ConnectResponse_Message struct {
+	Message *RadioMessage `protobuf:"bytes,4,opt,name=message,proto3,oneof"`
+}
+
+type ConnectResponse_Line struct {
+	Line *Line `protobuf:"bytes,5,opt,name=line,proto3,oneof"`
+}
+
+type ConnectResponse_Meters struct {
+	Meters *Meters `protobuf:"bytes,6,opt,name=meters,proto3,oneof"`
+}
+
+type ConnectResponse_Packet struct {
+	Packet *Packet `protobuf:"bytes,7,opt,name=packet,proto3,oneof"`
+}
+
+type ConnectResponse_Audio struct {
+	Audio *Audio `protobuf:"bytes,8,opt,name=audio,proto3,oneof"`
+}
+
+type ConnectResponse_Signal struct {
+	Signal *Signal `protobuf:"bytes,9,opt,name=signal,proto3,oneof"`
+}
+
+func (*ConnectResponse_Connected) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Reply) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Status) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Message) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Line) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Meters) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Packet) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Audio) isConnectResponse_Response() {}
+
+func (*ConnectResponse_Signal) isConnectResponse_Response() {}
+
+// Connected is the first response: the radio accepted the connection.
+type Connected struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// The radio's client handle for this session, e.g. "0x1234ABCD".
+	Handle string `protobuf:"bytes,2,opt,name=handle,proto3" json:"handle,omitempty"`
+	// The client_id the radio assigned, when Open registered a GUI client.
+	ClientId      string `protobuf:"bytes,3,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Connected) Reset() {
+	*x = Connected{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Connected) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Connected) ProtoMessage() {}
+
+func (x *Connected) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Connected.ProtoReflect.Descriptor instead.
+func (*Connected) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Connected) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *Connected) GetHandle() string {
+	if x != nil {
+		return x.Handle
+	}
+	return ""
+}
+
+func (x *Connected) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+// Reply answers a Command. A code of 0 is success.
+type Reply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sequence      uint32                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Code          uint32                 `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Reply) Reset() {
+	*x = Reply{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reply) ProtoMessage() {}
+
+func (x *Reply) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reply.ProtoReflect.Descriptor instead.
+func (*Reply) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Reply) GetSequence() uint32 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Reply) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Reply) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Status is one status line, split into the object it is about and its
+// attributes: "S1234ABCD|slice 0 RF_frequency=14.074000 mode=USB" is object
+// "slice 0" with two attributes.
+type Status struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Handle     string                 `protobuf:"bytes,1,opt,name=handle,proto3" json:"handle,omitempty"`
+	Object     string                 `protobuf:"bytes,2,opt,name=object,proto3" json:"object,omitempty"`
+	Attributes map[string]string      `protobuf:"bytes,3,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The line as received, for objects the split does not suit.
+	Raw           string `protobuf:"bytes,4,opt,name=raw,proto3" json:"raw,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Status) GetHandle() string {
+	if x != nil {
+		return x.Handle
+	}
+	return ""
+}
+
+func (x *Status) GetObject() string {
+	if x != nil {
+		return x.Object
+	}
+	return ""
+}
+
+func (x *Status) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Status) GetRaw() string {
+	if x != nil {
+		return x.Raw
+	}
+	return ""
+}
+
+// RadioMessage is a message the radio broadcasts, e.g. a warning.
+type RadioMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          uint32                 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RadioMessage) Reset() {
+	*x = RadioMessage{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RadioMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RadioMessage) ProtoMessage() {}
+
+func (x *RadioMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RadioMessage.ProtoReflect.Descriptor instead.
+func (*RadioMessage) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *RadioMessage) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *RadioMessage) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// Line is any other line from the radio, such as its version.
+type Line struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Raw           string                 `protobuf:"bytes,1,opt,name=raw,proto3" json:"raw,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Line) Reset() {
+	*x = Line{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Line) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Line) ProtoMessage() {}
+
+func (x *Line) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Line.ProtoReflect.Descriptor instead.
+func (*Line) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Line) GetRaw() string {
+	if x != nil {
+		return x.Raw
+	}
+	return ""
+}
+
+// Meters is one meter packet. Values are raw; each meter's "meter" status
+// gives its unit and scale.
+type Meters struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Meters        []*Meter               `protobuf:"bytes,1,rep,name=meters,proto3" json:"meters,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Meters) Reset() {
+	*x = Meters{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Meters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Meters) ProtoMessage() {}
+
+func (x *Meters) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Meters.ProtoReflect.Descriptor instead.
+func (*Meters) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Meters) GetMeters() []*Meter {
+	if x != nil {
+		return x.Meters
+	}
+	return nil
+}
+
+type Meter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Value         int32                  `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Meter) Reset() {
+	*x = Meter{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Meter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Meter) ProtoMessage() {}
+
+func (x *Meter) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Meter.ProtoReflect.Descriptor instead.
+func (*Meter) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Meter) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Meter) GetValue() int32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+// Packet is any other VITA-49 packet, e.g. panadapter or waterfall data.
+type Packet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClassCode     uint32                 `protobuf:"varint,1,opt,name=class_code,json=classCode,proto3" json:"class_code,omitempty"`
+	StreamId      uint32                 `protobuf:"varint,2,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Packet) Reset() {
+	*x = Packet{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Packet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Packet) ProtoMessage() {}
+
+func (x *Packet) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Packet.ProtoReflect.Descriptor instead.
+func (*Packet) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Packet) GetClassCode() uint32 {
+	if x != nil {
+		return x.ClassCode
+	}
+	return 0
+}
+
+func (x *Packet) GetStreamId() uint32 {
+	if x != nil {
+		return x.StreamId
+	}
+	return 0
+}
+
+func (x *Packet) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+// Audio is one Opus packet of the session's remote_audio_rx stream.
+type Audio struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Opus          []byte                 `protobuf:"bytes,1,opt,name=opus,proto3" json:"opus,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Audio) Reset() {
+	*x = Audio{}
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Audio) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Audio) ProtoMessage() {}
+
+func (x *Audio) ProtoReflect() protoreflect.Message {
+	mi := &file_solidsdr_bridge_v1_bridge_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Audio.ProtoReflect.Descriptor instead.
+func (*Audio) Descriptor() ([]byte, []int) {
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Audio) GetOpus() []byte {
+	if x != nil {
+		return x.Opus
+	}
+	return nil
+}
+
+var File_solidsdr_bridge_v1_bridge_proto protoreflect.FileDescriptor
+
+const file_solidsdr_bridge_v1_bridge_proto_rawDesc = "" +
+	"\n" +
+	"\x1fsolidsdr/bridge/v1/bridge.proto\x12\x12solidsdr.bridge.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x13\n" +
+	"\x11ListRadiosRequest\"G\n" +
+	"\x12ListRadiosResponse\x121\n" +
+	"\x06radios\x18\x01 \x03(\v2\x19.solidsdr.bridge.v1.RadioR\x06radios\"\xa4\x02\n" +
+	"\x05Radio\x12\x16\n" +
+	"\x06serial\x18\x01 \x01(\tR\x06serial\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\x12\x1a\n" +
+	"\bnickname\x18\x03 \x01(\tR\bnickname\x12\x1a\n" +
+	"\bcallsign\x18\x04 \x01(\tR\bcallsign\x12\x18\n" +
+	"\aversion\x18\x05 \x01(\tR\aversion\x12\x18\n" +
+	"\aaddress\x18\x06 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x12\x16\n" +
+	"\x06online\x18\b \x01(\bR\x06online\x12\x18\n" +
+	"\asources\x18\t \x03(\tR\asources\x127\n" +
+	"\tlast_seen\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\"\x15\n" +
+	"\x13ListSessionsRequest\"O\n" +
+	"\x14ListSessionsResponse\x127\n" +
+	"\bsessions\x18\x01 \x03(\v2\x1b.solidsdr.bridge.v1.SessionR\bsessions\"\xec\x03\n" +
+	"\aSession\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tclient_ip\x18\x02 \x01(\tR\bclientIp\x12\x12\n" +
+	"\x04role\x18\x03 \x01(\tR\x04role\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"peer_state\x18\x05 \x01(\tR\tpeerState\x12\x14\n" +
+	"\x05radio\x18\x06 \x01(\tR\x05radio\x12\x16\n" +
+	"\x06handle\x18\a \x01(\tR\x06handle\x12\x18\n" +
+	"\asandbox\x18\b \x01(\bR\asandbox\x12$\n" +
+	"\x0etcp_from_radio\x18\t \x01(\x04R\ftcpFromRadio\x12 \n" +
+	"\ftcp_to_radio\x18\n" +
+	" \x01(\x04R\n" +
+	"tcpToRadio\x12$\n" +
+	"\x0eudp_from_radio\x18\v \x01(\x04R\fudpFromRadio\x12 \n" +
+	"\fudp_to_radio\x18\f \x01(\x04R\n" +
+	"udpToRadio\x12\"\n" +
+	"\rgui_client_id\x18\r \x01(\tR\vguiClientId\x12!\n" +
+	"\fbound_client\x18\x0e \x01(\tR\vboundClient\x12'\n" +
+	"\x0fradio_unhealthy\x18\x0f \x01(\bR\x0eradioUnhealthy\"\xba\x01\n" +
+	"\x0eConnectRequest\x12.\n" +
+	"\x04open\x18\x01 \x01(\v2\x18.solidsdr.bridge.v1.OpenH\x00R\x04open\x127\n" +
+	"\acommand\x18\x02 \x01(\v2\x1b.solidsdr.bridge.v1.CommandH\x00R\acommand\x124\n" +
+	"\x06signal\x18\x03 \x01(\v2\x1a.solidsdr.bridge.v1.SignalH\x00R\x06signalB\t\n" +
+	"\arequest\"\x88\x01\n" +
+	"\x04Open\x12\x14\n" +
+	"\x05radio\x18\x01 \x01(\tR\x05radio\x12\x10\n" +
+	"\x03udp\x18\x02 \x01(\bR\x03udp\x12\x18\n" +
+	"\asandbox\x18\x03 \x01(\bR\asandbox\x12\x18\n" +
+	"\astation\x18\x04 \x01(\tR\astation\x12$\n" +
+	"\x0ebind_client_id\x18\x05 \x01(\tR\fbindClientId\"?\n" +
+	"\aCommand\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\rR\bsequence\x12\x18\n" +
+	"\acommand\x18\x02 \x01(\tR\acommand\"6\n" +
+	"\x06Signal\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\"\x88\x04\n" +
+	"\x0fConnectResponse\x12=\n" +
+	"\tconnected\x18\x01 \x01(\v2\x1d.solidsdr.bridge.v1.ConnectedH\x00R\tconnected\x121\n" +
+	"\x05reply\x18\x02 \x01(\v2\x19.solidsdr.bridge.v1.ReplyH\x00R\x05reply\x124\n" +
+	"\x06status\x18\x03 \x01(\v2\x1a.solidsdr.bridge.v1.StatusH\x00R\x06status\x12<\n" +
+	"\amessage\x18\x04 \x01(\v2 .solidsdr.bridge.v1.RadioMessageH\x00R\amessage\x12.\n" +
+	"\x04line\x18\x05 \x01(\v2\x18.solidsdr.bridge.v1.LineH\x00R\x04line\x124\n" +
+	"\x06meters\x18\x06 \x01(\v2\x1a.solidsdr.bridge.v1.MetersH\x00R\x06meters\x124\n" +
+	"\x06packet\x18\a \x01(\v2\x1a.solidsdr.bridge.v1.PacketH\x00R\x06packet\x121\n" +
+	"\x05audio\x18\b \x01(\v2\x19.solidsdr.bridge.v1.AudioH\x00R\x05audio\x124\n" +
+	"\x06signal\x18\t \x01(\v2\x1a.solidsdr.bridge.v1.SignalH\x00R\x06signalB\n" +
+	"\n" +
+	"\bresponse\"_\n" +
+	"\tConnected\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x16\n" +
+	"\x06handle\x18\x02 \x01(\tR\x06handle\x12\x1b\n" +
+	"\tclient_id\x18\x03 \x01(\tR\bclientId\"Q\n" +
+	"\x05Reply\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\rR\bsequence\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\rR\x04code\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\xd5\x01\n" +
+	"\x06Status\x12\x16\n" +
+	"\x06handle\x18\x01 \x01(\tR\x06handle\x12\x16\n" +
+	"\x06object\x18\x02 \x01(\tR\x06object\x12J\n" +
+	"\n" +
+	"attributes\x18\x03 \x03(\v2*.solidsdr.bridge.v1.Status.AttributesEntryR\n" +
+	"attributes\x12\x10\n" +
+	"\x03raw\x18\x04 \x01(\tR\x03raw\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"6\n" +
+	"\fRadioMessage\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\rR\x04code\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\"\x18\n" +
+	"\x04Line\x12\x10\n" +
+	"\x03raw\x18\x01 \x01(\tR\x03raw\";\n" +
+	"\x06Meters\x121\n" +
+	"\x06meters\x18\x01 \x03(\v2\x19.solidsdr.bridge.v1.MeterR\x06meters\"-\n" +
+	"\x05Meter\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x05R\x05value\"^\n" +
+	"\x06Packet\x12\x1d\n" +
+	"\n" +
+	"class_code\x18\x01 \x01(\rR\tclassCode\x12\x1b\n" +
+	"\tstream_id\x18\x02 \x01(\rR\bstreamId\x12\x18\n" +
+	"\apayload\x18\x03 \x01(\fR\apayload\"\x1b\n" +
+	"\x05Audio\x12\x12\n" +
+	"\x04opus\x18\x01 \x01(\fR\x04opus2\xa0\x02\n" +
+	"\x06Bridge\x12[\n" +
+	"\n" +
+	"ListRadios\x12%.solidsdr.bridge.v1.ListRadiosRequest\x1a&.solidsdr.bridge.v1.ListRadiosResponse\x12a\n" +
+	"\fListSessions\x12'.solidsdr.bridge.v1.ListSessionsRequest\x1a(.solidsdr.bridge.v1.ListSessionsResponse\x12V\n" +
+	"\aConnect\x12\".solidsdr.bridge.v1.ConnectRequest\x1a#.solidsdr.bridge.v1.ConnectResponse(\x010\x01BPZNgithub.com/daveisadork/solid-sdr/apps/server/proto/solidsdr/bridge/v1;bridgev1b\x06proto3"
+
+var (
+	file_solidsdr_bridge_v1_bridge_proto_rawDescOnce sync.Once
+	file_solidsdr_bridge_v1_bridge_proto_rawDescData []byte
+)
+
+func file_solidsdr_bridge_v1_bridge_proto_rawDescGZIP() []byte {
+	file_solidsdr_bridge_v1_bridge_proto_rawDescOnce.Do(func() {
+		file_solidsdr_bridge_v1_bridge_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_solidsdr_bridge_v1_bridge_proto_rawDesc), len(file_solidsdr_bridge_v1_bridge_proto_rawDesc)))
+	})
+	return file_solidsdr_bridge_v1_bridge_proto_rawDescData
+}
+
+var file_solidsdr_bridge_v1_bridge_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_solidsdr_bridge_v1_bridge_proto_goTypes = []any{
+	(*ListRadiosRequest)(nil),     // 0: solidsdr.bridge.v1.ListRadiosRequest
+	(*ListRadiosResponse)(nil),    // 1: solidsdr.bridge.v1.ListRadiosResponse
+	(*Radio)(nil),                 // 2: solidsdr.bridge.v1.Radio
+	(*ListSessionsRequest)(nil),   // 3: solidsdr.bridge.v1.ListSessionsRequest
+	(*ListSessionsResponse)(nil),  // 4: solidsdr.bridge.v1.ListSessionsResponse
+	(*Session)(nil),               // 5: solidsdr.bridge.v1.Session
+	(*ConnectRequest)(nil),        // 6: solidsdr.bridge.v1.ConnectRequest
+	(*Open)(nil),                  // 7: solidsdr.bridge.v1.Open
+	(*Command)(nil),               // 8: solidsdr.bridge.v1.Command
+	(*Signal)(nil),                // 9: solidsdr.bridge.v1.Signal
+	(*ConnectResponse)(nil),       // 10: solidsdr.bridge.v1.ConnectResponse
+	(*Connected)(nil),             // 11: solidsdr.bridge.v1.Connected
+	(*Reply)(nil),                 // 12: solidsdr.bridge.v1.Reply
+	(*Status)(nil),                // 13: solidsdr.bridge.v1.Status
+	(*RadioMessage)(nil),          // 14: solidsdr.bridge.v1.RadioMessage
+	(*Line)(nil),                  // 15: solidsdr.bridge.v1.Line
+	(*Meters)(nil),                // 16: solidsdr.bridge.v1.Meters
+	(*Meter)(nil),                 // 17: solidsdr.bridge.v1.Meter
+	(*Packet)(nil),                // 18: solidsdr.bridge.v1.Packet
+	(*Audio)(nil),                 // 19: solidsdr.bridge.v1.Audio
+	nil,                           // 20: solidsdr.bridge.v1.Status.AttributesEntry
+	(*timestamppb.Timestamp)(nil), // 21: google.protobuf.Timestamp
+}
+var file_solidsdr_bridge_v1_bridge_proto_depIdxs = []int32{
+	2,  // 0: solidsdr.bridge.v1.ListRadiosResponse.radios:type_name -> solidsdr.bridge.v1.Radio
+	21, // 1: solidsdr.bridge.v1.Radio.last_seen:type_name -> google.protobuf.Timestamp
+	5,  // 2: solidsdr.bridge.v1.ListSessionsResponse.sessions:type_name -> solidsdr.bridge.v1.Session
+	21, // 3: solidsdr.bridge.v1.Session.created_at:type_name -> google.protobuf.Timestamp
+	7,  // 4: solidsdr.bridge.v1.ConnectRequest.open:type_name -> solidsdr.bridge.v1.Open
+	8,  // 5: solidsdr.bridge.v1.ConnectRequest.command:type_name -> solidsdr.bridge.v1.Command
+	9,  // 6: solidsdr.bridge.v1.ConnectRequest.signal:type_name -> solidsdr.bridge.v1.Signal
+	11, // 7: solidsdr.bridge.v1.ConnectResponse.connected:type_name -> solidsdr.bridge.v1.Connected
+	12, // 8: solidsdr.bridge.v1.ConnectResponse.reply:type_name -> solidsdr.bridge.v1.Reply
+	13, // 9: solidsdr.bridge.v1.ConnectResponse.status:type_name -> solidsdr.bridge.v1.Status
+	14, // 10: solidsdr.bridge.v1.ConnectResponse.message:type_name -> solidsdr.bridge.v1.RadioMessage
+	15, // 11: solidsdr.bridge.v1.ConnectResponse.line:type_name -> solidsdr.bridge.v1.Line
+	16, // 12: solidsdr.bridge.v1.ConnectResponse.meters:type_name -> solidsdr.bridge.v1.Meters
+	18, // 13: solidsdr.bridge.v1.ConnectResponse.packet:type_name -> solidsdr.bridge.v1.Packet
+	19, // 14: solidsdr.bridge.v1.ConnectResponse.audio:type_name -> solidsdr.bridge.v1.Audio
+	9,  // 15: solidsdr.bridge.v1.ConnectResponse.signal:type_name -> solidsdr.bridge.v1.Signal
+	20, // 16: solidsdr.bridge.v1.Status.attributes:type_name -> solidsdr.bridge.v1.Status.AttributesEntry
+	17, // 17: solidsdr.bridge.v1.Meters.meters:type_name -> solidsdr.bridge.v1.Meter
+	0,  // 18: solidsdr.bridge.v1.Bridge.ListRadios:input_type -> solidsdr.bridge.v1.ListRadiosRequest
+	3,  // 19: solidsdr.bridge.v1.Bridge.ListSessions:input_type -> solidsdr.bridge.v1.ListSessionsRequest
+	6,  // 20: solidsdr.bridge.v1.Bridge.Connect:input_type -> solidsdr.bridge.v1.ConnectRequest
+	1,  // 21: solidsdr.bridge.v1.Bridge.ListRadios:output_type -> solidsdr.bridge.v1.ListRadiosResponse
+	4,  // 22: solidsdr.bridge.v1.Bridge.ListSessions:output_type -> solidsdr.bridge.v1.ListSessionsResponse
+	10, // 23: solidsdr.bridge.v1.Bridge.Connect:output_type -> solidsdr.bridge.v1.ConnectResponse
+	21, // [21:24] is the sub-list for method output_type
+	18, // [18:21] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_solidsdr_bridge_v1_bridge_proto_init() }
+func file_solidsdr_bridge_v1_bridge_proto_init() {
+	if File_solidsdr_bridge_v1_bridge_proto != nil {
+		return
+	}
+	file_solidsdr_bridge_v1_bridge_proto_msgTypes[6].OneofWrappers = []any{
+		(*ConnectRequest_Open)(nil),
+		(*ConnectRequest_Command)(nil),
+		(*ConnectRequest_Signal)(nil),
+	}
+	file_solidsdr_bridge_v1_bridge_proto_msgTypes[10].OneofWrappers = []any{
+		(*ConnectResponse_Connected)(nil),
+		(*ConnectResponse_Reply)(nil),
+		(*ConnectResponse_Status)(nil),
+		(*ConnectResponse_Message)(nil),
+		(*ConnectResponse_Line)(nil),
+		(*ConnectResponse_Meters)(nil),
+		(*ConnectResponse_Packet)(nil),
+		(*ConnectResponse_Audio)(nil),
+		(*ConnectResponse_Signal)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_solidsdr_bridge_v1_bridge_proto_rawDesc), len(file_solidsdr_bridge_v1_bridge_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   21,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_solidsdr_bridge_v1_bridge_proto_goTypes,
+		DependencyIndexes: file_solidsdr_bridge_v1_bridge_proto_depIdxs,
+		MessageInfos:      file_solidsdr_bridge_v1_bridge_proto_msgTypes,
+	}.Build()
+	File_solidsdr_bridge_v1_bridge_proto = out.File
+	file_solidsdr_bridge_v1_bridge_proto_goTypes = nil
+	file_solidsdr_bridge_v1_bridge_proto_depIdxs = nil
+}
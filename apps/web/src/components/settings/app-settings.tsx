@@ -119,7 +119,7 @@ export function AppSettings() {
   const { preferences, setPreferences } = usePreferences();
   const { setColorMode } = useColorMode();
   const { radio } = useFlexRadio();
-  const { serverVersion } = useRtc();
+  const { serverVersion, serverBuild } = useRtc();
   const [newRelease, setNewRelease] = createSignal(false);
   const [importFile, setImportFile] = createSignal<File>();
 
@@ -206,6 +206,21 @@ export function AppSettings() {
             </Show>
             <InfoItem label="Client Version" value={APP_VERSION} />
             <InfoItem label="Server Version" value={serverVersion() ?? "—"} />
+            <Show when={serverBuild()}>
+              {(build) => (
+                <InfoItem
+                  label="Server Build"
+                  value={[
+                    build().commit?.slice(0, 12),
+                    build().date,
+                    build().go,
+                    build().pion && `pion ${build().pion}`,
+                  ]
+                    .filter(Boolean)
+                    .join(" · ")}
+                />
+              )}
+            </Show>
           </CardContent>
           <CardFooter class="flex flex-col sm:flex-row sm:justify-end gap-2 items-stretch">
             <LicenseInfo />
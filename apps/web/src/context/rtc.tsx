@@ -37,15 +37,25 @@ type RtcContextValue = {
   signalingWs: ReconnectingWebSocket;
   signalingWsState: Accessor<0 | 1 | 2 | 3>;
   serverVersion: Accessor<string | null>;
+  serverBuild: Accessor<ServerBuild | null>;
+};
+
+export type ServerBuild = {
+  version: string;
+  commit?: string;
+  date?: string;
+  go: string;
+  pion?: string;
 };
 
 type SignalingMessage =
+  | { type: "offer"; payload: RTCSessionDescriptionInit }
   | { type: "answer"; payload: RTCSessionDescriptionInit }
   | { type: "ice"; payload: RTCIceCandidateInit }
   | { type: "error"; payload: { code: string; message: string } }
   | { type: "ping"; payload: null }
   | { type: "pong"; payload: null }
-  | { type: "version"; payload: { version: string } };
+  | { type: "version"; payload: { version: string; build?: ServerBuild } };
 
 export const RtcProvider: ParentComponent = (props) => {
   const [rtcState, setRtcState] = createStore<RtcState>({
@@ -57,6 +67,7 @@ export const RtcProvider: ParentComponent = (props) => {
   const [peerConnection, setPeerConnection] =
     createSignal<RTCPeerConnection | null>(null);
   const [serverVersion, setServerVersion] = createSignal<string | null>(null);
+  const [serverBuild, setServerBuild] = createSignal<ServerBuild | null>(null);
   const [audioTransceiver, setAudioTransceiver] =
     createSignal<RTCRtpTransceiver | null>(null);
   const [remoteAudioRxStream, setRemoteAudioRxStream] =
@@ -98,15 +109,42 @@ export const RtcProvider: ParentComponent = (props) => {
     }
   }
 
+  // Perfect negotiation: the bridge is the impolite peer, so when its offer
+  // crosses ours we roll ours back (setRemoteDescription does it implicitly),
+  // answer, and renegotiate afterwards.
+  let makingOffer = false;
+
   async function onNegotiationNeeded(this: RTCPeerConnection) {
-    const offer = await this.createOffer();
-    if (!offer.sdp) return;
-    const sdp = forceStereoInSDP(offer.sdp);
-    await this.setLocalDescription({ ...offer, sdp });
+    try {
+      makingOffer = true;
+      const offer = await this.createOffer();
+      if (!offer.sdp) return;
+      const sdp = forceStereoInSDP(offer.sdp);
+      await this.setLocalDescription({ ...offer, sdp });
+      signalingWs.send(
+        JSON.stringify({
+          type: "offer",
+          payload: this.localDescription.toJSON(),
+        }),
+      );
+    } finally {
+      makingOffer = false;
+    }
+  }
+
+  async function onRemoteOffer(
+    pc: RTCPeerConnection,
+    offer: RTCSessionDescriptionInit,
+  ) {
+    if (makingOffer) {
+      console.debug("[rtc] offer collision, yielding to the bridge");
+    }
+    await pc.setRemoteDescription(offer);
+    await pc.setLocalDescription();
     signalingWs.send(
       JSON.stringify({
-        type: "offer",
-        payload: this.localDescription.toJSON(),
+        type: "answer",
+        payload: pc.localDescription?.toJSON(),
       }),
     );
   }
@@ -122,8 +160,20 @@ export const RtcProvider: ParentComponent = (props) => {
   const onMessage = (ev: MessageEvent) => {
     const { type, payload } = JSON.parse(ev.data) as SignalingMessage;
     switch (type) {
+      case "offer": {
+        const pc = peerConnection();
+        if (pc) {
+          onRemoteOffer(pc, payload).catch((e) =>
+            console.warn("[rtc] renegotiation:", e),
+          );
+        }
+        break;
+      }
       case "answer": {
-        peerConnection()?.setRemoteDescription(payload);
+        const pc = peerConnection();
+        if (pc?.signalingState === "have-local-offer") {
+          pc.setRemoteDescription(payload);
+        }
         break;
       }
       case "ice": {
@@ -140,6 +190,7 @@ export const RtcProvider: ParentComponent = (props) => {
       }
       case "version": {
         setServerVersion(payload.version);
+        setServerBuild(payload.build ?? null);
         break;
       }
     }
@@ -245,6 +296,7 @@ export const RtcProvider: ParentComponent = (props) => {
         setRemoteAudioTxTrack,
         signalingWsState,
         serverVersion,
+        serverBuild,
       }}
     >
       {props.children}